@@ -18,8 +18,6 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
 	"github.com/aws/amazon-ecs-agent/agent/api"
-	apiappmesh "github.com/aws/amazon-ecs-agent/agent/api/appmesh"
-	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
@@ -198,71 +196,13 @@ func (payloadHandler *payloadRequestHandler) addPayloadTasks(payload *ecsacs.Pay
 			allTasksOK = false
 			continue
 		}
-		apiTask, err := apitask.TaskFromACS(task, payload)
+		apiTask, err := apitask.TaskFromACSWithCredentials(task, payload, payloadHandler.credentialsManager)
 		if err != nil {
 			payloadHandler.handleUnrecognizedTask(task, err, payload)
 			allTasksOK = false
 			continue
 		}
 
-		if task.RoleCredentials != nil {
-			// The payload from ACS for the task has credentials for the
-			// task. Add those to the credentials manager and set the
-			// credentials id for the task as well
-			taskIAMRoleCredentials := credentials.IAMRoleCredentialsFromACS(task.RoleCredentials, credentials.ApplicationRoleType)
-			err = payloadHandler.credentialsManager.SetTaskCredentials(
-				&(credentials.TaskIAMRoleCredentials{
-					ARN:                aws.StringValue(task.Arn),
-					IAMRoleCredentials: taskIAMRoleCredentials,
-				}))
-			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
-				continue
-			}
-			apiTask.SetCredentialsID(taskIAMRoleCredentials.CredentialsID)
-		}
-
-		// Add ENI information to the task struct.
-		for _, acsENI := range task.ElasticNetworkInterfaces {
-			eni, err := apieni.ENIFromACS(acsENI)
-			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
-				continue
-			}
-			apiTask.AddTaskENI(eni)
-		}
-
-		// Add the app mesh information to task struct
-		if task.ProxyConfiguration != nil {
-			appmesh, err := apiappmesh.AppMeshFromACS(task.ProxyConfiguration)
-			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
-				continue
-			}
-			apiTask.SetAppMesh(appmesh)
-		}
-
-		if task.ExecutionRoleCredentials != nil {
-			// The payload message contains execution credentials for the task.
-			// Add the credentials to the credentials manager and set the
-			// task executionCredentials id.
-			taskExecutionIAMRoleCredentials := credentials.IAMRoleCredentialsFromACS(task.ExecutionRoleCredentials, credentials.ExecutionRoleType)
-			err = payloadHandler.credentialsManager.SetTaskCredentials(
-				&(credentials.TaskIAMRoleCredentials{
-					ARN:                aws.StringValue(task.Arn),
-					IAMRoleCredentials: taskExecutionIAMRoleCredentials,
-				}))
-			if err != nil {
-				payloadHandler.handleUnrecognizedTask(task, err, payload)
-				allTasksOK = false
-				continue
-			}
-			apiTask.SetExecutionRoleCredentialsID(taskExecutionIAMRoleCredentials.CredentialsID)
-		}
-
 		validTasks = append(validTasks, apiTask)
 	}
 
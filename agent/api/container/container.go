@@ -17,13 +17,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"sync"
 	"time"
 
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/cihub/seelog"
@@ -168,9 +168,13 @@ type Container struct {
 	StartTimeout uint
 	// StopTimeout specifies the time value to be passed as StopContainer api call
 	StopTimeout uint
+	// RestartPolicy specifies whether the agent restarts the container after it exits.
+	// It only applies to non-essential containers
+	RestartPolicy *RestartPolicy `json:"restartPolicy,omitempty"`
 
-	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	// lock is used for fields that are accessed and updated concurrently. Its
+	// version tells whether the container changed since it was last saved.
+	lock utilsync.VersionedRWMutex
 
 	// DesiredStatusUnsafe represents the state where the container should go. Generally,
 	// the desired status is informed by the ECS backend as a result of either
@@ -241,6 +245,39 @@ type Container struct {
 	// and `SetKnownExitCode`.
 	KnownExitCodeUnsafe *int `json:"KnownExitCode"`
 
+	// RestartCountUnsafe is the number of times the container has been restarted
+	// by the agent as per its restart policy.
+	// NOTE: Do not access RestartCountUnsafe directly. Instead, use `GetRestartCount`.
+	RestartCountUnsafe int `json:"RestartCount,omitempty"`
+
+	// LastExitCodesUnsafe are the exit codes of the most recent runs of the container
+	// that were restarted by the agent.
+	// NOTE: Do not access LastExitCodesUnsafe directly. Instead, use `GetLastExitCodes`.
+	LastExitCodesUnsafe []int `json:"LastExitCodes,omitempty"`
+
+	// RestartAtUnsafe is when the container is due to be restarted as per its
+	// restart policy, while the restart is pending. It's saved so that a restart
+	// pending when the agent stops is resumed when the state is restored.
+	// NOTE: Do not access RestartAtUnsafe directly. Instead, use `GetRestartAt`
+	// and `SetRestartAt`.
+	RestartAtUnsafe *time.Time `json:"RestartAt,omitempty"`
+
+	// TransitionBlockersUnsafe are the dependencies that kept the container from
+	// transitioning to its next known status the last time it was evaluated.
+	// NOTE: Do not access TransitionBlockersUnsafe directly. Instead, use
+	// `GetTransitionBlockers` and `SetTransitionBlockers`.
+	TransitionBlockersUnsafe []TransitionBlocker `json:"-"`
+
+	// PullProgressUnsafe is the progress of the last pull of the container's image.
+	// NOTE: Do not access PullProgressUnsafe directly. Instead, use
+	// `GetPullProgress` and `SetPullProgress`.
+	PullProgressUnsafe *PullProgress `json:"-"`
+
+	// StopReasonUnsafe is why the container stopped the last time.
+	// NOTE: Do not access StopReasonUnsafe directly. Instead, use
+	// `GetStopReason` and `SetStopReason`.
+	StopReasonUnsafe *StopReason `json:"StopReason,omitempty"`
+
 	// KnownPortBindingsUnsafe is an array of port bindings for the container.
 	KnownPortBindingsUnsafe []PortBinding `json:"KnownPortBindings"`
 
@@ -639,6 +676,12 @@ func (c *Container) GetImageDigest() string {
 	return c.ImageDigest
 }
 
+// ChangeVersion returns a value that's larger every time the container may
+// have changed, as long as its fields are only changed with its lock held
+func (c *Container) ChangeVersion() uint64 {
+	return c.lock.Version()
+}
+
 // GetLabels gets the labels for a container
 func (c *Container) GetLabels() map[string]string {
 	c.lock.RLock()
@@ -998,15 +1041,15 @@ func (c *Container) HasSecret(f func(s Secret) bool) bool {
 }
 
 func (c *Container) GetStartTimeout() time.Duration {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 
 	return time.Duration(c.StartTimeout) * time.Second
 }
 
 func (c *Container) GetStopTimeout() time.Duration {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 
 	return time.Duration(c.StopTimeout) * time.Second
 }
@@ -1097,6 +1140,26 @@ func (c *Container) GetNetworkModeFromHostConfig() string {
 	return hostConfig.NetworkMode.NetworkName()
 }
 
+// GetMemoryReservationFromHostConfig returns the soft memory limit of the
+// container in bytes, as set by its memoryReservation, or 0 if it has none
+func (c *Container) GetMemoryReservationFromHostConfig() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.DockerConfig.HostConfig == nil {
+		return 0
+	}
+
+	hostConfig := &dockercontainer.HostConfig{}
+	err := json.Unmarshal([]byte(*c.DockerConfig.HostConfig), hostConfig)
+	if err != nil {
+		seelog.Warnf("Encountered error when trying to get memory reservation for container %s: %v", c.Name, err)
+		return 0
+	}
+
+	return hostConfig.MemoryReservation
+}
+
 // GetHostConfig returns the container's host config.
 func (c *Container) GetHostConfig() *string {
 	c.lock.RLock()
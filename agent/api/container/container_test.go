@@ -667,6 +667,18 @@ func TestGetNetworkModeFromHostConfig(t *testing.T) {
 	}
 }
 
+func TestGetMemoryReservationFromHostConfig(t *testing.T) {
+	container := &Container{Name: "c"}
+	assert.Zero(t, container.GetMemoryReservationFromHostConfig())
+
+	hostConfig := `{"MemoryReservation":67108864}`
+	container.DockerConfig.HostConfig = &hostConfig
+	assert.Equal(t, int64(67108864), container.GetMemoryReservationFromHostConfig())
+
+	hostConfig = "invalid"
+	assert.Zero(t, container.GetMemoryReservationFromHostConfig())
+}
+
 func TestShouldCreateWithEnvfiles(t *testing.T) {
 	cases := []struct {
 		in  Container
@@ -750,3 +762,32 @@ func TestMergeEnvironmentVariablesFromEnvfiles(t *testing.T) {
 		})
 	}
 }
+
+func TestSetTransitionBlockersChangeVersion(t *testing.T) {
+	container := &Container{}
+	blockers := []TransitionBlocker{{Type: BlockerDependsOn, Dependency: "db", Reason: "waiting for db"}}
+
+	container.SetTransitionBlockers(blockers)
+	version := container.ChangeVersion()
+	container.SetTransitionBlockers([]TransitionBlocker{blockers[0]})
+	assert.Equal(t, version, container.ChangeVersion(), "Setting the same blockers should not change the container")
+
+	container.SetTransitionBlockers(nil)
+	assert.NotEqual(t, version, container.ChangeVersion(), "Setting other blockers should change the container")
+	assert.Nil(t, container.GetTransitionBlockers())
+}
+
+func TestSetPullProgressChangeVersion(t *testing.T) {
+	container := &Container{}
+	progress := PullProgress{Layers: 2, LayersDone: 1, BytesDownloaded: 10, BytesTotal: 20, UpdatedAt: time.Now()}
+
+	container.SetPullProgress(progress)
+	version := container.ChangeVersion()
+	container.SetPullProgress(progress)
+	assert.Equal(t, version, container.ChangeVersion(), "Setting the same progress should not change the container")
+
+	progress.LayersDone = 2
+	container.SetPullProgress(progress)
+	assert.NotEqual(t, version, container.ChangeVersion(), "Setting another progress should change the container")
+	assert.Equal(t, 2, container.GetPullProgress().LayersDone)
+}
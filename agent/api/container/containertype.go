@@ -63,18 +63,10 @@ func (containerType ContainerType) String() string {
 func (containerType *ContainerType) UnmarshalJSON(b []byte) error {
 	strType := string(b)
 
-	switch strType {
-	case "null":
+	if strType == "null" {
 		*containerType = ContainerNormal
 		seelog.Warn("Unmarshalled nil ContainerType as Normal")
 		return nil
-	// 'true' or 'false' for compatibility with state version <= 5
-	case "true":
-		*containerType = ContainerEmptyHostVolume
-		return nil
-	case "false":
-		*containerType = ContainerNormal
-		return nil
 	}
 
 	if len(strType) < 2 {
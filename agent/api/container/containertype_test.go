@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"testing"
 
+	statemanager_testutils "github.com/aws/amazon-ecs-agent/agent/statemanager/testutils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type containerTypeWrapper struct {
@@ -66,8 +68,11 @@ func TestUnmarhsalContainerType(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(fmt.Sprintf("%s unmarshalled as %s", tc.encodedString, tc.containerType.Type.String()),
 			func(t *testing.T) {
+				// The flag saved before the type is migrated by the state manager
+				data, err := statemanager_testutils.MigrateContainer([]byte(tc.encodedString), 1)
+				require.NoError(t, err)
 				var contTypeWrapper containerTypeWrapper
-				err := json.Unmarshal([]byte(tc.encodedString), &contTypeWrapper)
+				err = json.Unmarshal(data, &contTypeWrapper)
 				assert.NoError(t, err)
 				assert.Equal(t, tc.containerType.Type, contTypeWrapper.Type)
 			})
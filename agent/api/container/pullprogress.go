@@ -0,0 +1,73 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+import (
+	"time"
+)
+
+// PullProgress is the progress of the pull of a container's image
+type PullProgress struct {
+	// Layers is the number of layers of the image the pull has seen so far
+	Layers int `json:"Layers"`
+	// LayersDone is the number of layers that are downloaded and extracted, or
+	// that were already present on the instance
+	LayersDone int `json:"LayersDone"`
+	// BytesDownloaded is the number of bytes of the layers downloaded so far
+	BytesDownloaded int64 `json:"BytesDownloaded"`
+	// BytesTotal is the size of the layers whose size is known so far
+	BytesTotal int64 `json:"BytesTotal"`
+	// UpdatedAt is the last time the pull made progress. A pull whose progress
+	// hasn't been updated for a while is likely stuck
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// GetPullProgress returns the progress of the last pull of the container's image
+func (c *Container) GetPullProgress() *PullProgress {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.PullProgressUnsafe == nil {
+		return nil
+	}
+	progress := *c.PullProgressUnsafe
+	return &progress
+}
+
+// SetPullProgress records the progress of the pull of the container's image.
+// The container is only locked for writing if the progress changed, so that
+// the containers waiting on the same pull aren't marked as changed by every
+// update that doesn't change it.
+func (c *Container) SetPullProgress(progress PullProgress) {
+	c.lock.RLock()
+	unchanged := c.PullProgressUnsafe != nil && c.PullProgressUnsafe.equal(progress)
+	c.lock.RUnlock()
+	if unchanged {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.PullProgressUnsafe = &progress
+}
+
+// equal returns whether the two progresses are the same
+func (progress PullProgress) equal(other PullProgress) bool {
+	return progress.Layers == other.Layers &&
+		progress.LayersDone == other.LayersDone &&
+		progress.BytesDownloaded == other.BytesDownloaded &&
+		progress.BytesTotal == other.BytesTotal &&
+		progress.UpdatedAt.Equal(other.UpdatedAt)
+}
@@ -0,0 +1,179 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RestartPolicyNever indicates that the container is never restarted by the agent
+	RestartPolicyNever RestartPolicyType = "never"
+	// RestartPolicyOnFailure indicates that the container is restarted by the agent
+	// only if it exits with a non-zero exit code
+	RestartPolicyOnFailure RestartPolicyType = "on-failure"
+	// RestartPolicyAlways indicates that the container is restarted by the agent
+	// whenever it exits
+	RestartPolicyAlways RestartPolicyType = "always"
+
+	// RestartPolicyLabel is the docker label that can be used in the task definition
+	// to specify the restart policy of a non-essential container. The value is of the
+	// format "<type>[:<max attempts>]", e.g. "on-failure:5"
+	RestartPolicyLabel = "com.amazonaws.ecs.restart-policy"
+
+	// maxLastExitCodes is the number of most recent exit codes retained for a
+	// container that is restarted by the agent
+	maxLastExitCodes = 10
+)
+
+// RestartPolicyType is the type of the restart policy of a container
+type RestartPolicyType string
+
+// RestartPolicy describes if and how many times the agent restarts a
+// non-essential container after it exits
+type RestartPolicy struct {
+	// Type is the type of the restart policy
+	Type RestartPolicyType `json:"type"`
+	// MaxAttempts is the maximum number of times the container is restarted.
+	// A value of 0 means there is no limit
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// ParseRestartPolicy parses a restart policy of the format "<type>[:<max attempts>]"
+func ParseRestartPolicy(value string) (*RestartPolicy, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	policy := &RestartPolicy{
+		Type: RestartPolicyType(strings.ToLower(parts[0])),
+	}
+	switch policy.Type {
+	case RestartPolicyNever, RestartPolicyOnFailure, RestartPolicyAlways:
+	default:
+		return nil, errors.Errorf("restart policy: unrecognized restart policy type: %s", parts[0])
+	}
+	if len(parts) == 1 {
+		return policy, nil
+	}
+	if policy.Type == RestartPolicyNever {
+		return nil, errors.Errorf("restart policy: max attempts cannot be set for policy type: %s", policy.Type)
+	}
+	maxAttempts, err := strconv.Atoi(parts[1])
+	if err != nil || maxAttempts < 0 {
+		return nil, errors.Errorf("restart policy: invalid max attempts: %s", parts[1])
+	}
+	policy.MaxAttempts = maxAttempts
+	return policy, nil
+}
+
+// ShouldRestart returns true if a container that has already been restarted
+// restartCount times and exited with the given exit code should be restarted
+func (policy *RestartPolicy) ShouldRestart(exitCode *int, restartCount int) bool {
+	if policy == nil {
+		return false
+	}
+	if policy.MaxAttempts > 0 && restartCount >= policy.MaxAttempts {
+		return false
+	}
+	switch policy.Type {
+	case RestartPolicyAlways:
+		return true
+	case RestartPolicyOnFailure:
+		return exitCode == nil || *exitCode != 0
+	default:
+		return false
+	}
+}
+
+// GetRestartPolicy returns the restart policy of the container
+func (c *Container) GetRestartPolicy() *RestartPolicy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.RestartPolicy
+}
+
+// SetRestartPolicy sets the restart policy of the container
+func (c *Container) SetRestartPolicy(policy *RestartPolicy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.RestartPolicy = policy
+}
+
+// GetRestartCount returns the number of times the container has been restarted
+// by the agent
+func (c *Container) GetRestartCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.RestartCountUnsafe
+}
+
+// GetLastExitCodes returns the exit codes of the most recent exits of a container
+// that has been restarted by the agent, oldest first
+func (c *Container) GetLastExitCodes() []int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.LastExitCodesUnsafe) == 0 {
+		return nil
+	}
+	exitCodes := make([]int, len(c.LastExitCodesUnsafe))
+	copy(exitCodes, c.LastExitCodesUnsafe)
+	return exitCodes
+}
+
+// RecordRestart increments the restart count of the container and records the
+// exit code of the run that is being restarted
+func (c *Container) RecordRestart() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.RestartCountUnsafe++
+	if c.KnownExitCodeUnsafe == nil {
+		return
+	}
+	c.LastExitCodesUnsafe = append(c.LastExitCodesUnsafe, *c.KnownExitCodeUnsafe)
+	if len(c.LastExitCodesUnsafe) > maxLastExitCodes {
+		c.LastExitCodesUnsafe = c.LastExitCodesUnsafe[len(c.LastExitCodesUnsafe)-maxLastExitCodes:]
+	}
+}
+
+// GetRestartAt returns when the container is due to be restarted, or the zero
+// time if no restart is pending
+func (c *Container) GetRestartAt() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.RestartAtUnsafe == nil {
+		return time.Time{}
+	}
+	return *c.RestartAtUnsafe
+}
+
+// SetRestartAt sets when the container is due to be restarted. The zero time
+// clears the pending restart
+func (c *Container) SetRestartAt(restartAt time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if restartAt.IsZero() {
+		c.RestartAtUnsafe = nil
+		return
+	}
+	c.RestartAtUnsafe = &restartAt
+}
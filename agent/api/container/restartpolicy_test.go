@@ -0,0 +1,114 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected *RestartPolicy
+		err      bool
+	}{
+		{"never", &RestartPolicy{Type: RestartPolicyNever}, false},
+		{"always", &RestartPolicy{Type: RestartPolicyAlways}, false},
+		{"On-Failure:3", &RestartPolicy{Type: RestartPolicyOnFailure, MaxAttempts: 3}, false},
+		{"never:3", nil, true},
+		{"on-failure:-1", nil, true},
+		{"sometimes", nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			policy, err := ParseRestartPolicy(tc.value)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, policy)
+		})
+	}
+}
+
+func TestRestartPolicyShouldRestart(t *testing.T) {
+	success, failure := 0, 1
+	testCases := []struct {
+		name         string
+		policy       *RestartPolicy
+		exitCode     *int
+		restartCount int
+		expected     bool
+	}{
+		{"no policy", nil, &failure, 0, false},
+		{"never", &RestartPolicy{Type: RestartPolicyNever}, &failure, 0, false},
+		{"always after success", &RestartPolicy{Type: RestartPolicyAlways}, &success, 5, true},
+		{"on-failure after success", &RestartPolicy{Type: RestartPolicyOnFailure}, &success, 0, false},
+		{"on-failure after failure", &RestartPolicy{Type: RestartPolicyOnFailure}, &failure, 0, true},
+		{"on-failure with unknown exit code", &RestartPolicy{Type: RestartPolicyOnFailure}, nil, 0, true},
+		{"max attempts reached", &RestartPolicy{Type: RestartPolicyAlways, MaxAttempts: 2}, &failure, 2, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.policy.ShouldRestart(tc.exitCode, tc.restartCount))
+		})
+	}
+}
+
+func TestRecordRestart(t *testing.T) {
+	container := &Container{}
+	for i := 0; i < maxLastExitCodes+2; i++ {
+		exitCode := i
+		container.SetKnownExitCode(&exitCode)
+		container.RecordRestart()
+	}
+	assert.Equal(t, maxLastExitCodes+2, container.GetRestartCount())
+	exitCodes := container.GetLastExitCodes()
+	assert.Len(t, exitCodes, maxLastExitCodes)
+	assert.Equal(t, 2, exitCodes[0])
+
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+	var unmarshalled Container
+	require.NoError(t, json.Unmarshal(data, &unmarshalled))
+	assert.Equal(t, container.GetRestartCount(), unmarshalled.GetRestartCount())
+	assert.Equal(t, exitCodes, unmarshalled.GetLastExitCodes())
+}
+
+func TestRestartAt(t *testing.T) {
+	container := &Container{}
+	assert.True(t, container.GetRestartAt().IsZero())
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "RestartAt")
+
+	restartAt := time.Unix(1600000000, 0).UTC()
+	container.SetRestartAt(restartAt)
+	data, err = json.Marshal(container)
+	require.NoError(t, err)
+	var unmarshalled Container
+	require.NoError(t, json.Unmarshal(data, &unmarshalled))
+	assert.True(t, restartAt.Equal(unmarshalled.GetRestartAt()))
+
+	container.SetRestartAt(time.Time{})
+	assert.True(t, container.GetRestartAt().IsZero())
+}
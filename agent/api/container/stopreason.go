@@ -0,0 +1,139 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// StopReasonExited indicates that the main process of the container exited
+	// on its own
+	StopReasonExited StopReasonType = "Exited"
+	// StopReasonOOMKilled indicates that a process of the container was killed
+	// by the kernel OOM killer
+	StopReasonOOMKilled StopReasonType = "OOMKilled"
+	// StopReasonSignaled indicates that the main process of the container was
+	// terminated by a signal
+	StopReasonSignaled StopReasonType = "Signaled"
+	// StopReasonError indicates that Docker failed to run the container
+	StopReasonError StopReasonType = "Error"
+
+	// signalExitCodeBase is added to the number of the signal that terminated
+	// the main process of a container to make its exit code
+	signalExitCodeBase = 128
+	// maxSignal is the highest signal number on Linux
+	maxSignal = 64
+)
+
+// signalNames are the names of the standard signals, by number
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	10: "SIGUSR1",
+	11: "SIGSEGV",
+	12: "SIGUSR2",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+}
+
+// StopReasonType is the type of the reason a container stopped
+type StopReasonType string
+
+// StopReason is why a container stopped the last time
+type StopReason struct {
+	// Type is the type of the reason
+	Type StopReasonType `json:"type"`
+	// Signal is the name of the signal that terminated the main process of the
+	// container, if known
+	Signal string `json:"signal,omitempty"`
+	// Message is the error Docker reported for the container, if any
+	Message string `json:"message,omitempty"`
+}
+
+// NewStopReason returns why a container stopped. The signal that terminated
+// the main process of the container is worked out from its exit code, which is
+// 128+n for signal n by the convention of shells and Docker
+func NewStopReason(exitCode *int, oomKilled bool, dockerError string) *StopReason {
+	reason := &StopReason{
+		Type:    StopReasonExited,
+		Message: dockerError,
+	}
+	if exitCode != nil {
+		reason.Signal = signalFromExitCode(*exitCode)
+	}
+	switch {
+	case oomKilled:
+		reason.Type = StopReasonOOMKilled
+	case dockerError != "":
+		reason.Type = StopReasonError
+	case reason.Signal != "":
+		reason.Type = StopReasonSignaled
+	}
+	return reason
+}
+
+// String returns a human readable form of the reason
+func (reason *StopReason) String() string {
+	parts := []string{string(reason.Type)}
+	if reason.Signal != "" {
+		parts = append(parts, reason.Signal)
+	}
+	if reason.Message != "" {
+		parts = append(parts, reason.Message)
+	}
+	return strings.Join(parts, ": ")
+}
+
+// signalFromExitCode returns the name of the signal the exit code stands for,
+// or an empty string if the exit code doesn't stand for a signal
+func signalFromExitCode(exitCode int) string {
+	signal := exitCode - signalExitCodeBase
+	if signal < 1 || signal > maxSignal {
+		return ""
+	}
+	if name, ok := signalNames[signal]; ok {
+		return name
+	}
+	return "SIG" + strconv.Itoa(signal)
+}
+
+// GetStopReason returns why the container stopped the last time
+func (c *Container) GetStopReason() *StopReason {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.StopReasonUnsafe == nil {
+		return nil
+	}
+	reason := *c.StopReasonUnsafe
+	return &reason
+}
+
+// SetStopReason records why the container stopped
+func (c *Container) SetStopReason(reason *StopReason) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.StopReasonUnsafe = reason
+}
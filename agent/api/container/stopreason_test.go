@@ -0,0 +1,75 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestNewStopReason(t *testing.T) {
+	testCases := []struct {
+		name        string
+		exitCode    *int
+		oomKilled   bool
+		dockerError string
+		expected    StopReason
+	}{
+		{"exited", intPtr(0), false, "", StopReason{Type: StopReasonExited}},
+		{"exited with failure", intPtr(1), false, "", StopReason{Type: StopReasonExited}},
+		{"no exit code", nil, false, "", StopReason{Type: StopReasonExited}},
+		{"sigterm", intPtr(143), false, "", StopReason{Type: StopReasonSignaled, Signal: "SIGTERM"}},
+		{"sigkill", intPtr(137), false, "", StopReason{Type: StopReasonSignaled, Signal: "SIGKILL"}},
+		{"real-time signal", intPtr(162), false, "", StopReason{Type: StopReasonSignaled, Signal: "SIG34"}},
+		{"exit code past signals", intPtr(255), false, "", StopReason{Type: StopReasonExited}},
+		{"oom killed", intPtr(137), true, "", StopReason{Type: StopReasonOOMKilled, Signal: "SIGKILL"}},
+		{"docker error", intPtr(128), false, "OCI runtime create failed",
+			StopReason{Type: StopReasonError, Message: "OCI runtime create failed"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := NewStopReason(tc.exitCode, tc.oomKilled, tc.dockerError)
+			require.NotNil(t, reason)
+			assert.Equal(t, tc.expected, *reason)
+		})
+	}
+}
+
+func TestStopReasonString(t *testing.T) {
+	assert.Equal(t, "Exited", NewStopReason(intPtr(0), false, "").String())
+	assert.Equal(t, "OOMKilled: SIGKILL", NewStopReason(intPtr(137), true, "").String())
+	assert.Equal(t, "Error: no such file", NewStopReason(intPtr(127), false, "no such file").String())
+}
+
+func TestStopReasonIsSaved(t *testing.T) {
+	container := &Container{Name: "web"}
+	assert.Nil(t, container.GetStopReason())
+	container.SetStopReason(NewStopReason(intPtr(137), true, ""))
+
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+	var restored Container
+	require.NoError(t, json.Unmarshal(data, &restored))
+	require.NotNil(t, restored.GetStopReason())
+	assert.Equal(t, StopReason{Type: StopReasonOOMKilled, Signal: "SIGKILL"}, *restored.GetStopReason())
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package container
+
+const (
+	// BlockerExecutionCredentials indicates that the container is waiting for
+	// the task execution role credentials to be delivered
+	BlockerExecutionCredentials TransitionBlockerType = "EXECUTION_CREDENTIALS"
+	// BlockerDependsOn indicates that the container is waiting for a container
+	// it declares in its dependsOn list
+	BlockerDependsOn TransitionBlockerType = "DEPENDS_ON"
+	// BlockerLink indicates that the container is waiting for a linked container
+	BlockerLink TransitionBlockerType = "LINK"
+	// BlockerVolumesFrom indicates that the container is waiting for a container
+	// it mounts volumes from
+	BlockerVolumesFrom TransitionBlockerType = "VOLUMES_FROM"
+	// BlockerSteadyState indicates that the container is waiting for a container
+	// it depends on to reach its steady state
+	BlockerSteadyState TransitionBlockerType = "STEADY_STATE"
+	// BlockerContainerDependency indicates that the container is waiting for a
+	// container that its next transition depends on
+	BlockerContainerDependency TransitionBlockerType = "CONTAINER_DEPENDENCY"
+	// BlockerResourceDependency indicates that the container is waiting for a
+	// task resource that its next transition depends on
+	BlockerResourceDependency TransitionBlockerType = "RESOURCE_DEPENDENCY"
+	// BlockerShutdownOrder indicates that the container is waiting for the
+	// containers that depend on it to stop before it can stop
+	BlockerShutdownOrder TransitionBlockerType = "SHUTDOWN_ORDER"
+)
+
+// TransitionBlockerType is the kind of dependency that blocks a container
+type TransitionBlockerType string
+
+// TransitionBlocker describes a dependency that keeps a container from
+// transitioning to its next known status
+type TransitionBlocker struct {
+	// Type is the kind of dependency
+	Type TransitionBlockerType `json:"Type"`
+	// Dependency is the name of the container or task resource that is
+	// depended on, if any
+	Dependency string `json:"Dependency,omitempty"`
+	// Condition is the condition or status the dependency needs to satisfy
+	Condition string `json:"Condition,omitempty"`
+	// Unresolvable is set when the dependency can never be satisfied, in which
+	// case the task is stopped
+	Unresolvable bool `json:"Unresolvable,omitempty"`
+	// Reason is a human readable description of the blocker
+	Reason string `json:"Reason"`
+}
+
+// GetTransitionBlockers returns the dependencies that kept the container from
+// transitioning the last time its dependencies were evaluated
+func (c *Container) GetTransitionBlockers() []TransitionBlocker {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.TransitionBlockersUnsafe) == 0 {
+		return nil
+	}
+	blockers := make([]TransitionBlocker, len(c.TransitionBlockersUnsafe))
+	copy(blockers, c.TransitionBlockersUnsafe)
+	return blockers
+}
+
+// SetTransitionBlockers records the dependencies that keep the container from
+// transitioning to its next known status. The container is only locked for
+// writing if they changed, so that a container that stays blocked isn't marked
+// as changed, and saved again, every time its dependencies are evaluated.
+func (c *Container) SetTransitionBlockers(blockers []TransitionBlocker) {
+	c.lock.RLock()
+	unchanged := transitionBlockersEqual(c.TransitionBlockersUnsafe, blockers)
+	c.lock.RUnlock()
+	if unchanged {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.TransitionBlockersUnsafe = blockers
+}
+
+// transitionBlockersEqual returns whether the two lists have the same blockers
+// in the same order
+func transitionBlockersEqual(a, b []TransitionBlocker) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -14,13 +14,8 @@
 package container
 
 import (
-	"encoding/json"
-
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
-
-	"github.com/cihub/seelog"
-	"github.com/pkg/errors"
 )
 
 // TransitionDependencySet contains dependencies that impact transitions of
@@ -63,34 +58,3 @@ func (rd *ResourceDependency) GetRequiredStatus() resourcestatus.ResourceStatus
 // TransitionDependenciesMap is a map of the dependent container status to other
 // dependencies that must be satisfied.
 type TransitionDependenciesMap map[apicontainerstatus.ContainerStatus]TransitionDependencySet
-
-// UnmarshalJSON decodes the TransitionDependencySet tag in the JSON encoded string
-// into the TransitionDependenciesMap object
-func (td *TransitionDependenciesMap) UnmarshalJSON(b []byte) error {
-	depMap := make(map[apicontainerstatus.ContainerStatus]TransitionDependencySet)
-	err := json.Unmarshal(b, &depMap)
-	if err == nil {
-		*td = depMap
-		return nil
-	}
-	seelog.Debugf("Unmarshal 'TransitionDependencySet': %s, not a map: %v", string(b), err)
-	// Unmarshal to deprecated 'TransitionDependencySet' and then convert to a map
-	tdSet := TransitionDependencySet{}
-	if err := json.Unmarshal(b, &tdSet); err != nil {
-		return errors.Wrapf(err,
-			"Unmarshal 'TransitionDependencySet': does not comply with any of the dependency types")
-	}
-	for _, dep := range tdSet.ContainerDependencies {
-		dependentStatus := dep.DependentStatus
-		// no need for DependentStatus field anymore, since it becomes the map's key
-		dep.DependentStatus = 0
-		if _, ok := depMap[dependentStatus]; !ok {
-			depMap[dependentStatus] = TransitionDependencySet{}
-		}
-		deps := depMap[dependentStatus]
-		deps.ContainerDependencies = append(deps.ContainerDependencies, dep)
-		depMap[dependentStatus] = deps
-	}
-	*td = depMap
-	return nil
-}
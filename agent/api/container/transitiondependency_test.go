@@ -20,7 +20,9 @@ import (
 	"testing"
 
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	statemanager_testutils "github.com/aws/amazon-ecs-agent/agent/statemanager/testutils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUnmarshalOldTransitionDependencySet(t *testing.T) {
@@ -33,8 +35,17 @@ func TestUnmarshalOldTransitionDependencySet(t *testing.T) {
 	    }
 	  ]
 	}`)
+	// The set saved before the map by dependent status is migrated by the
+	// state manager
+	container, err := statemanager_testutils.MigrateContainer(
+		[]byte(`{"TransitionDependencySet":`+string(bytes)+`}`), 11)
+	require.NoError(t, err)
+	var migrated struct {
+		TransitionDependencySet json.RawMessage
+	}
+	require.NoError(t, json.Unmarshal(container, &migrated))
 	unmarshalledTdMap := TransitionDependenciesMap{}
-	err := json.Unmarshal(bytes, &unmarshalledTdMap)
+	err = json.Unmarshal(migrated.TransitionDependencySet, &unmarshalledTdMap)
 	assert.NoError(t, err)
 	assert.Len(t, unmarshalledTdMap, 1)
 	assert.NotNil(t, unmarshalledTdMap[apicontainerstatus.ContainerRunning])
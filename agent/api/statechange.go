@@ -47,6 +47,8 @@ type ContainerStateChange struct {
 	Reason string
 	// ExitCode is the exit code of the container, if available
 	ExitCode *int
+	// StopReason is why the container stopped, if it has stopped
+	StopReason *apicontainer.StopReason
 	// PortBindings are the details of the host ports picked for the specified
 	// container ports
 	PortBindings []apicontainer.PortBinding
@@ -141,12 +143,20 @@ func NewContainerStateChangeEvent(task *apitask.Task, cont *apicontainer.Contain
 	if reason == "" && cont.ApplyingError != nil {
 		reason = cont.ApplyingError.Error()
 	}
+	var stopReason *apicontainer.StopReason
+	if contKnownStatus == apicontainerstatus.ContainerStopped {
+		stopReason = cont.GetStopReason()
+	}
+	if reason == "" && stopReason != nil && stopReason.Type != apicontainer.StopReasonExited {
+		reason = stopReason.String()
+	}
 	event = ContainerStateChange{
 		TaskArn:       task.Arn,
 		ContainerName: cont.Name,
 		RuntimeID:     cont.GetRuntimeID(),
 		Status:        contKnownStatus.BackendStatus(cont.GetSteadyStateStatus()),
 		ExitCode:      cont.GetKnownExitCode(),
+		StopReason:    stopReason,
 		PortBindings:  cont.GetKnownPortBindings(),
 		ImageDigest:   cont.GetImageDigest(),
 		Reason:        reason,
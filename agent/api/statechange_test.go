@@ -22,9 +22,12 @@ import (
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShouldBeReported(t *testing.T) {
@@ -125,3 +128,36 @@ func TestSetImageDigest(t *testing.T) {
 	assert.NoError(t, ok, "error create newContainerStateChangeEvent")
 	assert.Equal(t, "sha256:d1c14fcf2e9476ed58ebc4251b211f403f271e96b6c3d9ada0f1c5454ca4d230", resp.ImageDigest)
 }
+
+func TestContainerStateChangeStopReason(t *testing.T) {
+	testCases := []struct {
+		name           string
+		stopReason     *apicontainer.StopReason
+		applyingError  *apierrors.DefaultNamedError
+		expectedReason string
+	}{
+		{"signaled", apicontainer.NewStopReason(aws.Int(137), false, ""), nil, "Signaled: SIGKILL"},
+		{"exited", apicontainer.NewStopReason(aws.Int(0), false, ""), nil, ""},
+		{"applying error takes precedence", apicontainer.NewStopReason(aws.Int(137), true, ""),
+			&apierrors.DefaultNamedError{Name: "OutOfMemoryError", Err: "Container killed due to memory usage"},
+			"OutOfMemoryError: Container killed due to memory usage"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := &apicontainer.Container{
+				Name:              "web",
+				KnownStatusUnsafe: apicontainerstatus.ContainerStopped,
+				SentStatusUnsafe:  apicontainerstatus.ContainerRunning,
+				Type:              apicontainer.ContainerNormal,
+				ApplyingError:     tc.applyingError,
+			}
+			container.SetStopReason(tc.stopReason)
+			task := &apitask.Task{Arn: "task1", Containers: []*apicontainer.Container{container}}
+
+			event, err := NewContainerStateChangeEvent(task, container, "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.stopReason, event.StopReason)
+			assert.Equal(t, tc.expectedReason, event.Reason)
+		})
+	}
+}
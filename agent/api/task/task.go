@@ -17,6 +17,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"path/filepath"
 	"reflect"
 	"strconv"
@@ -52,6 +53,7 @@ import (
 	resourcetype "github.com/aws/amazon-ecs-agent/agent/taskresource/types"
 	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
 	"github.com/cihub/seelog"
 	"github.com/containernetworking/cni/libcni"
@@ -221,10 +223,8 @@ type Task struct {
 	credentialsID                string
 	credentialsRelativeURIUnsafe string
 
-	// ENIs is the list of Elastic Network Interfaces assigned to this task. The
-	// TaskENIs type is helpful when decoding state files which might have stored
-	// ENIs as a single ENI object instead of a list.
-	ENIs TaskENIs `json:"ENI"`
+	// ENIs is the list of Elastic Network Interfaces assigned to this task
+	ENIs []*apieni.ENI `json:"ENI"`
 
 	// AppMesh is the service mesh specified by the task
 	AppMesh *apiappmesh.AppMesh
@@ -252,8 +252,15 @@ type Task struct {
 	// NvidiaRuntime is the runtime to pass Nvidia GPU devices to containers
 	NvidiaRuntime string `json:"NvidiaRuntime,omitempty"`
 
-	// lock is for protecting all fields in the task struct
-	lock sync.RWMutex
+	// TimelineUnsafe is the journal of the most recent status changes of the task,
+	// its containers and its resources.
+	// NOTE: Do not access TimelineUnsafe directly. Instead, use `GetTimeline`
+	// and `AddTimelineEvent`.
+	TimelineUnsafe []TimelineEvent `json:"Timeline,omitempty"`
+
+	// lock is for protecting all fields in the task struct. Its version tells
+	// whether the task changed since it was last saved.
+	lock utilsync.VersionedRWMutex
 }
 
 // TaskFromACS translates ecsacs.Task to apitask.Task by first marshaling the received
@@ -286,6 +293,70 @@ func TaskFromACS(acsTask *ecsacs.Task, envelope *ecsacs.PayloadMessage) (*Task,
 	return task, nil
 }
 
+// TaskFromACSWithCredentials translates ecsacs.Task to apitask.Task along with
+// its ENIs and App Mesh configuration, and adds the task and execution role
+// credentials it carries to the credentials manager. The errors are returned as
+// is, since they're the reason reported when the task is rejected.
+func TaskFromACSWithCredentials(acsTask *ecsacs.Task, envelope *ecsacs.PayloadMessage,
+	credentialsManager credentials.Manager) (*Task, error) {
+	task, err := TaskFromACS(acsTask, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if acsTask.RoleCredentials != nil {
+		// The payload from ACS for the task has credentials for the
+		// task. Add those to the credentials manager and set the
+		// credentials id for the task as well
+		taskIAMRoleCredentials := credentials.IAMRoleCredentialsFromACS(acsTask.RoleCredentials, credentials.ApplicationRoleType)
+		err = credentialsManager.SetTaskCredentials(
+			&(credentials.TaskIAMRoleCredentials{
+				ARN:                aws.StringValue(acsTask.Arn),
+				IAMRoleCredentials: taskIAMRoleCredentials,
+			}))
+		if err != nil {
+			return nil, err
+		}
+		task.SetCredentialsID(taskIAMRoleCredentials.CredentialsID)
+	}
+
+	// Add ENI information to the task struct.
+	for _, acsENI := range acsTask.ElasticNetworkInterfaces {
+		eni, err := apieni.ENIFromACS(acsENI)
+		if err != nil {
+			return nil, err
+		}
+		task.AddTaskENI(eni)
+	}
+
+	// Add the app mesh information to task struct
+	if acsTask.ProxyConfiguration != nil {
+		appmesh, err := apiappmesh.AppMeshFromACS(acsTask.ProxyConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		task.SetAppMesh(appmesh)
+	}
+
+	if acsTask.ExecutionRoleCredentials != nil {
+		// The payload message contains execution credentials for the task.
+		// Add the credentials to the credentials manager and set the
+		// task executionCredentials id.
+		taskExecutionIAMRoleCredentials := credentials.IAMRoleCredentialsFromACS(acsTask.ExecutionRoleCredentials, credentials.ExecutionRoleType)
+		err = credentialsManager.SetTaskCredentials(
+			&(credentials.TaskIAMRoleCredentials{
+				ARN:                aws.StringValue(acsTask.Arn),
+				IAMRoleCredentials: taskExecutionIAMRoleCredentials,
+			}))
+		if err != nil {
+			return nil, err
+		}
+		task.SetExecutionRoleCredentialsID(taskExecutionIAMRoleCredentials.CredentialsID)
+	}
+
+	return task, nil
+}
+
 func (task *Task) initializeVolumes(cfg *config.Config, dockerClient dockerapi.DockerClient, ctx context.Context) error {
 	err := task.initializeDockerLocalVolumes(dockerClient, ctx)
 	if err != nil {
@@ -328,6 +399,11 @@ func (task *Task) PostUnmarshalTask(cfg *config.Config,
 		return apierrors.NewResourceInitError(task.Arn, err)
 	}
 
+	if err := task.initializeContainerRestartPolicies(); err != nil {
+		seelog.Errorf("Task [%s]: could not initialize restart policy for container: %v", task.Arn, err)
+		return apierrors.NewResourceInitError(task.Arn, err)
+	}
+
 	if task.requiresASMDockerAuthData() {
 		task.initializeASMAuthResource(credentialsManager, resourceFields)
 	}
@@ -380,6 +456,31 @@ func (task *Task) PostUnmarshalTask(cfg *config.Config,
 	return nil
 }
 
+// initializeContainerRestartPolicies sets the restart policy of the non-essential
+// containers that specify one with the restart policy docker label and don't
+// have one set already
+func (task *Task) initializeContainerRestartPolicies() error {
+	for _, container := range task.Containers {
+		if container.IsEssential() || container.GetRestartPolicy() != nil || container.DockerConfig.Config == nil {
+			continue
+		}
+		containerConfig := &dockercontainer.Config{}
+		if err := json.Unmarshal([]byte(aws.StringValue(container.DockerConfig.Config)), containerConfig); err != nil {
+			return errors.Wrapf(err, "unable to decode docker config of container %s", container.Name)
+		}
+		value, ok := containerConfig.Labels[apicontainer.RestartPolicyLabel]
+		if !ok {
+			continue
+		}
+		policy, err := apicontainer.ParseRestartPolicy(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid restart policy for container %s", container.Name)
+		}
+		container.SetRestartPolicy(policy)
+	}
+	return nil
+}
+
 func (task *Task) applyFirelensSetup(cfg *config.Config, resourceFields *taskresource.ResourceFields,
 	credentialsManager credentials.Manager) error {
 	firelensContainer := task.GetFirelensContainer()
@@ -2177,8 +2278,8 @@ func (task *Task) GetExecutionStoppedAt() time.Time {
 
 // String returns a human readable string representation of this object
 func (task *Task) String() string {
-	task.lock.Lock()
-	defer task.lock.Unlock()
+	task.lock.RLock()
+	defer task.lock.RUnlock()
 	return task.stringUnsafe()
 }
 
@@ -2254,6 +2355,29 @@ func (task *Task) RecordExecutionStoppedAt(container *apicontainer.Container) {
 		task.Arn, container.Name, now.String())
 }
 
+// ChangeVersion returns a value that changes every time the task, its
+// containers or its resources may have changed, so that the task doesn't need
+// to be marshaled again to be saved if it's the same
+func (task *Task) ChangeVersion() uint64 {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	var containersVersion, resourcesVersion uint64
+	for _, container := range task.Containers {
+		containersVersion += container.ChangeVersion()
+	}
+	// Resources are summed so that the order they're iterated over in doesn't
+	// matter
+	for _, resource := range task.getResourcesUnsafe() {
+		hash := fnv.New64a()
+		fmt.Fprintf(hash, "%s/%d", resource.GetName(), resource.ChangeVersion())
+		resourcesVersion += hash.Sum64()
+	}
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%d/%d", task.lock.Version(), containersVersion, resourcesVersion)
+	return hash.Sum64()
+}
+
 // GetResources returns the list of task resources from ResourcesMap
 func (task *Task) GetResources() []taskresource.TaskResource {
 	task.lock.RLock()
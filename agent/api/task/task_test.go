@@ -3202,6 +3202,43 @@ func TestTaskFromACSPerContainerTimeouts(t *testing.T) {
 	assert.Equal(t, task.Containers[0].StopTimeout, expectedTimeout)
 }
 
+func TestTaskFromACSWithCredentials(t *testing.T) {
+	credentialsManager := credentials.NewManager()
+	acsTask := &ecsacs.Task{
+		Arn:           strptr("myArn"),
+		DesiredStatus: strptr("RUNNING"),
+		Family:        strptr("myFamily"),
+		Version:       strptr("1"),
+		RoleCredentials: &ecsacs.IAMRoleCredentials{
+			CredentialsId:   strptr("taskCredentials"),
+			AccessKeyId:     strptr("akid"),
+			SecretAccessKey: strptr("secret"),
+		},
+		ExecutionRoleCredentials: &ecsacs.IAMRoleCredentials{
+			CredentialsId: strptr("executionCredentials"),
+		},
+		ElasticNetworkInterfaces: []*ecsacs.ElasticNetworkInterface{{
+			Ec2Id:         strptr("eni-1"),
+			MacAddress:    strptr("mac"),
+			Ipv4Addresses: []*ecsacs.IPv4AddressAssignment{{Primary: aws.Bool(true), PrivateAddress: strptr("10.0.0.1")}},
+		}},
+	}
+
+	task, err := TaskFromACSWithCredentials(acsTask, &ecsacs.PayloadMessage{SeqNum: aws.Int64(42)}, credentialsManager)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), task.StartSequenceNumber)
+	assert.Equal(t, "taskCredentials", task.GetCredentialsID())
+	assert.Equal(t, "executionCredentials", task.GetExecutionCredentialsID())
+	_, ok := credentialsManager.GetTaskCredentials("taskCredentials")
+	assert.True(t, ok, "Task credentials should be added to the credentials manager")
+	require.NotNil(t, task.GetPrimaryENI())
+	assert.Equal(t, "eni-1", task.GetPrimaryENI().ID)
+
+	acsTask.ElasticNetworkInterfaces[0].Ipv4Addresses = nil
+	_, err = TaskFromACSWithCredentials(acsTask, &ecsacs.PayloadMessage{}, credentialsManager)
+	assert.Error(t, err, "Tasks with invalid ENIs should be rejected")
+}
+
 func TestGetContainerIndex(t *testing.T) {
 	task := &Task{
 		Containers: []*apicontainer.Container{
@@ -3436,3 +3473,67 @@ func TestRequiresEnvfiles(t *testing.T) {
 
 	assert.Equal(t, true, task.requireEnvfiles())
 }
+
+func TestInitializeContainerRestartPolicies(t *testing.T) {
+	labelConfig := aws.String(`{"Labels":{"com.amazonaws.ecs.restart-policy":"on-failure:3"}}`)
+	nonEssential := &apicontainer.Container{
+		Name:         "sidecar",
+		DockerConfig: apicontainer.DockerConfig{Config: labelConfig},
+	}
+	essential := &apicontainer.Container{
+		Name:         "app",
+		Essential:    true,
+		DockerConfig: apicontainer.DockerConfig{Config: labelConfig},
+	}
+	noLabel := &apicontainer.Container{
+		Name: "logger",
+	}
+	task := &Task{
+		Arn:        "test",
+		Containers: []*apicontainer.Container{nonEssential, essential, noLabel},
+	}
+
+	require.NoError(t, task.initializeContainerRestartPolicies())
+	assert.Equal(t, &apicontainer.RestartPolicy{
+		Type:        apicontainer.RestartPolicyOnFailure,
+		MaxAttempts: 3,
+	}, nonEssential.GetRestartPolicy())
+	assert.Nil(t, essential.GetRestartPolicy())
+	assert.Nil(t, noLabel.GetRestartPolicy())
+
+	nonEssential.SetRestartPolicy(nil)
+	nonEssential.DockerConfig.Config = aws.String(`{"Labels":{"com.amazonaws.ecs.restart-policy":"sometimes"}}`)
+	assert.Error(t, task.initializeContainerRestartPolicies())
+}
+
+func TestChangeVersion(t *testing.T) {
+	container := &apicontainer.Container{Name: "c1"}
+	task := &Task{
+		Arn:                "arn:aws:ecs:us-west-2:123456789012:task/t1",
+		Containers:         []*apicontainer.Container{container},
+		ResourcesMapUnsafe: make(map[string][]taskresource.TaskResource),
+	}
+	res := asmauth.NewASMAuthResource(task.Arn, nil, "", nil, nil)
+	task.AddResource(asmauth.ResourceName, res)
+
+	version := task.ChangeVersion()
+	assert.Equal(t, version, task.ChangeVersion(), "Reading the task should not change its version")
+	task.String()
+	task.GetKnownStatus()
+	assert.Equal(t, version, task.ChangeVersion(), "Reading the task should not change its version")
+
+	task.SetKnownStatus(apitaskstatus.TaskRunning)
+	assert.NotEqual(t, version, task.ChangeVersion(), "Changing the task should change its version")
+
+	version = task.ChangeVersion()
+	container.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	assert.NotEqual(t, version, task.ChangeVersion(), "Changing a container should change the task version")
+
+	version = task.ChangeVersion()
+	res.SetKnownStatus(resourcestatus.ResourceCreated)
+	assert.NotEqual(t, version, task.ChangeVersion(), "Changing a resource status should change the task version")
+
+	version = task.ChangeVersion()
+	res.SetCreatedAt(time.Now())
+	assert.NotEqual(t, version, task.ChangeVersion(), "Changing a resource should change the task version")
+}
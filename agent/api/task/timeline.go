@@ -0,0 +1,75 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package task
+
+import (
+	"time"
+)
+
+const (
+	// TimelineEntityTask indicates that a timeline event is about the task itself
+	TimelineEntityTask = "task"
+	// TimelineEntityContainer indicates that a timeline event is about a container of the task
+	TimelineEntityContainer = "container"
+	// TimelineEntityResource indicates that a timeline event is about a resource of the task
+	TimelineEntityResource = "resource"
+
+	// maxTimelineEvents is the number of most recent events retained in the
+	// timeline of a task
+	maxTimelineEvents = 200
+)
+
+// TimelineEvent records a change in the known or desired status of a task, one of
+// its containers or one of its resources
+type TimelineEvent struct {
+	// Time is when the change was observed
+	Time time.Time `json:"Time"`
+	// Source is the kind of event that caused the change
+	Source string `json:"Source"`
+	// Entity is the kind of entity that changed, one of task, container or resource
+	Entity string `json:"Entity"`
+	// Name is the name of the container or resource that changed
+	Name string `json:"Name,omitempty"`
+	// KnownStatus is the new known status, if it changed
+	KnownStatus string `json:"KnownStatus,omitempty"`
+	// DesiredStatus is the new desired status, if it changed
+	DesiredStatus string `json:"DesiredStatus,omitempty"`
+	// Error is the error that came with the change, if any
+	Error string `json:"Error,omitempty"`
+}
+
+// AddTimelineEvent appends events to the timeline of the task, dropping the
+// oldest events once the timeline is full
+func (task *Task) AddTimelineEvent(events ...TimelineEvent) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	task.TimelineUnsafe = append(task.TimelineUnsafe, events...)
+	if len(task.TimelineUnsafe) > maxTimelineEvents {
+		task.TimelineUnsafe = task.TimelineUnsafe[len(task.TimelineUnsafe)-maxTimelineEvents:]
+	}
+}
+
+// GetTimeline returns the timeline of the task, oldest event first
+func (task *Task) GetTimeline() []TimelineEvent {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+
+	if len(task.TimelineUnsafe) == 0 {
+		return nil
+	}
+	timeline := make([]TimelineEvent, len(task.TimelineUnsafe))
+	copy(timeline, task.TimelineUnsafe)
+	return timeline
+}
@@ -0,0 +1,72 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTimelineEventDropsOldestEvents(t *testing.T) {
+	task := &Task{}
+	for i := 0; i < maxTimelineEvents+5; i++ {
+		task.AddTimelineEvent(TimelineEvent{
+			Source: "test",
+			Entity: TimelineEntityContainer,
+			Name:   fmt.Sprintf("container%d", i),
+		})
+	}
+
+	timeline := task.GetTimeline()
+	require.Len(t, timeline, maxTimelineEvents)
+	assert.Equal(t, "container5", timeline[0].Name)
+	assert.Equal(t, fmt.Sprintf("container%d", maxTimelineEvents+4), timeline[maxTimelineEvents-1].Name)
+}
+
+func TestTimelineIsSavedWithTask(t *testing.T) {
+	task := &Task{Arn: "arn"}
+	event := TimelineEvent{
+		Time:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Source:        "dockerContainerChange",
+		Entity:        TimelineEntityContainer,
+		Name:          "web",
+		KnownStatus:   "STOPPED",
+		DesiredStatus: "STOPPED",
+		Error:         "container exited",
+	}
+	task.AddTimelineEvent(event)
+
+	data, err := json.Marshal(task)
+	require.NoError(t, err)
+	var restored Task
+	require.NoError(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, []TimelineEvent{event}, restored.GetTimeline())
+}
+
+func TestGetTimelineReturnsCopy(t *testing.T) {
+	task := &Task{}
+	assert.Nil(t, task.GetTimeline())
+
+	task.AddTimelineEvent(TimelineEvent{Name: "web"})
+	timeline := task.GetTimeline()
+	timeline[0].Name = "changed"
+	assert.Equal(t, "web", task.GetTimeline()[0].Name)
+}
@@ -85,6 +85,9 @@ func ContainersEqual(lhs, rhs *apicontainer.Container) bool {
 	if !reflect.DeepEqual(lhs.GetKnownExitCode(), rhs.GetKnownExitCode()) {
 		return false
 	}
+	if !reflect.DeepEqual(lhs.GetStopReason(), rhs.GetStopReason()) {
+		return false
+	}
 
 	return true
 }
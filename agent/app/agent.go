@@ -28,6 +28,7 @@ import (
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	"github.com/aws/amazon-ecs-agent/agent/app/factory"
 	"github.com/aws/amazon-ecs-agent/agent/app/oswrapper"
+	"github.com/aws/amazon-ecs-agent/agent/app/statecmd"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/containermetadata"
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
@@ -39,12 +40,14 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/ecscni"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 	"github.com/aws/amazon-ecs-agent/agent/eni/pause"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
 	"github.com/aws/amazon-ecs-agent/agent/handlers"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/standalone"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
@@ -81,6 +84,12 @@ type agent interface {
 	// printECSAttributes prints the Agent's capabilities based on
 	// its environment
 	printECSAttributes() int
+	// printImageCleanupPlan prints what the next image cleanup cycle would
+	// do with each image
+	printImageCleanupPlan() int
+	// rotateStateEncryptionKey re-encrypts the saved state with the key in
+	// the given file
+	rotateStateEncryptionKey(newKeyFile string) int
 	// startWindowsService starts the agent as a Windows Service
 	startWindowsService() int
 	// start starts the Agent execution
@@ -196,6 +205,64 @@ func (agent *ecsAgent) printECSAttributes() int {
 	return exitcodes.ExitSuccess
 }
 
+// printImageCleanupPlan prints what the next image cleanup cycle would do with
+// each image on the instance, based on the saved state, without removing anything.
+// The saved state is migrated as it's read, but is neither saved again nor
+// recovered from a snapshot.
+func (agent *ecsAgent) printImageCleanupPlan() int {
+	imageManager := engine.NewImageManager(agent.cfg, agent.dockerClient, dockerstate.NewTaskEngineState())
+	if agent.cfg.Checkpoint {
+		_, taskEngineState, err := statecmd.ReadState(agent.cfg)
+		if err != nil && !statemanager.IsNoSavedState(err) {
+			seelog.Criticalf("Error reading previously saved state: %v", err)
+			return exitcodes.ExitError
+		}
+		if taskEngineState != nil {
+			imageManager.AddAllImageStates(savedImageStates(taskEngineState))
+		}
+	}
+
+	plan := imageManager.GetImageCleanupPlan(agent.ctx)
+	planJSON, err := json.MarshalIndent(&plan, "", "  ")
+	if err != nil {
+		seelog.Errorf("Unable to marshal the image cleanup plan: %v", err)
+		return exitcodes.ExitError
+	}
+	fmt.Println(string(planJSON))
+	return exitcodes.ExitSuccess
+}
+
+// savedImageStates returns the saved image states, with references to the
+// saved containers that use them, as the image manager has once the task
+// engine restored the state
+func savedImageStates(taskEngineState *dockerstate.SavedState) []*image.ImageState {
+	imageStates := make(map[string]*image.ImageState)
+	for _, imageState := range taskEngineState.ImageStates {
+		if imageState.Image != nil {
+			imageStates[imageState.Image.ImageID] = imageState
+		}
+	}
+	for _, task := range taskEngineState.Tasks {
+		for _, container := range task.Containers {
+			if imageState, ok := imageStates[container.ImageID]; ok {
+				imageState.UpdateContainerReference(container)
+			}
+		}
+	}
+	return taskEngineState.ImageStates
+}
+
+// rotateStateEncryptionKey re-encrypts the saved state with the key in
+// newKeyFile, decrypting it with the configured key
+func (agent *ecsAgent) rotateStateEncryptionKey(newKeyFile string) int {
+	if err := statemanager.RotateEncryptionKey(agent.cfg, newKeyFile); err != nil {
+		seelog.Criticalf("Error rotating the state encryption key: %v", err)
+		return exitcodes.ExitError
+	}
+	fmt.Printf("The saved state is now encrypted with the key in %s. Configure the agent with it before starting it again.\n", newKeyFile)
+	return exitcodes.ExitSuccess
+}
+
 func (agent *ecsAgent) setTerminationHandler(handler sighandlers.TerminationHandler) {
 	agent.terminationHandler = handler
 }
@@ -301,6 +368,12 @@ func (agent *ecsAgent) doStart(containerChangeEventStream *eventstream.EventStre
 		}
 	}
 
+	if agent.cfg.StandaloneEnabled() {
+		// Tasks are read locally, there is no container instance to register
+		return agent.startStandaloneSession(containerChangeEventStream, credentialsManager, imageManager,
+			taskEngine, stateManager, client, state)
+	}
+
 	// Register the container instance
 	err = agent.registerContainerInstance(stateManager, client, vpcSubnetAttributes)
 	if err != nil {
@@ -614,17 +687,20 @@ func (agent *ecsAgent) startAsyncRoutines(
 		go imageManager.StartImageCleanupProcess(agent.ctx)
 	}
 
+	// Pull the images of the pre-warm list and keep them refreshed
+	go imageManager.StartImagePrewarm(agent.ctx)
+
 	// Start automatic spot instance draining poller routine
 	if agent.cfg.SpotInstanceDrainingEnabled {
 		go agent.startSpotInstanceDrainingPoller(client)
 	}
 
-	go agent.terminationHandler(stateManager, taskEngine)
+	statsEngine := stats.NewDockerStatsEngine(agent.cfg, agent.dockerClient, containerChangeEventStream)
 
-	// Agent introspection api
-	go handlers.ServeIntrospectionHTTPEndpoint(&agent.containerInstanceARN, taskEngine, agent.cfg)
+	go agent.terminationHandler(&statsSaver{Saver: stateManager, statsEngine: statsEngine}, taskEngine)
 
-	statsEngine := stats.NewDockerStatsEngine(agent.cfg, agent.dockerClient, containerChangeEventStream)
+	// Agent introspection api
+	go handlers.ServeIntrospectionHTTPEndpoint(&agent.containerInstanceARN, taskEngine, imageManager, statsEngine, agent.cfg)
 
 	// Start serving the endpoint to fetch IAM Role credentials and other task metadata
 	if agent.cfg.TaskMetadataAZDisabled {
@@ -729,6 +805,54 @@ func (agent *ecsAgent) startACSSession(
 	return exitcodes.ExitError
 }
 
+// startStandaloneSession runs the tasks found in the local task directory or
+// received on the local task socket, without registering the container instance
+// or communicating with ACS and TCS. This is a blocking call
+func (agent *ecsAgent) startStandaloneSession(
+	containerChangeEventStream *eventstream.EventStream,
+	credentialsManager credentials.Manager,
+	imageManager engine.ImageManager,
+	taskEngine engine.TaskEngine,
+	stateManager statemanager.StateManager,
+	client api.ECSClient,
+	state dockerstate.TaskEngineState) int {
+
+	sink, err := standalone.NewStateChangeLog(agent.cfg.StandaloneStateChangeLog)
+	if err != nil {
+		seelog.Criticalf("Unable to open the state change log: %v", err)
+		return exitcodes.ExitTerminal
+	}
+
+	taskEngine.SetSaver(stateManager)
+	imageManager.SetSaver(stateManager)
+	taskEngine.MustInit(agent.ctx)
+
+	if !agent.cfg.ImageCleanupDisabled {
+		go imageManager.StartImageCleanupProcess(agent.ctx)
+	}
+	go imageManager.StartImagePrewarm(agent.ctx)
+
+	// The stats engine is normally initialized by the telemetry session, which
+	// is not started in standalone mode
+	statsEngine := stats.NewDockerStatsEngine(agent.cfg, agent.dockerClient, containerChangeEventStream)
+	go agent.terminationHandler(&statsSaver{Saver: stateManager, statsEngine: statsEngine}, taskEngine)
+	err = statsEngine.MustInit(agent.ctx, taskEngine, agent.cfg.Cluster, agent.containerInstanceARN)
+	if err != nil {
+		seelog.Warnf("Error initializing metrics engine: %v", err)
+	}
+	go handlers.ServeIntrospectionHTTPEndpoint(&agent.containerInstanceARN, taskEngine, imageManager, statsEngine, agent.cfg)
+	go handlers.ServeTaskHTTPEndpoint(credentialsManager, state, client, agent.containerInstanceARN, agent.cfg,
+		statsEngine, agent.availabilityZone)
+
+	seelog.Info("Running in standalone mode")
+	err = standalone.NewSession(agent.ctx, agent.cfg, taskEngine, credentialsManager, sink).Start()
+	if err != nil {
+		seelog.Criticalf("Unretriable error running standalone session: %v", err)
+		return exitcodes.ExitTerminal
+	}
+	return exitcodes.ExitSuccess
+}
+
 // validateRequiredVersion validates docker version.
 // Minimum docker version supported is 1.9.0, maps to api version 1.21
 // see https://docs.docker.com/develop/sdk/#api-version-matrix
@@ -802,3 +926,20 @@ func (agent *ecsAgent) getHostPublicIPv4AddressFromEC2Metadata() string {
 	}
 	return hostPublicIPv4Address
 }
+
+// statsSaver saves the stats of the watched containers along with the state,
+// so that both are saved when the agent is terminated
+type statsSaver struct {
+	statemanager.Saver
+	statsEngine *stats.DockerStatsEngine
+}
+
+// ForceSave saves the container stats before the state. Failing to save the
+// stats doesn't fail the final save, as they only fill the gaps in the metrics
+// after a restart.
+func (saver *statsSaver) ForceSave() error {
+	if err := saver.statsEngine.SaveStats(); err != nil {
+		seelog.Warnf("Error saving container stats: %v", err)
+	}
+	return saver.Saver.ForceSave()
+}
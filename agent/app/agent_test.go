@@ -19,14 +19,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"testing"
 
 	mock_pause "github.com/aws/amazon-ecs-agent/agent/eni/pause/mocks"
 
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
 	mock_api "github.com/aws/amazon-ecs-agent/agent/api/mocks"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	mock_factory "github.com/aws/amazon-ecs-agent/agent/app/factory/mocks"
 	app_mocks "github.com/aws/amazon-ecs-agent/agent/app/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/config"
@@ -38,7 +43,9 @@ import (
 	mock_ec2 "github.com/aws/amazon-ecs-agent/agent/ec2/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/model/ecs"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	mock_dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
@@ -50,6 +57,7 @@ import (
 	aws_credentials "github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -343,6 +351,7 @@ func TestDoStartRegisterAvailabilityZone(t *testing.T) {
 	mockPauseLoader.EXPECT().IsLoaded(gomock.Any()).Return(false, nil).AnyTimes()
 	mockPauseLoader.EXPECT().LoadImage(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 	imageManager.EXPECT().StartImageCleanupProcess(gomock.Any()).MaxTimes(1)
+	imageManager.EXPECT().StartImagePrewarm(gomock.Any()).MaxTimes(1)
 	dockerClient.EXPECT().ListContainers(gomock.Any(), gomock.Any(), gomock.Any()).Return(
 		dockerapi.ListContainersResponse{}).AnyTimes()
 	client.EXPECT().DiscoverPollEndpoint(gomock.Any()).Do(func(x interface{}) {
@@ -1424,3 +1433,94 @@ func getTestConfig() config.Config {
 	cfg.TaskCPUMemLimit = config.ExplicitlyDisabled
 	return cfg
 }
+
+// writeTestState writes a state file with the given task engine state to a
+// new temporary data directory
+func writeTestState(t *testing.T, taskEngineState string) (string, func()) {
+	dataDir, err := ioutil.TempDir("", "ecs_agent_test")
+	require.NoError(t, err)
+	data := fmt.Sprintf(`{"Data":{"TaskEngine":%s},"Version":%d}`, taskEngineState, statemanager.ECSDataVersion)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "ecs_agent_data.json"), []byte(data), 0600))
+	return dataDir, func() { os.RemoveAll(dataDir) }
+}
+
+func TestPrintImageCleanupPlan(t *testing.T) {
+	ctrl, _, _, _, _, dockerClient, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	dataDir, cleanup := writeTestState(t, `{"Tasks":[],"ImageStates":[{"Image":{"ImageID":"sha256:1","Names":["busybox"]}}]}`)
+	defer cleanup()
+	cfg := getTestConfig()
+	cfg.Checkpoint = true
+	cfg.DataDir = dataDir
+	dockerClient.EXPECT().ListImages(gomock.Any(), gomock.Any()).Return(dockerapi.ListImagesResponse{})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	agent := &ecsAgent{
+		ctx:          ctx,
+		cfg:          &cfg,
+		dockerClient: dockerClient,
+	}
+	assert.Equal(t, exitcodes.ExitSuccess, agent.printImageCleanupPlan())
+}
+
+func TestPrintImageCleanupPlanNoSavedState(t *testing.T) {
+	ctrl, _, _, _, _, dockerClient, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	dataDir, err := ioutil.TempDir("", "ecs_agent_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	cfg := getTestConfig()
+	cfg.Checkpoint = true
+	cfg.DataDir = dataDir
+	dockerClient.EXPECT().ListImages(gomock.Any(), gomock.Any()).Return(dockerapi.ListImagesResponse{})
+
+	agent := &ecsAgent{
+		ctx:          context.TODO(),
+		cfg:          &cfg,
+		dockerClient: dockerClient,
+	}
+	assert.Equal(t, exitcodes.ExitSuccess, agent.printImageCleanupPlan())
+}
+
+func TestPrintImageCleanupPlanStateReadError(t *testing.T) {
+	ctrl, _, _, _, _, dockerClient, _, _ := setup(t)
+	defer ctrl.Finish()
+
+	dataDir, cleanup := writeTestState(t, `{"Tasks":`)
+	defer cleanup()
+	cfg := getTestConfig()
+	cfg.Checkpoint = true
+	cfg.DataDir = dataDir
+
+	agent := &ecsAgent{
+		ctx:          context.TODO(),
+		cfg:          &cfg,
+		dockerClient: dockerClient,
+	}
+	assert.Equal(t, exitcodes.ExitError, agent.printImageCleanupPlan())
+	// The corrupted state is left for the agent to recover from
+	files, err := ioutil.ReadDir(dataDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "ecs_agent_data.json", files[0].Name())
+}
+
+func TestSavedImageStates(t *testing.T) {
+	taskEngineState := &dockerstate.SavedState{
+		Tasks: []*apitask.Task{{
+			Arn:        "t1",
+			Containers: []*apicontainer.Container{{Name: "c1", ImageID: "sha256:used"}},
+		}},
+		ImageStates: []*image.ImageState{
+			{Image: &image.Image{ImageID: "sha256:used"}},
+			{Image: &image.Image{ImageID: "sha256:unused"}},
+		},
+	}
+	imageStates := savedImageStates(taskEngineState)
+	require.Len(t, imageStates, 2)
+	assert.False(t, imageStates[0].HasNoAssociatedContainers())
+	assert.True(t, imageStates[1].HasNoAssociatedContainers())
+}
@@ -44,8 +44,10 @@ func (m *mockAgent) start() int {
 func (m *mockAgent) setTerminationHandler(handler sighandlers.TerminationHandler) {
 	m.terminationHandler = handler
 }
-func (m *mockAgent) printECSAttributes() int  { return 0 }
-func (m *mockAgent) startWindowsService() int { return 0 }
+func (m *mockAgent) printECSAttributes() int                        { return 0 }
+func (m *mockAgent) printImageCleanupPlan() int                     { return 0 }
+func (m *mockAgent) rotateStateEncryptionKey(newKeyFile string) int { return 0 }
+func (m *mockAgent) startWindowsService() int                       { return 0 }
 
 func TestHandler_RunAgent_StartExitImmediately(t *testing.T) {
 	// register some mocks, but nothing should get called on any of them
@@ -24,6 +24,8 @@ const (
 	blacholeEC2MetadataUsage = "Blackhole the EC2 Metadata requests. Setting this option can cause the ECS Agent to fail to work properly.  We do not recommend setting this option"
 	windowsServiceUsage      = "Run the ECS agent as a Windows Service"
 	healthcheckServiceUsage  = "Run the agent healthcheck"
+	imageCleanupPlanUsage    = "Print what the next image cleanup cycle would do with each image and exit, without removing anything"
+	rotateStateKeyUsage      = "Re-encrypt the saved state with the base64 encoded key in the given file and exit. The agent must be configured with the new key afterwards"
 
 	versionFlagName              = "version"
 	logLevelFlagName             = "loglevel"
@@ -33,6 +35,8 @@ const (
 	blackholeEC2MetadataFlagName = "blackhole-ec2-metadata"
 	windowsServiceFlagName       = "windows-service"
 	healthCheckFlagName          = "healthcheck"
+	imageCleanupPlanFlagName     = "image-cleanup-plan"
+	rotateStateKeyFlagName       = "rotate-state-encryption-key"
 )
 
 // Args wraps various ECS Agent arguments
@@ -55,6 +59,12 @@ type Args struct {
 	WindowsService *bool
 	// Healthcheck indicates that agent should run healthcheck
 	Healthcheck *bool
+	// ImageCleanupPlan indicates that the agent should print the image cleanup
+	// plan
+	ImageCleanupPlan *bool
+	// RotateStateEncryptionKey is the path to the file with the key that the
+	// saved state should be re-encrypted with
+	RotateStateEncryptionKey *string
 }
 
 // New creates a new Args object from the argument list
@@ -62,14 +72,16 @@ func New(arguments []string) (*Args, error) {
 	flagset := flag.NewFlagSet("Amazon ECS Agent", flag.ContinueOnError)
 
 	args := &Args{
-		Version:              flagset.Bool(versionFlagName, false, versionUsage),
-		LogLevel:             flagset.String(logLevelFlagName, "", logLevelUsage),
-		AcceptInsecureCert:   flagset.Bool(acceptInsecureCertFlagName, false, acceptInsecureCertUsage),
-		License:              flagset.Bool(licenseFlagName, false, licenseUsage),
-		BlackholeEC2Metadata: flagset.Bool(blackholeEC2MetadataFlagName, false, blacholeEC2MetadataUsage),
-		ECSAttributes:        flagset.Bool(ecsAttributesFlagName, false, ecsAttributesUsage),
-		WindowsService:       flagset.Bool(windowsServiceFlagName, false, windowsServiceUsage),
-		Healthcheck:          flagset.Bool(healthCheckFlagName, false, healthcheckServiceUsage),
+		Version:                  flagset.Bool(versionFlagName, false, versionUsage),
+		LogLevel:                 flagset.String(logLevelFlagName, "", logLevelUsage),
+		AcceptInsecureCert:       flagset.Bool(acceptInsecureCertFlagName, false, acceptInsecureCertUsage),
+		License:                  flagset.Bool(licenseFlagName, false, licenseUsage),
+		BlackholeEC2Metadata:     flagset.Bool(blackholeEC2MetadataFlagName, false, blacholeEC2MetadataUsage),
+		ECSAttributes:            flagset.Bool(ecsAttributesFlagName, false, ecsAttributesUsage),
+		WindowsService:           flagset.Bool(windowsServiceFlagName, false, windowsServiceUsage),
+		Healthcheck:              flagset.Bool(healthCheckFlagName, false, healthcheckServiceUsage),
+		ImageCleanupPlan:         flagset.Bool(imageCleanupPlanFlagName, false, imageCleanupPlanUsage),
+		RotateStateEncryptionKey: flagset.String(rotateStateKeyFlagName, "", rotateStateKeyUsage),
 	}
 
 	err := flagset.Parse(arguments)
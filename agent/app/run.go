@@ -15,9 +15,11 @@ package app
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/app/args"
+	"github.com/aws/amazon-ecs-agent/agent/app/statecmd"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
 	"github.com/aws/amazon-ecs-agent/agent/version"
@@ -30,6 +32,12 @@ import (
 func Run(arguments []string) int {
 	defer log.Flush()
 
+	if len(arguments) > 0 && arguments[0] == statecmd.Name {
+		// Work on the saved state of the agent without starting it
+		logger.SetLevel("warn")
+		return statecmd.Run(arguments[1:], os.Stdout)
+	}
+
 	parsedArgs, err := args.New(arguments)
 	if err != nil {
 		return exitcodes.ExitTerminal
@@ -63,6 +71,12 @@ func Run(arguments []string) int {
 	case *parsedArgs.ECSAttributes:
 		// Print agent's ecs attributes based on its environment and exit
 		return agent.printECSAttributes()
+	case *parsedArgs.ImageCleanupPlan:
+		// Print what the next image cleanup cycle would do and exit
+		return agent.printImageCleanupPlan()
+	case *parsedArgs.RotateStateEncryptionKey != "":
+		// Re-encrypt the saved state with a new key and exit
+		return agent.rotateStateEncryptionKey(*parsedArgs.RotateStateEncryptionKey)
 	case *parsedArgs.WindowsService:
 		// Enable Windows Service
 		return agent.startWindowsService()
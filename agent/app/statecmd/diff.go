@@ -0,0 +1,252 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statecmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/pkg/errors"
+)
+
+// changes lists the differences between two saved states, a line for each
+// that starts with "+" for what was added, "-" for what was removed and "~"
+// for what changed
+type changes []string
+
+func (c *changes) added(format string, args ...interface{}) {
+	*c = append(*c, "+ "+fmt.Sprintf(format, args...))
+}
+
+func (c *changes) removed(format string, args ...interface{}) {
+	*c = append(*c, "- "+fmt.Sprintf(format, args...))
+}
+
+// changed records a change of the value of what, if there's one
+func (c *changes) changed(what string, from, to string) {
+	if from != to {
+		*c = append(*c, fmt.Sprintf("~ %s: %s -> %s", what, from, to))
+	}
+}
+
+func diff(out io.Writer, fromCfg, toCfg *config.Config) error {
+	from, fromState, err := ReadState(fromCfg)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the state in %s", fromCfg.DataDir)
+	}
+	to, toState, err := ReadState(toCfg)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the state in %s", toCfg.DataDir)
+	}
+	if fromState == nil {
+		fromState = &dockerstate.SavedState{}
+	}
+	if toState == nil {
+		toState = &dockerstate.SavedState{}
+	}
+
+	var c changes
+	c.changed("version", fmt.Sprint(from.SavedVersion), fmt.Sprint(to.SavedVersion))
+	for _, name := range unionKeys(stringSet(saveableNames(from)), stringSet(saveableNames(to))) {
+		c.changed(name, valueOrNone(string(from.Saveables[name])), valueOrNone(string(to.Saveables[name])))
+	}
+	diffTasks(&c, tasksByARN(fromState), tasksByARN(toState))
+	diffContainers(&c, fromState, toState)
+	diffImageStates(&c, imageStatesByID(fromState), imageStatesByID(toState))
+	diffENIAttachments(&c, eniAttachmentsByMAC(fromState), eniAttachmentsByMAC(toState))
+	for _, ipAddr := range unionKeys(stringSet(sortedKeys(fromState.IPToTask)), stringSet(sortedKeys(toState.IPToTask))) {
+		c.changed("task of IP address "+ipAddr, valueOrNone(fromState.IPToTask[ipAddr]), valueOrNone(toState.IPToTask[ipAddr]))
+	}
+
+	if len(c) == 0 {
+		fmt.Fprintln(out, "No differences")
+		return nil
+	}
+	fmt.Fprintln(out, strings.Join(c, "\n"))
+	return nil
+}
+
+func diffTasks(c *changes, from, to map[string]*apitask.Task) {
+	for _, arn := range unionKeys(taskKeys(from), taskKeys(to)) {
+		fromTask, toTask := from[arn], to[arn]
+		switch {
+		case fromTask == nil:
+			c.added("task %s %s:%s known: %s", arn, toTask.Family, toTask.Version, toTask.GetKnownStatus().String())
+			continue
+		case toTask == nil:
+			c.removed("task %s %s:%s", arn, fromTask.Family, fromTask.Version)
+			continue
+		}
+		c.changed("known status of task "+arn, fromTask.GetKnownStatus().String(), toTask.GetKnownStatus().String())
+		c.changed("desired status of task "+arn, fromTask.GetDesiredStatus().String(), toTask.GetDesiredStatus().String())
+
+		fromContainers, toContainers := containersByName(fromTask), containersByName(toTask)
+		for _, name := range unionKeys(containerKeys(fromContainers), containerKeys(toContainers)) {
+			fromContainer, toContainer := fromContainers[name], toContainers[name]
+			what := "container " + name + " of task " + arn
+			switch {
+			case fromContainer == nil:
+				c.added("%s", what)
+			case toContainer == nil:
+				c.removed("%s", what)
+			default:
+				c.changed("known status of "+what, fromContainer.GetKnownStatus().String(), toContainer.GetKnownStatus().String())
+				c.changed("desired status of "+what, fromContainer.GetDesiredStatus().String(), toContainer.GetDesiredStatus().String())
+				c.changed("exit code of "+what, exitCodeString(fromContainer.GetKnownExitCode()), exitCodeString(toContainer.GetKnownExitCode()))
+			}
+		}
+	}
+}
+
+// diffContainers compares the containers of the states by Docker ID
+func diffContainers(c *changes, from, to *dockerstate.SavedState) {
+	fromIDs, toIDs := stringSet(sortedContainerIDs(from)), stringSet(sortedContainerIDs(to))
+	for _, id := range unionKeys(fromIDs, toIDs) {
+		switch {
+		case !fromIDs[id]:
+			c.added("docker container %s of task %s", id, valueOrNone(to.IdToTask[id]))
+		case !toIDs[id]:
+			c.removed("docker container %s of task %s", id, valueOrNone(from.IdToTask[id]))
+		default:
+			c.changed("task of docker container "+id, valueOrNone(from.IdToTask[id]), valueOrNone(to.IdToTask[id]))
+		}
+	}
+}
+
+func diffImageStates(c *changes, from, to map[string]*image.ImageState) {
+	for _, imageID := range unionKeys(imageStateKeys(from), imageStateKeys(to)) {
+		fromImageState, toImageState := from[imageID], to[imageID]
+		switch {
+		case fromImageState == nil:
+			c.added("image %s %s", imageID, strings.Join(toImageState.Image.Names, ", "))
+		case toImageState == nil:
+			c.removed("image %s %s", imageID, strings.Join(fromImageState.Image.Names, ", "))
+		default:
+			c.changed("names of image "+imageID, valueOrNone(strings.Join(fromImageState.Image.Names, ", ")),
+				valueOrNone(strings.Join(toImageState.Image.Names, ", ")))
+			c.changed("last use of image "+imageID, timeString(fromImageState.LastUsedAt), timeString(toImageState.LastUsedAt))
+		}
+	}
+}
+
+func diffENIAttachments(c *changes, from, to map[string]*apieni.ENIAttachment) {
+	for _, mac := range unionKeys(eniAttachmentKeys(from), eniAttachmentKeys(to)) {
+		fromAttachment, toAttachment := from[mac], to[mac]
+		switch {
+		case fromAttachment == nil:
+			c.added("ENI attachment %s of task %s", mac, valueOrNone(toAttachment.TaskARN))
+		case toAttachment == nil:
+			c.removed("ENI attachment %s of task %s", mac, valueOrNone(fromAttachment.TaskARN))
+		default:
+			c.changed("status of ENI attachment "+mac, fromAttachment.Status.String(), toAttachment.Status.String())
+		}
+	}
+}
+
+func tasksByARN(taskEngineState *dockerstate.SavedState) map[string]*apitask.Task {
+	tasks := make(map[string]*apitask.Task)
+	for _, task := range taskEngineState.Tasks {
+		tasks[task.Arn] = task
+	}
+	return tasks
+}
+
+func containersByName(task *apitask.Task) map[string]*apicontainer.Container {
+	containers := make(map[string]*apicontainer.Container)
+	for _, container := range task.Containers {
+		containers[container.Name] = container
+	}
+	return containers
+}
+
+func imageStatesByID(taskEngineState *dockerstate.SavedState) map[string]*image.ImageState {
+	imageStates := make(map[string]*image.ImageState)
+	for _, imageState := range taskEngineState.ImageStates {
+		if imageState.Image != nil {
+			imageStates[imageState.Image.ImageID] = imageState
+		}
+	}
+	return imageStates
+}
+
+func eniAttachmentsByMAC(taskEngineState *dockerstate.SavedState) map[string]*apieni.ENIAttachment {
+	eniAttachments := make(map[string]*apieni.ENIAttachment)
+	for _, eniAttachment := range taskEngineState.ENIAttachments {
+		eniAttachments[eniAttachment.MACAddress] = eniAttachment
+	}
+	return eniAttachments
+}
+
+func taskKeys(m map[string]*apitask.Task) map[string]bool {
+	keys := make(map[string]bool)
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
+
+func containerKeys(m map[string]*apicontainer.Container) map[string]bool {
+	keys := make(map[string]bool)
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
+
+func imageStateKeys(m map[string]*image.ImageState) map[string]bool {
+	keys := make(map[string]bool)
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
+
+func eniAttachmentKeys(m map[string]*apieni.ENIAttachment) map[string]bool {
+	keys := make(map[string]bool)
+	for key := range m {
+		keys[key] = true
+	}
+	return keys
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// unionKeys returns the sorted keys that are in either set
+func unionKeys(a, b map[string]bool) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if !a[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
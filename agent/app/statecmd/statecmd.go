@@ -0,0 +1,330 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package statecmd implements the state command of the agent, which inspects,
+// validates, migrates and compares the state saved in ECS data directories
+// without starting the agent, such as state copied off a broken instance.
+package statecmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name is the name of the state command, as the first argument of the agent
+	Name = "state"
+
+	// taskEngineSaveable is the name the task engine is saved under by the
+	// agent
+	taskEngineSaveable = "TaskEngine"
+
+	keyFileFlagName = "key-file"
+	keyFileUsage    = "Path to the file with the base64 encoded key the state is encrypted with, if it's encrypted"
+
+	usage = `Usage: agent state <command> [-key-file <file>] <data dir>...
+
+Works on the state saved in ECS data directories without starting the agent.
+
+Commands:
+  inspect <data dir>          Print the saved tasks, containers, resources, image states and ENI attachments
+  validate <data dir>         Check the saved state for inconsistencies, and exit with an error if there are any
+  migrate <data dir>          Upgrade the saved state to the version of this agent
+  diff <data dir> <data dir>  Print what changed from the state saved in the first data directory to the second
+`
+)
+
+// command is a state command, which takes a configuration for each data
+// directory it works on
+type command struct {
+	numDataDirs int
+	run         func(out io.Writer, cfgs ...*config.Config) error
+}
+
+var commands = map[string]command{
+	"inspect":  {1, func(out io.Writer, cfgs ...*config.Config) error { return inspect(out, cfgs[0]) }},
+	"validate": {1, func(out io.Writer, cfgs ...*config.Config) error { return validate(out, cfgs[0]) }},
+	"migrate":  {1, func(out io.Writer, cfgs ...*config.Config) error { return migrate(out, cfgs[0]) }},
+	"diff":     {2, func(out io.Writer, cfgs ...*config.Config) error { return diff(out, cfgs[0], cfgs[1]) }},
+}
+
+// Run runs the state command with the arguments that follow its name, writes
+// its output to out and returns the exit code of the agent
+func Run(arguments []string, out io.Writer) int {
+	if len(arguments) == 0 {
+		fmt.Fprint(out, usage)
+		return exitcodes.ExitTerminal
+	}
+	cmd, ok := commands[arguments[0]]
+	if !ok {
+		fmt.Fprintf(out, "Unknown command %q\n\n%s", arguments[0], usage)
+		return exitcodes.ExitTerminal
+	}
+	flagset := flag.NewFlagSet(Name+" "+arguments[0], flag.ContinueOnError)
+	flagset.SetOutput(out)
+	keyFile := flagset.String(keyFileFlagName, "", keyFileUsage)
+	if err := flagset.Parse(arguments[1:]); err != nil {
+		return exitcodes.ExitTerminal
+	}
+	if flagset.NArg() != cmd.numDataDirs {
+		fmt.Fprint(out, usage)
+		return exitcodes.ExitTerminal
+	}
+
+	var cfgs []*config.Config
+	for _, dataDir := range flagset.Args() {
+		cfgs = append(cfgs, &config.Config{
+			DataDir:                dataDir,
+			StateEncryptionKeyFile: *keyFile,
+		})
+	}
+	if err := cmd.run(out, cfgs...); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return exitcodes.ExitError
+	}
+	return exitcodes.ExitSuccess
+}
+
+// ReadState returns the state saved in the data directory of cfg, and the
+// state of the task engine in it, if there's one. Nothing is changed on disk.
+func ReadState(cfg *config.Config) (*statemanager.SavedData, *dockerstate.SavedState, error) {
+	saved, err := statemanager.ReadSavedData(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var taskEngineState *dockerstate.SavedState
+	if data, ok := saved.Saveables[taskEngineSaveable]; ok {
+		taskEngineState, err = dockerstate.ParseSavedState(data)
+	} else if entities, ok := saved.Entities[taskEngineSaveable]; ok {
+		taskEngineState, err = dockerstate.ParseSavedEntities(entities)
+	}
+	if err != nil {
+		return saved, nil, errors.Wrap(err, "could not decode the state of the task engine")
+	}
+	return saved, taskEngineState, nil
+}
+
+func inspect(out io.Writer, cfg *config.Config) error {
+	saved, taskEngineState, err := ReadState(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Store: %s\n", saved.Store)
+	fmt.Fprintf(out, "Version: %d\n", saved.SavedVersion)
+	for _, name := range saveableNames(saved) {
+		fmt.Fprintf(out, "%s: %s\n", name, string(saved.Saveables[name]))
+	}
+	if taskEngineState == nil {
+		return nil
+	}
+
+	dockerIDs := dockerIDsByContainer(taskEngineState)
+	tasks := sortedTasks(taskEngineState)
+	fmt.Fprintf(out, "\nTasks (%d):\n", len(tasks))
+	for _, task := range tasks {
+		fmt.Fprintf(out, "  %s  %s:%s  known: %s  desired: %s\n", task.Arn, task.Family, task.Version,
+			task.GetKnownStatus().String(), task.GetDesiredStatus().String())
+		for _, container := range task.Containers {
+			fmt.Fprintf(out, "    container %s  known: %s  desired: %s  docker ID: %s  exit code: %s\n",
+				container.Name, container.GetKnownStatus().String(), container.GetDesiredStatus().String(),
+				valueOrNone(dockerIDs[task.Arn+"/"+container.Name]), exitCodeString(container.GetKnownExitCode()))
+		}
+		for _, resource := range task.GetResources() {
+			fmt.Fprintf(out, "    resource %s  known: %s  desired: %s\n", resource.GetName(),
+				resource.StatusString(resource.GetKnownStatus()), resource.StatusString(resource.GetDesiredStatus()))
+		}
+	}
+
+	fmt.Fprintf(out, "\nContainers (%d):\n", len(taskEngineState.IdToContainer))
+	for _, id := range sortedContainerIDs(taskEngineState) {
+		var name, dockerName string
+		if container := taskEngineState.IdToContainer[id]; container != nil {
+			dockerName = container.DockerName
+			if container.Container != nil {
+				name = container.Container.Name
+			}
+		}
+		fmt.Fprintf(out, "  %s  name: %s  docker name: %s  task: %s\n", id, valueOrNone(name),
+			valueOrNone(dockerName), valueOrNone(taskEngineState.IdToTask[id]))
+	}
+
+	fmt.Fprintf(out, "\nImage states (%d):\n", len(taskEngineState.ImageStates))
+	for _, imageState := range taskEngineState.ImageStates {
+		if imageState.Image == nil {
+			continue
+		}
+		fmt.Fprintf(out, "  %s  names: %s  size: %d  pulled at: %s  last used at: %s\n", imageState.Image.ImageID,
+			valueOrNone(strings.Join(imageState.Image.Names, ", ")), imageState.Image.Size,
+			timeString(imageState.PulledAt), timeString(imageState.LastUsedAt))
+	}
+
+	fmt.Fprintf(out, "\nENI attachments (%d):\n", len(taskEngineState.ENIAttachments))
+	for _, eniAttachment := range taskEngineState.ENIAttachments {
+		fmt.Fprintf(out, "  %s  type: %s  task: %s  status: %s  expires at: %s\n", eniAttachment.MACAddress,
+			valueOrNone(eniAttachment.AttachmentType), valueOrNone(eniAttachment.TaskARN),
+			eniAttachment.Status.String(), timeString(eniAttachment.ExpiresAt))
+	}
+
+	fmt.Fprintf(out, "\nTask IP addresses (%d):\n", len(taskEngineState.IPToTask))
+	for _, ipAddr := range sortedKeys(taskEngineState.IPToTask) {
+		fmt.Fprintf(out, "  %s  task: %s\n", ipAddr, taskEngineState.IPToTask[ipAddr])
+	}
+	return nil
+}
+
+func validate(out io.Writer, cfg *config.Config) error {
+	saved, taskEngineState, err := ReadState(cfg)
+	if saved == nil {
+		return err
+	}
+	var problems []string
+	if saved.SavedVersion > statemanager.ECSDataVersion {
+		problems = append(problems, fmt.Sprintf("the state was saved by a newer agent, with version %d, than this one, with version %d",
+			saved.SavedVersion, statemanager.ECSDataVersion))
+	}
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if taskEngineState != nil {
+		problems = append(problems, taskEngineState.Check()...)
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "No problems found")
+		return nil
+	}
+	fmt.Fprintf(out, "Problems (%d):\n", len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(out, "  %s\n", problem)
+	}
+	return errors.Errorf("the state in %s is inconsistent", cfg.DataDir)
+}
+
+// migrate upgrades the saved state by loading it the way the agent does, and
+// saving it again. Saveables other than the task engine are saved as they are.
+func migrate(out io.Writer, cfg *config.Config) error {
+	saved, err := statemanager.ReadSavedData(cfg)
+	if err != nil {
+		return err
+	}
+	if saved.SavedVersion > statemanager.ECSDataVersion {
+		return errors.Errorf("the state was saved by a newer agent, with version %d, and can't be downgraded to version %d",
+			saved.SavedVersion, statemanager.ECSDataVersion)
+	}
+	if saved.SavedVersion == statemanager.ECSDataVersion {
+		fmt.Fprintf(out, "The state is already at version %d\n", saved.SavedVersion)
+		return nil
+	}
+
+	cfg.StateStore = saved.Store
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	options := []statemanager.Option{statemanager.AddSaveable(taskEngineSaveable, taskEngine)}
+	for _, name := range saveableNames(saved) {
+		options = append(options, statemanager.AddSaveable(name, &json.RawMessage{}))
+	}
+	stateManager, err := statemanager.NewStateManager(cfg, options...)
+	if err != nil {
+		return err
+	}
+	if err := stateManager.Load(); err != nil {
+		return errors.Wrap(err, "could not load the state")
+	}
+	if err := stateManager.ForceSave(); err != nil {
+		return errors.Wrap(err, "could not save the state")
+	}
+	fmt.Fprintf(out, "Migrated the state from version %d to version %d\n", saved.SavedVersion, statemanager.ECSDataVersion)
+	return nil
+}
+
+// saveableNames returns the sorted names of the saveables other than the task
+// engine
+func saveableNames(saved *statemanager.SavedData) []string {
+	var names []string
+	for name := range saved.Saveables {
+		if name != taskEngineSaveable {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedTasks(taskEngineState *dockerstate.SavedState) []*apitask.Task {
+	tasks := append([]*apitask.Task(nil), taskEngineState.Tasks...)
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Arn < tasks[j].Arn })
+	return tasks
+}
+
+// dockerIDsByContainer returns the Docker ID of each saved container by the
+// ARN of its task and its name, joined by a slash
+func dockerIDsByContainer(taskEngineState *dockerstate.SavedState) map[string]string {
+	dockerIDs := make(map[string]string)
+	for id, container := range taskEngineState.IdToContainer {
+		if container != nil && container.Container != nil {
+			dockerIDs[taskEngineState.IdToTask[id]+"/"+container.Container.Name] = id
+		}
+	}
+	return dockerIDs
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedContainerIDs(taskEngineState *dockerstate.SavedState) []string {
+	var ids []string
+	for id := range taskEngineState.IdToContainer {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func exitCodeString(exitCode *int) string {
+	if exitCode == nil {
+		return "none"
+	}
+	return fmt.Sprint(*exitCode)
+}
+
+func timeString(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
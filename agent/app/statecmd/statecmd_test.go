@@ -0,0 +1,208 @@
+// +build !windows,unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statecmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDataDir = filepath.Join("..", "..", "statemanager", "testdata")
+
+func runState(arguments ...string) (int, string) {
+	var out bytes.Buffer
+	exitCode := Run(arguments, &out)
+	return exitCode, out.String()
+}
+
+// copyDataDir copies the state file in the test data directory to a new
+// temporary directory
+func copyDataDir(t *testing.T, path ...string) (string, func()) {
+	tmpDir, err := ioutil.TempDir("", "ecs_statecmd_test")
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(append(append([]string{testDataDir}, path...), "ecs_agent_data.json")...))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "ecs_agent_data.json"), data, 0600))
+	return tmpDir, func() { os.RemoveAll(tmpDir) }
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, arguments := range [][]string{
+		nil,
+		{"unknown"},
+		{"inspect"},
+		{"diff", "a"},
+		{"inspect", "-unknown-flag", "a"},
+	} {
+		t.Run(fmt.Sprint(arguments), func(t *testing.T) {
+			exitCode, out := runState(arguments...)
+			assert.Equal(t, exitcodes.ExitTerminal, exitCode)
+			assert.Contains(t, out, "Usage")
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dataDirs, err := filepath.Glob(filepath.Join(testDataDir, "v*", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, dataDirs)
+	for _, dataDir := range dataDirs {
+		// gMSA resources can only be decoded on Windows
+		if filepath.Base(dataDir) == "gmsa" {
+			continue
+		}
+		t.Run(dataDir, func(t *testing.T) {
+			exitCode, out := runState("inspect", dataDir)
+			require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+			assert.Contains(t, out, "Store: json\n")
+			assert.Contains(t, out, "Tasks (")
+		})
+	}
+
+	exitCode, out := runState("inspect", filepath.Join(testDataDir, "v1", "1"))
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Contains(t, out, "Version: 1\n")
+	assert.Contains(t, out, "Cluster: \"test\"\n")
+	assert.Contains(t, out, "arn:aws:ecs:us-west-2:1234567890:task/f44b4fc9-adb0-4f4f-9dff-871512310588  nginx:2  known: STOPPED")
+	assert.Contains(t, out, "container nginx  known: STOPPED  desired: STOPPED  docker ID: d1d1293c78bd44124ca841f0f17f48502426fd972545adee2f41aa5899bd41ea  exit code: 128")
+
+	exitCode, out = runState("inspect", filepath.Join(testDataDir, "v28", "environmentFiles"))
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Contains(t, out, "resource envfile")
+
+	emptyDir, err := ioutil.TempDir("", "ecs_statecmd_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(emptyDir)
+	exitCode, out = runState("inspect", emptyDir)
+	assert.Equal(t, exitcodes.ExitError, exitCode)
+	assert.Contains(t, out, "no state is saved")
+}
+
+func TestValidate(t *testing.T) {
+	exitCode, out := runState("validate", filepath.Join(testDataDir, "v28", "environmentFiles"))
+	assert.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Equal(t, "No problems found\n", out)
+
+	dataDir, cleanup := copyDataDir(t, "v28", "environmentFiles")
+	defer cleanup()
+	state := `{"Data":{"TaskEngine":{"Tasks":[{"Arn":"task1","Containers":[{"Name":"web"}]}],` +
+		`"IdToContainer":{"id1":{"DockerId":"id1","Container":{"Name":"web"}},"id2":{"DockerId":"id2","Container":{"Name":"db"}}},` +
+		`"IdToTask":{"id1":"task1","id2":"task2"},"IPToTask":{"172.17.0.2":"task1","172.17.0.3":"task1"}}},"Version":28}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "ecs_agent_data.json"), []byte(state), 0600))
+	exitCode, out = runState("validate", dataDir)
+	assert.Equal(t, exitcodes.ExitError, exitCode)
+	assert.Contains(t, out, "Problems (2):\n")
+	assert.Contains(t, out, "container id2 (db) belongs to task task2, which isn't saved")
+	assert.Contains(t, out, "task task1 has more than one IP address: 172.17.0.2, 172.17.0.3")
+}
+
+func TestMigrateAndDiff(t *testing.T) {
+	dataDir, cleanup := copyDataDir(t, "v1", "1")
+	defer cleanup()
+
+	exitCode, out := runState("migrate", dataDir)
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Equal(t, fmt.Sprintf("Migrated the state from version 1 to version %d\n", statemanager.ECSDataVersion), out)
+
+	// The migrated state is loaded by the agent as it is
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(), nil, nil)
+	var cluster string
+	stateManager, err := statemanager.NewStateManager(&config.Config{DataDir: dataDir},
+		statemanager.AddSaveable("TaskEngine", taskEngine), statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, stateManager.Load())
+	assert.Equal(t, "test", cluster)
+	tasks, err := taskEngine.ListTasks()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 3)
+
+	exitCode, out = runState("migrate", dataDir)
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Equal(t, fmt.Sprintf("The state is already at version %d\n", statemanager.ECSDataVersion), out)
+
+	exitCode, out = runState("diff", filepath.Join(testDataDir, "v1", "1"), dataDir)
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Equal(t, fmt.Sprintf("~ version: 1 -> %d\n", statemanager.ECSDataVersion), out)
+
+	exitCode, out = runState("diff", dataDir, dataDir)
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Equal(t, "No differences\n", out)
+
+	exitCode, out = runState("diff", filepath.Join(testDataDir, "v1", "1"), filepath.Join(testDataDir, "v28", "environmentFiles"))
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Contains(t, out, "- task arn:aws:ecs:us-west-2:1234567890:task/f44b4fc9-adb0-4f4f-9dff-871512310588 nginx:2\n")
+	assert.Contains(t, out, "- docker container d1d1293c78bd44124ca841f0f17f48502426fd972545adee2f41aa5899bd41ea")
+	assert.Contains(t, out, "~ Cluster: \"test\" -> ")
+}
+
+func TestMigrateRefusesDowngrade(t *testing.T) {
+	dataDir, cleanup := copyDataDir(t, "v1", "1")
+	defer cleanup()
+	state := fmt.Sprintf(`{"Data":{"Cluster":"test"},"Version":%d}`, statemanager.ECSDataVersion+1)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "ecs_agent_data.json"), []byte(state), 0600))
+
+	exitCode, out := runState("migrate", dataDir)
+	assert.Equal(t, exitcodes.ExitError, exitCode)
+	assert.Contains(t, out, "can't be downgraded")
+
+	exitCode, out = runState("validate", dataDir)
+	assert.Equal(t, exitcodes.ExitError, exitCode)
+	assert.Contains(t, out, "saved by a newer agent")
+}
+
+func TestEncryptedStore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ecs_statecmd_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	keyFile := filepath.Join(tmpDir, "state.key")
+	require.NoError(t, ioutil.WriteFile(keyFile,
+		[]byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))), 0600))
+	dataDir := filepath.Join(tmpDir, "data")
+	require.NoError(t, os.Mkdir(dataDir, 0700))
+
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(), nil, nil)
+	taskEngine.(*engine.DockerTaskEngine).State().AddTask(&apitask.Task{Arn: "test-arn", Family: "test"})
+	stateManager, err := statemanager.NewStateManager(&config.Config{
+		DataDir:                dataDir,
+		StateStore:             config.StateStoreBoltDB,
+		StateEncryptionKeyFile: keyFile,
+	}, statemanager.AddSaveable("TaskEngine", taskEngine))
+	require.NoError(t, err)
+	require.NoError(t, stateManager.ForceSave())
+
+	exitCode, out := runState("inspect", dataDir)
+	assert.Equal(t, exitcodes.ExitError, exitCode)
+	assert.Contains(t, out, "no state encryption key is configured")
+
+	exitCode, out = runState("inspect", "-key-file", keyFile, dataDir)
+	require.Equal(t, exitcodes.ExitSuccess, exitCode, out)
+	assert.Contains(t, out, "Store: boltdb\n")
+	assert.Contains(t, out, "test-arn  test:")
+}
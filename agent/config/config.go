@@ -60,6 +60,33 @@ const (
 	// clean up task's containers.
 	DefaultTaskCleanupWaitDuration = 3 * time.Hour
 
+	// StateStoreBoltDB specifies that the state is checkpointed in an embedded key-value store
+	StateStoreBoltDB = "boltdb"
+
+	// StateStoreJSON specifies that the state is checkpointed in a single JSON file
+	StateStoreJSON = "json"
+
+	// DefaultStateBackupCount specifies the default number of snapshots of the JSON state file that are kept
+	// to recover the state from if the file is corrupted.
+	DefaultStateBackupCount = 3
+
+	// StatsSourceDocker specifies that container stats are streamed from the docker stats API
+	StatsSourceDocker = "docker"
+
+	// StatsSourceCgroup specifies that container stats are read from the cgroup files of the containers
+	StatsSourceCgroup = "cgroup"
+
+	// DefaultPrometheusContainerMetricsLimit specifies the default maximum number of containers whose resource
+	// usage is exported to Prometheus, to bound the number of series scraped from a dense instance.
+	DefaultPrometheusContainerMetricsLimit = 500
+
+	// DefaultContainerArchiveLogSizeMB specifies the default size in MB of the end of the log of a container
+	// that's archived at task cleanup.
+	DefaultContainerArchiveLogSizeMB = 10
+
+	// DefaultContainerArchiveMaxSizeMB specifies the default size in MB the archived containers are kept under.
+	DefaultContainerArchiveMaxSizeMB = 1024
+
 	// DefaultPollingMetricsWaitDuration specifies the default value for polling metrics wait duration
 	// This is only used when PollMetrics is set to true
 	DefaultPollingMetricsWaitDuration = DefaultContainerMetricsPublishInterval / 2
@@ -103,6 +130,10 @@ const (
 	// from docker. This is only used when PollMetrics is set to true
 	maximumPollingMetricsWaitDuration = DefaultContainerMetricsPublishInterval
 
+	// minimumContainerDiskUsageInterval specifies the minimum interval between samples of the disk usage of
+	// containers, which makes docker walk the writable layers and volumes of all the containers
+	minimumContainerDiskUsageInterval = 1 * time.Minute
+
 	// minimumDockerStopTimeout specifies the minimum value for docker StopContainer API
 	minimumDockerStopTimeout = 1 * time.Second
 
@@ -110,6 +141,10 @@ const (
 	// image cleanup.
 	minimumImageCleanupInterval = 10 * time.Minute
 
+	// defaultImageCleanupDiskWatermarkGap is how far below the high watermark the
+	// low watermark of disk usage is set to when it's not set or invalid
+	defaultImageCleanupDiskWatermarkGap = 10
+
 	// minimumNumImagesToDeletePerCycle specifies the minimum number of images that to be deleted when
 	// performing image cleanup.
 	minimumNumImagesToDeletePerCycle = 1
@@ -318,6 +353,9 @@ func (cfg *Config) validateAndOverrideBounds() error {
 		cfg.TaskCleanupWaitDuration = DefaultTaskCleanupWaitDuration
 	}
 
+	cfg.containerArchiveOverrides()
+	cfg.stateStoreOverrides()
+
 	if cfg.ImagePullInactivityTimeout < minimumImagePullInactivityTimeout {
 		seelog.Warnf("Invalid value for image pull inactivity timeout duration, will be overridden with the default value: %s. Parsed value: %v, minimum value: %v.", defaultImagePullInactivityTimeout.String(), cfg.ImagePullInactivityTimeout, minimumImagePullInactivityTimeout)
 		cfg.ImagePullInactivityTimeout = defaultImagePullInactivityTimeout
@@ -333,6 +371,13 @@ func (cfg *Config) validateAndOverrideBounds() error {
 		cfg.NumImagesToDeletePerCycle = DefaultNumImagesToDeletePerCycle
 	}
 
+	cfg.imageCleanupDiskWatermarkOverrides()
+
+	if cfg.ImagePullConcurrencyPerRegistry < 0 {
+		seelog.Warnf("Invalid value for ECS_IMAGE_PULL_CONCURRENCY_PER_REGISTRY, will be overridden to not limit concurrent pulls. Parsed value: %d.", cfg.ImagePullConcurrencyPerRegistry)
+		cfg.ImagePullConcurrencyPerRegistry = 0
+	}
+
 	if cfg.TaskMetadataSteadyStateRate <= 0 || cfg.TaskMetadataBurstRate <= 0 {
 		seelog.Warnf("Invalid values for rate limits, will be overridden with default values: %d,%d.", DefaultTaskMetadataSteadyStateRate, DefaultTaskMetadataBurstRate)
 		cfg.TaskMetadataSteadyStateRate = DefaultTaskMetadataSteadyStateRate
@@ -342,6 +387,24 @@ func (cfg *Config) validateAndOverrideBounds() error {
 	// check the PollMetrics specific configurations
 	cfg.pollMetricsOverrides()
 
+	if cfg.StatsSource != StatsSourceDocker && cfg.StatsSource != StatsSourceCgroup {
+		seelog.Warnf("Invalid value for ECS_STATS_SOURCE, will be overridden with the default value: %s. Parsed value: %s.", StatsSourceDocker, cfg.StatsSource)
+		cfg.StatsSource = StatsSourceDocker
+	}
+
+	if cfg.ContainerDiskUsageInterval != 0 && cfg.ContainerDiskUsageInterval < minimumContainerDiskUsageInterval {
+		seelog.Warnf("ECS_CONTAINER_DISK_USAGE_INTERVAL parsed value (%s) is less than the minimum of %s. Setting the interval to the minimum.",
+			cfg.ContainerDiskUsageInterval, minimumContainerDiskUsageInterval)
+		cfg.ContainerDiskUsageInterval = minimumContainerDiskUsageInterval
+	}
+
+	if cfg.PrometheusContainerMetricsLimit <= 0 {
+		seelog.Warnf("Invalid value for ECS_PROMETHEUS_CONTAINER_METRICS_LIMIT, will be overridden with the default value: %d. Parsed value: %d.", DefaultPrometheusContainerMetricsLimit, cfg.PrometheusContainerMetricsLimit)
+		cfg.PrometheusContainerMetricsLimit = DefaultPrometheusContainerMetricsLimit
+	}
+
+	cfg.imagePrewarmOverrides()
+
 	cfg.platformOverrides()
 
 	return nil
@@ -363,6 +426,57 @@ func (cfg *Config) pollMetricsOverrides() {
 	}
 }
 
+// imageCleanupDiskWatermarkOverrides disables the disk pressure driven image
+// cleanup if the high watermark is out of range, and sets the low watermark
+// below the high one if it isn't already
+func (cfg *Config) imageCleanupDiskWatermarkOverrides() {
+	if cfg.ImageCleanupDiskHighWatermark < 0 || cfg.ImageCleanupDiskHighWatermark > 100 {
+		seelog.Warnf("Invalid value for ECS_IMAGE_CLEANUP_DISK_HIGH_WATERMARK, disk usage will not be watched. Parsed value: %d, expected a percentage.", cfg.ImageCleanupDiskHighWatermark)
+		cfg.ImageCleanupDiskHighWatermark = 0
+	}
+	if cfg.ImageCleanupDiskHighWatermark == 0 {
+		cfg.ImageCleanupDiskLowWatermark = 0
+		return
+	}
+	if cfg.ImageCleanupDiskLowWatermark <= 0 || cfg.ImageCleanupDiskLowWatermark >= cfg.ImageCleanupDiskHighWatermark {
+		lowWatermark := cfg.ImageCleanupDiskHighWatermark - defaultImageCleanupDiskWatermarkGap
+		if lowWatermark < 0 {
+			lowWatermark = 0
+		}
+		if cfg.ImageCleanupDiskLowWatermark != 0 {
+			seelog.Warnf("Invalid value for ECS_IMAGE_CLEANUP_DISK_LOW_WATERMARK, will be overridden with %d. Parsed value: %d, high watermark: %d.", lowWatermark, cfg.ImageCleanupDiskLowWatermark, cfg.ImageCleanupDiskHighWatermark)
+		}
+		cfg.ImageCleanupDiskLowWatermark = lowWatermark
+	}
+}
+
+// containerArchiveOverrides sets the sizes the container archive is bounded by
+// to their defaults if they aren't positive
+func (cfg *Config) containerArchiveOverrides() {
+	if cfg.ContainerArchiveLogSizeMB <= 0 {
+		seelog.Warnf("Invalid value for ECS_CONTAINER_ARCHIVE_LOG_SIZE_MB, will be overridden with the default value: %d. Parsed value: %d.", DefaultContainerArchiveLogSizeMB, cfg.ContainerArchiveLogSizeMB)
+		cfg.ContainerArchiveLogSizeMB = DefaultContainerArchiveLogSizeMB
+	}
+	if cfg.ContainerArchiveMaxSizeMB <= 0 {
+		seelog.Warnf("Invalid value for ECS_CONTAINER_ARCHIVE_MAX_SIZE_MB, will be overridden with the default value: %d. Parsed value: %d.", DefaultContainerArchiveMaxSizeMB, cfg.ContainerArchiveMaxSizeMB)
+		cfg.ContainerArchiveMaxSizeMB = DefaultContainerArchiveMaxSizeMB
+	}
+}
+
+// stateStoreOverrides sets the state store to the default if it isn't one the
+// agent knows about, and the number of state file snapshots to the default if
+// it isn't positive
+func (cfg *Config) stateStoreOverrides() {
+	if cfg.StateStore != StateStoreBoltDB && cfg.StateStore != StateStoreJSON {
+		seelog.Warnf("Invalid value for ECS_STATE_STORE, will be overridden with the default value: %s. Parsed value: %s.", StateStoreJSON, cfg.StateStore)
+		cfg.StateStore = StateStoreJSON
+	}
+	if cfg.StateBackupCount <= 0 {
+		seelog.Warnf("Invalid value for ECS_STATE_BACKUP_COUNT, will be overridden with the default value: %d. Parsed value: %d.", DefaultStateBackupCount, cfg.StateBackupCount)
+		cfg.StateBackupCount = DefaultStateBackupCount
+	}
+}
+
 // checkMissingAndDeprecated checks all zero-valued fields for tags of the form
 // missing:STRING and acts based on that string. Current options are: fatal,
 // warn. Fatal will result in an error being returned, warn will result in a
@@ -415,6 +529,12 @@ func (cfg *Config) complete() bool {
 	return true
 }
 
+// StandaloneEnabled returns true if the Agent is configured to run tasks from a
+// local directory or socket instead of receiving them from ECS
+func (cfg *Config) StandaloneEnabled() bool {
+	return cfg.StandaloneTaskDir != "" || cfg.StandaloneTaskSocket != ""
+}
+
 func fileConfig() (Config, error) {
 	fileName := utils.DefaultIfBlank(os.Getenv("ECS_AGENT_CONFIG_FILE_PATH"), defaultConfigFileName)
 	cfg := Config{}
@@ -494,6 +614,8 @@ func environmentConfig() (Config, error) {
 
 	additionalLocalRoutes, errs := parseAdditionalLocalRoutes(errs)
 
+	imagePrewarmList, errs := parseImagePrewarmList(errs)
+
 	var err error
 	if len(errs) > 0 {
 		err = apierrors.NewMultiError(errs...)
@@ -507,17 +629,25 @@ func environmentConfig() (Config, error) {
 		ReservedPortsUDP:                    parseReservedPorts("ECS_RESERVED_PORTS_UDP"),
 		DataDir:                             dataDir,
 		Checkpoint:                          parseCheckpoint(dataDir),
+		StateStore:                          os.Getenv("ECS_STATE_STORE"),
+		StateEncryptionKey:                  NewSensitiveRawMessage([]byte(os.Getenv("ECS_STATE_ENCRYPTION_KEY"))),
+		StateEncryptionKeyFile:              os.Getenv("ECS_STATE_ENCRYPTION_KEY_FILE"),
+		StateBackupCount:                    parseStateBackupCount(),
 		EngineAuthType:                      os.Getenv("ECS_ENGINE_AUTH_TYPE"),
 		EngineAuthData:                      NewSensitiveRawMessage([]byte(os.Getenv("ECS_ENGINE_AUTH_DATA"))),
 		UpdatesEnabled:                      utils.ParseBool(os.Getenv("ECS_UPDATES_ENABLED"), false),
 		UpdateDownloadDir:                   os.Getenv("ECS_UPDATE_DOWNLOAD_DIR"),
 		DisableMetrics:                      utils.ParseBool(os.Getenv("ECS_DISABLE_METRICS"), false),
+		PrometheusContainerMetricsLimit:     parsePrometheusContainerMetricsLimit(),
 		ReservedMemory:                      parseEnvVariableUint16("ECS_RESERVED_MEMORY"),
 		AvailableLoggingDrivers:             parseAvailableLoggingDrivers(),
 		PrivilegedDisabled:                  utils.ParseBool(os.Getenv("ECS_DISABLE_PRIVILEGED"), false),
 		SELinuxCapable:                      utils.ParseBool(os.Getenv("ECS_SELINUX_CAPABLE"), false),
 		AppArmorCapable:                     utils.ParseBool(os.Getenv("ECS_APPARMOR_CAPABLE"), false),
 		TaskCleanupWaitDuration:             parseEnvVariableDuration("ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION"),
+		ContainerArchiveEnabled:             utils.ParseBool(os.Getenv("ECS_ENABLE_CONTAINER_ARCHIVE"), false),
+		ContainerArchiveLogSizeMB:           parseContainerArchiveSizeMB("ECS_CONTAINER_ARCHIVE_LOG_SIZE_MB"),
+		ContainerArchiveMaxSizeMB:           parseContainerArchiveSizeMB("ECS_CONTAINER_ARCHIVE_MAX_SIZE_MB"),
 		TaskENIEnabled:                      utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_ENI"), false),
 		TaskIAMRoleEnabled:                  utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_IAM_ROLE"), false),
 		DeleteNonECSImagesEnabled:           utils.ParseBool(os.Getenv("ECS_ENABLE_UNTRACKED_IMAGE_CLEANUP"), false),
@@ -535,7 +665,13 @@ func environmentConfig() (Config, error) {
 		NumImagesToDeletePerCycle:           parseNumImagesToDeletePerCycle(),
 		NumNonECSContainersToDeletePerCycle: parseNumNonECSContainersToDeletePerCycle(),
 		ImagePullBehavior:                   parseImagePullBehavior(),
+		ImagePullConcurrencyPerRegistry:     parseImagePullConcurrencyPerRegistry(),
+		ImageCleanupDiskHighWatermark:       parseImageCleanupDiskWatermark("ECS_IMAGE_CLEANUP_DISK_HIGH_WATERMARK"),
+		ImageCleanupDiskLowWatermark:        parseImageCleanupDiskWatermark("ECS_IMAGE_CLEANUP_DISK_LOW_WATERMARK"),
+		DockerDataRoot:                      os.Getenv("ECS_DOCKER_DATA_ROOT"),
+		ImageAdmissionPolicyFile:            os.Getenv("ECS_IMAGE_ADMISSION_POLICY_FILE"),
 		ImageCleanupExclusionList:           parseImageCleanupExclusionList("ECS_EXCLUDE_UNTRACKED_IMAGE"),
+		ImagePrewarmList:                    imagePrewarmList,
 		InstanceAttributes:                  instanceAttributes,
 		CNIPluginsPath:                      os.Getenv("ECS_CNI_PLUGINS_PATH"),
 		AWSVPCBlockInstanceMetdata:          utils.ParseBool(os.Getenv("ECS_AWSVPC_BLOCK_IMDS"), false),
@@ -550,6 +686,10 @@ func environmentConfig() (Config, error) {
 		ContainerInstanceTags:               containerInstanceTags,
 		ContainerInstancePropagateTagsFrom:  parseContainerInstancePropagateTagsFrom(),
 		PollMetrics:                         utils.ParseBool(os.Getenv("ECS_POLL_METRICS"), true),
+		StatsDistributionsEnabled:           utils.ParseBool(os.Getenv("ECS_ENABLE_STATS_DISTRIBUTIONS"), false),
+		StatsSource:                         os.Getenv("ECS_STATS_SOURCE"),
+		ContainerDiskUsageInterval:          parseEnvVariableDuration("ECS_CONTAINER_DISK_USAGE_INTERVAL"),
+		TaskDiskUsageWarningThresholds:      parseTaskDiskUsageWarningThresholds(),
 		PollingMetricsWaitDuration:          parseEnvVariableDuration("ECS_POLLING_METRICS_WAIT_DURATION"),
 		DisableDockerHealthCheck:            utils.ParseBool(os.Getenv("ECS_DISABLE_DOCKER_HEALTH_CHECK"), false),
 		GPUSupportEnabled:                   utils.ParseBool(os.Getenv("ECS_ENABLE_GPU_SUPPORT"), false),
@@ -559,6 +699,9 @@ func environmentConfig() (Config, error) {
 		SpotInstanceDrainingEnabled:         utils.ParseBool(os.Getenv("ECS_ENABLE_SPOT_INSTANCE_DRAINING"), false),
 		GMSACapable:                         parseGMSACapability(),
 		VolumePluginCapabilities:            parseVolumePluginCapabilities(),
+		StandaloneTaskDir:                   os.Getenv("ECS_STANDALONE_TASK_DIR"),
+		StandaloneTaskSocket:                os.Getenv("ECS_STANDALONE_TASK_SOCKET"),
+		StandaloneStateChangeLog:            os.Getenv("ECS_STANDALONE_STATE_CHANGE_LOG"),
 	}, err
 }
 
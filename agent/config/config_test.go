@@ -29,6 +29,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMerge(t *testing.T) {
@@ -99,6 +100,16 @@ func TestEnvironmentConfig(t *testing.T) {
 	defer setTestEnv("ECS_APPARMOR_CAPABLE", "true")()
 	defer setTestEnv("ECS_DISABLE_PRIVILEGED", "true")()
 	defer setTestEnv("ECS_ENGINE_TASK_CLEANUP_WAIT_DURATION", "90s")()
+	defer setTestEnv("ECS_ENABLE_CONTAINER_ARCHIVE", "true")()
+	defer setTestEnv("ECS_CONTAINER_ARCHIVE_LOG_SIZE_MB", "5")()
+	defer setTestEnv("ECS_CONTAINER_ARCHIVE_MAX_SIZE_MB", "200")()
+	defer setTestEnv("ECS_STATE_STORE", "boltdb")()
+	defer setTestEnv("ECS_CONTAINER_DISK_USAGE_INTERVAL", "10m")()
+	defer setTestEnv("ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS", `{"web":2048}`)()
+	defer setTestEnv("ECS_STATE_ENCRYPTION_KEY", "c3RhdGUtZW5jcnlwdGlvbi1rZXktb2YtMzItYnl0ZXM=")()
+	defer setTestEnv("ECS_STATE_ENCRYPTION_KEY_FILE", "/etc/ecs/state.key")()
+	defer setTestEnv("ECS_STATE_BACKUP_COUNT", "5")()
+	defer setTestEnv("ECS_PROMETHEUS_CONTAINER_METRICS_LIMIT", "100")()
 	defer setTestEnv("ECS_ENABLE_TASK_IAM_ROLE", "true")()
 	defer setTestEnv("ECS_ENABLE_UNTRACKED_IMAGE_CLEANUP", "true")()
 	defer setTestEnv("ECS_ENABLE_TASK_IAM_ROLE_NETWORK_HOST", "true")()
@@ -108,6 +119,11 @@ func TestEnvironmentConfig(t *testing.T) {
 	defer setTestEnv("NON_ECS_IMAGE_MINIMUM_CLEANUP_AGE", "30m")()
 	defer setTestEnv("ECS_NUM_IMAGES_DELETE_PER_CYCLE", "2")()
 	defer setTestEnv("ECS_IMAGE_PULL_BEHAVIOR", "always")()
+	defer setTestEnv("ECS_IMAGE_PULL_CONCURRENCY_PER_REGISTRY", "3")()
+	defer setTestEnv("ECS_IMAGE_ADMISSION_POLICY_FILE", "/etc/ecs/image-admission-policy.json")()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_HIGH_WATERMARK", "85%")()
+	defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_LOW_WATERMARK", "70")()
+	defer setTestEnv("ECS_DOCKER_DATA_ROOT", "/host/var/lib/docker")()
 	defer setTestEnv("ECS_INSTANCE_ATTRIBUTES", "{\"my_attribute\": \"testing\"}")()
 	defer setTestEnv("ECS_CONTAINER_INSTANCE_TAGS", `{"my_tag": "testing"}`)()
 	defer setTestEnv("ECS_ENABLE_TASK_ENI", "true")()
@@ -117,6 +133,7 @@ func TestEnvironmentConfig(t *testing.T) {
 	defer setTestEnv("ECS_DISABLE_TASK_METADATA_AZ", "true")()
 	defer setTestEnv("ECS_NVIDIA_RUNTIME", "nvidia")()
 	defer setTestEnv("ECS_POLL_METRICS", "true")()
+	defer setTestEnv("ECS_ENABLE_STATS_DISTRIBUTIONS", "true")()
 	defer setTestEnv("ECS_POLLING_METRICS_WAIT_DURATION", "10s")()
 	defer setTestEnv("ECS_CGROUP_CPU_PERIOD", "")
 	additionalLocalRoutesJSON := `["1.2.3.4/22","5.6.7.8/32"]`
@@ -146,6 +163,7 @@ func TestEnvironmentConfig(t *testing.T) {
 	assert.True(t, conf.TaskIAMRoleEnabledForNetworkHost, "Wrong value for TaskIAMRoleEnabledForNetworkHost")
 	assert.True(t, conf.ImageCleanupDisabled, "Wrong value for ImageCleanupDisabled")
 	assert.True(t, conf.PollMetrics, "Wrong value for PollMetrics")
+	assert.True(t, conf.StatsDistributionsEnabled, "Wrong value for StatsDistributionsEnabled")
 	expectedDurationPollingMetricsWaitDuration, _ := time.ParseDuration("10s")
 	assert.Equal(t, expectedDurationPollingMetricsWaitDuration, conf.PollingMetricsWaitDuration)
 	assert.True(t, conf.TaskENIEnabled, "Wrong value for TaskNetwork")
@@ -154,9 +172,25 @@ func TestEnvironmentConfig(t *testing.T) {
 	assert.Equal(t, (2 * time.Hour), conf.ImageCleanupInterval)
 	assert.Equal(t, 2, conf.NumImagesToDeletePerCycle)
 	assert.Equal(t, ImagePullAlwaysBehavior, conf.ImagePullBehavior)
+	assert.Equal(t, 3, conf.ImagePullConcurrencyPerRegistry)
+	assert.Equal(t, "/etc/ecs/image-admission-policy.json", conf.ImageAdmissionPolicyFile)
+	assert.Equal(t, 85, conf.ImageCleanupDiskHighWatermark)
+	assert.Equal(t, 70, conf.ImageCleanupDiskLowWatermark)
+	assert.Equal(t, "/host/var/lib/docker", conf.DockerDataRoot)
 	assert.Equal(t, "testing", conf.InstanceAttributes["my_attribute"])
 	assert.Equal(t, "testing", conf.ContainerInstanceTags["my_tag"])
 	assert.Equal(t, (90 * time.Second), conf.TaskCleanupWaitDuration)
+	assert.True(t, conf.ContainerArchiveEnabled, "Wrong value for ContainerArchiveEnabled")
+	assert.Equal(t, 5, conf.ContainerArchiveLogSizeMB)
+	assert.Equal(t, 200, conf.ContainerArchiveMaxSizeMB)
+	assert.Equal(t, StateStoreBoltDB, conf.StateStore)
+	require.NotNil(t, conf.StateEncryptionKey)
+	assert.Equal(t, "c3RhdGUtZW5jcnlwdGlvbi1rZXktb2YtMzItYnl0ZXM=", string(conf.StateEncryptionKey.Contents()))
+	assert.Equal(t, "/etc/ecs/state.key", conf.StateEncryptionKeyFile)
+	assert.Equal(t, 5, conf.StateBackupCount)
+	assert.Equal(t, 100, conf.PrometheusContainerMetricsLimit)
+	assert.Equal(t, 10*time.Minute, conf.ContainerDiskUsageInterval)
+	assert.Equal(t, map[string]int64{"web": 2048}, conf.TaskDiskUsageWarningThresholds)
 	serializedAdditionalLocalRoutesJSON, err := json.Marshal(conf.AWSVPCAdditionalLocalRoutes)
 	assert.NoError(t, err, "should marshal additional local routes")
 	assert.Equal(t, additionalLocalRoutesJSON, string(serializedAdditionalLocalRoutesJSON))
@@ -407,6 +441,30 @@ func TestValidForImagesCleanupExclusion(t *testing.T) {
 	assert.Equal(t, expectedImages, imagesNotDelete, "unexpected imageCleanupExclusionList")
 }
 
+func TestImagePrewarmList(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_PREWARM_LIST", `[
+		{"Image": "amazonlinux:2"},
+		{"Image": "busybox:latest", "PullPolicy": "if-not-present", "RefreshInterval": "30m"},
+		{"Image": "nginx:latest", "PullPolicy": "sometimes", "RefreshInterval": 1000000000},
+		{"PullPolicy": "always"}
+	]`)()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	require.NoError(t, err)
+	assert.Equal(t, []ImagePrewarm{
+		{Image: "amazonlinux:2", PullPolicy: ImagePrewarmPullAlways},
+		{Image: "busybox:latest", PullPolicy: ImagePrewarmPullIfNotPresent, RefreshInterval: 30 * time.Minute},
+		{Image: "nginx:latest", PullPolicy: ImagePrewarmPullAlways, RefreshInterval: minimumImagePrewarmRefreshInterval},
+	}, cfg.ImagePrewarmList)
+}
+
+func TestInvalidImagePrewarmList(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_PREWARM_LIST", `[{"Image": "busybox:latest", "RefreshInterval": "soon"}]`)()
+	_, err := environmentConfig()
+	assert.Error(t, err)
+}
+
 func TestValidFormatParseEnvVariableDuration(t *testing.T) {
 	defer setTestRegion()()
 	setTestEnv("FOO", "1s")
@@ -508,6 +566,105 @@ func TestImageCleanupMinimumNumImagesToDeletePerCycle(t *testing.T) {
 	assert.Equal(t, cfg.NumImagesToDeletePerCycle, DefaultNumImagesToDeletePerCycle, "Wrong value for NumImagesToDeletePerCycle")
 }
 
+func TestInvalidImagePullConcurrencyPerRegistry(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_IMAGE_PULL_CONCURRENCY_PER_REGISTRY", "-1")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.ImagePullConcurrencyPerRegistry, "Wrong value for ImagePullConcurrencyPerRegistry")
+}
+
+func TestInvalidContainerArchiveSizes(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_CONTAINER_ARCHIVE_LOG_SIZE_MB", "-1")()
+	defer setTestEnv("ECS_CONTAINER_ARCHIVE_MAX_SIZE_MB", "-10")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultContainerArchiveLogSizeMB, cfg.ContainerArchiveLogSizeMB)
+	assert.Equal(t, DefaultContainerArchiveMaxSizeMB, cfg.ContainerArchiveMaxSizeMB)
+}
+
+func TestInvalidStateStore(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STATE_STORE", "sqlite")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, StateStoreJSON, cfg.StateStore)
+}
+
+func TestInvalidStatsSource(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STATS_SOURCE", "procfs")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, StatsSourceDocker, cfg.StatsSource)
+}
+
+func TestContainerDiskUsageIntervalBelowMinimum(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_CONTAINER_DISK_USAGE_INTERVAL", "5s")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, minimumContainerDiskUsageInterval, cfg.ContainerDiskUsageInterval)
+}
+
+func TestInvalidTaskDiskUsageWarningThresholds(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS", `{"web":-1,"db":1024}`)()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"db": 1024}, cfg.TaskDiskUsageWarningThresholds)
+
+	defer setTestEnv("ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS", "web=1024")()
+	cfg, err = NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err, "Invalid thresholds should not fail the configuration")
+	assert.Nil(t, cfg.TaskDiskUsageWarningThresholds)
+}
+
+func TestInvalidStateBackupCount(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STATE_BACKUP_COUNT", "-1")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultStateBackupCount, cfg.StateBackupCount)
+}
+
+func TestInvalidPrometheusContainerMetricsLimit(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_PROMETHEUS_CONTAINER_METRICS_LIMIT", "0")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPrometheusContainerMetricsLimit, cfg.PrometheusContainerMetricsLimit)
+}
+
+func TestImageCleanupDiskWatermarks(t *testing.T) {
+	testCases := []struct {
+		name          string
+		highWatermark string
+		lowWatermark  string
+		expectedHigh  int
+		expectedLow   int
+	}{
+		{"not set", "", "", 0, 0},
+		{"low watermark not set", "80", "", 80, 70},
+		{"low watermark above high watermark", "80", "90", 80, 70},
+		{"high watermark too low for the gap", "5", "", 5, 0},
+		{"high watermark out of range", "150", "70", 0, 0},
+		{"both set", "90", "50", 90, 50},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setTestRegion()()
+			defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_HIGH_WATERMARK", tc.highWatermark)()
+			defer setTestEnv("ECS_IMAGE_CLEANUP_DISK_LOW_WATERMARK", tc.lowWatermark)()
+			cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedHigh, cfg.ImageCleanupDiskHighWatermark)
+			assert.Equal(t, tc.expectedLow, cfg.ImageCleanupDiskLowWatermark)
+		})
+	}
+}
+
 func TestInvalidImagePullBehavior(t *testing.T) {
 	defer setTestRegion()()
 	defer setTestEnv("ECS_IMAGE_PULL_BEHAVIOR", "invalid")()
@@ -773,6 +930,25 @@ func TestTaskMetadataAZDisabled(t *testing.T) {
 	assert.True(t, cfg.TaskMetadataAZDisabled, "Wrong value for TaskMetadataAZDisabled")
 }
 
+func TestStandaloneEnabled(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STANDALONE_TASK_DIR", "/etc/ecs/tasks")()
+	defer setTestEnv("ECS_STANDALONE_STATE_CHANGE_LOG", "/var/log/ecs/state-changes.log")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.True(t, cfg.StandaloneEnabled(), "Wrong value for StandaloneEnabled")
+	assert.Equal(t, "/etc/ecs/tasks", cfg.StandaloneTaskDir)
+	assert.Empty(t, cfg.StandaloneTaskSocket)
+	assert.Equal(t, "/var/log/ecs/state-changes.log", cfg.StandaloneStateChangeLog)
+}
+
+func TestStandaloneDisabledByDefault(t *testing.T) {
+	defer setTestRegion()()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.False(t, cfg.StandaloneEnabled(), "Wrong value for StandaloneEnabled")
+}
+
 func setTestRegion() func() {
 	return setTestEnv("AWS_DEFAULT_REGION", "us-west-2")
 }
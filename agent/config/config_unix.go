@@ -53,7 +53,12 @@ func DefaultConfig() Config {
 		DisableMetrics:                      false,
 		ReservedMemory:                      0,
 		AvailableLoggingDrivers:             []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver},
+		StateStore:                          StateStoreJSON,
+		StateBackupCount:                    DefaultStateBackupCount,
+		PrometheusContainerMetricsLimit:     DefaultPrometheusContainerMetricsLimit,
 		TaskCleanupWaitDuration:             DefaultTaskCleanupWaitDuration,
+		ContainerArchiveLogSizeMB:           DefaultContainerArchiveLogSizeMB,
+		ContainerArchiveMaxSizeMB:           DefaultContainerArchiveMaxSizeMB,
 		DockerStopTimeout:                   defaultDockerStopTimeout,
 		ContainerStartTimeout:               defaultContainerStartTimeout,
 		CredentialsAuditLogFile:             defaultCredentialsAuditLogFile,
@@ -80,6 +85,8 @@ func DefaultConfig() Config {
 		PrometheusMetricsEnabled:            false,
 		PollMetrics:                         false,
 		PollingMetricsWaitDuration:          DefaultPollingMetricsWaitDuration,
+		StatsDistributionsEnabled:           false,
+		StatsSource:                         StatsSourceDocker,
 		NvidiaRuntime:                       DefaultNvidiaRuntime,
 		CgroupCPUPeriod:                     defaultCgroupCPUPeriod,
 		GMSACapable:                         false,
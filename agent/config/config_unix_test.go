@@ -47,6 +47,16 @@ func TestConfigDefault(t *testing.T) {
 	assert.Equal(t, []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver},
 		cfg.AvailableLoggingDrivers, "Default logging drivers set incorrectly")
 	assert.Equal(t, 3*time.Hour, cfg.TaskCleanupWaitDuration, "Default task cleanup wait duration set incorrectly")
+	assert.False(t, cfg.ContainerArchiveEnabled, "Default ContainerArchiveEnabled set incorrectly")
+	assert.Equal(t, DefaultContainerArchiveLogSizeMB, cfg.ContainerArchiveLogSizeMB, "Default container archive log size set incorrectly")
+	assert.Equal(t, DefaultContainerArchiveMaxSizeMB, cfg.ContainerArchiveMaxSizeMB, "Default container archive size set incorrectly")
+	assert.Equal(t, StateStoreJSON, cfg.StateStore, "Default StateStore set incorrectly")
+	assert.Equal(t, DefaultStateBackupCount, cfg.StateBackupCount, "Default StateBackupCount set incorrectly")
+	assert.Equal(t, DefaultPrometheusContainerMetricsLimit, cfg.PrometheusContainerMetricsLimit, "Default PrometheusContainerMetricsLimit set incorrectly")
+	assert.False(t, cfg.StatsDistributionsEnabled, "Default StatsDistributionsEnabled set incorrectly")
+	assert.Equal(t, StatsSourceDocker, cfg.StatsSource, "Default StatsSource set incorrectly")
+	assert.Zero(t, cfg.ContainerDiskUsageInterval, "Default ContainerDiskUsageInterval set incorrectly")
+	assert.Empty(t, cfg.TaskDiskUsageWarningThresholds, "Default TaskDiskUsageWarningThresholds set incorrectly")
 	assert.False(t, cfg.TaskENIEnabled, "TaskENIEnabled set incorrectly")
 	assert.False(t, cfg.TaskIAMRoleEnabled, "TaskIAMRoleEnabled set incorrectly")
 	assert.False(t, cfg.TaskIAMRoleEnabledForNetworkHost, "TaskIAMRoleEnabledForNetworkHost set incorrectly")
@@ -193,6 +203,14 @@ func TestPrometheusMetricsPlatformOverrides(t *testing.T) {
 	assert.Equal(t, 6, len(cfg.ReservedPorts), "Reserved ports should have added Prometheus endpoint")
 }
 
+func TestCgroupStatsSource(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STATS_SOURCE", "cgroup")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	require.NoError(t, err)
+	assert.Equal(t, StatsSourceCgroup, cfg.StatsSource)
+}
+
 // TestENITrunkingEnabled tests that when task networking is enabled, eni trunking is enabled by default
 func TestENITrunkingEnabled(t *testing.T) {
 	defer setTestRegion()()
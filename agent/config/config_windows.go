@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/cihub/seelog"
 )
 
 const (
@@ -83,7 +84,12 @@ func DefaultConfig() Config {
 		DataDirOnHost:                       dataDir,
 		ReservedMemory:                      0,
 		AvailableLoggingDrivers:             []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver, dockerclient.AWSLogsDriver},
+		StateStore:                          StateStoreJSON,
+		StateBackupCount:                    DefaultStateBackupCount,
+		PrometheusContainerMetricsLimit:     DefaultPrometheusContainerMetricsLimit,
 		TaskCleanupWaitDuration:             DefaultTaskCleanupWaitDuration,
+		ContainerArchiveLogSizeMB:           DefaultContainerArchiveLogSizeMB,
+		ContainerArchiveMaxSizeMB:           DefaultContainerArchiveMaxSizeMB,
 		DockerStopTimeout:                   defaultDockerStopTimeout,
 		ContainerStartTimeout:               defaultContainerStartTimeout,
 		ImagePullInactivityTimeout:          defaultImagePullInactivityTimeout,
@@ -103,6 +109,8 @@ func DefaultConfig() Config {
 		SharedVolumeMatchFullConfig:         false, //only requiring shared volumes to match on name, which is default docker behavior
 		PollMetrics:                         false,
 		PollingMetricsWaitDuration:          DefaultPollingMetricsWaitDuration,
+		StatsDistributionsEnabled:           false,
+		StatsSource:                         StatsSourceDocker,
 		GMSACapable:                         true,
 	}
 }
@@ -120,6 +128,12 @@ func (cfg *Config) platformOverrides() {
 	// ensure TaskResourceLimit is disabled
 	cfg.TaskCPUMemLimit = ExplicitlyDisabled
 
+	// there are no cgroups to read container stats from
+	if cfg.StatsSource == StatsSourceCgroup {
+		seelog.Warnf("ECS_STATS_SOURCE %s is not supported on Windows, will be overridden with: %s.", StatsSourceCgroup, StatsSourceDocker)
+		cfg.StatsSource = StatsSourceDocker
+	}
+
 	cpuUnbounded := utils.ParseBool(os.Getenv("ECS_ENABLE_CPU_UNBOUNDED_WINDOWS_WORKAROUND"), false)
 	memoryUnbounded := utils.ParseBool(os.Getenv("ECS_ENABLE_MEMORY_UNBOUNDED_WINDOWS_WORKAROUND"), false)
 
@@ -44,6 +44,16 @@ func TestConfigDefault(t *testing.T) {
 	assert.Equal(t, []dockerclient.LoggingDriver{dockerclient.JSONFileDriver, dockerclient.NoneDriver, dockerclient.AWSLogsDriver},
 		cfg.AvailableLoggingDrivers, "Default logging drivers set incorrectly")
 	assert.Equal(t, 3*time.Hour, cfg.TaskCleanupWaitDuration, "Default task cleanup wait duration set incorrectly")
+	assert.False(t, cfg.ContainerArchiveEnabled, "Default ContainerArchiveEnabled set incorrectly")
+	assert.Equal(t, DefaultContainerArchiveLogSizeMB, cfg.ContainerArchiveLogSizeMB, "Default container archive log size set incorrectly")
+	assert.Equal(t, DefaultContainerArchiveMaxSizeMB, cfg.ContainerArchiveMaxSizeMB, "Default container archive size set incorrectly")
+	assert.Equal(t, StateStoreJSON, cfg.StateStore, "Default StateStore set incorrectly")
+	assert.Equal(t, DefaultStateBackupCount, cfg.StateBackupCount, "Default StateBackupCount set incorrectly")
+	assert.Equal(t, DefaultPrometheusContainerMetricsLimit, cfg.PrometheusContainerMetricsLimit, "Default PrometheusContainerMetricsLimit set incorrectly")
+	assert.False(t, cfg.StatsDistributionsEnabled, "Default StatsDistributionsEnabled set incorrectly")
+	assert.Equal(t, StatsSourceDocker, cfg.StatsSource, "Default StatsSource set incorrectly")
+	assert.Zero(t, cfg.ContainerDiskUsageInterval, "Default ContainerDiskUsageInterval set incorrectly")
+	assert.Empty(t, cfg.TaskDiskUsageWarningThresholds, "Default TaskDiskUsageWarningThresholds set incorrectly")
 	assert.False(t, cfg.TaskIAMRoleEnabled, "TaskIAMRoleEnabled set incorrectly")
 	assert.False(t, cfg.TaskIAMRoleEnabledForNetworkHost, "TaskIAMRoleEnabledForNetworkHost set incorrectly")
 	assert.False(t, cfg.CredentialsAuditLogDisabled, "CredentialsAuditLogDisabled set incorrectly")
@@ -98,6 +108,14 @@ func TestTaskResourceLimitPlatformOverrideDisabled(t *testing.T) {
 	assert.False(t, cfg.TaskCPUMemLimit.Enabled())
 }
 
+func TestCgroupStatsSourcePlatformOverride(t *testing.T) {
+	defer setTestRegion()()
+	defer setTestEnv("ECS_STATS_SOURCE", "cgroup")()
+	cfg, err := NewConfig(ec2.NewBlackholeEC2MetadataClient())
+	assert.NoError(t, err)
+	assert.Equal(t, StatsSourceDocker, cfg.StatsSource)
+}
+
 func TestCPUUnboundedSet(t *testing.T) {
 	defer setTestRegion()()
 	defer setTestEnv("ECS_ENABLE_CPU_UNBOUNDED_WINDOWS_WORKAROUND", "true")()
@@ -0,0 +1,116 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ImagePrewarmPullAlways indicates that a pre-warmed image is pulled at startup
+	// and on every refresh, even if it's already present on the instance
+	ImagePrewarmPullAlways ImagePrewarmPullPolicy = "always"
+	// ImagePrewarmPullIfNotPresent indicates that a pre-warmed image is only pulled
+	// if it's not present on the instance
+	ImagePrewarmPullIfNotPresent ImagePrewarmPullPolicy = "if-not-present"
+
+	// minimumImagePrewarmRefreshInterval is the minimum interval at which a
+	// pre-warmed image can be refreshed
+	minimumImagePrewarmRefreshInterval = 1 * time.Minute
+)
+
+// ImagePrewarmPullPolicy specifies when the agent pulls a pre-warmed image
+type ImagePrewarmPullPolicy string
+
+// ImagePrewarm describes an image that the agent pulls ahead of the tasks that
+// use it and keeps on the instance
+type ImagePrewarm struct {
+	// Image is the name of the image, e.g. "busybox:latest"
+	Image string
+	// PullPolicy specifies when the image is pulled. It defaults to "always"
+	PullPolicy ImagePrewarmPullPolicy
+	// RefreshInterval is the interval at which the image is pulled again in the
+	// background. The image is only pulled at startup if it's not set
+	RefreshInterval time.Duration
+}
+
+// UnmarshalJSON is used to deserialize json types into ImagePrewarm, per the
+// Unmarshaller interface. RefreshInterval can either be a duration string such
+// as "30m" or a number of nanoseconds
+func (prewarm *ImagePrewarm) UnmarshalJSON(jsonData []byte) error {
+	var raw struct {
+		Image           string
+		PullPolicy      ImagePrewarmPullPolicy
+		RefreshInterval json.RawMessage
+	}
+	if err := json.Unmarshal(jsonData, &raw); err != nil {
+		return err
+	}
+
+	prewarm.Image = raw.Image
+	prewarm.PullPolicy = raw.PullPolicy
+	prewarm.RefreshInterval = 0
+	if len(raw.RefreshInterval) == 0 || string(raw.RefreshInterval) == "null" {
+		return nil
+	}
+
+	var intervalString string
+	if err := json.Unmarshal(raw.RefreshInterval, &intervalString); err == nil {
+		interval, err := time.ParseDuration(intervalString)
+		if err != nil {
+			return errors.Wrapf(err, "image prewarm: invalid refresh interval for image %s", raw.Image)
+		}
+		prewarm.RefreshInterval = interval
+		return nil
+	}
+	var intervalNanos int64
+	if err := json.Unmarshal(raw.RefreshInterval, &intervalNanos); err != nil {
+		return errors.Errorf("image prewarm: invalid refresh interval for image %s: %s",
+			raw.Image, string(raw.RefreshInterval))
+	}
+	prewarm.RefreshInterval = time.Duration(intervalNanos)
+	return nil
+}
+
+// imagePrewarmOverrides drops the pre-warmed images that don't have a name and
+// overrides invalid pull policies and refresh intervals
+func (cfg *Config) imagePrewarmOverrides() {
+	var prewarmList []ImagePrewarm
+	for _, prewarm := range cfg.ImagePrewarmList {
+		if prewarm.Image == "" {
+			seelog.Warn("Ignoring pre-warmed image without a name")
+			continue
+		}
+		switch prewarm.PullPolicy {
+		case ImagePrewarmPullAlways, ImagePrewarmPullIfNotPresent:
+		case "":
+			prewarm.PullPolicy = ImagePrewarmPullAlways
+		default:
+			seelog.Warnf("Invalid pull policy for pre-warmed image %s, will be overridden with the default value: %s. Parsed value: %s.",
+				prewarm.Image, ImagePrewarmPullAlways, prewarm.PullPolicy)
+			prewarm.PullPolicy = ImagePrewarmPullAlways
+		}
+		if prewarm.RefreshInterval != 0 && prewarm.RefreshInterval < minimumImagePrewarmRefreshInterval {
+			seelog.Warnf("Invalid refresh interval for pre-warmed image %s, will be overridden with the minimum value: %v. Parsed value: %v.",
+				prewarm.Image, minimumImagePrewarmRefreshInterval, prewarm.RefreshInterval)
+			prewarm.RefreshInterval = minimumImagePrewarmRefreshInterval
+		}
+		prewarmList = append(prewarmList, prewarm)
+	}
+	cfg.ImagePrewarmList = prewarmList
+}
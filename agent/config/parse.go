@@ -144,6 +144,71 @@ func parseNumImagesToDeletePerCycle() int {
 	return numImagesToDeletePerCycle
 }
 
+func parseImagePullConcurrencyPerRegistry() int {
+	imagePullConcurrencyEnvVal := os.Getenv("ECS_IMAGE_PULL_CONCURRENCY_PER_REGISTRY")
+	imagePullConcurrency, err := strconv.Atoi(imagePullConcurrencyEnvVal)
+	if imagePullConcurrencyEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_IMAGE_PULL_CONCURRENCY_PER_REGISTRY\", expected an integer. err %v", err)
+	}
+
+	return imagePullConcurrency
+}
+
+func parseImageCleanupDiskWatermark(envVar string) int {
+	watermarkEnvVal := os.Getenv(envVar)
+	watermark, err := strconv.Atoi(strings.TrimSuffix(watermarkEnvVal, "%"))
+	if watermarkEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"%s\", expected a percentage. err %v", envVar, err)
+	}
+	return watermark
+}
+
+func parseContainerArchiveSizeMB(envVar string) int {
+	sizeEnvVal := os.Getenv(envVar)
+	size, err := strconv.Atoi(sizeEnvVal)
+	if sizeEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"%s\", expected an integer. err %v", envVar, err)
+	}
+	return size
+}
+
+func parseStateBackupCount() int {
+	backupCountEnvVal := os.Getenv("ECS_STATE_BACKUP_COUNT")
+	backupCount, err := strconv.Atoi(backupCountEnvVal)
+	if backupCountEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_STATE_BACKUP_COUNT\", expected an integer. err %v", err)
+	}
+	return backupCount
+}
+
+func parsePrometheusContainerMetricsLimit() int {
+	limitEnvVal := os.Getenv("ECS_PROMETHEUS_CONTAINER_METRICS_LIMIT")
+	limit, err := strconv.Atoi(limitEnvVal)
+	if limitEnvVal != "" && err != nil {
+		seelog.Warnf("Invalid format for \"ECS_PROMETHEUS_CONTAINER_METRICS_LIMIT\", expected an integer. err %v", err)
+	}
+	return limit
+}
+
+func parseTaskDiskUsageWarningThresholds() map[string]int64 {
+	thresholdsEnvVal := os.Getenv("ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS")
+	if thresholdsEnvVal == "" {
+		return nil
+	}
+	var thresholds map[string]int64
+	if err := json.Unmarshal([]byte(thresholdsEnvVal), &thresholds); err != nil {
+		seelog.Warnf("Invalid format for \"ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS\", expected a json hash of task families to sizes in MiB. err %v", err)
+		return nil
+	}
+	for family, threshold := range thresholds {
+		if threshold <= 0 {
+			seelog.Warnf("Invalid disk usage warning threshold for task family %s in \"ECS_TASK_DISK_USAGE_WARNING_THRESHOLDS\", ignoring it. Parsed value: %d.", family, threshold)
+			delete(thresholds, family)
+		}
+	}
+	return thresholds
+}
+
 func parseNumNonECSContainersToDeletePerCycle() int {
 	numNonEcsContainersToDeletePerCycleEnvVal := os.Getenv("NONECS_NUM_CONTAINERS_DELETE_PER_CYCLE")
 	numNonEcsContainersToDeletePerCycle, err := strconv.Atoi(numNonEcsContainersToDeletePerCycleEnvVal)
@@ -201,6 +266,20 @@ func parseAdditionalLocalRoutes(errs []error) ([]cnitypes.IPNet, []error) {
 	return additionalLocalRoutes, errs
 }
 
+func parseImagePrewarmList(errs []error) ([]ImagePrewarm, []error) {
+	var imagePrewarmList []ImagePrewarm
+	imagePrewarmListEnv := os.Getenv("ECS_IMAGE_PREWARM_LIST")
+	if imagePrewarmListEnv != "" {
+		err := json.Unmarshal([]byte(imagePrewarmListEnv), &imagePrewarmList)
+		if err != nil {
+			seelog.Errorf("Invalid format for ECS_IMAGE_PREWARM_LIST, expected a json array of images: %v", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return imagePrewarmList, errs
+}
+
 func parseTaskCPUMemLimitEnabled() Conditional {
 	var taskCPUMemLimitEnabled Conditional
 	taskCPUMemLimitConfigString := os.Getenv("ECS_ENABLE_TASK_CPU_MEM_LIMIT")
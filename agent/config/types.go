@@ -69,6 +69,22 @@ type Config struct {
 	// file, in DataDir, such that on instance or agent restarts it will resume
 	// as the same ContainerInstance. It defaults to false.
 	Checkpoint bool
+	// StateStore is how the checkpointed state is stored in DataDir. It's
+	// either "boltdb", for an embedded key-value store that saves each task,
+	// container, image state and ENI attachment on its own, or "json", for a
+	// single JSON file that's rewritten on every save. It defaults to "json".
+	StateStore string `trim:"true"`
+	// StateEncryptionKey is the base64 encoded 256-bit key to encrypt the
+	// checkpointed state with. State that isn't encrypted yet is still loaded,
+	// and is encrypted the next time it's saved.
+	StateEncryptionKey *SensitiveRawMessage
+	// StateEncryptionKeyFile is the path to a file that holds the key to
+	// encrypt the checkpointed state with, if StateEncryptionKey isn't set
+	StateEncryptionKeyFile string `trim:"true"`
+	// StateBackupCount is the number of the last good snapshots of the JSON
+	// state file that are kept, so that the state can be recovered if the file
+	// is corrupted. It defaults to 3.
+	StateBackupCount int
 
 	// EngineAuthType configures what type of data is in EngineAuthData.
 	// Supported types, right now, can be found in the dockerauth package: https://godoc.org/github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerauth
@@ -97,6 +113,26 @@ type Config struct {
 	// again when PollMetrics is set to true
 	PollingMetricsWaitDuration time.Duration
 
+	// StatsDistributionsEnabled configures whether the percentiles and
+	// histograms of the CPU and memory usage of each container are kept over
+	// its window of stats
+	StatsDistributionsEnabled bool
+
+	// StatsSource is where the stats of containers are collected from. It's
+	// either "docker", for a docker stats stream per container, or "cgroup",
+	// to read the cgroup files of all the containers on a single ticker. It
+	// defaults to "docker", and is always "docker" on Windows.
+	StatsSource string `trim:"true"`
+
+	// ContainerDiskUsageInterval is how often the size of the writable layers
+	// of the containers and of their task-scoped volumes is sampled. Sampling
+	// is disabled when it's 0, which is the default.
+	ContainerDiskUsageInterval time.Duration
+
+	// TaskDiskUsageWarningThresholds are the disk usages, in MiB and by task
+	// family, above which a warning is logged and reported for a task
+	TaskDiskUsageWarningThresholds map[string]int64
+
 	// DisableDockerHealthCheck configures whether container health feature was enabled
 	// on the instance
 	DisableDockerHealthCheck bool
@@ -135,6 +171,19 @@ type Config struct {
 	// until cleanup of task resources is started.
 	TaskCleanupWaitDuration time.Duration
 
+	// ContainerArchiveEnabled specifies whether the end of the json-file log,
+	// the inspect output and the exit metadata of each container of a task are
+	// saved under DataDir before the containers are removed at task cleanup
+	ContainerArchiveEnabled bool
+
+	// ContainerArchiveLogSizeMB is the size in MB of the end of the log of a
+	// container that's archived
+	ContainerArchiveLogSizeMB int
+
+	// ContainerArchiveMaxSizeMB is the size in MB the archived containers are
+	// kept under. The archives of the tasks cleaned up first are removed first
+	ContainerArchiveMaxSizeMB int
+
 	// TaskIAMRoleEnabled specifies if the Agent is capable of launching
 	// tasks with IAM Roles.
 	TaskIAMRoleEnabled bool
@@ -182,6 +231,21 @@ type Config struct {
 	// when Agent performs cleanup
 	NumImagesToDeletePerCycle int
 
+	// ImageCleanupDiskHighWatermark is the percentage of the space or inodes of
+	// the filesystem of the Docker data root in use above which the image cleanup
+	// runs right away. Disk usage isn't watched if it's 0
+	ImageCleanupDiskHighWatermark int
+
+	// ImageCleanupDiskLowWatermark is the percentage of the space and inodes of
+	// the filesystem of the Docker data root in use below which the image cleanup
+	// started by disk pressure stops removing images and containers
+	ImageCleanupDiskLowWatermark int
+
+	// DockerDataRoot is the path the Docker data root is visible at to the Agent,
+	// whose filesystem is watched for disk pressure. The data root reported by
+	// Docker is used if it's not set
+	DockerDataRoot string
+
 	// NumNonECSContainersToDeletePerCycle specifies the num of NonECS containers to delete every time
 	// when Agent performs cleanup
 	NumNonECSContainersToDeletePerCycle int
@@ -190,6 +254,16 @@ type Config struct {
 	// local Docker image cache
 	ImagePullBehavior ImagePullBehaviorType
 
+	// ImagePullConcurrencyPerRegistry is the maximum number of images that are
+	// pulled from the same registry at the same time. Pulls of the same image
+	// are always merged into one. There's no limit if it's 0
+	ImagePullConcurrencyPerRegistry int
+
+	// ImageAdmissionPolicyFile is the path of the image admission policy that
+	// the images of containers are checked against before the containers are
+	// created. No policy is enforced if it's not set
+	ImageAdmissionPolicyFile string
+
 	// InstanceAttributes contains key/value pairs representing
 	// attributes to be associated with this instance within the
 	// ECS service and used to influence behavior such as launch
@@ -220,6 +294,11 @@ type Config struct {
 	// default.
 	PrometheusMetricsEnabled bool
 
+	// PrometheusContainerMetricsLimit is the maximum number of containers whose
+	// resource usage is exported on the introspection server when Prometheus
+	// metrics are enabled.
+	PrometheusContainerMetricsLimit int
+
 	// AWSVPCBlockInstanceMetdata specifies if InstanceMetadata endpoint should be blocked
 	// for tasks that are launched with network mode "awsvpc" when ECS_AWSVPC_BLOCK_IMDS=true
 	AWSVPCBlockInstanceMetdata bool
@@ -285,6 +364,11 @@ type Config struct {
 	// ImageCleanupExclusionList is the list of image names customers want to keep for their own use and delete automatically
 	ImageCleanupExclusionList []string
 
+	// ImagePrewarmList is the list of images the agent pulls at startup, and
+	// optionally refreshes in the background, ahead of the tasks that use them.
+	// These images are never removed by the image cleanup
+	ImagePrewarmList []ImagePrewarm
+
 	// NvidiaRuntime is the runtime to be used for passing Nvidia GPU devices to containers
 	NvidiaRuntime string `trim:"true"`
 
@@ -312,4 +396,18 @@ type Config struct {
 
 	// VolumePluginCapabilities specifies the capabilities of the ecs volume plugin.
 	VolumePluginCapabilities []string
+
+	// StandaloneTaskDir is the directory that is watched for task definitions when
+	// the Agent runs in standalone mode. Every '.json' file in the directory is
+	// expected to contain a single task in the ACS task format
+	StandaloneTaskDir string `trim:"true"`
+
+	// StandaloneTaskSocket is the path of the unix socket on which the Agent accepts
+	// tasks when it runs in standalone mode
+	StandaloneTaskSocket string `trim:"true"`
+
+	// StandaloneStateChangeLog is the path of the file that task and container
+	// state changes are appended to when the Agent runs in standalone mode. State
+	// changes are only logged if it's not set
+	StandaloneStateChangeLog string `trim:"true"`
 }
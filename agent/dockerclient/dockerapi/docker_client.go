@@ -184,6 +184,11 @@ type DockerClient interface {
 
 	// Info returns the information of the Docker server.
 	Info(context.Context, time.Duration) (types.Info, error)
+
+	// DiskUsage returns the disk used by the images, containers and volumes of
+	// the Docker server. It's expensive, as the daemon walks the writable
+	// layers of the containers and the volumes to size them.
+	DiskUsage(context.Context, time.Duration) (types.DiskUsage, error)
 }
 
 // DockerGoClient wraps the underlying go-dockerclient and docker/docker library.
@@ -220,6 +225,11 @@ type dockerGoClient struct {
 
 	daemonVersionUnsafe string
 	lock                sync.Mutex
+
+	// oomEvents are the containers an oom event was received for that haven't
+	// stopped yet
+	oomEvents     map[string]struct{}
+	oomEventsLock sync.Mutex
 }
 
 type ImagePullResponse struct {
@@ -400,6 +410,8 @@ func (dg *dockerGoClient) pullImage(ctx context.Context, image string,
 		decoder := json.NewDecoder(reader)
 		data := new(ImagePullResponse)
 		var statusDisplayed time.Time
+		reporter, reportProgress := PullProgressReporterFromContext(ctx)
+		progressTracker := newPullProgressTracker(time.Now)
 		for err := decoder.Decode(data); err != io.EOF; err = decoder.Decode(data) {
 			if err != nil {
 				seelog.Warnf("DockerGoClient: Unable to decode pull event message for image %s: %v", image, err)
@@ -422,6 +434,9 @@ func (dg *dockerGoClient) pullImage(ctx context.Context, image string,
 			})
 
 			statusDisplayed = dg.filterPullDebugOutput(data, image, statusDisplayed)
+			if reportProgress && progressTracker.update(data) {
+				reporter(progressTracker.progress)
+			}
 
 			data = new(ImagePullResponse)
 		}
@@ -806,6 +821,7 @@ func MetadataFromContainer(dockerContainer *types.ContainerJSON) DockerContainer
 	}
 	if dockerContainer.State.OOMKilled {
 		metadata.Error = OutOfMemoryError{}
+		metadata.OOMKilled = true
 	}
 	// Health field in Docker SDK is a pointer, need to check before not nil before dereference.
 	if dockerContainer.State.Health == nil || dockerContainer.State.Health.Status == "" || dockerContainer.State.Health.Status == healthCheckStarting {
@@ -941,6 +957,10 @@ func (dg *dockerGoClient) handleContainerEvents(ctx context.Context,
 			// "oom" can either means any process got OOM'd, but doesn't always
 			// mean the container dies (non-init processes). If the container also
 			// dies, you see a "die" status as well; we'll update suitably there
+			dg.recordOOMEvent(containerID)
+			continue
+		case "destroy":
+			dg.takeOOMEvent(containerID)
 			continue
 		case "health_status: healthy":
 			fallthrough
@@ -953,6 +973,9 @@ func (dg *dockerGoClient) handleContainerEvents(ctx context.Context,
 		}
 
 		metadata := dg.containerMetadata(ctx, containerID)
+		if status == apicontainerstatus.ContainerStopped && dg.takeOOMEvent(containerID) {
+			metadata.OOMKilled = true
+		}
 
 		changedContainers <- DockerContainerChangeEvent{
 			Status:                  status,
@@ -962,6 +985,28 @@ func (dg *dockerGoClient) handleContainerEvents(ctx context.Context,
 	}
 }
 
+// recordOOMEvent records that an oom event was received for the container
+func (dg *dockerGoClient) recordOOMEvent(containerID string) {
+	dg.oomEventsLock.Lock()
+	defer dg.oomEventsLock.Unlock()
+
+	if dg.oomEvents == nil {
+		dg.oomEvents = make(map[string]struct{})
+	}
+	dg.oomEvents[containerID] = struct{}{}
+}
+
+// takeOOMEvent returns true if an oom event was received for the container
+// since it last stopped, and forgets about the event
+func (dg *dockerGoClient) takeOOMEvent(containerID string) bool {
+	dg.oomEventsLock.Lock()
+	defer dg.oomEventsLock.Unlock()
+
+	_, ok := dg.oomEvents[containerID]
+	delete(dg.oomEvents, containerID)
+	return ok
+}
+
 // ListContainers returns a slice of container IDs.
 func (dg *dockerGoClient) ListContainers(ctx context.Context, all bool, timeout time.Duration) ListContainersResponse {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -1093,6 +1138,17 @@ func (dg *dockerGoClient) Info(ctx context.Context, timeout time.Duration) (type
 	return info, nil
 }
 
+func (dg *dockerGoClient) DiskUsage(ctx context.Context, timeout time.Duration) (types.DiskUsage, error) {
+	derivedCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := dg.sdkDockerClient()
+	if err != nil {
+		return types.DiskUsage{}, err
+	}
+	return client.DiskUsage(derivedCtx)
+}
+
 func (dg *dockerGoClient) getDaemonVersion() string {
 	dg.lock.Lock()
 	defer dg.lock.Unlock()
@@ -213,6 +213,37 @@ func TestImagePull(t *testing.T) {
 	assert.NoError(t, metadata.Error, "Expected pull to succeed")
 }
 
+func TestImagePullReportsProgress(t *testing.T) {
+	mockDockerSDK, client, testTime, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	testTime.EXPECT().After(gomock.Any()).AnyTimes()
+
+	mockDockerSDK.EXPECT().ImagePull(gomock.Any(), "image:latest", gomock.Any()).Return(
+		mockReadCloser{
+			reader: strings.NewReader(`{"status":"Pulling from library/image","id":"latest"}
+{"status":"Pulling fs layer","id":"layer1"}
+{"status":"Downloading","id":"layer1","progressDetail":{"current":10,"total":20}}
+{"status":"Pull complete","id":"layer1"}
+{"status":"Digest: sha256:abc"}`),
+		}, nil)
+
+	var reported []apicontainer.PullProgress
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ctx = WithPullProgressReporter(ctx, func(progress apicontainer.PullProgress) {
+		reported = append(reported, progress)
+	})
+	metadata := client.PullImage(ctx, "image", nil, dockerclient.PullImageTimeout)
+	assert.NoError(t, metadata.Error, "Expected pull to succeed")
+	require.Len(t, reported, 3)
+	assert.Equal(t, 1, reported[0].Layers)
+	assert.EqualValues(t, 10, reported[1].BytesDownloaded)
+	assert.EqualValues(t, 20, reported[1].BytesTotal)
+	assert.Equal(t, 1, reported[2].LayersDone)
+	assert.EqualValues(t, 20, reported[2].BytesDownloaded)
+}
+
 func TestImagePullTag(t *testing.T) {
 	mockDockerSDK, client, testTime, _, _, done := dockerClientSetup(t)
 	defer done()
@@ -733,6 +764,7 @@ func TestContainerEvents(t *testing.T) {
 		"delete",
 		"oom",
 		"kill",
+		"destroy",
 	}
 	for _, eventStatus := range ignore {
 		eventsChan <- events.Message{Type: "container", ID: "123", Status: eventStatus}
@@ -763,6 +795,49 @@ func TestContainerEvents(t *testing.T) {
 	}
 }
 
+func TestContainerEventsOOMKilled(t *testing.T) {
+	mockDockerSDK, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	eventsChan := make(chan events.Message, dockerEventBufferSize)
+	errChan := make(chan error)
+	mockDockerSDK.EXPECT().Events(gomock.Any(), gomock.Any()).Return(eventsChan, errChan)
+
+	dockerEvents, err := client.ContainerEvents(context.TODO())
+	require.NoError(t, err, "Could not get container events")
+
+	for _, id := range []string{"oomcontainer", "oomcontainer2"} {
+		stoppedContainer := types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID: id,
+				State: &types.ContainerState{
+					FinishedAt: (time.Now()).Format(time.RFC3339),
+					ExitCode:   137,
+				},
+			},
+		}
+		mockDockerSDK.EXPECT().ContainerInspect(gomock.Any(), id).Return(stoppedContainer, nil)
+	}
+
+	// The oom event of a non-init process is recorded for when the container dies
+	eventsChan <- events.Message{Type: "container", ID: "oomcontainer", Status: "oom"}
+	select {
+	case <-dockerEvents:
+		t.Error("No event should be available for oom")
+	case <-time.After(100 * time.Millisecond):
+	}
+	eventsChan <- events.Message{Type: "container", ID: "oomcontainer", Status: "die"}
+	event := <-dockerEvents
+	assert.Equal(t, "oomcontainer", event.DockerID)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, event.Status)
+	assert.True(t, event.OOMKilled, "oom event should be reported when the container dies")
+
+	eventsChan <- events.Message{Type: "container", ID: "oomcontainer2", Status: "die"}
+	event = <-dockerEvents
+	assert.Equal(t, "oomcontainer2", event.DockerID)
+	assert.False(t, event.OOMKilled, "oom event of another container should not be reported")
+}
+
 func TestContainerEventsError(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -848,6 +923,23 @@ func TestDockerInfoError(t *testing.T) {
 	assert.Equal(t, types.Info{}, info)
 }
 
+func TestDockerDiskUsage(t *testing.T) {
+	mockDockerSDK, client, _, _, _, done := dockerClientSetup(t)
+	defer done()
+
+	mockDockerSDK.EXPECT().DiskUsage(gomock.Any()).Return(types.DiskUsage{
+		Containers: []*types.Container{{ID: "id", SizeRw: 1024}},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	usage, err := client.DiskUsage(ctx, dockerclient.DiskUsageTimeout)
+
+	require.NoError(t, err)
+	require.Len(t, usage.Containers, 1)
+	assert.Equal(t, int64(1024), usage.Containers[0].SizeRw)
+}
+
 func TestDockerInfoClientError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1546,6 +1638,25 @@ func TestMetadataFromContainer(t *testing.T) {
 	assert.True(t, finishedTime.Equal(finishedTimeSDK))
 }
 
+func TestMetadataFromContainerOOMKilled(t *testing.T) {
+	dockerContainer := types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID: "1234",
+			State: &types.ContainerState{
+				OOMKilled:  true,
+				ExitCode:   137,
+				FinishedAt: time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	metadata := MetadataFromContainer(&dockerContainer)
+	assert.True(t, metadata.OOMKilled)
+	assert.Equal(t, OutOfMemoryError{}, metadata.Error)
+	require.NotNil(t, metadata.ExitCode)
+	assert.Equal(t, 137, *metadata.ExitCode)
+}
+
 func TestMetadataFromContainerHealthCheckWithNoLogs(t *testing.T) {
 
 	dockerContainer := &types.ContainerJSON{
@@ -32,6 +32,7 @@ var containerEvents = []string{
 	"die",
 	"restart",
 	"oom",
+	"destroy",
 	"health_status: unhealthy",
 	"health_status: healthy",
 }
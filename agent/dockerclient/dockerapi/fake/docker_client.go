@@ -0,0 +1,1042 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fake provides a stateful in-memory implementation of the Docker
+// client. It models containers, images, volumes, event streams and stats, and
+// lets tests inject latency, errors, daemon restarts and dropped events, so
+// that task engine scenarios can run without a Docker daemon
+package fake
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/go-connections/nat"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DaemonVersion is the version reported by the fake daemon
+	DaemonVersion = "19.03.6"
+	// DefaultStatsInterval is the interval between stats samples of a container,
+	// which matches the interval of the Docker daemon
+	DefaultStatsInterval = time.Second
+
+	// firstHostPort is the first port assigned to container ports that are
+	// published without a host port
+	firstHostPort = 32768
+	// volumesRoot is the directory reported as the parent of volume mount points
+	volumesRoot = "/var/lib/docker/volumes"
+	// imageManifestFile is the name of the manifest in an image archive
+	imageManifestFile = "manifest.json"
+)
+
+// DockerClient is an in-memory implementation of dockerapi.DockerClient. It's
+// safe for concurrent use
+type DockerClient struct {
+	// StatsInterval is the interval between stats samples of a container
+	StatsInterval time.Duration
+
+	images      map[string]*image
+	tags        map[string]string
+	containers  map[string]*container
+	volumes     map[string]*types.Volume
+	volumeSizes map[string]int64
+	subscribers map[*subscriber]struct{}
+	lastID      int
+	lastPort    int
+
+	latencies     map[Operation]time.Duration
+	errors        map[Operation]error
+	pullErrors    map[string]error
+	calls         map[Operation]int
+	dropEvent     EventFilter
+	liveRestore   bool
+	daemonStopped bool
+
+	lock sync.Mutex
+}
+
+// image is an image known to the daemon
+type image struct {
+	id       string
+	repoTags []string
+	size     int64
+	created  time.Time
+}
+
+// container is a container known to the daemon
+type container struct {
+	id         string
+	name       string
+	imageID    string
+	config     dockercontainer.Config
+	hostConfig dockercontainer.HostConfig
+	ports      nat.PortMap
+	created    time.Time
+	started    time.Time
+	finished   time.Time
+	running    bool
+	exitCode   int
+	oomKilled  bool
+	stats      types.StatsJSON
+	sizeRw     int64
+}
+
+// subscriber is a consumer of the event stream. Events are queued so that a
+// slow consumer doesn't block the daemon and no event is lost
+type subscriber struct {
+	queue  []dockerapi.DockerContainerChangeEvent
+	notify chan struct{}
+}
+
+// notFoundError is returned for missing objects. Like the errors of the Docker
+// SDK, it's recognized by client.IsErrNotFound
+type notFoundError struct {
+	object string
+	id     string
+}
+
+func (err notFoundError) Error() string {
+	return fmt.Sprintf("Error: No such %s: %s", err.object, err.id)
+}
+
+// NotFound returns true to mark the error as a missing object
+func (err notFoundError) NotFound() bool {
+	return true
+}
+
+// versionedClient is the client returned by WithVersion, which shares the
+// state of the daemon
+type versionedClient struct {
+	*DockerClient
+	version dockerclient.DockerVersion
+}
+
+// NewDockerClient returns a client of an empty, running daemon
+func NewDockerClient() *DockerClient {
+	return &DockerClient{
+		StatsInterval: DefaultStatsInterval,
+		images:        make(map[string]*image),
+		tags:          make(map[string]string),
+		containers:    make(map[string]*container),
+		volumes:       make(map[string]*types.Volume),
+		volumeSizes:   make(map[string]int64),
+		subscribers:   make(map[*subscriber]struct{}),
+		latencies:     make(map[Operation]time.Duration),
+		errors:        make(map[Operation]error),
+		pullErrors:    make(map[string]error),
+		calls:         make(map[Operation]int),
+		lastPort:      firstHostPort - 1,
+	}
+}
+
+// AddImage makes an image available as if it had been pulled, and returns its id
+func (dg *DockerClient) AddImage(name string, size int64) string {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	img := dg.tagImageUnsafe(normalizeImage(name))
+	img.size = size
+	return img.id
+}
+
+// ExitContainer stops a running container with the given exit code, as if its
+// process had exited on its own
+func (dg *DockerClient) ExitContainer(id string, exitCode int) error {
+	return dg.exit(id, exitCode, false)
+}
+
+// OOMKillContainer stops a running container as if it had been killed for
+// running out of memory
+func (dg *DockerClient) OOMKillContainer(id string) error {
+	return dg.exit(id, daemonKilledExitCode, true)
+}
+
+// SetStats sets the stats reported for a container. The read times are set
+// when the stats are sampled
+func (dg *DockerClient) SetStats(id string, stats types.StatsJSON) error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return notFoundError{"container", id}
+	}
+	container.stats = stats
+	return nil
+}
+
+// SetContainerSize sets the size of the writable layer of a container, as
+// reported by DiskUsage
+func (dg *DockerClient) SetContainerSize(id string, sizeRw int64) error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return notFoundError{"container", id}
+	}
+	container.sizeRw = sizeRw
+	return nil
+}
+
+// SetVolumeSize sets the size of a volume, as reported by DiskUsage
+func (dg *DockerClient) SetVolumeSize(name string, size int64) error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if _, ok := dg.volumes[name]; !ok {
+		return notFoundError{"volume", name}
+	}
+	dg.volumeSizes[name] = size
+	return nil
+}
+
+// SupportedVersions returns the API versions supported by the fake daemon
+func (dg *DockerClient) SupportedVersions() []dockerclient.DockerVersion {
+	return dockerclient.GetKnownAPIVersions()
+}
+
+// KnownVersions returns the API versions known to the fake daemon
+func (dg *DockerClient) KnownVersions() []dockerclient.DockerVersion {
+	return dockerclient.GetKnownAPIVersions()
+}
+
+// WithVersion returns a client that shares the state of the daemon and reports
+// the given API version
+func (dg *DockerClient) WithVersion(version dockerclient.DockerVersion) dockerapi.DockerClient {
+	return &versionedClient{DockerClient: dg, version: version}
+}
+
+// ContainerEvents returns the container events that happen after the call. The
+// channel is closed when the context is canceled
+func (dg *DockerClient) ContainerEvents(ctx context.Context) (<-chan dockerapi.DockerContainerChangeEvent, error) {
+	dg.lock.Lock()
+	if dg.daemonStopped {
+		dg.lock.Unlock()
+		return nil, daemonStoppedError()
+	}
+	sub := &subscriber{notify: make(chan struct{}, 1)}
+	dg.subscribers[sub] = struct{}{}
+	dg.lock.Unlock()
+
+	events := make(chan dockerapi.DockerContainerChangeEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			dg.lock.Lock()
+			delete(dg.subscribers, sub)
+			dg.lock.Unlock()
+		}()
+		for {
+			select {
+			case <-sub.notify:
+			case <-ctx.Done():
+				return
+			}
+			dg.lock.Lock()
+			queue := sub.queue
+			sub.queue = nil
+			dg.lock.Unlock()
+			for _, event := range queue {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// PullImage makes the image available, unless a pull error was set for it. The
+// image is pulled as a single layer, whose progress is reported once it's pulled
+func (dg *DockerClient) PullImage(ctx context.Context, name string,
+	authData *apicontainer.RegistryAuthenticationData, timeout time.Duration) dockerapi.DockerContainerMetadata {
+	if err := dg.call(ctx, OperationPullImage, timeout, "pulled"); err != nil {
+		return dockerapi.DockerContainerMetadata{Error: namedError(err, pullError)}
+	}
+	dg.lock.Lock()
+	name = normalizeImage(name)
+	if err, ok := dg.pullErrors[name]; ok {
+		dg.lock.Unlock()
+		return dockerapi.DockerContainerMetadata{Error: namedError(err, pullError)}
+	}
+	size := dg.tagImageUnsafe(name).size
+	dg.lock.Unlock()
+
+	if reporter, ok := dockerapi.PullProgressReporterFromContext(ctx); ok {
+		reporter(apicontainer.PullProgress{
+			Layers:          1,
+			LayersDone:      1,
+			BytesDownloaded: size,
+			BytesTotal:      size,
+			UpdatedAt:       time.Now(),
+		})
+	}
+	return dockerapi.DockerContainerMetadata{}
+}
+
+// CreateContainer creates a container from an available image
+func (dg *DockerClient) CreateContainer(ctx context.Context, config *dockercontainer.Config,
+	hostConfig *dockercontainer.HostConfig, name string, timeout time.Duration) dockerapi.DockerContainerMetadata {
+	if err := dg.call(ctx, OperationCreateContainer, timeout, "created"); err != nil {
+		return dockerapi.DockerContainerMetadata{Error: namedError(err, createError)}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	imageID, ok := dg.imageIDUnsafe(config.Image)
+	if !ok {
+		return dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotCreateContainerError{FromError: notFoundError{"image", config.Image}}}
+	}
+	for _, existing := range dg.containers {
+		if name != "" && existing.name == name {
+			return dockerapi.DockerContainerMetadata{Error: dockerapi.CannotCreateContainerError{
+				FromError: errors.Errorf("Conflict. The container name \"/%s\" is already in use by container \"%s\"",
+					name, existing.id)}}
+		}
+	}
+	dg.lastID++
+	container := &container{
+		id:      fmt.Sprintf("%064x", dg.lastID),
+		name:    name,
+		imageID: imageID,
+		created: time.Now(),
+	}
+	if container.name == "" {
+		container.name = "container-" + strconv.Itoa(dg.lastID)
+	}
+	if config != nil {
+		container.config = *config
+	}
+	if hostConfig != nil {
+		container.hostConfig = *hostConfig
+	}
+	dg.containers[container.id] = container
+	dg.emitUnsafe(container, apicontainerstatus.ContainerCreated)
+	return dockerapi.MetadataFromContainer(container.json())
+}
+
+// StartContainer starts a created or stopped container
+func (dg *DockerClient) StartContainer(ctx context.Context, id string,
+	timeout time.Duration) dockerapi.DockerContainerMetadata {
+	if err := dg.call(ctx, OperationStartContainer, timeout, "started"); err != nil {
+		return dockerapi.DockerContainerMetadata{Error: namedError(err, startError)}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotStartContainerError{FromError: notFoundError{"container", id}}}
+	}
+	if !container.running {
+		container.running = true
+		container.exitCode = 0
+		container.oomKilled = false
+		container.started = time.Now()
+		container.finished = time.Time{}
+		container.ports = dg.publishPortsUnsafe(container.hostConfig.PortBindings)
+		dg.emitUnsafe(container, apicontainerstatus.ContainerRunning)
+	}
+	return dockerapi.MetadataFromContainer(container.json())
+}
+
+// StopContainer stops a running container with exit code 0
+func (dg *DockerClient) StopContainer(ctx context.Context, id string,
+	timeout time.Duration) dockerapi.DockerContainerMetadata {
+	if err := dg.call(ctx, OperationStopContainer, timeout, "stopped"); err != nil {
+		return dockerapi.DockerContainerMetadata{Error: namedError(err, stopError)}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotStopContainerError{FromError: dockerapi.NoSuchContainerError{ID: id}}}
+	}
+	if container.running {
+		dg.exitUnsafe(container, 0, false)
+	}
+	return dockerapi.MetadataFromContainer(container.json())
+}
+
+// DescribeContainer returns the status and metadata of a container
+func (dg *DockerClient) DescribeContainer(ctx context.Context,
+	id string) (apicontainerstatus.ContainerStatus, dockerapi.DockerContainerMetadata) {
+	dockerContainer, err := dg.InspectContainer(ctx, id, dockerclient.InspectContainerTimeout)
+	if err != nil {
+		return apicontainerstatus.ContainerStatusNone, dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotDescribeContainerError{FromError: err}}
+	}
+	return dockerapi.DockerStateToState(dockerContainer.State), dockerapi.MetadataFromContainer(dockerContainer)
+}
+
+// RemoveContainer removes a container that isn't running
+func (dg *DockerClient) RemoveContainer(ctx context.Context, id string, timeout time.Duration) error {
+	if err := dg.call(ctx, OperationRemoveContainer, timeout, "removed"); err != nil {
+		return err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return notFoundError{"container", id}
+	}
+	if container.running {
+		return errors.Errorf("You cannot remove a running container %s. Stop the container before attempting removal",
+			id)
+	}
+	delete(dg.containers, id)
+	return nil
+}
+
+// InspectContainer returns the inspect data of a container
+func (dg *DockerClient) InspectContainer(ctx context.Context, id string,
+	timeout time.Duration) (*types.ContainerJSON, error) {
+	if err := dg.call(ctx, OperationInspectContainer, timeout, "inspecting"); err != nil {
+		return nil, err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return nil, notFoundError{"container", id}
+	}
+	return container.json(), nil
+}
+
+// ListContainers returns the ids of the running containers, or of all
+// containers, in the order they were created
+func (dg *DockerClient) ListContainers(ctx context.Context, all bool,
+	timeout time.Duration) dockerapi.ListContainersResponse {
+	if err := dg.call(ctx, "", timeout, "listing"); err != nil {
+		return dockerapi.ListContainersResponse{Error: err}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	var ids []string
+	for _, container := range dg.sortedContainersUnsafe() {
+		if all || container.running {
+			ids = append(ids, container.id)
+		}
+	}
+	return dockerapi.ListContainersResponse{DockerIDs: ids}
+}
+
+// ListImages returns the ids and tags of the available images
+func (dg *DockerClient) ListImages(ctx context.Context, timeout time.Duration) dockerapi.ListImagesResponse {
+	if err := dg.call(ctx, "", timeout, "listing"); err != nil {
+		return dockerapi.ListImagesResponse{Error: err}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	var response dockerapi.ListImagesResponse
+	for _, img := range dg.sortedImagesUnsafe() {
+		response.ImageIDs = append(response.ImageIDs, img.id)
+		response.RepoTags = append(response.RepoTags, img.repoTags...)
+	}
+	return response
+}
+
+// CreateVolume creates a volume, or returns the volume if it already exists
+func (dg *DockerClient) CreateVolume(ctx context.Context, name string, driver string,
+	driverOptions map[string]string, labels map[string]string, timeout time.Duration) dockerapi.SDKVolumeResponse {
+	if err := dg.call(ctx, OperationCreateVolume, timeout, "creating volume"); err != nil {
+		return dockerapi.SDKVolumeResponse{Error: err}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	volume, ok := dg.volumes[name]
+	if !ok {
+		if driver == "" {
+			driver = "local"
+		}
+		volume = &types.Volume{
+			Name:       name,
+			Driver:     driver,
+			Options:    driverOptions,
+			Labels:     labels,
+			Mountpoint: volumesRoot + "/" + name + "/_data",
+			Scope:      "local",
+			CreatedAt:  time.Now().Format(time.RFC3339),
+		}
+		dg.volumes[name] = volume
+	}
+	volumeCopy := *volume
+	return dockerapi.SDKVolumeResponse{DockerVolume: &volumeCopy}
+}
+
+// InspectVolume returns a volume
+func (dg *DockerClient) InspectVolume(ctx context.Context, name string,
+	timeout time.Duration) dockerapi.SDKVolumeResponse {
+	if err := dg.call(ctx, OperationInspectVolume, timeout, "inspecting volume"); err != nil {
+		return dockerapi.SDKVolumeResponse{Error: err}
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	volume, ok := dg.volumes[name]
+	if !ok {
+		return dockerapi.SDKVolumeResponse{Error: notFoundError{"volume", name}}
+	}
+	volumeCopy := *volume
+	return dockerapi.SDKVolumeResponse{DockerVolume: &volumeCopy}
+}
+
+// RemoveVolume removes a volume that isn't used by any container
+func (dg *DockerClient) RemoveVolume(ctx context.Context, name string, timeout time.Duration) error {
+	if err := dg.call(ctx, OperationRemoveVolume, timeout, "removing volume"); err != nil {
+		return err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if _, ok := dg.volumes[name]; !ok {
+		return notFoundError{"volume", name}
+	}
+	for _, container := range dg.sortedContainersUnsafe() {
+		for _, bind := range container.hostConfig.Binds {
+			if strings.SplitN(bind, ":", 2)[0] == name {
+				return errors.Errorf("remove %s: volume is in use - [%s]", name, container.id)
+			}
+		}
+	}
+	delete(dg.volumes, name)
+	delete(dg.volumeSizes, name)
+	return nil
+}
+
+// ListPluginsWithFilters returns no plugins, the fake daemon has none
+func (dg *DockerClient) ListPluginsWithFilters(ctx context.Context, enabled bool, capabilities []string,
+	timeout time.Duration) ([]string, error) {
+	if err := dg.call(ctx, "", timeout, "listing plugins"); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ListPlugins returns no plugins, the fake daemon has none
+func (dg *DockerClient) ListPlugins(ctx context.Context, timeout time.Duration,
+	filters filters.Args) dockerapi.ListPluginsResponse {
+	if err := dg.call(ctx, "", timeout, "listing plugins"); err != nil {
+		return dockerapi.ListPluginsResponse{Error: err}
+	}
+	return dockerapi.ListPluginsResponse{}
+}
+
+// Stats returns a stats sample of a container right away and then every
+// StatsInterval. An error is sent when the container goes away
+func (dg *DockerClient) Stats(ctx context.Context, id string,
+	inactivityTimeout time.Duration) (<-chan *types.StatsJSON, <-chan error) {
+	statsC := make(chan *types.StatsJSON)
+	errC := make(chan error, 1)
+	go func() {
+		defer close(statsC)
+		ticker := time.NewTicker(dg.StatsInterval)
+		defer ticker.Stop()
+		var previous time.Time
+		for {
+			stats, err := dg.sample(id, previous)
+			if err != nil {
+				errC <- err
+				return
+			}
+			previous = stats.Read
+			select {
+			case statsC <- stats:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return statsC, errC
+}
+
+// Version returns the version of the fake daemon
+func (dg *DockerClient) Version(ctx context.Context, timeout time.Duration) (string, error) {
+	if err := dg.call(ctx, "", timeout, "version"); err != nil {
+		return "", err
+	}
+	return DaemonVersion, nil
+}
+
+// APIVersion returns the default API version of the client
+func (dg *DockerClient) APIVersion() (dockerclient.DockerVersion, error) {
+	versions := dockerclient.GetKnownAPIVersions()
+	return versions[len(versions)-1], nil
+}
+
+// InspectImage returns the inspect data of an image, by name or id
+func (dg *DockerClient) InspectImage(name string) (*types.ImageInspect, error) {
+	if err := dg.call(context.Background(), "", 0, "inspecting image"); err != nil {
+		return nil, err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	imageID, ok := dg.imageIDUnsafe(name)
+	if !ok {
+		return nil, notFoundError{"image", name}
+	}
+	img := dg.images[imageID]
+	return &types.ImageInspect{
+		ID:          img.id,
+		RepoTags:    append([]string{}, img.repoTags...),
+		RepoDigests: img.repoDigests(),
+		Created:     img.created.Format(time.RFC3339Nano),
+		Size:        img.size,
+		VirtualSize: img.size,
+	}, nil
+}
+
+// RemoveImage removes a tag of an image, or an image by id. The image is
+// deleted once it has no tags left, unless a container uses it
+func (dg *DockerClient) RemoveImage(ctx context.Context, name string, timeout time.Duration) error {
+	if err := dg.call(ctx, OperationRemoveImage, timeout, "removing image"); err != nil {
+		return err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	imageID, ok := dg.imageIDUnsafe(name)
+	if !ok {
+		return notFoundError{"image", name}
+	}
+	img := dg.images[imageID]
+	tag := normalizeImage(name)
+	removeImage := imageID == name || len(img.repoTags) <= 1
+	if removeImage {
+		for _, container := range dg.sortedContainersUnsafe() {
+			if container.imageID == imageID {
+				return errors.Errorf("conflict: unable to remove repository reference %q (must force) - "+
+					"container %s is using its referenced image %s", name, container.id, imageID)
+			}
+		}
+		for _, repoTag := range img.repoTags {
+			delete(dg.tags, repoTag)
+		}
+		delete(dg.images, imageID)
+		return nil
+	}
+	delete(dg.tags, tag)
+	for i, repoTag := range img.repoTags {
+		if repoTag == tag {
+			img.repoTags = append(img.repoTags[:i], img.repoTags[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// LoadImage makes the images tagged in the manifest of an image archive available
+func (dg *DockerClient) LoadImage(ctx context.Context, inputStream io.Reader, timeout time.Duration) error {
+	if err := dg.call(ctx, OperationLoadImage, timeout, "loading image"); err != nil {
+		return err
+	}
+	repoTags, err := imageArchiveRepoTags(inputStream)
+	if err != nil {
+		return err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	for _, repoTag := range repoTags {
+		dg.tagImageUnsafe(normalizeImage(repoTag))
+	}
+	return nil
+}
+
+// Info returns the information of the fake daemon
+func (dg *DockerClient) Info(ctx context.Context, timeout time.Duration) (types.Info, error) {
+	if err := dg.call(ctx, "", timeout, "info"); err != nil {
+		return types.Info{}, err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	info := types.Info{
+		ServerVersion: DaemonVersion,
+		Containers:    len(dg.containers),
+		Images:        len(dg.images),
+		Driver:        "overlay2",
+		OSType:        "linux",
+	}
+	for _, container := range dg.containers {
+		if container.running {
+			info.ContainersRunning++
+		} else {
+			info.ContainersStopped++
+		}
+	}
+	return info, nil
+}
+
+// DiskUsage returns the sizes of the writable layers of the containers and of
+// the volumes, as set with SetContainerSize and SetVolumeSize
+func (dg *DockerClient) DiskUsage(ctx context.Context, timeout time.Duration) (types.DiskUsage, error) {
+	if err := dg.call(ctx, OperationDiskUsage, timeout, "disk usage"); err != nil {
+		return types.DiskUsage{}, err
+	}
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	var usage types.DiskUsage
+	refCounts := make(map[string]int64)
+	for _, container := range dg.sortedContainersUnsafe() {
+		usage.Containers = append(usage.Containers, &types.Container{
+			ID:      container.id,
+			Names:   []string{"/" + container.name},
+			ImageID: container.imageID,
+			SizeRw:  container.sizeRw,
+		})
+		for _, bind := range container.hostConfig.Binds {
+			refCounts[strings.SplitN(bind, ":", 2)[0]]++
+		}
+	}
+	for _, img := range dg.sortedImagesUnsafe() {
+		usage.LayersSize += img.size
+	}
+	names := make([]string, 0, len(dg.volumes))
+	for name := range dg.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		volumeCopy := *dg.volumes[name]
+		volumeCopy.UsageData = &types.VolumeUsageData{
+			RefCount: refCounts[name],
+			Size:     dg.volumeSizes[name],
+		}
+		usage.Volumes = append(usage.Volumes, &volumeCopy)
+	}
+	return usage, nil
+}
+
+// APIVersion returns the API version the client was created with
+func (vc *versionedClient) APIVersion() (dockerclient.DockerVersion, error) {
+	return vc.version, nil
+}
+
+// call accounts for a call of the operation and applies the faults injected for
+// it. The latency of the call is bounded by the timeout, like in the real client
+func (dg *DockerClient) call(ctx context.Context, op Operation, timeout time.Duration, transition string) error {
+	dg.lock.Lock()
+	if op != "" {
+		dg.calls[op]++
+	}
+	latency := dg.latencies[op]
+	dg.lock.Unlock()
+
+	if latency > 0 {
+		wait := latency
+		if timeout > 0 && timeout < latency {
+			wait = timeout
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if wait < latency {
+			return &dockerapi.DockerTimeoutError{Duration: timeout, Transition: transition}
+		}
+	}
+
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if dg.daemonStopped {
+		return daemonStoppedError()
+	}
+	return dg.errors[op]
+}
+
+func (dg *DockerClient) exit(id string, exitCode int, oomKilled bool) error {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return notFoundError{"container", id}
+	}
+	if !container.running {
+		return errors.Errorf("fake docker: container %s is not running", id)
+	}
+	dg.exitUnsafe(container, exitCode, oomKilled)
+	return nil
+}
+
+func (dg *DockerClient) exitUnsafe(container *container, exitCode int, oomKilled bool) {
+	container.running = false
+	container.exitCode = exitCode
+	container.oomKilled = oomKilled
+	container.finished = time.Now()
+	container.ports = nil
+	dg.emitUnsafe(container, apicontainerstatus.ContainerStopped)
+}
+
+// emitUnsafe queues an event with the current state of the container for every
+// subscriber, unless the daemon is stopped or the event is dropped
+func (dg *DockerClient) emitUnsafe(container *container, status apicontainerstatus.ContainerStatus) {
+	if dg.daemonStopped {
+		return
+	}
+	event := dockerapi.DockerContainerChangeEvent{
+		Status: status,
+		Type:   apicontainer.ContainerStatusEvent,
+	}
+	// Like the real client, create events only carry the id of the container
+	if status == apicontainerstatus.ContainerCreated {
+		event.DockerID = container.id
+	} else {
+		event.DockerContainerMetadata = dockerapi.MetadataFromContainer(container.json())
+	}
+	if dg.dropEvent != nil && dg.dropEvent(event) {
+		return
+	}
+	for sub := range dg.subscribers {
+		sub.queue = append(sub.queue, event)
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (dg *DockerClient) sample(id string, previous time.Time) (*types.StatsJSON, error) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	container, ok := dg.containers[id]
+	if !ok {
+		return nil, notFoundError{"container", id}
+	}
+	stats := container.stats
+	stats.Read = time.Now()
+	stats.PreRead = previous
+	stats.ID = container.id
+	stats.Name = "/" + container.name
+	return &stats, nil
+}
+
+// tagImageUnsafe returns the image with the tag, creating it if needed. The id
+// of the image is derived from the tag so that it's stable across pulls
+func (dg *DockerClient) tagImageUnsafe(tag string) *image {
+	if imageID, ok := dg.tags[tag]; ok {
+		return dg.images[imageID]
+	}
+	img := &image{
+		id:       fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(tag))),
+		repoTags: []string{tag},
+		created:  time.Now(),
+	}
+	dg.images[img.id] = img
+	dg.tags[tag] = img.id
+	return img
+}
+
+// repoDigests returns the repository digests of the image, one per repository it's
+// tagged in. The image id stands in for the digest of its manifest
+func (img *image) repoDigests() []string {
+	var repoDigests []string
+	seen := make(map[string]bool)
+	for _, tag := range img.repoTags {
+		repository := tag
+		if i := strings.LastIndex(tag, ":"); i > strings.LastIndex(tag, "/") {
+			repository = tag[:i]
+		}
+		if !seen[repository] {
+			seen[repository] = true
+			repoDigests = append(repoDigests, repository+"@"+img.id)
+		}
+	}
+	return repoDigests
+}
+
+func (dg *DockerClient) imageIDUnsafe(name string) (string, bool) {
+	if _, ok := dg.images[name]; ok {
+		return name, true
+	}
+	imageID, ok := dg.tags[normalizeImage(name)]
+	return imageID, ok
+}
+
+// publishPortsUnsafe assigns host ports to the port bindings that don't have one
+func (dg *DockerClient) publishPortsUnsafe(bindings nat.PortMap) nat.PortMap {
+	ports := nat.PortMap{}
+	for port, portBindings := range bindings {
+		for _, binding := range portBindings {
+			if binding.HostIP == "" {
+				binding.HostIP = "0.0.0.0"
+			}
+			if binding.HostPort == "" {
+				dg.lastPort++
+				binding.HostPort = strconv.Itoa(dg.lastPort)
+			}
+			ports[port] = append(ports[port], binding)
+		}
+	}
+	return ports
+}
+
+func (dg *DockerClient) sortedContainersUnsafe() []*container {
+	containers := make([]*container, 0, len(dg.containers))
+	for _, container := range dg.containers {
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].id < containers[j].id })
+	return containers
+}
+
+func (dg *DockerClient) sortedImagesUnsafe() []*image {
+	images := make([]*image, 0, len(dg.images))
+	for _, img := range dg.images {
+		images = append(images, img)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].id < images[j].id })
+	return images
+}
+
+// json returns the inspect data of the container
+func (c *container) json() *types.ContainerJSON {
+	config := c.config
+	hostConfig := c.hostConfig
+	status := "created"
+	switch {
+	case c.running:
+		status = "running"
+	case !c.finished.IsZero():
+		status = "exited"
+	}
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:      c.id,
+			Created: c.created.Format(time.RFC3339Nano),
+			Name:    "/" + c.name,
+			Image:   c.imageID,
+			State: &types.ContainerState{
+				Status:     status,
+				Running:    c.running,
+				OOMKilled:  c.oomKilled,
+				ExitCode:   c.exitCode,
+				StartedAt:  c.started.Format(time.RFC3339Nano),
+				FinishedAt: c.finished.Format(time.RFC3339Nano),
+			},
+			HostConfig: &hostConfig,
+		},
+		Config: &config,
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{Ports: c.ports},
+		},
+	}
+}
+
+// namedError returns errors that are already named as is, and wraps the others
+func namedError(err error, wrap func(error) apierrors.NamedError) apierrors.NamedError {
+	if named, ok := err.(apierrors.NamedError); ok {
+		return named
+	}
+	return wrap(err)
+}
+
+func pullError(err error) apierrors.NamedError {
+	return dockerapi.CannotPullContainerError{FromError: err}
+}
+
+func createError(err error) apierrors.NamedError {
+	return dockerapi.CannotCreateContainerError{FromError: err}
+}
+
+func startError(err error) apierrors.NamedError {
+	return dockerapi.CannotStartContainerError{FromError: err}
+}
+
+func stopError(err error) apierrors.NamedError {
+	return dockerapi.CannotStopContainerError{FromError: err}
+}
+
+// normalizeImage adds the latest tag to image references without a tag or digest
+func normalizeImage(name string) string {
+	if strings.HasPrefix(name, "sha256:") || strings.Contains(name, "@") {
+		return name
+	}
+	if strings.LastIndex(name, ":") > strings.LastIndex(name, "/") {
+		return name
+	}
+	return name + ":latest"
+}
+
+// imageArchiveRepoTags returns the repository tags listed in the manifest of an
+// archive created by 'docker save'
+func imageArchiveRepoTags(inputStream io.Reader) ([]string, error) {
+	reader := tar.NewReader(inputStream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("open /var/lib/docker/tmp/%s: no such file or directory", imageManifestFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name != imageManifestFile {
+			continue
+		}
+		var manifest []struct {
+			RepoTags []string
+		}
+		if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+			return nil, err
+		}
+		var repoTags []string
+		for _, entry := range manifest {
+			repoTags = append(repoTags, entry.RepoTags...)
+		}
+		return repoTags, nil
+	}
+}
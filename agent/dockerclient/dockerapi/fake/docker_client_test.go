@@ -0,0 +1,321 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testImage   = "busybox"
+	testTimeout = time.Second
+)
+
+var _ dockerapi.DockerClient = (*DockerClient)(nil)
+
+func createTestContainer(t *testing.T, dg *DockerClient, name string) string {
+	metadata := dg.CreateContainer(context.TODO(), &dockercontainer.Config{Image: testImage},
+		&dockercontainer.HostConfig{}, name, testTimeout)
+	require.NoError(t, metadata.Error)
+	return metadata.DockerID
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	dg := NewDockerClient()
+	events, err := dg.ContainerEvents(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, dg.PullImage(ctx, testImage, nil, testTimeout).Error)
+	metadata := dg.CreateContainer(ctx, &dockercontainer.Config{Image: testImage}, &dockercontainer.HostConfig{
+		PortBindings: nat.PortMap{"80/tcp": []nat.PortBinding{{}}},
+	}, "app", testTimeout)
+	require.NoError(t, metadata.Error)
+	id := metadata.DockerID
+
+	metadata = dg.StartContainer(ctx, id, testTimeout)
+	require.NoError(t, metadata.Error)
+	require.Len(t, metadata.PortBindings, 1)
+	assert.Equal(t, uint16(80), metadata.PortBindings[0].ContainerPort)
+	assert.Equal(t, uint16(firstHostPort), metadata.PortBindings[0].HostPort)
+	status, _ := dg.DescribeContainer(ctx, id)
+	assert.Equal(t, apicontainerstatus.ContainerRunning, status)
+
+	require.NoError(t, dg.ExitContainer(id, 1))
+	status, metadata = dg.DescribeContainer(ctx, id)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, status)
+	require.NotNil(t, metadata.ExitCode)
+	assert.Equal(t, 1, *metadata.ExitCode)
+
+	for _, expected := range []apicontainerstatus.ContainerStatus{
+		apicontainerstatus.ContainerCreated,
+		apicontainerstatus.ContainerRunning,
+		apicontainerstatus.ContainerStopped,
+	} {
+		event := <-events
+		assert.Equal(t, id, event.DockerID)
+		assert.Equal(t, expected, event.Status)
+	}
+
+	require.NoError(t, dg.RemoveContainer(ctx, id, testTimeout))
+	_, err = dg.InspectContainer(ctx, id, testTimeout)
+	assert.True(t, client.IsErrNotFound(err))
+}
+
+func TestCreateContainerErrors(t *testing.T) {
+	dg := NewDockerClient()
+	metadata := dg.CreateContainer(context.TODO(), &dockercontainer.Config{Image: testImage}, nil, "app", testTimeout)
+	assert.IsType(t, dockerapi.CannotCreateContainerError{}, metadata.Error)
+
+	dg.AddImage(testImage, 0)
+	createTestContainer(t, dg, "app")
+	metadata = dg.CreateContainer(context.TODO(), &dockercontainer.Config{Image: testImage}, nil, "app", testTimeout)
+	assert.IsType(t, dockerapi.CannotCreateContainerError{}, metadata.Error)
+}
+
+func TestStopMissingContainer(t *testing.T) {
+	dg := NewDockerClient()
+	metadata := dg.StopContainer(context.TODO(), "missing", testTimeout)
+	stopErr, ok := metadata.Error.(dockerapi.CannotStopContainerError)
+	require.True(t, ok)
+	assert.False(t, stopErr.IsRetriableError())
+}
+
+func TestOOMKillContainer(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	id := createTestContainer(t, dg, "app")
+	require.NoError(t, dg.StartContainer(context.TODO(), id, testTimeout).Error)
+
+	require.NoError(t, dg.OOMKillContainer(id))
+	_, metadata := dg.DescribeContainer(context.TODO(), id)
+	assert.IsType(t, dockerapi.OutOfMemoryError{}, metadata.Error)
+}
+
+func TestPullImageErrors(t *testing.T) {
+	dg := NewDockerClient()
+	dg.SetPullError(testImage, errors.New("registry unavailable"))
+	metadata := dg.PullImage(context.TODO(), testImage+":latest", nil, testTimeout)
+	assert.IsType(t, dockerapi.CannotPullContainerError{}, metadata.Error)
+	_, err := dg.InspectImage(testImage)
+	assert.True(t, client.IsErrNotFound(err))
+
+	dg.SetPullError(testImage, nil)
+	require.NoError(t, dg.PullImage(context.TODO(), testImage, nil, testTimeout).Error)
+	inspected, err := dg.InspectImage(testImage)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testImage + ":latest"}, inspected.RepoTags)
+	assert.Equal(t, []string{testImage + "@" + inspected.ID}, inspected.RepoDigests)
+	assert.Equal(t, 2, dg.CallCount(OperationPullImage))
+}
+
+func TestPullImageReportsProgress(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 1024)
+
+	var reported []apicontainer.PullProgress
+	ctx := dockerapi.WithPullProgressReporter(context.TODO(), func(progress apicontainer.PullProgress) {
+		reported = append(reported, progress)
+	})
+	require.NoError(t, dg.PullImage(ctx, testImage, nil, testTimeout).Error)
+	require.Len(t, reported, 1)
+	assert.Equal(t, 1, reported[0].LayersDone)
+	assert.EqualValues(t, 1024, reported[0].BytesDownloaded)
+	assert.EqualValues(t, 1024, reported[0].BytesTotal)
+}
+
+func TestLatencyTimesOut(t *testing.T) {
+	dg := NewDockerClient()
+	dg.SetLatency(OperationPullImage, time.Minute)
+	metadata := dg.PullImage(context.TODO(), testImage, nil, 10*time.Millisecond)
+	assert.IsType(t, &dockerapi.DockerTimeoutError{}, metadata.Error)
+
+	dg.SetLatency(OperationPullImage, 10*time.Millisecond)
+	assert.NoError(t, dg.PullImage(context.TODO(), testImage, nil, testTimeout).Error)
+}
+
+func TestSetError(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	id := createTestContainer(t, dg, "app")
+
+	dg.SetError(OperationStartContainer, errors.New("start failed"))
+	assert.IsType(t, dockerapi.CannotStartContainerError{}, dg.StartContainer(context.TODO(), id, testTimeout).Error)
+	dg.SetError(OperationStartContainer, nil)
+	assert.NoError(t, dg.StartContainer(context.TODO(), id, testTimeout).Error)
+}
+
+func TestDropEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	events, err := dg.ContainerEvents(ctx)
+	require.NoError(t, err)
+
+	dg.DropEvents(func(event dockerapi.DockerContainerChangeEvent) bool {
+		return event.Status == apicontainerstatus.ContainerCreated
+	})
+	id := createTestContainer(t, dg, "app")
+	require.NoError(t, dg.StartContainer(ctx, id, testTimeout).Error)
+
+	event := <-events
+	assert.Equal(t, apicontainerstatus.ContainerRunning, event.Status)
+}
+
+func TestDaemonRestart(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	id := createTestContainer(t, dg, "app")
+	require.NoError(t, dg.StartContainer(context.TODO(), id, testTimeout).Error)
+
+	dg.StopDaemon()
+	_, err := dg.InspectContainer(context.TODO(), id, testTimeout)
+	assert.Error(t, err)
+	_, err = dg.ContainerEvents(context.TODO())
+	assert.Error(t, err)
+
+	dg.StartDaemon()
+	status, metadata := dg.DescribeContainer(context.TODO(), id)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, status)
+	require.NotNil(t, metadata.ExitCode)
+	assert.Equal(t, daemonKilledExitCode, *metadata.ExitCode)
+}
+
+func TestDaemonRestartWithLiveRestore(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	id := createTestContainer(t, dg, "app")
+	require.NoError(t, dg.StartContainer(context.TODO(), id, testTimeout).Error)
+
+	dg.SetLiveRestore(true)
+	dg.RestartDaemon()
+	status, _ := dg.DescribeContainer(context.TODO(), id)
+	assert.Equal(t, apicontainerstatus.ContainerRunning, status)
+}
+
+func TestRemoveImage(t *testing.T) {
+	dg := NewDockerClient()
+	imageID := dg.AddImage(testImage, 1024)
+	dg.AddImage(testImage+":1.0", 0)
+	id := createTestContainer(t, dg, "app")
+
+	assert.Error(t, dg.RemoveImage(context.TODO(), imageID, testTimeout))
+	require.NoError(t, dg.RemoveContainer(context.TODO(), id, testTimeout))
+	require.NoError(t, dg.RemoveImage(context.TODO(), testImage, testTimeout))
+	_, err := dg.InspectImage(imageID)
+	assert.True(t, client.IsErrNotFound(err))
+
+	images := dg.ListImages(context.TODO(), testTimeout)
+	require.NoError(t, images.Error)
+	assert.Equal(t, []string{testImage + ":1.0"}, images.RepoTags)
+}
+
+func TestVolumes(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 0)
+	response := dg.CreateVolume(context.TODO(), "data", "", nil, map[string]string{"key": "value"}, testTimeout)
+	require.NoError(t, response.Error)
+	assert.Equal(t, "local", response.DockerVolume.Driver)
+
+	metadata := dg.CreateContainer(context.TODO(), &dockercontainer.Config{Image: testImage},
+		&dockercontainer.HostConfig{Binds: []string{"data:/data"}}, "app", testTimeout)
+	require.NoError(t, metadata.Error)
+	assert.Error(t, dg.RemoveVolume(context.TODO(), "data", testTimeout))
+	require.NoError(t, dg.RemoveContainer(context.TODO(), metadata.DockerID, testTimeout))
+	require.NoError(t, dg.RemoveVolume(context.TODO(), "data", testTimeout))
+
+	response = dg.InspectVolume(context.TODO(), "data", testTimeout)
+	assert.True(t, client.IsErrNotFound(response.Error))
+}
+
+func TestDiskUsage(t *testing.T) {
+	dg := NewDockerClient()
+	dg.AddImage(testImage, 1024)
+	require.NoError(t, dg.CreateVolume(context.TODO(), "data", "", nil, nil, testTimeout).Error)
+	metadata := dg.CreateContainer(context.TODO(), &dockercontainer.Config{Image: testImage},
+		&dockercontainer.HostConfig{Binds: []string{"data:/data"}}, "app", testTimeout)
+	require.NoError(t, metadata.Error)
+	require.NoError(t, dg.SetContainerSize(metadata.DockerID, 512))
+	require.NoError(t, dg.SetVolumeSize("data", 2048))
+	assert.Error(t, dg.SetVolumeSize("missing", 1))
+
+	usage, err := dg.DiskUsage(context.TODO(), testTimeout)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), usage.LayersSize)
+	require.Len(t, usage.Containers, 1)
+	assert.Equal(t, metadata.DockerID, usage.Containers[0].ID)
+	assert.Equal(t, int64(512), usage.Containers[0].SizeRw)
+	require.Len(t, usage.Volumes, 1)
+	assert.Equal(t, "data", usage.Volumes[0].Name)
+	assert.Equal(t, &types.VolumeUsageData{RefCount: 1, Size: 2048}, usage.Volumes[0].UsageData)
+	assert.Equal(t, 1, dg.CallCount(OperationDiskUsage))
+}
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	dg := NewDockerClient()
+	dg.StatsInterval = time.Millisecond
+	dg.AddImage(testImage, 0)
+	id := createTestContainer(t, dg, "app")
+	stats := types.StatsJSON{}
+	stats.MemoryStats.Usage = 1024
+	require.NoError(t, dg.SetStats(id, stats))
+
+	statsC, errC := dg.Stats(ctx, id, dockerclient.StatsInactivityTimeout)
+	first := <-statsC
+	assert.Equal(t, uint64(1024), first.MemoryStats.Usage)
+	second := <-statsC
+	assert.Equal(t, first.Read, second.PreRead)
+
+	require.NoError(t, dg.RemoveContainer(ctx, id, testTimeout))
+	for {
+		select {
+		case <-statsC:
+		case err := <-errC:
+			assert.True(t, client.IsErrNotFound(err))
+			return
+		}
+	}
+}
+
+func TestWithVersion(t *testing.T) {
+	dg := NewDockerClient()
+	versioned := dg.WithVersion(dockerclient.Version_1_17)
+	version, err := versioned.APIVersion()
+	require.NoError(t, err)
+	assert.Equal(t, dockerclient.Version_1_17, version)
+
+	require.NoError(t, versioned.PullImage(context.TODO(), testImage, nil, testTimeout).Error)
+	_, err = dg.InspectImage(testImage)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,167 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fake
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/docker/docker/client"
+)
+
+// Operation identifies a call of the Docker client for fault injection
+type Operation string
+
+const (
+	// OperationPullImage is the PullImage call
+	OperationPullImage Operation = "PullImage"
+	// OperationLoadImage is the LoadImage call
+	OperationLoadImage Operation = "LoadImage"
+	// OperationRemoveImage is the RemoveImage call
+	OperationRemoveImage Operation = "RemoveImage"
+	// OperationCreateContainer is the CreateContainer call
+	OperationCreateContainer Operation = "CreateContainer"
+	// OperationStartContainer is the StartContainer call
+	OperationStartContainer Operation = "StartContainer"
+	// OperationStopContainer is the StopContainer call
+	OperationStopContainer Operation = "StopContainer"
+	// OperationRemoveContainer is the RemoveContainer call
+	OperationRemoveContainer Operation = "RemoveContainer"
+	// OperationInspectContainer is the InspectContainer call, which also backs
+	// DescribeContainer
+	OperationInspectContainer Operation = "InspectContainer"
+	// OperationCreateVolume is the CreateVolume call
+	OperationCreateVolume Operation = "CreateVolume"
+	// OperationInspectVolume is the InspectVolume call
+	OperationInspectVolume Operation = "InspectVolume"
+	// OperationRemoveVolume is the RemoveVolume call
+	OperationRemoveVolume Operation = "RemoveVolume"
+	// OperationDiskUsage is the DiskUsage call
+	OperationDiskUsage Operation = "DiskUsage"
+
+	// daemonKilledExitCode is the exit code of containers that are running when
+	// the daemon stops without live restore
+	daemonKilledExitCode = 137
+	// daemonHost is the host reported in errors while the daemon is stopped
+	daemonHost = "unix:///var/run/docker.sock"
+)
+
+// EventFilter decides whether a container event is dropped instead of being
+// delivered to the event streams
+type EventFilter func(event dockerapi.DockerContainerChangeEvent) bool
+
+// SetLatency delays every call of the operation. Calls fail with a
+// DockerTimeoutError if the latency exceeds their timeout
+func (dg *DockerClient) SetLatency(op Operation, latency time.Duration) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if latency <= 0 {
+		delete(dg.latencies, op)
+		return
+	}
+	dg.latencies[op] = latency
+}
+
+// SetError makes every call of the operation fail with err. A nil err makes
+// the calls succeed again
+func (dg *DockerClient) SetError(op Operation, err error) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if err == nil {
+		delete(dg.errors, op)
+		return
+	}
+	dg.errors[op] = err
+}
+
+// SetPullError makes pulls of the image fail with err. A nil err makes pulls
+// of the image succeed again
+func (dg *DockerClient) SetPullError(image string, err error) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if err == nil {
+		delete(dg.pullErrors, normalizeImage(image))
+		return
+	}
+	dg.pullErrors[normalizeImage(image)] = err
+}
+
+// DropEvents drops the container events for which the filter returns true. A
+// nil filter delivers all events again
+func (dg *DockerClient) DropEvents(filter EventFilter) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	dg.dropEvent = filter
+}
+
+// SetLiveRestore sets whether containers keep running while the daemon is
+// stopped
+func (dg *DockerClient) SetLiveRestore(liveRestore bool) {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	dg.liveRestore = liveRestore
+}
+
+// StopDaemon simulates the daemon going away. Every call fails until
+// StartDaemon is called, and no events are delivered in between. Without live
+// restore, running containers are killed first
+func (dg *DockerClient) StopDaemon() {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	if dg.daemonStopped {
+		return
+	}
+	if !dg.liveRestore {
+		for _, container := range dg.sortedContainersUnsafe() {
+			if container.running {
+				dg.exitUnsafe(container, daemonKilledExitCode, false)
+			}
+		}
+	}
+	dg.daemonStopped = true
+}
+
+// StartDaemon brings a stopped daemon back
+func (dg *DockerClient) StartDaemon() {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	dg.daemonStopped = false
+}
+
+// RestartDaemon stops and starts the daemon
+func (dg *DockerClient) RestartDaemon() {
+	dg.StopDaemon()
+	dg.StartDaemon()
+}
+
+// CallCount returns the number of calls of the operation so far, including
+// the calls that failed
+func (dg *DockerClient) CallCount(op Operation) int {
+	dg.lock.Lock()
+	defer dg.lock.Unlock()
+
+	return dg.calls[op]
+}
+
+// daemonStoppedError is returned by every call while the daemon is stopped
+func daemonStoppedError() error {
+	return client.ErrorConnectionFailed(daemonHost)
+}
@@ -130,6 +130,21 @@ func (mr *MockDockerClientMockRecorder) DescribeContainer(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeContainer", reflect.TypeOf((*MockDockerClient)(nil).DescribeContainer), arg0, arg1)
 }
 
+// DiskUsage mocks base method
+func (m *MockDockerClient) DiskUsage(arg0 context.Context, arg1 time.Duration) (types.DiskUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiskUsage", arg0, arg1)
+	ret0, _ := ret[0].(types.DiskUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiskUsage indicates an expected call of DiskUsage
+func (mr *MockDockerClientMockRecorder) DiskUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiskUsage", reflect.TypeOf((*MockDockerClient)(nil).DiskUsage), arg0, arg1)
+}
+
 // Info mocks base method
 func (m *MockDockerClient) Info(arg0 context.Context, arg1 time.Duration) (types.Info, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,131 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerapi
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+)
+
+// Statuses of a layer in the messages of an image pull
+const (
+	layerStatusPullingFsLayer    = "Pulling fs layer"
+	layerStatusWaiting           = "Waiting"
+	layerStatusDownloading       = "Downloading"
+	layerStatusVerifyingChecksum = "Verifying Checksum"
+	layerStatusDownloadComplete  = "Download complete"
+	layerStatusExtracting        = "Extracting"
+	layerStatusPullComplete      = "Pull complete"
+	layerStatusAlreadyExists     = "Already exists"
+	layerStatusRetrying          = "Retrying in"
+)
+
+// PullProgressReporter is called with the progress of an image pull every time
+// the pull makes progress
+type PullProgressReporter func(progress apicontainer.PullProgress)
+
+type pullProgressReporterKey struct{}
+
+// WithPullProgressReporter returns a context that makes PullImage report the
+// progress of the pull to the reporter
+func WithPullProgressReporter(ctx context.Context, reporter PullProgressReporter) context.Context {
+	return context.WithValue(ctx, pullProgressReporterKey{}, reporter)
+}
+
+// PullProgressReporterFromContext returns the reporter of the pull progress set
+// on the context, if any
+func PullProgressReporterFromContext(ctx context.Context) (PullProgressReporter, bool) {
+	reporter, ok := ctx.Value(pullProgressReporterKey{}).(PullProgressReporter)
+	return reporter, ok && reporter != nil
+}
+
+// layerProgress is the progress of the download of a single layer
+type layerProgress struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+// pullProgressTracker aggregates the per layer messages of an image pull into
+// the progress of the pull
+type pullProgressTracker struct {
+	layers   map[string]*layerProgress
+	progress apicontainer.PullProgress
+	now      func() time.Time
+}
+
+func newPullProgressTracker(now func() time.Time) *pullProgressTracker {
+	return &pullProgressTracker{
+		layers: make(map[string]*layerProgress),
+		now:    now,
+	}
+}
+
+// update applies a message of the pull and returns true if the pull made progress
+func (tracker *pullProgressTracker) update(data *ImagePullResponse) bool {
+	if data.Id == "" || !isLayerStatus(data.Status) {
+		return false
+	}
+	layer, ok := tracker.layers[data.Id]
+	if !ok {
+		layer = &layerProgress{}
+		tracker.layers[data.Id] = layer
+	}
+
+	switch {
+	case data.Status == layerStatusDownloading:
+		layer.current = data.ProgressDetail.Current
+		if data.ProgressDetail.Total > 0 {
+			layer.total = data.ProgressDetail.Total
+		}
+	case data.Status == layerStatusVerifyingChecksum, data.Status == layerStatusDownloadComplete,
+		data.Status == layerStatusExtracting:
+		// The progress of the extraction isn't accounted for, the layer is
+		// fully downloaded at this point
+		layer.current = layer.total
+	case data.Status == layerStatusPullComplete, data.Status == layerStatusAlreadyExists:
+		layer.current = layer.total
+		layer.done = true
+	}
+
+	var progress apicontainer.PullProgress
+	for _, layer := range tracker.layers {
+		progress.Layers++
+		if layer.done {
+			progress.LayersDone++
+		}
+		progress.BytesDownloaded += layer.current
+		progress.BytesTotal += layer.total
+	}
+	progress.UpdatedAt = tracker.progress.UpdatedAt
+	if progress == tracker.progress {
+		return false
+	}
+	progress.UpdatedAt = tracker.now()
+	tracker.progress = progress
+	return true
+}
+
+func isLayerStatus(status string) bool {
+	switch status {
+	case layerStatusPullingFsLayer, layerStatusWaiting, layerStatusDownloading, layerStatusVerifyingChecksum,
+		layerStatusDownloadComplete, layerStatusExtracting, layerStatusPullComplete, layerStatusAlreadyExists:
+		return true
+	default:
+		return strings.HasPrefix(status, layerStatusRetrying)
+	}
+}
@@ -0,0 +1,92 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func pullResponse(id, status string, current, total int64) *ImagePullResponse {
+	data := &ImagePullResponse{Id: id, Status: status}
+	data.ProgressDetail.Current = current
+	data.ProgressDetail.Total = total
+	return data
+}
+
+func TestPullProgressTracker(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tracker := newPullProgressTracker(func() time.Time { return now })
+
+	// Messages that aren't about a layer don't make progress
+	assert.False(t, tracker.update(pullResponse("", "Pulling from library/busybox", 0, 0)))
+	assert.False(t, tracker.update(pullResponse("latest", "Pulling from library/busybox", 0, 0)))
+
+	assert.True(t, tracker.update(pullResponse("layer1", layerStatusPullingFsLayer, 0, 0)))
+	assert.True(t, tracker.update(pullResponse("layer2", layerStatusAlreadyExists, 0, 0)))
+	assert.Equal(t, apicontainer.PullProgress{Layers: 2, LayersDone: 1, UpdatedAt: now}, tracker.progress)
+
+	now = now.Add(time.Second)
+	assert.True(t, tracker.update(pullResponse("layer1", layerStatusDownloading, 100, 400)))
+	assert.Equal(t, apicontainer.PullProgress{
+		Layers:          2,
+		LayersDone:      1,
+		BytesDownloaded: 100,
+		BytesTotal:      400,
+		UpdatedAt:       now,
+	}, tracker.progress)
+
+	// A repeated message doesn't make progress nor move the update time
+	updatedAt := now
+	now = now.Add(time.Second)
+	assert.False(t, tracker.update(pullResponse("layer1", layerStatusDownloading, 100, 400)))
+	assert.Equal(t, updatedAt, tracker.progress.UpdatedAt)
+
+	assert.True(t, tracker.update(pullResponse("layer1", layerStatusDownloadComplete, 0, 0)))
+	assert.EqualValues(t, 400, tracker.progress.BytesDownloaded)
+	assert.Equal(t, 1, tracker.progress.LayersDone)
+
+	assert.False(t, tracker.update(pullResponse("layer1", layerStatusExtracting, 200, 400)))
+	assert.True(t, tracker.update(pullResponse("layer1", layerStatusPullComplete, 0, 0)))
+	assert.Equal(t, apicontainer.PullProgress{
+		Layers:          2,
+		LayersDone:      2,
+		BytesDownloaded: 400,
+		BytesTotal:      400,
+		UpdatedAt:       now,
+	}, tracker.progress)
+}
+
+func TestPullProgressReporterFromContext(t *testing.T) {
+	_, ok := PullProgressReporterFromContext(context.TODO())
+	assert.False(t, ok)
+
+	_, ok = PullProgressReporterFromContext(WithPullProgressReporter(context.TODO(), nil))
+	assert.False(t, ok)
+
+	var reported []apicontainer.PullProgress
+	reporter, ok := PullProgressReporterFromContext(WithPullProgressReporter(context.TODO(),
+		func(progress apicontainer.PullProgress) {
+			reported = append(reported, progress)
+		}))
+	assert.True(t, ok)
+	reporter(apicontainer.PullProgress{Layers: 1})
+	assert.Equal(t, []apicontainer.PullProgress{{Layers: 1}}, reported)
+}
@@ -54,6 +54,9 @@ type DockerContainerMetadata struct {
 	DockerID string
 	// ExitCode contains container's exit code if it has stopped
 	ExitCode *int
+	// OOMKilled is set if a process of the container was killed by the OOM
+	// killer, as reported by Docker or by an oom event for the container
+	OOMKilled bool
 	// PortBindings is the list of port binding information of the container
 	PortBindings []apicontainer.PortBinding
 	// Error wraps various container transition errors and is set if engine
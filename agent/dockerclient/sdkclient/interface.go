@@ -56,4 +56,5 @@ type Client interface {
 	VolumeRemove(ctx context.Context, volumeID string, force bool) error
 	ServerVersion(ctx context.Context) (types.Version, error)
 	Info(ctx context.Context) (types.Info, error)
+	DiskUsage(ctx context.Context) (types.DiskUsage, error)
 }
@@ -72,4 +72,8 @@ const (
 
 	// InfoTimeout is the timeout for the Info API
 	InfoTimeout = 10 * time.Second
+
+	// DiskUsageTimeout is the timeout for the DiskUsage API, for which the
+	// daemon sizes the writable layers of all the containers and the volumes
+	DiskUsageTimeout = 2 * time.Minute
 )
@@ -0,0 +1,143 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"io"
+	"path/filepath"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// containerArchiveDir is the directory under the data directory the
+	// containers are archived in
+	containerArchiveDir = "container-archive"
+	bytesPerMegabyte    = 1024 * 1024
+	// logNotArchivedNoDataRoot is why the logs of containers aren't archived
+	// when the path of the Docker data root isn't configured
+	logNotArchivedNoDataRoot = "ECS_DOCKER_DATA_ROOT isn't set to where the agent can read the Docker data root"
+)
+
+// newContainerArchive returns the store containers are archived in. The store
+// is created even if archiving is disabled, so that the containers archived
+// before can still be listed
+func newContainerArchive(cfg *config.Config) *containerarchive.Store {
+	return containerarchive.NewStore(filepath.Join(cfg.DataDir, containerArchiveDir),
+		int64(cfg.ContainerArchiveLogSizeMB)*bytesPerMegabyte,
+		int64(cfg.ContainerArchiveMaxSizeMB)*bytesPerMegabyte)
+}
+
+// archiveContainer saves the end of the log, the inspect output and the exit
+// metadata of the container before it's removed. Containers created by the
+// agent aren't archived
+func (engine *DockerTaskEngine) archiveContainer(task *apitask.Task, container *apicontainer.Container) {
+	if !engine.cfg.ContainerArchiveEnabled || container.IsInternal() {
+		return
+	}
+	taskID, err := task.GetID()
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: unable to archive container [%s]: %v", task.Arn, container.Name, err)
+		return
+	}
+	containerMap, ok := engine.state.ContainerMapByArn(task.Arn)
+	if !ok {
+		return
+	}
+	dockerContainer, ok := containerMap[container.Name]
+	if !ok || dockerContainer.DockerID == "" {
+		// The container was never created, there's nothing to archive
+		return
+	}
+
+	archived := containerarchive.Container{
+		Exit: newContainerExitMetadata(task, container, dockerContainer.DockerID),
+	}
+	archived.Exit.ArchivedAt = engine.time().Now()
+	inspected, err := engine.client.InspectContainer(engine.ctx, dockerContainer.DockerID,
+		dockerclient.InspectContainerTimeout)
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: unable to inspect container [%s] to archive it: %v",
+			task.Arn, container.Name, err)
+	} else {
+		archived.Inspect = inspected
+		if engine.cfg.DockerDataRoot != "" {
+			// The log path reported by Docker is where the log is on the host,
+			// which may not be where the agent can read it
+			archived.LogPath = filepath.Join(engine.cfg.DockerDataRoot, "containers",
+				dockerContainer.DockerID, dockerContainer.DockerID+"-json.log")
+		} else {
+			archived.Exit.LogNotArchived = logNotArchivedNoDataRoot
+		}
+	}
+
+	if err := engine.containerArchive.Archive(taskID, archived); err != nil {
+		seelog.Warnf("Task engine [%s]: unable to archive container [%s]: %v", task.Arn, container.Name, err)
+		return
+	}
+	seelog.Infof("Task engine [%s]: archived container [%s]", task.Arn, container.Name)
+}
+
+// pruneContainerArchive removes the oldest archived tasks once the archive
+// grows past its maximum size
+func (engine *DockerTaskEngine) pruneContainerArchive() {
+	if !engine.cfg.ContainerArchiveEnabled {
+		return
+	}
+	if err := engine.containerArchive.Prune(); err != nil {
+		seelog.Warnf("Task engine: unable to prune the container archive: %v", err)
+	}
+}
+
+func newContainerExitMetadata(task *apitask.Task, container *apicontainer.Container,
+	dockerID string) containerarchive.ExitMetadata {
+	exit := containerarchive.ExitMetadata{
+		TaskARN:       task.Arn,
+		ContainerName: container.Name,
+		DockerID:      dockerID,
+		Image:         container.Image,
+		KnownStatus:   container.GetKnownStatus().String(),
+		ExitCode:      container.GetKnownExitCode(),
+	}
+	if container.ApplyingError != nil {
+		exit.Reason = container.ApplyingError.Error()
+	}
+	if startedAt := container.GetStartedAt(); !startedAt.IsZero() {
+		exit.StartedAt = &startedAt
+	}
+	if finishedAt := container.GetFinishedAt(); !finishedAt.IsZero() {
+		exit.FinishedAt = &finishedAt
+	}
+	return exit
+}
+
+// ListContainerArchives returns the tasks whose containers were archived at
+// task cleanup, from the most recently archived
+func (engine *DockerTaskEngine) ListContainerArchives() ([]containerarchive.TaskArchive, error) {
+	return engine.containerArchive.List()
+}
+
+// OpenContainerArchiveFile opens a file archived for the containers of a task
+func (engine *DockerTaskEngine) OpenContainerArchiveFile(taskID, name string) (io.ReadCloser, error) {
+	file, err := engine.containerArchive.Open(taskID, name)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
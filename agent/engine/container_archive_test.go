@@ -0,0 +1,231 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	mock_dockerapi "github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
+	mock_dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate/mocks"
+	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/testdata"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testArchivedTaskID = "12345678-90ab-cdef-1234-56780abcdef1"
+
+func newContainerArchiveTestEngine(t *testing.T, ctrl *gomock.Controller,
+	archiveEnabled bool) (*DockerTaskEngine, *mock_dockerstate.MockTaskEngineState, *mock_dockerapi.MockDockerClient, func()) {
+	dataDir, err := ioutil.TempDir("", "containerarchive")
+	require.NoError(t, err)
+	cfg := getTestConfig()
+	cfg.DataDir = dataDir
+	cfg.ContainerArchiveEnabled = archiveEnabled
+	cfg.ContainerArchiveLogSizeMB = config.DefaultContainerArchiveLogSizeMB
+	cfg.ContainerArchiveMaxSizeMB = config.DefaultContainerArchiveMaxSizeMB
+
+	mockState := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	mockClient := mock_dockerapi.NewMockDockerClient(ctrl)
+	mockImageManager := mock_engine.NewMockImageManager(ctrl)
+	mockImageManager.EXPECT().RemoveContainerReferenceFromImageState(gomock.Any()).Return(nil).AnyTimes()
+	taskEngine := &DockerTaskEngine{
+		ctx:              context.TODO(),
+		cfg:              &cfg,
+		saver:            statemanager.NewNoopStateManager(),
+		state:            mockState,
+		client:           mockClient,
+		imageManager:     mockImageManager,
+		containerArchive: newContainerArchive(&cfg),
+	}
+	return taskEngine, mockState, mockClient, func() { os.RemoveAll(dataDir) }
+}
+
+func TestSweepTaskArchivesContainers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine, mockState, mockClient, cleanup := newContainerArchiveTestEngine(t, ctrl, true)
+	defer cleanup()
+
+	task := testdata.LoadTask("sleep5")
+	container := task.Containers[0]
+	exitCode := 1
+	container.SetKnownExitCode(&exitCode)
+	container.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   "dockerid",
+		DockerName: "dockername",
+	}
+	taskEngine.cfg.DockerDataRoot = filepath.Join(taskEngine.cfg.DataDir, "docker")
+	logDir := filepath.Join(taskEngine.cfg.DockerDataRoot, "containers", "dockerid")
+	require.NoError(t, os.MkdirAll(logDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(logDir, "dockerid-json.log"), []byte("{\"log\":\"bye\\n\"}\n"), 0600))
+
+	mockState.EXPECT().ContainerMapByArn(task.Arn).Return(
+		map[string]*apicontainer.DockerContainer{container.Name: dockerContainer}, true).AnyTimes()
+	gomock.InOrder(
+		mockClient.EXPECT().InspectContainer(gomock.Any(), dockerContainer.DockerID, gomock.Any()).Return(
+			&types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:         dockerContainer.DockerID,
+					LogPath:    "/var/lib/docker/containers/dockerid/dockerid-json.log",
+					HostConfig: &dockercontainer.HostConfig{LogConfig: dockercontainer.LogConfig{Type: "json-file"}},
+				},
+			}, nil),
+		mockClient.EXPECT().RemoveContainer(gomock.Any(), dockerContainer.DockerName, gomock.Any()).Return(nil),
+	)
+
+	taskEngine.sweepTask(task)
+
+	archives, err := taskEngine.ListContainerArchives()
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	assert.Equal(t, testArchivedTaskID, archives[0].TaskID)
+	assert.Len(t, archives[0].Files, 3)
+
+	archivedLog, err := taskEngine.OpenContainerArchiveFile(testArchivedTaskID, container.Name+"-json.log")
+	require.NoError(t, err)
+	defer archivedLog.Close()
+	logData, err := ioutil.ReadAll(archivedLog)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"log\":\"bye\\n\"}\n", string(logData))
+}
+
+func TestSweepTaskArchivesContainersWithoutDockerDataRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine, mockState, mockClient, cleanup := newContainerArchiveTestEngine(t, ctrl, true)
+	defer cleanup()
+
+	task := testdata.LoadTask("sleep5")
+	container := task.Containers[0]
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   "dockerid",
+		DockerName: "dockername",
+	}
+	// The log is where Docker reports it is, but the agent may not see the
+	// host's filesystem there
+	logPath := filepath.Join(taskEngine.cfg.DataDir, "dockerid-json.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte("{\"log\":\"bye\\n\"}\n"), 0600))
+	mockState.EXPECT().ContainerMapByArn(task.Arn).Return(
+		map[string]*apicontainer.DockerContainer{container.Name: dockerContainer}, true).AnyTimes()
+	mockClient.EXPECT().InspectContainer(gomock.Any(), dockerContainer.DockerID, gomock.Any()).Return(
+		&types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:         dockerContainer.DockerID,
+				LogPath:    logPath,
+				HostConfig: &dockercontainer.HostConfig{LogConfig: dockercontainer.LogConfig{Type: "json-file"}},
+			},
+		}, nil)
+	mockClient.EXPECT().RemoveContainer(gomock.Any(), dockerContainer.DockerName, gomock.Any()).Return(nil)
+
+	taskEngine.sweepTask(task)
+
+	archives, err := taskEngine.ListContainerArchives()
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	assert.Len(t, archives[0].Files, 2, "the log should not be archived")
+	exitFile, err := taskEngine.OpenContainerArchiveFile(testArchivedTaskID, container.Name+"-exit.json")
+	require.NoError(t, err)
+	defer exitFile.Close()
+	var exit containerarchive.ExitMetadata
+	require.NoError(t, json.NewDecoder(exitFile).Decode(&exit))
+	assert.Equal(t, logNotArchivedNoDataRoot, exit.LogNotArchived)
+}
+
+func TestSweepTaskArchivesContainersWhenInspectFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine, mockState, mockClient, cleanup := newContainerArchiveTestEngine(t, ctrl, true)
+	defer cleanup()
+
+	task := testdata.LoadTask("sleep5")
+	container := task.Containers[0]
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   "dockerid",
+		DockerName: "dockername",
+	}
+	mockState.EXPECT().ContainerMapByArn(task.Arn).Return(
+		map[string]*apicontainer.DockerContainer{container.Name: dockerContainer}, true).AnyTimes()
+	mockClient.EXPECT().InspectContainer(gomock.Any(), dockerContainer.DockerID, gomock.Any()).Return(
+		nil, errors.New("no such container"))
+	mockClient.EXPECT().RemoveContainer(gomock.Any(), dockerContainer.DockerName, gomock.Any()).Return(nil)
+
+	taskEngine.sweepTask(task)
+
+	archives, err := taskEngine.ListContainerArchives()
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	require.Len(t, archives[0].Files, 1, "only the exit metadata should be archived")
+	assert.Equal(t, container.Name+"-exit.json", archives[0].Files[0].Name)
+}
+
+func TestSweepTaskArchiveDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine, mockState, mockClient, cleanup := newContainerArchiveTestEngine(t, ctrl, false)
+	defer cleanup()
+
+	task := testdata.LoadTask("sleep5")
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   "dockerid",
+		DockerName: "dockername",
+	}
+	mockState.EXPECT().ContainerMapByArn(task.Arn).Return(
+		map[string]*apicontainer.DockerContainer{task.Containers[0].Name: dockerContainer}, true)
+	mockClient.EXPECT().RemoveContainer(gomock.Any(), dockerContainer.DockerName, gomock.Any()).Return(nil)
+
+	taskEngine.sweepTask(task)
+
+	archives, err := taskEngine.ListContainerArchives()
+	require.NoError(t, err)
+	assert.Empty(t, archives)
+}
+
+func TestNewContainerExitMetadata(t *testing.T) {
+	task := testdata.LoadTask("sleep5")
+	container := task.Containers[0]
+	exitCode := 137
+	container.SetKnownExitCode(&exitCode)
+	container.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	startedAt := time.Now().Add(-time.Minute)
+	container.SetStartedAt(startedAt)
+
+	exit := newContainerExitMetadata(task, container, "dockerid")
+	assert.Equal(t, task.Arn, exit.TaskARN)
+	assert.Equal(t, "dockerid", exit.DockerID)
+	assert.Equal(t, apicontainerstatus.ContainerStopped.String(), exit.KnownStatus)
+	require.NotNil(t, exit.ExitCode)
+	assert.Equal(t, 137, *exit.ExitCode)
+	require.NotNil(t, exit.StartedAt)
+	assert.True(t, startedAt.Equal(*exit.StartedAt))
+	assert.Nil(t, exit.FinishedAt)
+}
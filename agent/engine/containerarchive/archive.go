@@ -0,0 +1,321 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package containerarchive keeps the end of the logs, the inspect output and the
+// exit metadata of the containers of a task after the containers are removed at
+// task cleanup, in one directory per task.
+package containerarchive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// jsonFileLogDriver is the only log driver whose logs are archived, as it's
+	// the only one whose logs are kept with the container
+	jsonFileLogDriver = "json-file"
+
+	inspectFileSuffix = "-inspect.json"
+	exitFileSuffix    = "-exit.json"
+	logFileSuffix     = "-json.log"
+	// redactedValue replaces the values of the environment variables and log
+	// driver options in the archived inspect output
+	redactedValue = "REDACTED"
+
+	dirMode  = 0700
+	fileMode = 0600
+)
+
+// ErrInvalidName is returned when the task ID or the file name of an archived
+// file isn't a plain file name
+var ErrInvalidName = errors.New("containerarchive: invalid name")
+
+// ExitMetadata is what the agent knows about how a container exited
+type ExitMetadata struct {
+	TaskARN       string
+	ContainerName string
+	DockerID      string
+	Image         string
+	KnownStatus   string
+	ExitCode      *int       `json:",omitempty"`
+	Reason        string     `json:",omitempty"`
+	StartedAt     *time.Time `json:",omitempty"`
+	FinishedAt    *time.Time `json:",omitempty"`
+	ArchivedAt    time.Time
+	// LogNotArchived is why the log of the container isn't archived, if it isn't
+	LogNotArchived string `json:",omitempty"`
+}
+
+// Container is what's archived for a container
+type Container struct {
+	Exit ExitMetadata
+	// Inspect is the inspect output of the container. It's nil if the container
+	// couldn't be inspected. The values of its environment variables and log
+	// driver options are redacted before it's archived.
+	Inspect *types.ContainerJSON
+	// LogPath is where the json-file log of the container can be read by the
+	// agent. The log path reported by Docker is where the log is on the host,
+	// which the agent can't read from when it runs in a container, so the log
+	// isn't archived if LogPath is empty.
+	LogPath string
+}
+
+// TaskArchive describes the archived containers of a task
+type TaskArchive struct {
+	TaskID     string
+	ArchivedAt time.Time
+	Size       int64
+	Files      []File
+}
+
+// File is a file of the archive of a task
+type File struct {
+	Name string
+	Size int64
+}
+
+// Store is the directory the containers of tasks are archived in. The store is
+// kept under a maximum size by removing the archives of the tasks archived first
+type Store struct {
+	dir         string
+	maxLogBytes int64
+	maxBytes    int64
+	lock        sync.Mutex
+}
+
+// NewStore returns a store in the directory, which is created when the first
+// container is archived. Only the last maxLogBytes of the log of each container
+// are archived
+func NewStore(dir string, maxLogBytes, maxBytes int64) *Store {
+	return &Store{
+		dir:         dir,
+		maxLogBytes: maxLogBytes,
+		maxBytes:    maxBytes,
+	}
+}
+
+// Archive saves the exit metadata, the inspect output and the end of the log of
+// the container in the directory of the task
+func (store *Store) Archive(taskID string, container Container) error {
+	if !isPlainName(taskID) || !isPlainName(container.Exit.ContainerName) {
+		return ErrInvalidName
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	taskDir := filepath.Join(store.dir, taskID)
+	if err := os.MkdirAll(taskDir, dirMode); err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to create the directory of task %s", taskID)
+	}
+	name := container.Exit.ContainerName
+	if container.Exit.LogNotArchived == "" {
+		container.Exit.LogNotArchived = logNotArchivedReason(container)
+	}
+	if err := writeJSON(filepath.Join(taskDir, name+exitFileSuffix), container.Exit); err != nil {
+		return err
+	}
+	if container.Inspect == nil {
+		return nil
+	}
+	if err := writeJSON(filepath.Join(taskDir, name+inspectFileSuffix), redactInspect(container.Inspect)); err != nil {
+		return err
+	}
+	if container.Exit.LogNotArchived != "" {
+		return nil
+	}
+	return store.archiveLog(container.LogPath, filepath.Join(taskDir, name+logFileSuffix))
+}
+
+// redactInspect returns a copy of the inspect output of a container without the
+// values of its environment variables and log driver options. The secrets from
+// Secrets Manager and SSM Parameter Store are injected into the environment of
+// containers and into the options of their log driver, and the archive is served
+// to anyone who can reach the introspection server.
+func redactInspect(inspect *types.ContainerJSON) *types.ContainerJSON {
+	redacted := *inspect
+	if inspect.Config != nil {
+		config := *inspect.Config
+		config.Env = make([]string, len(inspect.Config.Env))
+		for i, variable := range inspect.Config.Env {
+			name := strings.SplitN(variable, "=", 2)[0]
+			config.Env[i] = name + "=" + redactedValue
+		}
+		redacted.Config = &config
+	}
+	if inspect.ContainerJSONBase != nil && inspect.HostConfig != nil {
+		base := *inspect.ContainerJSONBase
+		hostConfig := *inspect.HostConfig
+		hostConfig.LogConfig.Config = make(map[string]string, len(inspect.HostConfig.LogConfig.Config))
+		for option := range inspect.HostConfig.LogConfig.Config {
+			hostConfig.LogConfig.Config[option] = redactedValue
+		}
+		base.HostConfig = &hostConfig
+		redacted.ContainerJSONBase = &base
+	}
+	return &redacted
+}
+
+// logNotArchivedReason returns why the log of the container can't be archived,
+// or an empty string if it can be
+func logNotArchivedReason(container Container) string {
+	switch {
+	case container.Inspect == nil:
+		return "the container couldn't be inspected"
+	case container.Inspect.HostConfig != nil && container.Inspect.HostConfig.LogConfig.Type != jsonFileLogDriver:
+		return fmt.Sprintf("the container logs with the %s log driver rather than %s",
+			container.Inspect.HostConfig.LogConfig.Type, jsonFileLogDriver)
+	case container.LogPath == "":
+		return "the path the log can be read at isn't known"
+	}
+	return ""
+}
+
+// archiveLog copies the last lines of the log that fit in maxLogBytes
+func (store *Store) archiveLog(logPath, archivePath string) error {
+	logFile, err := os.Open(logPath)
+	if err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to open log %s", logPath)
+	}
+	defer logFile.Close()
+	info, err := logFile.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to stat log %s", logPath)
+	}
+
+	reader := bufio.NewReader(logFile)
+	if offset := info.Size() - store.maxLogBytes; offset > 0 {
+		if _, err := logFile.Seek(offset, io.SeekStart); err != nil {
+			return errors.Wrapf(err, "containerarchive: unable to seek log %s", logPath)
+		}
+		// Skip the rest of the line the offset falls in, so that every archived
+		// line is a whole json-file log entry
+		if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+			return errors.Wrapf(err, "containerarchive: unable to read log %s", logPath)
+		}
+	}
+
+	archiveFile, err := os.OpenFile(archivePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to create %s", archivePath)
+	}
+	defer archiveFile.Close()
+	if _, err := io.Copy(archiveFile, io.LimitReader(reader, store.maxLogBytes)); err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to copy log %s", logPath)
+	}
+	return nil
+}
+
+// Prune removes the archives of the tasks archived first until the store is
+// under its maximum size. The archive of the task archived last is always kept
+func (store *Store) Prune() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	archives, err := store.list()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, archive := range archives {
+		size += archive.Size
+	}
+	// list returns the archives from the newest to the oldest
+	for i := len(archives) - 1; i > 0 && size > store.maxBytes; i-- {
+		if err := os.RemoveAll(filepath.Join(store.dir, archives[i].TaskID)); err != nil {
+			return errors.Wrapf(err, "containerarchive: unable to remove the archive of task %s", archives[i].TaskID)
+		}
+		size -= archives[i].Size
+	}
+	return nil
+}
+
+// List returns the archives of the tasks, from the newest to the oldest
+func (store *Store) List() ([]TaskArchive, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.list()
+}
+
+func (store *Store) list() ([]TaskArchive, error) {
+	taskDirs, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TaskArchive{}, nil
+		}
+		return nil, errors.Wrap(err, "containerarchive: unable to list the archived tasks")
+	}
+	archives := make([]TaskArchive, 0, len(taskDirs))
+	for _, taskDir := range taskDirs {
+		if !taskDir.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(store.dir, taskDir.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "containerarchive: unable to list the archive of task %s", taskDir.Name())
+		}
+		archive := TaskArchive{
+			TaskID:     taskDir.Name(),
+			ArchivedAt: taskDir.ModTime(),
+			Files:      make([]File, 0, len(files)),
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			archive.Files = append(archive.Files, File{Name: file.Name(), Size: file.Size()})
+			archive.Size += file.Size()
+		}
+		archives = append(archives, archive)
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].ArchivedAt.After(archives[j].ArchivedAt)
+	})
+	return archives, nil
+}
+
+// Open opens a file of the archive of a task
+func (store *Store) Open(taskID, name string) (*os.File, error) {
+	if !isPlainName(taskID) || !isPlainName(name) {
+		return nil, ErrInvalidName
+	}
+	return os.Open(filepath.Join(store.dir, taskID, name))
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to marshal %s", filepath.Base(path))
+	}
+	if err := ioutil.WriteFile(path, data, fileMode); err != nil {
+		return errors.Wrapf(err, "containerarchive: unable to write %s", path)
+	}
+	return nil
+}
+
+// isPlainName returns true if the name can't escape the directory it's joined to
+func isPlainName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
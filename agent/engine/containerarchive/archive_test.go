@@ -0,0 +1,249 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerarchive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, maxLogBytes, maxBytes int64) (*Store, string, func()) {
+	dir, err := ioutil.TempDir("", "containerarchive")
+	require.NoError(t, err)
+	return NewStore(filepath.Join(dir, "archive"), maxLogBytes, maxBytes), dir, func() { os.RemoveAll(dir) }
+}
+
+func newTestContainer(t *testing.T, dir, name, logDriver, log string) Container {
+	logPath := filepath.Join(dir, name+"-json.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(log), 0600))
+	exitCode := 137
+	return Container{
+		Exit: ExitMetadata{
+			TaskARN:       "arn:aws:ecs:us-west-2:1234567890:task/cluster/task1",
+			ContainerName: name,
+			DockerID:      "dockerid-" + name,
+			KnownStatus:   "STOPPED",
+			ExitCode:      &exitCode,
+			ArchivedAt:    time.Now(),
+		},
+		Inspect: &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:      "dockerid-" + name,
+				LogPath: logPath,
+				HostConfig: &container.HostConfig{
+					LogConfig: container.LogConfig{Type: logDriver},
+				},
+			},
+		},
+		LogPath: logPath,
+	}
+}
+
+// readTestExit reads the archived exit metadata of a container
+func readTestExit(t *testing.T, store *Store, taskID, name string) ExitMetadata {
+	exitFile, err := store.Open(taskID, name+"-exit.json")
+	require.NoError(t, err)
+	defer exitFile.Close()
+	var exit ExitMetadata
+	require.NoError(t, json.NewDecoder(exitFile).Decode(&exit))
+	return exit
+}
+
+func TestArchive(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	log := "{\"log\":\"line1\\n\"}\n{\"log\":\"line2\\n\"}\n"
+	require.NoError(t, store.Archive("task1", newTestContainer(t, dir, "web", jsonFileLogDriver, log)))
+
+	archives, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	assert.Equal(t, "task1", archives[0].TaskID)
+	var names []string
+	for _, file := range archives[0].Files {
+		names = append(names, file.Name)
+	}
+	assert.ElementsMatch(t, []string{"web-exit.json", "web-inspect.json", "web-json.log"}, names)
+
+	logFile, err := store.Open("task1", "web-json.log")
+	require.NoError(t, err)
+	defer logFile.Close()
+	archivedLog, err := ioutil.ReadAll(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, log, string(archivedLog))
+
+	exit := readTestExit(t, store, "task1", "web")
+	assert.Equal(t, "dockerid-web", exit.DockerID)
+	require.NotNil(t, exit.ExitCode)
+	assert.Equal(t, 137, *exit.ExitCode)
+	assert.Empty(t, exit.LogNotArchived)
+}
+
+func TestArchiveRedactsInspect(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	archived := newTestContainer(t, dir, "web", jsonFileLogDriver, "")
+	archived.Inspect.Config = &container.Config{
+		Image: "nginx",
+		Env:   []string{"DB_PASSWORD=hunter2", "API_KEY=s3cr3t=="},
+	}
+	archived.Inspect.HostConfig.LogConfig.Config = map[string]string{"splunk-token": "t0ken"}
+	require.NoError(t, store.Archive("task1", archived))
+
+	inspectFile, err := store.Open("task1", "web-inspect.json")
+	require.NoError(t, err)
+	defer inspectFile.Close()
+	data, err := ioutil.ReadAll(inspectFile)
+	require.NoError(t, err)
+	for _, secret := range []string{"hunter2", "s3cr3t", "t0ken"} {
+		assert.NotContains(t, string(data), secret)
+	}
+	var inspect types.ContainerJSON
+	require.NoError(t, json.Unmarshal(data, &inspect))
+	assert.Equal(t, "nginx", inspect.Config.Image)
+	assert.Equal(t, []string{"DB_PASSWORD=REDACTED", "API_KEY=REDACTED"}, inspect.Config.Env)
+	assert.Equal(t, map[string]string{"splunk-token": "REDACTED"}, inspect.HostConfig.LogConfig.Config)
+	assert.Equal(t, "hunter2", strings.TrimPrefix(archived.Inspect.Config.Env[0], "DB_PASSWORD="),
+		"The inspect output of the container should be left as is")
+}
+
+func TestArchiveKeepsEndOfLog(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 20, 1<<20)
+	defer cleanup()
+
+	log := "{\"log\":\"first\"}\n{\"log\":\"second\"}\n{\"log\":\"third\"}\n"
+	require.NoError(t, store.Archive("task1", newTestContainer(t, dir, "web", jsonFileLogDriver, log)))
+
+	archivedLog, err := ioutil.ReadFile(filepath.Join(dir, "archive", "task1", "web-json.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"log\":\"third\"}\n", string(archivedLog), "only whole lines at the end of the log should be archived")
+}
+
+func TestArchiveSkipsLogOfOtherDrivers(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	require.NoError(t, store.Archive("task1", newTestContainer(t, dir, "web", "awslogs", "line\n")))
+
+	_, err := os.Stat(filepath.Join(dir, "archive", "task1", "web-json.log"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "archive", "task1", "web-inspect.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, readTestExit(t, store, "task1", "web").LogNotArchived, "awslogs")
+}
+
+func TestArchiveWithoutLogPath(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	// The log path reported by Docker isn't read from
+	container := newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")
+	container.LogPath = ""
+	require.NoError(t, store.Archive("task1", container))
+
+	_, err := os.Stat(filepath.Join(dir, "archive", "task1", "web-json.log"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NotEmpty(t, readTestExit(t, store, "task1", "web").LogNotArchived)
+
+	container.Exit.LogNotArchived = "the data root isn't configured"
+	require.NoError(t, store.Archive("task1", container))
+	assert.Equal(t, "the data root isn't configured", readTestExit(t, store, "task1", "web").LogNotArchived)
+}
+
+func TestArchiveWithoutInspect(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	container := newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")
+	container.Inspect = nil
+	require.NoError(t, store.Archive("task1", container))
+
+	archives, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	require.Len(t, archives[0].Files, 1)
+	assert.Equal(t, "web-exit.json", archives[0].Files[0].Name)
+}
+
+func TestPrune(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1)
+	defer cleanup()
+
+	now := time.Now()
+	for i, taskID := range []string{"task1", "task2", "task3"} {
+		require.NoError(t, store.Archive(taskID, newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")))
+		archivedAt := now.Add(time.Duration(i-3) * time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "archive", taskID), archivedAt, archivedAt))
+	}
+
+	require.NoError(t, store.Prune())
+	archives, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, archives, 1, "the archive of the task archived last should be kept")
+	assert.Equal(t, "task3", archives[0].TaskID)
+}
+
+func TestPruneUnderMaxSize(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	for _, taskID := range []string{"task1", "task2"} {
+		require.NoError(t, store.Archive(taskID, newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")))
+	}
+
+	require.NoError(t, store.Prune())
+	archives, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, archives, 2)
+}
+
+func TestListEmptyStore(t *testing.T) {
+	store, _, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	archives, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, archives)
+}
+
+func TestInvalidNames(t *testing.T) {
+	store, dir, cleanup := newTestStore(t, 1024, 1<<20)
+	defer cleanup()
+
+	for _, name := range []string{"", ".", "..", "../task1", "task1/web-exit.json", `..\task1`} {
+		_, err := store.Open(name, "web-exit.json")
+		assert.Equal(t, ErrInvalidName, err, "task ID %q should be rejected", name)
+		_, err = store.Open("task1", name)
+		assert.Equal(t, ErrInvalidName, err, "file name %q should be rejected", name)
+		assert.Equal(t, ErrInvalidName, store.Archive(name, newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")))
+	}
+
+	container := newTestContainer(t, dir, "web", jsonFileLogDriver, "line\n")
+	container.Exit.ContainerName = strings.Repeat("../", 3)
+	assert.Equal(t, ErrInvalidName, store.Archive("task1", container))
+}
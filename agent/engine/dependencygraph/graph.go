@@ -15,6 +15,7 @@ package dependencygraph
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -105,10 +106,23 @@ func dependenciesCanBeResolved(target *apicontainer.Container, by []*apicontaine
 		nameMap[cont.Name] = cont
 	}
 
-	if _, err := verifyContainerOrderingStatusResolvable(target, nameMap, containerOrderingDependenciesCanResolve); err != nil {
+	if blockers := verifyContainerOrderingStatusResolvable(target, nameMap, containerOrderingDependenciesCanResolve); len(blockers) > 0 {
 		return false
 	}
-	return verifyStatusResolvable(target, nameMap, target.SteadyStateDependencies, onSteadyStateCanResolve)
+	return len(verifyStatusResolvable(target, nameMap, target.SteadyStateDependencies, onSteadyStateCanResolve)) == 0
+}
+
+// DependencyResolution is the outcome of evaluating the dependencies of a
+// container against the current known state of its task
+type DependencyResolution struct {
+	// Blockers are all the dependencies that keep the container from
+	// transitioning to its next known status
+	Blockers []apicontainer.TransitionBlocker
+	// BlockedOn is the container ordering dependency that the container is
+	// waiting on, if that is what blocks it
+	BlockedOn *apicontainer.DependsOn
+	// Err describes the first dependency that is not resolved
+	Err error
 }
 
 // DependenciesAreResolved validates that the `target` container can be
@@ -123,44 +137,56 @@ func DependenciesAreResolved(target *apicontainer.Container,
 	id string,
 	manager credentials.Manager,
 	resources []taskresource.TaskResource) (*apicontainer.DependsOn, error) {
-	if !executionCredentialsResolved(target, id, manager) {
-		return nil, CredentialsNotResolvedErr
+	resolution := ResolveDependencies(target, by, id, manager, resources)
+	return resolution.BlockedOn, resolution.Err
+}
+
+// ResolveDependencies evaluates all the dependencies of the `target` container
+// given the current known state of the containers in `by` and the task
+// resources, and reports every dependency that blocks its next transition.
+func ResolveDependencies(target *apicontainer.Container,
+	by []*apicontainer.Container,
+	id string,
+	manager credentials.Manager,
+	resources []taskresource.TaskResource) *DependencyResolution {
+	var blockers []apicontainer.TransitionBlocker
+	if blocker := executionCredentialsBlocker(target, id, manager); blocker != nil {
+		blockers = append(blockers, *blocker)
 	}
 
 	nameMap := make(map[string]*apicontainer.Container)
 	for _, cont := range by {
 		nameMap[cont.Name] = cont
 	}
-	neededVolumeContainers := make([]string, len(target.VolumesFrom))
-	for i, volume := range target.VolumesFrom {
-		neededVolumeContainers[i] = volume.SourceContainer
-	}
 
 	resourcesMap := make(map[string]taskresource.TaskResource)
 	for _, resource := range resources {
 		resourcesMap[resource.GetName()] = resource
 	}
 
-	if blocked, err := verifyContainerOrderingStatusResolvable(target, nameMap, containerOrderingDependenciesIsResolved); err != nil {
-		return blocked, err
-	}
-
-	if !verifyStatusResolvable(target, nameMap, target.SteadyStateDependencies, onSteadyStateIsResolved) {
-		return nil, DependentContainerNotResolvedErr
-	}
-	if err := verifyTransitionDependenciesResolved(target, nameMap, resourcesMap); err != nil {
-		return nil, err
-	}
+	blockers = append(blockers, verifyContainerOrderingStatusResolvable(target, nameMap, containerOrderingDependenciesIsResolved)...)
+	blockers = append(blockers, verifyStatusResolvable(target, nameMap, target.SteadyStateDependencies, onSteadyStateIsResolved)...)
+	blockers = append(blockers, verifyTransitionDependenciesResolved(target, nameMap, resourcesMap)...)
 
 	// If the target is desired terminal and isn't stopped, we should validate that it doesn't have any containers
 	// that are dependent on it that need to shut down first.
 	if target.DesiredTerminal() && !target.KnownTerminal() {
-		if err := verifyShutdownOrder(target, nameMap); err != nil {
-			return nil, err
-		}
+		blockers = append(blockers, verifyShutdownOrder(target, nameMap)...)
 	}
 
-	return nil, nil
+	resolution := &DependencyResolution{Blockers: blockers}
+	if len(blockers) == 0 {
+		return resolution
+	}
+	first := blockers[0]
+	resolution.Err = blockerError(target, first)
+	if isContainerOrderingBlocker(first) && !first.Unresolvable {
+		resolution.BlockedOn = &apicontainer.DependsOn{
+			ContainerName: first.Dependency,
+			Condition:     first.Condition,
+		}
+	}
+	return resolution
 }
 
 // TaskResourceDependenciesAreResolved validates that the `target` resource can be
@@ -210,62 +236,143 @@ func linksToContainerNames(links []string) []string {
 	return names
 }
 
-func executionCredentialsResolved(target *apicontainer.Container, id string, manager credentials.Manager) bool {
+// blockerError converts a blocker into the error that describes it. Blockers
+// that have a well known cause map to the corresponding sentinel error.
+func blockerError(target *apicontainer.Container, blocker apicontainer.TransitionBlocker) error {
+	switch blocker.Type {
+	case apicontainer.BlockerExecutionCredentials:
+		return CredentialsNotResolvedErr
+	case apicontainer.BlockerSteadyState:
+		return DependentContainerNotResolvedErr
+	case apicontainer.BlockerContainerDependency:
+		return ErrContainerDependencyNotResolved
+	case apicontainer.BlockerResourceDependency:
+		return ErrResourceDependencyNotResolved
+	default:
+		return errors.Errorf("dependency graph: container [%s] is blocked: %s", target.Name, blocker.Reason)
+	}
+}
+
+// blockersError returns the error describing the first of the blockers, or nil
+// if there are none
+func blockersError(target *apicontainer.Container, blockers []apicontainer.TransitionBlocker) error {
+	if len(blockers) == 0 {
+		return nil
+	}
+	return blockerError(target, blockers[0])
+}
+
+func isContainerOrderingBlocker(blocker apicontainer.TransitionBlocker) bool {
+	switch blocker.Type {
+	case apicontainer.BlockerDependsOn, apicontainer.BlockerLink, apicontainer.BlockerVolumesFrom:
+		return true
+	default:
+		return false
+	}
+}
+
+// containerOrderingBlockerType returns the kind of blocker for a dependsOn entry
+// of the target. Links and volumesFrom are translated into dependsOn entries when
+// the task is initialized, so they are told apart by the dependency name.
+func containerOrderingBlockerType(target *apicontainer.Container, dependency apicontainer.DependsOn) apicontainer.TransitionBlockerType {
+	for _, name := range linksToContainerNames(target.Links) {
+		if name == dependency.ContainerName {
+			return apicontainer.BlockerLink
+		}
+	}
+	for _, volume := range target.VolumesFrom {
+		if volume.SourceContainer == dependency.ContainerName {
+			return apicontainer.BlockerVolumesFrom
+		}
+	}
+	return apicontainer.BlockerDependsOn
+}
+
+func executionCredentialsBlocker(target *apicontainer.Container, id string, manager credentials.Manager) *apicontainer.TransitionBlocker {
 	if target.GetKnownStatus() >= apicontainerstatus.ContainerPulled ||
 		!target.ShouldPullWithExecutionRole() ||
 		target.GetDesiredStatus() >= apicontainerstatus.ContainerStopped {
-		return true
+		return nil
 	}
 
-	_, ok := manager.GetTaskCredentials(id)
-	return ok
+	if _, ok := manager.GetTaskCredentials(id); ok {
+		return nil
+	}
+	return &apicontainer.TransitionBlocker{
+		Type:   apicontainer.BlockerExecutionCredentials,
+		Reason: "waiting for the task execution role credentials to pull the image",
+	}
 }
 
 // verifyStatusResolvable validates that `target` can be resolved given that
 // target depends on `dependencies` (which are container names) and there are
 // `existingContainers` (map from name to container). The `resolves` function
-// passed should return true if the named container is resolved.
+// passed should return true if the named container is resolved. It returns
+// a blocker for every dependency that is not resolved.
 func verifyStatusResolvable(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container,
-	dependencies []string, resolves func(*apicontainer.Container, *apicontainer.Container) bool) bool {
+	dependencies []string, resolves func(*apicontainer.Container, *apicontainer.Container) bool) []apicontainer.TransitionBlocker {
 	targetGoal := target.GetDesiredStatus()
 	if targetGoal != target.GetSteadyStateStatus() && targetGoal != apicontainerstatus.ContainerCreated {
 		// A container can always stop, die, or reach whatever other state it
 		// wants regardless of what dependencies it has
-		return true
+		return nil
 	}
 
+	var blockers []apicontainer.TransitionBlocker
 	for _, dependency := range dependencies {
 		maybeResolves, exists := existingContainers[dependency]
 		if !exists {
-			return false
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:         apicontainer.BlockerSteadyState,
+				Dependency:   dependency,
+				Unresolvable: true,
+				Reason:       fmt.Sprintf("dependency container [%s] does not exist", dependency),
+			})
+			continue
 		}
 		if !resolves(target, maybeResolves) {
-			return false
+			steadyState := maybeResolves.GetSteadyStateStatus()
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:       apicontainer.BlockerSteadyState,
+				Dependency: dependency,
+				Condition:  steadyState.String(),
+				Reason:     fmt.Sprintf("waiting for container [%s] to reach %s", dependency, steadyState.String()),
+			})
 		}
 	}
-	return true
+	return blockers
 }
 
 // verifyContainerOrderingStatusResolvable validates that `target` can be resolved given that
 // the dependsOn containers are resolved and there are `existingContainers`
 // (map from name to container). The `resolves` function passed should return true if the named container is resolved.
-
+// It returns a blocker for every dependsOn container that is not resolved; blockers for
+// dependencies that can never be resolved are marked as unresolvable.
 func verifyContainerOrderingStatusResolvable(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container,
-	resolves func(*apicontainer.Container, *apicontainer.Container, string) bool) (*apicontainer.DependsOn, error) {
+	resolves func(*apicontainer.Container, *apicontainer.Container, string) bool) []apicontainer.TransitionBlocker {
 
 	targetGoal := target.GetDesiredStatus()
 	targetKnown := target.GetKnownStatus()
 	if targetGoal != target.GetSteadyStateStatus() && targetGoal != apicontainerstatus.ContainerCreated {
 		// A container can always stop, die, or reach whatever other state it
 		// wants regardless of what dependencies it has
-		return nil, nil
+		return nil
 	}
 
+	var blockers []apicontainer.TransitionBlocker
 	targetDependencies := target.GetDependsOn()
 	for _, dependency := range targetDependencies {
+		blocker := apicontainer.TransitionBlocker{
+			Type:       containerOrderingBlockerType(target, dependency),
+			Dependency: dependency.ContainerName,
+			Condition:  dependency.Condition,
+		}
 		dependencyContainer, ok := existingContainers[dependency.ContainerName]
 		if !ok {
-			return nil, fmt.Errorf("dependency graph: container ordering dependency [%v] for target [%v] does not exist.", dependencyContainer, target)
+			blocker.Unresolvable = true
+			blocker.Reason = fmt.Sprintf("dependency container [%s] does not exist", dependency.ContainerName)
+			blockers = append(blockers, blocker)
+			continue
 		}
 
 		// We want to check whether the dependency container has timed out only if target has not been created yet.
@@ -273,7 +380,11 @@ func verifyContainerOrderingStatusResolvable(target *apicontainer.Container, exi
 		// However, if dependency container has already stopped, then it cannot time out.
 		if targetKnown < apicontainerstatus.ContainerCreated && dependencyContainer.GetKnownStatus() != apicontainerstatus.ContainerStopped {
 			if hasDependencyTimedOut(dependencyContainer, dependency.Condition) {
-				return nil, fmt.Errorf("dependency graph: container ordering dependency [%v] for target [%v] has timed out.", dependencyContainer, target)
+				blocker.Unresolvable = true
+				blocker.Reason = fmt.Sprintf("dependency container [%s] did not reach condition %s within its start timeout of %s",
+					dependency.ContainerName, dependency.Condition, dependencyContainer.GetStartTimeout().String())
+				blockers = append(blockers, blocker)
+				continue
 			}
 		}
 
@@ -281,57 +392,88 @@ func verifyContainerOrderingStatusResolvable(target *apicontainer.Container, exi
 		// can then never progress to its desired state when the dependency condition is 'SUCCESS'
 		if dependency.Condition == successCondition && dependencyContainer.GetKnownStatus() == apicontainerstatus.ContainerStopped &&
 			!hasDependencyStoppedSuccessfully(dependencyContainer) {
-			return nil, fmt.Errorf("dependency graph: failed to resolve container ordering dependency [%v] for target [%v] as dependency did not exit successfully.", dependencyContainer, target)
+			blocker.Unresolvable = true
+			blocker.Reason = fmt.Sprintf("dependency container [%s] did not exit successfully", dependency.ContainerName)
+			if exitCode := dependencyContainer.GetKnownExitCode(); exitCode != nil {
+				blocker.Reason = fmt.Sprintf("%s (exit code %d)", blocker.Reason, *exitCode)
+			}
+			blockers = append(blockers, blocker)
+			continue
 		}
 
 		if !resolves(target, dependencyContainer, dependency.Condition) {
-			return &dependency, fmt.Errorf("dependency graph: failed to resolve the container ordering dependency [%v] for target [%v]", dependencyContainer, target)
+			blocker.Reason = fmt.Sprintf("waiting for container [%s] to reach condition %s",
+				dependency.ContainerName, dependency.Condition)
+			blockers = append(blockers, blocker)
 		}
 	}
-	return nil, nil
+	return blockers
 }
 
 func verifyTransitionDependenciesResolved(target *apicontainer.Container,
 	existingContainers map[string]*apicontainer.Container,
-	existingResources map[string]taskresource.TaskResource) error {
+	existingResources map[string]taskresource.TaskResource) []apicontainer.TransitionBlocker {
 
-	if !verifyContainerDependenciesResolved(target, existingContainers) {
-		return ErrContainerDependencyNotResolved
-	}
-	if !verifyResourceDependenciesResolved(target, existingResources) {
-		return ErrResourceDependencyNotResolved
-	}
-	return nil
+	blockers := verifyContainerDependenciesResolved(target, existingContainers)
+	return append(blockers, verifyResourceDependenciesResolved(target, existingResources)...)
 }
 
-func verifyContainerDependenciesResolved(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container) bool {
+func verifyContainerDependenciesResolved(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container) []apicontainer.TransitionBlocker {
+	var blockers []apicontainer.TransitionBlocker
 	targetNext := target.GetNextKnownStateProgression()
 	containerDependencies := target.TransitionDependenciesMap[targetNext].ContainerDependencies
 	for _, containerDependency := range containerDependencies {
 		dep, exists := existingContainers[containerDependency.ContainerName]
 		if !exists {
-			return false
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:         apicontainer.BlockerContainerDependency,
+				Dependency:   containerDependency.ContainerName,
+				Condition:    containerDependency.SatisfiedStatus.String(),
+				Unresolvable: true,
+				Reason:       fmt.Sprintf("dependency container [%s] does not exist", containerDependency.ContainerName),
+			})
+			continue
 		}
 		if dep.GetKnownStatus() < containerDependency.SatisfiedStatus {
-			return false
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:       apicontainer.BlockerContainerDependency,
+				Dependency: containerDependency.ContainerName,
+				Condition:  containerDependency.SatisfiedStatus.String(),
+				Reason: fmt.Sprintf("waiting for container [%s] to reach %s before moving to %s",
+					containerDependency.ContainerName, containerDependency.SatisfiedStatus.String(), targetNext.String()),
+			})
 		}
 	}
-	return true
+	return blockers
 }
 
-func verifyResourceDependenciesResolved(target *apicontainer.Container, existingResources map[string]taskresource.TaskResource) bool {
+func verifyResourceDependenciesResolved(target *apicontainer.Container, existingResources map[string]taskresource.TaskResource) []apicontainer.TransitionBlocker {
+	var blockers []apicontainer.TransitionBlocker
 	targetNext := target.GetNextKnownStateProgression()
 	resourceDependencies := target.TransitionDependenciesMap[targetNext].ResourceDependencies
 	for _, resourceDependency := range resourceDependencies {
 		dep, exists := existingResources[resourceDependency.Name]
 		if !exists {
-			return false
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:         apicontainer.BlockerResourceDependency,
+				Dependency:   resourceDependency.Name,
+				Unresolvable: true,
+				Reason:       fmt.Sprintf("task resource [%s] does not exist", resourceDependency.Name),
+			})
+			continue
 		}
 		if dep.GetKnownStatus() < resourceDependency.GetRequiredStatus() {
-			return false
+			requiredStatus := dep.StatusString(resourceDependency.GetRequiredStatus())
+			blockers = append(blockers, apicontainer.TransitionBlocker{
+				Type:       apicontainer.BlockerResourceDependency,
+				Dependency: resourceDependency.Name,
+				Condition:  requiredStatus,
+				Reason: fmt.Sprintf("waiting for task resource [%s] to reach %s before moving to %s",
+					resourceDependency.Name, requiredStatus, targetNext.String()),
+			})
 		}
 	}
-	return true
+	return blockers
 }
 
 func containerOrderingDependenciesCanResolve(target *apicontainer.Container,
@@ -457,10 +599,10 @@ func verifyContainerOrderingStatus(dependsOnContainer *apicontainer.Container) b
 		dependsOnContainerDesiredStatus == dependsOnContainer.GetSteadyStateStatus()
 }
 
-func verifyShutdownOrder(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container) error {
+func verifyShutdownOrder(target *apicontainer.Container, existingContainers map[string]*apicontainer.Container) []apicontainer.TransitionBlocker {
 	// We considered adding this to the task state, but this will be at most 45 loops,
 	// so we err'd on the side of having less state.
-	missingShutdownDependencies := []string{}
+	var blockers []apicontainer.TransitionBlocker
 
 	for _, existingContainer := range existingContainers {
 		dependencies := existingContainer.GetDependsOn()
@@ -469,18 +611,22 @@ func verifyShutdownOrder(target *apicontainer.Container, existingContainers map[
 			// stopped.
 			if dependency.ContainerName == target.Name {
 				if !existingContainer.KnownTerminal() {
-					missingShutdownDependencies = append(missingShutdownDependencies, existingContainer.Name)
+					blockers = append(blockers, apicontainer.TransitionBlocker{
+						Type:       apicontainer.BlockerShutdownOrder,
+						Dependency: existingContainer.Name,
+						Condition:  apicontainerstatus.ContainerStopped.String(),
+						Reason:     fmt.Sprintf("waiting for dependent container [%s] to stop first", existingContainer.Name),
+					})
 				}
 			}
 		}
 	}
 
-	if len(missingShutdownDependencies) == 0 {
-		return nil
-	}
-
-	return fmt.Errorf("dependency graph: target %s needs other containers stopped before it can stop: [%s]",
-		target.Name, strings.Join(missingShutdownDependencies, "], ["))
+	// Iterating over the map yields the dependents in random order
+	sort.Slice(blockers, func(i, j int) bool {
+		return blockers[i].Dependency < blockers[j].Dependency
+	})
+	return blockers
 }
 
 func onSteadyStateCanResolve(target *apicontainer.Container, run *apicontainer.Container) bool {
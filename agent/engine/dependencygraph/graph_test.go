@@ -426,8 +426,8 @@ func TestVerifyTransitionDependenciesResolved(t *testing.T) {
 			}
 			containers := make(map[string]*apicontainer.Container)
 			containers[dep.Name] = dep
-			resolved := verifyTransitionDependenciesResolved(target, containers, nil)
-			assert.Equal(t, tc.ResolvedErr, resolved)
+			blockers := verifyTransitionDependenciesResolved(target, containers, nil)
+			assert.Equal(t, tc.ResolvedErr, blockersError(target, blockers))
 		})
 	}
 }
@@ -477,6 +477,7 @@ func TestVerifyResourceDependenciesResolved(t *testing.T) {
 				mockResource.EXPECT().SetKnownStatus(tc.DependencyKnown),
 				mockResource.EXPECT().GetKnownStatus().Return(tc.DependencyKnown).AnyTimes(),
 			)
+			mockResource.EXPECT().StatusString(tc.RequiredStatus).Return("REQUIRED").AnyTimes()
 			mockResource.SetKnownStatus(tc.DependencyKnown)
 			target := &apicontainer.Container{
 				KnownStatusUnsafe:         tc.TargetKnown,
@@ -485,8 +486,8 @@ func TestVerifyResourceDependenciesResolved(t *testing.T) {
 			target.BuildResourceDependency(resourceName, tc.RequiredStatus, tc.TargetDep)
 			resources := make(map[string]taskresource.TaskResource)
 			resources[resourceName] = mockResource
-			resolved := verifyResourceDependenciesResolved(target, resources)
-			assert.Equal(t, tc.ExpectedResolved, resolved)
+			blockers := verifyResourceDependenciesResolved(target, resources)
+			assert.Equal(t, tc.ExpectedResolved, len(blockers) == 0)
 		})
 	}
 }
@@ -533,6 +534,7 @@ func TestVerifyTransitionResourceDependenciesResolved(t *testing.T) {
 				mockResource.EXPECT().SetKnownStatus(tc.DependencyKnown),
 				mockResource.EXPECT().GetKnownStatus().Return(tc.DependencyKnown).AnyTimes(),
 			)
+			mockResource.EXPECT().StatusString(tc.RequiredStatus).Return("REQUIRED").AnyTimes()
 			mockResource.SetKnownStatus(tc.DependencyKnown)
 			target := &apicontainer.Container{
 				KnownStatusUnsafe:         tc.TargetKnown,
@@ -541,8 +543,8 @@ func TestVerifyTransitionResourceDependenciesResolved(t *testing.T) {
 			target.BuildResourceDependency(resourceName, tc.RequiredStatus, tc.TargetDep)
 			resources := make(map[string]taskresource.TaskResource)
 			resources[resourceName] = mockResource
-			resolved := verifyTransitionDependenciesResolved(target, nil, resources)
-			assert.Equal(t, tc.ResolvedErr, resolved)
+			blockers := verifyTransitionDependenciesResolved(target, nil, resources)
+			assert.Equal(t, tc.ResolvedErr, blockersError(target, blockers))
 		})
 	}
 }
@@ -565,8 +567,8 @@ func TestTransitionDependencyResourceNotFound(t *testing.T) {
 
 	resources := make(map[string]taskresource.TaskResource)
 	resources["resource1"] = mockResource // different resource name
-	resolved := verifyTransitionDependenciesResolved(target, nil, resources)
-	assert.Equal(t, ErrResourceDependencyNotResolved, resolved)
+	blockers := verifyTransitionDependenciesResolved(target, nil, resources)
+	assert.Equal(t, ErrResourceDependencyNotResolved, blockersError(target, blockers))
 }
 
 // TestVerifyTransitionDependenciesResolvedForResource verifies the logic of function TaskResourceDependenciesAreResolved
@@ -1117,9 +1119,9 @@ func TestVerifyShutdownOrder(t *testing.T) {
 
 			// Validation
 			if tc.ShouldResolve {
-				assert.NoError(t, verifyShutdownOrder(target, others))
+				assert.Empty(t, verifyShutdownOrder(target, others))
 			} else {
-				assert.Error(t, verifyShutdownOrder(target, others))
+				assert.NotEmpty(t, verifyShutdownOrder(target, others))
 			}
 		})
 	}
@@ -1171,3 +1173,95 @@ func assertContainerOrderingNotTimedOut(f func(dep *apicontainer.Container, depC
 		assert.Equal(t, expectedTimedOut, timedOut)
 	}
 }
+
+func TestResolveDependenciesReportsAllBlockers(t *testing.T) {
+	db := &apicontainer.Container{
+		Name:                "db",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerPulled,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	data := &apicontainer.Container{
+		Name:                "data",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerStatusNone,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	app := &apicontainer.Container{
+		Name:                "app",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		Links:               []string{"db:database"},
+		VolumesFrom:         volumeStrToVol([]string{"data"}),
+		DependsOnUnsafe: []apicontainer.DependsOn{
+			{ContainerName: "db", Condition: startCondition},
+			{ContainerName: "data", Condition: createCondition},
+		},
+	}
+	containers := []*apicontainer.Container{db, data, app}
+
+	resolution := ResolveDependencies(app, containers, "", nil, nil)
+	assert.Len(t, resolution.Blockers, 2)
+	assert.Equal(t, apicontainer.BlockerLink, resolution.Blockers[0].Type)
+	assert.Equal(t, "db", resolution.Blockers[0].Dependency)
+	assert.Equal(t, startCondition, resolution.Blockers[0].Condition)
+	assert.False(t, resolution.Blockers[0].Unresolvable)
+	assert.Equal(t, apicontainer.BlockerVolumesFrom, resolution.Blockers[1].Type)
+	assert.Equal(t, "data", resolution.Blockers[1].Dependency)
+	assert.Equal(t, &apicontainer.DependsOn{ContainerName: "db", Condition: startCondition}, resolution.BlockedOn)
+	assert.Error(t, resolution.Err)
+
+	db.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	data.SetKnownStatus(apicontainerstatus.ContainerCreated)
+	resolution = ResolveDependencies(app, containers, "", nil, nil)
+	assert.Empty(t, resolution.Blockers)
+	assert.Nil(t, resolution.BlockedOn)
+	assert.NoError(t, resolution.Err)
+}
+
+func TestResolveDependenciesTimedOutDependencyIsUnresolvable(t *testing.T) {
+	dep := &apicontainer.Container{
+		Name:                "dep",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		StartTimeout:        10,
+	}
+	dep.SetStartedAt(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	target := &apicontainer.Container{
+		Name:                "target",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		DependsOnUnsafe:     []apicontainer.DependsOn{{ContainerName: "dep", Condition: healthyCondition}},
+	}
+
+	resolution := ResolveDependencies(target, []*apicontainer.Container{dep, target}, "", nil, nil)
+	assert.Len(t, resolution.Blockers, 1)
+	blocker := resolution.Blockers[0]
+	assert.Equal(t, apicontainer.BlockerDependsOn, blocker.Type)
+	assert.True(t, blocker.Unresolvable)
+	assert.Contains(t, blocker.Reason, "dependency container [dep] did not reach condition HEALTHY")
+	assert.Nil(t, resolution.BlockedOn, "unresolvable dependencies should not be waited on")
+	assert.Contains(t, resolution.Err.Error(), blocker.Reason)
+}
+
+func TestResolveDependenciesShutdownOrder(t *testing.T) {
+	target := &apicontainer.Container{
+		Name:                "target",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+	}
+	dependents := []*apicontainer.Container{target}
+	for _, name := range []string{"b", "a"} {
+		dependents = append(dependents, &apicontainer.Container{
+			Name:                name,
+			KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+			DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+			DependsOnUnsafe:     []apicontainer.DependsOn{{ContainerName: "target", Condition: startCondition}},
+		})
+	}
+
+	resolution := ResolveDependencies(target, dependents, "", nil, nil)
+	assert.Len(t, resolution.Blockers, 2)
+	for i, name := range []string{"a", "b"} {
+		assert.Equal(t, apicontainer.BlockerShutdownOrder, resolution.Blockers[i].Type)
+		assert.Equal(t, name, resolution.Blockers[i].Dependency)
+	}
+	assert.Nil(t, resolution.BlockedOn)
+	assert.Error(t, resolution.Err)
+}
@@ -73,8 +73,8 @@ func TestVerifyCgroupDependenciesResolved(t *testing.T) {
 			target.BuildResourceDependency("cgroup", tc.RequiredStatus, tc.TargetDep)
 			resources := make(map[string]taskresource.TaskResource)
 			resources[cgroupResource.GetName()] = cgroupResource
-			resolved := verifyResourceDependenciesResolved(target, resources)
-			assert.Equal(t, tc.ExpectedResolved, resolved)
+			blockers := verifyResourceDependenciesResolved(target, resources)
+			assert.Equal(t, tc.ExpectedResolved, len(blockers) == 0)
 		})
 	}
 }
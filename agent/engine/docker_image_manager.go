@@ -19,6 +19,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -29,12 +30,19 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/cihub/seelog"
 )
 
 const (
 	imageNotFoundForDeletionError = "no such image"
+	// diskUsageCheckInterval is how often the usage of the filesystem of the
+	// Docker data root is checked against the high watermark
+	diskUsageCheckInterval = time.Minute
+
+	imageCleanupReasonInterval     = "interval"
+	imageCleanupReasonDiskPressure = "disk_pressure"
 )
 
 // ImageManager is responsible for saving the Image states,
@@ -45,6 +53,9 @@ type ImageManager interface {
 	AddAllImageStates(imageStates []*image.ImageState)
 	GetImageStateFromImageName(containerImageName string) (*image.ImageState, bool)
 	StartImageCleanupProcess(ctx context.Context)
+	StartImagePrewarm(ctx context.Context)
+	GetImagePrewarmStatus() []image.PrewarmStatus
+	GetImageCleanupPlan(ctx context.Context) image.CleanupPlan
 	SetSaver(stateManager statemanager.Saver)
 }
 
@@ -62,11 +73,34 @@ type dockerImageManager struct {
 	numImagesToDelete                  int
 	imageCleanupTimeInterval           time.Duration
 	imagePullBehavior                  config.ImagePullBehaviorType
+	imageCleanupDisabled               bool
 	imageCleanupExclusionList          []string
 	deleteNonECSImagesEnabled          bool
 	nonECSContainerCleanupWaitDuration time.Duration
 	numNonECSContainersToDelete        int
 	nonECSMinimumAgeBeforeDeletion     time.Duration
+	imagePrewarmList                   []config.ImagePrewarm
+	// imagePrewarmStatus is the status of each image of the pre-warm list,
+	// keyed by image name and guarded by prewarmLock
+	imagePrewarmStatus map[string]*image.PrewarmStatus
+	prewarmLock        sync.RWMutex
+	// protectedImages maps the names of the images that are never removed by
+	// the image cleanup to their image ids
+	protectedImages map[string]string
+	// diskHighWatermark and diskLowWatermark are the percentages of the Docker
+	// data root filesystem in use between which the cleanup started by disk
+	// pressure runs. Disk usage isn't watched if diskHighWatermark is 0
+	diskHighWatermark      int
+	diskLowWatermark       int
+	dockerDataRoot         string
+	diskUsageCheckInterval time.Duration
+	// filesystemUsage returns the usage of the filesystem of a path
+	filesystemUsage func(path string) (*filesystemUsage, error)
+	// cleanupRunning is set while a cleanup started by runImageCleanup runs
+	cleanupRunning int32
+	// reclaimedBytes is the size of the images removed by the running cleanup,
+	// guarded by updateLock
+	reclaimedBytes uint64
 }
 
 // ImageStatesForDeletion is used for implementing the sort interface
@@ -74,6 +108,18 @@ type ImageStatesForDeletion []*image.ImageState
 
 // NewImageManager returns a new ImageManager
 func NewImageManager(cfg *config.Config, client dockerapi.DockerClient, state dockerstate.TaskEngineState) ImageManager {
+	imagePrewarmStatus := make(map[string]*image.PrewarmStatus)
+	for _, prewarm := range cfg.ImagePrewarmList {
+		status := &image.PrewarmStatus{
+			Image:      prewarm.Image,
+			PullPolicy: string(prewarm.PullPolicy),
+			State:      image.PrewarmPending,
+		}
+		if prewarm.RefreshInterval != 0 {
+			status.RefreshInterval = prewarm.RefreshInterval.String()
+		}
+		imagePrewarmStatus[prewarm.Image] = status
+	}
 	return &dockerImageManager{
 		client:                             client,
 		state:                              state,
@@ -81,11 +127,20 @@ func NewImageManager(cfg *config.Config, client dockerapi.DockerClient, state do
 		numImagesToDelete:                  cfg.NumImagesToDeletePerCycle,
 		imageCleanupTimeInterval:           cfg.ImageCleanupInterval,
 		imagePullBehavior:                  cfg.ImagePullBehavior,
+		imageCleanupDisabled:               cfg.ImageCleanupDisabled,
 		imageCleanupExclusionList:          cfg.ImageCleanupExclusionList,
 		deleteNonECSImagesEnabled:          cfg.DeleteNonECSImagesEnabled,
 		nonECSContainerCleanupWaitDuration: cfg.TaskCleanupWaitDuration,
 		numNonECSContainersToDelete:        cfg.NumNonECSContainersToDeletePerCycle,
 		nonECSMinimumAgeBeforeDeletion:     cfg.NonECSMinimumImageDeletionAge,
+		imagePrewarmList:                   cfg.ImagePrewarmList,
+		imagePrewarmStatus:                 imagePrewarmStatus,
+		protectedImages:                    make(map[string]string),
+		diskHighWatermark:                  cfg.ImageCleanupDiskHighWatermark,
+		diskLowWatermark:                   cfg.ImageCleanupDiskLowWatermark,
+		dockerDataRoot:                     cfg.DockerDataRoot,
+		diskUsageCheckInterval:             diskUsageCheckInterval,
+		filesystemUsage:                    getFilesystemUsage,
 	}
 }
 
@@ -327,10 +382,21 @@ func (imageManager *dockerImageManager) StartImageCleanupProcess(ctx context.Con
 
 func (imageManager *dockerImageManager) performPeriodicImageCleanup(ctx context.Context, imageCleanupInterval time.Duration) {
 	imageManager.imageCleanupTicker = time.NewTicker(imageCleanupInterval)
+	// The disk usage check never fires unless disk usage is watched
+	var diskUsageCheck <-chan time.Time
+	if imageManager.diskHighWatermark > 0 && imageManager.diskUsageCheckInterval > 0 {
+		diskUsageCheckTicker := time.NewTicker(imageManager.diskUsageCheckInterval)
+		defer diskUsageCheckTicker.Stop()
+		diskUsageCheck = diskUsageCheckTicker.C
+	}
 	for {
 		select {
 		case <-imageManager.imageCleanupTicker.C:
-			go imageManager.removeUnusedImages(ctx)
+			go imageManager.runImageCleanup(ctx, imageCleanupReasonInterval, "")
+		case <-diskUsageCheck:
+			if dataRoot, ok := imageManager.checkDiskPressure(ctx); ok {
+				go imageManager.runImageCleanup(ctx, imageCleanupReasonDiskPressure, dataRoot)
+			}
 		case <-ctx.Done():
 			imageManager.imageCleanupTicker.Stop()
 			return
@@ -338,7 +404,66 @@ func (imageManager *dockerImageManager) performPeriodicImageCleanup(ctx context.
 	}
 }
 
-func (imageManager *dockerImageManager) removeUnusedImages(ctx context.Context) {
+// runImageCleanup runs the cleanup for the reason, unless a cleanup is already
+// running, and records the bytes it reclaimed
+func (imageManager *dockerImageManager) runImageCleanup(ctx context.Context, reason string, dataRoot string) {
+	if !atomic.CompareAndSwapInt32(&imageManager.cleanupRunning, 0, 1) {
+		seelog.Infof("Image cleanup (%s) skipped, a cleanup is already running", reason)
+		return
+	}
+	defer atomic.StoreInt32(&imageManager.cleanupRunning, 0)
+
+	var reclaimedBytes uint64
+	if reason == imageCleanupReasonDiskPressure {
+		reclaimedBytes = imageManager.removeUnusedImagesUnderDiskPressure(ctx, dataRoot)
+	} else {
+		reclaimedBytes = imageManager.removeUnusedImages(ctx)
+	}
+	seelog.Infof("Image cleanup (%s) reclaimed %d bytes", reason, reclaimedBytes)
+	metrics.MetricsEngineGlobal.RecordImageCleanup(reason, reclaimedBytes)
+}
+
+// checkDiskPressure returns the path of the Docker data root and true if the
+// usage of its filesystem is at or above the high watermark. The data root
+// reported by Docker is used unless it's configured
+func (imageManager *dockerImageManager) checkDiskPressure(ctx context.Context) (string, bool) {
+	if imageManager.dockerDataRoot == "" {
+		info, err := imageManager.client.Info(ctx, dockerclient.InfoTimeout)
+		if err != nil {
+			seelog.Warnf("Unable to get the Docker data root to check disk usage: %v", err)
+			return "", false
+		}
+		imageManager.dockerDataRoot = info.DockerRootDir
+	}
+	usage, err := imageManager.filesystemUsage(imageManager.dockerDataRoot)
+	if err != nil {
+		seelog.Warnf("Unable to check disk usage of the Docker data root %s: %v", imageManager.dockerDataRoot, err)
+		return "", false
+	}
+	metrics.MetricsEngineGlobal.RecordDockerDataRootUsage(usage.bytesUsedPercent(), usage.inodesUsedPercent())
+	if usage.usedPercent() < float64(imageManager.diskHighWatermark) {
+		return "", false
+	}
+	seelog.Infof("Docker data root %s is %.1f%% full, at or above the high watermark of %d%%",
+		imageManager.dockerDataRoot, usage.usedPercent(), imageManager.diskHighWatermark)
+	return imageManager.dockerDataRoot, true
+}
+
+// aboveLowWatermark returns true if the usage of the filesystem of the Docker
+// data root is at or above the low watermark
+func (imageManager *dockerImageManager) aboveLowWatermark(dataRoot string) bool {
+	usage, err := imageManager.filesystemUsage(dataRoot)
+	if err != nil {
+		seelog.Warnf("Unable to check disk usage of the Docker data root %s: %v", dataRoot, err)
+		return false
+	}
+	return usage.usedPercent() >= float64(imageManager.diskLowWatermark)
+}
+
+// removeUnusedImages removes up to numImagesToDelete of the least recently used
+// images, and non-ECS containers and images if enabled. It returns the size of
+// the images removed
+func (imageManager *dockerImageManager) removeUnusedImages(ctx context.Context) uint64 {
 	seelog.Debug("Attempting to obtain ImagePullDeleteLock for removing images")
 	ImagePullDeleteLock.Lock()
 	seelog.Debug("Obtained ImagePullDeleteLock for removing images")
@@ -348,6 +473,7 @@ func (imageManager *dockerImageManager) removeUnusedImages(ctx context.Context)
 	imageManager.updateLock.Lock()
 	defer imageManager.updateLock.Unlock()
 
+	imageManager.reclaimedBytes = 0
 	var numECSImagesDeleted int
 	imageManager.imageStatesConsideredForDeletion = imageManager.imagesConsiderForDeletion(imageManager.getAllImageStates())
 
@@ -366,9 +492,90 @@ func (imageManager *dockerImageManager) removeUnusedImages(ctx context.Context)
 		var nonECSImagesNumToDelete = imageManager.numImagesToDelete - numECSImagesDeleted
 		imageManager.removeNonECSImages(ctx, nonECSImagesNumToDelete)
 	}
+	return imageManager.reclaimedBytes
+}
+
+// removeUnusedImagesUnderDiskPressure removes the least recently used images,
+// then stopped non-ECS containers and non-ECS images if enabled, one at a time
+// until the usage of the filesystem of the Docker data root falls below the
+// low watermark. It returns the size of the images removed
+func (imageManager *dockerImageManager) removeUnusedImagesUnderDiskPressure(ctx context.Context, dataRoot string) uint64 {
+	seelog.Debug("Attempting to obtain ImagePullDeleteLock for removing images under disk pressure")
+	ImagePullDeleteLock.Lock()
+	seelog.Debug("Obtained ImagePullDeleteLock for removing images under disk pressure")
+	defer seelog.Debug("Released ImagePullDeleteLock after removing images under disk pressure")
+	defer ImagePullDeleteLock.Unlock()
+
+	imageManager.updateLock.Lock()
+	defer imageManager.updateLock.Unlock()
+
+	imageManager.reclaimedBytes = 0
+	imageManager.imageStatesConsideredForDeletion = imageManager.imagesConsiderForDeletion(imageManager.getAllImageStates())
+	for imageManager.aboveLowWatermark(dataRoot) {
+		if err := imageManager.removeLeastRecentlyUsedImage(ctx); err != nil {
+			seelog.Infof("End of eligible images for deletion under disk pressure: %v", err)
+			break
+		}
+	}
+	if imageManager.deleteNonECSImagesEnabled {
+		imageManager.removeNonECSUnderDiskPressure(ctx, dataRoot)
+	}
+	if imageManager.aboveLowWatermark(dataRoot) {
+		seelog.Warnf("Docker data root %s is still at or above the low watermark of %d%% after removing all eligible images",
+			dataRoot, imageManager.diskLowWatermark)
+	}
+	return imageManager.reclaimedBytes
+}
+
+// removeNonECSUnderDiskPressure removes stopped non-ECS containers, then non-ECS
+// images, until the usage of the filesystem of the Docker data root falls below
+// the low watermark. The limits on how many are removed per cycle don't apply
+func (imageManager *dockerImageManager) removeNonECSUnderDiskPressure(ctx context.Context, dataRoot string) {
+	if !imageManager.aboveLowWatermark(dataRoot) {
+		return
+	}
+	for _, id := range imageManager.getRemovableNonECSContainerIDs(ctx) {
+		imageManager.removeNonECSContainer(ctx, id)
+		if !imageManager.aboveLowWatermark(dataRoot) {
+			return
+		}
+	}
+	for _, image := range imageManager.getRemovableNonECSImages(ctx) {
+		imageManager.removeNonECSImage(ctx, image)
+		if !imageManager.aboveLowWatermark(dataRoot) {
+			return
+		}
+	}
 }
 
 func (imageManager *dockerImageManager) removeNonECSContainers(ctx context.Context) {
+	var numNonECSContainerDeleted = 0
+	for _, id := range imageManager.getRemovableNonECSContainerIDs(ctx) {
+		if numNonECSContainerDeleted == imageManager.numNonECSContainersToDelete {
+			break
+		}
+		if imageManager.removeNonECSContainer(ctx, id) {
+			numNonECSContainerDeleted++
+		}
+	}
+}
+
+// removeNonECSContainer removes the non-ECS container and returns true if it
+// was removed
+func (imageManager *dockerImageManager) removeNonECSContainer(ctx context.Context, id string) bool {
+	seelog.Debugf("Removing non-ECS Container ID %s", id)
+	err := imageManager.client.RemoveContainer(ctx, id, dockerclient.RemoveContainerTimeout)
+	if err != nil {
+		seelog.Errorf("Error Removing Container ID %s - %s", id, err)
+		return false
+	}
+	seelog.Infof("Removed Container ID: %s", id)
+	return true
+}
+
+// getRemovableNonECSContainerIDs returns the ids of the non-ECS containers that
+// have been stopped for longer than the cleanup wait duration
+func (imageManager *dockerImageManager) getRemovableNonECSContainerIDs(ctx context.Context) []string {
 	nonECSContainersIDs, err := imageManager.getNonECSContainerIDs(ctx)
 	if err != nil {
 		seelog.Errorf("Error getting non-ECS container IDs: %v", err)
@@ -395,21 +602,7 @@ func (imageManager *dockerImageManager) removeNonECSContainers(ctx context.Conte
 			nonECSContainerRemoveAvailableIDs = append(nonECSContainerRemoveAvailableIDs, id)
 		}
 	}
-	var numNonECSContainerDeleted = 0
-	for _, id := range nonECSContainerRemoveAvailableIDs {
-		if numNonECSContainerDeleted == imageManager.numNonECSContainersToDelete {
-			break
-		}
-		seelog.Debugf("Removing non-ECS Container ID %s", id)
-		err := imageManager.client.RemoveContainer(ctx, id, dockerclient.RemoveContainerTimeout)
-		if err == nil {
-			seelog.Infof("Removed Container ID: %s", id)
-			numNonECSContainerDeleted++
-		} else {
-			seelog.Errorf("Error Removing Container ID %s - %s", id, err)
-			continue
-		}
-	}
+	return nonECSContainerRemoveAvailableIDs
 }
 
 func (imageManager *dockerImageManager) getNonECSContainerIDs(ctx context.Context) ([]string, error) {
@@ -437,49 +630,86 @@ func (imageManager *dockerImageManager) removeNonECSImages(ctx context.Context,
 	if nonECSImagesNumToDelete == 0 {
 		return
 	}
-	nonECSImages := imageManager.getNonECSImages(ctx)
-
-	// we want to sort images with size ascending
-	sort.Slice(nonECSImages, func(i, j int) bool {
-		return nonECSImages[i].Size < nonECSImages[j].Size
-	})
-
 	// we will remove the remaining nonECSImages in each performPeriodicImageCleanup call()
 	var numImagesAlreadyDeleted = 0
-	for _, image := range nonECSImages {
+	for _, image := range imageManager.getRemovableNonECSImages(ctx) {
 		if numImagesAlreadyDeleted >= nonECSImagesNumToDelete {
 			break
 		}
+		numImagesAlreadyDeleted += imageManager.removeNonECSImage(ctx, image)
+	}
+}
+
+// getRemovableNonECSImages returns the non-ECS images that are old enough to be
+// removed, smallest first
+func (imageManager *dockerImageManager) getRemovableNonECSImages(ctx context.Context) []ImageWithSizeID {
+	var removableImages []ImageWithSizeID
+	for _, image := range imageManager.getNonECSImages(ctx) {
 		// use current time - image creation time to determine if image is old enough to be deleted.
-		if !imageManager.nonECSImageOldEnough(image) {
-			continue
+		if imageManager.nonECSImageOldEnough(image) {
+			removableImages = append(removableImages, image)
 		}
-		if len(image.RepoTags) > 1 {
-			seelog.Debugf("Non-ECS image has more than one tag Image: %s (Tags: %s)", image.ImageID, image.RepoTags)
-			for _, tag := range image.RepoTags {
-				err := imageManager.client.RemoveImage(ctx, tag, dockerclient.RemoveImageTimeout)
-				if err != nil {
-					seelog.Errorf("Error removing RepoTag (ImageID: %s, Tag: %s) %v", image.ImageID, tag, err)
-				} else {
-					seelog.Infof("Image Tag Removed: %s (ImageID: %s)", tag, image.ImageID)
-					numImagesAlreadyDeleted++
-				}
-			}
+	}
+
+	// we want to sort images with size ascending
+	sort.Slice(removableImages, func(i, j int) bool {
+		return removableImages[i].Size < removableImages[j].Size
+	})
+	return removableImages
+}
+
+// removeNonECSImage removes the non-ECS image, by tag if it has more than one.
+// It returns the number of images and tags removed
+func (imageManager *dockerImageManager) removeNonECSImage(ctx context.Context, image ImageWithSizeID) int {
+	if len(image.RepoTags) <= 1 {
+		seelog.Debugf("Removing non-ECS Image: %s (Tags: %s)", image.ImageID, image.RepoTags)
+		err := imageManager.client.RemoveImage(ctx, image.ImageID, dockerclient.RemoveImageTimeout)
+		if err != nil {
+			seelog.Errorf("Error removing Image %s (Tags: %s) - %v", image.ImageID, image.RepoTags, err)
+			return 0
+		}
+		seelog.Infof("Image removed: %s (Tags: %s)", image.ImageID, image.RepoTags)
+		imageManager.reclaimedBytes += uint64(image.Size)
+		return 1
+	}
+
+	seelog.Debugf("Non-ECS image has more than one tag Image: %s (Tags: %s)", image.ImageID, image.RepoTags)
+	var numTagsRemoved int
+	for _, tag := range image.RepoTags {
+		err := imageManager.client.RemoveImage(ctx, tag, dockerclient.RemoveImageTimeout)
+		if err != nil {
+			seelog.Errorf("Error removing RepoTag (ImageID: %s, Tag: %s) %v", image.ImageID, tag, err)
 		} else {
-			seelog.Debugf("Removing non-ECS Image: %s (Tags: %s)", image.ImageID, image.RepoTags)
-			err := imageManager.client.RemoveImage(ctx, image.ImageID, dockerclient.RemoveImageTimeout)
-			if err != nil {
-				seelog.Errorf("Error removing Image %s (Tags: %s) - %v", image.ImageID, image.RepoTags, err)
-			} else {
-				seelog.Infof("Image removed: %s (Tags: %s)", image.ImageID, image.RepoTags)
-				numImagesAlreadyDeleted++
-			}
+			seelog.Infof("Image Tag Removed: %s (ImageID: %s)", tag, image.ImageID)
+			numTagsRemoved++
 		}
 	}
+	// The image is only removed along with its last tag
+	if numTagsRemoved == len(image.RepoTags) {
+		imageManager.reclaimedBytes += uint64(image.Size)
+	}
+	return numTagsRemoved
 }
 
 // getNonECSImages returns type ImageWithSizeID with all fields populated.
 func (imageManager *dockerImageManager) getNonECSImages(ctx context.Context) []ImageWithSizeID {
+	var nonECSImages []ImageWithSizeID
+	for _, image := range imageManager.getUntrackedImages(ctx) {
+		// check image is not protected
+		if imageManager.isProtected(image.RepoTags, image.ImageID) {
+			continue
+		}
+		// check image TAG(s) is not excluded
+		if !anyIsInExclusionList(image.RepoTags, imageManager.imageCleanupExclusionList) {
+			nonECSImages = append(nonECSImages, image)
+		}
+	}
+	return nonECSImages
+}
+
+// getUntrackedImages returns the images on the instance that don't have an
+// image state, whether or not they're excluded from the cleanup
+func (imageManager *dockerImageManager) getUntrackedImages(ctx context.Context) []ImageWithSizeID {
 	r := imageManager.client.ListImages(ctx, dockerclient.ListImagesTimeout)
 	var allImages []ImageWithSizeID
 	// inspect all images
@@ -509,19 +739,14 @@ func (imageManager *dockerImageManager) getNonECSImages(ctx context.Context) []I
 		ecsImageIDs = append(ecsImageIDs, imageState.Image.ImageID)
 	}
 
-	// exclude 'ecs' image IDs and image IDs with an explicitly excluded tag
-	var nonECSImages []ImageWithSizeID
+	// exclude 'ecs' image IDs
+	var untrackedImages []ImageWithSizeID
 	for _, image := range allImages {
-		// check image ID is not excluded
-		if isInExclusionList(image.ImageID, ecsImageIDs) {
-			continue
-		}
-		// check image TAG(s) is not excluded
-		if !anyIsInExclusionList(image.RepoTags, imageManager.imageCleanupExclusionList) {
-			nonECSImages = append(nonECSImages, image)
+		if !isInExclusionList(image.ImageID, ecsImageIDs) {
+			untrackedImages = append(untrackedImages, image)
 		}
 	}
-	return nonECSImages
+	return untrackedImages
 }
 
 func isInExclusionList(imageName string, imageExclusionList []string) bool {
@@ -576,6 +801,9 @@ func (imageManager *dockerImageManager) imagesConsiderForDeletion(allImageStates
 }
 
 func (imageManager *dockerImageManager) isExcludedFromCleanup(imageState *image.ImageState) bool {
+	if imageManager.isProtected(imageState.Image.Names, imageState.Image.ImageID) {
+		return true
+	}
 	for _, ecsName := range imageState.Image.Names {
 		for _, exclusionName := range imageManager.imageCleanupExclusionList {
 			if ecsName == exclusionName {
@@ -641,6 +869,7 @@ func (imageManager *dockerImageManager) deleteImage(ctx context.Context, imageID
 	if len(imageState.Image.Names) == 0 {
 		seelog.Infof("Cleaning up all tracking information for image %s as it has zero references", imageID)
 		delete(imageManager.imageStatesConsideredForDeletion, imageState.Image.ImageID)
+		imageManager.reclaimedBytes += uint64(imageState.Image.Size)
 		imageManager.removeImageState(imageState)
 		imageManager.state.RemoveImageState(imageState)
 		imageManager.saver.Save()
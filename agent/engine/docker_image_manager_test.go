@@ -1950,3 +1950,187 @@ func TestImageCleanupProcessNotStart(t *testing.T) {
 	imageManager.StartImageCleanupProcess(ctx)
 	// Nothing should happen.
 }
+
+// fakeFilesystem is a filesystem of 100 bytes and inodes whose usage goes down
+// as images and containers are removed
+type fakeFilesystem struct {
+	lock        sync.Mutex
+	used        uint64
+	inodesUsed  uint64
+	pathsStated []string
+}
+
+func (fs *fakeFilesystem) usage(path string) (*filesystemUsage, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	fs.pathsStated = append(fs.pathsStated, path)
+	return &filesystemUsage{
+		bytesTotal:  100,
+		bytesFree:   100 - fs.used,
+		inodesTotal: 100,
+		inodesFree:  100 - fs.inodesUsed,
+	}, nil
+}
+
+func (fs *fakeFilesystem) free(bytes uint64) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	fs.used -= bytes
+}
+
+// addUnusedImageStates adds image states of unused images that were pulled
+// long enough ago to be removed. The first image is the least recently used
+func addUnusedImageStates(imageManager *dockerImageManager, names ...string) {
+	for i, name := range names {
+		imageManager.AddAllImageStates([]*image.ImageState{{
+			Image: &image.Image{
+				ImageID: "sha256:" + name,
+				Names:   []string{name},
+				Size:    int64(1000 * (i + 1)),
+			},
+			PulledAt:   time.Now().AddDate(0, -2, 0),
+			LastUsedAt: time.Now().AddDate(0, -2, i),
+		}})
+	}
+}
+
+func TestFilesystemUsedPercent(t *testing.T) {
+	usage := &filesystemUsage{bytesTotal: 200, bytesFree: 50, inodesTotal: 100, inodesFree: 10}
+	assert.Equal(t, 75.0, usage.bytesUsedPercent())
+	assert.Equal(t, 90.0, usage.inodesUsedPercent())
+	assert.Equal(t, 90.0, usage.usedPercent(), "running out of inodes fills up the disk too")
+
+	usage = &filesystemUsage{bytesTotal: 200, bytesFree: 150}
+	assert.Equal(t, 0.0, usage.inodesUsedPercent(), "filesystems may not report inodes")
+	assert.Equal(t, 25.0, usage.usedPercent())
+}
+
+func TestImageCleanupUnderDiskPressure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	fs := &fakeFilesystem{used: 95}
+	imageManager := &dockerImageManager{
+		client:                   client,
+		state:                    dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion: config.DefaultImageDeletionAge,
+		numImagesToDelete:        1,
+		diskHighWatermark:        90,
+		diskLowWatermark:         70,
+		filesystemUsage:          fs.usage,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+	addUnusedImageStates(imageManager, "oldest", "older", "newest")
+
+	// Each image frees up 15% of the disk, so removing two of them brings the
+	// usage below the low watermark even though only one image is removed per
+	// cycle
+	freeImage := func(ctx context.Context, imageName string, timeout time.Duration) error {
+		fs.free(15)
+		return nil
+	}
+	gomock.InOrder(
+		client.EXPECT().RemoveImage(gomock.Any(), "oldest", dockerclient.RemoveImageTimeout).DoAndReturn(freeImage),
+		client.EXPECT().RemoveImage(gomock.Any(), "older", dockerclient.RemoveImageTimeout).DoAndReturn(freeImage),
+	)
+
+	reclaimedBytes := imageManager.removeUnusedImagesUnderDiskPressure(context.TODO(), "/var/lib/docker")
+	assert.Equal(t, uint64(3000), reclaimedBytes)
+	require.Len(t, imageManager.imageStates, 1)
+	assert.Equal(t, "newest", imageManager.imageStates[0].Image.Names[0])
+}
+
+func TestImageCleanupUnderDiskPressureRemovesNonECSContainers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	fs := &fakeFilesystem{used: 50, inodesUsed: 95}
+	imageManager := &dockerImageManager{
+		client:                             client,
+		state:                              dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion:           config.DefaultImageDeletionAge,
+		numImagesToDelete:                  config.DefaultNumImagesToDeletePerCycle,
+		deleteNonECSImagesEnabled:          true,
+		nonECSContainerCleanupWaitDuration: time.Hour,
+		diskHighWatermark:                  90,
+		diskLowWatermark:                   70,
+		filesystemUsage:                    fs.usage,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	inspectContainerResponse := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			State: &types.ContainerState{
+				Status:     "exited",
+				FinishedAt: time.Now().AddDate(0, -2, 0).Format(time.RFC3339Nano),
+			},
+		},
+	}
+	client.EXPECT().ListContainers(gomock.Any(), true, dockerclient.ListContainersTimeout).Return(
+		dockerapi.ListContainersResponse{DockerIDs: []string{"1", "2"}})
+	client.EXPECT().InspectContainer(gomock.Any(), gomock.Any(), dockerclient.InspectContainerTimeout).Return(
+		inspectContainerResponse, nil).Times(2)
+	// Removing a container frees up inodes only. The usage falls below the low
+	// watermark after the first container, so the second one and non-ECS images
+	// are left alone
+	client.EXPECT().RemoveContainer(gomock.Any(), gomock.Any(), dockerclient.RemoveContainerTimeout).DoAndReturn(
+		func(ctx context.Context, id string, timeout time.Duration) error {
+			fs.lock.Lock()
+			defer fs.lock.Unlock()
+			fs.inodesUsed -= 30
+			return nil
+		})
+
+	reclaimedBytes := imageManager.removeUnusedImagesUnderDiskPressure(context.TODO(), "/var/lib/docker")
+	assert.Zero(t, reclaimedBytes)
+}
+
+func TestPeriodicImageCleanupOnDiskPressure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	fs := &fakeFilesystem{used: 85}
+	imageManager := &dockerImageManager{
+		client:                   client,
+		state:                    dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion: config.DefaultImageDeletionAge,
+		numImagesToDelete:        config.DefaultNumImagesToDeletePerCycle,
+		diskHighWatermark:        90,
+		diskLowWatermark:         70,
+		diskUsageCheckInterval:   5 * time.Millisecond,
+		filesystemUsage:          fs.usage,
+	}
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+	addUnusedImageStates(imageManager, "oldest", "newest")
+
+	client.EXPECT().Info(gomock.Any(), dockerclient.InfoTimeout).Return(
+		types.Info{DockerRootDir: "/var/lib/docker"}, nil)
+	client.EXPECT().RemoveImage(gomock.Any(), "oldest", dockerclient.RemoveImageTimeout).DoAndReturn(
+		func(ctx context.Context, imageName string, timeout time.Duration) error {
+			fs.free(30)
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	// The usage is below the high watermark, so the periodic cleanup doesn't run
+	// until the usage goes above it
+	go imageManager.performPeriodicImageCleanup(ctx, time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, imageManager.GetImageStatesCount())
+	fs.lock.Lock()
+	fs.used = 95
+	fs.lock.Unlock()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if imageManager.GetImageStatesCount() == 1 {
+			break
+		}
+	}
+	assert.Equal(t, 1, imageManager.GetImageStatesCount())
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	for _, path := range fs.pathsStated {
+		assert.Equal(t, "/var/lib/docker", path, "the data root reported by Docker is watched")
+	}
+}
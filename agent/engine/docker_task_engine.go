@@ -15,6 +15,7 @@
 package engine
 
 import (
+	"encoding/json"
 	"context"
 	"fmt"
 	"path/filepath"
@@ -36,6 +37,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/ecscni"
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dependencygraph"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
@@ -121,6 +123,8 @@ type DockerTaskEngine struct {
 	managedTasks map[string]*managedTask
 
 	taskStopGroup *utilsync.SequentialWaitGroup
+	// hostResources accounts for the host resources consumed by the tasks
+	hostResources *hostResourceManager
 
 	events            <-chan dockerapi.DockerContainerChangeEvent
 	stateChangeEvents chan statechange.Event
@@ -128,6 +132,15 @@ type DockerTaskEngine struct {
 
 	client    dockerapi.DockerClient
 	cniClient ecscni.CNIClient
+	// pullCoordinator merges concurrent pulls of the same image and limits the
+	// concurrent pulls from each registry
+	pullCoordinator *imagePullCoordinator
+	// imageAdmission checks the images of containers against the image
+	// admission policy before the containers are created
+	imageAdmission *imageAdmission
+	// containerArchive is where the logs and the inspect output of containers
+	// are saved before the containers are removed at task cleanup
+	containerArchive *containerarchive.Store
 
 	containerChangeEventStream *eventstream.EventStream
 
@@ -175,13 +188,19 @@ func NewDockerTaskEngine(cfg *config.Config,
 	metadataManager containermetadata.Manager,
 	resourceFields *taskresource.ResourceFields) *DockerTaskEngine {
 	dockerTaskEngine := &DockerTaskEngine{
-		cfg:    cfg,
-		client: client,
-		saver:  statemanager.NewNoopStateManager(),
+		cfg:              cfg,
+		client:           client,
+		pullCoordinator:  newImagePullCoordinator(cfg.ImagePullConcurrencyPerRegistry),
+		imageAdmission:   newImageAdmission(cfg.ImageAdmissionPolicyFile),
+		containerArchive: newContainerArchive(cfg),
+		saver:            statemanager.NewNoopStateManager(),
 
 		state:         state,
 		managedTasks:  make(map[string]*managedTask),
 		taskStopGroup: utilsync.NewSequentialWaitGroup(),
+		hostResources: newHostResourceManagerFromConfig(cfg, func() []string {
+			return hostGPUIDs(resourceFields)
+		}),
 
 		stateChangeEvents: make(chan statechange.Event),
 
@@ -229,6 +248,17 @@ func (engine *DockerTaskEngine) MarshalJSON() ([]byte, error) {
 	return engine.state.MarshalJSON()
 }
 
+// MarshalEntities marshals each of the entities of the state on its own
+func (engine *DockerTaskEngine) MarshalEntities() (map[string]map[string]json.RawMessage, error) {
+	return engine.state.MarshalEntities()
+}
+
+// UnmarshalEntities restores a task-engine state previously marshaled with
+// MarshalEntities
+func (engine *DockerTaskEngine) UnmarshalEntities(entities map[string]map[string]json.RawMessage) error {
+	return engine.state.UnmarshalEntities(entities)
+}
+
 // Init initializes a DockerTaskEngine such that it may communicate with docker
 // and operate normally.
 // This function must be called before any other function, except serializing and deserializing, can succeed without error.
@@ -400,6 +430,7 @@ func updateContainerMetadata(metadata *dockerapi.DockerContainerMetadata, contai
 	// Set Exitcode if it's not set
 	if metadata.ExitCode != nil {
 		container.SetKnownExitCode(metadata.ExitCode)
+		container.SetStopReason(containerStopReason(metadata))
 	}
 
 	// Set port mappings
@@ -414,6 +445,16 @@ func updateContainerMetadata(metadata *dockerapi.DockerContainerMetadata, contai
 	container.SetNetworkSettings(metadata.NetworkSettings)
 }
 
+// containerStopReason works out why the container stopped from the metadata of
+// the container once it has stopped
+func containerStopReason(metadata *dockerapi.DockerContainerMetadata) *apicontainer.StopReason {
+	var dockerError string
+	if stateError, ok := metadata.Error.(dockerapi.DockerStateError); ok {
+		dockerError = stateError.Error()
+	}
+	return apicontainer.NewStopReason(metadata.ExitCode, metadata.OOMKilled, dockerError)
+}
+
 // synchronizeContainerStatus checks and updates the container status with docker
 func (engine *DockerTaskEngine) synchronizeContainerStatus(container *apicontainer.DockerContainer, task *apitask.Task) {
 	if container.DockerID == "" {
@@ -505,6 +546,7 @@ func (engine *DockerTaskEngine) checkTaskState(task *apitask.Task) {
 // sweepTask deletes all the containers associated with a task
 func (engine *DockerTaskEngine) sweepTask(task *apitask.Task) {
 	for _, cont := range task.Containers {
+		engine.archiveContainer(task, cont)
 		err := engine.removeContainer(task, cont)
 		if err != nil {
 			seelog.Infof("Task engine [%s]: unable to remove old container [%s]: %v",
@@ -520,6 +562,7 @@ func (engine *DockerTaskEngine) sweepTask(task *apitask.Task) {
 				task.Arn, cont.Name, err)
 		}
 	}
+	engine.pruneContainerArchive()
 
 	// Clean metadata directory for task
 	if engine.cfg.ContainerMetadataEnabled {
@@ -858,7 +901,9 @@ func (engine *DockerTaskEngine) pullAndUpdateContainerReference(task *apitask.Ta
 		defer container.SetASMDockerAuthConfig(types.AuthConfig{})
 	}
 
-	metadata := engine.client.PullImage(engine.ctx, container.Image, container.RegistryAuthentication, dockerclient.PullImageTimeout)
+	metadata := engine.pullCoordinator.pull(engine.ctx, container, func(ctx context.Context) dockerapi.DockerContainerMetadata {
+		return engine.client.PullImage(ctx, container.Image, container.RegistryAuthentication, dockerclient.PullImageTimeout)
+	})
 
 	// Don't add internal images(created by ecs-agent) into imagemanger state
 	if container.IsInternal() {
@@ -885,6 +930,9 @@ func (engine *DockerTaskEngine) updateContainerReference(pullSucceeded bool, con
 
 func (engine *DockerTaskEngine) createContainer(task *apitask.Task, container *apicontainer.Container) dockerapi.DockerContainerMetadata {
 	seelog.Infof("Task engine [%s]: creating container: %s", task.Arn, container.Name)
+	if err := engine.admitContainerImage(task, container); err != nil {
+		return dockerapi.DockerContainerMetadata{Error: err}
+	}
 	client := engine.client
 	if container.DockerConfig.Version != nil {
 		client = client.WithVersion(dockerclient.DockerVersion(*container.DockerConfig.Version))
@@ -0,0 +1,220 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/fake"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeDockerTestImage   = "busybox:latest"
+	fakeDockerTestTimeout = 10 * time.Second
+)
+
+// setupWithFakeDocker returns a task engine that drives the in-memory Docker client
+func setupWithFakeDocker(t *testing.T, cfg config.Config) (*DockerTaskEngine, *fake.DockerClient, func()) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	client := fake.NewDockerClient()
+	state := dockerstate.NewTaskEngineState()
+	imageManager := NewImageManager(&cfg, client, state)
+	imageManager.SetSaver(statemanager.NewNoopStateManager())
+
+	taskEngine := NewDockerTaskEngine(&cfg, client, credentials.NewManager(),
+		eventstream.NewEventStream("FAKEDOCKERTEST", ctx), imageManager, state, nil, nil)
+	taskEngine.taskSteadyStatePollInterval = 100 * time.Millisecond
+	taskEngine.taskSteadyStatePollIntervalJitter = 10 * time.Millisecond
+	require.NoError(t, taskEngine.Init(ctx))
+	return taskEngine, client, func() {
+		taskEngine.Shutdown()
+		cancel()
+	}
+}
+
+func fakeDockerTestTask(arn string) *apitask.Task {
+	return &apitask.Task{
+		Arn:                 arn,
+		Family:              "family",
+		Version:             "1",
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers: []*apicontainer.Container{
+			{
+				Name:                "app",
+				Image:               fakeDockerTestImage,
+				Essential:           true,
+				DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+				CPU:                 256,
+				Memory:              128,
+			},
+		},
+	}
+}
+
+// waitForTaskStateChange consumes the state changes of the engine until the
+// task reaches the status, and returns the container state changes seen so far
+func waitForTaskStateChange(t *testing.T, taskEngine *DockerTaskEngine,
+	status apitaskstatus.TaskStatus) []api.ContainerStateChange {
+	var containerChanges []api.ContainerStateChange
+	timeout := time.After(fakeDockerTestTimeout)
+	for {
+		select {
+		case event := <-taskEngine.StateChangeEvents():
+			switch change := event.(type) {
+			case api.ContainerStateChange:
+				containerChanges = append(containerChanges, change)
+			case api.TaskStateChange:
+				containerChanges = append(containerChanges, change.Containers...)
+				if change.Status == status {
+					return containerChanges
+				}
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for task to reach %s", status.String())
+		}
+	}
+}
+
+func fakeDockerContainerID(t *testing.T, taskEngine *DockerTaskEngine, arn string, name string) string {
+	containers, ok := taskEngine.State().ContainerMapByArn(arn)
+	require.True(t, ok)
+	container, ok := containers[name]
+	require.True(t, ok)
+	return container.DockerID
+}
+
+func TestFakeDockerTaskRunsAndStops(t *testing.T) {
+	taskEngine, client, done := setupWithFakeDocker(t, defaultConfig)
+	defer done()
+
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/runs")
+	taskEngine.AddTask(task)
+	waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskRunning)
+	assert.Equal(t, 1, client.CallCount(fake.OperationPullImage))
+
+	dockerID := fakeDockerContainerID(t, taskEngine, task.Arn, "app")
+	require.NoError(t, client.ExitContainer(dockerID, 3))
+	changes := waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+	require.NotEmpty(t, changes)
+	stopped := changes[len(changes)-1]
+	assert.Equal(t, apicontainerstatus.ContainerStopped, stopped.Status)
+	require.NotNil(t, stopped.ExitCode)
+	assert.Equal(t, 3, *stopped.ExitCode)
+}
+
+func TestFakeDockerPullFailureStopsTask(t *testing.T) {
+	taskEngine, client, done := setupWithFakeDocker(t, defaultConfig)
+	defer done()
+
+	client.SetPullError(fakeDockerTestImage, errors.New("registry unavailable"))
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/pullFailure")
+	taskEngine.AddTask(task)
+
+	changes := waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+	require.NotEmpty(t, changes)
+	assert.Contains(t, changes[len(changes)-1].Reason, dockerapi.CannotPullContainerError{}.ErrorName())
+	assert.Equal(t, 0, client.CallCount(fake.OperationStartContainer))
+}
+
+func TestFakeDockerDroppedStopEventIsRecoveredByPolling(t *testing.T) {
+	taskEngine, client, done := setupWithFakeDocker(t, defaultConfig)
+	defer done()
+
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/droppedEvent")
+	taskEngine.AddTask(task)
+	waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskRunning)
+
+	client.DropEvents(func(event dockerapi.DockerContainerChangeEvent) bool {
+		return event.Status == apicontainerstatus.ContainerStopped
+	})
+	require.NoError(t, client.ExitContainer(fakeDockerContainerID(t, taskEngine, task.Arn, "app"), 0))
+	waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+}
+
+func TestFakeDockerDaemonRestartStopsTask(t *testing.T) {
+	taskEngine, client, done := setupWithFakeDocker(t, defaultConfig)
+	defer done()
+
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/daemonRestart")
+	taskEngine.AddTask(task)
+	waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskRunning)
+
+	client.RestartDaemon()
+	changes := waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+	require.NotEmpty(t, changes)
+	require.NotNil(t, changes[len(changes)-1].ExitCode)
+	assert.Equal(t, 137, *changes[len(changes)-1].ExitCode)
+}
+
+func TestFakeDockerStartTimeout(t *testing.T) {
+	cfg := defaultConfig
+	cfg.ContainerStartTimeout = 100 * time.Millisecond
+	taskEngine, client, done := setupWithFakeDocker(t, cfg)
+	defer done()
+
+	client.SetLatency(fake.OperationStartContainer, time.Minute)
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/startTimeout")
+	taskEngine.AddTask(task)
+
+	changes := waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+	require.NotEmpty(t, changes)
+	assert.Contains(t, changes[len(changes)-1].Reason, (&dockerapi.DockerTimeoutError{}).ErrorName())
+}
+
+func TestFakeDockerConcurrentTasksSharePull(t *testing.T) {
+	taskEngine, client, done := setupWithFakeDocker(t, defaultConfig)
+	defer done()
+
+	client.SetLatency(fake.OperationPullImage, 500*time.Millisecond)
+	const numTasks = 5
+	for i := 0; i < numTasks; i++ {
+		task := fakeDockerTestTask(fmt.Sprintf("arn:aws:ecs:us-west-2:123456789012:task/cluster/shared-%d", i))
+		// Don't reserve CPU so that all of the tasks fit on the host at once
+		task.Containers[0].CPU = 0
+		taskEngine.AddTask(task)
+	}
+
+	running := make(map[string]struct{})
+	timeout := time.After(fakeDockerTestTimeout)
+	for len(running) < numTasks {
+		select {
+		case event := <-taskEngine.StateChangeEvents():
+			if change, ok := event.(api.TaskStateChange); ok && change.Status == apitaskstatus.TaskRunning {
+				running[change.TaskARN] = struct{}{}
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for tasks to run, %d of %d running", len(running), numTasks)
+		}
+	}
+	assert.Equal(t, 1, client.CallCount(fake.OperationPullImage))
+}
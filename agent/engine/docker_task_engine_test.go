@@ -3104,3 +3104,42 @@ func TestStartFirelensContainerRetryForContainerIP(t *testing.T) {
 	assert.NoError(t, ret.Error)
 	assert.Equal(t, jsonBaseWithNetwork.NetworkSettings, ret.NetworkSettings)
 }
+
+func TestUpdateContainerMetadataStopReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metadata dockerapi.DockerContainerMetadata
+		expected *apicontainer.StopReason
+	}{
+		{
+			name:     "running",
+			metadata: dockerapi.DockerContainerMetadata{DockerID: "dockerid"},
+		},
+		{
+			name:     "oom killed",
+			metadata: dockerapi.DockerContainerMetadata{ExitCode: aws.Int(137), OOMKilled: true},
+			expected: &apicontainer.StopReason{Type: apicontainer.StopReasonOOMKilled, Signal: "SIGKILL"},
+		},
+		{
+			name:     "signaled",
+			metadata: dockerapi.DockerContainerMetadata{ExitCode: aws.Int(143)},
+			expected: &apicontainer.StopReason{Type: apicontainer.StopReasonSignaled, Signal: "SIGTERM"},
+		},
+		{
+			name: "docker error",
+			metadata: dockerapi.DockerContainerMetadata{
+				ExitCode: aws.Int(128),
+				Error:    dockerapi.NewDockerStateError("starting container process caused"),
+			},
+			expected: &apicontainer.StopReason{Type: apicontainer.StopReasonError,
+				Message: "starting container process caused"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := &apicontainer.Container{Name: "web"}
+			updateContainerMetadata(&tc.metadata, container, &apitask.Task{})
+			assert.Equal(t, tc.expected, container.GetStopReason())
+		})
+	}
+}
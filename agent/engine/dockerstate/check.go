@@ -0,0 +1,117 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+)
+
+// Check returns a description of each inconsistency in the saved state, such
+// as containers without tasks and IP addresses claimed by more than one task.
+// The descriptions are sorted so that checks of the same state are comparable.
+func (saved *SavedState) Check() []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	tasks := make(map[string]*apitask.Task, len(saved.Tasks))
+	for _, task := range saved.Tasks {
+		if _, ok := tasks[task.Arn]; ok {
+			report("task %s is saved more than once", task.Arn)
+		}
+		tasks[task.Arn] = task
+	}
+
+	for id, container := range saved.IdToContainer {
+		if container == nil || container.Container == nil {
+			report("container %s has no definition", id)
+			continue
+		}
+		taskARN, ok := saved.IdToTask[id]
+		if !ok {
+			report("container %s (%s) has no task", id, container.Container.Name)
+			continue
+		}
+		task, ok := tasks[taskARN]
+		if !ok {
+			report("container %s (%s) belongs to task %s, which isn't saved", id, container.Container.Name, taskARN)
+			continue
+		}
+		if _, ok := task.ContainerByName(container.Container.Name); !ok {
+			report("container %s (%s) isn't one of the containers of task %s", id, container.Container.Name, taskARN)
+		}
+	}
+	for id, taskARN := range saved.IdToTask {
+		if _, ok := saved.IdToContainer[id]; !ok {
+			report("container %s is mapped to task %s, but isn't saved", id, taskARN)
+		}
+	}
+
+	taskIPAddresses := make(map[string][]string)
+	for ipAddr, taskARN := range saved.IPToTask {
+		if _, ok := tasks[taskARN]; !ok {
+			report("IP address %s belongs to task %s, which isn't saved", ipAddr, taskARN)
+		}
+		taskIPAddresses[taskARN] = append(taskIPAddresses[taskARN], ipAddr)
+	}
+	for taskARN, ipAddrs := range taskIPAddresses {
+		if len(ipAddrs) > 1 {
+			sort.Strings(ipAddrs)
+			report("task %s has more than one IP address: %s", taskARN, strings.Join(ipAddrs, ", "))
+		}
+	}
+
+	// Tasks that aren't stopping have their ENIs, whose addresses must not be
+	// shared with other tasks
+	eniIPAddressTasks := make(map[string][]string)
+	for arn, task := range tasks {
+		if task.GetDesiredStatus().Terminal() {
+			continue
+		}
+		for _, eni := range task.ENIs {
+			for _, ipAddr := range eni.GetIPV4Addresses() {
+				eniIPAddressTasks[ipAddr] = append(eniIPAddressTasks[ipAddr], arn)
+			}
+		}
+	}
+	for ipAddr, taskARNs := range eniIPAddressTasks {
+		if len(taskARNs) > 1 {
+			sort.Strings(taskARNs)
+			report("IP address %s is used by the ENIs of more than one task: %s", ipAddr, strings.Join(taskARNs, ", "))
+		}
+	}
+
+	for _, eniAttachment := range saved.ENIAttachments {
+		if eniAttachment.TaskARN == "" {
+			continue
+		}
+		if _, ok := tasks[eniAttachment.TaskARN]; !ok {
+			report("ENI attachment %s belongs to task %s, which isn't saved", eniAttachment.MACAddress, eniAttachment.TaskARN)
+		}
+	}
+
+	for _, imageState := range saved.ImageStates {
+		if imageState.Image == nil || imageState.Image.ImageID == "" {
+			report("image state has no image ID")
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,134 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerstate
+
+import (
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedStateCheck(t *testing.T) {
+	newTask := func(arn string, ipAddrs ...string) *apitask.Task {
+		task := &apitask.Task{
+			Arn:        arn,
+			Containers: []*apicontainer.Container{{Name: "web"}},
+		}
+		if len(ipAddrs) > 0 {
+			eni := &apieni.ENI{ID: "eni-" + arn}
+			for _, ipAddr := range ipAddrs {
+				eni.IPV4Addresses = append(eni.IPV4Addresses, &apieni.ENIIPV4Address{Address: ipAddr})
+			}
+			task.ENIs = []*apieni.ENI{eni}
+		}
+		return task
+	}
+	container := func(name string) *apicontainer.DockerContainer {
+		return &apicontainer.DockerContainer{Container: &apicontainer.Container{Name: name}}
+	}
+	stoppedTask := newTask("stopped", "10.0.0.1")
+	stoppedTask.SetDesiredStatus(apitaskstatus.TaskStopped)
+
+	testCases := []struct {
+		name     string
+		saved    *SavedState
+		problems []string
+	}{
+		{
+			name: "consistent",
+			saved: &SavedState{
+				Tasks:          []*apitask.Task{newTask("task1", "10.0.0.1"), stoppedTask},
+				IdToContainer:  map[string]*apicontainer.DockerContainer{"id1": container("web")},
+				IdToTask:       map[string]string{"id1": "task1"},
+				ImageStates:    []*image.ImageState{{Image: &image.Image{ImageID: "sha256:image"}}},
+				ENIAttachments: []*apieni.ENIAttachment{{TaskARN: "task1", MACAddress: "mac"}, {MACAddress: "instance"}},
+				IPToTask:       map[string]string{"172.17.0.2": "task1"},
+			},
+		},
+		{
+			name:     "empty",
+			saved:    &SavedState{},
+			problems: nil,
+		},
+		{
+			name: "duplicate task",
+			saved: &SavedState{
+				Tasks: []*apitask.Task{newTask("task1"), newTask("task1")},
+			},
+			problems: []string{"task task1 is saved more than once"},
+		},
+		{
+			name: "containers without tasks",
+			saved: &SavedState{
+				Tasks: []*apitask.Task{newTask("task1")},
+				IdToContainer: map[string]*apicontainer.DockerContainer{
+					"id1": container("web"),
+					"id2": container("web"),
+					"id3": container("db"),
+					"id4": nil,
+				},
+				IdToTask: map[string]string{"id2": "task2", "id3": "task1", "id5": "task1"},
+			},
+			problems: []string{
+				"container id1 (web) has no task",
+				"container id2 (web) belongs to task task2, which isn't saved",
+				"container id3 (db) isn't one of the containers of task task1",
+				"container id4 has no definition",
+				"container id5 is mapped to task task1, but isn't saved",
+			},
+		},
+		{
+			name: "IP addresses",
+			saved: &SavedState{
+				Tasks:    []*apitask.Task{newTask("task1"), newTask("task2")},
+				IPToTask: map[string]string{"172.17.0.2": "task1", "172.17.0.3": "task1", "172.17.0.4": "task3"},
+			},
+			problems: []string{
+				"IP address 172.17.0.4 belongs to task task3, which isn't saved",
+				"task task1 has more than one IP address: 172.17.0.2, 172.17.0.3",
+			},
+		},
+		{
+			name: "ENI addresses used by more than one task",
+			saved: &SavedState{
+				Tasks: []*apitask.Task{newTask("task1", "10.0.0.1"), newTask("task2", "10.0.0.1"), stoppedTask},
+			},
+			problems: []string{"IP address 10.0.0.1 is used by the ENIs of more than one task: task1, task2"},
+		},
+		{
+			name: "ENI attachment and image state",
+			saved: &SavedState{
+				ImageStates:    []*image.ImageState{{}},
+				ENIAttachments: []*apieni.ENIAttachment{{TaskARN: "task1", MACAddress: "mac"}},
+			},
+			problems: []string{
+				"ENI attachment mac belongs to task task1, which isn't saved",
+				"image state has no image ID",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.problems, tc.saved.Check())
+		})
+	}
+}
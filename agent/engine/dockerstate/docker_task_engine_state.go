@@ -72,6 +72,12 @@ type TaskEngineState interface {
 	DockerIDByV3EndpointID(v3EndpointID string) (string, bool)
 	// TaskARNByV3EndpointID returns a taskARN for a given v3 endpoint ID
 	TaskARNByV3EndpointID(v3EndpointID string) (string, bool)
+	// MarshalEntities returns the JSON of each task, container, image state
+	// and ENI attachment in the state, so that they can be saved one at a time
+	MarshalEntities() (map[string]map[string]json.RawMessage, error)
+	// UnmarshalEntities restores the state from the entities returned by
+	// MarshalEntities
+	UnmarshalEntities(entities map[string]map[string]json.RawMessage) error
 
 	json.Marshaler
 	json.Unmarshaler
@@ -102,6 +108,13 @@ type DockerTaskEngineState struct {
 	ipToTask               map[string]string // ip address -> task arn
 	v3EndpointIDToTask     map[string]string // container's v3 endpoint id -> taskarn
 	v3EndpointIDToDockerID map[string]string // container's v3 endpoint id -> DockerId
+
+	// marshaled holds the JSON of the tasks, containers and image states as
+	// MarshalEntities last returned it, by kind and key. It's guarded by
+	// marshalLock rather than lock, since the state is only read locked while
+	// it's marshaled.
+	marshaled   map[string]map[string]marshaledEntity
+	marshalLock sync.Mutex
 }
 
 // NewTaskEngineState returns a new TaskEngineState
@@ -23,9 +23,21 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 )
 
-// These bits of information should be enough to reconstruct the entire
-// DockerTaskEngine state
-type savedState struct {
+// The kinds of entities the state is saved as by MarshalEntities, named after
+// the SavedState fields they make up
+const (
+	tasksEntities           = "Tasks"
+	containersEntities      = "IdToContainer"
+	containerTasksEntities  = "IdToTask"
+	imageStatesEntities     = "ImageStates"
+	eniAttachmentsEntities  = "ENIAttachments"
+	taskIPAddressesEntities = "IPToTask"
+)
+
+// SavedState is the state of the task engine as it's saved. These bits of
+// information should be enough to reconstruct the entire DockerTaskEngine
+// state. The saved state is only checked to be consistent when it's restored.
+type SavedState struct {
 	Tasks          []*apitask.Task
 	IdToContainer  map[string]*apicontainer.DockerContainer `json:"IdToContainer"` // DockerId -> apicontainer.DockerContainer
 	IdToTask       map[string]string                        `json:"IdToTask"`      // DockerId -> taskarn
@@ -37,7 +49,7 @@ type savedState struct {
 func (state *DockerTaskEngineState) MarshalJSON() ([]byte, error) {
 	state.lock.RLock()
 	defer state.lock.RUnlock()
-	toSave := savedState{
+	toSave := SavedState{
 		Tasks:          state.allTasksUnsafe(),
 		IdToContainer:  state.idToContainer,
 		IdToTask:       state.idToTask,
@@ -49,13 +61,182 @@ func (state *DockerTaskEngineState) MarshalJSON() ([]byte, error) {
 }
 
 func (state *DockerTaskEngineState) UnmarshalJSON(data []byte) error {
-	var saved savedState
+	saved, err := ParseSavedState(data)
+	if err != nil {
+		return err
+	}
+	return state.restore(saved)
+}
+
+// ParseSavedState returns the state saved as JSON, without restoring it
+func ParseSavedState(data []byte) (*SavedState, error) {
+	var saved SavedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// marshaledEntity is the JSON of an entity as MarshalEntities last returned
+// it, with the version the entity was at before it was marshaled
+type marshaledEntity struct {
+	entity  interface{}
+	version uint64
+	data    json.RawMessage
+}
+
+// MarshalEntities returns the JSON of each task, container, image state, ENI
+// attachment and task IP address in the state, by the name of the SavedState
+// field it belongs to and then by its key, so that they can be saved one at a
+// time. Tasks, containers and image states whose change version is the same
+// as when they were last marshaled aren't marshaled again.
+func (state *DockerTaskEngineState) MarshalEntities() (map[string]map[string]json.RawMessage, error) {
+	state.lock.RLock()
+	defer state.lock.RUnlock()
+	state.marshalLock.Lock()
+	defer state.marshalLock.Unlock()
+
+	marshaled := map[string]map[string]marshaledEntity{
+		tasksEntities:       make(map[string]marshaledEntity, len(state.tasks)),
+		containersEntities:  make(map[string]marshaledEntity, len(state.idToContainer)),
+		imageStatesEntities: make(map[string]marshaledEntity, len(state.imageStates)),
+	}
+	entities := map[string]map[string]json.RawMessage{
+		tasksEntities:           make(map[string]json.RawMessage, len(state.tasks)),
+		containersEntities:      make(map[string]json.RawMessage, len(state.idToContainer)),
+		containerTasksEntities:  make(map[string]json.RawMessage, len(state.idToTask)),
+		imageStatesEntities:     make(map[string]json.RawMessage, len(state.imageStates)),
+		eniAttachmentsEntities:  make(map[string]json.RawMessage, len(state.eniAttachments)),
+		taskIPAddressesEntities: make(map[string]json.RawMessage, len(state.ipToTask)),
+	}
+	add := func(kind string, key string, entity interface{}) error {
+		data, err := json.Marshal(entity)
+		if err != nil {
+			return err
+		}
+		entities[kind][key] = data
+		return nil
+	}
+	// The version is read before the entity is marshaled, so that a change
+	// made while it's marshaled gets it marshaled again the next time
+	addVersioned := func(kind string, key string, entity interface{}, version uint64) error {
+		if last, ok := state.marshaled[kind][key]; ok && last.entity == entity && last.version == version {
+			entities[kind][key] = last.data
+			marshaled[kind][key] = last
+			return nil
+		}
+		if err := add(kind, key, entity); err != nil {
+			return err
+		}
+		marshaled[kind][key] = marshaledEntity{entity: entity, version: version, data: entities[kind][key]}
+		return nil
+	}
+	for arn, task := range state.tasks {
+		if err := addVersioned(tasksEntities, arn, task, task.ChangeVersion()); err != nil {
+			return nil, err
+		}
+	}
+	for id, container := range state.idToContainer {
+		if container.Container == nil {
+			if err := add(containersEntities, id, container); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := addVersioned(containersEntities, id, container, container.Container.ChangeVersion()); err != nil {
+			return nil, err
+		}
+	}
+	for id, taskARN := range state.idToTask {
+		if err := add(containerTasksEntities, id, taskARN); err != nil {
+			return nil, err
+		}
+	}
+	for imageID, imageState := range state.imageStates {
+		if err := addVersioned(imageStatesEntities, imageID, imageState, imageState.ChangeVersion()); err != nil {
+			return nil, err
+		}
+	}
+	for mac, eniAttachment := range state.eniAttachments {
+		if err := add(eniAttachmentsEntities, mac, eniAttachment); err != nil {
+			return nil, err
+		}
+	}
+	for ipAddr, taskARN := range state.ipToTask {
+		if err := add(taskIPAddressesEntities, ipAddr, taskARN); err != nil {
+			return nil, err
+		}
+	}
+	state.marshaled = marshaled
+	return entities, nil
+}
 
-	err := json.Unmarshal(data, &saved)
+// UnmarshalEntities restores the state from the entities returned by
+// MarshalEntities
+func (state *DockerTaskEngineState) UnmarshalEntities(entities map[string]map[string]json.RawMessage) error {
+	saved, err := ParseSavedEntities(entities)
 	if err != nil {
 		return err
 	}
+	return state.restore(saved)
+}
+
+// ParseSavedEntities returns the state saved as entities by MarshalEntities,
+// without restoring it
+func ParseSavedEntities(entities map[string]map[string]json.RawMessage) (*SavedState, error) {
+	saved := SavedState{
+		IdToContainer: make(map[string]*apicontainer.DockerContainer),
+		IdToTask:      make(map[string]string),
+		IPToTask:      make(map[string]string),
+	}
+	for _, data := range entities[tasksEntities] {
+		var task apitask.Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, err
+		}
+		saved.Tasks = append(saved.Tasks, &task)
+	}
+	for id, data := range entities[containersEntities] {
+		var container apicontainer.DockerContainer
+		if err := json.Unmarshal(data, &container); err != nil {
+			return nil, err
+		}
+		saved.IdToContainer[id] = &container
+	}
+	for id, data := range entities[containerTasksEntities] {
+		var taskARN string
+		if err := json.Unmarshal(data, &taskARN); err != nil {
+			return nil, err
+		}
+		saved.IdToTask[id] = taskARN
+	}
+	for _, data := range entities[imageStatesEntities] {
+		var imageState image.ImageState
+		if err := json.Unmarshal(data, &imageState); err != nil {
+			return nil, err
+		}
+		saved.ImageStates = append(saved.ImageStates, &imageState)
+	}
+	for _, data := range entities[eniAttachmentsEntities] {
+		var eniAttachment apieni.ENIAttachment
+		if err := json.Unmarshal(data, &eniAttachment); err != nil {
+			return nil, err
+		}
+		saved.ENIAttachments = append(saved.ENIAttachments, &eniAttachment)
+	}
+	for ipAddr, data := range entities[taskIPAddressesEntities] {
+		var taskARN string
+		if err := json.Unmarshal(data, &taskARN); err != nil {
+			return nil, err
+		}
+		saved.IPToTask[ipAddr] = taskARN
+	}
+	return &saved, nil
+}
 
+// restore resets the state to the saved one, once it's checked that all of
+// the saved containers belong to saved tasks
+func (state *DockerTaskEngineState) restore(saved *SavedState) error {
 	// run precheck to shake out all the errors before resetting state.
 	precheckState := newDockerTaskEngineState()
 	for _, task := range saved.Tasks {
@@ -16,10 +16,14 @@
 package dockerstate
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	statemanager_testutils "github.com/aws/amazon-ecs-agent/agent/statemanager/testutils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -278,11 +282,19 @@ const (
 `
 )
 
+// migratedStateFileContents returns stateFileContents, which is saved at
+// version 11, migrated as the state manager migrates it before it's loaded
+func migratedStateFileContents(t *testing.T) []byte {
+	data, err := statemanager_testutils.MigrateTaskEngineState([]byte(stateFileContents), 11)
+	require.NoError(t, err)
+	return data
+}
+
 func TestUnmarshalMarshal(t *testing.T) {
 	// Create a new state object
 	state := newDockerTaskEngineState()
 	// Validate all the upper level fields unmarshaled
-	validateUnmarshaledState(t, state, []byte(stateFileContents))
+	validateUnmarshaledState(t, state, migratedStateFileContents(t))
 	// Marshal the state again
 	stateContents, err := state.MarshalJSON()
 	assert.NoError(t, err)
@@ -290,6 +302,74 @@ func TestUnmarshalMarshal(t *testing.T) {
 	validateUnmarshaledState(t, state, stateContents)
 }
 
+func TestMarshalUnmarshalEntities(t *testing.T) {
+	state := newDockerTaskEngineState()
+	require.NoError(t, state.UnmarshalJSON(migratedStateFileContents(t)))
+
+	entities, err := state.MarshalEntities()
+	require.NoError(t, err)
+	assert.Len(t, entities[tasksEntities], 1)
+	assert.Contains(t, entities[tasksEntities], "task1")
+	assert.Len(t, entities[containersEntities], 2)
+	assert.Len(t, entities[containerTasksEntities], 2)
+	assert.Len(t, entities[imageStatesEntities], 1)
+	assert.Len(t, entities[eniAttachmentsEntities], 1)
+	assert.Contains(t, entities[taskIPAddressesEntities], "169.254.172.2")
+
+	restored := newDockerTaskEngineState()
+	require.NoError(t, restored.UnmarshalEntities(entities))
+	restoredContents, err := restored.MarshalJSON()
+	require.NoError(t, err)
+	t.Logf("Validating the state restored from entities")
+	validateUnmarshaledState(t, newDockerTaskEngineState(), restoredContents)
+}
+
+func TestMarshalEntitiesReusesUnchanged(t *testing.T) {
+	state := newDockerTaskEngineState()
+	require.NoError(t, state.UnmarshalJSON(migratedStateFileContents(t)))
+	const (
+		changedID   = "042fd42cf3016526ccff43ef6ccb2c6a4b6f112bb74d5175cbebca75059b0c38"
+		unchangedID = "40109a71187ddd35effcd4e20067f97c140cd8ca7bef6a62028743c7f5b88a53"
+	)
+	sameData := func(a, b json.RawMessage) bool {
+		return len(a) > 0 && len(a) == len(b) && &a[0] == &b[0]
+	}
+
+	first, err := state.MarshalEntities()
+	require.NoError(t, err)
+	second, err := state.MarshalEntities()
+	require.NoError(t, err)
+	assert.True(t, sameData(first[tasksEntities]["task1"], second[tasksEntities]["task1"]),
+		"An unchanged task should not be marshaled again")
+	assert.True(t, sameData(first[containersEntities][changedID], second[containersEntities][changedID]),
+		"An unchanged container should not be marshaled again")
+	for imageID := range first[imageStatesEntities] {
+		assert.True(t, sameData(first[imageStatesEntities][imageID], second[imageStatesEntities][imageID]),
+			"An unchanged image state should not be marshaled again")
+	}
+
+	state.idToContainer[changedID].Container.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	third, err := state.MarshalEntities()
+	require.NoError(t, err)
+	assert.False(t, sameData(second[tasksEntities]["task1"], third[tasksEntities]["task1"]),
+		"A task should be marshaled again when one of its containers changed")
+	assert.False(t, sameData(second[containersEntities][changedID], third[containersEntities][changedID]),
+		"A changed container should be marshaled again")
+	assert.Contains(t, string(third[containersEntities][changedID]), `"KnownStatus":"STOPPED"`)
+	assert.True(t, sameData(second[containersEntities][unchangedID], third[containersEntities][unchangedID]))
+}
+
+func TestUnmarshalEntitiesContainerWithoutTask(t *testing.T) {
+	state := newDockerTaskEngineState()
+	require.NoError(t, state.UnmarshalJSON(migratedStateFileContents(t)))
+	entities, err := state.MarshalEntities()
+	require.NoError(t, err)
+	delete(entities, tasksEntities)
+
+	restored := newDockerTaskEngineState()
+	assert.Error(t, restored.UnmarshalEntities(entities))
+}
+
 func validateUnmarshaledState(t *testing.T, state *DockerTaskEngineState, contents []byte) {
 	state.initializeDockerTaskEngineState()
 	// Load state from string
@@ -19,6 +19,7 @@
 package mock_dockerstate
 
 import (
+	json "encoding/json"
 	reflect "reflect"
 
 	container "github.com/aws/amazon-ecs-agent/agent/api/container"
@@ -242,6 +243,21 @@ func (mr *MockTaskEngineStateMockRecorder) GetTaskByIPAddress(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaskByIPAddress", reflect.TypeOf((*MockTaskEngineState)(nil).GetTaskByIPAddress), arg0)
 }
 
+// MarshalEntities mocks base method
+func (m *MockTaskEngineState) MarshalEntities() (map[string]map[string]json.RawMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarshalEntities")
+	ret0, _ := ret[0].(map[string]map[string]json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarshalEntities indicates an expected call of MarshalEntities
+func (mr *MockTaskEngineStateMockRecorder) MarshalEntities() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarshalEntities", reflect.TypeOf((*MockTaskEngineState)(nil).MarshalEntities))
+}
+
 // MarshalJSON mocks base method
 func (m *MockTaskEngineState) MarshalJSON() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -365,6 +381,20 @@ func (mr *MockTaskEngineStateMockRecorder) TaskByShortID(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskByShortID", reflect.TypeOf((*MockTaskEngineState)(nil).TaskByShortID), arg0)
 }
 
+// UnmarshalEntities mocks base method
+func (m *MockTaskEngineState) UnmarshalEntities(arg0 map[string]map[string]json.RawMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnmarshalEntities", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnmarshalEntities indicates an expected call of UnmarshalEntities
+func (mr *MockTaskEngineStateMockRecorder) UnmarshalEntities(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnmarshalEntities", reflect.TypeOf((*MockTaskEngineState)(nil).UnmarshalEntities), arg0)
+}
+
 // UnmarshalJSON mocks base method
 func (m *MockTaskEngineState) UnmarshalJSON(arg0 []byte) error {
 	m.ctrl.T.Helper()
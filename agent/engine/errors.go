@@ -97,3 +97,34 @@ func (err CannotGetDockerClientVersionError) ErrorName() string {
 func (err CannotGetDockerClientVersionError) Error() string {
 	return err.fromError.Error()
 }
+
+// HostResourcesError is the error for a task that requires more resources than
+// the host can ever provide
+type HostResourcesError struct {
+	reason string
+}
+
+func (err HostResourcesError) Error() string {
+	return "Task cannot be placed on this host: " + err.reason
+}
+
+// ErrorName is the name of the error
+func (err HostResourcesError) ErrorName() string {
+	return "HostResourcesError"
+}
+
+// ImageAdmissionError is the error for a container whose image isn't admitted by
+// the image admission policy
+type ImageAdmissionError struct {
+	image     string
+	fromError error
+}
+
+func (err ImageAdmissionError) Error() string {
+	return "Image " + err.image + " is not admitted by the image admission policy: " + err.fromError.Error()
+}
+
+// ErrorName is the name of the error
+func (err ImageAdmissionError) ErrorName() string {
+	return "ImageAdmissionError"
+}
@@ -0,0 +1,50 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+// filesystemUsage is the usage of the space and inodes of a filesystem
+type filesystemUsage struct {
+	bytesTotal  uint64
+	bytesFree   uint64
+	inodesTotal uint64
+	inodesFree  uint64
+}
+
+func usedPercent(total, free uint64) float64 {
+	if total == 0 || free > total {
+		return 0
+	}
+	return float64(total-free) * 100 / float64(total)
+}
+
+// bytesUsedPercent returns the percentage of the space of the filesystem in use
+func (usage *filesystemUsage) bytesUsedPercent() float64 {
+	return usedPercent(usage.bytesTotal, usage.bytesFree)
+}
+
+// inodesUsedPercent returns the percentage of the inodes of the filesystem in
+// use. Filesystems that allocate inodes dynamically report no inodes at all
+func (usage *filesystemUsage) inodesUsedPercent() float64 {
+	return usedPercent(usage.inodesTotal, usage.inodesFree)
+}
+
+// usedPercent returns the larger of the percentages of the space and the inodes
+// of the filesystem in use, since running out of either fills up the disk
+func (usage *filesystemUsage) usedPercent() float64 {
+	bytesUsed, inodesUsed := usage.bytesUsedPercent(), usage.inodesUsedPercent()
+	if inodesUsed > bytesUsed {
+		return inodesUsed
+	}
+	return bytesUsed
+}
@@ -0,0 +1,33 @@
+// +build linux
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import "syscall"
+
+// getFilesystemUsage returns the usage of the filesystem the path is on. The
+// free space is the space available to unprivileged users, as Docker sees it
+func getFilesystemUsage(path string) (*filesystemUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, err
+	}
+	return &filesystemUsage{
+		bytesTotal:  stat.Blocks * uint64(stat.Bsize),
+		bytesFree:   stat.Bavail * uint64(stat.Bsize),
+		inodesTotal: stat.Files,
+		inodesFree:  stat.Ffree,
+	}, nil
+}
@@ -0,0 +1,24 @@
+// +build !linux
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import "github.com/pkg/errors"
+
+// getFilesystemUsage returns the usage of the filesystem the path is on. Disk
+// usage is only watched on Linux
+func getFilesystemUsage(path string) (*filesystemUsage, error) {
+	return nil, errors.New("filesystem usage is not supported on this platform")
+}
@@ -0,0 +1,379 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/cihub/seelog"
+	"github.com/docker/docker/pkg/system"
+)
+
+const (
+	// cpuUnitsPerCore is the number of CPU units registered for every core of the host
+	cpuUnitsPerCore = 1024
+
+	hostNetworkMode = "host"
+)
+
+// hostResources is a set of host resources. It's used both for the resources of
+// the host that are available to tasks and for the resources consumed by a task
+type hostResources struct {
+	// cpu is the number of CPU units
+	cpu int64
+	// memory is the amount of memory in MiB
+	memory   int64
+	tcpPorts []uint16
+	udpPorts []uint16
+	gpuIDs   []string
+}
+
+// hostResourceManager accounts for the CPU, memory, host ports and GPUs consumed
+// by the tasks on the host against the resources that were registered for the
+// container instance. The control plane makes its placement decisions based on
+// the same registered resources; the manager guards against its view of the
+// host diverging from the real state of the host
+type hostResourceManager struct {
+	// cpu and memory are the registered CPU units and memory. A value of 0
+	// means that the resource could not be determined and isn't accounted for
+	cpu    int64
+	memory int64
+	// reservedTCPPorts and reservedUDPPorts are the ports that are reserved
+	// on the host and can never be used by tasks
+	reservedTCPPorts map[uint16]struct{}
+	reservedUDPPorts map[uint16]struct{}
+	// gpuIDs are the GPUs available on the host
+	gpuIDs map[string]struct{}
+	// loadGPUIDs returns the GPUs available on the host. The GPUs are only
+	// discovered once the agent initializes the GPU manager, which happens
+	// after the engine is created, so they are loaded the first time a task
+	// consumes resources
+	loadGPUIDs func() []string
+
+	consumedCPU    int64
+	consumedMemory int64
+	// consumedTCPPorts, consumedUDPPorts and consumedGPUIDs map the port or
+	// the GPU to the arn of the task that consumes it
+	consumedTCPPorts map[uint16]string
+	consumedUDPPorts map[uint16]string
+	consumedGPUIDs   map[string]string
+	// taskResources maps the arn of every task that consumes resources to the
+	// resources it consumes
+	taskResources map[string]*hostResources
+
+	// released is closed and replaced every time resources are released
+	released chan struct{}
+	lock     sync.Mutex
+}
+
+// newHostResourceManager creates a hostResourceManager for the given host resources
+func newHostResourceManager(host *hostResources, reservedTCPPorts, reservedUDPPorts []uint16) *hostResourceManager {
+	manager := &hostResourceManager{
+		cpu:              host.cpu,
+		memory:           host.memory,
+		reservedTCPPorts: make(map[uint16]struct{}),
+		reservedUDPPorts: make(map[uint16]struct{}),
+		gpuIDs:           make(map[string]struct{}),
+		consumedTCPPorts: make(map[uint16]string),
+		consumedUDPPorts: make(map[uint16]string),
+		consumedGPUIDs:   make(map[string]string),
+		taskResources:    make(map[string]*hostResources),
+		released:         make(chan struct{}),
+	}
+	for _, port := range reservedTCPPorts {
+		manager.reservedTCPPorts[port] = struct{}{}
+	}
+	for _, port := range reservedUDPPorts {
+		manager.reservedUDPPorts[port] = struct{}{}
+	}
+	for _, gpuID := range host.gpuIDs {
+		manager.gpuIDs[gpuID] = struct{}{}
+	}
+	return manager
+}
+
+// newHostResourceManagerFromConfig creates a hostResourceManager for the resources
+// that are registered for the container instance
+func newHostResourceManagerFromConfig(cfg *config.Config, loadGPUIDs func() []string) *hostResourceManager {
+	host := &hostResources{
+		cpu: int64(runtime.NumCPU() * cpuUnitsPerCore),
+	}
+	memInfo, err := system.ReadMemInfo()
+	if err != nil {
+		seelog.Warnf("Task engine: unable to get memory info, task memory will not be accounted for: %v", err)
+	} else if memory := memInfo.MemTotal/1024/1024 - int64(cfg.ReservedMemory); memory > 0 {
+		host.memory = memory
+	}
+	manager := newHostResourceManager(host, cfg.ReservedPorts, cfg.ReservedPortsUDP)
+	manager.loadGPUIDs = loadGPUIDs
+	return manager
+}
+
+// consume reserves the resources required by a task if they are available. If
+// they aren't, it returns false along with a channel that is closed the next time
+// any resources are released. An error is returned if the task can never fit on
+// the host
+func (manager *hostResourceManager) consume(arn string, resources *hostResources) (bool, <-chan struct{}, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	if _, ok := manager.taskResources[arn]; ok {
+		return true, nil, nil
+	}
+	manager.loadGPUIDsUnsafe()
+	if err := manager.checkCapacity(resources); err != nil {
+		return false, nil, err
+	}
+	if reason := manager.checkAvailable(resources); reason != "" {
+		seelog.Infof("Task engine [%s]: waiting for host resources: %s", arn, reason)
+		return false, manager.released, nil
+	}
+	manager.consumeUnsafe(arn, resources)
+	return true, nil, nil
+}
+
+// loadGPUIDsUnsafe adds the GPUs available on the host the first time it's
+// called. It must be called with the lock held
+func (manager *hostResourceManager) loadGPUIDsUnsafe() {
+	if manager.loadGPUIDs == nil {
+		return
+	}
+	for _, gpuID := range manager.loadGPUIDs() {
+		manager.gpuIDs[gpuID] = struct{}{}
+	}
+	manager.loadGPUIDs = nil
+}
+
+// forceConsume records the resources consumed by a task that was started before,
+// regardless of whether they are available
+func (manager *hostResourceManager) forceConsume(arn string, resources *hostResources) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	if _, ok := manager.taskResources[arn]; ok {
+		return
+	}
+	manager.loadGPUIDsUnsafe()
+	if reason := manager.checkAvailable(resources); reason != "" {
+		seelog.Warnf("Task engine [%s]: task is already running even though host resources are exhausted: %s",
+			arn, reason)
+	}
+	manager.consumeUnsafe(arn, resources)
+}
+
+func (manager *hostResourceManager) consumeUnsafe(arn string, resources *hostResources) {
+	manager.consumedCPU += resources.cpu
+	manager.consumedMemory += resources.memory
+	for _, port := range resources.tcpPorts {
+		manager.consumedTCPPorts[port] = arn
+	}
+	for _, port := range resources.udpPorts {
+		manager.consumedUDPPorts[port] = arn
+	}
+	for _, gpuID := range resources.gpuIDs {
+		manager.consumedGPUIDs[gpuID] = arn
+	}
+	manager.taskResources[arn] = resources
+}
+
+// release releases the resources consumed by a task and wakes up the tasks
+// that are waiting for resources
+func (manager *hostResourceManager) release(arn string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	resources, ok := manager.taskResources[arn]
+	if !ok {
+		return
+	}
+	delete(manager.taskResources, arn)
+	manager.consumedCPU -= resources.cpu
+	manager.consumedMemory -= resources.memory
+	for _, port := range resources.tcpPorts {
+		if manager.consumedTCPPorts[port] == arn {
+			delete(manager.consumedTCPPorts, port)
+		}
+	}
+	for _, port := range resources.udpPorts {
+		if manager.consumedUDPPorts[port] == arn {
+			delete(manager.consumedUDPPorts, port)
+		}
+	}
+	for _, gpuID := range resources.gpuIDs {
+		if manager.consumedGPUIDs[gpuID] == arn {
+			delete(manager.consumedGPUIDs, gpuID)
+		}
+	}
+
+	close(manager.released)
+	manager.released = make(chan struct{})
+}
+
+// checkCapacity returns an error if the resources exceed what the host can
+// provide even when no other task is running
+func (manager *hostResourceManager) checkCapacity(resources *hostResources) error {
+	var reasons []string
+	if manager.cpu > 0 && resources.cpu > manager.cpu {
+		reasons = append(reasons, fmt.Sprintf("requires %d CPU units but the host has %d",
+			resources.cpu, manager.cpu))
+	}
+	if manager.memory > 0 && resources.memory > manager.memory {
+		reasons = append(reasons, fmt.Sprintf("requires %d MiB of memory but the host has %d",
+			resources.memory, manager.memory))
+	}
+	if ports := conflictingPorts(resources.tcpPorts, manager.reservedTCPPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("requires reserved TCP host ports %v", ports))
+	}
+	if ports := conflictingPorts(resources.udpPorts, manager.reservedUDPPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("requires reserved UDP host ports %v", ports))
+	}
+	if ports := duplicatePorts(resources.tcpPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("requires TCP host ports %v more than once", ports))
+	}
+	if ports := duplicatePorts(resources.udpPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("requires UDP host ports %v more than once", ports))
+	}
+	var missingGPUIDs []string
+	for _, gpuID := range resources.gpuIDs {
+		if _, ok := manager.gpuIDs[gpuID]; !ok {
+			missingGPUIDs = append(missingGPUIDs, gpuID)
+		}
+	}
+	if len(missingGPUIDs) > 0 {
+		reasons = append(reasons, fmt.Sprintf("requires GPUs %v that are not available on the host", missingGPUIDs))
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	return HostResourcesError{reason: strings.Join(reasons, "; ")}
+}
+
+// checkAvailable returns the reason why the resources are not currently available,
+// or an empty string if they are
+func (manager *hostResourceManager) checkAvailable(resources *hostResources) string {
+	var reasons []string
+	if manager.cpu > 0 && manager.consumedCPU+resources.cpu > manager.cpu {
+		reasons = append(reasons, fmt.Sprintf("%d of %d CPU units are available, %d are required",
+			manager.cpu-manager.consumedCPU, manager.cpu, resources.cpu))
+	}
+	if manager.memory > 0 && manager.consumedMemory+resources.memory > manager.memory {
+		reasons = append(reasons, fmt.Sprintf("%d of %d MiB of memory are available, %d are required",
+			manager.memory-manager.consumedMemory, manager.memory, resources.memory))
+	}
+	if ports := consumedPorts(resources.tcpPorts, manager.consumedTCPPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("TCP host ports %v are in use", ports))
+	}
+	if ports := consumedPorts(resources.udpPorts, manager.consumedUDPPorts); len(ports) > 0 {
+		reasons = append(reasons, fmt.Sprintf("UDP host ports %v are in use", ports))
+	}
+	var consumedGPUIDs []string
+	for _, gpuID := range resources.gpuIDs {
+		if _, ok := manager.consumedGPUIDs[gpuID]; ok {
+			consumedGPUIDs = append(consumedGPUIDs, gpuID)
+		}
+	}
+	if len(consumedGPUIDs) > 0 {
+		reasons = append(reasons, fmt.Sprintf("GPUs %v are in use", consumedGPUIDs))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func conflictingPorts(ports []uint16, reserved map[uint16]struct{}) []uint16 {
+	var conflicts []uint16
+	for _, port := range ports {
+		if _, ok := reserved[port]; ok {
+			conflicts = append(conflicts, port)
+		}
+	}
+	return conflicts
+}
+
+func consumedPorts(ports []uint16, consumed map[uint16]string) []uint16 {
+	var conflicts []uint16
+	for _, port := range ports {
+		if _, ok := consumed[port]; ok {
+			conflicts = append(conflicts, port)
+		}
+	}
+	return conflicts
+}
+
+func duplicatePorts(ports []uint16) []uint16 {
+	seen := make(map[uint16]int)
+	var duplicates []uint16
+	for _, port := range ports {
+		seen[port]++
+		if seen[port] == 2 {
+			duplicates = append(duplicates, port)
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i] < duplicates[j] })
+	return duplicates
+}
+
+// taskHostResources returns the host resources required by a task. Task level
+// CPU and memory limits take precedence over the sum of the container limits.
+// Like the scheduler, the memory reservation of the containers that have no
+// hard memory limit is counted instead.
+// Host ports are only consumed by tasks that don't have their own network
+// namespace, and only when the port is not dynamically assigned
+func taskHostResources(task *apitask.Task) *hostResources {
+	resources := &hostResources{}
+	var containerCPU, containerMemory int64
+	for _, container := range task.Containers {
+		containerCPU += int64(container.CPU)
+		if container.Memory > 0 {
+			containerMemory += int64(container.Memory)
+		} else {
+			containerMemory += container.GetMemoryReservationFromHostConfig() / 1024 / 1024
+		}
+		resources.gpuIDs = append(resources.gpuIDs, container.GPUIDs...)
+		if task.IsNetworkModeAWSVPC() {
+			continue
+		}
+		hostNetwork := container.GetNetworkModeFromHostConfig() == hostNetworkMode
+		for _, binding := range container.Ports {
+			hostPort := binding.HostPort
+			if hostPort == 0 && hostNetwork {
+				hostPort = binding.ContainerPort
+			}
+			if hostPort == 0 {
+				continue
+			}
+			if binding.Protocol == apicontainer.TransportProtocolUDP {
+				resources.udpPorts = append(resources.udpPorts, hostPort)
+			} else {
+				resources.tcpPorts = append(resources.tcpPorts, hostPort)
+			}
+		}
+	}
+
+	resources.cpu = containerCPU
+	if task.CPU > 0 {
+		resources.cpu = int64(task.CPU * cpuUnitsPerCore)
+	}
+	resources.memory = containerMemory
+	if task.Memory > 0 {
+		resources.memory = task.Memory
+	}
+	return resources
+}
@@ -0,0 +1,209 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostResourceManagerConsumeAndRelease(t *testing.T) {
+	manager := newHostResourceManager(&hostResources{cpu: 2048, memory: 1024}, nil, nil)
+
+	consumed, _, err := manager.consume("task1", &hostResources{cpu: 1024, memory: 512})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	// Consuming resources for the same task again is a no-op
+	consumed, _, err = manager.consume("task1", &hostResources{cpu: 1024, memory: 512})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	consumed, _, err = manager.consume("task2", &hostResources{cpu: 512, memory: 256})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	consumed, released, err := manager.consume("task3", &hostResources{cpu: 1024, memory: 256})
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	require.NotNil(t, released)
+	select {
+	case <-released:
+		t.Fatal("resources were not released yet")
+	default:
+	}
+
+	manager.release("task1")
+	<-released
+
+	consumed, _, err = manager.consume("task3", &hostResources{cpu: 1024, memory: 256})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	assert.Equal(t, int64(1536), manager.consumedCPU)
+	assert.Equal(t, int64(512), manager.consumedMemory)
+}
+
+func TestHostResourceManagerPorts(t *testing.T) {
+	manager := newHostResourceManager(&hostResources{}, []uint16{22}, []uint16{53})
+
+	consumed, _, err := manager.consume("task1", &hostResources{tcpPorts: []uint16{80}, udpPorts: []uint16{80}})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	consumed, _, err = manager.consume("task2", &hostResources{tcpPorts: []uint16{80}})
+	require.NoError(t, err)
+	assert.False(t, consumed)
+
+	consumed, _, err = manager.consume("task3", &hostResources{tcpPorts: []uint16{81}, udpPorts: []uint16{81}})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	manager.release("task1")
+	consumed, _, err = manager.consume("task2", &hostResources{tcpPorts: []uint16{80}})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	assert.Equal(t, map[uint16]string{80: "task2", 81: "task3"}, manager.consumedTCPPorts)
+	assert.Equal(t, map[uint16]string{81: "task3"}, manager.consumedUDPPorts)
+}
+
+func TestHostResourceManagerNeverFits(t *testing.T) {
+	manager := newHostResourceManager(&hostResources{cpu: 1024, memory: 512, gpuIDs: []string{"gpu0"}},
+		[]uint16{22}, []uint16{53})
+
+	testCases := []struct {
+		name      string
+		resources *hostResources
+		reason    string
+	}{
+		{"cpu", &hostResources{cpu: 2048}, "requires 2048 CPU units but the host has 1024"},
+		{"memory", &hostResources{memory: 1024}, "requires 1024 MiB of memory but the host has 512"},
+		{"reserved tcp port", &hostResources{tcpPorts: []uint16{22}}, "requires reserved TCP host ports [22]"},
+		{"reserved udp port", &hostResources{udpPorts: []uint16{53}}, "requires reserved UDP host ports [53]"},
+		{"duplicate port", &hostResources{tcpPorts: []uint16{80, 80}}, "requires TCP host ports [80] more than once"},
+		{"gpu", &hostResources{gpuIDs: []string{"gpu1"}}, "requires GPUs [gpu1] that are not available on the host"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			consumed, _, err := manager.consume("task", tc.resources)
+			assert.False(t, consumed)
+			require.Error(t, err)
+			assert.IsType(t, HostResourcesError{}, err)
+			assert.Contains(t, err.Error(), tc.reason)
+		})
+	}
+	assert.Empty(t, manager.taskResources)
+}
+
+func TestHostResourceManagerLoadsGPUsOnFirstConsume(t *testing.T) {
+	loads := 0
+	manager := newHostResourceManager(&hostResources{}, nil, nil)
+	manager.loadGPUIDs = func() []string {
+		loads++
+		return []string{"gpu0"}
+	}
+
+	consumed, _, err := manager.consume("task1", &hostResources{gpuIDs: []string{"gpu0"}})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	manager.release("task1")
+	consumed, _, err = manager.consume("task2", &hostResources{gpuIDs: []string{"gpu0"}})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	assert.Equal(t, 1, loads)
+}
+
+func TestHostResourceManagerUnknownMemory(t *testing.T) {
+	manager := newHostResourceManager(&hostResources{cpu: 1024}, nil, nil)
+
+	consumed, _, err := manager.consume("task", &hostResources{cpu: 1024, memory: 1 << 20})
+	require.NoError(t, err)
+	assert.True(t, consumed)
+}
+
+func TestHostResourceManagerForceConsume(t *testing.T) {
+	manager := newHostResourceManager(&hostResources{cpu: 1024}, nil, nil)
+
+	manager.forceConsume("task1", &hostResources{cpu: 1024, tcpPorts: []uint16{80}})
+	manager.forceConsume("task2", &hostResources{cpu: 1024, tcpPorts: []uint16{80}})
+	assert.Equal(t, int64(2048), manager.consumedCPU)
+
+	manager.release("task1")
+	manager.release("task2")
+	assert.Zero(t, manager.consumedCPU)
+	assert.Empty(t, manager.consumedTCPPorts)
+}
+
+func TestTaskHostResources(t *testing.T) {
+	hostConfig := `{"NetworkMode":"host"}`
+	task := &apitask.Task{
+		Containers: []*apicontainer.Container{
+			{
+				CPU:    512,
+				Memory: 256,
+				GPUIDs: []string{"gpu0"},
+				Ports: []apicontainer.PortBinding{
+					{ContainerPort: 80, HostPort: 8080},
+					{ContainerPort: 53, HostPort: 5353, Protocol: apicontainer.TransportProtocolUDP},
+					{ContainerPort: 81},
+				},
+			},
+			{
+				CPU:    256,
+				Memory: 128,
+				Ports: []apicontainer.PortBinding{
+					{ContainerPort: 9000},
+				},
+				DockerConfig: apicontainer.DockerConfig{
+					HostConfig: aws.String(hostConfig),
+				},
+			},
+		},
+	}
+
+	resources := taskHostResources(task)
+	assert.Equal(t, int64(768), resources.cpu)
+	assert.Equal(t, int64(384), resources.memory)
+	assert.Equal(t, []uint16{8080, 9000}, resources.tcpPorts)
+	assert.Equal(t, []uint16{5353}, resources.udpPorts)
+	assert.Equal(t, []string{"gpu0"}, resources.gpuIDs)
+
+	// The memory reservation is counted for containers without a hard limit
+	task.Containers = append(task.Containers, &apicontainer.Container{
+		DockerConfig: apicontainer.DockerConfig{
+			HostConfig: aws.String(`{"MemoryReservation":67108864}`),
+		},
+	})
+	resources = taskHostResources(task)
+	assert.Equal(t, int64(384+64), resources.memory)
+
+	// Task level limits take precedence
+	task.CPU = 2
+	task.Memory = 2048
+	resources = taskHostResources(task)
+	assert.Equal(t, int64(2048), resources.cpu)
+	assert.Equal(t, int64(2048), resources.memory)
+
+	// awsvpc tasks don't consume host ports
+	task.ENIs = []*apieni.ENI{{ID: "eni-1"}}
+	resources = taskHostResources(task)
+	assert.Empty(t, resources.tcpPorts)
+	assert.Empty(t, resources.udpPorts)
+}
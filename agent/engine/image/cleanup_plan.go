@@ -0,0 +1,52 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package image
+
+import (
+	"time"
+)
+
+const (
+	// CleanupDelete indicates that the image cleanup would remove an image
+	CleanupDelete CleanupAction = "DELETE"
+	// CleanupKeep indicates that the image cleanup would keep an image
+	CleanupKeep CleanupAction = "KEEP"
+)
+
+// CleanupAction is what the image cleanup would do with an image
+type CleanupAction string
+
+// CleanupPlan is what the next image cleanup cycle would do with each image on
+// the instance, computed without removing anything
+type CleanupPlan struct {
+	GeneratedAt time.Time `json:"GeneratedAt"`
+	// DisabledReason is set if the image cleanup doesn't run at all
+	DisabledReason string `json:"DisabledReason,omitempty"`
+	// ImagesPerCycle is the number of images removed per cleanup cycle
+	ImagesPerCycle int `json:"ImagesPerCycle"`
+	// Images are the images pulled for tasks, least recently used first
+	Images []CleanupPlanImage `json:"Images"`
+	// NonECSImages are the other images on the instance, smallest first
+	NonECSImages []CleanupPlanImage `json:"NonECSImages"`
+}
+
+// CleanupPlanImage is what the image cleanup would do with an image, and why
+type CleanupPlanImage struct {
+	ImageID    string        `json:"ImageID"`
+	Names      []string      `json:"Names"`
+	Size       int64         `json:"Size"`
+	LastUsedAt *time.Time    `json:"LastUsedAt,omitempty"`
+	Action     CleanupAction `json:"Action"`
+	Reason     string        `json:"Reason"`
+}
@@ -0,0 +1,44 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package image
+
+import (
+	"time"
+)
+
+const (
+	// PrewarmPending indicates that a pre-warmed image has not been pulled yet
+	PrewarmPending PrewarmState = "PENDING"
+	// PrewarmPulling indicates that a pre-warmed image is being pulled
+	PrewarmPulling PrewarmState = "PULLING"
+	// PrewarmReady indicates that a pre-warmed image is present on the instance
+	PrewarmReady PrewarmState = "READY"
+	// PrewarmFailed indicates that the last pull of a pre-warmed image failed
+	PrewarmFailed PrewarmState = "FAILED"
+)
+
+// PrewarmState is the state of an image that is pulled ahead of the tasks that use it
+type PrewarmState string
+
+// PrewarmStatus is the status of an image that is pulled ahead of the tasks that use it
+type PrewarmStatus struct {
+	Image           string       `json:"Image"`
+	PullPolicy      string       `json:"PullPolicy"`
+	RefreshInterval string       `json:"RefreshInterval,omitempty"`
+	State           PrewarmState `json:"State"`
+	ImageID         string       `json:"ImageID,omitempty"`
+	LastPullAttempt *time.Time   `json:"LastPullAttempt,omitempty"`
+	LastPulledAt    *time.Time   `json:"LastPulledAt,omitempty"`
+	LastError       string       `json:"LastError,omitempty"`
+}
@@ -17,10 +17,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/cihub/seelog"
 )
 
@@ -48,7 +48,13 @@ type ImageState struct {
 	// PullSucceeded defines whether this image has been pulled successfully before,
 	// this should be set to true when one of the pull image call succeeds.
 	PullSucceeded bool
-	lock          sync.RWMutex
+	lock          utilsync.VersionedRWMutex
+}
+
+// ChangeVersion returns a value that's larger every time the image state may
+// have changed
+func (imageState *ImageState) ChangeVersion() uint64 {
+	return imageState.lock.Version()
 }
 
 // UpdateContainerReference updates container reference in image state
@@ -144,8 +150,8 @@ func (imageState *ImageState) GetPullSucceeded() bool {
 
 // MarshalJSON marshals image state
 func (imageState *ImageState) MarshalJSON() ([]byte, error) {
-	imageState.lock.Lock()
-	defer imageState.lock.Unlock()
+	imageState.lock.RLock()
+	defer imageState.lock.RUnlock()
 
 	return json.Marshal(&struct {
 		Image         *Image
@@ -0,0 +1,98 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apierrors "github.com/aws/amazon-ecs-agent/agent/api/errors"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/engine/imageadmission"
+	"github.com/cihub/seelog"
+	"github.com/docker/distribution/reference"
+)
+
+// imageAdmission holds the image admission policy the images of containers are
+// checked against before the containers are created
+type imageAdmission struct {
+	policy *imageadmission.Policy
+	// loadErr is the error the policy failed to load with. No image is admitted
+	// if the policy couldn't be loaded
+	loadErr error
+}
+
+// newImageAdmission loads the image admission policy from the file. It returns
+// nil if there's no policy file, in which case every image is admitted
+func newImageAdmission(policyFile string) *imageAdmission {
+	if policyFile == "" {
+		return nil
+	}
+	policy, err := imageadmission.LoadPolicy(policyFile)
+	if err != nil {
+		seelog.Criticalf("Task engine: unable to load image admission policy, no container will be admitted: %v", err)
+	}
+	return &imageAdmission{
+		policy:  policy,
+		loadErr: err,
+	}
+}
+
+// admitContainerImage returns an error if the image of the container isn't
+// admitted by the image admission policy. The image is checked against the
+// digest recorded on the container when the image was pulled
+func (engine *DockerTaskEngine) admitContainerImage(task *apitask.Task,
+	container *apicontainer.Container) apierrors.NamedError {
+	admission := engine.imageAdmission
+	if admission == nil || container.IsInternal() {
+		return nil
+	}
+	if admission.loadErr != nil {
+		return ImageAdmissionError{image: container.Image, fromError: admission.loadErr}
+	}
+
+	if container.GetImageDigest() == "" && admission.policy.RequiresDigest(container.Image) {
+		engine.resolveImageDigest(task, container)
+	}
+	if err := admission.policy.Check(container.Image, container.GetImageDigest()); err != nil {
+		seelog.Errorf("Task engine [%s]: image %s of container %s is not admitted by the image admission policy: %v",
+			task.Arn, container.Image, container.Name, err)
+		return ImageAdmissionError{image: container.Image, fromError: err}
+	}
+	return nil
+}
+
+// resolveImageDigest records the digest of the image of the container from the
+// repository digests of the image. The digest is only recorded when the image is
+// pulled for images from ECR
+func (engine *DockerTaskEngine) resolveImageDigest(task *apitask.Task, container *apicontainer.Container) {
+	named, err := reference.ParseNormalizedNamed(container.Image)
+	if err != nil {
+		return
+	}
+	imageInspected, err := engine.client.InspectImage(container.Image)
+	if err != nil {
+		seelog.Warnf("Task engine [%s]: unable to inspect image %s of container %s to resolve its digest: %v",
+			task.Arn, container.Image, container.Name, err)
+		return
+	}
+	for _, repoDigest := range imageInspected.RepoDigests {
+		parsed, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+		if canonical, ok := parsed.(reference.Canonical); ok && canonical.Name() == named.Name() {
+			container.SetImageDigest(canonical.Digest().String())
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOtherImageDigest = "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+// setupImageAdmissionPolicy writes the policy to a temporary file and returns
+// its path along with a function that removes it
+func setupImageAdmissionPolicy(t *testing.T, policy string) (string, func()) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(policy), 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func pinnedDigestPolicy(digest string) string {
+	return fmt.Sprintf(`{"Repositories":[{"Repository":"docker.io/library/busybox","Digests":[%q]}]}`, digest)
+}
+
+func TestImageAdmissionAdmitsPinnedDigest(t *testing.T) {
+	// The fake client uses the image id as the digest of the image
+	imageDigest := fake.NewDockerClient().AddImage(fakeDockerTestImage, 0)
+	policyFile, cleanup := setupImageAdmissionPolicy(t, pinnedDigestPolicy(imageDigest))
+	defer cleanup()
+	cfg := defaultConfig
+	cfg.ImageAdmissionPolicyFile = policyFile
+	taskEngine, _, done := setupWithFakeDocker(t, cfg)
+	defer done()
+
+	task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/admitted")
+	taskEngine.AddTask(task)
+	waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskRunning)
+	assert.Equal(t, imageDigest, task.Containers[0].GetImageDigest())
+}
+
+func TestImageAdmissionRejectsContainer(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy string
+	}{
+		{
+			name:   "unpinned digest",
+			policy: pinnedDigestPolicy(testOtherImageDigest),
+		},
+		{
+			name:   "registry not allowed",
+			policy: `{"AllowedRegistries":["registry.example.com"]}`,
+		},
+		{
+			name:   "invalid policy",
+			policy: `{"Repositories":[{"Digests":["latest"]}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyFile, cleanup := setupImageAdmissionPolicy(t, tc.policy)
+			defer cleanup()
+			cfg := defaultConfig
+			cfg.ImageAdmissionPolicyFile = policyFile
+			taskEngine, client, done := setupWithFakeDocker(t, cfg)
+			defer done()
+
+			task := fakeDockerTestTask("arn:aws:ecs:us-west-2:123456789012:task/cluster/rejected")
+			taskEngine.AddTask(task)
+			changes := waitForTaskStateChange(t, taskEngine, apitaskstatus.TaskStopped)
+			require.NotEmpty(t, changes)
+			assert.Contains(t, changes[len(changes)-1].Reason, ImageAdmissionError{}.ErrorName())
+			assert.Equal(t, 1, client.CallCount(fake.OperationPullImage))
+			assert.Equal(t, 0, client.CallCount(fake.OperationCreateContainer))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+)
+
+// GetImageCleanupPlan returns what the next image cleanup cycle would do with
+// each image on the instance, and why. Nothing is removed
+func (imageManager *dockerImageManager) GetImageCleanupPlan(ctx context.Context) image.CleanupPlan {
+	imageManager.updateLock.Lock()
+	defer imageManager.updateLock.Unlock()
+
+	plan := image.CleanupPlan{
+		GeneratedAt:    time.Now(),
+		DisabledReason: imageManager.cleanupDisabledReason(),
+		ImagesPerCycle: imageManager.numImagesToDelete,
+	}
+	numECSImagesToDelete := imageManager.planECSImages(&plan)
+	imageManager.planNonECSImages(ctx, &plan, numECSImagesToDelete)
+	return plan
+}
+
+// cleanupDisabledReason returns why the image cleanup doesn't run, if it doesn't
+func (imageManager *dockerImageManager) cleanupDisabledReason() string {
+	if imageManager.imageCleanupDisabled {
+		return "image cleanup is disabled"
+	}
+	if imageManager.imagePullBehavior == config.ImagePullPreferCachedBehavior {
+		return "image cleanup is disabled by the prefer-cached image pull behavior"
+	}
+	return ""
+}
+
+// planECSImages adds the images with an image state to the plan, and returns
+// the number of them the cleanup would remove
+func (imageManager *dockerImageManager) planECSImages(plan *image.CleanupPlan) int {
+	allImageStates := imageManager.getAllImageStates()
+	imageManager.imageStatesConsideredForDeletion = imageManager.imagesConsiderForDeletion(allImageStates)
+	candidates := ImageStatesForDeletion(imageManager.getCandidateImagesForDeletion())
+	sort.Sort(candidates)
+	toDelete := make(map[string]bool)
+	if plan.DisabledReason == "" {
+		for i := 0; i < len(candidates) && i < imageManager.numImagesToDelete; i++ {
+			toDelete[candidates[i].Image.ImageID] = true
+		}
+	}
+
+	imageStates := make(ImageStatesForDeletion, len(allImageStates))
+	copy(imageStates, allImageStates)
+	sort.Sort(imageStates)
+	plan.Images = make([]image.CleanupPlanImage, 0, len(imageStates))
+	for _, imageState := range imageStates {
+		lastUsedAt := imageState.LastUsedAt
+		planned := image.CleanupPlanImage{
+			ImageID:    imageState.Image.ImageID,
+			Names:      append([]string{}, imageState.Image.Names...),
+			Size:       imageState.Image.Size,
+			LastUsedAt: &lastUsedAt,
+			Action:     image.CleanupKeep,
+		}
+		switch {
+		case plan.DisabledReason != "":
+			planned.Reason = plan.DisabledReason
+		case imageManager.isProtected(imageState.Image.Names, imageState.Image.ImageID):
+			planned.Reason = "image is on the pre-warm list"
+		case imageManager.isExcludedFromCleanup(imageState):
+			planned.Reason = "image is on the cleanup exclusion list"
+		case !imageState.HasNoAssociatedContainers():
+			planned.Reason = "image is used by containers"
+		case !imageManager.isImageOldEnough(imageState):
+			planned.Reason = fmt.Sprintf("image was pulled less than %s ago", imageManager.minimumAgeBeforeDeletion)
+		case toDelete[imageState.Image.ImageID]:
+			planned.Action = image.CleanupDelete
+			planned.Reason = "image is one of the least recently used unused images"
+		default:
+			planned.Reason = fmt.Sprintf("more recently used than the %d images removed per cycle", imageManager.numImagesToDelete)
+		}
+		plan.Images = append(plan.Images, planned)
+	}
+	return len(toDelete)
+}
+
+// planNonECSImages adds the images without an image state to the plan. The
+// number of them the cleanup would remove is what's left of the images removed
+// per cycle, counted the way removeUnusedImages counts them
+func (imageManager *dockerImageManager) planNonECSImages(ctx context.Context, plan *image.CleanupPlan, numECSImagesToDelete int) {
+	if numECSImagesToDelete >= imageManager.numImagesToDelete {
+		numECSImagesToDelete = imageManager.numImagesToDelete - 1
+	}
+	nonECSImagesNumToDelete := imageManager.numImagesToDelete - numECSImagesToDelete
+
+	untrackedImages := imageManager.getUntrackedImages(ctx)
+	sort.Slice(untrackedImages, func(i, j int) bool {
+		return untrackedImages[i].Size < untrackedImages[j].Size
+	})
+	plan.NonECSImages = make([]image.CleanupPlanImage, 0, len(untrackedImages))
+	var numImagesToDelete int
+	for _, untracked := range untrackedImages {
+		planned := image.CleanupPlanImage{
+			ImageID: untracked.ImageID,
+			Names:   untracked.RepoTags,
+			Size:    untracked.Size,
+			Action:  image.CleanupKeep,
+		}
+		switch {
+		case plan.DisabledReason != "":
+			planned.Reason = plan.DisabledReason
+		case !imageManager.deleteNonECSImagesEnabled:
+			planned.Reason = "untracked image cleanup is disabled"
+		case imageManager.isProtected(untracked.RepoTags, untracked.ImageID):
+			planned.Reason = "image is on the pre-warm list"
+		case anyIsInExclusionList(untracked.RepoTags, imageManager.imageCleanupExclusionList):
+			planned.Reason = "image is on the cleanup exclusion list"
+		case !imageManager.nonECSImageOldEnough(untracked):
+			planned.Reason = fmt.Sprintf("image was created less than %s ago", imageManager.nonECSMinimumAgeBeforeDeletion)
+		case numImagesToDelete >= nonECSImagesNumToDelete:
+			planned.Reason = fmt.Sprintf("larger than the %d untracked images removed per cycle", nonECSImagesNumToDelete)
+		default:
+			planned.Action = image.CleanupDelete
+			planned.Reason = "image is one of the smallest untracked images"
+			// Images with several tags are removed, and counted, by tag
+			if len(untracked.RepoTags) > 1 {
+				numImagesToDelete += len(untracked.RepoTags)
+			} else {
+				numImagesToDelete++
+			}
+		}
+		plan.NonECSImages = append(plan.NonECSImages, planned)
+	}
+}
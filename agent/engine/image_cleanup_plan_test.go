@@ -0,0 +1,122 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/fake"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planActions maps the first name of each image of the plan to what the plan
+// would do with it
+func planActions(t *testing.T, images []image.CleanupPlanImage) map[string]image.CleanupAction {
+	actions := make(map[string]image.CleanupAction)
+	for _, planned := range images {
+		assert.NotEmpty(t, planned.Reason, planned.ImageID)
+		actions[planned.Names[0]] = planned.Action
+	}
+	return actions
+}
+
+func TestGetImageCleanupPlan(t *testing.T) {
+	client := fake.NewDockerClient()
+	imageManager := &dockerImageManager{
+		client:                    client,
+		state:                     dockerstate.NewTaskEngineState(),
+		minimumAgeBeforeDeletion:  time.Hour,
+		numImagesToDelete:         2,
+		imageCleanupExclusionList: []string{"excluded:latest", "untracked-excluded:latest"},
+		deleteNonECSImagesEnabled: true,
+		protectedImages:           map[string]string{},
+	}
+
+	now := time.Now()
+	for i, name := range []string{"lru-1:latest", "lru-2:latest", "lru-3:latest", "fresh:latest", "in-use:latest", "excluded:latest"} {
+		imageState := &image.ImageState{
+			Image:      &image.Image{ImageID: client.AddImage(name, 100), Names: []string{name}, Size: 100},
+			PulledAt:   now.AddDate(0, 0, -10),
+			LastUsedAt: now.AddDate(0, 0, -10+i),
+		}
+		switch name {
+		case "fresh:latest":
+			imageState.PulledAt = now
+		case "in-use:latest":
+			imageState.Containers = []*apicontainer.Container{{Name: "app"}}
+		}
+		imageManager.AddAllImageStates([]*image.ImageState{imageState})
+	}
+	client.AddImage("untracked-small:latest", 10)
+	client.AddImage("untracked-large:latest", 1000)
+	client.AddImage("untracked-excluded:latest", 1)
+
+	plan := imageManager.GetImageCleanupPlan(context.TODO())
+	assert.Empty(t, plan.DisabledReason)
+	assert.Equal(t, 2, plan.ImagesPerCycle)
+	require.Len(t, plan.Images, 6)
+	assert.Equal(t, "lru-1:latest", plan.Images[0].Names[0], "images are listed least recently used first")
+	assert.Equal(t, map[string]image.CleanupAction{
+		"lru-1:latest":    image.CleanupDelete,
+		"lru-2:latest":    image.CleanupDelete,
+		"lru-3:latest":    image.CleanupKeep,
+		"fresh:latest":    image.CleanupKeep,
+		"in-use:latest":   image.CleanupKeep,
+		"excluded:latest": image.CleanupKeep,
+	}, planActions(t, plan.Images))
+
+	// Removing the full number of images per cycle leaves room for one
+	// untracked image, the smallest one
+	require.Len(t, plan.NonECSImages, 3)
+	assert.Equal(t, "untracked-excluded:latest", plan.NonECSImages[0].Names[0], "untracked images are listed smallest first")
+	assert.Equal(t, map[string]image.CleanupAction{
+		"untracked-excluded:latest": image.CleanupKeep,
+		"untracked-small:latest":    image.CleanupDelete,
+		"untracked-large:latest":    image.CleanupKeep,
+	}, planActions(t, plan.NonECSImages))
+
+	assert.Zero(t, client.CallCount(fake.OperationRemoveImage), "the plan doesn't remove anything")
+	assert.Len(t, imageManager.imageStates, 6)
+}
+
+func TestGetImageCleanupPlanDisabled(t *testing.T) {
+	client := fake.NewDockerClient()
+	cfg := &config.Config{
+		ImagePullBehavior:         config.ImagePullPreferCachedBehavior,
+		NumImagesToDeletePerCycle: 5,
+	}
+	imageManager := NewImageManager(cfg, client, dockerstate.NewTaskEngineState())
+	imageManager.AddAllImageStates([]*image.ImageState{{
+		Image:    &image.Image{ImageID: client.AddImage("busybox:latest", 100), Names: []string{"busybox:latest"}},
+		PulledAt: time.Now().AddDate(0, 0, -10),
+	}})
+	client.AddImage("untracked:latest", 10)
+
+	plan := imageManager.GetImageCleanupPlan(context.TODO())
+	assert.NotEmpty(t, plan.DisabledReason)
+	require.Len(t, plan.Images, 1)
+	assert.Equal(t, image.CleanupKeep, plan.Images[0].Action)
+	assert.Equal(t, plan.DisabledReason, plan.Images[0].Reason)
+	require.Len(t, plan.NonECSImages, 1)
+	assert.Equal(t, image.CleanupKeep, plan.NonECSImages[0].Action)
+}
@@ -0,0 +1,183 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/cihub/seelog"
+)
+
+// ecrImagePattern matches the names of the images hosted in an ECR registry and
+// captures the registry id and the region of the registry
+var ecrImagePattern = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/`)
+
+// StartImagePrewarm pulls the images of the pre-warm list and refreshes the ones
+// with a refresh interval until the context is cancelled. This is a blocking call
+func (imageManager *dockerImageManager) StartImagePrewarm(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, prewarm := range imageManager.imagePrewarmList {
+		wg.Add(1)
+		go func(prewarm config.ImagePrewarm) {
+			defer wg.Done()
+			imageManager.prewarmImage(ctx, prewarm)
+		}(prewarm)
+	}
+	wg.Wait()
+}
+
+// GetImagePrewarmStatus returns the status of the images of the pre-warm list
+func (imageManager *dockerImageManager) GetImagePrewarmStatus() []image.PrewarmStatus {
+	imageManager.prewarmLock.RLock()
+	defer imageManager.prewarmLock.RUnlock()
+
+	statuses := make([]image.PrewarmStatus, 0, len(imageManager.imagePrewarmList))
+	for _, prewarm := range imageManager.imagePrewarmList {
+		statuses = append(statuses, *imageManager.imagePrewarmStatus[prewarm.Image])
+	}
+	return statuses
+}
+
+func (imageManager *dockerImageManager) prewarmImage(ctx context.Context, prewarm config.ImagePrewarm) {
+	imageManager.pullPrewarmImage(ctx, prewarm)
+	if prewarm.RefreshInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(prewarm.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			imageManager.pullPrewarmImage(ctx, prewarm)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pullPrewarmImage pulls a pre-warmed image according to its pull policy and
+// protects it from the image cleanup
+func (imageManager *dockerImageManager) pullPrewarmImage(ctx context.Context, prewarm config.ImagePrewarm) {
+	// Hold the pull lock until the image is protected so that the image cleanup
+	// cannot remove the image in between
+	ImagePullDeleteLock.RLock()
+	defer ImagePullDeleteLock.RUnlock()
+
+	if prewarm.PullPolicy == config.ImagePrewarmPullIfNotPresent {
+		if imageInspected, err := imageManager.client.InspectImage(prewarm.Image); err == nil {
+			seelog.Debugf("Image manager: pre-warmed image %s is already present", prewarm.Image)
+			imageManager.protectImage(prewarm.Image, imageInspected.ID)
+			imageManager.updatePrewarmStatus(prewarm.Image, func(status *image.PrewarmStatus) {
+				status.State = image.PrewarmReady
+				status.ImageID = imageInspected.ID
+				status.LastError = ""
+			})
+			return
+		}
+	}
+
+	seelog.Infof("Image manager: pulling pre-warmed image %s", prewarm.Image)
+	pullStartedAt := time.Now()
+	imageManager.updatePrewarmStatus(prewarm.Image, func(status *image.PrewarmStatus) {
+		status.State = image.PrewarmPulling
+		status.LastPullAttempt = &pullStartedAt
+	})
+
+	metadata := imageManager.client.PullImage(ctx, prewarm.Image,
+		prewarmRegistryAuthData(prewarm.Image), dockerclient.PullImageTimeout)
+	if metadata.Error != nil {
+		seelog.Errorf("Image manager: unable to pull pre-warmed image %s: %v", prewarm.Image, metadata.Error)
+		imageManager.updatePrewarmStatus(prewarm.Image, func(status *image.PrewarmStatus) {
+			status.State = image.PrewarmFailed
+			status.LastError = metadata.Error.Error()
+		})
+		return
+	}
+
+	imageInspected, err := imageManager.client.InspectImage(prewarm.Image)
+	if err != nil {
+		seelog.Errorf("Image manager: unable to inspect pre-warmed image %s: %v", prewarm.Image, err)
+		imageManager.updatePrewarmStatus(prewarm.Image, func(status *image.PrewarmStatus) {
+			status.State = image.PrewarmFailed
+			status.LastError = err.Error()
+		})
+		return
+	}
+
+	seelog.Infof("Image manager: pre-warmed image %s is ready, image id: %s", prewarm.Image, imageInspected.ID)
+	imageManager.protectImage(prewarm.Image, imageInspected.ID)
+	pulledAt := time.Now()
+	imageManager.updatePrewarmStatus(prewarm.Image, func(status *image.PrewarmStatus) {
+		status.State = image.PrewarmReady
+		status.ImageID = imageInspected.ID
+		status.LastPulledAt = &pulledAt
+		status.LastError = ""
+	})
+}
+
+func (imageManager *dockerImageManager) updatePrewarmStatus(imageName string, update func(status *image.PrewarmStatus)) {
+	imageManager.prewarmLock.Lock()
+	defer imageManager.prewarmLock.Unlock()
+
+	update(imageManager.imagePrewarmStatus[imageName])
+}
+
+// protectImage records an image that is never removed by the image cleanup. The
+// image is protected by name and by the id it currently refers to, so that an
+// older image that is replaced by a refresh is no longer protected
+func (imageManager *dockerImageManager) protectImage(imageName string, imageID string) {
+	imageManager.updateLock.Lock()
+	defer imageManager.updateLock.Unlock()
+
+	imageManager.protectedImages[imageName] = imageID
+}
+
+// isProtected returns true if any of the names or the id belongs to a protected
+// image. The update lock must be held by the caller
+func (imageManager *dockerImageManager) isProtected(imageNames []string, imageID string) bool {
+	for protectedName, protectedID := range imageManager.protectedImages {
+		if imageID != "" && imageID == protectedID {
+			return true
+		}
+		if isInExclusionList(protectedName, imageNames) {
+			return true
+		}
+	}
+	return false
+}
+
+// prewarmRegistryAuthData returns the registry auth data that is used to pull a
+// pre-warmed image. Images hosted in ECR are pulled with the instance's
+// credentials, other images are pulled with the engine auth data
+func prewarmRegistryAuthData(imageName string) *apicontainer.RegistryAuthenticationData {
+	matches := ecrImagePattern.FindStringSubmatch(imageName)
+	if matches == nil {
+		return nil
+	}
+	return &apicontainer.RegistryAuthenticationData{
+		Type: apicontainer.AuthTypeECR,
+		ECRAuthData: &apicontainer.ECRAuthData{
+			RegistryID: matches[1],
+			Region:     matches[2],
+		},
+	}
+}
@@ -0,0 +1,163 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/fake"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPrewarmTestImageManager(client *fake.DockerClient, prewarmList []config.ImagePrewarm) *dockerImageManager {
+	cfg := &config.Config{ImagePrewarmList: prewarmList}
+	return NewImageManager(cfg, client, dockerstate.NewTaskEngineState()).(*dockerImageManager)
+}
+
+func TestImagePrewarmPullsAndProtectsImages(t *testing.T) {
+	client := fake.NewDockerClient()
+	presentImageID := client.AddImage("amazonlinux:2", 100)
+	otherImageID := client.AddImage("other:latest", 100)
+	imageManager := newPrewarmTestImageManager(client, []config.ImagePrewarm{
+		{Image: "busybox:latest", PullPolicy: config.ImagePrewarmPullAlways},
+		{Image: "amazonlinux:2", PullPolicy: config.ImagePrewarmPullIfNotPresent},
+	})
+
+	imageManager.StartImagePrewarm(context.Background())
+
+	assert.Equal(t, 1, client.CallCount(fake.OperationPullImage))
+	statuses := imageManager.GetImagePrewarmStatus()
+	require.Len(t, statuses, 2)
+
+	busybox, err := client.InspectImage("busybox:latest")
+	require.NoError(t, err)
+	assert.Equal(t, "busybox:latest", statuses[0].Image)
+	assert.Equal(t, image.PrewarmReady, statuses[0].State)
+	assert.Equal(t, busybox.ID, statuses[0].ImageID)
+	assert.NotNil(t, statuses[0].LastPulledAt)
+
+	assert.Equal(t, "amazonlinux:2", statuses[1].Image)
+	assert.Equal(t, image.PrewarmReady, statuses[1].State)
+	assert.Equal(t, presentImageID, statuses[1].ImageID)
+	assert.Nil(t, statuses[1].LastPullAttempt)
+
+	nonECSImages := imageManager.getNonECSImages(context.Background())
+	require.Len(t, nonECSImages, 1)
+	assert.Equal(t, otherImageID, nonECSImages[0].ImageID)
+}
+
+func TestImagePrewarmPullFailure(t *testing.T) {
+	client := fake.NewDockerClient()
+	client.SetPullError("busybox:latest", errors.New("registry unavailable"))
+	imageManager := newPrewarmTestImageManager(client, []config.ImagePrewarm{
+		{Image: "busybox:latest", PullPolicy: config.ImagePrewarmPullAlways},
+	})
+
+	imageManager.StartImagePrewarm(context.Background())
+
+	statuses := imageManager.GetImagePrewarmStatus()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, image.PrewarmFailed, statuses[0].State)
+	assert.Contains(t, statuses[0].LastError, "registry unavailable")
+	assert.NotNil(t, statuses[0].LastPullAttempt)
+	assert.Nil(t, statuses[0].LastPulledAt)
+	assert.Empty(t, imageManager.protectedImages)
+}
+
+func TestImagePrewarmRefresh(t *testing.T) {
+	client := fake.NewDockerClient()
+	imageManager := newPrewarmTestImageManager(client, []config.ImagePrewarm{
+		{Image: "busybox:latest", PullPolicy: config.ImagePrewarmPullAlways, RefreshInterval: 10 * time.Millisecond},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		imageManager.StartImagePrewarm(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.CallCount(fake.OperationPullImage) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	assert.True(t, client.CallCount(fake.OperationPullImage) >= 3, "pre-warmed image was not refreshed")
+	assert.Equal(t, "10ms", imageManager.GetImagePrewarmStatus()[0].RefreshInterval)
+}
+
+func TestImagePrewarmExcludesImageStatesFromCleanup(t *testing.T) {
+	client := fake.NewDockerClient()
+	imageManager := newPrewarmTestImageManager(client, []config.ImagePrewarm{
+		{Image: "busybox:latest", PullPolicy: config.ImagePrewarmPullAlways},
+	})
+	imageManager.StartImagePrewarm(context.Background())
+	busybox, err := client.InspectImage("busybox:latest")
+	require.NoError(t, err)
+
+	protectedByName := &image.ImageState{Image: &image.Image{ImageID: "sha256:old", Names: []string{"busybox:latest"}}}
+	protectedByID := &image.ImageState{Image: &image.Image{ImageID: busybox.ID, Names: []string{"busybox"}}}
+	unprotected := &image.ImageState{Image: &image.Image{ImageID: "sha256:other", Names: []string{"other:latest"}}}
+
+	assert.True(t, imageManager.isExcludedFromCleanup(protectedByName))
+	assert.True(t, imageManager.isExcludedFromCleanup(protectedByID))
+	assert.False(t, imageManager.isExcludedFromCleanup(unprotected))
+}
+
+func TestPrewarmRegistryAuthData(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected *apicontainer.RegistryAuthenticationData
+	}{
+		{
+			image: "123456789012.dkr.ecr.us-west-2.amazonaws.com/app:latest",
+			expected: &apicontainer.RegistryAuthenticationData{
+				Type:        apicontainer.AuthTypeECR,
+				ECRAuthData: &apicontainer.ECRAuthData{RegistryID: "123456789012", Region: "us-west-2"},
+			},
+		},
+		{
+			image: "123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com/app",
+			expected: &apicontainer.RegistryAuthenticationData{
+				Type:        apicontainer.AuthTypeECR,
+				ECRAuthData: &apicontainer.ECRAuthData{RegistryID: "123456789012", Region: "us-gov-west-1"},
+			},
+		},
+		{
+			image: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn/app",
+			expected: &apicontainer.RegistryAuthenticationData{
+				Type:        apicontainer.AuthTypeECR,
+				ECRAuthData: &apicontainer.ECRAuthData{RegistryID: "123456789012", Region: "cn-north-1"},
+			},
+		},
+		{image: "busybox:latest"},
+		{image: "registry.example.com/123456789012.dkr.ecr.us-west-2.amazonaws.com/app"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			assert.Equal(t, tc.expected, prewarmRegistryAuthData(tc.image))
+		})
+	}
+}
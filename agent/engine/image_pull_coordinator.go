@@ -0,0 +1,218 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/cihub/seelog"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+)
+
+// imagePull is a pull of an image that's in flight. Every container that needs
+// the same image with the same credentials waits for it and gets its result
+type imagePull struct {
+	// done is closed once the pull completes and metadata is set
+	done     chan struct{}
+	metadata dockerapi.DockerContainerMetadata
+	// waiters is the number of containers that share the pull besides the one
+	// that started it
+	waiters int
+	// containers are the containers that share the pull, whose pull progress
+	// is updated as the pull makes progress
+	containers []*apicontainer.Container
+	// progress is the latest progress of the pull, if any
+	progress *apicontainer.PullProgress
+}
+
+// imagePullCoordinator merges the concurrent pulls of the same image into one and
+// limits the number of images that are pulled from the same registry at the
+// same time
+type imagePullCoordinator struct {
+	// maxPullsPerRegistry is the maximum number of concurrent pulls from a
+	// registry. There's no limit if it's 0
+	maxPullsPerRegistry int
+	// pulls maps the key of every pull that's in flight to the pull
+	pulls map[string]*imagePull
+	// registrySlots maps a registry host to a semaphore that holds a slot for
+	// every pull from the registry that's in flight
+	registrySlots map[string]chan struct{}
+	lock          sync.Mutex
+}
+
+// newImagePullCoordinator creates an imagePullCoordinator that allows up to
+// maxPullsPerRegistry concurrent pulls from the same registry
+func newImagePullCoordinator(maxPullsPerRegistry int) *imagePullCoordinator {
+	return &imagePullCoordinator{
+		maxPullsPerRegistry: maxPullsPerRegistry,
+		pulls:               make(map[string]*imagePull),
+		registrySlots:       make(map[string]chan struct{}),
+	}
+}
+
+// pull pulls the image of the container with pullImage, unless a pull of the same
+// image with the same credentials is already in flight, in which case it waits
+// for that pull and returns its result. The context passed to pullImage reports
+// the progress of the pull to every container that shares it
+func (coordinator *imagePullCoordinator) pull(ctx context.Context,
+	container *apicontainer.Container,
+	pullImage func(ctx context.Context) dockerapi.DockerContainerMetadata) dockerapi.DockerContainerMetadata {
+	image, registry := normalizeImageReference(container.Image)
+	key := image + "|" + imagePullAuthKey(container.RegistryAuthentication)
+
+	coordinator.lock.Lock()
+	if inflight, ok := coordinator.pulls[key]; ok {
+		inflight.waiters++
+		inflight.containers = append(inflight.containers, container)
+		if inflight.progress != nil {
+			container.SetPullProgress(*inflight.progress)
+		}
+		coordinator.lock.Unlock()
+		seelog.Infof("Image pull coordinator: waiting for in-flight pull of image %s for container %s",
+			image, container.Name)
+		select {
+		case <-inflight.done:
+			return inflight.metadata
+		case <-ctx.Done():
+			return dockerapi.DockerContainerMetadata{
+				Error: dockerapi.CannotPullContainerError{FromError: errors.Wrapf(ctx.Err(),
+					"image pull coordinator: stopped waiting for pull of image %s", image)},
+			}
+		}
+	}
+	inflight := &imagePull{
+		done:       make(chan struct{}),
+		containers: []*apicontainer.Container{container},
+	}
+	coordinator.pulls[key] = inflight
+	coordinator.lock.Unlock()
+
+	defer func() {
+		coordinator.lock.Lock()
+		delete(coordinator.pulls, key)
+		if inflight.waiters > 0 {
+			seelog.Infof("Image pull coordinator: shared pull of image %s with %d other containers",
+				image, inflight.waiters)
+		}
+		coordinator.lock.Unlock()
+		close(inflight.done)
+	}()
+
+	if err := coordinator.acquireRegistrySlot(ctx, registry); err != nil {
+		inflight.metadata = dockerapi.DockerContainerMetadata{
+			Error: dockerapi.CannotPullContainerError{FromError: errors.Wrapf(err,
+				"image pull coordinator: stopped waiting to pull image %s from registry %s", image, registry)},
+		}
+		return inflight.metadata
+	}
+	defer coordinator.releaseRegistrySlot(registry)
+
+	inflight.metadata = pullImage(dockerapi.WithPullProgressReporter(ctx, func(progress apicontainer.PullProgress) {
+		coordinator.reportProgress(inflight, progress)
+	}))
+	return inflight.metadata
+}
+
+// reportProgress records the progress of a pull on every container that shares it
+func (coordinator *imagePullCoordinator) reportProgress(inflight *imagePull, progress apicontainer.PullProgress) {
+	coordinator.lock.Lock()
+	defer coordinator.lock.Unlock()
+
+	inflight.progress = &progress
+	for _, container := range inflight.containers {
+		container.SetPullProgress(progress)
+	}
+}
+
+// acquireRegistrySlot blocks until fewer than maxPullsPerRegistry pulls from the
+// registry are in flight and takes a slot for a new pull
+func (coordinator *imagePullCoordinator) acquireRegistrySlot(ctx context.Context, registry string) error {
+	if coordinator.maxPullsPerRegistry <= 0 {
+		return nil
+	}
+	select {
+	case coordinator.slots(registry) <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (coordinator *imagePullCoordinator) releaseRegistrySlot(registry string) {
+	if coordinator.maxPullsPerRegistry <= 0 {
+		return
+	}
+	<-coordinator.slots(registry)
+}
+
+func (coordinator *imagePullCoordinator) slots(registry string) chan struct{} {
+	coordinator.lock.Lock()
+	defer coordinator.lock.Unlock()
+
+	slots, ok := coordinator.registrySlots[registry]
+	if !ok {
+		slots = make(chan struct{}, coordinator.maxPullsPerRegistry)
+		coordinator.registrySlots[registry] = slots
+	}
+	return slots
+}
+
+// normalizeImageReference returns the fully qualified reference of an image and
+// the host of its registry, so that e.g. "busybox" and
+// "docker.io/library/busybox:latest" are pulled once. The tag of a reference
+// that has a digest is dropped since the digest alone determines what's pulled
+func normalizeImageReference(image string) (string, string) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image, ""
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		trimmed, err := reference.WithDigest(reference.TrimNamed(named), canonical.Digest())
+		if err == nil {
+			return trimmed.String(), reference.Domain(named)
+		}
+	}
+	return reference.TagNameOnly(named).String(), reference.Domain(named)
+}
+
+// imagePullAuthKey identifies the credentials an image is pulled with. Pulls are
+// only shared by containers that use the same credentials, so that a container
+// never gets an image it isn't allowed to pull
+func imagePullAuthKey(authData *apicontainer.RegistryAuthenticationData) string {
+	if authData == nil {
+		return ""
+	}
+	switch authData.Type {
+	case apicontainer.AuthTypeECR:
+		if authData.ECRAuthData == nil {
+			return authData.Type
+		}
+		ecrAuthData := authData.ECRAuthData
+		return fmt.Sprintf("%s/%s/%s/%s/%s", authData.Type, ecrAuthData.RegistryID, ecrAuthData.Region,
+			ecrAuthData.EndpointOverride, ecrAuthData.GetPullCredentials().RoleArn)
+	case apicontainer.AuthTypeASM:
+		if authData.ASMAuthData == nil {
+			return authData.Type
+		}
+		return fmt.Sprintf("%s/%s/%s", authData.Type, authData.ASMAuthData.Region,
+			authData.ASMAuthData.CredentialsParameter)
+	default:
+		return authData.Type
+	}
+}
@@ -0,0 +1,292 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPull returns a pull function that counts its calls and blocks until
+// release is closed
+func blockingPull(calls *int32, release <-chan struct{}, metadata dockerapi.DockerContainerMetadata) func(ctx context.Context) dockerapi.DockerContainerMetadata {
+	return func(ctx context.Context) dockerapi.DockerContainerMetadata {
+		atomic.AddInt32(calls, 1)
+		<-release
+		return metadata
+	}
+}
+
+// waitForWaiters waits until the in-flight pull of the image has the number of waiters
+func waitForWaiters(t *testing.T, coordinator *imagePullCoordinator, key string, waiters int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		coordinator.lock.Lock()
+		inflight, ok := coordinator.pulls[key]
+		found := ok && inflight.waiters == waiters
+		coordinator.lock.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters on pull %s", waiters, key)
+}
+
+func TestImagePullCoordinatorMergesPullsOfSameImage(t *testing.T) {
+	coordinator := newImagePullCoordinator(0)
+	pullErr := dockerapi.CannotPullContainerError{FromError: errors.New("registry unavailable")}
+	release := make(chan struct{})
+	var calls int32
+
+	images := []string{"busybox", "busybox:latest", "docker.io/library/busybox:latest"}
+	results := make([]dockerapi.DockerContainerMetadata, 20)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		container := &apicontainer.Container{Name: "c0", Image: images[0]}
+		results[0] = coordinator.pull(context.Background(), container,
+			blockingPull(&calls, release, dockerapi.DockerContainerMetadata{Error: pullErr}))
+	}()
+	waitForWaiters(t, coordinator, "docker.io/library/busybox:latest|", 0)
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			container := &apicontainer.Container{Name: fmt.Sprintf("c%d", i), Image: images[i%len(images)]}
+			results[i] = coordinator.pull(context.Background(), container,
+				blockingPull(&calls, release, dockerapi.DockerContainerMetadata{}))
+		}(i)
+	}
+	waitForWaiters(t, coordinator, "docker.io/library/busybox:latest|", len(results)-1)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls)
+	for _, result := range results {
+		assert.Equal(t, pullErr, result.Error)
+	}
+	assert.Empty(t, coordinator.pulls)
+}
+
+func TestImagePullCoordinatorDoesNotMergePullsWithDifferentCredentials(t *testing.T) {
+	coordinator := newImagePullCoordinator(0)
+	release := make(chan struct{})
+	var calls int32
+
+	ecrAuthData := func(roleArn string) *apicontainer.RegistryAuthenticationData {
+		authData := &apicontainer.RegistryAuthenticationData{
+			Type: apicontainer.AuthTypeECR,
+			ECRAuthData: &apicontainer.ECRAuthData{
+				RegistryID: "123456789012",
+				Region:     "us-west-2",
+			},
+		}
+		authData.ECRAuthData.SetPullCredentials(credentials.IAMRoleCredentials{RoleArn: roleArn})
+		return authData
+	}
+	containers := []*apicontainer.Container{
+		{Name: "role1", Image: "123456789012.dkr.ecr.us-west-2.amazonaws.com/app", RegistryAuthentication: ecrAuthData("role1")},
+		{Name: "role2", Image: "123456789012.dkr.ecr.us-west-2.amazonaws.com/app", RegistryAuthentication: ecrAuthData("role2")},
+		{Name: "none", Image: "123456789012.dkr.ecr.us-west-2.amazonaws.com/app"},
+	}
+
+	var wg sync.WaitGroup
+	for _, container := range containers {
+		wg.Add(1)
+		go func(container *apicontainer.Container) {
+			defer wg.Done()
+			coordinator.pull(context.Background(), container, blockingPull(&calls, release, dockerapi.DockerContainerMetadata{}))
+		}(container)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < int32(len(containers)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, len(containers), calls)
+}
+
+func TestImagePullCoordinatorReportsProgressToAllContainers(t *testing.T) {
+	coordinator := newImagePullCoordinator(0)
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	leader := &apicontainer.Container{Name: "leader", Image: "busybox"}
+	waiter := &apicontainer.Container{Name: "waiter", Image: "busybox"}
+	late := &apicontainer.Container{Name: "late", Image: "busybox"}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		coordinator.pull(context.Background(), leader, func(ctx context.Context) dockerapi.DockerContainerMetadata {
+			reporter, ok := dockerapi.PullProgressReporterFromContext(ctx)
+			require.True(t, ok)
+			<-release
+			reporter(apicontainer.PullProgress{Layers: 2, LayersDone: 1, BytesDownloaded: 10, BytesTotal: 20})
+			close(reported)
+			<-release
+			return dockerapi.DockerContainerMetadata{}
+		})
+	}()
+	waitForWaiters(t, coordinator, "docker.io/library/busybox:latest|", 0)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		coordinator.pull(context.Background(), waiter, blockingPull(new(int32), release, dockerapi.DockerContainerMetadata{}))
+	}()
+	waitForWaiters(t, coordinator, "docker.io/library/busybox:latest|", 1)
+	assert.Nil(t, leader.GetPullProgress())
+	release <- struct{}{}
+	<-reported
+
+	expected := &apicontainer.PullProgress{Layers: 2, LayersDone: 1, BytesDownloaded: 10, BytesTotal: 20}
+	assert.Equal(t, expected, leader.GetPullProgress())
+	assert.Equal(t, expected, waiter.GetPullProgress())
+
+	// A container that joins the pull later starts with its latest progress
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		coordinator.pull(context.Background(), late, blockingPull(new(int32), release, dockerapi.DockerContainerMetadata{}))
+	}()
+	waitForWaiters(t, coordinator, "docker.io/library/busybox:latest|", 2)
+	assert.Equal(t, expected, late.GetPullProgress())
+	close(release)
+	wg.Wait()
+}
+
+func TestImagePullCoordinatorLimitsPullsPerRegistry(t *testing.T) {
+	coordinator := newImagePullCoordinator(2)
+	var inflight, maxInflight int32
+	pull := func(ctx context.Context) dockerapi.DockerContainerMetadata {
+		current := atomic.AddInt32(&inflight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInflight, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inflight, -1)
+		return dockerapi.DockerContainerMetadata{}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			container := &apicontainer.Container{Name: "app", Image: fmt.Sprintf("registry.example.com/app%d", i)}
+			coordinator.pull(context.Background(), container, pull)
+		}(i)
+	}
+	wg.Wait()
+	assert.EqualValues(t, 2, maxInflight)
+}
+
+func TestImagePullCoordinatorDoesNotLimitOtherRegistries(t *testing.T) {
+	coordinator := newImagePullCoordinator(1)
+	release := make(chan struct{})
+	var calls int32
+
+	done := make(chan struct{})
+	go func() {
+		container := &apicontainer.Container{Name: "blocked", Image: "registry.example.com/app"}
+		coordinator.pull(context.Background(), container, blockingPull(&calls, release, dockerapi.DockerContainerMetadata{}))
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	container := &apicontainer.Container{Name: "other", Image: "busybox"}
+	metadata := coordinator.pull(context.Background(), container, func(ctx context.Context) dockerapi.DockerContainerMetadata {
+		return dockerapi.DockerContainerMetadata{}
+	})
+	assert.NoError(t, metadata.Error)
+	close(release)
+	<-done
+}
+
+func TestImagePullCoordinatorStopsWaitingForRegistrySlot(t *testing.T) {
+	coordinator := newImagePullCoordinator(1)
+	release := make(chan struct{})
+	defer close(release)
+	var calls int32
+
+	go func() {
+		container := &apicontainer.Container{Name: "blocked", Image: "registry.example.com/app"}
+		coordinator.pull(context.Background(), container, blockingPull(&calls, release, dockerapi.DockerContainerMetadata{}))
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	container := &apicontainer.Container{Name: "waiting", Image: "registry.example.com/other"}
+	metadata := coordinator.pull(ctx, container, blockingPull(&calls, release, dockerapi.DockerContainerMetadata{}))
+	require.Error(t, metadata.Error)
+	assert.IsType(t, dockerapi.CannotPullContainerError{}, metadata.Error)
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestNormalizeImageReference(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected string
+		registry string
+	}{
+		{"busybox", "docker.io/library/busybox:latest", "docker.io"},
+		{"busybox:1.31", "docker.io/library/busybox:1.31", "docker.io"},
+		{"amazon/amazon-ecs-agent:latest", "docker.io/amazon/amazon-ecs-agent:latest", "docker.io"},
+		{"localhost:5000/app", "localhost:5000/app:latest", "localhost:5000"},
+		{
+			"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1",
+			"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1",
+			"123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		},
+		{
+			"busybox:1.31@sha256:fc4a9d0a5b82e2b6bb5b6e58a2b7b3a0c4d0b6cf4bbf1e3b0ed4e7b8b8a5b2b0",
+			"docker.io/library/busybox@sha256:fc4a9d0a5b82e2b6bb5b6e58a2b7b3a0c4d0b6cf4bbf1e3b0ed4e7b8b8a5b2b0",
+			"docker.io",
+		},
+		{"Invalid:Image:Name", "Invalid:Image:Name", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			image, registry := normalizeImageReference(tc.image)
+			assert.Equal(t, tc.expected, image)
+			assert.Equal(t, tc.registry, registry)
+		})
+	}
+}
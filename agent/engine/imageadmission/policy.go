@@ -0,0 +1,259 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package imageadmission implements the local image admission policy, which the
+// images of containers are checked against after they're pulled and before the
+// containers are created.
+package imageadmission
+
+import (
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SignatureStoreFile indicates that the signatures of an image are stored as
+	// detached signatures of its digest, in the "<algorithm>-<hex>.sig" file of
+	// the store directory. Every line of the file is a base64 encoded signature
+	SignatureStoreFile SignatureStoreType = "file"
+	// SignatureStoreOCILayout indicates that the signatures of an image are
+	// stored as OCI artifacts in an OCI image layout, tagged "<algorithm>-<hex>.sig"
+	SignatureStoreOCILayout SignatureStoreType = "oci-layout"
+
+	// repositoryWildcardSuffix is the suffix of a repository of the policy that
+	// matches every repository under its prefix, e.g. "docker.io/myorg/*"
+	repositoryWildcardSuffix = "/*"
+	// registryWildcardPrefix is the prefix of an allowed registry that matches
+	// every subdomain of the registry, e.g. "*.dkr.ecr.us-west-2.amazonaws.com"
+	registryWildcardPrefix = "*."
+)
+
+// SignatureStoreType is the type of the store of the signatures of images
+type SignatureStoreType string
+
+// Policy is the image admission policy
+type Policy struct {
+	// AllowedRegistries are the registries images can be pulled from. Images
+	// can be pulled from any registry if it's empty
+	AllowedRegistries []string
+	// Repositories are the rules that apply to the images of some repositories.
+	// The most specific rule that matches the repository of an image applies
+	Repositories []RepositoryPolicy
+	// SignatureStore is where the signatures of images are stored. It's
+	// required if any repository requires its images to be signed
+	SignatureStore SignatureStoreConfig
+
+	store signatureStore
+}
+
+// RepositoryPolicy is the rule that applies to the images of a repository
+type RepositoryPolicy struct {
+	// Repository is the fully qualified name of the repository, e.g.
+	// "docker.io/library/busybox". A name ending in "/*" matches every
+	// repository under its prefix
+	Repository string
+	// Digests pins the images of the repository. Images must resolve to one
+	// of these digests if it's not empty
+	Digests []string
+	// PublicKeys are the paths of the PEM encoded public keys that images of the
+	// repository must be signed with. Images must have a signature of their
+	// digest made by one of these keys if it's not empty
+	PublicKeys []string
+
+	publicKeys []crypto.PublicKey
+}
+
+// SignatureStoreConfig is the configuration of the store of the signatures of
+// images
+type SignatureStoreConfig struct {
+	// Type is the type of the store
+	Type SignatureStoreType
+	// Path is the directory of the store
+	Path string
+}
+
+// LoadPolicy loads the image admission policy from a JSON file, along with the
+// public keys it refers to
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "image admission policy: unable to read policy file %s", path)
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, errors.Wrapf(err, "image admission policy: unable to parse policy file %s", path)
+	}
+	if err := policy.init(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// init validates the policy, loads the public keys of the repositories and
+// creates the signature store
+func (policy *Policy) init() error {
+	requiresSignatures := false
+	for i := range policy.Repositories {
+		repository := &policy.Repositories[i]
+		if repository.Repository == "" {
+			return errors.New("image admission policy: repository without a name")
+		}
+		for _, pinned := range repository.Digests {
+			if _, err := digest.Parse(pinned); err != nil {
+				return errors.Wrapf(err, "image admission policy: invalid digest %s for repository %s",
+					pinned, repository.Repository)
+			}
+		}
+		for _, keyPath := range repository.PublicKeys {
+			key, err := loadPublicKey(keyPath)
+			if err != nil {
+				return errors.Wrapf(err, "image admission policy: unable to load public key for repository %s",
+					repository.Repository)
+			}
+			repository.publicKeys = append(repository.publicKeys, key)
+		}
+		requiresSignatures = requiresSignatures || len(repository.publicKeys) > 0
+	}
+	if !requiresSignatures {
+		return nil
+	}
+
+	switch policy.SignatureStore.Type {
+	case SignatureStoreFile:
+		policy.store = &fileSignatureStore{dir: policy.SignatureStore.Path}
+	case SignatureStoreOCILayout:
+		policy.store = &ociLayoutSignatureStore{dir: policy.SignatureStore.Path}
+	default:
+		return errors.Errorf("image admission policy: invalid signature store type: %q", policy.SignatureStore.Type)
+	}
+	if policy.SignatureStore.Path == "" {
+		return errors.New("image admission policy: signature store without a path")
+	}
+	return nil
+}
+
+// RequiresDigest returns true if the digest of the image has to be known to
+// check it against the policy
+func (policy *Policy) RequiresDigest(image string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	repository := policy.repositoryPolicy(named.Name())
+	return repository != nil && (len(repository.Digests) > 0 || len(repository.publicKeys) > 0)
+}
+
+// Check returns an error if the image, which resolved to imageDigest when it was
+// pulled, isn't admitted by the policy
+func (policy *Policy) Check(image string, imageDigest string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return errors.Wrapf(err, "invalid image reference %s", image)
+	}
+	registry := reference.Domain(named)
+	if !policy.registryAllowed(registry) {
+		return errors.Errorf("registry %s is not allowed", registry)
+	}
+	if canonical, ok := named.(reference.Canonical); ok && imageDigest != "" &&
+		canonical.Digest().String() != imageDigest {
+		return errors.Errorf("image resolved to digest %s instead of the digest it references", imageDigest)
+	}
+
+	repository := policy.repositoryPolicy(named.Name())
+	if repository == nil || (len(repository.Digests) == 0 && len(repository.publicKeys) == 0) {
+		return nil
+	}
+	if imageDigest == "" {
+		return errors.Errorf("digest of the image could not be resolved, it's required by the policy of repository %s",
+			repository.Repository)
+	}
+	if _, err := digest.Parse(imageDigest); err != nil {
+		return errors.Wrapf(err, "invalid image digest %s", imageDigest)
+	}
+	if len(repository.Digests) > 0 && !contains(repository.Digests, imageDigest) {
+		return errors.Errorf("digest %s is not one of the digests pinned for repository %s",
+			imageDigest, repository.Repository)
+	}
+	if len(repository.publicKeys) == 0 {
+		return nil
+	}
+
+	signatures, err := policy.store.signatures(imageDigest)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read the signatures of digest %s", imageDigest)
+	}
+	for _, signature := range signatures {
+		for _, key := range repository.publicKeys {
+			if verifySignature(key, signature.payload, signature.signature) {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf("no valid signature of digest %s by the public keys of repository %s (%d signatures found)",
+		imageDigest, repository.Repository, len(signatures))
+}
+
+// registryAllowed returns true if images can be pulled from the registry
+func (policy *Policy) registryAllowed(registry string) bool {
+	if len(policy.AllowedRegistries) == 0 {
+		return true
+	}
+	for _, allowed := range policy.AllowedRegistries {
+		if allowed == registry {
+			return true
+		}
+		if strings.HasPrefix(allowed, registryWildcardPrefix) &&
+			strings.HasSuffix(registry, allowed[len(registryWildcardPrefix)-1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// repositoryPolicy returns the most specific rule that matches the repository,
+// or nil if there's none. A rule for the exact repository is more specific than
+// any wildcard rule, and longer wildcard prefixes are more specific than shorter ones
+func (policy *Policy) repositoryPolicy(name string) *RepositoryPolicy {
+	var match *RepositoryPolicy
+	matchLength := -1
+	for i := range policy.Repositories {
+		repository := &policy.Repositories[i]
+		if repository.Repository == name {
+			return repository
+		}
+		if !strings.HasSuffix(repository.Repository, repositoryWildcardSuffix) {
+			continue
+		}
+		prefix := strings.TrimSuffix(repository.Repository, "*")
+		if strings.HasPrefix(name, prefix) && len(prefix) > matchLength {
+			match = repository
+			matchLength = len(prefix)
+		}
+	}
+	return match
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
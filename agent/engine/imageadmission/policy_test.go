@@ -0,0 +1,137 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package imageadmission
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDigest      = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testOtherDigest = "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+)
+
+func writePolicy(t *testing.T, dir string, policy interface{}) string {
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = LoadPolicy(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	invalidJSON := filepath.Join(dir, "invalid.json")
+	require.NoError(t, ioutil.WriteFile(invalidJSON, []byte("{"), 0600))
+	_, err = LoadPolicy(invalidJSON)
+	assert.Error(t, err)
+
+	for name, policy := range map[string]Policy{
+		"repository without a name": {Repositories: []RepositoryPolicy{{Digests: []string{testDigest}}}},
+		"invalid digest":            {Repositories: []RepositoryPolicy{{Repository: "docker.io/library/busybox", Digests: []string{"latest"}}}},
+		"missing public key": {Repositories: []RepositoryPolicy{{
+			Repository: "docker.io/library/busybox",
+			PublicKeys: []string{filepath.Join(dir, "missing.pem")},
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadPolicy(writePolicy(t, dir, policy))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadPolicySignatureStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath, _ := writeECDSAKey(t, dir)
+
+	repositories := []RepositoryPolicy{{Repository: "docker.io/library/busybox", PublicKeys: []string{keyPath}}}
+	_, err = LoadPolicy(writePolicy(t, dir, Policy{Repositories: repositories}))
+	assert.Error(t, err, "a signature store is required to verify signatures")
+	_, err = LoadPolicy(writePolicy(t, dir, Policy{
+		Repositories:   repositories,
+		SignatureStore: SignatureStoreConfig{Type: SignatureStoreFile},
+	}))
+	assert.Error(t, err, "a signature store requires a path")
+
+	policy, err := LoadPolicy(writePolicy(t, dir, Policy{
+		Repositories:   repositories,
+		SignatureStore: SignatureStoreConfig{Type: SignatureStoreOCILayout, Path: dir},
+	}))
+	require.NoError(t, err)
+	assert.IsType(t, &ociLayoutSignatureStore{}, policy.store)
+	assert.Len(t, policy.Repositories[0].publicKeys, 1)
+}
+
+func TestCheckAllowedRegistries(t *testing.T) {
+	policy := &Policy{AllowedRegistries: []string{"docker.io", "*.dkr.ecr.us-west-2.amazonaws.com"}}
+	require.NoError(t, policy.init())
+
+	for image, allowed := range map[string]bool{
+		"busybox":                          true,
+		"docker.io/library/busybox:latest": true,
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:1": true,
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com/app:1": false,
+		"registry.example.com/app":                           false,
+		"dkr.ecr.us-west-2.amazonaws.com/app":                false,
+	} {
+		err := policy.Check(image, "")
+		if allowed {
+			assert.NoError(t, err, image)
+		} else {
+			assert.Error(t, err, image)
+		}
+	}
+}
+
+func TestCheckPinnedDigests(t *testing.T) {
+	policy := &Policy{Repositories: []RepositoryPolicy{
+		{Repository: "docker.io/library/busybox", Digests: []string{testDigest}},
+		{Repository: "docker.io/myorg/*", Digests: []string{testOtherDigest}},
+		{Repository: "docker.io/myorg/unpinned"},
+	}}
+	require.NoError(t, policy.init())
+
+	assert.True(t, policy.RequiresDigest("busybox:latest"))
+	assert.True(t, policy.RequiresDigest("myorg/app"))
+	assert.False(t, policy.RequiresDigest("myorg/unpinned"))
+	assert.False(t, policy.RequiresDigest("amazonlinux:2"))
+
+	assert.NoError(t, policy.Check("busybox:latest", testDigest))
+	assert.Error(t, policy.Check("busybox:latest", testOtherDigest))
+	assert.Error(t, policy.Check("busybox:latest", ""), "the digest is required to check the pin")
+	assert.NoError(t, policy.Check("myorg/app", testOtherDigest))
+	assert.Error(t, policy.Check("myorg/app", testDigest))
+	assert.NoError(t, policy.Check("myorg/unpinned", testDigest), "the exact rule takes precedence over the wildcard")
+	assert.NoError(t, policy.Check("amazonlinux:2", ""))
+	assert.Error(t, policy.Check("busybox@"+testDigest, testOtherDigest),
+		"the resolved digest must match the referenced one")
+}
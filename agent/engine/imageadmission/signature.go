@@ -0,0 +1,222 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package imageadmission
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// signatureTagSuffix is the suffix of the files and tags the signatures of
+	// a digest are stored under, e.g. "sha256-<hex>.sig"
+	signatureTagSuffix = ".sig"
+	// ociSignatureAnnotation is the annotation of the layers of a signature
+	// artifact that holds the base64 encoded signature of the layer
+	ociSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// signature is a signature of a payload that binds it to the digest of an image
+type signature struct {
+	payload   []byte
+	signature []byte
+}
+
+// signatureStore looks up the signatures of the digest of an image
+type signatureStore interface {
+	signatures(imageDigest string) ([]signature, error)
+}
+
+// signatureTag returns the name the signatures of a digest are stored under
+func signatureTag(imageDigest string) string {
+	return strings.Replace(imageDigest, ":", "-", 1) + signatureTagSuffix
+}
+
+// fileSignatureStore reads detached signatures of the digests of images from
+// files. The signed payload is the digest itself
+type fileSignatureStore struct {
+	dir string
+}
+
+func (store *fileSignatureStore) signatures(imageDigest string) ([]signature, error) {
+	file, err := os.Open(filepath.Join(store.dir, signatureTag(imageDigest)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var signatures []signature
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid signature in %s", file.Name())
+		}
+		signatures = append(signatures, signature{payload: []byte(imageDigest), signature: decoded})
+	}
+	return signatures, scanner.Err()
+}
+
+// simpleSigningPayload is the part of the payload of a signature artifact that
+// binds it to the digest of an image
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// ociLayoutSignatureStore reads the signatures of the digests of images from
+// the signature artifacts of an OCI image layout. Every layer of an artifact is
+// a signed payload that names the digest, and its signature is one of the
+// annotations of the layer
+type ociLayoutSignatureStore struct {
+	dir string
+}
+
+func (store *ociLayoutSignatureStore) signatures(imageDigest string) ([]signature, error) {
+	var index ocispec.Index
+	if err := store.readJSON(filepath.Join(store.dir, "index.json"), &index); err != nil {
+		return nil, err
+	}
+
+	tag := signatureTag(imageDigest)
+	var signatures []signature
+	for _, descriptor := range index.Manifests {
+		if descriptor.Annotations[ocispec.AnnotationRefName] != tag {
+			continue
+		}
+		manifestData, err := store.readBlob(descriptor)
+		if err != nil {
+			return nil, err
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(manifestData, &manifest); err != nil {
+			return nil, errors.Wrapf(err, "invalid signature manifest %s", descriptor.Digest)
+		}
+		for _, layer := range manifest.Layers {
+			encoded, ok := layer.Annotations[ociSignatureAnnotation]
+			if !ok {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid signature in layer %s", layer.Digest)
+			}
+			payload, err := store.readBlob(layer)
+			if err != nil {
+				return nil, err
+			}
+			var signed simpleSigningPayload
+			if err := json.Unmarshal(payload, &signed); err != nil ||
+				signed.Critical.Image.DockerManifestDigest != imageDigest {
+				// The payload doesn't bind the signature to this digest
+				continue
+			}
+			signatures = append(signatures, signature{payload: payload, signature: decoded})
+		}
+	}
+	return signatures, nil
+}
+
+// readBlob reads the blob of the descriptor and verifies its digest
+func (store *ociLayoutSignatureStore) readBlob(descriptor ocispec.Descriptor) ([]byte, error) {
+	if err := descriptor.Digest.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid blob digest %s", descriptor.Digest)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(store.dir, "blobs",
+		descriptor.Digest.Algorithm().String(), descriptor.Digest.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	if descriptor.Digest.Algorithm().FromBytes(data) != descriptor.Digest {
+		return nil, errors.Errorf("content of blob %s doesn't match its digest", descriptor.Digest)
+	}
+	return data, nil
+}
+
+func (store *ociLayoutSignatureStore) readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadPublicKey loads a PEM encoded ECDSA or RSA public key
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("no PEM encoded key in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid public key in %s", path)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported type of public key in %s: %T", path, key)
+	}
+}
+
+// verifySignature returns true if the signature is a valid signature of the
+// SHA-256 hash of the payload made by the key
+func verifySignature(key crypto.PublicKey, payload []byte, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var ecdsaSignature struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(sig, &ecdsaSignature)
+		if err != nil || len(rest) > 0 {
+			return false
+		}
+		return ecdsa.Verify(key, hash[:], ecdsaSignature.R, ecdsaSignature.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil
+	default:
+		return false
+	}
+}
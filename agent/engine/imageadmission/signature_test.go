@@ -0,0 +1,178 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package imageadmission
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePublicKey(t *testing.T, dir string, name string, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return path
+}
+
+// writeECDSAKey generates an ECDSA key and writes its public key to the
+// directory. It returns the path of the public key and a function that signs
+// payloads with the key
+func writeECDSAKey(t *testing.T, dir string) (string, func([]byte) []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return writePublicKey(t, dir, "ecdsa.pem", &key.PublicKey), func(payload []byte) []byte {
+		hash := sha256.Sum256(payload)
+		sig, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+		require.NoError(t, err)
+		return sig
+	}
+}
+
+func writeRSAKey(t *testing.T, dir string) (string, func([]byte) []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return writePublicKey(t, dir, "rsa.pem", &key.PublicKey), func(payload []byte) []byte {
+		hash := sha256.Sum256(payload)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		require.NoError(t, err)
+		return sig
+	}
+}
+
+func signaturePolicy(t *testing.T, dir string, storeType SignatureStoreType, keyPaths ...string) *Policy {
+	policy := &Policy{
+		Repositories: []RepositoryPolicy{
+			{Repository: "docker.io/library/busybox", PublicKeys: keyPaths},
+		},
+		SignatureStore: SignatureStoreConfig{Type: storeType, Path: dir},
+	}
+	require.NoError(t, policy.init())
+	return policy
+}
+
+func TestCheckFileSignatures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ecdsaKeyPath, signECDSA := writeECDSAKey(t, dir)
+	rsaKeyPath, signRSA := writeRSAKey(t, dir)
+	otherDir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(otherDir)
+	_, signOther := writeECDSAKey(t, otherDir)
+
+	policy := signaturePolicy(t, dir, SignatureStoreFile, ecdsaKeyPath, rsaKeyPath)
+	assert.Error(t, policy.Check("busybox", testDigest), "images without signatures aren't admitted")
+
+	signatureFile := filepath.Join(dir, "sha256-"+digest.Digest(testDigest).Hex()+".sig")
+	require.NoError(t, ioutil.WriteFile(signatureFile,
+		[]byte(base64.StdEncoding.EncodeToString(signOther([]byte(testDigest)))+"\n"), 0600))
+	assert.Error(t, policy.Check("busybox", testDigest), "signatures by other keys aren't accepted")
+
+	for name, sign := range map[string]func([]byte) []byte{"ecdsa": signECDSA, "rsa": signRSA} {
+		t.Run(name, func(t *testing.T) {
+			content := fmt.Sprintf("%s\n\n%s\n",
+				base64.StdEncoding.EncodeToString(signOther([]byte(testDigest))),
+				base64.StdEncoding.EncodeToString(sign([]byte(testDigest))))
+			require.NoError(t, ioutil.WriteFile(signatureFile, []byte(content), 0600))
+			assert.NoError(t, policy.Check("busybox", testDigest))
+			assert.Error(t, policy.Check("busybox", testOtherDigest), "signatures are bound to a digest")
+		})
+	}
+}
+
+// writeBlob writes the blob to the OCI image layout and returns its descriptor
+func writeBlob(t *testing.T, dir string, data []byte, annotations map[string]string) ocispec.Descriptor {
+	blobDigest := digest.FromBytes(data)
+	blobDir := filepath.Join(dir, "blobs", blobDigest.Algorithm().String())
+	require.NoError(t, os.MkdirAll(blobDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blobDir, blobDigest.Hex()), data, 0600))
+	return ocispec.Descriptor{Digest: blobDigest, Size: int64(len(data)), Annotations: annotations}
+}
+
+// writeSignatureArtifact writes a signature artifact of the digest, with a layer
+// per payload, to the OCI image layout
+func writeSignatureArtifact(t *testing.T, dir string, imageDigest string, sign func([]byte) []byte, payloads ...[]byte) {
+	var layers []ocispec.Descriptor
+	for _, payload := range payloads {
+		layers = append(layers, writeBlob(t, dir, payload, map[string]string{
+			ociSignatureAnnotation: base64.StdEncoding.EncodeToString(sign(payload)),
+		}))
+	}
+	manifest, err := json.Marshal(ocispec.Manifest{Layers: layers})
+	require.NoError(t, err)
+	manifestDescriptor := writeBlob(t, dir, manifest, map[string]string{
+		ocispec.AnnotationRefName: signatureTag(imageDigest),
+	})
+	index, err := json.Marshal(ocispec.Index{Manifests: []ocispec.Descriptor{manifestDescriptor}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "index.json"), index, 0600))
+}
+
+func simpleSigningPayloadOf(imageDigest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"docker.io/library/busybox"},`+
+		`"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, imageDigest))
+}
+
+func TestCheckOCILayoutSignatures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath, sign := writeECDSAKey(t, dir)
+	policy := signaturePolicy(t, dir, SignatureStoreOCILayout, keyPath)
+
+	writeSignatureArtifact(t, dir, testDigest, sign, simpleSigningPayloadOf(testOtherDigest))
+	assert.Error(t, policy.Check("busybox", testDigest), "the payload must name the digest")
+
+	writeSignatureArtifact(t, dir, testDigest, sign, simpleSigningPayloadOf(testOtherDigest),
+		simpleSigningPayloadOf(testDigest))
+	assert.NoError(t, policy.Check("busybox", testDigest))
+	assert.Error(t, policy.Check("busybox", testOtherDigest), "there's no artifact tagged for the digest")
+}
+
+func TestCheckOCILayoutTamperedBlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-admission")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyPath, sign := writeECDSAKey(t, dir)
+	policy := signaturePolicy(t, dir, SignatureStoreOCILayout, keyPath)
+
+	payload := simpleSigningPayloadOf(testDigest)
+	writeSignatureArtifact(t, dir, testDigest, sign, payload)
+	payloadDigest := digest.FromBytes(payload)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", payloadDigest.Hex()),
+		simpleSigningPayloadOf(testDigest+" "), 0600))
+	assert.Error(t, policy.Check("busybox", testDigest))
+}
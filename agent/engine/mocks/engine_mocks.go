@@ -238,6 +238,34 @@ func (mr *MockImageManagerMockRecorder) AddAllImageStates(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAllImageStates", reflect.TypeOf((*MockImageManager)(nil).AddAllImageStates), arg0)
 }
 
+// GetImageCleanupPlan mocks base method
+func (m *MockImageManager) GetImageCleanupPlan(arg0 context.Context) image.CleanupPlan {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageCleanupPlan", arg0)
+	ret0, _ := ret[0].(image.CleanupPlan)
+	return ret0
+}
+
+// GetImageCleanupPlan indicates an expected call of GetImageCleanupPlan
+func (mr *MockImageManagerMockRecorder) GetImageCleanupPlan(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageCleanupPlan", reflect.TypeOf((*MockImageManager)(nil).GetImageCleanupPlan), arg0)
+}
+
+// GetImagePrewarmStatus mocks base method
+func (m *MockImageManager) GetImagePrewarmStatus() []image.PrewarmStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImagePrewarmStatus")
+	ret0, _ := ret[0].([]image.PrewarmStatus)
+	return ret0
+}
+
+// GetImagePrewarmStatus indicates an expected call of GetImagePrewarmStatus
+func (mr *MockImageManagerMockRecorder) GetImagePrewarmStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImagePrewarmStatus", reflect.TypeOf((*MockImageManager)(nil).GetImagePrewarmStatus))
+}
+
 // GetImageStateFromImageName mocks base method
 func (m *MockImageManager) GetImageStateFromImageName(arg0 string) (*image.ImageState, bool) {
 	m.ctrl.T.Helper()
@@ -304,3 +332,15 @@ func (mr *MockImageManagerMockRecorder) StartImageCleanupProcess(arg0 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartImageCleanupProcess", reflect.TypeOf((*MockImageManager)(nil).StartImageCleanupProcess), arg0)
 }
+
+// StartImagePrewarm mocks base method
+func (m *MockImageManager) StartImagePrewarm(arg0 context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StartImagePrewarm", arg0)
+}
+
+// StartImagePrewarm indicates an expected call of StartImagePrewarm
+func (mr *MockImageManagerMockRecorder) StartImagePrewarm(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartImagePrewarm", reflect.TypeOf((*MockImageManager)(nil).StartImagePrewarm), arg0)
+}
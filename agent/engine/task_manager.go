@@ -15,6 +15,7 @@ package engine
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
@@ -55,6 +56,9 @@ const (
 	stoppedSentWaitInterval                  = 30 * time.Second
 	maxStoppedWaitTimes                      = 72 * time.Hour / stoppedSentWaitInterval
 	taskUnableToTransitionToStoppedReason    = "TaskStateError: Agent could not progress task's state to stopped"
+	minContainerRestartDelay                 = 5 * time.Second
+	maxContainerRestartDelay                 = 5 * time.Minute
+	containerRestartDelayMultiplier          = 2
 )
 
 var (
@@ -137,6 +141,7 @@ type managedTask struct {
 	credentialsManager credentials.Manager
 	cniClient          ecscni.CNIClient
 	taskStopWG         *utilsync.SequentialWaitGroup
+	hostResources      *hostResourceManager
 
 	acsMessages                chan acsTransition
 	dockerMessages             chan dockerContainerChange
@@ -164,6 +169,16 @@ type managedTask struct {
 	// verification logic gets executed to set it to a low interval
 	steadyStatePollInterval       time.Duration
 	steadyStatePollIntervalJitter time.Duration
+
+	// pendingRestarts maps the names of containers that are waiting to be
+	// restarted as per their restart policy to the functions that cancel the
+	// restart. It must only be accessed from the overseeTask goroutine
+	pendingRestarts map[string]context.CancelFunc
+
+	// timelineStatuses are the statuses of the task, its containers and its
+	// resources as of the last event recorded in the timeline of the task. It
+	// must only be accessed from the overseeTask goroutine
+	timelineStatuses map[timelineEntity]timelineStatus
 }
 
 // newManagedTask is a method on DockerTaskEngine to create a new managedTask.
@@ -186,8 +201,10 @@ func (engine *DockerTaskEngine) newManagedTask(task *apitask.Task) *managedTask
 		credentialsManager:            engine.credentialsManager,
 		cniClient:                     engine.cniClient,
 		taskStopWG:                    engine.taskStopGroup,
+		hostResources:                 engine.hostResources,
 		steadyStatePollInterval:       engine.taskSteadyStatePollInterval,
 		steadyStatePollIntervalJitter: engine.taskSteadyStatePollIntervalJitter,
+		pendingRestarts:               make(map[string]context.CancelFunc),
 	}
 	engine.managedTasks[task.Arn] = t
 	return t
@@ -201,6 +218,9 @@ func (mtask *managedTask) overseeTask() {
 	// `desiredstatus`es which are a construct of the engine used only here,
 	// not present on the backend
 	mtask.UpdateStatus()
+	mtask.initTimeline()
+	// Restarts that were pending when the agent stopped are resumed
+	mtask.resumeContainerRestarts()
 	// If this was a 'state restore', send all unsent statuses
 	mtask.emitCurrentStatus()
 
@@ -234,6 +254,7 @@ func (mtask *managedTask) overseeTask() {
 		// Conversely, for it to spin in steady state it will have to have been
 		// loaded in steady state or progressed through here, so saving here should
 		// be sufficient to capture state changes.
+		mtask.recordTimeline(timelineSourceTaskProgress, timelineEntity{}, nil)
 		err := mtask.saver.Save()
 		if err != nil {
 			seelog.Warnf("Managed task [%s]: unable to checkpoint task's states to disk: %v",
@@ -253,6 +274,7 @@ func (mtask *managedTask) overseeTask() {
 			mtask.Arn, mtask.StopSequenceNumber)
 		mtask.taskStopWG.Done(mtask.StopSequenceNumber)
 	}
+	mtask.releaseHostResources()
 	// TODO: make this idempotent on agent restart
 	go mtask.releaseIPInIPAM()
 	mtask.cleanupTask(mtask.cfg.TaskCleanupWaitDuration)
@@ -269,8 +291,16 @@ func (mtask *managedTask) emitCurrentStatus() {
 
 // waitForHostResources waits for host resources to become available to start
 // the task. This involves waiting for previous stops to complete so the
-// resources become free.
+// resources become free, and then for the resources required by the task to
+// be available on the host.
 func (mtask *managedTask) waitForHostResources() {
+	mtask.waitForPreviousStops()
+	mtask.waitForTaskResources()
+}
+
+// waitForPreviousStops waits for the tasks that were stopped before this task
+// was started by ACS to complete their stop
+func (mtask *managedTask) waitForPreviousStops() {
 	if mtask.StartSequenceNumber == 0 {
 		// This is the first transition on this host. No need to wait
 		return
@@ -303,6 +333,82 @@ func (mtask *managedTask) waitForHostResources() {
 		mtask.Arn, mtask.GetDesiredStatus().String())
 }
 
+// waitForTaskResources consumes the CPU, memory, host ports and GPUs required by
+// the task, waiting for other tasks to release them if needed. A task that can
+// never fit on the host is stopped
+func (mtask *managedTask) waitForTaskResources() {
+	if mtask.hostResources == nil {
+		return
+	}
+	resources := taskHostResources(mtask.Task)
+	if mtask.taskStarted() {
+		// The task was started before the agent restarted, its resources are
+		// in use no matter what
+		mtask.hostResources.forceConsume(mtask.Arn, resources)
+		return
+	}
+
+	for !mtask.GetDesiredStatus().Terminal() {
+		consumed, released, err := mtask.hostResources.consume(mtask.Arn, resources)
+		if err != nil {
+			seelog.Errorf("Managed task [%s]: %v", mtask.Arn, err)
+			mtask.SetDesiredStatus(apitaskstatus.TaskStopped)
+			mtask.UpdateDesiredStatus()
+			mtask.Task.SetTerminalReason(err.Error())
+			return
+		}
+		if consumed || !mtask.waitForReleasedResources(released) {
+			return
+		}
+	}
+}
+
+// waitForReleasedResources processes events for the task until other tasks
+// release host resources. It returns false if the task should no longer wait
+// for resources
+func (mtask *managedTask) waitForReleasedResources(released <-chan struct{}) bool {
+	releasedCtx, cancel := context.WithCancel(mtask.ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-released:
+			cancel()
+		case <-releasedCtx.Done():
+		}
+	}()
+
+	for !mtask.waitEvent(releasedCtx.Done()) {
+		if mtask.GetDesiredStatus().Terminal() {
+			seelog.Infof("Managed task [%s]: task stopped while waiting for host resources", mtask.Arn)
+			return false
+		}
+	}
+	return mtask.ctx.Err() == nil
+}
+
+// taskStarted returns true if the task or any of its containers progressed
+// past the initial status
+func (mtask *managedTask) taskStarted() bool {
+	if mtask.GetKnownStatus() != apitaskstatus.TaskStatusNone {
+		return true
+	}
+	for _, container := range mtask.Containers {
+		if container.GetKnownStatus() != apicontainerstatus.ContainerStatusNone {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseHostResources releases the host resources consumed by the task
+func (mtask *managedTask) releaseHostResources() {
+	if mtask.hostResources == nil {
+		return
+	}
+	mtask.hostResources.release(mtask.Arn)
+}
+
 // waitSteady waits for a task to leave steady-state by waiting for a new
 // event, or a timeout.
 func (mtask *managedTask) waitSteady() {
@@ -349,15 +455,19 @@ func (mtask *managedTask) waitEvent(stopWaiting <-chan struct{}) bool {
 	case acsTransition := <-mtask.acsMessages:
 		seelog.Infof("Managed task [%s]: got acs event", mtask.Arn)
 		mtask.handleDesiredStatusChange(acsTransition.desiredStatus, acsTransition.seqnum)
+		mtask.recordTimeline(timelineSourceACSTransition, timelineEntity{}, nil)
 		return false
 	case dockerChange := <-mtask.dockerMessages:
 		mtask.handleContainerChange(dockerChange)
+		mtask.recordTimeline(timelineSourceDockerContainerChange,
+			containerTimelineEntity(dockerChange.container.Name), dockerChange.event.Error)
 		return false
 	case resChange := <-mtask.resourceStateChangeEvent:
 		res := resChange.resource
 		seelog.Infof("Managed task [%s]: got resource [%s] event: [%s]",
 			mtask.Arn, res.GetName(), res.StatusString(resChange.nextState))
 		mtask.handleResourceStateChange(resChange)
+		mtask.recordTimeline(timelineSourceResourceStateChange, resourceTimelineEntity(res.GetName()), resChange.err)
 		return false
 	case <-stopWaiting:
 		seelog.Infof("Managed task [%s]: no longer waiting", mtask.Arn)
@@ -408,6 +518,12 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 		return
 	}
 
+	if mtask.isContainerRestartPending(container, event.Status) {
+		seelog.Infof("Managed task [%s]: Container [name=%s runtimeID=%s]: container is waiting to be restarted, ignoring change event [%s]",
+			mtask.Arn, container.Name, runtimeID, event.Status.String())
+		return
+	}
+
 	// If this is a backwards transition stopped->running, the first time set it
 	// to be known running so it will be stopped. Subsequently ignore these backward transitions
 	containerKnownStatus := container.GetKnownStatus()
@@ -427,6 +543,9 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	currentKnownStatus := containerKnownStatus
 	container.SetKnownStatus(event.Status)
 	updateContainerMetadata(&event.DockerContainerMetadata, container, mtask.Task)
+	if event.Status.IsRunning() || event.Status.Terminal() {
+		mtask.clearContainerRestart(container)
+	}
 
 	if event.Error != nil {
 		proceedAnyway := mtask.handleEventError(containerChange, currentKnownStatus)
@@ -444,6 +563,11 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 			mtask.Arn, container.Name, runtimeID, err)
 	}
 
+	if mtask.shouldRestartContainer(container, event.Status) {
+		mtask.scheduleContainerRestart(container)
+		return
+	}
+
 	mtask.emitContainerEvent(mtask.Task, container, "")
 	if mtask.UpdateStatus() {
 		seelog.Infof("Managed task [%s]: Container [name=%s runtimeID=%s]: container change also resulted in task change [%s]",
@@ -457,6 +581,125 @@ func (mtask *managedTask) handleContainerChange(containerChange dockerContainerC
 	}
 }
 
+// shouldRestartContainer returns true if the container has just stopped on its own
+// and should be restarted as per its restart policy. Only non-essential containers
+// are restarted, as an essential container stopping stops the task
+func (mtask *managedTask) shouldRestartContainer(container *apicontainer.Container, status apicontainerstatus.ContainerStatus) bool {
+	if status != apicontainerstatus.ContainerStopped || container.IsEssential() {
+		return false
+	}
+	if container.DesiredTerminal() || mtask.GetDesiredStatus().Terminal() {
+		return false
+	}
+	return container.GetRestartPolicy().ShouldRestart(container.GetKnownExitCode(), container.GetRestartCount())
+}
+
+// scheduleContainerRestart moves a stopped container back to CREATED and starts
+// it again once the restart delay for the container elapses. The applied status
+// of the container is set to RUNNING so that the task progression does not start
+// the container before that
+func (mtask *managedTask) scheduleContainerRestart(container *apicontainer.Container) {
+	delay := containerRestartDelay(container.GetRestartCount())
+	container.RecordRestart()
+	container.SetRestartAt(mtask.time().Now().Add(delay))
+	seelog.Infof("Managed task [%s]: Container [name=%s runtimeID=%s]: restarting container in %s as per its restart policy, restart count: %d",
+		mtask.Arn, container.Name, container.GetRuntimeID(), delay.String(), container.GetRestartCount())
+	container.SetKnownStatus(apicontainerstatus.ContainerCreated)
+	container.SetAppliedStatus(apicontainerstatus.ContainerRunning)
+
+	ctx, cancel := context.WithCancel(mtask.ctx)
+	mtask.pendingRestarts[container.Name] = cancel
+	go mtask.restartContainerAfterDelay(ctx, container, delay)
+	mtask.saver.Save()
+}
+
+// resumeContainerRestarts schedules again the restarts that were pending when
+// the state of the task was saved, for the time that was left of their delay.
+// A container found running has been restarted already, and one that is to
+// stop isn't restarted.
+func (mtask *managedTask) resumeContainerRestarts() {
+	for _, container := range mtask.Containers {
+		restartAt := container.GetRestartAt()
+		if restartAt.IsZero() {
+			continue
+		}
+		if container.IsRunning() || container.DesiredTerminal() {
+			container.SetRestartAt(time.Time{})
+			continue
+		}
+		delay := restartAt.Sub(mtask.time().Now())
+		if delay < 0 {
+			delay = 0
+		}
+		seelog.Infof("Managed task [%s]: Container [name=%s runtimeID=%s]: resuming restart of container in %s",
+			mtask.Arn, container.Name, container.GetRuntimeID(), delay.String())
+		// The container may have been found stopped when the state was synchronized
+		container.SetKnownStatus(apicontainerstatus.ContainerCreated)
+		container.SetAppliedStatus(apicontainerstatus.ContainerRunning)
+		ctx, cancel := context.WithCancel(mtask.ctx)
+		mtask.pendingRestarts[container.Name] = cancel
+		go mtask.restartContainerAfterDelay(ctx, container, delay)
+	}
+}
+
+// restartContainerAfterDelay starts the container once the delay elapses. If the
+// restart is cancelled before that, the container is moved to STOPPED instead
+func (mtask *managedTask) restartContainerAfterDelay(ctx context.Context, container *apicontainer.Container, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+		if mtask.ctx.Err() != nil {
+			return
+		}
+		seelog.Infof("Managed task [%s]: restart of container [%s] cancelled", mtask.Arn, container.Name)
+		mtask.emitDockerContainerChange(dockerContainerChange{
+			container: container,
+			event: dockerapi.DockerContainerChangeEvent{
+				Status: apicontainerstatus.ContainerStopped,
+			},
+		})
+	case <-mtask.time().After(delay):
+		mtask.engine.transitionContainer(mtask.Task, container, apicontainerstatus.ContainerRunning)
+	}
+}
+
+// isContainerRestartPending returns true if the container is waiting to be restarted
+// and the change event would only report the exit that is being restarted
+func (mtask *managedTask) isContainerRestartPending(container *apicontainer.Container, status apicontainerstatus.ContainerStatus) bool {
+	if _, ok := mtask.pendingRestarts[container.Name]; !ok {
+		return false
+	}
+	return status.Terminal() && !container.DesiredTerminal()
+}
+
+// cancelContainerRestart cancels the pending restart of the container, if any
+func (mtask *managedTask) cancelContainerRestart(container *apicontainer.Container) {
+	if cancel, ok := mtask.pendingRestarts[container.Name]; ok {
+		cancel()
+	}
+}
+
+// clearContainerRestart forgets about the pending restart of the container, if any
+func (mtask *managedTask) clearContainerRestart(container *apicontainer.Container) {
+	if cancel, ok := mtask.pendingRestarts[container.Name]; ok {
+		cancel()
+		delete(mtask.pendingRestarts, container.Name)
+		container.SetRestartAt(time.Time{})
+	}
+}
+
+// containerRestartDelay returns how long to wait before restarting a container
+// that has already been restarted restartCount times
+func containerRestartDelay(restartCount int) time.Duration {
+	delay := minContainerRestartDelay
+	for i := 0; i < restartCount && delay < maxContainerRestartDelay; i++ {
+		delay *= containerRestartDelayMultiplier
+	}
+	if delay > maxContainerRestartDelay {
+		delay = maxContainerRestartDelay
+	}
+	return delay
+}
+
 // handleResourceStateChange attempts to update resource's known status depending on
 // the current status and errors during transition
 func (mtask *managedTask) handleResourceStateChange(resChange resourceStateChange) {
@@ -1008,20 +1251,25 @@ func (mtask *managedTask) containerNextState(container *apicontainer.Container)
 			reason:         dependencygraph.ContainerPastDesiredStatusErr,
 		}
 	}
-	if blocked, err := dependencygraph.DependenciesAreResolved(container, mtask.Containers,
-		mtask.Task.GetExecutionCredentialsID(), mtask.credentialsManager, mtask.GetResources()); err != nil {
+	resolution := dependencygraph.ResolveDependencies(container, mtask.Containers,
+		mtask.Task.GetExecutionCredentialsID(), mtask.credentialsManager, mtask.GetResources())
+	container.SetTransitionBlockers(resolution.Blockers)
+	if resolution.Err != nil {
 		seelog.Debugf("Managed task [%s]: can't apply state to container [%s (Runtime ID: %s)] yet due to unresolved dependencies: %v",
-			mtask.Arn, container.Name, container.GetRuntimeID(), err)
+			mtask.Arn, container.Name, container.GetRuntimeID(), resolution.Err)
 		return &containerTransition{
 			nextState:      apicontainerstatus.ContainerStatusNone,
 			actionRequired: false,
-			reason:         err,
-			blockedOn:      blocked,
+			reason:         resolution.Err,
+			blockedOn:      resolution.BlockedOn,
 		}
 	}
 
 	var nextState apicontainerstatus.ContainerStatus
 	if container.DesiredTerminal() {
+		// A container waiting to be restarted is moved to STOPPED once its restart
+		// is cancelled
+		mtask.cancelContainerRestart(container)
 		nextState = apicontainerstatus.ContainerStopped
 		// It's not enough to just check if container is in steady state here
 		// we should really check if >= RUNNING <= STOPPED
@@ -1118,10 +1366,26 @@ func (mtask *managedTask) handleContainersUnableToTransitionState() {
 		// TODO we should probably panic here
 	} else {
 		seelog.Criticalf("Managed task [%s]: moving task to stopped due to bad state", mtask.Arn)
+		if reason := mtask.unresolvableDependencyReason(); reason != "" {
+			mtask.SetTerminalReason(reason)
+		}
 		mtask.handleDesiredStatusChange(apitaskstatus.TaskStopped, 0)
 	}
 }
 
+// unresolvableDependencyReason describes the first container dependency of the
+// task that can never be resolved, if there is one
+func (mtask *managedTask) unresolvableDependencyReason() string {
+	for _, container := range mtask.Containers {
+		for _, blocker := range container.GetTransitionBlockers() {
+			if blocker.Unresolvable {
+				return fmt.Sprintf("container [%s] cannot progress: %s", container.Name, blocker.Reason)
+			}
+		}
+	}
+	return ""
+}
+
 func (mtask *managedTask) waitForTransition(transitions map[string]string,
 	transition <-chan struct{},
 	transitionChangeEntity <-chan string) {
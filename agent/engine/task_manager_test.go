@@ -51,6 +51,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
 	mock_ttime "github.com/aws/amazon-ecs-agent/agent/utils/ttime/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/golang/mock/gomock"
 )
@@ -915,6 +916,67 @@ func TestOnContainersUnableToTransitionStateForDesiredRunningTask(t *testing.T)
 	assert.Equal(t, task.Containers[0].GetDesiredStatus(), apicontainerstatus.ContainerStopped)
 }
 
+func TestOnContainersUnableToTransitionStateWithUnresolvableDependency(t *testing.T) {
+	container := &apicontainer.Container{
+		KnownStatusUnsafe:   apicontainerstatus.ContainerStatusNone,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		Name:                "app",
+	}
+	container.SetTransitionBlockers([]apicontainer.TransitionBlocker{{
+		Type:         apicontainer.BlockerDependsOn,
+		Dependency:   "db",
+		Condition:    "HEALTHY",
+		Unresolvable: true,
+		Reason:       "dependency container [db] did not reach condition HEALTHY within its start timeout of 10s",
+	}})
+	task := &managedTask{
+		Task: &apitask.Task{
+			Containers:          []*apicontainer.Container{container},
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+	}
+
+	task.handleContainersUnableToTransitionState()
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+	assert.Equal(t, "Container [app] cannot progress: dependency container [db] did not reach condition HEALTHY within its start timeout of 10s",
+		task.GetTerminalReason())
+}
+
+func TestContainerNextStateRecordsTransitionBlockers(t *testing.T) {
+	dependency := &apicontainer.Container{
+		Name:                "dependency",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerPulled,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	container := &apicontainer.Container{
+		Name:                "container",
+		KnownStatusUnsafe:   apicontainerstatus.ContainerStatusNone,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		DependsOnUnsafe:     []apicontainer.DependsOn{{ContainerName: "dependency", Condition: "START"}},
+	}
+	task := &managedTask{
+		Task: &apitask.Task{
+			Containers:          []*apicontainer.Container{container, dependency},
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		},
+		engine: &DockerTaskEngine{},
+	}
+
+	transition := task.containerNextState(container)
+	assert.Error(t, transition.reason)
+	assert.Equal(t, &apicontainer.DependsOn{ContainerName: "dependency", Condition: "START"}, transition.blockedOn)
+	blockers := container.GetTransitionBlockers()
+	require.Len(t, blockers, 1)
+	assert.Equal(t, apicontainer.BlockerDependsOn, blockers[0].Type)
+	assert.Equal(t, "dependency", blockers[0].Dependency)
+
+	dependency.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	transition = task.containerNextState(container)
+	assert.NoError(t, transition.reason)
+	assert.Equal(t, apicontainerstatus.ContainerPulled, transition.nextState)
+	assert.Empty(t, container.GetTransitionBlockers())
+}
+
 // TODO: Test progressContainers workflow
 
 func TestHandleStoppedToSteadyStateTransition(t *testing.T) {
@@ -1605,6 +1667,176 @@ func TestHandleContainerChangeUpdateMetadataRedundant(t *testing.T) {
 	assert.Equal(t, timeNow, containerCreateTime)
 }
 
+func TestHandleContainerChangeRestartsNonEssentialContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	eventStreamName := "TestHandleContainerChangeRestartsNonEssentialContainer"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream(eventStreamName, ctx)
+	containerChangeEventStream.StartListening()
+
+	mTask := &managedTask{
+		ctx:                        ctx,
+		Task:                       testdata.LoadTask("sleep5TaskCgroup"),
+		containerChangeEventStream: containerChangeEventStream,
+		stateChangeEvents:          make(chan statechange.Event),
+		dockerMessages:             make(chan dockerContainerChange),
+		saver:                      statemanager.NewNoopStateManager(),
+		pendingRestarts:            make(map[string]context.CancelFunc),
+		_time:                      mockTime,
+	}
+	// Discard all the statechange events
+	defer discardEvents(mTask.stateChangeEvents)()
+	now := time.Now()
+	mockTime.EXPECT().Now().Return(now).AnyTimes()
+	mockTime.EXPECT().After(minContainerRestartDelay).Return(make(chan time.Time)).AnyTimes()
+
+	mTask.SetKnownStatus(apitaskstatus.TaskRunning)
+	mTask.SetDesiredStatus(apitaskstatus.TaskRunning)
+	container := mTask.Containers[0]
+	container.Essential = false
+	container.SetRestartPolicy(&apicontainer.RestartPolicy{
+		Type:        apicontainer.RestartPolicyOnFailure,
+		MaxAttempts: 1,
+	})
+	container.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	container.SetDesiredStatus(apicontainerstatus.ContainerRunning)
+
+	exitCode := 1
+	mTask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerStopped,
+			DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+				DockerID: "dockerID",
+				ExitCode: &exitCode,
+			},
+		},
+	})
+
+	assert.Equal(t, apicontainerstatus.ContainerCreated, container.GetKnownStatus())
+	assert.Equal(t, apicontainerstatus.ContainerRunning, container.GetAppliedStatus())
+	assert.Equal(t, 1, container.GetRestartCount())
+	assert.Equal(t, []int{exitCode}, container.GetLastExitCodes())
+	assert.Contains(t, mTask.pendingRestarts, container.Name)
+	assert.Equal(t, now.Add(minContainerRestartDelay), container.GetRestartAt())
+
+	// Exits reported while the restart is pending are ignored
+	mTask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerStopped,
+		},
+	})
+	assert.Equal(t, apicontainerstatus.ContainerCreated, container.GetKnownStatus())
+	assert.Equal(t, 1, container.GetRestartCount())
+
+	// Stopping the container cancels the restart and moves it to STOPPED
+	container.SetDesiredStatus(apicontainerstatus.ContainerStopped)
+	transition := mTask.containerNextState(container)
+	assert.False(t, transition.actionRequired)
+	change := <-mTask.dockerMessages
+	assert.Equal(t, apicontainerstatus.ContainerStopped, change.event.Status)
+	mTask.handleContainerChange(change)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetKnownStatus())
+	assert.NotContains(t, mTask.pendingRestarts, container.Name)
+	assert.True(t, container.GetRestartAt().IsZero())
+}
+
+func TestResumeContainerRestarts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTime := mock_ttime.NewMockTime(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mTask := &managedTask{
+		ctx:             ctx,
+		Task:            testdata.LoadTask("sleep5TaskCgroup"),
+		pendingRestarts: make(map[string]context.CancelFunc),
+		_time:           mockTime,
+	}
+	now := time.Now()
+	mockTime.EXPECT().Now().Return(now).AnyTimes()
+	// The delay is what was left of it when the state was saved
+	mockTime.EXPECT().After(10 * time.Second).Return(make(chan time.Time)).MaxTimes(1)
+
+	// The container was found stopped while its restart was pending
+	pending := mTask.Containers[0]
+	pending.SetRestartAt(now.Add(10 * time.Second))
+	pending.SetKnownStatus(apicontainerstatus.ContainerStopped)
+	pending.SetDesiredStatus(apicontainerstatus.ContainerRunning)
+	// The container was restarted before the state was saved again
+	restarted := &apicontainer.Container{Name: "restarted"}
+	restarted.SetRestartAt(now.Add(-time.Second))
+	restarted.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	restarted.SetDesiredStatus(apicontainerstatus.ContainerRunning)
+	mTask.Containers = append(mTask.Containers, restarted)
+
+	mTask.resumeContainerRestarts()
+	assert.Contains(t, mTask.pendingRestarts, pending.Name)
+	assert.Equal(t, apicontainerstatus.ContainerCreated, pending.GetKnownStatus())
+	assert.Equal(t, apicontainerstatus.ContainerRunning, pending.GetAppliedStatus())
+	assert.NotContains(t, mTask.pendingRestarts, restarted.Name)
+	assert.True(t, restarted.GetRestartAt().IsZero())
+}
+
+func TestHandleContainerChangeRestartPolicyMaxAttempts(t *testing.T) {
+	eventStreamName := "TestHandleContainerChangeRestartPolicyMaxAttempts"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	containerChangeEventStream := eventstream.NewEventStream(eventStreamName, ctx)
+	containerChangeEventStream.StartListening()
+
+	mTask := &managedTask{
+		ctx:                        ctx,
+		Task:                       testdata.LoadTask("sleep5TaskCgroup"),
+		containerChangeEventStream: containerChangeEventStream,
+		stateChangeEvents:          make(chan statechange.Event),
+		saver:                      statemanager.NewNoopStateManager(),
+		pendingRestarts:            make(map[string]context.CancelFunc),
+	}
+	// Discard all the statechange events
+	defer discardEvents(mTask.stateChangeEvents)()
+
+	mTask.SetKnownStatus(apitaskstatus.TaskRunning)
+	mTask.SetDesiredStatus(apitaskstatus.TaskRunning)
+	container := mTask.Containers[0]
+	container.Essential = false
+	container.SetRestartPolicy(&apicontainer.RestartPolicy{
+		Type:        apicontainer.RestartPolicyAlways,
+		MaxAttempts: 1,
+	})
+	container.RestartCountUnsafe = 1
+	container.SetKnownStatus(apicontainerstatus.ContainerRunning)
+	container.SetDesiredStatus(apicontainerstatus.ContainerRunning)
+
+	exitCode := 0
+	mTask.handleContainerChange(dockerContainerChange{
+		container: container,
+		event: dockerapi.DockerContainerChangeEvent{
+			Status: apicontainerstatus.ContainerStopped,
+			DockerContainerMetadata: dockerapi.DockerContainerMetadata{
+				DockerID: "dockerID",
+				ExitCode: &exitCode,
+			},
+		},
+	})
+
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetKnownStatus())
+	assert.Equal(t, 1, container.GetRestartCount())
+	assert.Empty(t, mTask.pendingRestarts)
+}
+
+func TestContainerRestartDelay(t *testing.T) {
+	assert.Equal(t, minContainerRestartDelay, containerRestartDelay(0))
+	assert.Equal(t, 2*minContainerRestartDelay, containerRestartDelay(1))
+	assert.Equal(t, maxContainerRestartDelay, containerRestartDelay(100))
+}
+
 func TestWaitForHostResources(t *testing.T) {
 	taskStopWG := utilsync.NewSequentialWaitGroup()
 	taskStopWG.Add(1, 1)
@@ -1630,6 +1862,105 @@ func TestWaitForHostResources(t *testing.T) {
 	waitForHostResourcesWG.Wait()
 }
 
+func TestWaitForTaskResourcesStopsTaskThatNeverFits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := &apicontainer.Container{
+		Name:                "c1",
+		CPU:                 2048,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+	}
+	mtask := &managedTask{
+		ctx:    ctx,
+		cancel: cancel,
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+			Containers:          []*apicontainer.Container{container},
+		},
+		hostResources: newHostResourceManager(&hostResources{cpu: 1024}, nil, nil),
+	}
+
+	mtask.waitForTaskResources()
+	assert.Equal(t, apitaskstatus.TaskStopped, mtask.GetDesiredStatus())
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetDesiredStatus())
+	assert.Contains(t, mtask.GetTerminalReason(), "requires 2048 CPU units but the host has 1024")
+	assert.Empty(t, mtask.hostResources.taskResources)
+}
+
+func TestWaitForTaskResourcesQueuesUntilReleased(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hostResourceManager := newHostResourceManager(&hostResources{cpu: 1024}, nil, nil)
+	newTask := func(arn string) *managedTask {
+		return &managedTask{
+			ctx:    ctx,
+			cancel: cancel,
+			Task: &apitask.Task{
+				Arn:                 arn,
+				DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+				Containers: []*apicontainer.Container{
+					{
+						Name: "c1",
+						CPU:  1024,
+					},
+				},
+			},
+			hostResources: hostResourceManager,
+		}
+	}
+	mtask1 := newTask("task1")
+	mtask2 := newTask("task2")
+
+	mtask1.waitForTaskResources()
+	done := make(chan struct{})
+	go func() {
+		mtask2.waitForTaskResources()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("task2 should wait for task1 to release its resources")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mtask1.releaseHostResources()
+	<-done
+	assert.Contains(t, hostResourceManager.taskResources, "task2")
+	assert.NotContains(t, hostResourceManager.taskResources, "task1")
+	assert.Equal(t, apitaskstatus.TaskRunning, mtask2.GetDesiredStatus())
+}
+
+func TestWaitForTaskResourcesRestoredTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mtask := &managedTask{
+		ctx:    ctx,
+		cancel: cancel,
+		Task: &apitask.Task{
+			Arn:                 "task1",
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+			KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+			Containers: []*apicontainer.Container{
+				{
+					Name: "c1",
+					CPU:  2048,
+				},
+			},
+		},
+		hostResources: newHostResourceManager(&hostResources{cpu: 1024}, nil, nil),
+	}
+
+	// Tasks that were running before the agent restarted are never stopped
+	mtask.waitForTaskResources()
+	assert.Equal(t, apitaskstatus.TaskRunning, mtask.GetDesiredStatus())
+	assert.Equal(t, int64(2048), mtask.hostResources.consumedCPU)
+}
+
 func TestWaitForResourceTransition(t *testing.T) {
 	task := &managedTask{
 		Task: &apitask.Task{
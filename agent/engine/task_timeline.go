@@ -0,0 +1,146 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+const (
+	// timelineSourceTaskStart is the source of the events recorded when the
+	// engine starts managing a new task
+	timelineSourceTaskStart = "taskStart"
+	// timelineSourceACSTransition is the source of the events caused by an acsTransition
+	timelineSourceACSTransition = "acsTransition"
+	// timelineSourceDockerContainerChange is the source of the events caused by a
+	// dockerContainerChange
+	timelineSourceDockerContainerChange = "dockerContainerChange"
+	// timelineSourceResourceStateChange is the source of the events caused by a
+	// resourceStateChange
+	timelineSourceResourceStateChange = "resourceStateChange"
+	// timelineSourceTaskProgress is the source of the events caused by the managed
+	// task progressing the task on its own, e.g. when it is stopped because it
+	// cannot be placed on the host
+	timelineSourceTaskProgress = "taskProgress"
+)
+
+// timelineEntity identifies the task, a container or a resource in the timeline
+type timelineEntity struct {
+	entity string
+	name   string
+}
+
+// timelineStatus is the last recorded status of a timeline entity
+type timelineStatus struct {
+	known   string
+	desired string
+}
+
+type timelineEntry struct {
+	timelineEntity
+	timelineStatus
+}
+
+// initTimeline starts tracking the status changes of the task. A new task gets
+// its initial statuses recorded, while a task restored from the saved state
+// keeps the timeline it was saved with.
+func (mtask *managedTask) initTimeline() {
+	if len(mtask.GetTimeline()) == 0 {
+		mtask.recordTimeline(timelineSourceTaskStart, timelineEntity{}, nil)
+		return
+	}
+	mtask.timelineStatuses = make(map[timelineEntity]timelineStatus)
+	for _, entry := range mtask.currentTimelineStatuses() {
+		mtask.timelineStatuses[entry.timelineEntity] = entry.timelineStatus
+	}
+}
+
+// recordTimeline adds an event to the timeline of the task for the task, every
+// container and every resource whose known or desired status changed since the
+// statuses were last recorded. If the change came with an error, it is recorded
+// for the entity that the error is about even if its status did not change.
+func (mtask *managedTask) recordTimeline(source string, errEntity timelineEntity, err error) {
+	now := ttime.Now()
+	statuses := make(map[timelineEntity]timelineStatus)
+	var events []apitask.TimelineEvent
+	for _, entry := range mtask.currentTimelineStatuses() {
+		statuses[entry.timelineEntity] = entry.timelineStatus
+		event := apitask.TimelineEvent{
+			Time:   now,
+			Source: source,
+			Entity: entry.entity,
+			Name:   entry.name,
+		}
+		last := mtask.timelineStatuses[entry.timelineEntity]
+		if entry.known != last.known {
+			event.KnownStatus = entry.known
+		}
+		if entry.desired != last.desired {
+			event.DesiredStatus = entry.desired
+		}
+		if err != nil && entry.timelineEntity == errEntity {
+			event.Error = err.Error()
+		}
+		if event.KnownStatus == "" && event.DesiredStatus == "" && event.Error == "" {
+			continue
+		}
+		events = append(events, event)
+	}
+	mtask.timelineStatuses = statuses
+	if len(events) > 0 {
+		mtask.AddTimelineEvent(events...)
+	}
+}
+
+// currentTimelineStatuses returns the current statuses of the task, its
+// containers and its resources, in that order
+func (mtask *managedTask) currentTimelineStatuses() []timelineEntry {
+	resources := mtask.GetResources()
+	entries := make([]timelineEntry, 0, 1+len(mtask.Containers)+len(resources))
+	entries = append(entries, timelineEntry{
+		timelineEntity: timelineEntity{entity: apitask.TimelineEntityTask},
+		timelineStatus: timelineStatus{
+			known:   mtask.GetKnownStatus().String(),
+			desired: mtask.GetDesiredStatus().String(),
+		},
+	})
+	for _, container := range mtask.Containers {
+		entries = append(entries, timelineEntry{
+			timelineEntity: containerTimelineEntity(container.Name),
+			timelineStatus: timelineStatus{
+				known:   container.GetKnownStatus().String(),
+				desired: container.GetDesiredStatus().String(),
+			},
+		})
+	}
+	for _, resource := range resources {
+		entries = append(entries, timelineEntry{
+			timelineEntity: resourceTimelineEntity(resource.GetName()),
+			timelineStatus: timelineStatus{
+				known:   resource.StatusString(resource.GetKnownStatus()),
+				desired: resource.StatusString(resource.GetDesiredStatus()),
+			},
+		})
+	}
+	return entries
+}
+
+func containerTimelineEntity(name string) timelineEntity {
+	return timelineEntity{entity: apitask.TimelineEntityContainer, name: name}
+}
+
+func resourceTimelineEntity(name string) timelineEntity {
+	return timelineEntity{entity: apitask.TimelineEntityResource, name: name}
+}
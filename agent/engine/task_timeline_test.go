@@ -0,0 +1,114 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTimelineTestTask() *managedTask {
+	return &managedTask{
+		Task: &apitask.Task{
+			Arn:                 "arn",
+			KnownStatusUnsafe:   apitaskstatus.TaskStatusNone,
+			DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+			Containers: []*apicontainer.Container{
+				{
+					Name:                "web",
+					DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+				},
+			},
+		},
+		acsMessages: make(chan acsTransition),
+	}
+}
+
+func TestInitTimelineRecordsNewTask(t *testing.T) {
+	mtask := newTimelineTestTask()
+	mtask.initTimeline()
+
+	timeline := mtask.GetTimeline()
+	require.Len(t, timeline, 2)
+	assert.Equal(t, timelineSourceTaskStart, timeline[0].Source)
+	assert.Equal(t, apitask.TimelineEntityTask, timeline[0].Entity)
+	assert.Equal(t, "NONE", timeline[0].KnownStatus)
+	assert.Equal(t, "RUNNING", timeline[0].DesiredStatus)
+	assert.Equal(t, apitask.TimelineEntityContainer, timeline[1].Entity)
+	assert.Equal(t, "web", timeline[1].Name)
+	assert.False(t, timeline[1].Time.IsZero())
+}
+
+func TestInitTimelineKeepsRestoredTimeline(t *testing.T) {
+	mtask := newTimelineTestTask()
+	mtask.AddTimelineEvent(apitask.TimelineEvent{Source: timelineSourceTaskStart, Entity: apitask.TimelineEntityTask})
+	mtask.initTimeline()
+	assert.Len(t, mtask.GetTimeline(), 1)
+
+	// Only changes after the restore are recorded
+	mtask.Containers[0].SetKnownStatus(apicontainerstatus.ContainerPulled)
+	mtask.recordTimeline(timelineSourceDockerContainerChange, containerTimelineEntity("web"), nil)
+	timeline := mtask.GetTimeline()
+	require.Len(t, timeline, 2)
+	assert.Equal(t, apitask.TimelineEvent{
+		Time:        timeline[1].Time,
+		Source:      timelineSourceDockerContainerChange,
+		Entity:      apitask.TimelineEntityContainer,
+		Name:        "web",
+		KnownStatus: "PULLED",
+	}, timeline[1])
+}
+
+func TestRecordTimelineForACSTransition(t *testing.T) {
+	mtask := newTimelineTestTask()
+	mtask.initTimeline()
+
+	go func() {
+		mtask.acsMessages <- acsTransition{desiredStatus: apitaskstatus.TaskStopped}
+	}()
+	assert.False(t, mtask.waitEvent(nil))
+
+	timeline := mtask.GetTimeline()
+	require.Len(t, timeline, 4)
+	for _, event := range timeline[2:] {
+		assert.Equal(t, timelineSourceACSTransition, event.Source)
+		assert.Equal(t, "STOPPED", event.DesiredStatus)
+		assert.Empty(t, event.KnownStatus)
+	}
+	assert.Equal(t, apitask.TimelineEntityTask, timeline[2].Entity)
+	assert.Equal(t, "web", timeline[3].Name)
+}
+
+func TestRecordTimelineRecordsErrorWithoutStatusChange(t *testing.T) {
+	mtask := newTimelineTestTask()
+	mtask.initTimeline()
+
+	mtask.recordTimeline(timelineSourceDockerContainerChange, containerTimelineEntity("web"), errors.New("pull failed"))
+	mtask.recordTimeline(timelineSourceTaskProgress, timelineEntity{}, nil)
+
+	timeline := mtask.GetTimeline()
+	require.Len(t, timeline, 3)
+	assert.Equal(t, "web", timeline[2].Name)
+	assert.Equal(t, "pull failed", timeline[2].Error)
+	assert.Empty(t, timeline[2].KnownStatus)
+}
@@ -7,14 +7,22 @@ import (
 	"runtime"
 
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	statemanager_testutils "github.com/aws/amazon-ecs-agent/agent/statemanager/testutils"
 )
 
+// LoadTask loads the task in test_tasks/<name>.json. The tasks there are in the
+// format of the first version of the saved state, and are migrated as the state
+// manager migrates the saved state before it's loaded.
 func LoadTask(name string) *apitask.Task {
 	_, filename, _, _ := runtime.Caller(0)
 	filedata, err := ioutil.ReadFile(filepath.Join(filepath.Dir(filename), "test_tasks", name+".json"))
 	if err != nil {
 		panic(err)
 	}
+	filedata, err = statemanager_testutils.MigrateTask(filedata, 1)
+	if err != nil {
+		panic(err)
+	}
 	t := &apitask.Task{}
 	if err := json.Unmarshal(filedata, t); err != nil {
 		panic(err)
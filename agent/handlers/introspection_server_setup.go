@@ -25,16 +25,30 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	handlersutils "github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 	v1 "github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/utils/retry"
 	"github.com/cihub/seelog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type rootResponse struct {
 	AvailableCommands []string
 }
 
-func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersutils.DockerStateResolver, cfg *config.Config) *http.Server {
-	paths := []string{v1.AgentMetadataPath, v1.TaskContainerMetadataPath, v1.LicensePath}
+func introspectionServerSetup(containerInstanceArn *string,
+	taskEngine handlersutils.TaskEngineResolver,
+	imageManager handlersutils.ImageManagerResolver,
+	containerMetrics prometheus.Collector,
+	diskUsage handlersutils.DiskUsageResolver,
+	cfg *config.Config) *http.Server {
+	paths := []string{v1.AgentMetadataPath, v1.TaskContainerMetadataPath, v1.TaskBlockersPath, v1.TaskTimelinePath,
+		v1.TaskPullsPath, v1.ImagePrewarmPath, v1.ImageCleanupPlanPath, v1.ContainerArchivePath, v1.LicensePath}
+	if containerMetrics != nil {
+		paths = append(paths, v1.ContainerMetricsPath)
+	}
+	if diskUsage != nil {
+		paths = append(paths, v1.TaskDiskUsagePath)
+	}
 	availableCommands := &rootResponse{paths}
 	// Autogenerated list of the above serverFunctions paths
 	availableCommandResponse, err := json.Marshal(&availableCommands)
@@ -49,7 +63,13 @@ func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersu
 	serverMux := http.NewServeMux()
 	serverMux.HandleFunc("/", defaultHandler)
 
-	v1HandlersSetup(serverMux, containerInstanceArn, taskEngine, cfg)
+	v1HandlersSetup(serverMux, containerInstanceArn, taskEngine, imageManager, cfg)
+	if containerMetrics != nil {
+		serverMux.Handle(v1.ContainerMetricsPath, v1.ContainerMetricsHandler(containerMetrics))
+	}
+	if diskUsage != nil {
+		serverMux.HandleFunc(v1.TaskDiskUsagePath, v1.TaskDiskUsageHandler(diskUsage))
+	}
 
 	// Log all requests and then pass through to serverMux
 	loggingServeMux := http.NewServeMux()
@@ -68,22 +88,44 @@ func introspectionServerSetup(containerInstanceArn *string, taskEngine handlersu
 // v1HandlersSetup adds all handlers except CredentialsHandler in v1 package to the server mux.
 func v1HandlersSetup(serverMux *http.ServeMux,
 	containerInstanceArn *string,
-	taskEngine handlersutils.DockerStateResolver,
+	taskEngine handlersutils.TaskEngineResolver,
+	imageManager handlersutils.ImageManagerResolver,
 	cfg *config.Config) {
 	serverMux.HandleFunc(v1.AgentMetadataPath, v1.AgentMetadataHandler(containerInstanceArn, cfg))
 	serverMux.HandleFunc(v1.TaskContainerMetadataPath, v1.TaskContainerMetadataHandler(taskEngine))
+	serverMux.HandleFunc(v1.TaskPathPrefix, v1.TaskHandler(taskEngine))
+	serverMux.HandleFunc(v1.ImagePrewarmPath, v1.ImagePrewarmHandler(imageManager))
+	serverMux.HandleFunc(v1.ImageCleanupPlanPath, v1.ImageCleanupPlanHandler(imageManager))
+	serverMux.HandleFunc(v1.ContainerArchivePath, v1.ContainerArchiveHandler(taskEngine))
+	serverMux.HandleFunc(v1.ContainerArchivePathPrefix, v1.ContainerArchiveHandler(taskEngine))
 	serverMux.HandleFunc(v1.LicensePath, v1.LicenseHandler)
 }
 
 // ServeIntrospectionHTTPEndpoint serves information about this agent/containerInstance and tasks
 // running on it. "V1" here indicates the hostname version of this server instead
 // of the handler versions, i.e. "V1" server can include "V1" and "V2" handlers.
-func ServeIntrospectionHTTPEndpoint(containerInstanceArn *string, taskEngine engine.TaskEngine, cfg *config.Config) {
+// The resource usage of the containers tracked by the stats engine is exposed
+// too when Prometheus metrics are enabled, and their disk usage when it's
+// sampled.
+func ServeIntrospectionHTTPEndpoint(containerInstanceArn *string,
+	taskEngine engine.TaskEngine,
+	imageManager engine.ImageManager,
+	statsEngine *stats.DockerStatsEngine,
+	cfg *config.Config) {
 	// Is this the right level to type assert, assuming we'd abstract multiple taskengines here?
 	// Revisit if we ever add another type..
 	dockerTaskEngine := taskEngine.(*engine.DockerTaskEngine)
 
-	server := introspectionServerSetup(containerInstanceArn, dockerTaskEngine, cfg)
+	var containerMetrics prometheus.Collector
+	if cfg.PrometheusMetricsEnabled {
+		containerMetrics = stats.NewPrometheusCollector(statsEngine, cfg.PrometheusContainerMetricsLimit)
+	}
+	var diskUsage handlersutils.DiskUsageResolver
+	if cfg.ContainerDiskUsageInterval > 0 {
+		diskUsage = statsEngine
+	}
+	server := introspectionServerSetup(containerInstanceArn, dockerTaskEngine, imageManager, containerMetrics,
+		diskUsage, cfg)
 	for {
 		once := sync.Once{}
 		retry.RetryWithBackoff(retry.NewExponentialBackoff(time.Second, time.Minute, 0.2, 2), func() error {
@@ -17,21 +17,30 @@ package handlers
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
 	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
 	mock_utils "github.com/aws/amazon-ecs-agent/agent/handlers/mocks"
 	v1 "github.com/aws/amazon-ecs-agent/agent/handlers/v1"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -195,6 +204,370 @@ func TestGetTaskByTaskArnAndDockerIDBadRequest(t *testing.T) {
 	}
 }
 
+func TestGetTaskBlockers(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/arn:aws:ecs:us-west-2:123456789012:task/cluster/blockedTask/blockers")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var blockersResponse v1.TaskBlockersResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &blockersResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:task/cluster/blockedTask", blockersResponse.Arn)
+	require.Len(t, blockersResponse.Containers, 1, "only blocked containers should be listed")
+	assert.Equal(t, "app", blockersResponse.Containers[0].Name)
+	assert.Equal(t, []apicontainer.TransitionBlocker{
+		{
+			Type:       apicontainer.BlockerDependsOn,
+			Dependency: "db",
+			Condition:  "HEALTHY",
+			Reason:     "waiting for container [db] to reach condition HEALTHY",
+		},
+	}, blockersResponse.Containers[0].Blockers)
+}
+
+func TestGetTaskBlockersNotFound(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/doesnotexist/blockers")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestGetTaskTimeline(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/arn:aws:ecs:us-west-2:123456789012:task/cluster/blockedTask/timeline")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var timelineResponse v1.TaskTimelineResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &timelineResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:task/cluster/blockedTask", timelineResponse.Arn)
+	assert.Equal(t, testTasks[len(testTasks)-1].GetTimeline(), timelineResponse.Timeline)
+}
+
+func TestGetTaskTimelineEmpty(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/task1/timeline")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"Arn":"task1","Timeline":[]}`, recorder.Body.String())
+}
+
+func TestGetTaskPulls(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/pullingTask/pulls")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var pullsResponse v1.TaskPullsResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &pullsResponse)
+	require.NoError(t, err)
+
+	assert.Equal(t, "pullingTask", pullsResponse.Arn)
+	require.Len(t, pullsResponse.Containers, 2)
+	assert.Equal(t, v1.ContainerPullResponse{
+		Name:         "pulled",
+		Image:        "busybox:latest",
+		KnownStatus:  "PULLED",
+		PullProgress: testTasks[len(testTasks)-2].Containers[0].GetPullProgress(),
+	}, pullsResponse.Containers[0])
+	assert.Equal(t, "NONE", pullsResponse.Containers[1].KnownStatus)
+	require.NotNil(t, pullsResponse.Containers[1].PullProgress)
+	assert.EqualValues(t, 2048, pullsResponse.Containers[1].PullProgress.BytesDownloaded)
+	assert.EqualValues(t, 4096, pullsResponse.Containers[1].PullProgress.BytesTotal)
+}
+
+func TestGetTaskPullsNotFound(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks/doesnotexist/pulls")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestGetTaskPullProgress(t *testing.T) {
+	recorder := performMockRequest(t, "/v1/tasks?taskarn=pullingTask")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var taskResponse v1.TaskResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &taskResponse)
+	require.NoError(t, err)
+
+	require.Len(t, taskResponse.Containers, 2)
+	for _, container := range taskResponse.Containers {
+		assert.NotNil(t, container.PullProgress, "container %s should have its pull progress", container.Name)
+	}
+}
+
+func TestGetImagePrewarmStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pulledAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := []image.PrewarmStatus{
+		{
+			Image:           "busybox:latest",
+			PullPolicy:      "always",
+			RefreshInterval: "30m0s",
+			State:           image.PrewarmReady,
+			ImageID:         "sha256:busybox",
+			LastPullAttempt: &pulledAt,
+			LastPulledAt:    &pulledAt,
+		},
+		{
+			Image:      "amazonlinux:2",
+			PullPolicy: "if-not-present",
+			State:      image.PrewarmFailed,
+			LastError:  "pull failed",
+		},
+	}
+	mockImageManager := mock_utils.NewMockImageManagerResolver(ctrl)
+	mockImageManager.EXPECT().GetImagePrewarmStatus().Return(statuses)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mockImageManager, nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.ImagePrewarmPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var prewarmResponse v1.ImagePrewarmResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &prewarmResponse)
+	require.NoError(t, err)
+	assert.Equal(t, statuses, prewarmResponse.Images)
+}
+
+func TestGetImagePrewarmStatusEmpty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockImagePrewarmResolver := mock_utils.NewMockImagePrewarmStatusResolver(ctrl)
+	mockImagePrewarmResolver.EXPECT().GetImagePrewarmStatus().Return(nil)
+	requestHandler := v1.ImagePrewarmHandler(mockImagePrewarmResolver)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.ImagePrewarmPath, nil)
+	requestHandler(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"Images":[]}`, recorder.Body.String())
+}
+
+func TestGetImageCleanupPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lastUsedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	plan := image.CleanupPlan{
+		GeneratedAt:    lastUsedAt.Add(time.Hour),
+		ImagesPerCycle: 5,
+		Images: []image.CleanupPlanImage{
+			{
+				ImageID:    "sha256:busybox",
+				Names:      []string{"busybox:latest"},
+				Size:       1024,
+				LastUsedAt: &lastUsedAt,
+				Action:     image.CleanupDelete,
+				Reason:     "image is one of the least recently used unused images",
+			},
+		},
+		NonECSImages: []image.CleanupPlanImage{
+			{
+				ImageID: "sha256:nginx",
+				Names:   []string{"nginx:latest"},
+				Action:  image.CleanupKeep,
+				Reason:  "untracked image cleanup is disabled",
+			},
+		},
+	}
+	mockImageManager := mock_utils.NewMockImageManagerResolver(ctrl)
+	mockImageManager.EXPECT().GetImageCleanupPlan(gomock.Any()).Return(plan)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mockImageManager, nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.ImageCleanupPlanPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var planResponse image.CleanupPlan
+	err := json.Unmarshal(recorder.Body.Bytes(), &planResponse)
+	require.NoError(t, err)
+	assert.Equal(t, plan, planResponse)
+}
+
+func TestContainerMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_container_metric", Help: "Test metric"})
+	gauge.Set(42)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mock_utils.NewMockImageManagerResolver(ctrl), gauge,
+		nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.ContainerMetricsPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "test_container_metric 42\n")
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	var root rootResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &root))
+	assert.Contains(t, root.AvailableCommands, v1.ContainerMetricsPath)
+}
+
+func TestContainerMetricsDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mock_utils.NewMockImageManagerResolver(ctrl), nil,
+		nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.ContainerMetricsPath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	var root rootResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &root), "Expected the root response")
+	assert.NotContains(t, root.AvailableCommands, v1.ContainerMetricsPath)
+}
+
+func TestTaskDiskUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	diskUsage := mock_utils.NewMockDiskUsageResolver(ctrl)
+	sampledAt := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []*stats.TaskDiskUsage{
+		{
+			TaskARN:                  "t1",
+			Family:                   "web",
+			Containers:               map[string]*stats.ContainerDiskUsage{"c1": {WritableLayerBytes: 1024, SampledAt: sampledAt}},
+			TotalBytes:               1024,
+			WarningThresholdBytes:    512,
+			WarningThresholdExceeded: true,
+			SampledAt:                sampledAt,
+		},
+		{
+			TaskARN:    "t2",
+			Family:     "db",
+			Containers: map[string]*stats.ContainerDiskUsage{"c2": {WritableLayerBytes: 2048, SampledAt: sampledAt}},
+			TotalBytes: 2048,
+			SampledAt:  sampledAt,
+		},
+	}
+	diskUsage.EXPECT().TasksDiskUsage().Return(tasks).Times(3)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mock_utils.NewMockImageManagerResolver(ctrl), nil,
+		diskUsage, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.TaskDiskUsagePath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var tasksResponse []*stats.TaskDiskUsage
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &tasksResponse))
+	assert.Equal(t, tasks, tasksResponse)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", v1.TaskDiskUsagePath+"?taskarn=t2", nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var taskResponse stats.TaskDiskUsage
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &taskResponse))
+	assert.Equal(t, tasks[1], &taskResponse)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", v1.TaskDiskUsagePath+"?taskarn=t3", nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	var root rootResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &root))
+	assert.Contains(t, root.AvailableCommands, v1.TaskDiskUsagePath)
+}
+
+func TestTaskDiskUsageDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn),
+		mock_utils.NewMockTaskEngineResolver(ctrl), mock_utils.NewMockImageManagerResolver(ctrl), nil,
+		nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", v1.TaskDiskUsagePath, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	var root rootResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &root), "Expected the root response")
+	assert.NotContains(t, root.AvailableCommands, v1.TaskDiskUsagePath)
+}
+
+func performContainerArchiveRequest(t *testing.T, path string,
+	setup func(*mock_utils.MockTaskEngineResolver)) *httptest.ResponseRecorder {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTaskEngine := mock_utils.NewMockTaskEngineResolver(ctrl)
+	setup(mockTaskEngine)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn), mockTaskEngine,
+		mock_utils.NewMockImageManagerResolver(ctrl), nil, nil, &config.Config{Cluster: testClusterArn})
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	requestHandler.Handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestListContainerArchive(t *testing.T) {
+	archives := []containerarchive.TaskArchive{
+		{
+			TaskID:     "task1",
+			ArchivedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Size:       42,
+			Files:      []containerarchive.File{{Name: "web-exit.json", Size: 42}},
+		},
+	}
+	recorder := performContainerArchiveRequest(t, v1.ContainerArchivePath, func(m *mock_utils.MockTaskEngineResolver) {
+		m.EXPECT().ListContainerArchives().Return(archives, nil)
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var archiveResponse v1.ContainerArchiveResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &archiveResponse)
+	require.NoError(t, err)
+	assert.Equal(t, archives, archiveResponse.Tasks)
+}
+
+func TestGetContainerArchiveFile(t *testing.T) {
+	recorder := performContainerArchiveRequest(t, v1.ContainerArchivePathPrefix+"task1/web-json.log",
+		func(m *mock_utils.MockTaskEngineResolver) {
+			m.EXPECT().OpenContainerArchiveFile("task1", "web-json.log").Return(
+				ioutil.NopCloser(strings.NewReader("{\"log\":\"bye\"}\n")), nil)
+		})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "{\"log\":\"bye\"}\n", recorder.Body.String())
+}
+
+func TestGetContainerArchiveFileErrors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		path         string
+		openErr      error
+		expectedCode int
+	}{
+		{"not found", v1.ContainerArchivePathPrefix + "task1/missing.log", os.ErrNotExist, http.StatusNotFound},
+		{"invalid name", v1.ContainerArchivePathPrefix + "task1/..%5Cweb-exit.json", containerarchive.ErrInvalidName, http.StatusBadRequest},
+		{"no file", v1.ContainerArchivePathPrefix + "task1", nil, http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := performContainerArchiveRequest(t, tc.path, func(m *mock_utils.MockTaskEngineResolver) {
+				if tc.openErr != nil {
+					m.EXPECT().OpenContainerArchiveFile(gomock.Any(), gomock.Any()).Return(nil, tc.openErr)
+				}
+			})
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
 func TestBackendMismatchMapping(t *testing.T) {
 	// Test that a KnownStatus past a DesiredStatus suppresses the DesiredStatus output
 	ctrl := gomock.NewController(t)
@@ -385,6 +758,70 @@ var testTasks = []*apitask.Task{
 			},
 		},
 	},
+	{
+		Arn:                 "pullingTask",
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+		Family:              "test",
+		Version:             "1",
+		Containers: []*apicontainer.Container{
+			{
+				Name:              "pulled",
+				Image:             "busybox:latest",
+				KnownStatusUnsafe: apicontainerstatus.ContainerPulled,
+				PullProgressUnsafe: &apicontainer.PullProgress{
+					Layers:          1,
+					LayersDone:      1,
+					BytesDownloaded: 1024,
+					BytesTotal:      1024,
+					UpdatedAt:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			{
+				Name:  "pulling",
+				Image: "amazonlinux:2",
+				PullProgressUnsafe: &apicontainer.PullProgress{
+					Layers:          2,
+					LayersDone:      1,
+					BytesDownloaded: 2048,
+					BytesTotal:      4096,
+					UpdatedAt:       time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC),
+				},
+			},
+		},
+	},
+	{
+		Arn:                 "arn:aws:ecs:us-west-2:123456789012:task/cluster/blockedTask",
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+		Family:              "test",
+		Version:             "1",
+		Containers: []*apicontainer.Container{
+			{
+				Name: "db",
+			},
+			{
+				Name: "app",
+				TransitionBlockersUnsafe: []apicontainer.TransitionBlocker{
+					{
+						Type:       apicontainer.BlockerDependsOn,
+						Dependency: "db",
+						Condition:  "HEALTHY",
+						Reason:     "waiting for container [db] to reach condition HEALTHY",
+					},
+				},
+			},
+		},
+		TimelineUnsafe: []apitask.TimelineEvent{
+			{
+				Time:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				Source:        "taskStart",
+				Entity:        apitask.TimelineEntityTask,
+				KnownStatus:   "NONE",
+				DesiredStatus: "RUNNING",
+			},
+		},
+	},
 }
 
 func stateSetupHelper(state dockerstate.TaskEngineState, tasks []*apitask.Task) {
@@ -404,13 +841,15 @@ func performMockRequest(t *testing.T, path string) *httptest.ResponseRecorder {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockStateResolver := mock_utils.NewMockDockerStateResolver(ctrl)
+	mockStateResolver := mock_utils.NewMockTaskEngineResolver(ctrl)
 
 	state := dockerstate.NewTaskEngineState()
 	stateSetupHelper(state, testTasks)
 
 	mockStateResolver.EXPECT().State().Return(state)
-	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn), mockStateResolver, &config.Config{Cluster: testClusterArn})
+	mockImageManager := mock_utils.NewMockImageManagerResolver(ctrl)
+	requestHandler := introspectionServerSetup(utils.Strptr(testContainerInstanceArn), mockStateResolver,
+		mockImageManager, nil, nil, &config.Config{Cluster: testClusterArn})
 
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", path, nil)
@@ -13,15 +13,20 @@
 //
 
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/aws/amazon-ecs-agent/agent/handlers/utils (interfaces: DockerStateResolver)
+// Source: github.com/aws/amazon-ecs-agent/agent/handlers/utils (interfaces: DockerStateResolver,ContainerArchiveResolver,TaskEngineResolver,ImagePrewarmStatusResolver,ImageCleanupPlanResolver,ImageManagerResolver,DiskUsageResolver)
 
 // Package mock_utils is a generated GoMock package.
 package mock_utils
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
 
+	containerarchive "github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
 	dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	image "github.com/aws/amazon-ecs-agent/agent/engine/image"
+	stats "github.com/aws/amazon-ecs-agent/agent/stats"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -61,3 +66,285 @@ func (mr *MockDockerStateResolverMockRecorder) State() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockDockerStateResolver)(nil).State))
 }
+
+// MockContainerArchiveResolver is a mock of ContainerArchiveResolver interface
+type MockContainerArchiveResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockContainerArchiveResolverMockRecorder
+}
+
+// MockContainerArchiveResolverMockRecorder is the mock recorder for MockContainerArchiveResolver
+type MockContainerArchiveResolverMockRecorder struct {
+	mock *MockContainerArchiveResolver
+}
+
+// NewMockContainerArchiveResolver creates a new mock instance
+func NewMockContainerArchiveResolver(ctrl *gomock.Controller) *MockContainerArchiveResolver {
+	mock := &MockContainerArchiveResolver{ctrl: ctrl}
+	mock.recorder = &MockContainerArchiveResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockContainerArchiveResolver) EXPECT() *MockContainerArchiveResolverMockRecorder {
+	return m.recorder
+}
+
+// ListContainerArchives mocks base method
+func (m *MockContainerArchiveResolver) ListContainerArchives() ([]containerarchive.TaskArchive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListContainerArchives")
+	ret0, _ := ret[0].([]containerarchive.TaskArchive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListContainerArchives indicates an expected call of ListContainerArchives
+func (mr *MockContainerArchiveResolverMockRecorder) ListContainerArchives() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListContainerArchives", reflect.TypeOf((*MockContainerArchiveResolver)(nil).ListContainerArchives))
+}
+
+// OpenContainerArchiveFile mocks base method
+func (m *MockContainerArchiveResolver) OpenContainerArchiveFile(arg0, arg1 string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenContainerArchiveFile", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenContainerArchiveFile indicates an expected call of OpenContainerArchiveFile
+func (mr *MockContainerArchiveResolverMockRecorder) OpenContainerArchiveFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenContainerArchiveFile", reflect.TypeOf((*MockContainerArchiveResolver)(nil).OpenContainerArchiveFile), arg0, arg1)
+}
+
+// MockTaskEngineResolver is a mock of TaskEngineResolver interface
+type MockTaskEngineResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockTaskEngineResolverMockRecorder
+}
+
+// MockTaskEngineResolverMockRecorder is the mock recorder for MockTaskEngineResolver
+type MockTaskEngineResolverMockRecorder struct {
+	mock *MockTaskEngineResolver
+}
+
+// NewMockTaskEngineResolver creates a new mock instance
+func NewMockTaskEngineResolver(ctrl *gomock.Controller) *MockTaskEngineResolver {
+	mock := &MockTaskEngineResolver{ctrl: ctrl}
+	mock.recorder = &MockTaskEngineResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTaskEngineResolver) EXPECT() *MockTaskEngineResolverMockRecorder {
+	return m.recorder
+}
+
+// ListContainerArchives mocks base method
+func (m *MockTaskEngineResolver) ListContainerArchives() ([]containerarchive.TaskArchive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListContainerArchives")
+	ret0, _ := ret[0].([]containerarchive.TaskArchive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListContainerArchives indicates an expected call of ListContainerArchives
+func (mr *MockTaskEngineResolverMockRecorder) ListContainerArchives() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListContainerArchives", reflect.TypeOf((*MockTaskEngineResolver)(nil).ListContainerArchives))
+}
+
+// OpenContainerArchiveFile mocks base method
+func (m *MockTaskEngineResolver) OpenContainerArchiveFile(arg0, arg1 string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenContainerArchiveFile", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenContainerArchiveFile indicates an expected call of OpenContainerArchiveFile
+func (mr *MockTaskEngineResolverMockRecorder) OpenContainerArchiveFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenContainerArchiveFile", reflect.TypeOf((*MockTaskEngineResolver)(nil).OpenContainerArchiveFile), arg0, arg1)
+}
+
+// State mocks base method
+func (m *MockTaskEngineResolver) State() dockerstate.TaskEngineState {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "State")
+	ret0, _ := ret[0].(dockerstate.TaskEngineState)
+	return ret0
+}
+
+// State indicates an expected call of State
+func (mr *MockTaskEngineResolverMockRecorder) State() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockTaskEngineResolver)(nil).State))
+}
+
+// MockImagePrewarmStatusResolver is a mock of ImagePrewarmStatusResolver interface
+type MockImagePrewarmStatusResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockImagePrewarmStatusResolverMockRecorder
+}
+
+// MockImagePrewarmStatusResolverMockRecorder is the mock recorder for MockImagePrewarmStatusResolver
+type MockImagePrewarmStatusResolverMockRecorder struct {
+	mock *MockImagePrewarmStatusResolver
+}
+
+// NewMockImagePrewarmStatusResolver creates a new mock instance
+func NewMockImagePrewarmStatusResolver(ctrl *gomock.Controller) *MockImagePrewarmStatusResolver {
+	mock := &MockImagePrewarmStatusResolver{ctrl: ctrl}
+	mock.recorder = &MockImagePrewarmStatusResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockImagePrewarmStatusResolver) EXPECT() *MockImagePrewarmStatusResolverMockRecorder {
+	return m.recorder
+}
+
+// GetImagePrewarmStatus mocks base method
+func (m *MockImagePrewarmStatusResolver) GetImagePrewarmStatus() []image.PrewarmStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImagePrewarmStatus")
+	ret0, _ := ret[0].([]image.PrewarmStatus)
+	return ret0
+}
+
+// GetImagePrewarmStatus indicates an expected call of GetImagePrewarmStatus
+func (mr *MockImagePrewarmStatusResolverMockRecorder) GetImagePrewarmStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImagePrewarmStatus", reflect.TypeOf((*MockImagePrewarmStatusResolver)(nil).GetImagePrewarmStatus))
+}
+
+// MockImageCleanupPlanResolver is a mock of ImageCleanupPlanResolver interface
+type MockImageCleanupPlanResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockImageCleanupPlanResolverMockRecorder
+}
+
+// MockImageCleanupPlanResolverMockRecorder is the mock recorder for MockImageCleanupPlanResolver
+type MockImageCleanupPlanResolverMockRecorder struct {
+	mock *MockImageCleanupPlanResolver
+}
+
+// NewMockImageCleanupPlanResolver creates a new mock instance
+func NewMockImageCleanupPlanResolver(ctrl *gomock.Controller) *MockImageCleanupPlanResolver {
+	mock := &MockImageCleanupPlanResolver{ctrl: ctrl}
+	mock.recorder = &MockImageCleanupPlanResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockImageCleanupPlanResolver) EXPECT() *MockImageCleanupPlanResolverMockRecorder {
+	return m.recorder
+}
+
+// GetImageCleanupPlan mocks base method
+func (m *MockImageCleanupPlanResolver) GetImageCleanupPlan(arg0 context.Context) image.CleanupPlan {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageCleanupPlan", arg0)
+	ret0, _ := ret[0].(image.CleanupPlan)
+	return ret0
+}
+
+// GetImageCleanupPlan indicates an expected call of GetImageCleanupPlan
+func (mr *MockImageCleanupPlanResolverMockRecorder) GetImageCleanupPlan(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageCleanupPlan", reflect.TypeOf((*MockImageCleanupPlanResolver)(nil).GetImageCleanupPlan), arg0)
+}
+
+// MockImageManagerResolver is a mock of ImageManagerResolver interface
+type MockImageManagerResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockImageManagerResolverMockRecorder
+}
+
+// MockImageManagerResolverMockRecorder is the mock recorder for MockImageManagerResolver
+type MockImageManagerResolverMockRecorder struct {
+	mock *MockImageManagerResolver
+}
+
+// NewMockImageManagerResolver creates a new mock instance
+func NewMockImageManagerResolver(ctrl *gomock.Controller) *MockImageManagerResolver {
+	mock := &MockImageManagerResolver{ctrl: ctrl}
+	mock.recorder = &MockImageManagerResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockImageManagerResolver) EXPECT() *MockImageManagerResolverMockRecorder {
+	return m.recorder
+}
+
+// GetImageCleanupPlan mocks base method
+func (m *MockImageManagerResolver) GetImageCleanupPlan(arg0 context.Context) image.CleanupPlan {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageCleanupPlan", arg0)
+	ret0, _ := ret[0].(image.CleanupPlan)
+	return ret0
+}
+
+// GetImageCleanupPlan indicates an expected call of GetImageCleanupPlan
+func (mr *MockImageManagerResolverMockRecorder) GetImageCleanupPlan(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageCleanupPlan", reflect.TypeOf((*MockImageManagerResolver)(nil).GetImageCleanupPlan), arg0)
+}
+
+// GetImagePrewarmStatus mocks base method
+func (m *MockImageManagerResolver) GetImagePrewarmStatus() []image.PrewarmStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImagePrewarmStatus")
+	ret0, _ := ret[0].([]image.PrewarmStatus)
+	return ret0
+}
+
+// GetImagePrewarmStatus indicates an expected call of GetImagePrewarmStatus
+func (mr *MockImageManagerResolverMockRecorder) GetImagePrewarmStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImagePrewarmStatus", reflect.TypeOf((*MockImageManagerResolver)(nil).GetImagePrewarmStatus))
+}
+
+// MockDiskUsageResolver is a mock of DiskUsageResolver interface
+type MockDiskUsageResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockDiskUsageResolverMockRecorder
+}
+
+// MockDiskUsageResolverMockRecorder is the mock recorder for MockDiskUsageResolver
+type MockDiskUsageResolverMockRecorder struct {
+	mock *MockDiskUsageResolver
+}
+
+// NewMockDiskUsageResolver creates a new mock instance
+func NewMockDiskUsageResolver(ctrl *gomock.Controller) *MockDiskUsageResolver {
+	mock := &MockDiskUsageResolver{ctrl: ctrl}
+	mock.recorder = &MockDiskUsageResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockDiskUsageResolver) EXPECT() *MockDiskUsageResolverMockRecorder {
+	return m.recorder
+}
+
+// TasksDiskUsage mocks base method
+func (m *MockDiskUsageResolver) TasksDiskUsage() []*stats.TaskDiskUsage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TasksDiskUsage")
+	ret0, _ := ret[0].([]*stats.TaskDiskUsage)
+	return ret0
+}
+
+// TasksDiskUsage indicates an expected call of TasksDiskUsage
+func (mr *MockDiskUsageResolverMockRecorder) TasksDiskUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TasksDiskUsage", reflect.TypeOf((*MockDiskUsageResolver)(nil).TasksDiskUsage))
+}
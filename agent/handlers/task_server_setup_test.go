@@ -44,6 +44,7 @@ import (
 	v3 "github.com/aws/amazon-ecs-agent/agent/handlers/v3"
 	v4 "github.com/aws/amazon-ecs-agent/agent/handlers/v4"
 	mock_audit "github.com/aws/amazon-ecs-agent/agent/logger/audit/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	mock_stats "github.com/aws/amazon-ecs-agent/agent/stats/mock"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/docker/docker/api/types"
@@ -1154,6 +1155,7 @@ func TestV4ContainerMetadata(t *testing.T) {
 		state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true),
 		state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true),
 		state.EXPECT().TaskByID(containerID).Return(task, true).Times(2),
+		state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, ecsClient, clusterName, statsEngine,
 		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate, "us-west-2b", containerInstanceArn)
@@ -1263,7 +1265,7 @@ func TestV4BridgeTaskMetadata(t *testing.T) {
 		state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
 		state.EXPECT().TaskByArn(taskARN).Return(bridgeTask, true),
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToBridgeContainer, true),
-		state.EXPECT().TaskByArn(taskARN).Return(bridgeTask, true),
+		state.EXPECT().TaskByArn(taskARN).Return(bridgeTask, true).Times(2),
 		state.EXPECT().ContainerByID(containerID).Return(bridgeContainer, true),
 	)
 
@@ -1297,7 +1299,7 @@ func TestV4BridgeContainerMetadata(t *testing.T) {
 		state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true),
 		state.EXPECT().ContainerByID(containerID).Return(bridgeContainer, true),
 		state.EXPECT().TaskByID(containerID).Return(bridgeTask, true),
-		state.EXPECT().ContainerByID(containerID).Return(bridgeContainer, true),
+		state.EXPECT().ContainerByID(containerID).Return(bridgeContainer, true).Times(2),
 	)
 
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, ecsClient, clusterName, statsEngine,
@@ -1334,10 +1336,29 @@ func TestV4TaskStats(t *testing.T) {
 		},
 	}
 
+	distributions := &stats.UsageDistributions{
+		MemoryUsageInMegs: &stats.UsageDistribution{
+			P50:         32,
+			P90:         48,
+			P99:         63,
+			SampleCount: 4,
+			Sum:         160,
+			Buckets:     []stats.HistogramBucket{{UpperBound: 16, Count: 0}, {UpperBound: 32, Count: 2}, {UpperBound: 64, Count: 4}},
+		},
+	}
+
+	diskUsage := &stats.ContainerDiskUsage{
+		WritableLayerBytes: 1024,
+		VolumeBytes:        map[string]int64{"scratch": 4096},
+		SampledAt:          time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC),
+	}
+
 	gomock.InOrder(
 		state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerMap, true),
 		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerUsageDistributions(taskARN, containerID).Return(distributions, nil),
+		statsEngine.EXPECT().ContainerDiskUsage(taskARN, containerID).Return(diskUsage, nil),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, ecsClient, clusterName, statsEngine,
 		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate, "", containerInstanceArn)
@@ -1347,12 +1368,14 @@ func TestV4TaskStats(t *testing.T) {
 	res, err := ioutil.ReadAll(recorder.Body)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, recorder.Code)
-	var statsFromResult map[string]*types.StatsJSON
+	var statsFromResult map[string]*v4.StatsResponse
 	err = json.Unmarshal(res, &statsFromResult)
 	assert.NoError(t, err)
 	containerStats, ok := statsFromResult[containerID]
 	assert.True(t, ok)
 	assert.Equal(t, dockerStats.NumProcs, containerStats.NumProcs)
+	assert.Equal(t, distributions, containerStats.UsageDistributions)
+	assert.Equal(t, diskUsage, containerStats.DiskUsage)
 }
 
 func TestV4ContainerStats(t *testing.T) {
@@ -1371,6 +1394,8 @@ func TestV4ContainerStats(t *testing.T) {
 		state.EXPECT().TaskARNByV3EndpointID(v3EndpointID).Return(taskARN, true),
 		state.EXPECT().DockerIDByV3EndpointID(v3EndpointID).Return(containerID, true),
 		statsEngine.EXPECT().ContainerDockerStats(taskARN, containerID).Return(dockerStats, nil),
+		statsEngine.EXPECT().ContainerUsageDistributions(taskARN, containerID).Return(nil, nil),
+		statsEngine.EXPECT().ContainerDiskUsage(taskARN, containerID).Return(nil, fmt.Errorf("container not found")),
 	)
 	server := taskServerSetup(credentials.NewManager(), auditLog, state, ecsClient, clusterName, statsEngine,
 		config.DefaultTaskMetadataSteadyStateRate, config.DefaultTaskMetadataBurstRate, "", containerInstanceArn)
@@ -1384,6 +1409,8 @@ func TestV4ContainerStats(t *testing.T) {
 	err = json.Unmarshal(res, &statsFromResult)
 	assert.NoError(t, err)
 	assert.Equal(t, dockerStats.NumProcs, statsFromResult.NumProcs)
+	assert.NotContains(t, string(res), "usage_distributions", "No distributions unless they're enabled")
+	assert.NotContains(t, string(res), "disk_usage")
 }
 
 func TestV4ContainerAssociations(t *testing.T) {
@@ -51,6 +51,18 @@ const (
 	// RequestTypeContainerAssociation specifies the container association request type of ContainerAssociationHandler.
 	RequestTypeContainerAssociation = "container association"
 
+	// RequestTypeImagePrewarm specifies the request type of ImagePrewarmHandler.
+	RequestTypeImagePrewarm = "image prewarm"
+
+	// RequestTypeImageCleanupPlan specifies the request type of ImageCleanupPlanHandler.
+	RequestTypeImageCleanupPlan = "image cleanup plan"
+
+	// RequestTypeContainerArchive specifies the request type of ContainerArchiveHandler.
+	RequestTypeContainerArchive = "container archive"
+
+	// RequestTypeTaskDiskUsage specifies the request type of TaskDiskUsageHandler.
+	RequestTypeTaskDiskUsage = "task disk usage"
+
 	// AnythingButSlashRegEx is a regex pattern that matches any string without slash.
 	AnythingButSlashRegEx = "[^/]*"
 
@@ -13,10 +13,57 @@
 
 package utils
 
-import "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+import (
+	"context"
+	"io"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
+)
 
 // DockerStateResolver is a sub-interface for the engine.TaskEngine interface
 // to make it easy to test code in this package
 type DockerStateResolver interface {
 	State() dockerstate.TaskEngineState
 }
+
+// ContainerArchiveResolver is a sub-interface for the engine.DockerTaskEngine
+// to make it easy to test code in this package
+type ContainerArchiveResolver interface {
+	ListContainerArchives() ([]containerarchive.TaskArchive, error)
+	OpenContainerArchiveFile(taskID, name string) (io.ReadCloser, error)
+}
+
+// TaskEngineResolver is the part of the engine.DockerTaskEngine the
+// introspection handlers use
+type TaskEngineResolver interface {
+	DockerStateResolver
+	ContainerArchiveResolver
+}
+
+// ImagePrewarmStatusResolver is a sub-interface for the engine.ImageManager
+// interface to make it easy to test code in this package
+type ImagePrewarmStatusResolver interface {
+	GetImagePrewarmStatus() []image.PrewarmStatus
+}
+
+// ImageCleanupPlanResolver is a sub-interface for the engine.ImageManager
+// interface to make it easy to test code in this package
+type ImageCleanupPlanResolver interface {
+	GetImageCleanupPlan(ctx context.Context) image.CleanupPlan
+}
+
+// ImageManagerResolver is the part of the engine.ImageManager interface the
+// introspection handlers use
+type ImageManagerResolver interface {
+	ImagePrewarmStatusResolver
+	ImageCleanupPlanResolver
+}
+
+// DiskUsageResolver is a sub-interface for the stats.DockerStatsEngine to make
+// it easy to test code in this package
+type DiskUsageResolver interface {
+	TasksDiskUsage() []*stats.TaskDiskUsage
+}
@@ -0,0 +1,96 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/containerarchive"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// ContainerArchivePath is the path of the v1 handler that lists the tasks
+	// whose containers were archived at task cleanup
+	ContainerArchivePath = "/v1/container-archive"
+	// ContainerArchivePathPrefix is the prefix of the paths of archived files,
+	// which are of the form /v1/container-archive/{taskID}/{file}
+	ContainerArchivePathPrefix = ContainerArchivePath + "/"
+)
+
+// ContainerArchiveResponse is the schema for the container archive response JSON object
+type ContainerArchiveResponse struct {
+	Tasks []containerarchive.TaskArchive `json:"Tasks"`
+}
+
+// ContainerArchiveHandler creates response for the 'v1/container-archive' API,
+// and serves the archived files at 'v1/container-archive/{taskID}/{file}'.
+func ContainerArchiveHandler(taskEngine utils.ContainerArchiveResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == ContainerArchivePath {
+			listContainerArchives(w, taskEngine)
+			return
+		}
+		pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, ContainerArchivePathPrefix), "/")
+		if len(pathParts) != 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		serveContainerArchiveFile(w, taskEngine, pathParts[0], pathParts[1])
+	}
+}
+
+func listContainerArchives(w http.ResponseWriter, taskEngine utils.ContainerArchiveResolver) {
+	archives, err := taskEngine.ListContainerArchives()
+	if err != nil {
+		seelog.Errorf("Unable to list the container archive: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	responseJSON, err := json.Marshal(&ContainerArchiveResponse{Tasks: archives})
+	if e := utils.WriteResponseIfMarshalError(w, err); e != nil {
+		return
+	}
+	utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeContainerArchive)
+}
+
+func serveContainerArchiveFile(w http.ResponseWriter, taskEngine utils.ContainerArchiveResolver,
+	taskID, name string) {
+	file, err := taskEngine.OpenContainerArchiveFile(taskID, name)
+	if err != nil {
+		switch {
+		case err == containerarchive.ErrInvalidName:
+			w.WriteHeader(http.StatusBadRequest)
+		case os.IsNotExist(err):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			seelog.Errorf("Unable to open archived file %s of task %s: %v", name, taskID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		seelog.Errorf("Unable to write archived file %s of task %s to response: %v", name, taskID, err)
+	}
+}
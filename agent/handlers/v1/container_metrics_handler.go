@@ -0,0 +1,34 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ContainerMetricsPath is the path of the v1 handler that exposes the resource
+// usage of the containers in the Prometheus text format.
+const ContainerMetricsPath = "/v1/metrics"
+
+// ContainerMetricsHandler creates the handler for 'v1/metrics' API. The
+// collector is registered in a registry of its own, so that the agent's
+// metrics aren't mixed with the containers'.
+func ContainerMetricsHandler(collector prometheus.Collector) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
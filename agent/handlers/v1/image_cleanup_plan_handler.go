@@ -0,0 +1,37 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+)
+
+// ImageCleanupPlanPath is the path of the v1 handler that lists what the next
+// image cleanup cycle would do with each image, without removing anything.
+const ImageCleanupPlanPath = "/v1/images/cleanup-plan"
+
+// ImageCleanupPlanHandler creates response for 'v1/images/cleanup-plan' API.
+func ImageCleanupPlanHandler(imageManager utils.ImageCleanupPlanResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plan := imageManager.GetImageCleanupPlan(r.Context())
+		responseJSON, err := json.Marshal(&plan)
+		if e := utils.WriteResponseIfMarshalError(w, err); e != nil {
+			return
+		}
+		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeImageCleanupPlan)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine/image"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+)
+
+// ImagePrewarmPath is the path of the v1 handler that lists the status of the
+// images that are pulled ahead of the tasks that use them.
+const ImagePrewarmPath = "/v1/images/prewarm"
+
+// ImagePrewarmResponse is the schema for the image pre-warm response JSON object
+type ImagePrewarmResponse struct {
+	Images []image.PrewarmStatus `json:"Images"`
+}
+
+// ImagePrewarmHandler creates response for 'v1/images/prewarm' API.
+func ImagePrewarmHandler(imageManager utils.ImagePrewarmStatusResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := imageManager.GetImagePrewarmStatus()
+		if statuses == nil {
+			statuses = []image.PrewarmStatus{}
+		}
+		responseJSON, err := json.Marshal(&ImagePrewarmResponse{Images: statuses})
+		if e := utils.WriteResponseIfMarshalError(w, err); e != nil {
+			return
+		}
+		utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeImagePrewarm)
+	}
+}
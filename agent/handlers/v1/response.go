@@ -52,6 +52,8 @@ type ContainerResponse struct {
 	Ports      []PortResponse              `json:"Ports,omitempty"`
 	Networks   []containermetadata.Network `json:"Networks,omitempty"`
 	Volumes    []VolumeResponse            `json:"Volumes,omitempty"`
+	// PullProgress is the progress of the pull of the container's image
+	PullProgress *apicontainer.PullProgress `json:"PullProgress,omitempty"`
 }
 
 // VolumeResponse is the schema for the volume response JSON object
@@ -108,6 +110,7 @@ func NewContainerResponse(dockerContainer *apicontainer.DockerContainer, eni *ap
 		DockerName: dockerContainer.DockerName,
 	}
 
+	resp.PullProgress = container.GetPullProgress()
 	resp.Ports = NewPortBindingsResponse(dockerContainer, eni)
 	resp.Volumes = NewVolumesResponse(dockerContainer)
 
@@ -0,0 +1,67 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+)
+
+const (
+	// TaskBlockersPath is the path of the v1 handler that lists the dependencies
+	// keeping the containers of a task from progressing.
+	TaskBlockersPath       = TaskPathPrefix + taskARNPathPlaceholder + taskBlockersPathSuffix
+	taskBlockersPathSuffix = "/blockers"
+)
+
+// TaskBlockersResponse is the schema for the task blockers response JSON object
+type TaskBlockersResponse struct {
+	Arn           string                      `json:"Arn"`
+	DesiredStatus string                      `json:"DesiredStatus,omitempty"`
+	KnownStatus   string                      `json:"KnownStatus"`
+	Containers    []ContainerBlockersResponse `json:"Containers"`
+}
+
+// ContainerBlockersResponse is the schema for the blockers of a container
+type ContainerBlockersResponse struct {
+	Name          string                           `json:"Name"`
+	DesiredStatus string                           `json:"DesiredStatus"`
+	KnownStatus   string                           `json:"KnownStatus"`
+	Blockers      []apicontainer.TransitionBlocker `json:"Blockers"`
+}
+
+// NewTaskBlockersResponse creates a TaskBlockersResponse listing the containers
+// of the task that are blocked on their dependencies.
+func NewTaskBlockersResponse(task *apitask.Task) *TaskBlockersResponse {
+	containers := []ContainerBlockersResponse{}
+	for _, container := range task.Containers {
+		blockers := container.GetTransitionBlockers()
+		if len(blockers) == 0 {
+			continue
+		}
+		containers = append(containers, ContainerBlockersResponse{
+			Name:          container.Name,
+			DesiredStatus: container.GetDesiredStatus().String(),
+			KnownStatus:   container.GetKnownStatus().String(),
+			Blockers:      blockers,
+		})
+	}
+
+	return &TaskBlockersResponse{
+		Arn:           task.Arn,
+		DesiredStatus: task.GetDesiredStatus().String(),
+		KnownStatus:   task.GetKnownStatus().String(),
+		Containers:    containers,
+	}
+}
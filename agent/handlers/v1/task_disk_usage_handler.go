@@ -0,0 +1,57 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
+)
+
+// TaskDiskUsagePath is the path of the v1 handler that lists the disk used by
+// the containers of the tasks, as last sampled by the stats engine.
+const TaskDiskUsagePath = "/v1/diskusage"
+
+// TaskDiskUsageHandler creates response for 'v1/diskusage' API. Lists the disk
+// usage of all the sampled tasks, or of the task given by 'taskarn'.
+func TaskDiskUsageHandler(statsEngine utils.DiskUsageResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tasks := statsEngine.TasksDiskUsage()
+		taskARN, taskARNExists := utils.ValueFromRequest(r, taskARNQueryField)
+		if !taskARNExists {
+			responseJSON, err := json.Marshal(tasks)
+			if e := utils.WriteResponseIfMarshalError(w, err); e != nil {
+				return
+			}
+			utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeTaskDiskUsage)
+			return
+		}
+		for _, task := range tasks {
+			if task.TaskARN != taskARN {
+				continue
+			}
+			responseJSON, err := json.Marshal(task)
+			if e := utils.WriteResponseIfMarshalError(w, err); e != nil {
+				return
+			}
+			utils.WriteJSONToResponse(w, http.StatusOK, responseJSON, utils.RequestTypeTaskDiskUsage)
+			return
+		}
+		seelog.Warn("Could not find the disk usage of requested task: " + taskARN)
+		utils.WriteJSONToResponse(w, http.StatusNotFound, []byte(`{}`), utils.RequestTypeTaskDiskUsage)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
+	"github.com/cihub/seelog"
+)
+
+const (
+	// TaskPathPrefix is the prefix of the v1 paths about a single task, which
+	// are of the form /v1/tasks/{taskARN}/<resource>.
+	TaskPathPrefix         = TaskContainerMetadataPath + "/"
+	taskARNPathPlaceholder = "{taskARN}"
+)
+
+// TaskHandler creates response for the 'v1/tasks/{taskARN}/blockers',
+// 'v1/tasks/{taskARN}/timeline' and 'v1/tasks/{taskARN}/pulls' APIs.
+func TaskHandler(taskEngine utils.DockerStateResolver) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Task ARNs contain slashes, so everything between the prefix and the
+		// suffix is the ARN
+		path := strings.TrimPrefix(r.URL.Path, TaskPathPrefix)
+		var taskARN string
+		var newResponse func(*apitask.Task) interface{}
+		switch {
+		case strings.HasSuffix(path, taskBlockersPathSuffix):
+			taskARN = strings.TrimSuffix(path, taskBlockersPathSuffix)
+			newResponse = func(task *apitask.Task) interface{} { return NewTaskBlockersResponse(task) }
+		case strings.HasSuffix(path, taskTimelinePathSuffix):
+			taskARN = strings.TrimSuffix(path, taskTimelinePathSuffix)
+			newResponse = func(task *apitask.Task) interface{} { return NewTaskTimelineResponse(task) }
+		case strings.HasSuffix(path, taskPullsPathSuffix):
+			taskARN = strings.TrimSuffix(path, taskPullsPathSuffix)
+			newResponse = func(task *apitask.Task) interface{} { return NewTaskPullsResponse(task) }
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		task, found := taskEngine.State().TaskByArn(taskARN)
+		if !found {
+			seelog.Warn("Could not find requested resource: " + taskARN)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		responseJSON, err := json.Marshal(newResponse(task))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(responseJSON)
+	}
+}
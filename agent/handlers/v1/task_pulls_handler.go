@@ -0,0 +1,62 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+)
+
+const (
+	// TaskPullsPath is the path of the v1 handler that lists the progress of the
+	// image pulls of the containers of a task.
+	TaskPullsPath       = TaskPathPrefix + taskARNPathPlaceholder + taskPullsPathSuffix
+	taskPullsPathSuffix = "/pulls"
+)
+
+// TaskPullsResponse is the schema for the task pulls response JSON object
+type TaskPullsResponse struct {
+	Arn        string                  `json:"Arn"`
+	Containers []ContainerPullResponse `json:"Containers"`
+}
+
+// ContainerPullResponse is the schema for the image pull of a container. Unlike
+// ContainerResponse, it includes the containers that aren't created yet, whose
+// image is still being pulled.
+type ContainerPullResponse struct {
+	Name         string                     `json:"Name"`
+	Image        string                     `json:"Image"`
+	KnownStatus  string                     `json:"KnownStatus"`
+	PullProgress *apicontainer.PullProgress `json:"PullProgress,omitempty"`
+}
+
+// NewTaskPullsResponse creates a TaskPullsResponse for a task.
+func NewTaskPullsResponse(task *apitask.Task) *TaskPullsResponse {
+	containers := []ContainerPullResponse{}
+	for _, container := range task.Containers {
+		if container.IsInternal() {
+			continue
+		}
+		containers = append(containers, ContainerPullResponse{
+			Name:         container.Name,
+			Image:        container.Image,
+			KnownStatus:  container.GetKnownStatus().String(),
+			PullProgress: container.GetPullProgress(),
+		})
+	}
+	return &TaskPullsResponse{
+		Arn:        task.Arn,
+		Containers: containers,
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v1
+
+import (
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+)
+
+const (
+	// TaskTimelinePath is the path of the v1 handler that lists the most recent
+	// status changes of a task, its containers and its resources.
+	TaskTimelinePath       = TaskPathPrefix + taskARNPathPlaceholder + taskTimelinePathSuffix
+	taskTimelinePathSuffix = "/timeline"
+)
+
+// TaskTimelineResponse is the schema for the task timeline response JSON object
+type TaskTimelineResponse struct {
+	Arn      string                  `json:"Arn"`
+	Timeline []apitask.TimelineEvent `json:"Timeline"`
+}
+
+// NewTaskTimelineResponse creates a TaskTimelineResponse for a task.
+func NewTaskTimelineResponse(task *apitask.Task) *TaskTimelineResponse {
+	timeline := task.GetTimeline()
+	if timeline == nil {
+		timeline = []apitask.TimelineEvent{}
+	}
+	return &TaskTimelineResponse{
+		Arn:      task.Arn,
+		Timeline: timeline,
+	}
+}
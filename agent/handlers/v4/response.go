@@ -17,11 +17,13 @@ import (
 	"net"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
 	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
 	"github.com/aws/amazon-ecs-agent/agent/containermetadata"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/handlers/utils"
 	v2 "github.com/aws/amazon-ecs-agent/agent/handlers/v2"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/pkg/errors"
 )
 
@@ -37,6 +39,18 @@ type TaskResponse struct {
 type ContainerResponse struct {
 	*v2.ContainerResponse
 	Networks []Network `json:"Networks,omitempty"`
+	// RestartCount is the number of times the container has been restarted by
+	// the agent. It is only populated for containers with a restart policy.
+	RestartCount *int `json:"RestartCount,omitempty"`
+	// LastExitCodes are the exit codes of the most recent runs of the container
+	// that were restarted by the agent.
+	LastExitCodes []int `json:"LastExitCodes,omitempty"`
+	// PullProgress is the progress of the pull of the container's image. It
+	// is only populated once the agent has pulled, or started pulling, the image.
+	PullProgress *apicontainer.PullProgress `json:"PullProgress,omitempty"`
+	// StopReason is why the container stopped the last time. It is only
+	// populated once the container has stopped.
+	StopReason *apicontainer.StopReason `json:"StopReason,omitempty"`
 }
 
 // Network is the v4 Network response. It adds a bunch of information about network
@@ -89,6 +103,7 @@ func NewTaskResponse(
 	if err != nil {
 		return nil, err
 	}
+	task, _ := state.TaskByArn(taskARN)
 	var containers []ContainerResponse
 	// Convert each container response into v4 container response.
 	for i, container := range v2Resp.Containers {
@@ -98,10 +113,28 @@ func NewTaskResponse(
 		if err != nil {
 			return nil, err
 		}
-		containers = append(containers, ContainerResponse{
+		resp := ContainerResponse{
 			ContainerResponse: &v2Resp.Containers[i],
 			Networks:          networks,
-		})
+		}
+		if task != nil {
+			if apiContainer, ok := task.ContainerByName(container.Name); ok {
+				resp.addRestartInfo(apiContainer)
+				resp.PullProgress = apiContainer.GetPullProgress()
+				resp.StopReason = apiContainer.GetStopReason()
+			}
+		}
+		containers = append(containers, resp)
+	}
+	// Containers are only added to the state once they're created, so the ones
+	// whose image is still being pulled are reported from the task
+	if task != nil {
+		for _, apiContainer := range task.Containers {
+			if hasContainerResponse(v2Resp.Containers, apiContainer.Name) {
+				continue
+			}
+			containers = append(containers, newPendingContainerResponse(apiContainer))
+		}
 	}
 
 	return &TaskResponse{
@@ -128,10 +161,61 @@ func NewContainerResponse(
 	if err != nil {
 		return nil, err
 	}
-	return &ContainerResponse{
+	resp := &ContainerResponse{
 		ContainerResponse: container,
 		Networks:          networks,
-	}, nil
+	}
+	if dockerContainer, ok := state.ContainerByID(containerID); ok {
+		resp.addRestartInfo(dockerContainer.Container)
+		resp.PullProgress = dockerContainer.Container.GetPullProgress()
+		resp.StopReason = dockerContainer.Container.GetStopReason()
+	}
+	return resp, nil
+}
+
+// hasContainerResponse returns true if one of the container responses is for
+// the named container
+func hasContainerResponse(containers []v2.ContainerResponse, name string) bool {
+	for _, container := range containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newPendingContainerResponse creates a v4 container response for a container
+// that hasn't been created yet, with the progress of the pull of its image
+func newPendingContainerResponse(container *apicontainer.Container) ContainerResponse {
+	resp := ContainerResponse{
+		ContainerResponse: &v2.ContainerResponse{
+			Name:          container.Name,
+			Image:         container.Image,
+			DesiredStatus: container.GetDesiredStatus().String(),
+			KnownStatus:   container.GetKnownStatus().String(),
+			Limits: v2.LimitsResponse{
+				CPU:    aws.Float64(float64(container.CPU)),
+				Memory: aws.Int64(int64(container.Memory)),
+			},
+			Type:   container.Type.String(),
+			Labels: container.GetLabels(),
+		},
+		PullProgress: container.GetPullProgress(),
+		StopReason:   container.GetStopReason(),
+	}
+	resp.addRestartInfo(container)
+	return resp
+}
+
+// addRestartInfo populates the restart count and the last exit codes of the
+// container if it has a restart policy.
+func (resp *ContainerResponse) addRestartInfo(container *apicontainer.Container) {
+	if container.GetRestartPolicy() == nil {
+		return
+	}
+	restartCount := container.GetRestartCount()
+	resp.RestartCount = &restartCount
+	resp.LastExitCodes = container.GetLastExitCodes()
 }
 
 // toV4NetworkResponse converts v2 network response to v4. Additional fields are only
@@ -122,7 +122,7 @@ func TestNewTaskContainerResponses(t *testing.T) {
 	gomock.InOrder(
 		state.EXPECT().TaskByArn(taskARN).Return(task, true),
 		state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToDockerContainer, true),
-		state.EXPECT().TaskByArn(taskARN).Return(task, true),
+		state.EXPECT().TaskByArn(taskARN).Return(task, true).Times(2),
 	)
 
 	taskResponse, err := NewTaskResponse(taskARN, state, ecsClient, cluster, availabilityZone, containerInstanceArn, false)
@@ -136,6 +136,7 @@ func TestNewTaskContainerResponses(t *testing.T) {
 	gomock.InOrder(
 		state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true),
 		state.EXPECT().TaskByID(containerID).Return(task, true).Times(2),
+		state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true),
 	)
 	containerResponse, err := NewContainerResponse(containerID, state)
 	require.NoError(t, err)
@@ -145,3 +146,191 @@ func TestNewTaskContainerResponses(t *testing.T) {
 	assert.Equal(t, "192.168.0.0/24", containerResponse.Networks[0].IPV4SubnetCIDRBlock)
 	assert.Equal(t, subnetGatewayIPV4Address, containerResponse.Networks[0].SubnetGatewayIPV4Address)
 }
+
+func TestNewContainerResponseWithRestartPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                 taskARN,
+		Family:              family,
+		Version:             version,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+	}
+	exitCode := 1
+	container := &apicontainer.Container{
+		Name:                containerName,
+		Image:               imageName,
+		ImageID:             imageID,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerRunning,
+		RestartPolicy: &apicontainer.RestartPolicy{
+			Type:        apicontainer.RestartPolicyOnFailure,
+			MaxAttempts: 3,
+		},
+		KnownExitCodeUnsafe: &exitCode,
+	}
+	container.RecordRestart()
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: containerName,
+		Container:  container,
+	}
+	state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true).Times(2)
+	state.EXPECT().TaskByID(containerID).Return(task, true)
+
+	containerResponse, err := NewContainerResponse(containerID, state)
+	require.NoError(t, err)
+	require.NotNil(t, containerResponse.RestartCount)
+	assert.Equal(t, 1, *containerResponse.RestartCount)
+	assert.Equal(t, []int{exitCode}, containerResponse.LastExitCodes)
+}
+
+func TestNewContainerResponseWithPullProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                 taskARN,
+		Family:              family,
+		Version:             version,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		KnownStatusUnsafe:   apitaskstatus.TaskRunning,
+	}
+	container := &apicontainer.Container{
+		Name:                containerName,
+		Image:               imageName,
+		ImageID:             imageID,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerPulled,
+	}
+	progress := apicontainer.PullProgress{
+		Layers:          3,
+		LayersDone:      3,
+		BytesDownloaded: 2048,
+		BytesTotal:      2048,
+		UpdatedAt:       time.Unix(1500000000, 0).UTC(),
+	}
+	container.SetPullProgress(progress)
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: containerName,
+		Container:  container,
+	}
+	state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true).Times(2)
+	state.EXPECT().TaskByID(containerID).Return(task, true)
+
+	containerResponse, err := NewContainerResponse(containerID, state)
+	require.NoError(t, err)
+	assert.Nil(t, containerResponse.RestartCount)
+	require.NotNil(t, containerResponse.PullProgress)
+	assert.Equal(t, progress, *containerResponse.PullProgress)
+
+	data, err := json.Marshal(containerResponse)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"PullProgress":{"Layers":3,"LayersDone":3,"BytesDownloaded":2048,"BytesTotal":2048`)
+}
+
+func TestNewTaskResponseWithPendingContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	ecsClient := mock_api.NewMockECSClient(ctrl)
+	created := &apicontainer.Container{
+		Name:                containerName,
+		Image:               imageName,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerCreated,
+	}
+	pulling := &apicontainer.Container{
+		Name:                "pulling",
+		Image:               "amazonlinux:2",
+		DesiredStatusUnsafe: apicontainerstatus.ContainerRunning,
+		CPU:                 cpu,
+		Memory:              memory,
+	}
+	progress := apicontainer.PullProgress{
+		Layers:          2,
+		LayersDone:      1,
+		BytesDownloaded: 1024,
+		BytesTotal:      4096,
+		UpdatedAt:       time.Unix(1500000000, 0).UTC(),
+	}
+	pulling.SetPullProgress(progress)
+	task := &apitask.Task{
+		Arn:                 taskARN,
+		Family:              family,
+		Version:             version,
+		DesiredStatusUnsafe: apitaskstatus.TaskRunning,
+		Containers:          []*apicontainer.Container{created, pulling},
+	}
+	containerNameToDockerContainer := map[string]*apicontainer.DockerContainer{
+		containerName: {
+			DockerID:   containerID,
+			DockerName: containerName,
+			Container:  created,
+		},
+	}
+	state.EXPECT().TaskByArn(taskARN).Return(task, true).AnyTimes()
+	state.EXPECT().ContainerMapByArn(taskARN).Return(containerNameToDockerContainer, true)
+
+	taskResponse, err := NewTaskResponse(taskARN, state, ecsClient, cluster, availabilityZone, containerInstanceArn, false)
+	require.NoError(t, err)
+	require.Len(t, taskResponse.Containers, 2)
+	assert.Equal(t, containerID, taskResponse.Containers[0].ID)
+	pendingResponse := taskResponse.Containers[1]
+	assert.Equal(t, "pulling", pendingResponse.Name)
+	assert.Empty(t, pendingResponse.ID)
+	assert.Equal(t, "NONE", pendingResponse.KnownStatus)
+	assert.Equal(t, "RUNNING", pendingResponse.DesiredStatus)
+	assert.Equal(t, float64(cpu), *pendingResponse.Limits.CPU)
+	require.NotNil(t, pendingResponse.PullProgress)
+	assert.Equal(t, progress, *pendingResponse.PullProgress)
+
+	_, err = json.Marshal(taskResponse)
+	require.NoError(t, err)
+}
+
+func TestNewContainerResponseWithStopReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mock_dockerstate.NewMockTaskEngineState(ctrl)
+	task := &apitask.Task{
+		Arn:                 taskARN,
+		Family:              family,
+		Version:             version,
+		DesiredStatusUnsafe: apitaskstatus.TaskStopped,
+		KnownStatusUnsafe:   apitaskstatus.TaskStopped,
+	}
+	exitCode := 137
+	container := &apicontainer.Container{
+		Name:                containerName,
+		Image:               imageName,
+		ImageID:             imageID,
+		DesiredStatusUnsafe: apicontainerstatus.ContainerStopped,
+		KnownStatusUnsafe:   apicontainerstatus.ContainerStopped,
+		KnownExitCodeUnsafe: &exitCode,
+	}
+	container.SetStopReason(apicontainer.NewStopReason(&exitCode, true, ""))
+	dockerContainer := &apicontainer.DockerContainer{
+		DockerID:   containerID,
+		DockerName: containerName,
+		Container:  container,
+	}
+	state.EXPECT().ContainerByID(containerID).Return(dockerContainer, true).Times(2)
+	state.EXPECT().TaskByID(containerID).Return(task, true)
+
+	containerResponse, err := NewContainerResponse(containerID, state)
+	require.NoError(t, err)
+	require.NotNil(t, containerResponse.StopReason)
+	assert.Equal(t, apicontainer.StopReasonOOMKilled, containerResponse.StopReason.Type)
+
+	data, err := json.Marshal(containerResponse)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"StopReason":{"type":"OOMKilled","signal":"SIGKILL"}`)
+}
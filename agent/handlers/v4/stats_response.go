@@ -0,0 +1,92 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package v4
+
+import (
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
+	"github.com/cihub/seelog"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// StatsResponse is the v4 Stats response. It augments the v2 stats response,
+// the last stats object from docker, with the distributions of the CPU and
+// memory usage of the container over its stats window, and with the disk used
+// by the container.
+type StatsResponse struct {
+	*types.StatsJSON
+	// UsageDistributions are only populated when distributions are enabled
+	// in the agent's configuration.
+	UsageDistributions *stats.UsageDistributions `json:"usage_distributions,omitempty"`
+	// DiskUsage is only populated when disk usage sampling is enabled in the
+	// agent's configuration, once the container has been sampled.
+	DiskUsage *stats.ContainerDiskUsage `json:"disk_usage,omitempty"`
+}
+
+// NewContainerStatsResponse returns a new container stats response object
+func NewContainerStatsResponse(taskARN string,
+	containerID string,
+	statsEngine stats.Engine) (*StatsResponse, error) {
+	dockerStats, err := statsEngine.ContainerDockerStats(taskARN, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if dockerStats == nil {
+		return nil, nil
+	}
+
+	resp := &StatsResponse{StatsJSON: dockerStats}
+	resp.UsageDistributions, err = statsEngine.ContainerUsageDistributions(taskARN, containerID)
+	if err != nil {
+		// The distributions are optional, the stats are still worth returning
+		seelog.Warnf("V4 stats response: Unable to get usage distributions for container '%s' for task '%s': %v",
+			containerID, taskARN, err)
+	}
+	resp.DiskUsage, err = statsEngine.ContainerDiskUsage(taskARN, containerID)
+	if err != nil {
+		seelog.Warnf("V4 stats response: Unable to get disk usage for container '%s' for task '%s': %v",
+			containerID, taskARN, err)
+	}
+	return resp, nil
+}
+
+// NewTaskStatsResponse returns a new task stats response object
+func NewTaskStatsResponse(taskARN string,
+	state dockerstate.TaskEngineState,
+	statsEngine stats.Engine) (map[string]*StatsResponse, error) {
+
+	containerMap, ok := state.ContainerMapByArn(taskARN)
+	if !ok {
+		return nil, errors.Errorf(
+			"v4 task stats response: unable to lookup containers for task %s",
+			taskARN)
+	}
+
+	resp := make(map[string]*StatsResponse)
+	for _, dockerContainer := range containerMap {
+		containerID := dockerContainer.DockerID
+		containerStats, err := NewContainerStatsResponse(taskARN, containerID, statsEngine)
+		if err != nil {
+			seelog.Warnf("V4 task stats response: Unable to get stats for container '%s' for task '%s': %v",
+				containerID, taskARN, err)
+			resp[containerID] = nil
+			continue
+		}
+
+		resp[containerID] = containerStats
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ImageCleanupSubsystem = "ImageCleanup"
+)
+
+// imageCleanupMetrics are the metrics of the image cleanup and of the usage of
+// the filesystem it frees up
+type imageCleanupMetrics struct {
+	runs           *prometheus.CounterVec
+	reclaimedBytes *prometheus.CounterVec
+	diskUsage      *prometheus.GaugeVec
+}
+
+func newImageCleanupMetrics(registry *prometheus.Registry) *imageCleanupMetrics {
+	runs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: AgentNamespace,
+		Subsystem: ImageCleanupSubsystem,
+		Name:      "run_count",
+		Help:      "Number of image cleanup runs",
+	}, []string{"Reason"})
+	registry.MustRegister(runs)
+
+	reclaimedBytes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: AgentNamespace,
+		Subsystem: ImageCleanupSubsystem,
+		Name:      "reclaimed_bytes",
+		Help:      "Bytes of the Docker data root filesystem freed up by image cleanup runs",
+	}, []string{"Reason"})
+	registry.MustRegister(reclaimedBytes)
+
+	diskUsage := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: AgentNamespace,
+		Subsystem: ImageCleanupSubsystem,
+		Name:      "disk_usage_percent",
+		Help:      "Percentage of the space and inodes of the Docker data root filesystem in use",
+	}, []string{"Resource"})
+	registry.MustRegister(diskUsage)
+
+	return &imageCleanupMetrics{
+		runs:           runs,
+		reclaimedBytes: reclaimedBytes,
+		diskUsage:      diskUsage,
+	}
+}
+
+// RecordImageCleanup records a run of the image cleanup started for the reason
+// and the bytes it freed up
+func (engine *MetricsEngine) RecordImageCleanup(reason string, reclaimedBytes uint64) {
+	if engine == nil || !engine.collection {
+		return
+	}
+	engine.imageCleanup.runs.WithLabelValues(reason).Inc()
+	engine.imageCleanup.reclaimedBytes.WithLabelValues(reason).Add(float64(reclaimedBytes))
+}
+
+// RecordDockerDataRootUsage records the percentages of the space and inodes of
+// the Docker data root filesystem in use
+func (engine *MetricsEngine) RecordDockerDataRootUsage(bytesUsedPercent, inodesUsedPercent float64) {
+	if engine == nil || !engine.collection {
+		return
+	}
+	engine.imageCleanup.diskUsage.WithLabelValues("bytes").Set(bytesUsedPercent)
+	engine.imageCleanup.diskUsage.WithLabelValues("inodes").Set(inodesUsedPercent)
+}
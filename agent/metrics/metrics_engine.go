@@ -32,6 +32,8 @@ type MetricsEngine struct {
 	ctx            context.Context
 	Registry       *prometheus.Registry
 	managedMetrics map[APIType]MetricsClient
+	imageCleanup   *imageCleanupMetrics
+	stateManager   *stateManagerMetrics
 }
 
 const (
@@ -88,6 +90,8 @@ func NewMetricsEngine(cfg *config.Config, registry *prometheus.Registry) *Metric
 		cfg:            cfg,
 		Registry:       registry,
 		managedMetrics: make(map[APIType]MetricsClient),
+		imageCleanup:   newImageCleanupMetrics(registry),
+		stateManager:   newStateManagerMetrics(registry),
 	}
 	for managedAPI, _ := range managedAPIs {
 		aClient := NewMetricsClient(managedAPI, metricsEngine.Registry)
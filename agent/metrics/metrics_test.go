@@ -215,3 +215,70 @@ func compareDiff(a, b, deltaMin float64) bool {
 	}
 	return diff <= (a * deltaMin)
 }
+
+func TestImageCleanupMetrics(t *testing.T) {
+	defer func() {
+		MetricsEngineGlobal = &MetricsEngine{
+			collection: false,
+		}
+	}()
+	// Recording metrics without collection is a no-op
+	MetricsEngineGlobal.RecordImageCleanup("interval", 100)
+
+	cfg := getTestConfig()
+	MustInit(&cfg, prometheus.NewRegistry())
+	MetricsEngineGlobal.RecordImageCleanup("interval", 100)
+	MetricsEngineGlobal.RecordImageCleanup("disk_pressure", 300)
+	MetricsEngineGlobal.RecordImageCleanup("disk_pressure", 200)
+	MetricsEngineGlobal.RecordDockerDataRootUsage(91.5, 20)
+
+	metricFamilies, err := MetricsEngineGlobal.Registry.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.GetMetric() {
+			key := metricFamily.GetName() + "/" + metric.GetLabel()[0].GetValue()
+			switch metricFamily.GetType() {
+			case dto.MetricType_COUNTER:
+				values[key] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				values[key] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, 1.0, values["AgentMetrics_ImageCleanup_run_count/interval"])
+	assert.Equal(t, 2.0, values["AgentMetrics_ImageCleanup_run_count/disk_pressure"])
+	assert.Equal(t, 100.0, values["AgentMetrics_ImageCleanup_reclaimed_bytes/interval"])
+	assert.Equal(t, 500.0, values["AgentMetrics_ImageCleanup_reclaimed_bytes/disk_pressure"])
+	assert.Equal(t, 91.5, values["AgentMetrics_ImageCleanup_disk_usage_percent/bytes"])
+	assert.Equal(t, 20.0, values["AgentMetrics_ImageCleanup_disk_usage_percent/inodes"])
+}
+
+func TestStateManagerMetrics(t *testing.T) {
+	defer func() {
+		MetricsEngineGlobal = &MetricsEngine{
+			collection: false,
+		}
+	}()
+	// Recording metrics without collection is a no-op
+	MetricsEngineGlobal.RecordCorruptedState("recovered")
+
+	cfg := getTestConfig()
+	MustInit(&cfg, prometheus.NewRegistry())
+	MetricsEngineGlobal.RecordCorruptedState("recovered")
+	MetricsEngineGlobal.RecordCorruptedState("recovered")
+	MetricsEngineGlobal.RecordCorruptedState("quarantined")
+
+	metricFamilies, err := MetricsEngineGlobal.Registry.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() != "AgentMetrics_StateManager_corrupted_state_count" {
+			continue
+		}
+		for _, metric := range metricFamily.GetMetric() {
+			values[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"recovered": 2, "quarantined": 1}, values)
+}
@@ -0,0 +1,46 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stateManagerMetrics are the metrics of the integrity of the saved state
+type stateManagerMetrics struct {
+	corruptedStates *prometheus.CounterVec
+}
+
+func newStateManagerMetrics(registry *prometheus.Registry) *stateManagerMetrics {
+	corruptedStates := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: AgentNamespace,
+		Subsystem: StateManagerSubsystem,
+		Name:      "corrupted_state_count",
+		Help:      "Number of times the saved state was found corrupted when loaded, by whether it was recovered from a snapshot or quarantined",
+	}, []string{"Outcome"})
+	registry.MustRegister(corruptedStates)
+
+	return &stateManagerMetrics{
+		corruptedStates: corruptedStates,
+	}
+}
+
+// RecordCorruptedState records that the saved state was found corrupted, and
+// the outcome of recovering it
+func (engine *MetricsEngine) RecordCorruptedState(outcome string) {
+	if engine == nil || !engine.collection {
+		return
+	}
+	engine.stateManager.corruptedStates.WithLabelValues(outcome).Inc()
+}
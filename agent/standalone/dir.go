@@ -0,0 +1,153 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// taskDirPollInterval is the interval at which the task directory is scanned
+	// for new, changed and removed task files
+	taskDirPollInterval = 5 * time.Second
+
+	taskFileExtension = ".json"
+)
+
+// taskFile is a task file that has been read from the task directory
+type taskFile struct {
+	modTime time.Time
+	// arn is the arn of the task in the file, it's empty if the file could
+	// not be read or translated
+	arn string
+}
+
+// taskDirWatcher polls a directory for task files. Tasks in new or modified
+// files are added to the task engine and tasks whose file has been removed
+// are stopped
+type taskDirWatcher struct {
+	dir   string
+	adder *taskAdder
+	files map[string]taskFile
+}
+
+func newTaskDirWatcher(dir string, adder *taskAdder) *taskDirWatcher {
+	return &taskDirWatcher{
+		dir:   dir,
+		adder: adder,
+		files: make(map[string]taskFile),
+	}
+}
+
+// watch scans the task directory until the context is cancelled
+func (watcher *taskDirWatcher) watch(ctx context.Context) {
+	seelog.Infof("Standalone: watching %s for tasks", watcher.dir)
+	ticker := time.NewTicker(taskDirPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := watcher.scan(); err != nil {
+			seelog.Warnf("Standalone: unable to scan task directory %s: %v", watcher.dir, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan adds the tasks in every task file that is new or has been modified since
+// the last scan, and stops the tasks whose file has been removed
+func (watcher *taskDirWatcher) scan() error {
+	entries, err := ioutil.ReadDir(watcher.dir)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{})
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), taskFileExtension) {
+			continue
+		}
+		path := filepath.Join(watcher.dir, entry.Name())
+		seen[path] = struct{}{}
+		previous, ok := watcher.files[path]
+		if ok && previous.modTime.Equal(entry.ModTime()) {
+			continue
+		}
+		arn, err := watcher.addTaskFromFile(path)
+		if err != nil {
+			seelog.Errorf("Standalone: unable to add task from file %s: %v", path, err)
+		}
+		if previous.arn != "" && previous.arn != arn {
+			watcher.stopTask(previous.arn)
+		}
+		// Failed files are remembered as well, so that they are only retried
+		// once they are modified
+		watcher.files[path] = taskFile{modTime: entry.ModTime(), arn: arn}
+	}
+
+	for path, file := range watcher.files {
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		delete(watcher.files, path)
+		if file.arn != "" {
+			seelog.Infof("Standalone: task file %s was removed", path)
+			watcher.stopTask(file.arn)
+		}
+	}
+	return nil
+}
+
+func (watcher *taskDirWatcher) addTaskFromFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	acsTask, err := decodeTask(data)
+	if err != nil {
+		return "", err
+	}
+	task, err := watcher.adder.addTask(acsTask)
+	if err != nil {
+		return "", err
+	}
+	return task.Arn, nil
+}
+
+func (watcher *taskDirWatcher) stopTask(arn string) {
+	if err := watcher.adder.stopTask(arn); err != nil {
+		seelog.Errorf("Standalone: unable to stop task [%s]: %v", arn, err)
+	}
+}
+
+// decodeTask decodes a task in the format used in ACS payload messages
+func decodeTask(data []byte) (*ecsacs.Task, error) {
+	acsTask := &ecsacs.Task{}
+	if err := jsonutil.UnmarshalJSON(acsTask, bytes.NewReader(data)); err != nil {
+		return nil, errors.Wrap(err, "standalone: unable to decode task")
+	}
+	return acsTask, nil
+}
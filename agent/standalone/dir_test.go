@@ -0,0 +1,98 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTaskJSON = `{
+	"arn": "arn:aws:ecs:us-west-2:123456789012:task/standalone/1",
+	"family": "standalone",
+	"version": "1",
+	"containers": [{"name": "app", "image": "busybox", "essential": true}]
+}`
+
+func TestTaskDirWatcherScan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+
+	dir, err := ioutil.TempDir("", "standalone")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	watcher := newTaskDirWatcher(dir, newTaskAdder(taskEngine, credentials.NewManager()))
+
+	taskPath := filepath.Join(dir, "task.json")
+	require.NoError(t, ioutil.WriteFile(taskPath, []byte(testTaskJSON), 0644))
+	// Files without the task file extension and invalid task files are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("tasks"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.json"), []byte("{"), 0644))
+
+	taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, testTaskARN, task.Arn)
+		assert.Equal(t, apitaskstatus.TaskRunning, task.GetDesiredStatus())
+	})
+	require.NoError(t, watcher.scan())
+
+	// Files that haven't changed are not added again
+	require.NoError(t, watcher.scan())
+
+	taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, testTaskARN, task.Arn)
+		assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+	})
+	require.NoError(t, os.Remove(taskPath))
+	require.NoError(t, watcher.scan())
+	assert.NotContains(t, watcher.files, taskPath)
+}
+
+func TestTaskDirWatcherScanModifiedFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+
+	dir, err := ioutil.TempDir("", "standalone")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	watcher := newTaskDirWatcher(dir, newTaskAdder(taskEngine, credentials.NewManager()))
+
+	taskPath := filepath.Join(dir, "task.json")
+	require.NoError(t, ioutil.WriteFile(taskPath, []byte(testTaskJSON), 0644))
+	taskEngine.EXPECT().AddTask(gomock.Any()).Times(2)
+	require.NoError(t, watcher.scan())
+
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(taskPath, modTime, modTime))
+	require.NoError(t, watcher.scan())
+}
+
+func TestTaskDirWatcherScanMissingDir(t *testing.T) {
+	watcher := newTaskDirWatcher(filepath.Join(os.TempDir(), "standalone-does-not-exist"), nil)
+	assert.Error(t, watcher.scan())
+}
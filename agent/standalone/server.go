@@ -0,0 +1,125 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+const (
+	// TasksPath is the path on the task socket to which tasks are posted
+	TasksPath = "/v1/tasks"
+
+	// maxTaskBodySize is the maximum size of a task posted to the task socket
+	maxTaskBodySize = 1 << 20
+
+	socketReadTimeout  = 10 * time.Second
+	socketWriteTimeout = 10 * time.Second
+)
+
+// TaskResponse is the response to a task posted on the task socket
+type TaskResponse struct {
+	TaskARN       string `json:"TaskARN,omitempty"`
+	DesiredStatus string `json:"DesiredStatus,omitempty"`
+	Error         string `json:"Error,omitempty"`
+}
+
+// tasksHandler returns the handler for tasks posted on the task socket. Posting a
+// task with the desired status 'STOPPED' stops the task
+func tasksHandler(adder *taskAdder) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeTaskResponse(w, http.StatusMethodNotAllowed, TaskResponse{Error: "method not allowed"})
+			return
+		}
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTaskBodySize))
+		if err != nil {
+			writeTaskResponse(w, http.StatusBadRequest, TaskResponse{Error: err.Error()})
+			return
+		}
+		acsTask, err := decodeTask(data)
+		if err != nil {
+			writeTaskResponse(w, http.StatusBadRequest, TaskResponse{Error: err.Error()})
+			return
+		}
+		task, err := adder.addTask(acsTask)
+		if err != nil {
+			writeTaskResponse(w, http.StatusBadRequest, TaskResponse{Error: err.Error()})
+			return
+		}
+		writeTaskResponse(w, http.StatusAccepted, TaskResponse{
+			TaskARN:       task.Arn,
+			DesiredStatus: task.GetDesiredStatus().String(),
+		})
+	}
+}
+
+func writeTaskResponse(w http.ResponseWriter, httpStatusCode int, response TaskResponse) {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		seelog.Errorf("Standalone: unable to marshal task response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusCode)
+	if _, err := w.Write(responseJSON); err != nil {
+		seelog.Errorf("Standalone: unable to write task response: %v", err)
+	}
+}
+
+// serveTaskSocket accepts tasks on the unix socket at the given path until the
+// context is cancelled
+func serveTaskSocket(ctx context.Context, socketPath string, adder *taskAdder) error {
+	// Remove the socket left behind by a previous run of the agent
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	serverMux := http.NewServeMux()
+	serverMux.HandleFunc(TasksPath, tasksHandler(adder))
+	server := &http.Server{
+		Handler:      serverMux,
+		ReadTimeout:  socketReadTimeout,
+		WriteTimeout: socketWriteTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	seelog.Infof("Standalone: accepting tasks on %s", socketPath)
+	err = server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
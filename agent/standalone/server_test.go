@@ -0,0 +1,84 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTasksHandlerPostTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	handler := tasksHandler(newTaskAdder(taskEngine, credentials.NewManager()))
+
+	taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, testTaskARN, task.Arn)
+	})
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, TasksPath, strings.NewReader(testTaskJSON))
+	require.NoError(t, err)
+	handler(recorder, req)
+
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	var response TaskResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, testTaskARN, response.TaskARN)
+	assert.Equal(t, apitaskstatus.TaskRunning.String(), response.DesiredStatus)
+}
+
+func TestTasksHandlerInvalidTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	handler := tasksHandler(newTaskAdder(mock_engine.NewMockTaskEngine(ctrl), credentials.NewManager()))
+
+	for _, body := range []string{"{", `{"family": "standalone"}`} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, TasksPath, strings.NewReader(body))
+		require.NoError(t, err)
+		handler(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		var response TaskResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Error)
+	}
+}
+
+func TestTasksHandlerMethodNotAllowed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	handler := tasksHandler(newTaskAdder(mock_engine.NewMockTaskEngine(ctrl), credentials.NewManager()))
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, TasksPath, nil)
+	require.NoError(t, err)
+	handler(recorder, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
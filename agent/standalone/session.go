@@ -0,0 +1,77 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"context"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// Session runs the tasks found in the task directory and received on the task
+// socket, and records their state changes in a StateChangeSink. It takes the
+// place of the ACS session and the event handlers when the agent runs in
+// standalone mode
+type Session struct {
+	ctx        context.Context
+	cfg        *config.Config
+	taskEngine engine.TaskEngine
+	sink       StateChangeSink
+	adder      *taskAdder
+}
+
+// NewSession creates a new standalone session
+func NewSession(ctx context.Context,
+	cfg *config.Config,
+	taskEngine engine.TaskEngine,
+	credentialsManager credentials.Manager,
+	sink StateChangeSink) *Session {
+	return &Session{
+		ctx:        ctx,
+		cfg:        cfg,
+		taskEngine: taskEngine,
+		sink:       sink,
+		adder:      newTaskAdder(taskEngine, credentialsManager),
+	}
+}
+
+// Start starts the session. It blocks until the context is cancelled or the
+// task socket can no longer be served
+func (session *Session) Start() error {
+	if !session.cfg.StandaloneEnabled() {
+		return errors.New("standalone: neither a task directory nor a task socket is configured")
+	}
+
+	go handleStateChanges(session.ctx, session.taskEngine.StateChangeEvents(), session.sink)
+
+	if session.cfg.StandaloneTaskDir != "" {
+		go newTaskDirWatcher(session.cfg.StandaloneTaskDir, session.adder).watch(session.ctx)
+	}
+
+	if session.cfg.StandaloneTaskSocket != "" {
+		err := serveTaskSocket(session.ctx, session.cfg.StandaloneTaskSocket, session.adder)
+		if err != nil {
+			return errors.Wrapf(err, "standalone: unable to serve task socket %s", session.cfg.StandaloneTaskSocket)
+		}
+		return nil
+	}
+
+	<-session.ctx.Done()
+	seelog.Info("Standalone: session stopped")
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/statechange"
+	"github.com/cihub/seelog"
+)
+
+// StateChangeSink receives the task and container state changes that are sent to
+// ECS when the agent is not running in standalone mode
+type StateChangeSink interface {
+	// SubmitTaskStateChange records a task state change
+	SubmitTaskStateChange(change api.TaskStateChange) error
+	// SubmitContainerStateChange records a container state change
+	SubmitContainerStateChange(change api.ContainerStateChange) error
+}
+
+// stateChangeRecord is a single state change, as written to the state change log
+type stateChangeRecord struct {
+	Time          time.Time `json:"time"`
+	TaskARN       string    `json:"taskArn"`
+	ContainerName string    `json:"containerName,omitempty"`
+	RuntimeID     string    `json:"runtimeId,omitempty"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason,omitempty"`
+	ExitCode      *int      `json:"exitCode,omitempty"`
+}
+
+// stateChangeLog writes every state change as a line of JSON
+type stateChangeLog struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+// NewStateChangeLog returns a sink that appends state changes to the file at the
+// given path. If the path is empty, state changes are only logged
+func NewStateChangeLog(path string) (StateChangeSink, error) {
+	if path == "" {
+		return &stateChangeLog{}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &stateChangeLog{writer: file}, nil
+}
+
+// SubmitTaskStateChange writes the task state change to the log
+func (sink *stateChangeLog) SubmitTaskStateChange(change api.TaskStateChange) error {
+	seelog.Infof("Standalone: task state change: %s", change.String())
+	return sink.write(stateChangeRecord{
+		Time:    time.Now(),
+		TaskARN: change.TaskARN,
+		Status:  change.Status.String(),
+		Reason:  change.Reason,
+	})
+}
+
+// SubmitContainerStateChange writes the container state change to the log
+func (sink *stateChangeLog) SubmitContainerStateChange(change api.ContainerStateChange) error {
+	seelog.Infof("Standalone: container state change: %s", change.String())
+	return sink.write(stateChangeRecord{
+		Time:          time.Now(),
+		TaskARN:       change.TaskArn,
+		ContainerName: change.ContainerName,
+		RuntimeID:     change.RuntimeID,
+		Status:        change.Status.String(),
+		Reason:        change.Reason,
+		ExitCode:      change.ExitCode,
+	})
+}
+
+func (sink *stateChangeLog) write(record stateChangeRecord) error {
+	if sink.writer == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	_, err = sink.writer.Write(append(data, '\n'))
+	return err
+}
+
+// handleStateChanges sends the state change events emitted by the task engine to
+// the sink until the context is cancelled. Changes are marked as sent once they
+// have been recorded, so that the engine can clean up stopped tasks as it would
+// once their state has been reported to ECS
+func handleStateChanges(ctx context.Context, events <-chan statechange.Event, sink StateChangeSink) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				seelog.Error("Standalone: unable to handle state change event. The events channel is closed")
+				return
+			}
+			if err := handleStateChange(event, sink); err != nil {
+				seelog.Errorf("Standalone: unable to record state change event %v: %v", event, err)
+			}
+		}
+	}
+}
+
+func handleStateChange(event statechange.Event, sink StateChangeSink) error {
+	switch change := event.(type) {
+	case api.TaskStateChange:
+		if change.Task != nil && change.Task.GetSentStatus() >= change.Status {
+			return nil
+		}
+		if err := sink.SubmitTaskStateChange(change); err != nil {
+			return err
+		}
+		if change.Task != nil {
+			change.Task.SetSentStatus(change.Status)
+		}
+		for _, containerChange := range change.Containers {
+			setContainerChangeSent(containerChange)
+		}
+	case api.ContainerStateChange:
+		if change.Container != nil && change.Container.GetSentStatus() >= change.Status {
+			return nil
+		}
+		if err := sink.SubmitContainerStateChange(change); err != nil {
+			return err
+		}
+		setContainerChangeSent(change)
+	default:
+		// Attachments are only received from ACS, there is nothing to acknowledge
+		seelog.Debugf("Standalone: ignoring state change event: %v", event)
+	}
+	return nil
+}
+
+func setContainerChangeSent(change api.ContainerStateChange) {
+	if change.Container != nil && change.Container.GetSentStatus() < change.Status {
+		change.Container.SetSentStatus(change.Status)
+	}
+}
@@ -0,0 +1,92 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleStateChange(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := &stateChangeLog{writer: buf}
+
+	container := &apicontainer.Container{Name: "app"}
+	task := &apitask.Task{
+		Arn:        testTaskARN,
+		Containers: []*apicontainer.Container{container},
+	}
+	containerChange := api.ContainerStateChange{
+		TaskArn:       testTaskARN,
+		ContainerName: "app",
+		RuntimeID:     "runtime-id",
+		Status:        apicontainerstatus.ContainerStopped,
+		ExitCode:      aws.Int(1),
+		Container:     container,
+	}
+	taskChange := api.TaskStateChange{
+		TaskARN:    testTaskARN,
+		Status:     apitaskstatus.TaskStopped,
+		Reason:     "Essential container in task exited",
+		Containers: []api.ContainerStateChange{containerChange},
+		Task:       task,
+	}
+
+	require.NoError(t, handleStateChange(containerChange, sink))
+	assert.Equal(t, apicontainerstatus.ContainerStopped, container.GetSentStatus())
+	require.NoError(t, handleStateChange(taskChange, sink))
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetSentStatus())
+
+	// Changes that were already recorded are not recorded again
+	require.NoError(t, handleStateChange(containerChange, sink))
+	require.NoError(t, handleStateChange(taskChange, sink))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var record stateChangeRecord
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, testTaskARN, record.TaskARN)
+	assert.Equal(t, "app", record.ContainerName)
+	assert.Equal(t, "runtime-id", record.RuntimeID)
+	assert.Equal(t, apicontainerstatus.ContainerStopped.String(), record.Status)
+	require.NotNil(t, record.ExitCode)
+	assert.Equal(t, 1, *record.ExitCode)
+
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.Equal(t, testTaskARN, record.TaskARN)
+	assert.Equal(t, apitaskstatus.TaskStopped.String(), record.Status)
+	assert.Equal(t, "Essential container in task exited", record.Reason)
+}
+
+func TestStateChangeLogWithoutPath(t *testing.T) {
+	sink, err := NewStateChangeLog("")
+	require.NoError(t, err)
+	assert.NoError(t, sink.SubmitTaskStateChange(api.TaskStateChange{
+		TaskARN: testTaskARN,
+		Status:  apitaskstatus.TaskRunning,
+	}))
+}
@@ -0,0 +1,103 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package standalone runs tasks that are read from a local directory or received
+// on a local socket, without a connection to the ECS backend.
+package standalone
+
+import (
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// taskAdder converts tasks in the ACS format and adds them to the task engine. It
+// remembers the last version of every task it added so that the task can be
+// stopped when its source goes away
+type taskAdder struct {
+	taskEngine         engine.TaskEngine
+	credentialsManager credentials.Manager
+	// tasks maps the task arn to the last version of the task that was added
+	tasks map[string]*ecsacs.Task
+	lock  sync.Mutex
+}
+
+func newTaskAdder(taskEngine engine.TaskEngine, credentialsManager credentials.Manager) *taskAdder {
+	return &taskAdder{
+		taskEngine:         taskEngine,
+		credentialsManager: credentialsManager,
+		tasks:              make(map[string]*ecsacs.Task),
+	}
+}
+
+// addTask converts the task and adds it to the task engine. Adding a task that
+// was added before updates its desired status
+func (adder *taskAdder) addTask(acsTask *ecsacs.Task) (*apitask.Task, error) {
+	if acsTask == nil {
+		return nil, errors.New("standalone: task is empty")
+	}
+	if aws.StringValue(acsTask.Arn) == "" {
+		return nil, errors.New("standalone: task arn is not set")
+	}
+	if aws.StringValue(acsTask.DesiredStatus) == "" {
+		acsTask.DesiredStatus = aws.String(apitaskstatus.TaskRunning.String())
+	}
+
+	task, err := adder.taskFromACS(acsTask)
+	if err != nil {
+		return nil, err
+	}
+
+	adder.lock.Lock()
+	defer adder.lock.Unlock()
+
+	adder.tasks[task.Arn] = acsTask
+	adder.taskEngine.AddTask(task)
+	seelog.Infof("Standalone: added task [%s] with desired status %s", task.Arn, task.GetDesiredStatus().String())
+	return task, nil
+}
+
+// stopTask sets the desired status of a task that was added before to stopped
+func (adder *taskAdder) stopTask(arn string) error {
+	adder.lock.Lock()
+	acsTask, ok := adder.tasks[arn]
+	adder.lock.Unlock()
+	if !ok {
+		return errors.Errorf("standalone: unknown task: %s", arn)
+	}
+	if aws.StringValue(acsTask.DesiredStatus) == apitaskstatus.TaskStopped.String() {
+		return nil
+	}
+
+	stoppedTask := *acsTask
+	stoppedTask.DesiredStatus = aws.String(apitaskstatus.TaskStopped.String())
+	_, err := adder.addTask(&stoppedTask)
+	return err
+}
+
+// taskFromACS translates the task in the same way as tasks received from ACS
+// are translated by the payload handler
+func (adder *taskAdder) taskFromACS(acsTask *ecsacs.Task) (*apitask.Task, error) {
+	task, err := apitask.TaskFromACSWithCredentials(acsTask, &ecsacs.PayloadMessage{}, adder.credentialsManager)
+	if err != nil {
+		return nil, errors.Wrapf(err, "standalone: unable to translate task %s", aws.StringValue(acsTask.Arn))
+	}
+	return task, nil
+}
@@ -0,0 +1,132 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/credentials"
+	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTaskARN = "arn:aws:ecs:us-west-2:123456789012:task/standalone/1"
+
+func testACSTask() *ecsacs.Task {
+	return &ecsacs.Task{
+		Arn:     aws.String(testTaskARN),
+		Family:  aws.String("standalone"),
+		Version: aws.String("1"),
+		Containers: []*ecsacs.Container{
+			{
+				Name:  aws.String("app"),
+				Image: aws.String("busybox"),
+			},
+		},
+	}
+}
+
+func TestAddTaskDefaultsDesiredStatusToRunning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	adder := newTaskAdder(taskEngine, credentials.NewManager())
+
+	taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+		assert.Equal(t, testTaskARN, task.Arn)
+		assert.Equal(t, apitaskstatus.TaskRunning, task.GetDesiredStatus())
+		require.Len(t, task.Containers, 1)
+		assert.Equal(t, "app", task.Containers[0].Name)
+	})
+
+	task, err := adder.addTask(testACSTask())
+	require.NoError(t, err)
+	assert.Equal(t, testTaskARN, task.Arn)
+}
+
+func TestAddTaskWithoutArn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	adder := newTaskAdder(mock_engine.NewMockTaskEngine(ctrl), credentials.NewManager())
+
+	acsTask := testACSTask()
+	acsTask.Arn = nil
+	_, err := adder.addTask(acsTask)
+	assert.Error(t, err)
+}
+
+func TestAddTaskWithRoleCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	credentialsManager := credentials.NewManager()
+	adder := newTaskAdder(taskEngine, credentialsManager)
+
+	acsTask := testACSTask()
+	acsTask.RoleCredentials = &ecsacs.IAMRoleCredentials{
+		AccessKeyId:     aws.String("akid"),
+		CredentialsId:   aws.String("credsid"),
+		Expiration:      aws.String("expiration"),
+		RoleArn:         aws.String("rolearn"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+	}
+	taskEngine.EXPECT().AddTask(gomock.Any())
+
+	task, err := adder.addTask(acsTask)
+	require.NoError(t, err)
+	assert.Equal(t, "credsid", task.GetCredentialsID())
+	taskCredentials, ok := credentialsManager.GetTaskCredentials("credsid")
+	require.True(t, ok)
+	assert.Equal(t, testTaskARN, taskCredentials.ARN)
+}
+
+func TestStopTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	taskEngine := mock_engine.NewMockTaskEngine(ctrl)
+	adder := newTaskAdder(taskEngine, credentials.NewManager())
+
+	gomock.InOrder(
+		taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+			assert.Equal(t, apitaskstatus.TaskRunning, task.GetDesiredStatus())
+		}),
+		taskEngine.EXPECT().AddTask(gomock.Any()).Do(func(task *apitask.Task) {
+			assert.Equal(t, testTaskARN, task.Arn)
+			assert.Equal(t, apitaskstatus.TaskStopped, task.GetDesiredStatus())
+		}),
+	)
+
+	_, err := adder.addTask(testACSTask())
+	require.NoError(t, err)
+	require.NoError(t, adder.stopTask(testTaskARN))
+	// Stopping a task that is already stopped is a no-op
+	require.NoError(t, adder.stopTask(testTaskARN))
+}
+
+func TestStopUnknownTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	adder := newTaskAdder(mock_engine.NewMockTaskEngine(ctrl), credentials.NewManager())
+
+	assert.Error(t, adder.stopTask(testTaskARN))
+}
@@ -45,6 +45,7 @@ type FS interface {
 	ReadAll(f File) ([]byte, error)
 	TempFile(dir, prefix string) (File, error)
 	Remove(name string) error
+	Rename(oldpath, newpath string) error
 }
 
 // File is an interface for the os.File type
@@ -87,3 +88,7 @@ func (StdFS) TempFile(dir, prefix string) (File, error) {
 func (StdFS) Remove(name string) error {
 	return os.Remove(name)
 }
+
+func (StdFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
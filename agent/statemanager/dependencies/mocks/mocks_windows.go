@@ -182,6 +182,16 @@ func (_mr *_MockFSRecorder) Remove(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Remove", arg0)
 }
 
+func (_m *MockFS) Rename(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "Rename", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockFSRecorder) Rename(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rename", arg0, arg1)
+}
+
 func (_m *MockFS) TempFile(_param0 string, _param1 string) (dependencies.File, error) {
 	ret := _m.ctrl.Call(_m, "TempFile", _param0, _param1)
 	ret0, _ := ret[0].(dependencies.File)
@@ -0,0 +1,279 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+/*
+When an encryption key is configured, the state is encrypted with AES-256-GCM
+before it's written, the whole of it in the JSON state file and each value on
+its own in the key-value store. Encrypted data is wrapped in an envelope:
+
+	"ECSENC" | version (1 byte) | key ID (8 bytes) | nonce (12 bytes) | ciphertext
+
+The key ID is the start of the SHA-256 of the key, so that data encrypted with
+another key is told apart from corrupted data. The header up to the nonce is
+authenticated along with the ciphertext, and so is the path of buckets and key
+a value is saved at in the key-value store, so that a value can't be swapped
+for another one encrypted with the same key.
+
+Only the values in the key-value store are encrypted. The names of its buckets
+and its keys, which are the ARNs of tasks, the IDs of containers and images and
+the like, are saved as they are. The store is compacted into a new file the
+first time it's saved with a key, since bolt leaves the values it overwrites in
+its free pages.
+
+Data that doesn't start with the envelope magic is taken to be plain JSON that
+was saved before encryption was enabled, and is encrypted the next time it's
+saved.
+
+The state files that were moved aside rather than removed, which are the state
+file moved to the key-value store and the quarantined state files, are
+encrypted as a whole when the state is loaded with a key, and re-encrypted when
+the key is rotated. Quarantined stores can't be re-encrypted value by value, as
+they're corrupted, so they're removed once the store is encrypted or its key is
+rotated.
+*/
+
+const (
+	envelopeMagic = "ECSENC"
+	// envelopeVersion1 is the version of the envelope described above
+	envelopeVersion1 byte = 1
+	keyIDSize             = 8
+	// encryptionKeySize is the size of the AES-256 key
+	encryptionKeySize = 32
+
+	envelopeHeaderSize = len(envelopeMagic) + 1 + keyIDSize
+)
+
+// stateCipher encrypts and decrypts the saved state with a key
+type stateCipher struct {
+	aead  cipher.AEAD
+	keyID []byte
+}
+
+func newStateCipher(key []byte) (*stateCipher, error) {
+	if len(key) != encryptionKeySize {
+		return nil, errors.Errorf("state encryption key must be %d bytes, not %d", encryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	keyHash := sha256.Sum256(key)
+	return &stateCipher{
+		aead:  aead,
+		keyID: keyHash[:keyIDSize],
+	}, nil
+}
+
+// newConfiguredStateCipher returns the cipher for the encryption key
+// configured in cfg, or nil if no key is configured. The key in
+// cfg.StateEncryptionKey takes precedence over the one in the file at
+// cfg.StateEncryptionKeyFile.
+func newConfiguredStateCipher(cfg *config.Config) (*stateCipher, error) {
+	var encodedKey string
+	switch {
+	case cfg.StateEncryptionKey != nil:
+		encodedKey = string(cfg.StateEncryptionKey.Contents())
+	case cfg.StateEncryptionKeyFile != "":
+		data, err := ioutil.ReadFile(cfg.StateEncryptionKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read the state encryption key file")
+		}
+		encodedKey = string(data)
+	default:
+		return nil, nil
+	}
+	return parseStateCipher(encodedKey)
+}
+
+// parseStateCipher returns the cipher for a base64 encoded key
+func parseStateCipher(encodedKey string) (*stateCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "state encryption key must be encoded in base64")
+	}
+	return newStateCipher(key)
+}
+
+// seal encrypts the data into an envelope. The path the data is saved at is
+// authenticated along with it.
+func (c *stateCipher) seal(plaintext []byte, path []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	envelope := make([]byte, envelopeHeaderSize+nonceSize,
+		envelopeHeaderSize+nonceSize+len(plaintext)+c.aead.Overhead())
+	copy(envelope, envelopeMagic)
+	envelope[len(envelopeMagic)] = envelopeVersion1
+	copy(envelope[len(envelopeMagic)+1:], c.keyID)
+	nonce := envelope[envelopeHeaderSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "could not generate a nonce")
+	}
+	return c.aead.Seal(envelope, nonce, plaintext, additionalData(envelope[:envelopeHeaderSize], path)), nil
+}
+
+// additionalData returns the data authenticated along with the ciphertext
+func additionalData(header []byte, path []byte) []byte {
+	return append(append([]byte(nil), header...), path...)
+}
+
+// valuePath returns the path of a value in the key-value store, from the names
+// of the buckets it's in and its key. Each name is prefixed with its length,
+// since keys such as task ARNs may contain any separator.
+func valuePath(names ...[]byte) []byte {
+	var path []byte
+	for _, name := range names {
+		path = strconv.AppendInt(path, int64(len(name)), 10)
+		path = append(path, ':')
+		path = append(path, name...)
+	}
+	return path
+}
+
+// sealedWith returns whether the data is an envelope sealed with the key of
+// the cipher
+func (c *stateCipher) sealedWith(data []byte) bool {
+	return isEnvelope(data) && len(data) >= envelopeHeaderSize &&
+		bytes.Equal(data[len(envelopeMagic)+1:envelopeHeaderSize], c.keyID)
+}
+
+// isEnvelope returns whether the data is encrypted
+func isEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(envelopeMagic))
+}
+
+// openState returns the plain data of the state read from disk, at the path it
+// was saved at in the key-value store or at a nil path. Data that isn't
+// encrypted is returned as is.
+func openState(c *stateCipher, data []byte, path []byte) ([]byte, error) {
+	if !isEnvelope(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, errors.New("state is encrypted, but no state encryption key is configured")
+	}
+	if len(data) < envelopeHeaderSize+c.aead.NonceSize() {
+		return nil, &corruptStateError{reason: "encrypted state is truncated"}
+	}
+	if version := data[len(envelopeMagic)]; version != envelopeVersion1 {
+		return nil, errors.Errorf("unsupported state encryption version %d", version)
+	}
+	if keyID := data[len(envelopeMagic)+1 : envelopeHeaderSize]; !bytes.Equal(keyID, c.keyID) {
+		return nil, errors.Errorf("state is encrypted with key %x, not with the configured key %x", keyID, c.keyID)
+	}
+	header := data[:envelopeHeaderSize]
+	nonce := data[envelopeHeaderSize : envelopeHeaderSize+c.aead.NonceSize()]
+	plaintext, err := c.aead.Open(nil, nonce, data[envelopeHeaderSize+c.aead.NonceSize():], additionalData(header, path))
+	if err != nil {
+		return nil, &corruptStateError{reason: "could not decrypt state: " + err.Error()}
+	}
+	return plaintext, nil
+}
+
+// sealState returns the data of the state to write to disk at a path in the
+// key-value store, or at a nil path, which is encrypted if there's a cipher
+func sealState(c *stateCipher, plaintext []byte, path []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	return c.seal(plaintext, path)
+}
+
+// encryptLeftoverFiles encrypts the state files that were moved aside with the
+// configured key, if there's one
+func (manager *basicStateManager) encryptLeftoverFiles() {
+	if manager.cipher == nil {
+		return
+	}
+	if err := manager.sealLeftoverFiles(manager.cipher); err != nil {
+		seelog.Warnf("Unable to encrypt the state files that were moved aside: %v", err)
+	}
+}
+
+// sealLeftoverFiles encrypts the state files that were moved aside with
+// newCipher, unless they already are. They're decrypted with the current
+// cipher, and the ones that can't be are removed, since they couldn't be read
+// anymore anyway.
+func (manager *basicStateManager) sealLeftoverFiles(newCipher *stateCipher) error {
+	paths, err := filepath.Glob(filepath.Join(manager.statePath, ecsDataFile+quarantineInfix+"*"))
+	if err != nil {
+		return err
+	}
+	paths = append(paths, filepath.Join(manager.statePath, ecsDataFile+migratedStateFileSuffix))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if newCipher.sealedWith(data) {
+			continue
+		}
+		data, err = openState(manager.cipher, data, nil)
+		if err == nil {
+			data, err = newCipher.seal(data, nil)
+		}
+		if err != nil {
+			seelog.Warnf("Removing the state file %s that can't be encrypted: %v", path, err)
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		seelog.Infof("Encrypting the state file %s", path)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeQuarantinedStores removes the key-value stores that were quarantined,
+// which hold values saved in plain text or with another key
+func (manager *basicStateManager) removeQuarantinedStores() error {
+	paths, err := filepath.Glob(filepath.Join(manager.statePath, ecsDataStore+quarantineInfix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		seelog.Infof("Removing the quarantined state store %s", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
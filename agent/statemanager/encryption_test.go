@@ -0,0 +1,146 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testEncodedKey      = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, encryptionKeySize))
+	testOtherEncodedKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, encryptionKeySize))
+)
+
+func TestSealOpenState(t *testing.T) {
+	c, err := parseStateCipher(testEncodedKey)
+	require.NoError(t, err)
+	plaintext := []byte(`{"Data":{"Cluster":"default"},"Version":31}`)
+
+	sealed, err := sealState(c, plaintext, nil)
+	require.NoError(t, err)
+	assert.True(t, c.sealedWith(sealed))
+	assert.False(t, bytes.Contains(sealed, []byte("default")), "The state should not be saved in plain text")
+	opened, err := openState(c, sealed, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	sealedAgain, err := sealState(c, plaintext, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, sealedAgain, "Each encryption should use a new nonce")
+}
+
+func TestOpenStateErrors(t *testing.T) {
+	c, err := parseStateCipher(testEncodedKey)
+	require.NoError(t, err)
+	otherCipher, err := parseStateCipher(testOtherEncodedKey)
+	require.NoError(t, err)
+	path := valuePath([]byte("saveables"), []byte("Cluster"))
+	sealed, err := c.seal([]byte(`{}`), path)
+	require.NoError(t, err)
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	unsupportedVersion := append([]byte(nil), sealed...)
+	unsupportedVersion[len(envelopeMagic)] = 2
+
+	testCases := []struct {
+		name   string
+		cipher *stateCipher
+		data   []byte
+		path   []byte
+	}{
+		{"no key", nil, sealed, path},
+		{"other key", otherCipher, sealed, path},
+		{"tampered", c, tampered, path},
+		{"truncated", c, sealed[:envelopeHeaderSize+2], path},
+		{"unsupported version", c, unsupportedVersion, path},
+		{"other path", c, sealed, valuePath([]byte("saveables"), []byte("ContainerInstanceArn"))},
+		{"no path", c, sealed, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := openState(tc.cipher, tc.data, tc.path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOpenStatePlainData(t *testing.T) {
+	c, err := parseStateCipher(testEncodedKey)
+	require.NoError(t, err)
+	plaintext := []byte(`{"Data":{},"Version":31}`)
+
+	for _, stateCipher := range []*stateCipher{nil, c} {
+		opened, err := openState(stateCipher, plaintext, nil)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, opened)
+	}
+	sealed, err := sealState(nil, plaintext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, sealed)
+}
+
+func TestValuePath(t *testing.T) {
+	assert.NotEqual(t, valuePath([]byte("a/b"), []byte("c")), valuePath([]byte("a"), []byte("b/c")))
+	assert.NotEqual(t, valuePath([]byte("ab"), []byte("c")), valuePath([]byte("a"), []byte("bc")))
+	assert.Equal(t, valuePath([]byte("a"), []byte("b")), valuePath([]byte("a"), []byte("b")))
+}
+
+func TestParseStateCipherInvalidKey(t *testing.T) {
+	_, err := parseStateCipher("not base64!")
+	assert.Error(t, err)
+	_, err = parseStateCipher(base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.Error(t, err)
+}
+
+func TestNewConfiguredStateCipher(t *testing.T) {
+	keyFile, err := ioutil.TempFile("", "ecs_state_key")
+	require.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString(testOtherEncodedKey + "\n")
+	require.NoError(t, err)
+	require.NoError(t, keyFile.Close())
+	c, err := parseStateCipher(testEncodedKey)
+	require.NoError(t, err)
+	otherCipher, err := parseStateCipher(testOtherEncodedKey)
+	require.NoError(t, err)
+
+	configuredCipher, err := newConfiguredStateCipher(&config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, configuredCipher)
+
+	configuredCipher, err = newConfiguredStateCipher(&config.Config{StateEncryptionKeyFile: keyFile.Name()})
+	require.NoError(t, err)
+	assert.Equal(t, otherCipher.keyID, configuredCipher.keyID)
+
+	configuredCipher, err = newConfiguredStateCipher(&config.Config{
+		StateEncryptionKey:     config.NewSensitiveRawMessage([]byte(testEncodedKey)),
+		StateEncryptionKeyFile: keyFile.Name(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, c.keyID, configuredCipher.keyID, "The key should take precedence over the key file")
+
+	_, err = newConfiguredStateCipher(&config.Config{StateEncryptionKeyFile: keyFile.Name() + ".missing"})
+	assert.Error(t, err)
+}
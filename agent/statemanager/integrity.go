@@ -0,0 +1,224 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+/*
+The JSON state file carries the SHA-256 checksum of its data, so that a file
+that was corrupted after it was written is detected when it's loaded. Every
+time the state file is saved, a snapshot of it is kept next to it as well:
+
+	ecs_agent_data.json.1 (the newest) ... ecs_agent_data.json.<StateBackupCount>
+
+If the state file turns out to be corrupted, the state is recovered from the
+newest good snapshot. If there's none, the state file is quarantined, i.e.
+moved aside instead of being overwritten by the next save, and the agent starts
+with an empty state.
+*/
+
+const (
+	checksumPrefix = "sha256:"
+	// quarantineInfix is added to the name of a quarantined state file, along
+	// with the time it was quarantined
+	quarantineInfix = ".corrupted-"
+	// quarantineTimeFormat is the format of the time in the name of a
+	// quarantined state file
+	quarantineTimeFormat = "20060102T150405Z"
+
+	corruptedStateRecovered   = "recovered"
+	corruptedStateQuarantined = "quarantined"
+)
+
+// corruptStateError is returned when the saved state is corrupted, as opposed
+// to when it can't be read for another reason, such as a missing key
+type corruptStateError struct {
+	reason string
+}
+
+func (err *corruptStateError) Error() string {
+	return "saved state is corrupted: " + err.reason
+}
+
+func isCorruptState(err error) bool {
+	_, ok := errors.Cause(err).(*corruptStateError)
+	return ok
+}
+
+// checksummedState is the layout of the state file that the checksum is
+// verified against. Data is kept as it was saved, since the checksum is of the
+// exact JSON of it.
+type checksummedState struct {
+	Data     json.RawMessage
+	Version  int
+	Checksum string `json:",omitempty"`
+}
+
+// marshalState returns the JSON of the state along with the checksum of its
+// data
+func marshalState(s *state) ([]byte, error) {
+	data, err := json.Marshal(s.Data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&checksummedState{
+		Data:     data,
+		Version:  s.Version,
+		Checksum: stateChecksum(data),
+	})
+}
+
+// verifyState checks that the JSON of the state is whole and matches its
+// checksum. State saved before checksums were added has none to check.
+func verifyState(data []byte) error {
+	var saved checksummedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return &corruptStateError{reason: err.Error()}
+	}
+	if saved.Checksum == "" {
+		return nil
+	}
+	if checksum := stateChecksum(saved.Data); checksum != saved.Checksum {
+		return &corruptStateError{reason: "checksum " + checksum + " does not match the saved checksum " + saved.Checksum}
+	}
+	return nil
+}
+
+func stateChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return checksumPrefix + hex.EncodeToString(sum[:])
+}
+
+// readState returns the plain JSON of the state saved in the state file, or nil
+// if there's none. The state is recovered from a snapshot if the state file is
+// corrupted.
+func (manager *basicStateManager) readState() ([]byte, error) {
+	data, err := manager.readFile()
+	if err != nil {
+		seelog.Error("Error reading existing state file", "err", err)
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+	data, err = manager.openStateFile(data)
+	if err == nil {
+		return data, nil
+	}
+	if !isCorruptState(err) {
+		seelog.Errorf("Error reading existing state file: %v", err)
+		return nil, err
+	}
+	seelog.Criticalf("Unable to load the state file, recovering the state from a snapshot: %v", err)
+	return manager.recoverState()
+}
+
+// openStateFile returns the plain JSON of the state read from the state file or
+// from a snapshot of it, once it's verified
+func (manager *basicStateManager) openStateFile(data []byte) ([]byte, error) {
+	data, err := openState(manager.cipher, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyState(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// recoverState returns the plain JSON of the state in the newest good snapshot.
+// If there's none, the state file is quarantined and no state is returned.
+func (manager *basicStateManager) recoverState() ([]byte, error) {
+	for i := 1; i <= manager.backupCount; i++ {
+		path := manager.snapshotPath(i)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				seelog.Warnf("Unable to read the state snapshot %s: %v", path, err)
+			}
+			continue
+		}
+		data, err = manager.openStateFile(data)
+		if err != nil {
+			seelog.Warnf("Unable to recover the state from the snapshot %s: %v", path, err)
+			continue
+		}
+		seelog.Criticalf("Recovered the state from the snapshot %s; changes to the state saved after it are lost", path)
+		metrics.MetricsEngineGlobal.RecordCorruptedState(corruptedStateRecovered)
+		return data, nil
+	}
+
+	path, err := manager.quarantineFile()
+	if err != nil {
+		seelog.Criticalf("Unable to quarantine the corrupted state file: %v", err)
+		return nil, err
+	}
+	seelog.Criticalf("No good snapshot of the state to recover it from; the corrupted state file was moved to %s, and the agent is starting with an empty state", path)
+	metrics.MetricsEngineGlobal.RecordCorruptedState(corruptedStateQuarantined)
+	return nil, nil
+}
+
+// writeSnapshot keeps the data just saved to the state file as the newest
+// snapshot of it, and drops the oldest one
+func (manager *basicStateManager) writeSnapshot(data []byte) error {
+	if manager.backupCount <= 0 {
+		return nil
+	}
+	for i := manager.backupCount - 1; i >= 1; i-- {
+		err := os.Rename(manager.snapshotPath(i), manager.snapshotPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	tmpfile, err := ioutil.TempFile(manager.statePath, "tmp_ecs_agent_data")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	if _, err := tmpfile.Write(data); err != nil {
+		return err
+	}
+	if err := tmpfile.Sync(); err != nil {
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile.Name(), manager.snapshotPath(1))
+}
+
+// snapshotPath returns the path of the ith newest snapshot of the state file
+func (manager *basicStateManager) snapshotPath(i int) string {
+	return filepath.Join(manager.statePath, ecsDataFile+"."+strconv.Itoa(i))
+}
+
+// quarantinePath returns the path a corrupted state file is moved to
+func quarantinePath(path string) string {
+	return path + quarantineInfix + time.Now().UTC().Format(quarantineTimeFormat)
+}
@@ -0,0 +1,273 @@
+// +build !windows,unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newIntegrityTestConfig(t *testing.T, backupCount int) (*config.Config, func()) {
+	tmpDir, err := ioutil.TempDir("", "ecs_statemanager_test")
+	require.NoError(t, err)
+	return &config.Config{DataDir: tmpDir, StateBackupCount: backupCount}, func() { os.RemoveAll(tmpDir) }
+}
+
+// saveCluster saves the cluster as the state, and returns the saved state file
+func saveCluster(t *testing.T, cfg *config.Config, cluster string) []byte {
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.ForceSave())
+	data, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, "ecs_agent_data.json"))
+	require.NoError(t, err)
+	return data
+}
+
+func loadCluster(t *testing.T, cfg *config.Config) (string, error) {
+	var cluster string
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	err = manager.Load()
+	return cluster, err
+}
+
+func writeStateFile(t *testing.T, cfg *config.Config, name string, data []byte) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cfg.DataDir, name), data, 0600))
+}
+
+func TestStateFileChecksum(t *testing.T) {
+	cfg, cleanup := newIntegrityTestConfig(t, 2)
+	defer cleanup()
+
+	data := saveCluster(t, cfg, "first")
+	var saved struct {
+		Checksum string
+	}
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Contains(t, saved.Checksum, "sha256:")
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", cluster)
+
+	// The state file is still valid JSON, but no longer matches its checksum
+	data = saveCluster(t, cfg, "second")
+	writeStateFile(t, cfg, "ecs_agent_data.json", bytes.Replace(data, []byte("second"), []byte("secont"), 1))
+	cluster, err = loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "second", cluster, "The state should be recovered from the newest snapshot")
+}
+
+func TestStateFileSnapshots(t *testing.T) {
+	cfg, cleanup := newIntegrityTestConfig(t, 2)
+	defer cleanup()
+
+	saveCluster(t, cfg, "first")
+	saveCluster(t, cfg, "second")
+	saveCluster(t, cfg, "third")
+	snapshot1, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, "ecs_agent_data.json.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(snapshot1), "third")
+	snapshot2, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, "ecs_agent_data.json.2"))
+	require.NoError(t, err)
+	assert.Contains(t, string(snapshot2), "second")
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.json.3"))
+	assert.True(t, os.IsNotExist(err), "Only the configured number of snapshots should be kept")
+	assertFileMode(t, filepath.Join(cfg.DataDir, "ecs_agent_data.json.1"))
+
+	// Corrupted snapshots are skipped
+	data := saveCluster(t, cfg, "fourth")
+	writeStateFile(t, cfg, "ecs_agent_data.json", data[:len(data)/2])
+	writeStateFile(t, cfg, "ecs_agent_data.json.1", data[:len(data)/2])
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "third", cluster)
+}
+
+func TestStateFileQuarantined(t *testing.T) {
+	cfg, cleanup := newIntegrityTestConfig(t, 1)
+	defer cleanup()
+
+	data := saveCluster(t, cfg, "first")
+	corrupted := data[:len(data)/2]
+	writeStateFile(t, cfg, "ecs_agent_data.json", corrupted)
+	writeStateFile(t, cfg, "ecs_agent_data.json.1", corrupted)
+
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Empty(t, cluster, "The agent should start with an empty state")
+
+	quarantined, err := filepath.Glob(filepath.Join(cfg.DataDir, "ecs_agent_data.json.corrupted-*"))
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	saveCluster(t, cfg, "second")
+	quarantinedData, err := ioutil.ReadFile(quarantined[0])
+	require.NoError(t, err)
+	assert.Equal(t, corrupted, quarantinedData, "The corrupted state file should not be overwritten")
+}
+
+func TestEncryptedStateFileRecovered(t *testing.T) {
+	cfg, cleanup := newIntegrityTestConfig(t, 1)
+	defer cleanup()
+	cfg.StateEncryptionKey = config.NewSensitiveRawMessage([]byte(testStateEncryptionKey(1)))
+
+	data := saveCluster(t, cfg, "first")
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	writeStateFile(t, cfg, "ecs_agent_data.json", tampered)
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", cluster)
+
+	// State encrypted with another key isn't corrupted, and is left as is
+	otherCfg := *cfg
+	otherCfg.StateEncryptionKey = config.NewSensitiveRawMessage([]byte(testStateEncryptionKey(2)))
+	_, err = loadCluster(t, &otherCfg)
+	assert.Error(t, err)
+	quarantined, err := filepath.Glob(filepath.Join(cfg.DataDir, "ecs_agent_data.json.corrupted-*"))
+	require.NoError(t, err)
+	assert.Empty(t, quarantined)
+}
+
+func newStoreIntegrityTestConfig(t *testing.T, backupCount int) (*config.Config, func()) {
+	cfg, cleanup := newIntegrityTestConfig(t, backupCount)
+	cfg.StateStore = config.StateStoreBoltDB
+	return cfg, cleanup
+}
+
+// saveStoreCluster saves the cluster as the state in the key-value store
+func saveStoreCluster(t *testing.T, cfg *config.Config, cluster string) {
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.ForceSave())
+}
+
+func quarantinedStores(t *testing.T, cfg *config.Config) []string {
+	quarantined, err := filepath.Glob(filepath.Join(cfg.DataDir, "ecs_agent_data.db.corrupted-*"))
+	require.NoError(t, err)
+	return quarantined
+}
+
+var corruptedStore = bytes.Repeat([]byte{0xab}, 8192)
+
+func TestStoreSnapshots(t *testing.T) {
+	cfg, cleanup := newStoreIntegrityTestConfig(t, 2)
+	defer cleanup()
+
+	saveStoreCluster(t, cfg, "first")
+	saveStoreCluster(t, cfg, "second")
+	saveStoreCluster(t, cfg, "third")
+	for _, name := range []string{"ecs_agent_data.db.1", "ecs_agent_data.db.2"} {
+		assertFileMode(t, filepath.Join(cfg.DataDir, name))
+	}
+	_, err := os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.db.3"))
+	assert.True(t, os.IsNotExist(err), "Only the configured number of snapshots should be kept")
+
+	writeStateFile(t, cfg, "ecs_agent_data.db", corruptedStore)
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "third", cluster, "The state should be recovered from the newest snapshot")
+	quarantined := quarantinedStores(t, cfg)
+	require.Len(t, quarantined, 1)
+	quarantinedData, err := ioutil.ReadFile(quarantined[0])
+	require.NoError(t, err)
+	assert.Equal(t, corruptedStore, quarantinedData)
+
+	// The store is replaced by the snapshot
+	cluster, err = loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "third", cluster)
+	assert.Len(t, quarantinedStores(t, cfg), 1)
+}
+
+func TestStoreSnapshotInterval(t *testing.T) {
+	cfg, cleanup := newStoreIntegrityTestConfig(t, 2)
+	defer cleanup()
+
+	cluster := "first"
+	manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.ForceSave())
+	cluster = "second"
+	require.NoError(t, manager.ForceSave())
+
+	// Only the first save is kept as a snapshot, as the next one comes before
+	// the snapshot interval is over
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.db.2"))
+	assert.True(t, os.IsNotExist(err))
+	db, err := bolt.Open(filepath.Join(cfg.DataDir, "ecs_agent_data.db.1"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, `"first"`, string(tx.Bucket([]byte("saveables")).Get([]byte("Cluster"))))
+		return nil
+	}))
+}
+
+func TestStoreCorruptedValueRecovered(t *testing.T) {
+	cfg, cleanup := newStoreIntegrityTestConfig(t, 1)
+	defer cleanup()
+
+	saveStoreCluster(t, cfg, "first")
+	db, err := bolt.Open(filepath.Join(cfg.DataDir, "ecs_agent_data.db"), 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("saveables")).Put([]byte("Cluster"), []byte(`"fir`))
+	}))
+	require.NoError(t, db.Close())
+
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", cluster)
+	assert.Len(t, quarantinedStores(t, cfg), 1)
+}
+
+func TestStoreQuarantined(t *testing.T) {
+	cfg, cleanup := newStoreIntegrityTestConfig(t, 1)
+	defer cleanup()
+
+	saveStoreCluster(t, cfg, "first")
+	writeStateFile(t, cfg, "ecs_agent_data.db", corruptedStore)
+	writeStateFile(t, cfg, "ecs_agent_data.db.1", corruptedStore)
+
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Empty(t, cluster, "The agent should start with an empty state")
+	assert.Len(t, quarantinedStores(t, cfg), 1)
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.db"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStoreCorruptedOnSave(t *testing.T) {
+	cfg, cleanup := newStoreIntegrityTestConfig(t, 0)
+	defer cleanup()
+
+	writeStateFile(t, cfg, "ecs_agent_data.db", corruptedStore)
+	saveStoreCluster(t, cfg, "first")
+	assert.Len(t, quarantinedStores(t, cfg), 1)
+	cluster, err := loadCluster(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", cluster, "The state should be saved to a new store")
+}
@@ -0,0 +1,200 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+The key-value store is checked when it's loaded: bolt verifies the checksum of
+its meta pages when it's opened, every page of it is checked for consistency,
+and every value in it must decrypt to whole JSON. After a save that changed the
+store, at most every storeSnapshotInterval, a copy of the store is kept next to
+it as a snapshot:
+
+	ecs_agent_data.db.1 (the newest) ... ecs_agent_data.db.<StateBackupCount>
+
+If the store turns out to be corrupted when it's loaded, it's quarantined and
+replaced by the newest good snapshot. If there's none, the agent starts with an
+empty state. If the store turns out to be corrupted when it's opened to be
+saved, it's quarantined and the state is saved whole to a new store.
+*/
+
+// storeSnapshotInterval specifies how frequently to keep a snapshot of the
+// key-value store. Each snapshot is a copy of the whole store file, synced to
+// disk, which costs as much as writing the whole JSON state file and more, since
+// bolt never shrinks the file. That's what saving each entity on its own avoids,
+// so snapshots are kept much less often than the store is saved, and the store
+// is recovered from a snapshot that may be this old.
+const storeSnapshotInterval = 5 * time.Minute
+
+// openStoreFile opens the key-value store at path. The errors bolt returns or
+// panics with when the store is corrupted are returned as corruptStateErrors.
+func openStoreFile(path string, readOnly bool) (db *bolt.DB, err error) {
+	defer recoverStoreCorruption(&err)
+	db, err = bolt.Open(path, 0600, &bolt.Options{Timeout: storeOpenTimeout, ReadOnly: readOnly})
+	switch err {
+	case bolt.ErrInvalid, bolt.ErrChecksum, bolt.ErrVersionMismatch:
+		return nil, &corruptStateError{reason: err.Error()}
+	}
+	return db, err
+}
+
+// recoverStoreCorruption turns the panics of bolt on corrupted pages into a
+// corruptStateError
+func recoverStoreCorruption(err *error) {
+	if r := recover(); r != nil {
+		*err = &corruptStateError{reason: fmt.Sprint(r)}
+	}
+}
+
+// checkStore returns an error if any page of the store is inconsistent
+func checkStore(tx *bolt.Tx) error {
+	var err error
+	// Every error has to be received for the check to finish
+	for checkErr := range tx.Check() {
+		if err == nil {
+			err = &corruptStateError{reason: checkErr.Error()}
+		}
+	}
+	return err
+}
+
+// recoverStore quarantines the corrupted key-value store, and replaces it with
+// the newest good snapshot of it. The state in the snapshot is returned, or nil
+// if there's no good snapshot.
+func (manager *kvStateManager) recoverStore() (*SavedData, error) {
+	path, err := manager.quarantineStore()
+	if err != nil {
+		seelog.Criticalf("Unable to quarantine the corrupted state store: %v", err)
+		return nil, err
+	}
+	for i := 1; i <= manager.backupCount; i++ {
+		snapshotPath := manager.storeSnapshotPath(i)
+		saved, err := manager.readStoreFile(snapshotPath)
+		if err != nil {
+			seelog.Warnf("Unable to recover the state from the snapshot %s: %v", snapshotPath, err)
+			continue
+		}
+		if saved == nil {
+			continue
+		}
+		if err := manager.restoreStoreSnapshot(snapshotPath); err != nil {
+			return nil, err
+		}
+		seelog.Criticalf("Recovered the state from the snapshot %s; the corrupted state store was moved to %s, and changes to the state saved after the snapshot are lost",
+			snapshotPath, path)
+		metrics.MetricsEngineGlobal.RecordCorruptedState(corruptedStateRecovered)
+		return saved, nil
+	}
+	seelog.Criticalf("No good snapshot of the state store to recover it from; the corrupted state store was moved to %s, and the agent is starting with an empty state", path)
+	metrics.MetricsEngineGlobal.RecordCorruptedState(corruptedStateQuarantined)
+	return nil, nil
+}
+
+// reopenCorruptStore quarantines the key-value store that's corrupted, and
+// opens a new one for the whole state to be saved to
+func (manager *kvStateManager) reopenCorruptStore(openErr error) (*bolt.DB, error) {
+	path, err := manager.quarantineStore()
+	if err != nil {
+		seelog.Criticalf("Unable to quarantine the corrupted state store: %v", err)
+		return nil, openErr
+	}
+	seelog.Criticalf("Unable to open the state store, saving the state to a new one; the corrupted state store was moved to %s: %v", path, openErr)
+	metrics.MetricsEngineGlobal.RecordCorruptedState(corruptedStateQuarantined)
+	manager.lastSaved = nil
+	return manager.openStore()
+}
+
+// quarantineStore moves the key-value store aside so that it isn't
+// overwritten, and returns where it was moved to
+func (manager *kvStateManager) quarantineStore() (string, error) {
+	path := quarantinePath(manager.storePath())
+	return path, os.Rename(manager.storePath(), path)
+}
+
+// writeStoreSnapshot keeps a copy of the key-value store open in db as the
+// newest snapshot of it, and drops the oldest one
+func (manager *kvStateManager) writeStoreSnapshot(db *bolt.DB) error {
+	if manager.backupCount <= 0 {
+		return nil
+	}
+	for i := manager.backupCount - 1; i >= 1; i-- {
+		err := os.Rename(manager.storeSnapshotPath(i), manager.storeSnapshotPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return manager.copyStore(db, manager.storeSnapshotPath(1))
+}
+
+// restoreStoreSnapshot copies the snapshot at path to the key-value store
+func (manager *kvStateManager) restoreStoreSnapshot(path string) error {
+	db, err := openStoreFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return manager.copyStore(db, manager.storePath())
+}
+
+// copyStore writes a copy of the key-value store open in db to path
+func (manager *kvStateManager) copyStore(db *bolt.DB, path string) error {
+	tmpfile, err := ioutil.TempFile(manager.statePath, "tmp_ecs_agent_data")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(tmpfile)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tmpfile.Sync(); err != nil {
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile.Name(), path)
+}
+
+// removeStoreSnapshots removes the snapshots of the key-value store
+func (manager *kvStateManager) removeStoreSnapshots() error {
+	for i := 1; i <= manager.backupCount; i++ {
+		if err := os.Remove(manager.storeSnapshotPath(i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeSnapshotPath returns the path of the ith newest snapshot of the
+// key-value store
+func (manager *kvStateManager) storeSnapshotPath(i int) string {
+	return filepath.Join(manager.statePath, ecsDataStore+"."+strconv.Itoa(i))
+}
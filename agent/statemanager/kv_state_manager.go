@@ -0,0 +1,611 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// ecsDataStore specifies the filename of the key-value store in the
+	// ECS_DATADIR
+	ecsDataStore = "ecs_agent_data.db"
+
+	// migratedStateFileSuffix is appended to the name of the JSON state file
+	// once its state is moved to the key-value store
+	migratedStateFileSuffix = ".migrated"
+
+	// minStoreSaveInterval specifies how frequently to write to the key-value
+	// store. Only what changed since the last save is written to it, so it's
+	// written to much more often than the JSON state file
+	minStoreSaveInterval = time.Second
+
+	// storeOpenTimeout specifies how long to wait for the lock that's held on
+	// the key-value store while it's open
+	storeOpenTimeout = 10 * time.Second
+
+	// compactedStoreSuffix is appended to the name of the key-value store for
+	// the new file it's compacted into
+	compactedStoreSuffix = ".compacted"
+)
+
+var (
+	// metadataBucket holds the version of the saved data
+	metadataBucket = []byte("metadata")
+	versionKey     = []byte("version")
+	// keyIDKey holds the ID of the encryption key the values in the store are
+	// encrypted with. It's only recorded once the store is compacted after
+	// they're encrypted with the key.
+	keyIDKey = []byte("keyID")
+	// saveablesBucket holds the JSON of each saveable that isn't an
+	// EntitySaveable, by name
+	saveablesBucket = []byte("saveables")
+	// entitiesBucket holds a bucket for each EntitySaveable by name, which
+	// holds a bucket of entities for each kind of them
+	entitiesBucket = []byte("entities")
+)
+
+// EntitySaveable is a Saveable made up of entities, such as tasks and
+// containers. The key-value store saves each entity under its own key, so that
+// only the entities that changed since the last save are written.
+type EntitySaveable interface {
+	// MarshalEntities returns the JSON of each entity by kind and by key
+	MarshalEntities() (map[string]map[string]json.RawMessage, error)
+	// UnmarshalEntities restores the saveable from the entities returned by
+	// MarshalEntities
+	UnmarshalEntities(entities map[string]map[string]json.RawMessage) error
+}
+
+// kvStateManager is a StateManager that saves data in an embedded key-value
+// store. The store is only open while it's being read or written, so that the
+// state managers created one after another by the agent can all use it.
+type kvStateManager struct {
+	*basicStateManager
+	// lastSaved holds the values the last save wrote or found already saved,
+	// by their path in the store, so that the values that didn't change since
+	// aren't decrypted to be compared. It's guarded by savingLock.
+	lastSaved map[string]savedValue
+	// lastSnapshot is when the last snapshot of the store was kept. It's
+	// guarded by savingLock.
+	lastSnapshot time.Time
+	// changedSinceSnapshot is whether values were written to or deleted from
+	// the store since the last snapshot of it was kept. It's guarded by
+	// savingLock.
+	changedSinceSnapshot bool
+}
+
+// savedValue is the JSON of a saveable or an entity and the value it's saved as
+type savedValue struct {
+	data   []byte
+	sealed []byte
+}
+
+// Save triggers a save to the key-value store, though respects a minimum save
+// interval to wait between saves.
+func (manager *kvStateManager) Save() error {
+	defer metrics.MetricsEngineGlobal.RecordStateManagerMetric("SAVE")()
+	return manager.save(minStoreSaveInterval, manager.ForceSave)
+}
+
+// ForceSave writes the saveables to the key-value store in a single
+// transaction. Entities that haven't changed since the last save aren't
+// rewritten, and the ones that are gone are deleted. The first time the store
+// is saved with an encryption key, it's compacted afterwards, and otherwise a
+// snapshot of it is kept if it changed since the last one and the last one is
+// older than storeSnapshotInterval.
+func (manager *kvStateManager) ForceSave() error {
+	manager.savingLock.Lock()
+	defer manager.savingLock.Unlock()
+	seelog.Debug("Saving state!")
+
+	saved := make(map[string]savedValue)
+	compact := false
+	db, err := manager.openStore()
+	if isCorruptState(err) {
+		db, err = manager.reopenCorruptStore(err)
+	}
+	if err != nil {
+		seelog.Errorf("Error saving state; could not open the state store, err: %v", err)
+		return err
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		metadata, err := tx.CreateBucketIfNotExists(metadataBucket)
+		if err != nil {
+			return err
+		}
+		if err := putIfChanged(metadata, versionKey, []byte(strconv.Itoa(ECSDataVersion))); err != nil {
+			return err
+		}
+		if manager.cipher != nil {
+			compact = !bytes.Equal(metadata.Get(keyIDKey), manager.cipher.keyID)
+		} else if err := metadata.Delete(keyIDKey); err != nil {
+			return err
+		}
+		saveables, err := tx.CreateBucketIfNotExists(saveablesBucket)
+		if err != nil {
+			return err
+		}
+		entities, err := tx.CreateBucketIfNotExists(entitiesBucket)
+		if err != nil {
+			return err
+		}
+
+		for name, saveable := range manager.state.Data {
+			if entitySaveable, ok := asEntitySaveable(*saveable); ok {
+				marshaled, err := entitySaveable.MarshalEntities()
+				if err != nil {
+					return errors.Wrapf(err, "could not marshal %s", name)
+				}
+				bucket, err := entities.CreateBucketIfNotExists([]byte(name))
+				if err != nil {
+					return err
+				}
+				if err := manager.putEntities(bucket, name, marshaled, saved); err != nil {
+					return errors.Wrapf(err, "could not save %s", name)
+				}
+				continue
+			}
+			data, err := json.Marshal(saveable)
+			if err != nil {
+				return errors.Wrapf(err, "could not marshal %s", name)
+			}
+			path := valuePath(saveablesBucket, []byte(name))
+			if err := manager.putSaved(saveables, []byte(name), data, path, saved); err != nil {
+				return errors.Wrapf(err, "could not save %s", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		seelog.Errorf("Error saving state to the state store, err: %v", err)
+		manager.lastSaved = nil
+		return err
+	}
+	manager.lastSaved = saved
+	if compact {
+		seelog.Infof("Compacting %s now that it's encrypted", ecsDataStore)
+		if err := manager.compactStore(db, manager.cipher.keyID); err != nil {
+			seelog.Warnf("Unable to compact the state store, values saved before it was encrypted may remain in it: %v", err)
+			return nil
+		}
+		// The snapshots hold the values saved before the store was encrypted
+		if err := manager.removeStoreSnapshots(); err != nil {
+			seelog.Warnf("Unable to remove the snapshots of the state store saved before it was encrypted: %v", err)
+		}
+		if err := manager.removeQuarantinedStores(); err != nil {
+			seelog.Warnf("Unable to remove the state stores quarantined before the store was encrypted: %v", err)
+		}
+		manager.lastSnapshot = time.Time{}
+		return nil
+	}
+	if manager.changedSinceSnapshot && time.Since(manager.lastSnapshot) >= storeSnapshotInterval {
+		if err := manager.writeStoreSnapshot(db); err != nil {
+			seelog.Warnf("Unable to keep a snapshot of the state store: %v", err)
+			return nil
+		}
+		manager.lastSnapshot = time.Now()
+		manager.changedSinceSnapshot = false
+	}
+	return nil
+}
+
+// putEntities writes the entities that changed to the bucket of their kind,
+// and deletes the entities that are gone
+func (manager *kvStateManager) putEntities(bucket *bolt.Bucket, name string,
+	entities map[string]map[string]json.RawMessage, saved map[string]savedValue) error {
+	for kind, byKey := range entities {
+		kindBucket, err := bucket.CreateBucketIfNotExists([]byte(kind))
+		if err != nil {
+			return err
+		}
+		for key, data := range byKey {
+			if key == "" {
+				seelog.Warnf("Not saving %s entity without a key: %s", kind, string(data))
+				continue
+			}
+			path := valuePath(entitiesBucket, []byte(name), []byte(kind), []byte(key))
+			if err := manager.putSaved(kindBucket, []byte(key), data, path, saved); err != nil {
+				return err
+			}
+		}
+
+		// Keys can't be deleted while the bucket is iterated over
+		var removed [][]byte
+		kindBucket.ForEach(func(key, _ []byte) error {
+			if _, ok := byKey[string(key)]; !ok {
+				removed = append(removed, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		for _, key := range removed {
+			if err := kindBucket.Delete(key); err != nil {
+				return err
+			}
+			manager.changedSinceSnapshot = true
+		}
+	}
+	return nil
+}
+
+func putIfChanged(bucket *bolt.Bucket, key []byte, value []byte) error {
+	if bytes.Equal(bucket.Get(key), value) {
+		return nil
+	}
+	return bucket.Put(key, value)
+}
+
+// putSaved writes the JSON of a saveable or an entity, encrypted if an
+// encryption key is configured, unless the same JSON is already saved the same
+// way. Values are compared decrypted since each encryption of them differs,
+// unless the last save left the value as it is. path is the path of the value
+// in the store, which the value is recorded in saved by.
+func (manager *kvStateManager) putSaved(bucket *bolt.Bucket, key []byte, data []byte,
+	path []byte, saved map[string]savedValue) error {
+	existing := bucket.Get(key)
+	if last, ok := manager.lastSaved[string(path)]; ok && bytes.Equal(last.data, data) && bytes.Equal(last.sealed, existing) {
+		saved[string(path)] = last
+		return nil
+	}
+	if existing != nil && manager.savedWithCurrentKey(existing) {
+		if opened, err := openState(manager.cipher, existing, path); err == nil && bytes.Equal(opened, data) {
+			// The existing value is only valid while the transaction is open
+			saved[string(path)] = savedValue{data: data, sealed: append([]byte(nil), existing...)}
+			return nil
+		}
+	}
+	sealed, err := sealState(manager.cipher, data, path)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put(key, sealed); err != nil {
+		return err
+	}
+	manager.changedSinceSnapshot = true
+	saved[string(path)] = savedValue{data: data, sealed: sealed}
+	return nil
+}
+
+// savedWithCurrentKey returns whether the value is encrypted with the
+// configured key, or is plain if there's no key configured
+func (manager *kvStateManager) savedWithCurrentKey(value []byte) bool {
+	if manager.cipher == nil {
+		return !isEnvelope(value)
+	}
+	return manager.cipher.sealedWith(value)
+}
+
+// Load reads the state from the key-value store into the saveables. If nothing
+// was ever saved in the store, the state is moved over from the JSON state file
+// earlier versions of the agent saved it in.
+func (manager *kvStateManager) Load() error {
+	seelog.Info("Loading state!")
+	loaded, err := manager.loadStore()
+	if err != nil {
+		seelog.Errorf("Error loading state from the state store, err: %v", err)
+		return err
+	}
+	if !loaded {
+		if err := manager.migrateStateFile(); err != nil {
+			return err
+		}
+	}
+	manager.encryptLeftoverFiles()
+	return nil
+}
+
+// loadStore reads the state from the key-value store, and returns whether any
+// state had been saved in it. The state is recovered from a snapshot if the
+// store is corrupted.
+func (manager *kvStateManager) loadStore() (bool, error) {
+	saved, err := manager.readStore()
+	if isCorruptState(err) {
+		seelog.Criticalf("Unable to load the state store, recovering the state from a snapshot: %v", err)
+		saved, err = manager.recoverStore()
+	}
+	if err != nil || saved == nil {
+		return false, err
+	}
+	if err := migrateSavedData(saved); err != nil {
+		return false, err
+	}
+	if err := manager.loadSaveables(saved.Saveables); err != nil {
+		return false, err
+	}
+	return true, manager.loadEntitySaveables(saved.Entities)
+}
+
+// readStore returns the plain JSON of what's saved in the key-value store, or
+// nil if nothing was ever saved in it
+func (manager *kvStateManager) readStore() (*SavedData, error) {
+	return manager.readStoreFile(manager.storePath())
+}
+
+// readStoreFile returns the plain JSON of what's saved in the key-value store
+// or the snapshot of it at path, or nil if nothing was ever saved in it. The
+// store is checked as it's read, and a corruptStateError is returned if it's
+// corrupted.
+func (manager *kvStateManager) readStoreFile(path string) (*SavedData, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			// Happens every first run; not a real error
+			return nil, nil
+		}
+		return nil, err
+	}
+	db, err := openStoreFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var saved *SavedData
+	err = db.View(func(tx *bolt.Tx) (err error) {
+		defer recoverStoreCorruption(&err)
+		if err := checkStore(tx); err != nil {
+			return err
+		}
+		metadata := tx.Bucket(metadataBucket)
+		if metadata == nil {
+			return nil
+		}
+		version, err := strconv.Atoi(string(metadata.Get(versionKey)))
+		if err != nil {
+			return &corruptStateError{reason: "could not read the version of the saved state: " + err.Error()}
+		}
+		saved = &SavedData{
+			Store:        config.StateStoreBoltDB,
+			SavedVersion: version,
+			Version:      version,
+			Saveables:    make(map[string]json.RawMessage),
+			Entities:     make(map[string]map[string]map[string]json.RawMessage),
+		}
+		if err := manager.readSaveables(tx.Bucket(saveablesBucket), saved.Saveables); err != nil {
+			return err
+		}
+		return manager.readEntities(tx.Bucket(entitiesBucket), saved.Entities)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (manager *kvStateManager) readSaveables(bucket *bolt.Bucket, saveables map[string]json.RawMessage) error {
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(name, data []byte) error {
+		data, err := openState(manager.cipher, data, valuePath(saveablesBucket, name))
+		if err != nil {
+			return errors.Wrapf(err, "could not read %s", string(name))
+		}
+		if !json.Valid(data) {
+			return &corruptStateError{reason: string(name) + " is not valid JSON"}
+		}
+		// The data is only valid while the transaction is open
+		saveables[string(name)] = append(json.RawMessage(nil), data...)
+		return nil
+	})
+}
+
+func (manager *kvStateManager) readEntities(bucket *bolt.Bucket, entities map[string]map[string]map[string]json.RawMessage) error {
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(name, _ []byte) error {
+		byKind := make(map[string]map[string]json.RawMessage)
+		entities[string(name)] = byKind
+		return bucket.Bucket(name).ForEach(func(kind, _ []byte) error {
+			byKey := make(map[string]json.RawMessage)
+			byKind[string(kind)] = byKey
+			return bucket.Bucket(name).Bucket(kind).ForEach(func(key, data []byte) error {
+				data, err := openState(manager.cipher, data, valuePath(entitiesBucket, name, kind, key))
+				if err != nil {
+					return errors.Wrapf(err, "could not read %s entity %s", string(kind), string(key))
+				}
+				if !json.Valid(data) {
+					return &corruptStateError{reason: string(kind) + " entity " + string(key) + " is not valid JSON"}
+				}
+				byKey[string(key)] = append(json.RawMessage(nil), data...)
+				return nil
+			})
+		})
+	})
+}
+
+func (manager *kvStateManager) loadSaveables(saveables map[string]json.RawMessage) error {
+	for name, data := range saveables {
+		actualPointer, ok := manager.state.Data[name]
+		if !ok {
+			seelog.Errorf("Loading state: unknown saveable %s", name)
+			continue
+		}
+		if err := json.Unmarshal(data, actualPointer); err != nil {
+			return errors.Wrapf(err, "could not unmarshal %s", name)
+		}
+	}
+	return nil
+}
+
+func (manager *kvStateManager) loadEntitySaveables(entities map[string]map[string]map[string]json.RawMessage) error {
+	for name, byKind := range entities {
+		actualPointer, ok := manager.state.Data[name]
+		if !ok {
+			seelog.Errorf("Loading state: unknown saveable %s", name)
+			continue
+		}
+		entitySaveable, ok := asEntitySaveable(*actualPointer)
+		if !ok {
+			return errors.Errorf("%s was saved as entities but can't be loaded from them", name)
+		}
+		if err := entitySaveable.UnmarshalEntities(byKind); err != nil {
+			return errors.Wrapf(err, "could not unmarshal %s", name)
+		}
+	}
+	return nil
+}
+
+// migrateStateFile moves the state saved in the JSON state file by earlier
+// versions of the agent to the key-value store. The file is renamed once the
+// state is in the store rather than removed, so that the agent can still be
+// downgraded. The state is moved back by migrateStore if the agent is switched
+// back to the JSON state file.
+func (manager *kvStateManager) migrateStateFile() error {
+	data, err := manager.readState()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	seelog.Infof("Moving the state from %s to %s", ecsDataFile, ecsDataStore)
+	if err := manager.loadData(data); err != nil {
+		return err
+	}
+	if err := manager.ForceSave(); err != nil {
+		return err
+	}
+	if err := manager.moveFileAside(migratedStateFileSuffix); err != nil {
+		seelog.Warnf("Unable to rename the state file once its state was moved to %s: %v", ecsDataStore, err)
+	}
+	return nil
+}
+
+// migrateStore moves the state saved in the key-value store back to the JSON
+// state file, when the agent is switched back to it. Only agents that use the
+// store read it, so the store and its snapshots are removed once the state is
+// in the JSON state file, as is the state file that was moved aside when the
+// state was moved to the store.
+func (manager *basicStateManager) migrateStore() error {
+	kvManager := &kvStateManager{basicStateManager: manager}
+	loaded, err := kvManager.loadStore()
+	if err != nil || !loaded {
+		return err
+	}
+	seelog.Infof("Moving the state from %s to %s", ecsDataStore, ecsDataFile)
+	if err := manager.ForceSave(); err != nil {
+		return err
+	}
+	movedPaths := []string{
+		kvManager.storePath(),
+		filepath.Join(manager.statePath, ecsDataFile+migratedStateFileSuffix),
+	}
+	for _, path := range movedPaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			seelog.Warnf("Unable to remove %s once its state was moved to %s: %v", path, ecsDataFile, err)
+		}
+	}
+	if err := kvManager.removeStoreSnapshots(); err != nil {
+		seelog.Warnf("Unable to remove the snapshots of %s once its state was moved to %s: %v", ecsDataStore, ecsDataFile, err)
+	}
+	return nil
+}
+
+func (manager *kvStateManager) storePath() string {
+	return filepath.Join(manager.statePath, ecsDataStore)
+}
+
+func (manager *kvStateManager) openStore() (*bolt.DB, error) {
+	return openStoreFile(manager.storePath(), false)
+}
+
+// compactStore copies the key-value store open in db into a new file that
+// replaces it, and records in it the ID of the key its values are encrypted
+// with. Bolt keeps the values it overwrites in its free pages until they're
+// reused, so this is what rids the store of the values that were saved in
+// plain text or with another key. db is closed before it's replaced.
+func (manager *kvStateManager) compactStore(db *bolt.DB, keyID []byte) error {
+	compactedPath := manager.storePath() + compactedStoreSuffix
+	if err := os.Remove(compactedPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	compacted, err := bolt.Open(compactedPath, 0600, &bolt.Options{Timeout: storeOpenTimeout})
+	if err != nil {
+		return err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		return compacted.Update(func(compactedTx *bolt.Tx) error {
+			err := tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+				compactedBucket, err := compactedTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(bucket, compactedBucket)
+			})
+			if err != nil {
+				return err
+			}
+			metadata, err := compactedTx.CreateBucketIfNotExists(metadataBucket)
+			if err != nil {
+				return err
+			}
+			return metadata.Put(keyIDKey, keyID)
+		})
+	})
+	if closeErr := compacted.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = db.Close()
+	}
+	if err != nil {
+		os.Remove(compactedPath)
+		return err
+	}
+	return os.Rename(compactedPath, manager.storePath())
+}
+
+// copyBucket copies the values in a bucket, and the buckets nested in it, to
+// another bucket
+func copyBucket(from *bolt.Bucket, to *bolt.Bucket) error {
+	return from.ForEach(func(key, value []byte) error {
+		if value != nil {
+			return to.Put(key, value)
+		}
+		nested, err := to.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		return copyBucket(from.Bucket(key), nested)
+	})
+}
+
+// asEntitySaveable returns the saveable as an EntitySaveable if it is one.
+// Saveables may be pointers to the interface values that implement it.
+func asEntitySaveable(saveable Saveable) (EntitySaveable, bool) {
+	for {
+		if entitySaveable, ok := saveable.(EntitySaveable); ok {
+			return entitySaveable, true
+		}
+		value := reflect.ValueOf(saveable)
+		if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Interface {
+			return nil, false
+		}
+		saveable = value.Elem().Interface()
+	}
+}
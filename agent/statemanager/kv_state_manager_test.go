@@ -0,0 +1,368 @@
+// +build !windows,unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	engine_testutils "github.com/aws/amazon-ecs-agent/agent/engine/testutils"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newKVTestConfig(t *testing.T) (*config.Config, func()) {
+	tmpDir, err := ioutil.TempDir("", "ecs_statemanager_test")
+	require.NoError(t, err)
+	return &config.Config{DataDir: tmpDir, StateStore: config.StateStoreBoltDB}, func() { os.RemoveAll(tmpDir) }
+}
+
+func TestKVStateManager(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+
+	manager, err := statemanager.NewStateManager(cfg)
+	require.NoError(t, err)
+	assert.NoError(t, manager.Load(), "Expected loading a non-existent store to not be an error")
+
+	containerInstanceArn := "containerInstanceArn"
+	taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	manager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", taskEngine),
+		statemanager.AddSaveable("ContainerInstanceArn", &containerInstanceArn))
+	require.NoError(t, err)
+
+	state := taskEngine.(*engine.DockerTaskEngine).State()
+	testTask := &apitask.Task{
+		Arn:        "test-arn",
+		Containers: []*apicontainer.Container{{Name: "web"}},
+	}
+	state.AddTask(testTask)
+	state.AddContainer(&apicontainer.DockerContainer{
+		DockerID:   "dockerid",
+		DockerName: "dockername",
+		Container:  testTask.Containers[0],
+	}, testTask)
+	state.AddTask(&apitask.Task{Arn: "other-arn"})
+	require.NoError(t, manager.ForceSave())
+
+	assertFileMode(t, filepath.Join(cfg.DataDir, "ecs_agent_data.db"))
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.json"))
+	assert.True(t, os.IsNotExist(err), "The state file should not be written")
+
+	loadedTaskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	var loadedContainerInstanceArn string
+	loadedManager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", &loadedTaskEngine),
+		statemanager.AddSaveable("ContainerInstanceArn", &loadedContainerInstanceArn))
+	require.NoError(t, err)
+	require.NoError(t, loadedManager.Load())
+	assert.Equal(t, containerInstanceArn, loadedContainerInstanceArn)
+	assert.True(t, engine_testutils.DockerTaskEnginesEqual(loadedTaskEngine.(*engine.DockerTaskEngine),
+		taskEngine.(*engine.DockerTaskEngine)), "Did not load taskEngine correctly")
+	dockerContainer, ok := loadedTaskEngine.(*engine.DockerTaskEngine).State().ContainerByID("dockerid")
+	require.True(t, ok)
+	assert.Equal(t, "web", dockerContainer.Container.Name)
+
+	// Tasks that are gone from the state are deleted from the store
+	otherTask, ok := state.TaskByArn("other-arn")
+	require.True(t, ok)
+	state.RemoveTask(otherTask)
+	require.NoError(t, manager.ForceSave())
+
+	loadedTaskEngine = engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+		nil, nil)
+	loadedManager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", loadedTaskEngine),
+		statemanager.AddSaveable("ContainerInstanceArn", &loadedContainerInstanceArn))
+	require.NoError(t, err)
+	require.NoError(t, loadedManager.Load())
+	tasks, err := loadedTaskEngine.ListTasks()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "test-arn", tasks[0].Arn)
+}
+
+func TestKVStateManagerMovesStateFile(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+	data, err := ioutil.ReadFile(filepath.Join(".", "testdata", "v23", "perContainerRuntimeID", "ecs_agent_data.json"))
+	require.NoError(t, err)
+	stateFile := filepath.Join(cfg.DataDir, "ecs_agent_data.json")
+	require.NoError(t, ioutil.WriteFile(stateFile, data, 0600))
+
+	for _, source := range []string{"state file", "store"} {
+		t.Run(source, func(t *testing.T) {
+			taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(), nil, nil)
+			var containerInstanceArn, cluster, savedInstanceID string
+			stateManager, err := statemanager.NewStateManager(cfg,
+				statemanager.AddSaveable("TaskEngine", taskEngine),
+				statemanager.AddSaveable("ContainerInstanceArn", &containerInstanceArn),
+				statemanager.AddSaveable("Cluster", &cluster),
+				statemanager.AddSaveable("EC2InstanceID", &savedInstanceID),
+			)
+			require.NoError(t, err)
+			require.NoError(t, stateManager.Load())
+
+			assert.Equal(t, "state-file", cluster)
+			tasks, err := taskEngine.ListTasks()
+			require.NoError(t, err)
+			require.Len(t, tasks, 1)
+			assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:task/70947c96-f64e-483a-a612-3fd4303546e7", tasks[0].Arn)
+			require.Len(t, tasks[0].Containers, 1)
+			assert.Equal(t, "c00bc15085ef16b4c6b259f7dfc198a0a36b1ea1004c6de778bdc4b6629a7f90", tasks[0].Containers[0].RuntimeID)
+
+			_, err = os.Stat(stateFile)
+			assert.True(t, os.IsNotExist(err), "The state file should be moved aside once moved to the store")
+			migrated, err := ioutil.ReadFile(stateFile + ".migrated")
+			require.NoError(t, err)
+			assert.Equal(t, data, migrated)
+		})
+	}
+}
+
+func TestStateManagerMovesStore(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+	data, err := ioutil.ReadFile(filepath.Join(".", "testdata", "v23", "perContainerRuntimeID", "ecs_agent_data.json"))
+	require.NoError(t, err)
+	stateFile := filepath.Join(cfg.DataDir, "ecs_agent_data.json")
+	storeFile := filepath.Join(cfg.DataDir, "ecs_agent_data.db")
+	require.NoError(t, ioutil.WriteFile(stateFile, data, 0600))
+
+	// The state is moved to the store, then back to the state file once the
+	// agent is switched back to it, and to the store again
+	for _, stateStore := range []string{config.StateStoreBoltDB, config.StateStoreJSON, config.StateStoreBoltDB} {
+		cfg.StateStore = stateStore
+		taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(), nil, nil)
+		var cluster string
+		stateManager, err := statemanager.NewStateManager(cfg,
+			statemanager.AddSaveable("TaskEngine", taskEngine),
+			statemanager.AddSaveable("Cluster", &cluster),
+		)
+		require.NoError(t, err)
+		require.NoError(t, stateManager.Load())
+
+		assert.Equal(t, "state-file", cluster, stateStore)
+		tasks, err := taskEngine.ListTasks()
+		require.NoError(t, err)
+		require.Len(t, tasks, 1, stateStore)
+		assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:task/70947c96-f64e-483a-a612-3fd4303546e7", tasks[0].Arn)
+
+		if stateStore == config.StateStoreJSON {
+			_, err = os.Stat(storeFile)
+			assert.True(t, os.IsNotExist(err), "The store should be removed once moved to the state file")
+			_, err = os.Stat(stateFile + ".migrated")
+			assert.True(t, os.IsNotExist(err), "The moved state file should be removed once the state is moved back")
+			_, err = os.Stat(stateFile)
+			assert.NoError(t, err)
+		}
+	}
+
+	_, err = os.Stat(stateFile)
+	assert.True(t, os.IsNotExist(err), "The state file should be moved aside once moved to the store")
+	_, err = os.Stat(storeFile)
+	assert.NoError(t, err)
+}
+
+func TestKVStateManagerUnsupportedVersion(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+	db, err := bolt.Open(filepath.Join(cfg.DataDir, "ecs_agent_data.db"), 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		metadata, err := tx.CreateBucket([]byte("metadata"))
+		if err != nil {
+			return err
+		}
+		return metadata.Put([]byte("version"), []byte("1000"))
+	}))
+	require.NoError(t, db.Close())
+
+	var cluster string
+	stateManager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	assert.Error(t, stateManager.Load())
+}
+
+func TestKVStateManagerEncryption(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+	cfg.StateBackupCount = 1
+	newManager := func(cfg *config.Config) (statemanager.StateManager, dockerstate.TaskEngineState, *string) {
+		taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(),
+			nil, nil)
+		var containerInstanceArn string
+		manager, err := statemanager.NewStateManager(cfg, statemanager.AddSaveable("TaskEngine", taskEngine),
+			statemanager.AddSaveable("ContainerInstanceArn", &containerInstanceArn))
+		require.NoError(t, err)
+		return manager, taskEngine.(*engine.DockerTaskEngine).State(), &containerInstanceArn
+	}
+
+	// State saved before encryption is enabled is loaded and then encrypted
+	manager, state, containerInstanceArn := newManager(cfg)
+	state.AddTask(&apitask.Task{Arn: "test-arn"})
+	*containerInstanceArn = "containerInstanceArn"
+	require.NoError(t, manager.ForceSave())
+	assert.False(t, isEncrypted(t, cfg, "saveables", "ContainerInstanceArn"))
+	assert.True(t, storeContains(t, cfg, `"containerInstanceArn"`))
+	assertFileMode(t, filepath.Join(cfg.DataDir, "ecs_agent_data.db.1"))
+
+	encryptedCfg := *cfg
+	encryptedCfg.StateEncryptionKey = config.NewSensitiveRawMessage([]byte(testStateEncryptionKey(1)))
+	manager, state, containerInstanceArn = newManager(&encryptedCfg)
+	require.NoError(t, manager.Load())
+	assert.Equal(t, "containerInstanceArn", *containerInstanceArn)
+	require.NoError(t, manager.ForceSave())
+	assert.True(t, isEncrypted(t, cfg, "saveables", "ContainerInstanceArn"))
+	assert.True(t, isEncrypted(t, cfg, "entities", "TaskEngine", "Tasks", "test-arn"))
+	assert.False(t, storeContains(t, cfg, `"containerInstanceArn"`),
+		"The values saved in plain text should be gone once the store is encrypted")
+	assert.Len(t, savedStoreValue(t, cfg, "metadata", "keyID"), 8)
+	_, err := os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.db.compacted"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(cfg.DataDir, "ecs_agent_data.db.1"))
+	assert.True(t, os.IsNotExist(err), "The snapshots saved before the store was encrypted should be removed")
+
+	// Values that didn't change are left as they are, even though each
+	// encryption of them differs
+	savedTask := savedStoreValue(t, cfg, "entities", "TaskEngine", "Tasks", "test-arn")
+	savedArn := savedStoreValue(t, cfg, "saveables", "ContainerInstanceArn")
+	require.NoError(t, manager.ForceSave())
+	assert.Equal(t, savedTask, savedStoreValue(t, cfg, "entities", "TaskEngine", "Tasks", "test-arn"))
+	assert.Equal(t, savedArn, savedStoreValue(t, cfg, "saveables", "ContainerInstanceArn"))
+	*containerInstanceArn = "otherContainerInstanceArn"
+	require.NoError(t, manager.ForceSave())
+	assert.Equal(t, savedTask, savedStoreValue(t, cfg, "entities", "TaskEngine", "Tasks", "test-arn"))
+	assert.NotEqual(t, savedArn, savedStoreValue(t, cfg, "saveables", "ContainerInstanceArn"))
+	*containerInstanceArn = "containerInstanceArn"
+	require.NoError(t, manager.ForceSave())
+
+	manager, state, containerInstanceArn = newManager(&encryptedCfg)
+	require.NoError(t, manager.Load())
+	assert.Equal(t, "containerInstanceArn", *containerInstanceArn)
+	_, ok := state.TaskByArn("test-arn")
+	assert.True(t, ok)
+
+	manager, _, _ = newManager(cfg)
+	assert.Error(t, manager.Load(), "Encrypted state should not be loaded without the key")
+
+	// Rotating the key re-encrypts the state with the new key
+	keyFile := filepath.Join(cfg.DataDir, "new.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(testStateEncryptionKey(2)), 0600))
+	require.NoError(t, statemanager.RotateEncryptionKey(&encryptedCfg, keyFile))
+
+	manager, _, _ = newManager(&encryptedCfg)
+	assert.Error(t, manager.Load(), "State should not be loaded with the old key once it's rotated")
+	rotatedCfg := *cfg
+	rotatedCfg.StateEncryptionKeyFile = keyFile
+	manager, state, containerInstanceArn = newManager(&rotatedCfg)
+	require.NoError(t, manager.Load())
+	assert.Equal(t, "containerInstanceArn", *containerInstanceArn)
+	_, ok = state.TaskByArn("test-arn")
+	assert.True(t, ok)
+}
+
+func TestStateManagerEncryptsLeftoverFiles(t *testing.T) {
+	cfg, cleanup := newKVTestConfig(t)
+	defer cleanup()
+	migratedFile := filepath.Join(cfg.DataDir, "ecs_agent_data.json.migrated")
+	quarantinedFile := filepath.Join(cfg.DataDir, "ecs_agent_data.json.corrupted-20200101T000000Z")
+	quarantinedStore := filepath.Join(cfg.DataDir, "ecs_agent_data.db.corrupted-20200101T000000Z")
+	for _, path := range []string{migratedFile, quarantinedFile, quarantinedStore} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"secret":"value"}`), 0600))
+	}
+	assertEncryptedWith := func(keyID []byte) {
+		for _, path := range []string{migratedFile, quarantinedFile} {
+			data, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(data, []byte("ECSENC")), path)
+			assert.Equal(t, keyID, data[7:15], path)
+			assert.False(t, bytes.Contains(data, []byte("secret")), path)
+		}
+	}
+
+	encryptedCfg := *cfg
+	encryptedCfg.StateEncryptionKey = config.NewSensitiveRawMessage([]byte(testStateEncryptionKey(1)))
+	var cluster string
+	manager, err := statemanager.NewStateManager(&encryptedCfg, statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.Load())
+	require.NoError(t, manager.ForceSave())
+	assertEncryptedWith(savedStoreValue(t, cfg, "metadata", "keyID"))
+	_, err = os.Stat(quarantinedStore)
+	assert.True(t, os.IsNotExist(err), "The quarantined store should be removed once the store is encrypted")
+
+	// Rotating the key re-encrypts them with the new key
+	require.NoError(t, ioutil.WriteFile(quarantinedStore, []byte(`{"secret":"value"}`), 0600))
+	keyFile := filepath.Join(cfg.DataDir, "new.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(testStateEncryptionKey(2)), 0600))
+	keyID := savedStoreValue(t, cfg, "metadata", "keyID")
+	require.NoError(t, statemanager.RotateEncryptionKey(&encryptedCfg, keyFile))
+	rotatedKeyID := savedStoreValue(t, cfg, "metadata", "keyID")
+	assert.NotEqual(t, keyID, rotatedKeyID)
+	assertEncryptedWith(rotatedKeyID)
+	_, err = os.Stat(quarantinedStore)
+	assert.True(t, os.IsNotExist(err), "The quarantined store should be removed once the key is rotated")
+}
+
+func testStateEncryptionKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// isEncrypted returns whether the value at the path of buckets and key in the
+// state store is encrypted
+func isEncrypted(t *testing.T, cfg *config.Config, path ...string) bool {
+	return bytes.HasPrefix(savedStoreValue(t, cfg, path...), []byte("ECSENC"))
+}
+
+// storeContains returns whether the state store file contains the data
+func storeContains(t *testing.T, cfg *config.Config, data string) bool {
+	contents, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, "ecs_agent_data.db"))
+	require.NoError(t, err)
+	return bytes.Contains(contents, []byte(data))
+}
+
+// savedStoreValue returns the value at the path of buckets and key in the
+// state store
+func savedStoreValue(t *testing.T, cfg *config.Config, path ...string) []byte {
+	db, err := bolt.Open(filepath.Join(cfg.DataDir, "ecs_agent_data.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	var value []byte
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(path[0]))
+		for _, name := range path[1 : len(path)-1] {
+			require.NotNil(t, bucket)
+			bucket = bucket.Bucket([]byte(name))
+		}
+		require.NotNil(t, bucket)
+		value = append(value, bucket.Get([]byte(path[len(path)-1]))...)
+		return nil
+	}))
+	require.NotEmpty(t, value)
+	return value
+}
@@ -0,0 +1,401 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+/*
+Saved state is upgraded one version at a time before it's unmarshaled, by
+changing its raw JSON into what the next version of the agent would have saved.
+Every version before ECSDataVersion has a migration to the next one, but most
+versions only added fields, which are unmarshaled as their zero values when
+they're missing, and so have nothing to change.
+
+When ECSDataVersion is incremented, register a migration from the previous
+version in migrations, and if it changes anything, add golden files for it
+under testdata/migrations.
+*/
+
+const (
+	// taskEngineSaveable is the name the agent saves the task engine under
+	taskEngineSaveable = "TaskEngine"
+
+	tasksKey            = "Tasks"
+	dockerContainersKey = "IdToContainer"
+)
+
+// migration upgrades the raw JSON of the state saved at a version to the
+// next version
+type migration struct {
+	// description says what changed in the next version
+	description string
+	// migrate changes the saved data in place. It's nil if there's nothing
+	// to change.
+	migrate func(saved *SavedData) error
+}
+
+// migrations holds the migration from each version to the next one, by the
+// version it migrates from
+var migrations = map[int]migration{
+	1:  {"add 'ACSSeqNum' and stop saving the 'DEAD' and 'UNKNOWN' statuses", migrateDeadAndUnknownStatuses},
+	2:  {"add 'Protocol' to 'portMappings' and 'KnownPortBindings'", nil},
+	3:  {"add 'DockerConfig'", nil},
+	4:  {"add 'ImageStates'", nil},
+	5:  {"replace the 'IsInternal' flag of containers with their type", migrateInternalContainers},
+	6:  {"add 'MetadataUpdated' to containers and 'DomainNameServers' and 'DomainNameSearchList' to ENIs", nil},
+	7:  {"add 'UseExecutionRole', 'executionCredentialsID', 'LogsAuthStrategy' and task cgroup fields", nil},
+	8:  {"add 'ipToTask'", nil},
+	9:  {"add 'healthCheckType' to containers", nil},
+	10: {"add 'PrivateDNSName' to ENIs and remove 'AppliedStatus' from containers", migrateAppliedStatus},
+	11: {"save 'TransitionDependencySet' as a map by dependent status", migrateTransitionDependencies},
+	12: {"add 'resources' to tasks", nil},
+	13: {"add 'PlatformFields' to tasks", nil},
+	14: {"add 'PIDMode' and 'IPCMode' to tasks", nil},
+	15: {"add 'V3EndpointID' to containers", nil},
+	16: {"add 'secrets' to containers and the 'ssmsecret' resource", nil},
+	17: {"add 'AvailabilityZone' and the 'asmsecret' resource", nil},
+	18: {"add 'Associations', 'GPUIDs' and 'NvidiaRuntime'", nil},
+	19: {"add 'DependsOn', 'StartTime' and 'StopTime' to containers", nil},
+	20: {"add 'target' to secrets", nil},
+	21: {"add 'attachmentType' to ENI attachments and 'InterfaceAssociationProtocol' and 'InterfaceVlanProperties' to ENIs", nil},
+	22: {"add 'RuntimeID' and 'FirelensConfig' to containers and the 'firelens' resource", nil},
+	23: {"add 'imageDigest' to containers and more fields to the 'firelens' resource", nil},
+	24: {"add 'seqNumTaskManifest'", nil},
+	25: {"add the 'credentialspec' resource", nil},
+	26: {"add EFS authorization and transit encryption and 'pauseContainerPID' to volumes", nil},
+	27: {"add the 'envfile' resource", nil},
+	28: {"add 'restartPolicy', 'RestartCount' and 'LastExitCodes' to containers", nil},
+	29: {"add 'Timeline' to tasks", nil},
+	30: {"add 'StopReason' to containers", nil},
+}
+
+// migrateSavedData upgrades the saved data to ECSDataVersion. Data saved by a
+// newer version of the agent isn't downgraded, since the fields it added would
+// be lost.
+func migrateSavedData(saved *SavedData) error {
+	if saved.Version > ECSDataVersion {
+		return errors.Errorf("state was saved by a newer agent with data version %d, and can't be downgraded to data version %d",
+			saved.Version, ECSDataVersion)
+	}
+	version := saved.Version
+	if version < 1 {
+		// The version was always saved, but state without one can only be
+		// as old as the first version
+		version = 1
+	}
+	for ; version < ECSDataVersion; version++ {
+		step, ok := migrations[version]
+		if !ok {
+			return errors.Errorf("no migration of the state from version %d to version %d", version, version+1)
+		}
+		if step.migrate == nil {
+			continue
+		}
+		seelog.Infof("Migrating the state from version %d to version %d: %s", version, version+1, step.description)
+		if err := step.migrate(saved); err != nil {
+			return errors.Wrapf(err, "could not migrate the state from version %d to version %d", version, version+1)
+		}
+	}
+	// Agents kept saving the 'ENI' of tasks as an object at every version
+	// after they could load it as a list, so it's changed into a list whatever
+	// version the state was saved at
+	if err := migrateENIs(saved); err != nil {
+		return errors.Wrap(err, "could not migrate the ENIs of tasks")
+	}
+	saved.Version = ECSDataVersion
+	return nil
+}
+
+// migrateDeadAndUnknownStatuses replaces the 'DEAD' and 'UNKNOWN' statuses of
+// tasks and containers, which were only saved by the first version, with
+// 'STOPPED' and 'NONE'
+func migrateDeadAndUnknownStatuses(saved *SavedData) error {
+	replaceStatuses := func(object jsonObject, keys ...string) {
+		for _, key := range keys {
+			switch string(object[key]) {
+			case `"DEAD"`:
+				object[key] = json.RawMessage(`"STOPPED"`)
+			case `"UNKNOWN"`:
+				object[key] = json.RawMessage(`"NONE"`)
+			}
+		}
+	}
+	if err := forEachTask(saved, func(task jsonObject) error {
+		replaceStatuses(task, "KnownStatus", "DesiredStatus", "SentStatus")
+		return nil
+	}); err != nil {
+		return err
+	}
+	return forEachContainer(saved, func(container jsonObject) error {
+		replaceStatuses(container, "KnownStatus", "desiredStatus", "SentStatus", "AppliedStatus")
+		return nil
+	})
+}
+
+// migrateInternalContainers replaces the 'IsInternal' flag of containers with
+// the type of the container, which is saved under the same key
+func migrateInternalContainers(saved *SavedData) error {
+	return forEachContainer(saved, func(container jsonObject) error {
+		switch string(container["IsInternal"]) {
+		case "true":
+			container["IsInternal"] = json.RawMessage(`"EMPTY_HOST_VOLUME"`)
+		case "false":
+			container["IsInternal"] = json.RawMessage(`"NORMAL"`)
+		}
+		return nil
+	})
+}
+
+func migrateAppliedStatus(saved *SavedData) error {
+	return forEachContainer(saved, func(container jsonObject) error {
+		delete(container, "AppliedStatus")
+		return nil
+	})
+}
+
+// migrateENIs changes the 'ENI' of tasks from an object into a list. ENIs that
+// are already saved as a list are left as they are.
+func migrateENIs(saved *SavedData) error {
+	return forEachTask(saved, func(task jsonObject) error {
+		eni, ok := task["ENI"]
+		if !ok || isJSONNull(eni) || isJSONArray(eni) {
+			return nil
+		}
+		enis, err := json.Marshal([]json.RawMessage{eni})
+		if err != nil {
+			return err
+		}
+		task["ENI"] = enis
+		return nil
+	})
+}
+
+// migrateTransitionDependencies changes the 'TransitionDependencySet' of
+// containers from a set of dependencies that each have their dependent status
+// into a map of sets by dependent status
+func migrateTransitionDependencies(saved *SavedData) error {
+	return forEachContainer(saved, func(container jsonObject) error {
+		data, ok := container["TransitionDependencySet"]
+		if !ok || isJSONNull(data) {
+			return nil
+		}
+		var dependencies jsonObject
+		if err := json.Unmarshal(data, &dependencies); err != nil {
+			return errors.Wrap(err, "could not unmarshal 'TransitionDependencySet'")
+		}
+		_, hasContainerDependencies := dependencies["ContainerDependencies"]
+		_, hasResourceDependencies := dependencies["ResourceDependencies"]
+		if !hasContainerDependencies && !hasResourceDependencies {
+			// Already a map by dependent status
+			return nil
+		}
+		var set struct {
+			ContainerDependencies []jsonObject
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return errors.Wrap(err, "could not unmarshal 'TransitionDependencySet'")
+		}
+
+		dependenciesByStatus := make(map[string][]jsonObject)
+		for _, dependency := range set.ContainerDependencies {
+			var dependentStatus apicontainerstatus.ContainerStatus
+			if data, ok := dependency["DependentStatus"]; ok {
+				if err := json.Unmarshal(data, &dependentStatus); err != nil {
+					return errors.Wrap(err, "could not unmarshal the dependent status of a container dependency")
+				}
+			}
+			// The dependent status is the key of the map instead
+			delete(dependency, "DependentStatus")
+			key := strconv.Itoa(int(dependentStatus))
+			dependenciesByStatus[key] = append(dependenciesByStatus[key], dependency)
+		}
+		dependenciesMap := make(map[string]jsonObject, len(dependenciesByStatus))
+		for key, dependencies := range dependenciesByStatus {
+			data, err := json.Marshal(dependencies)
+			if err != nil {
+				return err
+			}
+			dependenciesMap[key] = jsonObject{"ContainerDependencies": data}
+		}
+		data, err := json.Marshal(dependenciesMap)
+		if err != nil {
+			return err
+		}
+		container["TransitionDependencySet"] = data
+		return nil
+	})
+}
+
+// jsonObject is a JSON object whose values are left as they were saved
+type jsonObject map[string]json.RawMessage
+
+// forEachTask calls fn with each task saved by the task engine, whether it was
+// saved in the state file or as an entity in the key-value store, and saves
+// the changes fn makes to it
+func forEachTask(saved *SavedData, fn func(task jsonObject) error) error {
+	if data, ok := saved.Saveables[taskEngineSaveable]; ok {
+		return updateJSONObject(saved.Saveables, taskEngineSaveable, data, func(taskEngine jsonObject) error {
+			data, ok := taskEngine[tasksKey]
+			if !ok || isJSONNull(data) {
+				return nil
+			}
+			var tasks []jsonObject
+			if err := json.Unmarshal(data, &tasks); err != nil {
+				return errors.Wrap(err, "could not unmarshal the saved tasks")
+			}
+			for _, task := range tasks {
+				if err := fn(task); err != nil {
+					return err
+				}
+			}
+			data, err := json.Marshal(tasks)
+			if err != nil {
+				return err
+			}
+			taskEngine[tasksKey] = data
+			return nil
+		})
+	}
+	tasks := saved.Entities[taskEngineSaveable][tasksKey]
+	for _, arn := range sortedRawKeys(tasks) {
+		if err := updateJSONObject(tasks, arn, tasks[arn], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachContainer calls fn with each container saved by the task engine, both
+// in the tasks and in the Docker containers that wrap them, and saves the
+// changes fn makes to it
+func forEachContainer(saved *SavedData, fn func(container jsonObject) error) error {
+	containersOfTask := func(task jsonObject) error {
+		data, ok := task["Containers"]
+		if !ok || isJSONNull(data) {
+			return nil
+		}
+		var containers []jsonObject
+		if err := json.Unmarshal(data, &containers); err != nil {
+			return errors.Wrap(err, "could not unmarshal the saved containers of a task")
+		}
+		for _, container := range containers {
+			if err := fn(container); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(containers)
+		if err != nil {
+			return err
+		}
+		task["Containers"] = data
+		return nil
+	}
+	containerOfDockerContainer := func(dockerContainer jsonObject) error {
+		data, ok := dockerContainer["Container"]
+		if !ok || isJSONNull(data) {
+			return nil
+		}
+		return updateJSONObject(dockerContainer, "Container", data, fn)
+	}
+
+	if err := forEachTask(saved, containersOfTask); err != nil {
+		return err
+	}
+	if data, ok := saved.Saveables[taskEngineSaveable]; ok {
+		return updateJSONObject(saved.Saveables, taskEngineSaveable, data, func(taskEngine jsonObject) error {
+			data, ok := taskEngine[dockerContainersKey]
+			if !ok || isJSONNull(data) {
+				return nil
+			}
+			var dockerContainers map[string]jsonObject
+			if err := json.Unmarshal(data, &dockerContainers); err != nil {
+				return errors.Wrap(err, "could not unmarshal the saved Docker containers")
+			}
+			for _, dockerContainer := range dockerContainers {
+				if dockerContainer == nil {
+					continue
+				}
+				if err := containerOfDockerContainer(dockerContainer); err != nil {
+					return err
+				}
+			}
+			data, err := json.Marshal(dockerContainers)
+			if err != nil {
+				return err
+			}
+			taskEngine[dockerContainersKey] = data
+			return nil
+		})
+	}
+	dockerContainers := saved.Entities[taskEngineSaveable][dockerContainersKey]
+	for _, id := range sortedRawKeys(dockerContainers) {
+		if err := updateJSONObject(dockerContainers, id, dockerContainers[id], containerOfDockerContainer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateJSONObject unmarshals the object saved under the key, calls fn with it
+// and saves it back under the key
+func updateJSONObject(objects map[string]json.RawMessage, key string, data json.RawMessage, fn func(object jsonObject) error) error {
+	var object jsonObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return errors.Wrapf(err, "could not unmarshal %s", key)
+	}
+	if object == nil {
+		return nil
+	}
+	if err := fn(object); err != nil {
+		return err
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	objects[key] = data
+	return nil
+}
+
+func sortedRawKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isJSONNull(data json.RawMessage) bool {
+	return string(data) == "null"
+}
+
+func isJSONArray(data json.RawMessage) bool {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return b == '['
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apicontainerstatus "github.com/aws/amazon-ecs-agent/agent/api/container/status"
+	apitaskstatus "github.com/aws/amazon-ecs-agent/agent/api/task/status"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGoldenFiles = flag.Bool("update", false, "update the golden files of the state migrations")
+
+func TestMigrationsRegistered(t *testing.T) {
+	for version := 1; version < ECSDataVersion; version++ {
+		step, ok := migrations[version]
+		if assert.True(t, ok, "No migration from version %d", version) {
+			assert.NotEmpty(t, step.description, "No description of the migration from version %d", version)
+		}
+	}
+	assert.Len(t, migrations, ECSDataVersion-1, "Migrations are registered from versions that aren't before the current one")
+}
+
+// TestMigrationGoldenFiles migrates the state in before.json in the directory
+// of each migration that changes anything under testdata/migrations, and
+// compares it with after.json. Run with -update to rewrite after.json.
+func TestMigrationGoldenFiles(t *testing.T) {
+	for version := 1; version < ECSDataVersion; version++ {
+		step := migrations[version]
+		if step.migrate == nil {
+			continue
+		}
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			dir := filepath.Join("testdata", "migrations", fmt.Sprintf("v%d", version))
+			data, err := ioutil.ReadFile(filepath.Join(dir, "before.json"))
+			require.NoError(t, err, "Migrations that change the state need golden files")
+			var before intermediateState
+			require.NoError(t, json.Unmarshal(data, &before))
+			require.Equal(t, version, before.Version)
+
+			saved := &SavedData{Store: config.StateStoreJSON, Version: before.Version, Saveables: before.Data}
+			require.NoError(t, step.migrate(saved))
+			migrated, err := json.MarshalIndent(intermediateState{Data: saved.Saveables, Version: version + 1}, "", "  ")
+			require.NoError(t, err)
+
+			afterPath := filepath.Join(dir, "after.json")
+			if *updateGoldenFiles {
+				require.NoError(t, ioutil.WriteFile(afterPath, append(migrated, '\n'), 0644))
+			}
+			after, err := ioutil.ReadFile(afterPath)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(after), string(migrated))
+
+			// Migrating again changes nothing
+			require.NoError(t, step.migrate(saved))
+			remigrated, err := json.MarshalIndent(intermediateState{Data: saved.Saveables, Version: version + 1}, "", "  ")
+			require.NoError(t, err)
+			assert.JSONEq(t, string(migrated), string(remigrated))
+		})
+	}
+}
+
+func TestMigrateSavedData(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "v1", "before.json"))
+	require.NoError(t, err)
+	var before intermediateState
+	require.NoError(t, json.Unmarshal(data, &before))
+	saved := &SavedData{Store: config.StateStoreJSON, Version: before.Version, Saveables: before.Data}
+	require.NoError(t, migrateSavedData(saved))
+	assert.Equal(t, ECSDataVersion, saved.Version)
+
+	taskEngineState, err := dockerstate.ParseSavedState(saved.Saveables[taskEngineSaveable])
+	require.NoError(t, err)
+	require.Len(t, taskEngineState.Tasks, 1)
+	task := taskEngineState.Tasks[0]
+	assert.Equal(t, apitaskstatus.TaskStopped, task.GetKnownStatus())
+	require.Len(t, task.Containers, 1)
+	assert.Equal(t, apicontainerstatus.ContainerStopped, task.Containers[0].GetKnownStatus())
+	assert.Equal(t, apicontainer.ContainerNormal, task.Containers[0].Type)
+	assert.Equal(t, `"test"`, string(saved.Saveables["Cluster"]), "Other saveables should not change")
+}
+
+func TestReadSavedDataMigrates(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "migrations", "v11", "before.json"))
+	require.NoError(t, err)
+	dataDir, err := ioutil.TempDir("", "ecs_statemanager_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, ecsDataFile), data, 0600))
+
+	saved, err := ReadSavedData(&config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	assert.Equal(t, 11, saved.SavedVersion)
+	assert.Equal(t, ECSDataVersion, saved.Version)
+	taskEngineState, err := dockerstate.ParseSavedState(saved.Saveables[taskEngineSaveable])
+	require.NoError(t, err, "The saved state should be migrated before it's parsed")
+	require.NotEmpty(t, taskEngineState.Tasks)
+	assert.Len(t, taskEngineState.Tasks[0].ENIs, 1)
+
+	unchanged, err := ioutil.ReadFile(filepath.Join(dataDir, ecsDataFile))
+	require.NoError(t, err)
+	assert.Equal(t, data, unchanged, "The state file should not be changed")
+}
+
+func TestMigrateSavedDataENIObject(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "v28", "task-networking", "ecs_agent_data.json"))
+	require.NoError(t, err)
+	var before intermediateState
+	require.NoError(t, json.Unmarshal(data, &before))
+	require.Equal(t, 28, before.Version)
+	saved := &SavedData{Store: config.StateStoreJSON, Version: before.Version, Saveables: before.Data}
+	_, err = dockerstate.ParseSavedState(saved.Saveables[taskEngineSaveable])
+	require.Error(t, err, "The ENI saved as an object should not be unmarshaled as a list")
+
+	require.NoError(t, migrateSavedData(saved))
+	taskEngineState, err := dockerstate.ParseSavedState(saved.Saveables[taskEngineSaveable])
+	require.NoError(t, err)
+	require.Len(t, taskEngineState.Tasks, 1)
+	require.Len(t, taskEngineState.Tasks[0].ENIs, 1)
+	assert.Equal(t, "eni-089ba8329b8e3f6ec", taskEngineState.Tasks[0].ENIs[0].ID)
+}
+
+func TestMigrateSavedDataEntities(t *testing.T) {
+	saved := &SavedData{
+		Store:   config.StateStoreBoltDB,
+		Version: 5,
+		Entities: map[string]map[string]map[string]json.RawMessage{
+			taskEngineSaveable: {
+				tasksKey: {
+					"task-arn": json.RawMessage(`{"Arn":"task-arn","Containers":[{"Name":"web","IsInternal":false},` +
+						`{"Name":"volume","IsInternal":true}]}`),
+				},
+				dockerContainersKey: {
+					"docker-id": json.RawMessage(`{"DockerId":"docker-id","Container":{"Name":"volume","IsInternal":true}}`),
+				},
+				"IdToTask": {
+					"docker-id": json.RawMessage(`"task-arn"`),
+				},
+			},
+		},
+	}
+	require.NoError(t, migrateSavedData(saved))
+	assert.Equal(t, ECSDataVersion, saved.Version)
+
+	taskEngineState, err := dockerstate.ParseSavedEntities(saved.Entities[taskEngineSaveable])
+	require.NoError(t, err)
+	require.Len(t, taskEngineState.Tasks, 1)
+	require.Len(t, taskEngineState.Tasks[0].Containers, 2)
+	assert.Equal(t, apicontainer.ContainerNormal, taskEngineState.Tasks[0].Containers[0].Type)
+	assert.Equal(t, apicontainer.ContainerEmptyHostVolume, taskEngineState.Tasks[0].Containers[1].Type)
+	assert.Equal(t, apicontainer.ContainerEmptyHostVolume, taskEngineState.IdToContainer["docker-id"].Container.Type)
+	assert.JSONEq(t, `{"DockerId":"docker-id","Container":{"Name":"volume","IsInternal":"EMPTY_HOST_VOLUME"}}`,
+		string(saved.Entities[taskEngineSaveable][dockerContainersKey]["docker-id"]))
+}
+
+func TestMigrateSavedDataRefusesDowngrade(t *testing.T) {
+	saved := &SavedData{
+		Store:     config.StateStoreJSON,
+		Version:   ECSDataVersion + 1,
+		Saveables: map[string]json.RawMessage{"Cluster": json.RawMessage(`"test"`)},
+	}
+	err := migrateSavedData(saved)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't be downgraded")
+	assert.Equal(t, ECSDataVersion+1, saved.Version)
+}
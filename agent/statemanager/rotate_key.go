@@ -0,0 +1,186 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// RotateEncryptionKey re-encrypts the state saved in the DataDir of cfg, both
+// in the JSON state file and in the key-value store, with the key in
+// newKeyFile. The state files that were moved aside are re-encrypted as well,
+// and the quarantined stores are removed. The state is decrypted with the key
+// configured in cfg, or read as is if there's none. The agent must not be
+// running while the key is rotated, and must be configured with the new key
+// afterwards.
+func RotateEncryptionKey(cfg *config.Config, newKeyFile string) error {
+	encodedKey, err := ioutil.ReadFile(newKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "could not read the new state encryption key file")
+	}
+	newCipher, err := parseStateCipher(string(encodedKey))
+	if err != nil {
+		return err
+	}
+	currentCipher, err := newConfiguredStateCipher(cfg)
+	if err != nil {
+		return err
+	}
+
+	manager := &basicStateManager{
+		statePath:            cfg.DataDir,
+		cipher:               currentCipher,
+		backupCount:          cfg.StateBackupCount,
+		platformDependencies: newPlatformDependencies(),
+	}
+	if err := manager.rotateFileKey(newCipher); err != nil {
+		return errors.Wrap(err, "could not re-encrypt the state file")
+	}
+	if err := manager.sealLeftoverFiles(newCipher); err != nil {
+		return errors.Wrap(err, "could not re-encrypt the state files that were moved aside")
+	}
+	kvManager := &kvStateManager{basicStateManager: manager}
+	if err := kvManager.rotateStoreKey(newCipher); err != nil {
+		return errors.Wrap(err, "could not re-encrypt the state store")
+	}
+	if err := manager.removeQuarantinedStores(); err != nil {
+		return errors.Wrap(err, "could not remove the quarantined state stores")
+	}
+	return nil
+}
+
+func (manager *basicStateManager) rotateFileKey(newCipher *stateCipher) error {
+	data, err := manager.readFile()
+	if err != nil || data == nil {
+		return err
+	}
+	data, err = openState(manager.cipher, data, nil)
+	if err != nil {
+		return err
+	}
+	data, err = newCipher.seal(data, nil)
+	if err != nil {
+		return err
+	}
+	seelog.Infof("Re-encrypting %s", ecsDataFile)
+	if err := manager.writeFile(data); err != nil {
+		return err
+	}
+	return manager.rotateSnapshotsKey(newCipher)
+}
+
+// rotateSnapshotsKey re-encrypts the snapshots of the state file. Snapshots
+// that can't be read are removed, since they couldn't be recovered from anyway.
+func (manager *basicStateManager) rotateSnapshotsKey(newCipher *stateCipher) error {
+	for i := 1; i <= manager.backupCount; i++ {
+		path := manager.snapshotPath(i)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		data, err = openState(manager.cipher, data, nil)
+		if err == nil {
+			data, err = newCipher.seal(data, nil)
+		}
+		if err != nil {
+			seelog.Warnf("Removing the state snapshot %s that can't be re-encrypted: %v", path, err)
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (manager *kvStateManager) rotateStoreKey(newCipher *stateCipher) error {
+	if _, err := os.Stat(manager.storePath()); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := manager.openStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	seelog.Infof("Re-encrypting %s", ecsDataStore)
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{saveablesBucket, entitiesBucket} {
+			if bucket := tx.Bucket(name); bucket != nil {
+				if err := manager.resealBucket(bucket, newCipher, name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The values encrypted with the current key are only gone from the store
+	// once it's compacted. The snapshots of the store hold them as well.
+	if err := manager.compactStore(db, newCipher.keyID); err != nil {
+		return err
+	}
+	return manager.removeStoreSnapshots()
+}
+
+// resealBucket re-encrypts the values in the bucket, and in the buckets nested
+// in it, with the new cipher. path is the names of the bucket and of the
+// buckets it's in.
+func (manager *kvStateManager) resealBucket(bucket *bolt.Bucket, newCipher *stateCipher, path ...[]byte) error {
+	// Values can't be written while the bucket is iterated over
+	var keys, nestedBuckets [][]byte
+	bucket.ForEach(func(key, value []byte) error {
+		key = append([]byte(nil), key...)
+		if value == nil {
+			nestedBuckets = append(nestedBuckets, key)
+		} else {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	for _, key := range keys {
+		keyPath := valuePath(append(path[:len(path):len(path)], key)...)
+		data, err := openState(manager.cipher, bucket.Get(key), keyPath)
+		if err != nil {
+			return errors.Wrapf(err, "could not read %s", string(key))
+		}
+		sealed, err := newCipher.seal(data, keyPath)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(key, sealed); err != nil {
+			return err
+		}
+	}
+	for _, key := range nestedBuckets {
+		if err := manager.resealBucket(bucket.Bucket(key), newCipher, append(path[:len(path):len(path)], key)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
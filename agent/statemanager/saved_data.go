@@ -0,0 +1,112 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/pkg/errors"
+)
+
+// errNoSavedState is the cause of the error ReadSavedData returns when no
+// state is saved in the data directory
+var errNoSavedState = errors.New("no state is saved")
+
+// IsNoSavedState returns true if the error is that no state is saved in the
+// data directory
+func IsNoSavedState(err error) bool {
+	return errors.Cause(err) == errNoSavedState
+}
+
+// SavedData is the state saved in a data directory
+type SavedData struct {
+	// Store is how the state was saved, either config.StateStoreJSON or
+	// config.StateStoreBoltDB
+	Store string
+	// SavedVersion is the ECSDataVersion of the agent that saved the state
+	SavedVersion int
+	// Version is the version of the saved data, which is ECSDataVersion once
+	// it's migrated
+	Version int
+	// Saveables holds the JSON of each saveable by name
+	Saveables map[string]json.RawMessage
+	// Entities holds the entities of each EntitySaveable by name, as they're
+	// passed to UnmarshalEntities. Only the key-value store saves entities.
+	Entities map[string]map[string]map[string]json.RawMessage
+}
+
+// ReadSavedData reads the state saved in the DataDir of cfg without loading it
+// into any saveables, so that it can be inspected however inconsistent it is.
+// The state is read from the key-value store if anything was saved in it, and
+// from the JSON state file otherwise. It's migrated to ECSDataVersion as the
+// agent would migrate it, unless it was saved by a newer agent, in which case
+// it's returned as it was saved. Nothing is changed on disk: a corrupted state
+// file is an error rather than being recovered from a snapshot.
+func ReadSavedData(cfg *config.Config) (*SavedData, error) {
+	saved, err := readSavedData(cfg)
+	if err != nil || saved.Version > ECSDataVersion {
+		return saved, err
+	}
+	if err := migrateSavedData(saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// readSavedData reads the state saved in the DataDir of cfg as it was saved
+func readSavedData(cfg *config.Config) (*SavedData, error) {
+	stateCipher, err := newConfiguredStateCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	manager := &kvStateManager{
+		basicStateManager: &basicStateManager{
+			statePath: cfg.DataDir,
+			cipher:    stateCipher,
+		},
+	}
+	if saved, err := manager.readStore(); err != nil || saved != nil {
+		return saved, err
+	}
+
+	// The state file is read directly rather than with readFile, since on
+	// Windows that reads the file the registry points to rather than the one
+	// in DataDir
+	path := filepath.Join(cfg.DataDir, ecsDataFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(errNoSavedState, "could not read the state in %s", cfg.DataDir)
+		}
+		return nil, err
+	}
+	data, err = manager.openStateFile(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+	var saved intermediateState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal %s", path)
+	}
+	return &SavedData{
+		Store:        config.StateStoreJSON,
+		SavedVersion: saved.Version,
+		Version:      saved.Version,
+		Saveables:    saved.Data,
+	}, nil
+}
@@ -21,7 +21,6 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -34,7 +33,8 @@ import (
 const (
 	// ECSDataVersion is the current version of saved data. Any backwards or
 	// forwards incompatible changes to the data-format should increment this number
-	// and retain the ability to read old data versions.
+	// and retain the ability to read old data versions, by registering a migration
+	// from the previous version in migrations.go.
 	// Version changes:
 	// 1) initial
 	// 2)
@@ -102,8 +102,11 @@ const (
 	//	 a) Add 'authorizationConfig', 'transitEncryption' and 'transitEncryptionPort' to 'taskresource.volume.EFSVolumeConfig'
 	//	 b) Add 'pauseContainerPID' field to 'taskresource.volume.VolumeResource'
 	// 28) Add 'envfile' field to 'resources'
+	// 29) Add 'restartPolicy', 'RestartCount' and 'LastExitCodes' fields to 'apicontainer.Container'
+	// 30) Add 'Timeline' field to 'apitask.Task'
+	// 31) Add 'StopReason' field to 'apicontainer.Container'
 
-	ECSDataVersion = 28
+	ECSDataVersion = 31
 
 	// ecsDataFile specifies the filename in the ECS_DATADIR
 	ecsDataFile = "ecs_agent_data.json"
@@ -144,10 +147,7 @@ type state struct {
 }
 
 type intermediateState struct {
-	Data intermediateSaveableState
-}
-
-type versionOnlyState struct {
+	Data    intermediateSaveableState
 	Version int
 }
 
@@ -171,13 +171,19 @@ type basicStateManager struct {
 
 	savingLock sync.Mutex // guards marshal, write, move (on Linux), and load (on Windows)
 
+	cipher      *stateCipher // encrypts the state if an encryption key is configured
+	backupCount int          // the number of snapshots of the state file to keep
+
 	platformDependencies platformDependencies // platform-specific dependencies
 }
 
 // NewStateManager constructs a new StateManager which saves data at the
 // location specified in cfg and operates under the given options.
-// The returned StateManager will not save more often than every 10 seconds and
-// will not reliably return errors with Save, but will log them appropriately.
+// The data is saved in the key-value store if cfg.StateStore is "boltdb", and
+// in the JSON state file otherwise.
+// The returned StateManager will not save to the JSON state file more often
+// than every 10 seconds and will not reliably return errors with Save, but
+// will log them appropriately.
 func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error) {
 	fi, err := os.Stat(cfg.DataDir)
 	if err != nil {
@@ -186,23 +192,33 @@ func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error
 	if !fi.IsDir() {
 		return nil, errors.New("State manager DataDir must exist")
 	}
+	stateCipher, err := newConfiguredStateCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	state := &state{
 		Data:    make(saveableState),
 		Version: ECSDataVersion,
 	}
 	manager := &basicStateManager{
-		statePath: cfg.DataDir,
-		state:     state,
+		statePath:   cfg.DataDir,
+		state:       state,
+		cipher:      stateCipher,
+		backupCount: cfg.StateBackupCount,
+	}
+	var stateManager StateManager = manager
+	if cfg.StateStore == config.StateStoreBoltDB {
+		stateManager = &kvStateManager{basicStateManager: manager}
 	}
 
 	for _, option := range options {
-		option(manager)
+		option(stateManager)
 	}
 
 	manager.platformDependencies = newPlatformDependencies()
 
-	return manager, nil
+	return stateManager, nil
 }
 
 // AddSaveable is an option that adds a given saveable as one that should be saved
@@ -211,8 +227,13 @@ func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error
 // deserialized correctly.
 func AddSaveable(name string, saveable Saveable) Option {
 	return (Option)(func(m StateManager) {
-		manager, ok := m.(*basicStateManager)
-		if !ok {
+		var manager *basicStateManager
+		switch m := m.(type) {
+		case *basicStateManager:
+			manager = m
+		case *kvStateManager:
+			manager = m.basicStateManager
+		default:
 			seelog.Critical("Unable to add to state manager; unknown instantiation")
 			return
 		}
@@ -224,21 +245,27 @@ func AddSaveable(name string, saveable Saveable) Option {
 // between saves.
 func (manager *basicStateManager) Save() error {
 	defer metrics.MetricsEngineGlobal.RecordStateManagerMetric("SAVE")()
+	return manager.save(minSaveInterval, manager.ForceSave)
+}
+
+// save calls forceSave unless it was last called less than interval ago, in
+// which case a call is planned for when interval is over
+func (manager *basicStateManager) save(interval time.Duration, forceSave func() error) error {
 	manager.saveTimesLock.Lock()
 	defer manager.saveTimesLock.Unlock()
-	if time.Since(manager.lastSave) >= minSaveInterval {
+	if time.Since(manager.lastSave) >= interval {
 		// we can just save
-		err := manager.ForceSave()
+		err := forceSave()
 		manager.lastSave = time.Now()
 		manager.nextPlannedSave = time.Time{} // re-zero it; assume all pending desires to save are fulfilled
 		return err
 	} else if manager.nextPlannedSave.IsZero() {
 		// No save planned yet, we should plan one.
-		next := manager.lastSave.Add(minSaveInterval)
+		next := manager.lastSave.Add(interval)
 		manager.nextPlannedSave = next
 		go func() {
 			time.Sleep(next.Sub(time.Now()))
-			manager.Save()
+			manager.save(interval, forceSave)
 		}()
 	}
 	// else nextPlannedSave wasn't Zero so there's a save planned elsewhere that'll
@@ -260,47 +287,74 @@ func (manager *basicStateManager) ForceSave() error {
 	s := manager.state
 	s.Version = ECSDataVersion
 
-	data, err := json.Marshal(s)
+	data, err := marshalState(s)
 	if err != nil {
 		seelog.Error("Error saving state; could not marshal data; this is odd", "err", err)
 		return err
 	}
-	return manager.writeFile(data)
+	data, err = sealState(manager.cipher, data, nil)
+	if err != nil {
+		seelog.Errorf("Error saving state; could not encrypt data, err: %v", err)
+		return err
+	}
+	if err := manager.writeFile(data); err != nil {
+		return err
+	}
+	if err := manager.writeSnapshot(data); err != nil {
+		seelog.Warnf("Unable to keep a snapshot of the saved state: %v", err)
+	}
+	return nil
 }
 
 // Load reads state off the disk from the well-known filepath and loads it into
-// the passed State object.
+// the passed State object. If the state file is corrupted, the state is loaded
+// from the newest good snapshot of it instead.
 func (manager *basicStateManager) Load() error {
-	s := manager.state
 	seelog.Info("Loading state!")
-	data, err := manager.readFile()
+	data, err := manager.readState()
 	if err != nil {
-		seelog.Error("Error reading existing state file", "err", err)
 		return err
 	}
 	if data == nil {
-		return nil
+		err = manager.migrateStore()
+	} else {
+		err = manager.loadData(data)
 	}
-	// Dry-run to make sure this is a version we can understand
-	err = manager.dryRun(data)
 	if err != nil {
 		return err
 	}
-	// Now load it into the actual state. The reason we do this with the
-	// intermediate state is that we *must* unmarshal directly into the
-	// "saveable" pointers we were given in AddSaveable; if we unmarshal
-	// directly into a map with values of pointers, those pointers are lost.
-	// We *must* unmarshal this way because the existing pointers could have
-	// semi-initialized data (and are actually expected to)
+	manager.encryptLeftoverFiles()
+	return nil
+}
 
+// loadData loads the state saved in data into the saveables
+func (manager *basicStateManager) loadData(data []byte) error {
+	s := manager.state
+	// The reason we unmarshal into the intermediate state is that we *must*
+	// unmarshal directly into the "saveable" pointers we were given in
+	// AddSaveable; if we unmarshal directly into a map with values of
+	// pointers, those pointers are lost. We *must* unmarshal this way because
+	// the existing pointers could have semi-initialized data (and are actually
+	// expected to)
 	var intermediate intermediateState
-	err = json.Unmarshal(data, &intermediate)
+	err := json.Unmarshal(data, &intermediate)
 	if err != nil {
-		seelog.Debug("Could not unmarshal into intermediate")
+		seelog.Critical("Could not unmarshal existing state; corrupted data?", "err", err, "data", data)
+		return err
+	}
+	// Make sure this is a version we can understand, and upgrade it to the
+	// current one
+	saved := &SavedData{
+		Store:        config.StateStoreJSON,
+		SavedVersion: intermediate.Version,
+		Version:      intermediate.Version,
+		Saveables:    intermediate.Data,
+	}
+	if err := migrateSavedData(saved); err != nil {
 		return err
 	}
 
-	for key, rawJSON := range intermediate.Data {
+	for key, rawJSON := range saved.Saveables {
 		actualPointer, ok := manager.state.Data[key]
 		if !ok {
 			seelog.Error("Loading state: potentially malformed json key of " + key)
@@ -316,18 +370,3 @@ func (manager *basicStateManager) Load() error {
 	seelog.Debug("Loaded state!", "state", s)
 	return nil
 }
-
-func (manager *basicStateManager) dryRun(data []byte) error {
-	// Dry-run to make sure this is a version we can understand
-	tmps := versionOnlyState{}
-	err := json.Unmarshal(data, &tmps)
-	if err != nil {
-		seelog.Critical("Could not unmarshal existing state; corrupted data?", "err", err, "data", data)
-		return err
-	}
-	if tmps.Version > ECSDataVersion {
-		strversion := strconv.Itoa(tmps.Version)
-		return errors.New("Unsupported data format: Version " + strversion + " not " + strconv.Itoa(ECSDataVersion))
-	}
-	return nil
-}
@@ -99,3 +99,18 @@ func (manager *basicStateManager) writeFile(data []byte) error {
 	}
 	return err
 }
+
+// moveFileAside renames the state file by appending the suffix to its name, so
+// that it's kept but no longer read
+func (manager *basicStateManager) moveFileAside(suffix string) error {
+	path := filepath.Join(manager.statePath, ecsDataFile)
+	return os.Rename(path, path+suffix)
+}
+
+// quarantineFile moves the state file aside so that it isn't overwritten, and
+// returns where it was moved to
+func (manager *basicStateManager) quarantineFile() (string, error) {
+	path := filepath.Join(manager.statePath, ecsDataFile)
+	quarantinePath := quarantinePath(path)
+	return quarantinePath, os.Rename(path, quarantinePath)
+}
@@ -88,6 +88,56 @@ func TestStateManager(t *testing.T) {
 	assert.Equal(t, "test-arn", tasks[0].Arn, "Wrong arn")
 }
 
+func TestStateManagerEncryption(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "ecs_statemanager_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	stateFile := filepath.Join(tmpDir, "ecs_agent_data.json")
+
+	cluster := "state-file"
+	manager, err := statemanager.NewStateManager(&config.Config{DataDir: tmpDir},
+		statemanager.AddSaveable("Cluster", &cluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.ForceSave())
+
+	// A state file saved before encryption is enabled is loaded, and is
+	// encrypted the next time it's saved
+	cfg := &config.Config{
+		DataDir:            tmpDir,
+		StateEncryptionKey: config.NewSensitiveRawMessage([]byte(testStateEncryptionKey(1))),
+	}
+	var loadedCluster string
+	manager, err = statemanager.NewStateManager(cfg, statemanager.AddSaveable("Cluster", &loadedCluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.Load())
+	assert.Equal(t, cluster, loadedCluster)
+	require.NoError(t, manager.ForceSave())
+
+	data, err := ioutil.ReadFile(stateFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), cluster, "The state file should be encrypted")
+	assertFileMode(t, stateFile)
+
+	loadedCluster = ""
+	require.NoError(t, manager.Load())
+	assert.Equal(t, cluster, loadedCluster)
+
+	manager, err = statemanager.NewStateManager(&config.Config{DataDir: tmpDir},
+		statemanager.AddSaveable("Cluster", &loadedCluster))
+	require.NoError(t, err)
+	assert.Error(t, manager.Load(), "An encrypted state file should not be loaded without the key")
+
+	keyFile := filepath.Join(tmpDir, "new.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(testStateEncryptionKey(2)), 0600))
+	require.NoError(t, statemanager.RotateEncryptionKey(cfg, keyFile))
+	loadedCluster = ""
+	manager, err = statemanager.NewStateManager(&config.Config{DataDir: tmpDir, StateEncryptionKeyFile: keyFile},
+		statemanager.AddSaveable("Cluster", &loadedCluster))
+	require.NoError(t, err)
+	require.NoError(t, manager.Load())
+	assert.Equal(t, cluster, loadedCluster)
+}
+
 func assertFileMode(t *testing.T, path string) {
 	info, err := os.Stat(path)
 	assert.Nil(t, err)
@@ -100,15 +150,17 @@ func assertFileMode(t *testing.T, path string) {
 // if we change those fields in the future, we should modify this test to test the new fields
 func TestLoadsDataForAWSVPCTask(t *testing.T) {
 	testCases := []struct {
-		dir  string
-		name string
+		version string
+		dir     string
+		name    string
 	}{
-		{"task-networking", "original_v11_encoding_scheme"},
-		{"task-networking-refactor", "refactored_v11_encoding_scheme"},
+		{"v11", "task-networking", "original_v11_encoding_scheme"},
+		{"v11", "task-networking-refactor", "refactored_v11_encoding_scheme"},
+		{"v28", "task-networking", "original_encoding_scheme_saved_at_v28"},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			cfg := &config.Config{DataDir: filepath.Join(".", "testdata", "v11", tc.dir)}
+			cfg := &config.Config{DataDir: filepath.Join(".", "testdata", tc.version, tc.dir)}
 
 			taskEngine := engine.NewTaskEngine(&config.Config{}, nil, nil, nil, nil, dockerstate.NewTaskEngineState(), nil, nil)
 			var containerInstanceArn, cluster, savedInstanceID string
@@ -150,8 +150,9 @@ func (manager *basicStateManager) writeFile(data []byte) error {
 		return nil
 	}
 
+	// The old file is already gone if it was quarantined
 	err = deps.fs.Remove(oldFile)
-	if err != nil {
+	if err != nil && !deps.fs.IsNotExist(err) {
 		seelog.Errorf("Error removing old file %s; err %v", oldFile, err)
 		return err
 	}
@@ -171,3 +172,30 @@ func (manager *basicStateManager) savePath(path string) error {
 	}
 	return key.SetStringValue(valueName(), path)
 }
+
+// moveFileAside renames the state file the registry points to after the state
+// file in the data directory, appending the suffix, so that it's kept but no
+// longer read
+func (manager *basicStateManager) moveFileAside(suffix string) error {
+	deps := manager.platformDependencies.(windowsDependencies)
+	path, err := manager.getPath()
+	if err != nil {
+		if err == registry.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	return deps.fs.Rename(path, filepath.Join(manager.statePath, ecsDataFile)+suffix)
+}
+
+// quarantineFile moves the state file aside so that it isn't overwritten, and
+// returns where it was moved to
+func (manager *basicStateManager) quarantineFile() (string, error) {
+	deps := manager.platformDependencies.(windowsDependencies)
+	path, err := manager.getPath()
+	if err != nil {
+		return "", err
+	}
+	quarantinePath := quarantinePath(filepath.Join(manager.statePath, ecsDataFile))
+	return quarantinePath, deps.fs.Rename(path, quarantinePath)
+}
@@ -0,0 +1,112 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+
+// Package testutils contains files that are used in tests but not elsewhere and thus can
+// be excluded from the final executable. Including them in a different package
+// allows this to happen
+package testutils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/pkg/errors"
+)
+
+const (
+	stateFile          = "ecs_agent_data.json"
+	taskEngineSaveable = "TaskEngine"
+)
+
+// MigrateTaskEngineState returns the JSON of the task engine state saved at
+// the version, migrated to the current version the same way the state manager
+// migrates the saved state before it loads it
+func MigrateTaskEngineState(data []byte, version int) ([]byte, error) {
+	dataDir, err := ioutil.TempDir("", "ecs_statemanager_testutils")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dataDir)
+
+	state, err := json.Marshal(struct {
+		Data    map[string]json.RawMessage
+		Version int
+	}{
+		Data:    map[string]json.RawMessage{taskEngineSaveable: data},
+		Version: version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, stateFile), state, 0600); err != nil {
+		return nil, err
+	}
+	saved, err := statemanager.ReadSavedData(&config.Config{DataDir: dataDir})
+	if err != nil {
+		return nil, err
+	}
+	return saved.Saveables[taskEngineSaveable], nil
+}
+
+// MigrateTask returns the JSON of a task saved at the version, migrated to the
+// current version
+func MigrateTask(data []byte, version int) ([]byte, error) {
+	var taskEngineState struct {
+		Tasks []json.RawMessage
+	}
+	taskEngineState.Tasks = []json.RawMessage{data}
+	taskEngineData, err := json.Marshal(taskEngineState)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := MigrateTaskEngineState(taskEngineData, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(migrated, &taskEngineState); err != nil {
+		return nil, err
+	}
+	if len(taskEngineState.Tasks) != 1 {
+		return nil, errors.Errorf("expected 1 migrated task, got %d", len(taskEngineState.Tasks))
+	}
+	return taskEngineState.Tasks[0], nil
+}
+
+// MigrateContainer returns the JSON of a container saved at the version,
+// migrated to the current version
+func MigrateContainer(data []byte, version int) ([]byte, error) {
+	var task struct {
+		Containers []json.RawMessage
+	}
+	task.Containers = []json.RawMessage{data}
+	taskData, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := MigrateTask(taskData, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(migrated, &task); err != nil {
+		return nil, err
+	}
+	if len(task.Containers) != 1 {
+		return nil, errors.Errorf("expected 1 migrated container, got %d", len(task.Containers))
+	}
+	return task.Containers[0], nil
+}
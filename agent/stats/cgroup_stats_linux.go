@@ -0,0 +1,476 @@
+// +build linux
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// cgroupV2ControllersFile is only found at the root of the unified
+	// hierarchy of cgroup v2
+	cgroupV2ControllersFile = "cgroup.controllers"
+	// cgroupSearchDepth is how deep the cgroup of a container is looked for
+	// under the root of a hierarchy, which is enough for docker/<id>,
+	// system.slice/docker-<id>.scope and ecs/<task id>/<id>
+	cgroupSearchDepth = 3
+	// cgroupUnlimited is the value of the limits of cgroup v2 and of pids.max
+	// when there's no limit
+	cgroupUnlimited = "max"
+	// cgroupProcsFile lists the pids of the processes in a cgroup
+	cgroupProcsFile = "cgroup.procs"
+	// loopbackInterface is left out of the network stats, like docker does
+	loopbackInterface = "lo"
+)
+
+// cgroupStatsReader reads the stats of containers from the files of their
+// cgroups, on either cgroup v1 or v2
+type cgroupStatsReader struct {
+	// root is where the cgroup hierarchies are mounted
+	root string
+	// unified is true for cgroup v2, where all the controllers are in a
+	// single hierarchy
+	unified bool
+	// procRoot is where procfs is mounted, to read the network stats of
+	// containers from the network namespace of their processes
+	procRoot string
+	lock     sync.Mutex
+	// cgroups maps the docker IDs of the containers read so far to their
+	// cgroups
+	cgroups map[string]*containerCgroup
+}
+
+// containerCgroup is the cgroup of a container, and the stats last read from it
+type containerCgroup struct {
+	// path is the path of the cgroup in the hierarchies, relative to their root
+	path     string
+	previous *types.StatsJSON
+}
+
+// newCgroupStatsReader returns a reader of the cgroups mounted at root
+func newCgroupStatsReader(root string) (containerStatsReader, error) {
+	reader := &cgroupStatsReader{
+		root:     root,
+		procRoot: "/proc",
+		cgroups:  make(map[string]*containerCgroup),
+	}
+	if _, err := os.Stat(filepath.Join(root, cgroupV2ControllersFile)); err == nil {
+		reader.unified = true
+		return reader, nil
+	}
+	for _, controller := range []string{"cpuacct", "memory"} {
+		if _, err := os.Stat(filepath.Join(root, controller)); err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: %s cgroup hierarchy not found under %s", controller, root)
+		}
+	}
+	return reader, nil
+}
+
+// containerStats implements containerStatsReader. The cgroup of a container is
+// looked for on its first read, and again if it can't be read anymore.
+func (reader *cgroupStatsReader) containerStats(dockerID string) (*types.StatsJSON, error) {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+
+	cgroup, ok := reader.cgroups[dockerID]
+	if !ok {
+		path, err := reader.findCgroup(dockerID)
+		if err != nil {
+			return nil, err
+		}
+		cgroup = &containerCgroup{path: path}
+		reader.cgroups[dockerID] = cgroup
+	}
+
+	stats := &types.StatsJSON{}
+	stats.ID = dockerID
+	stats.Read = time.Now()
+	var err error
+	if reader.unified {
+		err = reader.readV2(cgroup.path, stats)
+	} else {
+		err = reader.readV1(cgroup.path, stats)
+	}
+	if err != nil {
+		delete(reader.cgroups, dockerID)
+		return nil, errors.Wrapf(err, "cgroup stats: unable to read the stats of container %s", dockerID)
+	}
+	stats.CPUStats.OnlineCPUs = uint32(numCores)
+	stats.Networks = reader.readNetworkStats(cgroup.path)
+	// Like docker, return the previous CPU stats along with the current ones
+	// so that the CPU usage can be computed from a single sample
+	if cgroup.previous != nil {
+		stats.PreRead = cgroup.previous.Read
+		stats.PreCPUStats = cgroup.previous.CPUStats
+	}
+	cgroup.previous = stats
+	return stats, nil
+}
+
+// forget implements containerStatsReader
+func (reader *cgroupStatsReader) forget(dockerID string) {
+	reader.lock.Lock()
+	defer reader.lock.Unlock()
+	delete(reader.cgroups, dockerID)
+}
+
+// hierarchy returns the directory of the hierarchy of a controller
+func (reader *cgroupStatsReader) hierarchy(controller string) string {
+	if reader.unified {
+		return reader.root
+	}
+	return filepath.Join(reader.root, controller)
+}
+
+// findCgroup looks for the cgroup of a container, breadth first, in the memory
+// hierarchy on cgroup v1 or in the unified one on cgroup v2. It's named after
+// the docker ID of the container with the cgroupfs driver, and
+// docker-<id>.scope with the systemd one.
+func (reader *cgroupStatsReader) findCgroup(dockerID string) (string, error) {
+	hierarchy := reader.hierarchy("memory")
+	names := map[string]bool{
+		dockerID:                        true,
+		"docker-" + dockerID + ".scope": true,
+	}
+	dirs := []string{""}
+	for depth := 0; depth < cgroupSearchDepth && len(dirs) > 0; depth++ {
+		var next []string
+		for _, dir := range dirs {
+			entries, err := ioutil.ReadDir(filepath.Join(hierarchy, dir))
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+				path := filepath.Join(dir, entry.Name())
+				if names[entry.Name()] {
+					return path, nil
+				}
+				next = append(next, path)
+			}
+		}
+		dirs = next
+	}
+	return "", errors.Errorf("cgroup stats: cgroup of container %s not found under %s", dockerID, hierarchy)
+}
+
+// readV1 reads the stats of a cgroup from the hierarchies of cgroup v1. The
+// blkio and pids stats are left out if their controllers aren't mounted.
+func (reader *cgroupStatsReader) readV1(path string, stats *types.StatsJSON) error {
+	cpuacct := filepath.Join(reader.hierarchy("cpuacct"), path)
+	var err error
+	if stats.CPUStats.CPUUsage.TotalUsage, err = readCgroupValue(filepath.Join(cpuacct, "cpuacct.usage")); err != nil {
+		return err
+	}
+	if stats.CPUStats.CPUUsage.PercpuUsage, err = readCgroupValues(filepath.Join(cpuacct, "cpuacct.usage_percpu")); err != nil {
+		return err
+	}
+
+	memory := filepath.Join(reader.hierarchy("memory"), path)
+	if stats.MemoryStats.Usage, err = readCgroupValue(filepath.Join(memory, "memory.usage_in_bytes")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.MaxUsage, err = readCgroupValue(filepath.Join(memory, "memory.max_usage_in_bytes")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Limit, err = readCgroupValue(filepath.Join(memory, "memory.limit_in_bytes")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Stats, err = readCgroupKeyValues(filepath.Join(memory, "memory.stat")); err != nil {
+		return err
+	}
+
+	// Like docker, fall back to the throttling stats when the IO scheduler
+	// doesn't account for the IO of the cgroup
+	blkio := filepath.Join(reader.hierarchy("blkio"), path)
+	for _, file := range []string{"blkio.io_service_bytes_recursive", "blkio.throttle.io_service_bytes_recursive",
+		"blkio.throttle.io_service_bytes"} {
+		entries, err := readBlkioV1(filepath.Join(blkio, file))
+		if err == nil && len(entries) > 0 {
+			stats.BlkioStats.IoServiceBytesRecursive = entries
+			break
+		}
+	}
+
+	readPidsStats(filepath.Join(reader.hierarchy("pids"), path), stats)
+	return nil
+}
+
+// readV2 reads the stats of a cgroup from the unified hierarchy of cgroup v2.
+// There are no per CPU usages on cgroup v2.
+func (reader *cgroupStatsReader) readV2(path string, stats *types.StatsJSON) error {
+	dir := filepath.Join(reader.root, path)
+	cpuStats, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	// The usages are in microseconds, and docker reports nanoseconds
+	stats.CPUStats.CPUUsage.TotalUsage = cpuStats["usage_usec"] * 1000
+	stats.CPUStats.CPUUsage.UsageInUsermode = cpuStats["user_usec"] * 1000
+	stats.CPUStats.CPUUsage.UsageInKernelmode = cpuStats["system_usec"] * 1000
+
+	if stats.MemoryStats.Usage, err = readCgroupValue(filepath.Join(dir, "memory.current")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Limit, err = readCgroupValue(filepath.Join(dir, "memory.max")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Stats, err = readCgroupKeyValues(filepath.Join(dir, "memory.stat")); err != nil {
+		return err
+	}
+
+	if entries, err := readIOStatV2(filepath.Join(dir, "io.stat")); err == nil {
+		stats.BlkioStats.IoServiceBytesRecursive = entries
+	}
+
+	readPidsStats(dir, stats)
+	return nil
+}
+
+// readNetworkStats reads the network stats of a cgroup from /proc/<pid>/net/dev
+// of the first of its processes that can be read. Cgroups don't account for
+// network usage, but the processes of a container share its network
+// namespace. It returns nil when no process can be read, such as when the
+// agent doesn't share the pid namespace of the containers.
+func (reader *cgroupStatsReader) readNetworkStats(path string) map[string]types.NetworkStats {
+	pids, err := readCgroupValues(filepath.Join(reader.hierarchy("memory"), path, cgroupProcsFile))
+	if err != nil {
+		return nil
+	}
+	for _, pid := range pids {
+		// Processes outside of the pid namespace of the agent are listed as 0
+		if pid == 0 {
+			continue
+		}
+		networks, err := readNetDev(filepath.Join(reader.procRoot, strconv.FormatUint(pid, 10), "net", "dev"))
+		if err == nil {
+			return networks
+		}
+	}
+	return nil
+}
+
+// readNetDev reads the stats of the network interfaces in /proc/<pid>/net/dev,
+// with two header lines and then lines like
+// "eth0: 1296 16 0 0 0 0 0 0 656 8 0 0 0 0 0 0" with 8 receive and 8 transmit
+// counters, starting with bytes, packets, errors and drops
+func readNetDev(path string) (map[string]types.NetworkStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	networks := make(map[string]types.NetworkStats)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		nameCounters := strings.SplitN(scanner.Text(), ":", 2)
+		if len(nameCounters) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(nameCounters[0])
+		fields := strings.Fields(nameCounters[1])
+		if name == loopbackInterface || len(fields) < 16 {
+			continue
+		}
+		counters := make([]uint64, len(fields))
+		for i, field := range fields {
+			if counters[i], err = strconv.ParseUint(field, 10, 64); err != nil {
+				return nil, errors.Wrapf(err, "cgroup stats: invalid counter of %s in %s", name, path)
+			}
+		}
+		networks[name] = types.NetworkStats{
+			RxBytes:   counters[0],
+			RxPackets: counters[1],
+			RxErrors:  counters[2],
+			RxDropped: counters[3],
+			TxBytes:   counters[8],
+			TxPackets: counters[9],
+			TxErrors:  counters[10],
+			TxDropped: counters[11],
+		}
+	}
+	return networks, scanner.Err()
+}
+
+// readPidsStats reads the number of pids in a cgroup and their limit, if the
+// pids controller is enabled for it
+func readPidsStats(dir string, stats *types.StatsJSON) {
+	current, err := readCgroupValue(filepath.Join(dir, "pids.current"))
+	if err != nil {
+		return
+	}
+	stats.PidsStats.Current = current
+	if limit, err := readCgroupValue(filepath.Join(dir, "pids.max")); err == nil {
+		stats.PidsStats.Limit = limit
+	}
+}
+
+// readCgroupValue reads a file holding a single value. A limit of "max" is
+// read as 0, which is how docker reports that there's no limit.
+func readCgroupValue(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == cgroupUnlimited {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cgroup stats: invalid value in %s", path)
+	}
+	return parsed, nil
+}
+
+// readCgroupValues reads a file holding values separated by spaces
+func readCgroupValues(path string) ([]uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values []uint64
+	for _, field := range strings.Fields(string(data)) {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: invalid value in %s", path)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// readCgroupKeyValues reads a file holding a key and a value on each line,
+// like memory.stat and cpu.stat
+func readCgroupKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: invalid value of %s in %s", fields[0], path)
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+// readBlkioV1 reads a blkio file of cgroup v1, with lines like "8:0 Read 4096"
+// and a last line with the total
+func readBlkioV1(path string) ([]types.BlkioStatEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []types.BlkioStatEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		major, minor, err := parseDevice(fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: invalid device in %s", path)
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: invalid value in %s", path)
+		}
+		entries = append(entries, types.BlkioStatEntry{Major: major, Minor: minor, Op: fields[1], Value: value})
+	}
+	return entries, scanner.Err()
+}
+
+// readIOStatV2 reads the io.stat file of cgroup v2, with lines like
+// "8:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0", into the entries
+// docker reports for cgroup v1
+func readIOStatV2(path string) ([]types.BlkioStatEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ops := map[string]string{"rbytes": "Read", "wbytes": "Write"}
+	var entries []types.BlkioStatEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		major, minor, err := parseDevice(fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cgroup stats: invalid device in %s", path)
+		}
+		for _, field := range fields[1:] {
+			keyValue := strings.SplitN(field, "=", 2)
+			op, ok := ops[keyValue[0]]
+			if !ok || len(keyValue) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(keyValue[1], 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cgroup stats: invalid value of %s in %s", keyValue[0], path)
+			}
+			entries = append(entries, types.BlkioStatEntry{Major: major, Minor: minor, Op: op, Value: value})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseDevice parses the major and minor numbers of a device, like "8:0"
+func parseDevice(device string) (uint64, uint64, error) {
+	numbers := strings.SplitN(device, ":", 2)
+	if len(numbers) != 2 {
+		return 0, 0, errors.Errorf("expected major:minor, got %s", device)
+	}
+	major, err := strconv.ParseUint(numbers[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	minor, err := strconv.ParseUint(numbers[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
@@ -0,0 +1,208 @@
+// +build linux,unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCgroupDockerID = "0123456789abcdef"
+
+// writeCgroupFiles writes files with their contents in a directory of a fake
+// cgroup hierarchy
+func writeCgroupFiles(t *testing.T, dir string, files map[string]string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+}
+
+func TestCgroupStatsReaderV1(t *testing.T) {
+	numCores = 2
+	root, err := ioutil.TempDir("", "cgroup-v1")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join("ecs", "task-id", testCgroupDockerID)
+	writeCgroupFiles(t, filepath.Join(root, "cpuacct", path), map[string]string{
+		"cpuacct.usage":        "3000\n",
+		"cpuacct.usage_percpu": "1000 2000 \n",
+	})
+	writeCgroupFiles(t, filepath.Join(root, "memory", path), map[string]string{
+		"memory.usage_in_bytes":     "8192\n",
+		"memory.max_usage_in_bytes": "16384\n",
+		"memory.limit_in_bytes":     "9223372036854771712\n",
+		"memory.stat":               "cache 4096\nrss 4096\n",
+		cgroupProcsFile:             "0\n42\n43\n",
+	})
+	writeCgroupFiles(t, filepath.Join(root, "blkio", path), map[string]string{
+		"blkio.io_service_bytes_recursive":          "Total 0\n",
+		"blkio.throttle.io_service_bytes_recursive": "8:0 Read 100\n8:0 Write 200\n8:0 Total 300\nTotal 300\n",
+	})
+	writeCgroupFiles(t, filepath.Join(root, "pids", path), map[string]string{
+		"pids.current": "3\n",
+		"pids.max":     "max\n",
+	})
+
+	procRoot := filepath.Join(root, "proc")
+	writeCgroupFiles(t, filepath.Join(procRoot, "42", "net"), map[string]string{"dev": `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    1296      16    1    2    0     0          0         0      656       8    3    4    0     0       0          0
+`})
+
+	reader, err := newCgroupStatsReader(root)
+	require.NoError(t, err)
+	reader.(*cgroupStatsReader).procRoot = procRoot
+	stats, err := reader.containerStats(testCgroupDockerID)
+	require.NoError(t, err)
+	assert.Equal(t, testCgroupDockerID, stats.ID)
+	assert.Equal(t, uint64(3000), stats.CPUStats.CPUUsage.TotalUsage)
+	assert.Equal(t, []uint64{1000, 2000}, stats.CPUStats.CPUUsage.PercpuUsage)
+	assert.Equal(t, uint32(2), stats.CPUStats.OnlineCPUs)
+	assert.Equal(t, uint64(8192), stats.MemoryStats.Usage)
+	assert.Equal(t, uint64(16384), stats.MemoryStats.MaxUsage)
+	assert.Equal(t, uint64(9223372036854771712), stats.MemoryStats.Limit)
+	assert.Equal(t, map[string]uint64{"cache": 4096, "rss": 4096}, stats.MemoryStats.Stats)
+	assert.Equal(t, []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 100},
+		{Major: 8, Minor: 0, Op: "Write", Value: 200},
+		{Major: 8, Minor: 0, Op: "Total", Value: 300},
+	}, stats.BlkioStats.IoServiceBytesRecursive, "The throttling stats should be read when the IO scheduler doesn't account for any IO")
+	assert.Equal(t, types.PidsStats{Current: 3}, stats.PidsStats)
+	assert.True(t, stats.PreRead.IsZero(), "There are no previous stats on the first read")
+	assert.Equal(t, map[string]types.NetworkStats{"eth0": {
+		RxBytes: 1296, RxPackets: 16, RxErrors: 1, RxDropped: 2,
+		TxBytes: 656, TxPackets: 8, TxErrors: 3, TxDropped: 4,
+	}}, stats.Networks, "The network stats should be read from the first process that can be read, without the loopback")
+
+	containerStats, err := dockerStatsToContainerStats(stats)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4096), containerStats.memoryUsage)
+	assert.Equal(t, uint64(100), containerStats.storageReadBytes)
+	assert.Equal(t, uint64(200), containerStats.storageWriteBytes)
+
+	writeCgroupFiles(t, filepath.Join(root, "cpuacct", path), map[string]string{"cpuacct.usage": "5000\n"})
+	next, err := reader.containerStats(testCgroupDockerID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5000), next.CPUStats.CPUUsage.TotalUsage)
+	assert.Equal(t, stats.Read, next.PreRead)
+	assert.Equal(t, stats.CPUStats, next.PreCPUStats)
+}
+
+func TestCgroupStatsReaderV2(t *testing.T) {
+	numCores = 2
+	root, err := ioutil.TempDir("", "cgroup-v2")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeCgroupFiles(t, root, map[string]string{cgroupV2ControllersFile: "cpu io memory pids\n"})
+	writeCgroupFiles(t, filepath.Join(root, "system.slice", "docker-"+testCgroupDockerID+".scope"), map[string]string{
+		"cpu.stat":       "usage_usec 3\nuser_usec 2\nsystem_usec 1\n",
+		"memory.current": "8192\n",
+		"memory.max":     "max\n",
+		"memory.stat":    "anon 4096\nfile 4096\ninactive_file 1024\n",
+		"io.stat":        "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n",
+		"pids.current":   "3\n",
+		"pids.max":       "100\n",
+	})
+
+	reader, err := newCgroupStatsReader(root)
+	require.NoError(t, err)
+	stats, err := reader.containerStats(testCgroupDockerID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3000), stats.CPUStats.CPUUsage.TotalUsage)
+	assert.Equal(t, uint64(2000), stats.CPUStats.CPUUsage.UsageInUsermode)
+	assert.Equal(t, uint64(1000), stats.CPUStats.CPUUsage.UsageInKernelmode)
+	assert.Empty(t, stats.CPUStats.CPUUsage.PercpuUsage)
+	assert.Equal(t, uint64(8192), stats.MemoryStats.Usage)
+	assert.Equal(t, uint64(0), stats.MemoryStats.Limit)
+	assert.Equal(t, []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 100},
+		{Major: 8, Minor: 0, Op: "Write", Value: 200},
+	}, stats.BlkioStats.IoServiceBytesRecursive)
+	assert.Equal(t, types.PidsStats{Current: 3, Limit: 100}, stats.PidsStats)
+	assert.Nil(t, stats.Networks, "There are no network stats without processes to read them from")
+
+	containerStats, err := dockerStatsToContainerStats(stats)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1500), containerStats.cpuUsage)
+	assert.Equal(t, uint64(7168), containerStats.memoryUsage)
+	assert.Equal(t, uint64(100), containerStats.storageReadBytes)
+	assert.Equal(t, uint64(200), containerStats.storageWriteBytes)
+}
+
+func TestCgroupStatsReaderContainerNotFound(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup-v2")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writeCgroupFiles(t, root, map[string]string{cgroupV2ControllersFile: "cpu io memory pids\n"})
+
+	reader, err := newCgroupStatsReader(root)
+	require.NoError(t, err)
+	_, err = reader.containerStats(testCgroupDockerID)
+	assert.Error(t, err)
+
+	// Cgroups deeper than the search depth aren't found
+	writeCgroupFiles(t, filepath.Join(root, "a", "b", "c", testCgroupDockerID), map[string]string{
+		"cpu.stat":       "usage_usec 3\n",
+		"memory.current": "8192\n",
+		"memory.max":     "max\n",
+		"memory.stat":    "anon 4096\n",
+	})
+	_, err = reader.containerStats(testCgroupDockerID)
+	assert.Error(t, err)
+}
+
+func TestCgroupStatsReaderRemovedCgroup(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup-v2")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writeCgroupFiles(t, root, map[string]string{cgroupV2ControllersFile: "cpu io memory pids\n"})
+	dir := filepath.Join(root, "docker", testCgroupDockerID)
+	writeCgroupFiles(t, dir, map[string]string{
+		"cpu.stat":       "usage_usec 3\n",
+		"memory.current": "8192\n",
+		"memory.max":     "max\n",
+		"memory.stat":    "anon 4096\n",
+	})
+
+	reader, err := newCgroupStatsReader(root)
+	require.NoError(t, err)
+	_, err = reader.containerStats(testCgroupDockerID)
+	require.NoError(t, err)
+
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = reader.containerStats(testCgroupDockerID)
+	assert.Error(t, err)
+	assert.Empty(t, reader.(*cgroupStatsReader).cgroups, "The cgroup should be looked for again on the next read")
+}
+
+func TestNewCgroupStatsReaderNoHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	_, err = newCgroupStatsReader(root)
+	assert.Error(t, err)
+}
@@ -48,19 +48,34 @@ func newStatsContainer(dockerID string, client dockerapi.DockerClient, resolver
 }
 
 func (container *StatsContainer) StartStatsCollection() {
-	// queue will be sized to hold enough stats for 4 publishing intervals.
-	var queueSize int
-	if container.config != nil && container.config.PollMetrics {
-		pollingInterval := container.config.PollingMetricsWaitDuration.Seconds()
-		queueSize = int(config.DefaultContainerMetricsPublishInterval.Seconds() / pollingInterval * 4)
-	} else {
-		// for streaming stats we assume 1 stat every second
-		queueSize = int(config.DefaultContainerMetricsPublishInterval.Seconds() * 4)
-	}
-	container.statsQueue = NewQueue(queueSize)
+	container.initStatsQueue()
 	go container.collect()
 }
 
+// initStatsQueue creates the queue of the stats of the container, without
+// collecting any. It's used directly when the stats are read by the engine.
+func (container *StatsContainer) initStatsQueue() {
+	// queue will be sized to hold enough stats for the window.
+	queueSize := int(statsWindow.Seconds() / statsInterval(container.config).Seconds())
+	container.statsQueue = NewQueue(queueSize)
+	if container.config != nil && container.config.StatsDistributionsEnabled {
+		container.statsQueue.enableDistributions()
+	}
+}
+
+// statsWindow is how far back the stats of a container are kept, which is 4
+// publishing intervals
+const statsWindow = 4 * config.DefaultContainerMetricsPublishInterval
+
+// statsInterval returns how often a stat is expected for each container
+func statsInterval(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.PollMetrics {
+		return cfg.PollingMetricsWaitDuration
+	}
+	// for streaming stats we assume 1 stat every second
+	return time.Second
+}
+
 func (container *StatsContainer) StopStatsCollection() {
 	container.cancel()
 }
@@ -0,0 +1,262 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	"github.com/aws/amazon-ecs-agent/agent/dockerclient"
+	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// ContainerDiskUsage is the disk used by a container when it was last sampled
+type ContainerDiskUsage struct {
+	// WritableLayerBytes is the size of the writable layer of the container
+	WritableLayerBytes int64 `json:"writable_layer_bytes"`
+	// VolumeBytes are the sizes of the task scoped volumes the container
+	// mounts, by the name of the volume in the task definition. Volumes whose
+	// driver doesn't report their size are left out.
+	VolumeBytes map[string]int64 `json:"volume_bytes,omitempty"`
+	SampledAt   time.Time        `json:"sampled_at"`
+}
+
+// TaskDiskUsage is the disk used by the containers of a task when it was last
+// sampled
+type TaskDiskUsage struct {
+	TaskARN string `json:"task_arn"`
+	Family  string `json:"family"`
+	// Containers maps the docker IDs of the containers of the task to their
+	// disk usage
+	Containers map[string]*ContainerDiskUsage `json:"containers"`
+	// TotalBytes is the size of the writable layers of the containers and of
+	// the task scoped volumes they mount, counting a shared volume once
+	TotalBytes int64 `json:"total_bytes"`
+	// WarningThresholdBytes is the disk usage above which a warning is logged
+	// for the task, if one is configured for its family
+	WarningThresholdBytes    int64     `json:"warning_threshold_bytes,omitempty"`
+	WarningThresholdExceeded bool      `json:"warning_threshold_exceeded,omitempty"`
+	SampledAt                time.Time `json:"sampled_at"`
+}
+
+// collectDiskUsage samples the disk usage of the watched containers on every
+// tick, until the context is done. A sample that takes longer than the
+// interval delays the next one instead of overlapping it.
+func (engine *DockerStatsEngine) collectDiskUsage(ctx context.Context) {
+	ticker := time.NewTicker(engine.config.ContainerDiskUsageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			engine.sampleDiskUsage(ctx)
+		}
+	}
+}
+
+// sampleDiskUsage samples the size of the writable layers of the watched
+// containers, and of the task scoped volumes they mount. The sizes come from
+// a single disk usage call to docker, made without the engine locked as the
+// daemon walks every writable layer and volume to answer it.
+func (engine *DockerStatsEngine) sampleDiskUsage(ctx context.Context) {
+	engine.lock.RLock()
+	idle := len(engine.tasksToContainers) == 0
+	engine.lock.RUnlock()
+	if idle {
+		return
+	}
+
+	diskUsage, err := engine.client.DiskUsage(ctx, dockerclient.DiskUsageTimeout)
+	if err != nil {
+		seelog.Warnf("Error sampling the disk usage of the containers: %v", err)
+		return
+	}
+	sampledAt := time.Now()
+	containerSizes := make(map[string]int64)
+	for _, container := range diskUsage.Containers {
+		containerSizes[container.ID] = container.SizeRw
+	}
+	volumeSizes := make(map[string]int64)
+	for _, volume := range diskUsage.Volumes {
+		// The size is -1 for volumes whose driver doesn't report it
+		if volume.UsageData != nil && volume.UsageData.Size >= 0 {
+			volumeSizes[volume.Name] = volume.UsageData.Size
+		}
+	}
+
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	tasksToDiskUsage := make(map[string]*TaskDiskUsage)
+	for taskARN, containerMap := range engine.tasksToContainers {
+		taskUsage := engine.taskDiskUsageUnsafe(taskARN, containerMap, containerSizes, volumeSizes)
+		if taskUsage == nil {
+			continue
+		}
+		taskUsage.SampledAt = sampledAt
+		for _, containerUsage := range taskUsage.Containers {
+			containerUsage.SampledAt = sampledAt
+		}
+		engine.checkDiskUsageThresholdUnsafe(taskUsage, engine.tasksToDiskUsage[taskARN])
+		tasksToDiskUsage[taskARN] = taskUsage
+	}
+	engine.tasksToDiskUsage = tasksToDiskUsage
+}
+
+// taskDiskUsageUnsafe adds up the disk usage of the containers of a task. It
+// returns nil if none of them were sized.
+func (engine *DockerStatsEngine) taskDiskUsageUnsafe(taskARN string,
+	containerMap map[string]*StatsContainer,
+	containerSizes map[string]int64,
+	volumeSizes map[string]int64) *TaskDiskUsage {
+	taskUsage := &TaskDiskUsage{
+		TaskARN:    taskARN,
+		Containers: make(map[string]*ContainerDiskUsage),
+	}
+	if definition, ok := engine.tasksToDefinitions[taskARN]; ok {
+		taskUsage.Family = definition.family
+	}
+	var taskVolumes map[string]string
+	countedVolumes := make(map[string]struct{})
+	for dockerID := range containerMap {
+		sizeRw, ok := containerSizes[dockerID]
+		if !ok {
+			// The container was removed since it was sized
+			continue
+		}
+		containerUsage := &ContainerDiskUsage{WritableLayerBytes: sizeRw}
+		taskUsage.Containers[dockerID] = containerUsage
+		taskUsage.TotalBytes += sizeRw
+
+		dockerContainer, err := engine.resolver.ResolveContainer(dockerID)
+		if err != nil {
+			seelog.Debugf("Could not map container ID to container, not sizing its volumes, container: %s, err: %v",
+				dockerID, err)
+			continue
+		}
+		if taskVolumes == nil {
+			task, err := engine.resolver.ResolveTask(dockerID)
+			if err != nil {
+				seelog.Debugf("Could not map container ID to task, not sizing its volumes, container: %s, err: %v",
+					dockerID, err)
+				continue
+			}
+			taskVolumes = taskScopedVolumes(task)
+		}
+		for _, mountPoint := range dockerContainer.Container.MountPoints {
+			volumeName, ok := taskVolumes[mountPoint.SourceVolume]
+			if !ok {
+				continue
+			}
+			size, ok := volumeSizes[volumeName]
+			if !ok {
+				continue
+			}
+			if containerUsage.VolumeBytes == nil {
+				containerUsage.VolumeBytes = make(map[string]int64)
+			}
+			containerUsage.VolumeBytes[mountPoint.SourceVolume] = size
+			if _, ok := countedVolumes[volumeName]; !ok {
+				countedVolumes[volumeName] = struct{}{}
+				taskUsage.TotalBytes += size
+			}
+		}
+	}
+	if len(taskUsage.Containers) == 0 {
+		return nil
+	}
+	return taskUsage
+}
+
+// taskScopedVolumes maps the names of the volumes of a task that are created
+// and removed with it to the names of their docker volumes. Bind mounts and
+// shared volumes aren't the task's to account for.
+func taskScopedVolumes(task *apitask.Task) map[string]string {
+	volumes := make(map[string]string)
+	for _, taskVolume := range task.Volumes {
+		switch volume := taskVolume.Volume.(type) {
+		case *taskresourcevolume.DockerVolumeConfig:
+			if volume.Scope == taskresourcevolume.TaskScope {
+				volumes[taskVolume.Name] = volume.DockerVolumeName
+			}
+		case *taskresourcevolume.LocalDockerVolume:
+			// Host volumes without a source path are docker volumes named
+			// after the task
+			volumes[taskVolume.Name] = volume.HostPath
+		}
+	}
+	return volumes
+}
+
+// checkDiskUsageThresholdUnsafe compares the disk usage of a task with the
+// warning threshold of its family, logging when the usage crosses it either
+// way rather than on every sample
+func (engine *DockerStatsEngine) checkDiskUsageThresholdUnsafe(taskUsage *TaskDiskUsage, previous *TaskDiskUsage) {
+	thresholdMiB, ok := engine.config.TaskDiskUsageWarningThresholds[taskUsage.Family]
+	if !ok {
+		return
+	}
+	taskUsage.WarningThresholdBytes = thresholdMiB * BytesInMiB
+	taskUsage.WarningThresholdExceeded = taskUsage.TotalBytes > taskUsage.WarningThresholdBytes
+	wasExceeded := previous != nil && previous.WarningThresholdExceeded
+	switch {
+	case taskUsage.WarningThresholdExceeded && !wasExceeded:
+		seelog.Warnf("Task %s is using %d MiB of disk, above the %d MiB warning threshold of the %s family",
+			taskUsage.TaskARN, taskUsage.TotalBytes/BytesInMiB, thresholdMiB, taskUsage.Family)
+	case !taskUsage.WarningThresholdExceeded && wasExceeded:
+		seelog.Infof("Task %s is using %d MiB of disk, back below the %d MiB warning threshold of the %s family",
+			taskUsage.TaskARN, taskUsage.TotalBytes/BytesInMiB, thresholdMiB, taskUsage.Family)
+	}
+}
+
+// ContainerDiskUsage returns the disk usage of a container when it was last
+// sampled, or nil if disk usage sampling isn't enabled or the container
+// hasn't been sampled yet
+func (engine *DockerStatsEngine) ContainerDiskUsage(taskARN string, containerID string) (*ContainerDiskUsage, error) {
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	containerIDToStatsContainer, ok := engine.tasksToContainers[taskARN]
+	if !ok {
+		return nil, errors.Errorf("stats engine: task '%s' for container '%s' not found",
+			taskARN, containerID)
+	}
+	if _, ok := containerIDToStatsContainer[containerID]; !ok {
+		return nil, errors.Errorf("stats engine: container not found: %s", containerID)
+	}
+	taskUsage, ok := engine.tasksToDiskUsage[taskARN]
+	if !ok {
+		return nil, nil
+	}
+	return taskUsage.Containers[containerID], nil
+}
+
+// TasksDiskUsage returns the disk usage of the watched tasks when they were
+// last sampled, sorted by task ARN. The returned values aren't modified by
+// later samples.
+func (engine *DockerStatsEngine) TasksDiskUsage() []*TaskDiskUsage {
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	tasks := make([]*TaskDiskUsage, 0, len(engine.tasksToDiskUsage))
+	for _, taskUsage := range engine.tasksToDiskUsage {
+		tasks = append(tasks, taskUsage)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskARN < tasks[j].TaskARN })
+	return tasks
+}
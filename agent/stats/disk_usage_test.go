@@ -0,0 +1,174 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	mock_dockerapi "github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi/mocks"
+	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	taskresourcevolume "github.com/aws/amazon-ecs-agent/agent/taskresource/volume"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDiskUsageTestEngine returns an engine watching the containers of a task
+// that mounts task scoped, shared and host volumes
+func newDiskUsageTestEngine(t *testing.T, ctrl *gomock.Controller) (*DockerStatsEngine, *mock_dockerapi.MockDockerClient) {
+	task := &apitask.Task{
+		Arn:    "t1",
+		Family: "web",
+		Volumes: []apitask.TaskVolume{
+			{Name: "scratch", Volume: &taskresourcevolume.DockerVolumeConfig{
+				Scope: taskresourcevolume.TaskScope, DockerVolumeName: "ecs-web-1-scratch"}},
+			{Name: "shared", Volume: &taskresourcevolume.DockerVolumeConfig{
+				Scope: taskresourcevolume.SharedScope, DockerVolumeName: "shared"}},
+			{Name: "empty", Volume: &taskresourcevolume.LocalDockerVolume{HostPath: "ecs-web-1-empty"}},
+			{Name: "logs", Volume: &taskresourcevolume.FSHostVolume{FSSourcePath: "/var/log"}},
+		},
+	}
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	resolver.EXPECT().ResolveTask(gomock.Any()).AnyTimes().Return(task, nil)
+	resolver.EXPECT().ResolveContainer("c1").AnyTimes().Return(&apicontainer.DockerContainer{
+		DockerID: "c1",
+		Container: &apicontainer.Container{MountPoints: []apicontainer.MountPoint{
+			{SourceVolume: "scratch"}, {SourceVolume: "shared"}, {SourceVolume: "empty"}, {SourceVolume: "logs"},
+		}},
+	}, nil)
+	resolver.EXPECT().ResolveContainer("c2").AnyTimes().Return(&apicontainer.DockerContainer{
+		DockerID:  "c2",
+		Container: &apicontainer.Container{MountPoints: []apicontainer.MountPoint{{SourceVolume: "scratch"}}},
+	}, nil)
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+
+	testConfig := cfg
+	engine := NewDockerStatsEngine(&testConfig, client, eventStream(t.Name()))
+	engine.resolver = resolver
+	engine.tasksToDefinitions["t1"] = &taskDefinition{family: "web", version: "1"}
+	addTestStatsContainer(engine, "t1", "c1", "app", "bridge", 0)
+	addTestStatsContainer(engine, "t1", "c2", "sidecar", "bridge", 0)
+	return engine, client
+}
+
+// newTestDiskUsage returns the disk usage docker reports for the containers
+// and volumes of the test task
+func newTestDiskUsage(scratchSize int64) types.DiskUsage {
+	return types.DiskUsage{
+		Containers: []*types.Container{{ID: "c1", SizeRw: 100}, {ID: "c2", SizeRw: 200}, {ID: "other", SizeRw: 400}},
+		Volumes: []*types.Volume{
+			{Name: "ecs-web-1-scratch", UsageData: &types.VolumeUsageData{Size: scratchSize}},
+			{Name: "shared", UsageData: &types.VolumeUsageData{Size: 5000}},
+			{Name: "ecs-web-1-empty", UsageData: &types.VolumeUsageData{Size: 10}},
+		},
+	}
+}
+
+func TestSampleDiskUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	engine, client := newDiskUsageTestEngine(t, ctrl)
+	addTestStatsContainer(engine, "t1", "removed", "removed", "bridge", 0)
+
+	usage, err := engine.ContainerDiskUsage("t1", "c1")
+	require.NoError(t, err)
+	assert.Nil(t, usage, "There's no disk usage before the first sample")
+
+	client.EXPECT().DiskUsage(gomock.Any(), gomock.Any()).Return(newTestDiskUsage(1000), nil)
+	engine.sampleDiskUsage(context.TODO())
+
+	usage, err = engine.ContainerDiskUsage("t1", "c1")
+	require.NoError(t, err)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(100), usage.WritableLayerBytes)
+	assert.Equal(t, map[string]int64{"scratch": 1000, "empty": 10}, usage.VolumeBytes,
+		"Only the task scoped volumes should be sized")
+	assert.False(t, usage.SampledAt.IsZero())
+
+	usage, err = engine.ContainerDiskUsage("t1", "removed")
+	require.NoError(t, err)
+	assert.Nil(t, usage, "Containers docker doesn't know of should not be sized")
+	_, err = engine.ContainerDiskUsage("t1", "unknown")
+	assert.Error(t, err)
+	_, err = engine.ContainerDiskUsage("t2", "c1")
+	assert.Error(t, err)
+
+	tasks := engine.TasksDiskUsage()
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "t1", tasks[0].TaskARN)
+	assert.Equal(t, "web", tasks[0].Family)
+	assert.Len(t, tasks[0].Containers, 2)
+	assert.Equal(t, int64(100+200+1000+10), tasks[0].TotalBytes, "Volumes mounted by several containers should be counted once")
+	assert.Zero(t, tasks[0].WarningThresholdBytes)
+
+	for _, container := range engine.tasksToContainers["t1"] {
+		container.ctx, container.cancel = context.WithCancel(context.TODO())
+		engine.doRemoveContainerUnsafe(container, "t1")
+	}
+	assert.Empty(t, engine.TasksDiskUsage())
+}
+
+func TestSampleDiskUsageError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	engine, client := newDiskUsageTestEngine(t, ctrl)
+
+	client.EXPECT().DiskUsage(gomock.Any(), gomock.Any()).Return(newTestDiskUsage(1000), nil)
+	engine.sampleDiskUsage(context.TODO())
+	client.EXPECT().DiskUsage(gomock.Any(), gomock.Any()).Return(types.DiskUsage{}, errors.New("timeout"))
+	engine.sampleDiskUsage(context.TODO())
+
+	tasks := engine.TasksDiskUsage()
+	require.Len(t, tasks, 1, "The last sample should be kept when sampling fails")
+	assert.Equal(t, int64(1310), tasks[0].TotalBytes)
+}
+
+func TestSampleDiskUsageIdle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_dockerapi.NewMockDockerClient(ctrl)
+	engine := NewDockerStatsEngine(&cfg, client, eventStream(t.Name()))
+
+	// Docker isn't asked to size anything when no container is watched
+	engine.sampleDiskUsage(context.TODO())
+	assert.Empty(t, engine.TasksDiskUsage())
+}
+
+func TestDiskUsageWarningThreshold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	engine, client := newDiskUsageTestEngine(t, ctrl)
+	engine.config.TaskDiskUsageWarningThresholds = map[string]int64{"web": 1, "other": 10}
+
+	client.EXPECT().DiskUsage(gomock.Any(), gomock.Any()).Return(newTestDiskUsage(2*BytesInMiB), nil)
+	engine.sampleDiskUsage(context.TODO())
+	tasks := engine.TasksDiskUsage()
+	require.Len(t, tasks, 1)
+	assert.Equal(t, int64(BytesInMiB), tasks[0].WarningThresholdBytes)
+	assert.True(t, tasks[0].WarningThresholdExceeded)
+
+	client.EXPECT().DiskUsage(gomock.Any(), gomock.Any()).Return(newTestDiskUsage(1000), nil)
+	engine.sampleDiskUsage(context.TODO())
+	tasks = engine.TasksDiskUsage()
+	require.Len(t, tasks, 1)
+	assert.Equal(t, int64(BytesInMiB), tasks[0].WarningThresholdBytes)
+	assert.False(t, tasks[0].WarningThresholdExceeded)
+}
@@ -0,0 +1,160 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+)
+
+var (
+	// CPUUsageBuckets are the upper bounds of the buckets of the histograms of
+	// CPU usage, in percent of the instance's cores. The histograms are of the
+	// usage in percent of a core, like docker stats reports it, so the bounds
+	// are scaled by the number of cores for containers using several of them.
+	CPUUsageBuckets = []float64{1, 2.5, 5, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	// MemoryUsageBuckets are the upper bounds of the buckets of the histograms
+	// of memory usage, in MiB
+	MemoryUsageBuckets = []float64{16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
+)
+
+// UsageDistributions are the distributions of the CPU and memory usage of a
+// container over the samples in its stats queue.
+type UsageDistributions struct {
+	CPUUsagePerc      *UsageDistribution `json:"cpu_usage_percent,omitempty"`
+	MemoryUsageInMegs *UsageDistribution `json:"memory_usage_mib,omitempty"`
+}
+
+// UsageDistribution is the distribution of a stat over a window of samples.
+// The percentiles are estimated from the histogram, by interpolating linearly
+// within the bucket they fall in. Percentiles that fall above the last bucket
+// are reported as its upper bound.
+type UsageDistribution struct {
+	P50         float64 `json:"p50"`
+	P90         float64 `json:"p90"`
+	P99         float64 `json:"p99"`
+	SampleCount uint64  `json:"sample_count"`
+	Sum         float64 `json:"sum"`
+	// Buckets are cumulative: each counts the samples that are less than or
+	// equal to its upper bound. Samples above the last upper bound are only
+	// counted in SampleCount.
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket is the number of samples less than or equal to an upper bound
+type HistogramBucket struct {
+	UpperBound float64 `json:"upper_bound"`
+	Count      uint64  `json:"count"`
+}
+
+// windowHistogram is a histogram of the samples in a window. Samples are
+// added as they arrive and removed as they leave the window, so that the
+// distribution is never computed from the whole window at once.
+type windowHistogram struct {
+	upperBounds []float64
+	// counts are the number of samples in each bucket, with the samples above
+	// the last upper bound in the last count
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newWindowHistogram(upperBounds []float64) *windowHistogram {
+	return &windowHistogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)+1),
+	}
+}
+
+// scaleBuckets returns the upper bounds multiplied by a factor
+func scaleBuckets(upperBounds []float64, factor float64) []float64 {
+	scaled := make([]float64, len(upperBounds))
+	for i, upperBound := range upperBounds {
+		scaled[i] = upperBound * factor
+	}
+	return scaled
+}
+
+func (histogram *windowHistogram) bucket(value float64) int {
+	for i, upperBound := range histogram.upperBounds {
+		if value <= upperBound {
+			return i
+		}
+	}
+	return len(histogram.upperBounds)
+}
+
+// add adds a sample that enters the window. NaN samples are ignored.
+func (histogram *windowHistogram) add(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	histogram.counts[histogram.bucket(value)]++
+	histogram.count++
+	histogram.sum += value
+}
+
+// remove removes a sample that was added, when it leaves the window
+func (histogram *windowHistogram) remove(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	bucket := histogram.bucket(value)
+	if histogram.counts[bucket] == 0 {
+		return
+	}
+	histogram.counts[bucket]--
+	histogram.count--
+	histogram.sum -= value
+	if histogram.count == 0 {
+		// Don't let rounding errors accumulate in the sum of an empty window
+		histogram.sum = 0
+	}
+}
+
+// distribution returns the distribution of the samples in the window, or nil
+// if there are none
+func (histogram *windowHistogram) distribution() *UsageDistribution {
+	if histogram.count == 0 {
+		return nil
+	}
+	distribution := &UsageDistribution{
+		P50:         histogram.percentile(0.5),
+		P90:         histogram.percentile(0.9),
+		P99:         histogram.percentile(0.99),
+		SampleCount: histogram.count,
+		Sum:         histogram.sum,
+		Buckets:     make([]HistogramBucket, len(histogram.upperBounds)),
+	}
+	var cumulative uint64
+	for i, upperBound := range histogram.upperBounds {
+		cumulative += histogram.counts[i]
+		distribution.Buckets[i] = HistogramBucket{UpperBound: upperBound, Count: cumulative}
+	}
+	return distribution
+}
+
+func (histogram *windowHistogram) percentile(quantile float64) float64 {
+	rank := quantile * float64(histogram.count)
+	var cumulative uint64
+	lowerBound := 0.0
+	for i, upperBound := range histogram.upperBounds {
+		count := histogram.counts[i]
+		if count > 0 && float64(cumulative+count) >= rank {
+			return lowerBound + (upperBound-lowerBound)*(rank-float64(cumulative))/float64(count)
+		}
+		cumulative += count
+		lowerBound = upperBound
+	}
+	return lowerBound
+}
@@ -0,0 +1,71 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowHistogram(t *testing.T) {
+	histogram := newWindowHistogram([]float64{10, 20, 40})
+	assert.Nil(t, histogram.distribution(), "No distribution of an empty window")
+
+	for _, value := range []float64{2, 4, 6, 8, 10, 12, 14, 16, 18, 30, math.NaN()} {
+		histogram.add(value)
+	}
+	distribution := histogram.distribution()
+	require.NotNil(t, distribution)
+	assert.Equal(t, uint64(10), distribution.SampleCount, "NaN samples should be ignored")
+	assert.Equal(t, float64(120), distribution.Sum)
+	assert.Equal(t, []HistogramBucket{{10, 5}, {20, 9}, {40, 10}}, distribution.Buckets)
+	// The 5th sample is at the top of the first bucket
+	assert.Equal(t, float64(10), distribution.P50)
+	// The 9th sample is at the top of the second bucket
+	assert.Equal(t, float64(20), distribution.P90)
+	// The 9.9th sample is 90% of the way through the third bucket
+	assert.InDelta(t, 38, distribution.P99, 1e-9)
+
+	histogram.remove(2)
+	histogram.remove(4)
+	histogram.remove(math.NaN())
+	distribution = histogram.distribution()
+	assert.Equal(t, uint64(8), distribution.SampleCount)
+	assert.Equal(t, float64(114), distribution.Sum)
+	assert.Equal(t, []HistogramBucket{{10, 3}, {20, 7}, {40, 8}}, distribution.Buckets)
+	// The 4th sample is a quarter of the way through the second bucket
+	assert.Equal(t, float64(12.5), distribution.P50)
+}
+
+func TestWindowHistogramOverflow(t *testing.T) {
+	histogram := newWindowHistogram([]float64{10})
+	histogram.add(5)
+	histogram.add(50)
+	histogram.add(500)
+	distribution := histogram.distribution()
+	assert.Equal(t, uint64(3), distribution.SampleCount)
+	assert.Equal(t, []HistogramBucket{{10, 1}}, distribution.Buckets)
+	assert.Equal(t, float64(10), distribution.P90, "Percentiles above the last bucket are its upper bound")
+
+	histogram.remove(5)
+	histogram.remove(50)
+	histogram.remove(500)
+	assert.Nil(t, histogram.distribution())
+	assert.Zero(t, histogram.sum)
+}
@@ -66,7 +66,18 @@ type DockerContainerMetadataResolver struct {
 type Engine interface {
 	GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error)
 	ContainerDockerStats(taskARN string, containerID string) (*types.StatsJSON, error)
+	ContainerUsageDistributions(taskARN string, containerID string) (*UsageDistributions, error)
 	GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error)
+	ContainerDiskUsage(taskARN string, containerID string) (*ContainerDiskUsage, error)
+}
+
+// containerStatsReader reads the stats of a container on demand, instead of
+// them being streamed from docker
+type containerStatsReader interface {
+	// containerStats reads the current stats of a container
+	containerStats(dockerID string) (*types.StatsJSON, error)
+	// forget drops what's kept about a container that's not watched anymore
+	forget(dockerID string)
 }
 
 // DockerStatsEngine is used to monitor docker container events and to report
@@ -87,6 +98,16 @@ type DockerStatsEngine struct {
 	tasksToHealthCheckContainers map[string]map[string]*StatsContainer
 	// tasksToDefinitions maps task arns to task definition name and family metadata objects.
 	tasksToDefinitions map[string]*taskDefinition
+	// cgroupStats is set when the stats of the containers are read from their
+	// cgroups on a single ticker instead of being streamed from docker
+	cgroupStats containerStatsReader
+	// savedStats are the stats saved before the agent restarted. They're
+	// restored into the queues of the containers found running when the
+	// engine is initialized.
+	savedStats *statsSnapshot
+	// tasksToDiskUsage maps task arns to the disk usage of their containers,
+	// as last sampled. It's replaced on every sample.
+	tasksToDiskUsage map[string]*TaskDiskUsage
 }
 
 // ResolveTask resolves the api task object, given container id.
@@ -125,6 +146,7 @@ func NewDockerStatsEngine(cfg *config.Config, client dockerapi.DockerClient, con
 		tasksToContainers:            make(map[string]map[string]*StatsContainer),
 		tasksToHealthCheckContainers: make(map[string]map[string]*StatsContainer),
 		tasksToDefinitions:           make(map[string]*taskDefinition),
+		tasksToDiskUsage:             make(map[string]*TaskDiskUsage),
 		containerChangeEventStream:   containerChangeEventStream,
 	}
 }
@@ -156,7 +178,57 @@ func (engine *DockerStatsEngine) addAndStartStatsContainer(containerID string) {
 		return
 	}
 
-	statsContainer.StartStatsCollection()
+	statsContainer.initStatsQueue()
+	engine.restoreStatsUnsafe(statsContainer)
+	if engine.cgroupStats == nil {
+		// Otherwise the stats are read by collectCgroupStats
+		go statsContainer.collect()
+	}
+}
+
+// collectCgroupStats reads the stats of all the watched containers from their
+// cgroups on every tick, until the context is done
+func (engine *DockerStatsEngine) collectCgroupStats(ctx context.Context) {
+	ticker := time.NewTicker(statsInterval(engine.config))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			engine.readCgroupStats()
+		}
+	}
+}
+
+// readCgroupStats reads the stats of all the watched containers from their
+// cgroups. The engine isn't locked while reading, so that a slow read doesn't
+// hold up the task metadata and telemetry requests.
+func (engine *DockerStatsEngine) readCgroupStats() {
+	engine.lock.RLock()
+	var containers []*StatsContainer
+	for _, containerMap := range engine.tasksToContainers {
+		for _, container := range containerMap {
+			containers = append(containers, container)
+		}
+	}
+	engine.lock.RUnlock()
+
+	for _, container := range containers {
+		if container.ctx.Err() != nil {
+			// The container was removed since
+			continue
+		}
+		dockerID := container.containerMetadata.DockerID
+		dockerStats, err := engine.cgroupStats.containerStats(dockerID)
+		if err != nil {
+			seelog.Debugf("Error reading cgroup stats of container %s: %v", dockerID, err)
+			continue
+		}
+		if err := container.statsQueue.Add(dockerStats); err != nil {
+			seelog.Warnf("Error converting stats for container %s: %v", dockerID, err)
+		}
+	}
 }
 
 // MustInit initializes fields of the DockerStatsEngine object.
@@ -170,11 +242,27 @@ func (engine *DockerStatsEngine) MustInit(ctx context.Context, taskEngine ecseng
 	engine.cluster = cluster
 	engine.containerInstanceArn = containerInstanceArn
 
-	var err error
-	engine.resolver, err = newDockerContainerMetadataResolver(taskEngine)
+	resolver, err := newDockerContainerMetadataResolver(taskEngine)
 	if err != nil {
 		return err
 	}
+	// The resolver is read by the Prometheus collector, which may be scraped
+	// before the engine is initialized
+	engine.lock.Lock()
+	engine.resolver = resolver
+	engine.lock.Unlock()
+
+	if engine.config.StatsSource == config.StatsSourceCgroup {
+		cgroupStats, err := newCgroupStatsReader(engine.config.CgroupPath)
+		if err != nil {
+			seelog.Warnf("Unable to read container stats from cgroups, falling back to docker stats streams: %v", err)
+		} else {
+			engine.cgroupStats = cgroupStats
+			go engine.collectCgroupStats(derivedCtx)
+		}
+	}
+
+	engine.savedStats = engine.loadSavedStats()
 
 	// Subscribe to the container change event stream
 	err = engine.containerChangeEventStream.Subscribe(containerChangeHandler, engine.handleDockerEvents)
@@ -186,6 +274,16 @@ func (engine *DockerStatsEngine) MustInit(ctx context.Context, taskEngine ecseng
 	if err != nil {
 		seelog.Warnf("Synchronize the container state failed, err: %v", err)
 	}
+	// The saved stats of containers that aren't running anymore are dropped
+	engine.lock.Lock()
+	engine.savedStats = nil
+	engine.lock.Unlock()
+	if engine.config.Checkpoint {
+		go engine.checkpointStats(derivedCtx)
+	}
+	if engine.config.ContainerDiskUsageInterval > 0 && !engine.config.DisableMetrics {
+		go engine.collectDiskUsage(derivedCtx)
+	}
 
 	go engine.waitToStop()
 	return nil
@@ -194,6 +292,9 @@ func (engine *DockerStatsEngine) MustInit(ctx context.Context, taskEngine ecseng
 // Shutdown cleans up the resources after the statas engine.
 func (engine *DockerStatsEngine) Shutdown() {
 	engine.stopEngine()
+	if err := engine.SaveStats(); err != nil {
+		seelog.Warnf("Error saving container stats: %v", err)
+	}
 	engine.Disable()
 }
 
@@ -636,6 +737,9 @@ func (engine *DockerStatsEngine) taskContainerMetricsUnsafe(taskArn string) ([]*
 func (engine *DockerStatsEngine) doRemoveContainerUnsafe(container *StatsContainer, taskArn string) {
 	container.StopStatsCollection()
 	dockerID := container.containerMetadata.DockerID
+	if engine.cgroupStats != nil {
+		engine.cgroupStats.forget(dockerID)
+	}
 	delete(engine.tasksToContainers[taskArn], dockerID)
 	seelog.Debugf("Deleted container from tasks, id: %s", dockerID)
 
@@ -645,6 +749,7 @@ func (engine *DockerStatsEngine) doRemoveContainerUnsafe(container *StatsContain
 		// No need to verify if the key exists in tasksToDefinitions.
 		// Delete will do nothing if the specified key doesn't exist.
 		delete(engine.tasksToDefinitions, taskArn)
+		delete(engine.tasksToDiskUsage, taskArn)
 		seelog.Debugf("Deleted task from tasks, arn: %s", taskArn)
 	}
 
@@ -687,6 +792,26 @@ func (engine *DockerStatsEngine) ContainerDockerStats(taskARN string, containerI
 	return container.statsQueue.GetLastStat(), nil
 }
 
+// ContainerUsageDistributions returns the distributions of the CPU and memory
+// usage of a container over its stats window, or nil if distributions aren't
+// enabled
+func (engine *DockerStatsEngine) ContainerUsageDistributions(taskARN string, containerID string) (*UsageDistributions, error) {
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	containerIDToStatsContainer, ok := engine.tasksToContainers[taskARN]
+	if !ok {
+		return nil, errors.Errorf("stats engine: task '%s' for container '%s' not found",
+			taskARN, containerID)
+	}
+
+	container, ok := containerIDToStatsContainer[containerID]
+	if !ok {
+		return nil, errors.Errorf("stats engine: container not found: %s", containerID)
+	}
+	return container.statsQueue.GetUsageDistributions(), nil
+}
+
 // newMetricsMetadata creates the singleton metadata object.
 func newMetricsMetadata(cluster *string, containerInstance *string) *ecstcs.MetricsMetadata {
 	return &ecstcs.MetricsMetadata{
@@ -501,3 +501,55 @@ func testNetworkModeStats(t *testing.T, netMode string, enis []*apieni.ENI, empt
 		}
 	}
 }
+
+// fakeStatsReader returns the stats of a container from a map
+type fakeStatsReader struct {
+	stats     map[string]*types.StatsJSON
+	forgotten []string
+}
+
+func (reader *fakeStatsReader) containerStats(dockerID string) (*types.StatsJSON, error) {
+	stats, ok := reader.stats[dockerID]
+	if !ok {
+		return nil, fmt.Errorf("no stats for %s", dockerID)
+	}
+	return stats, nil
+}
+
+func (reader *fakeStatsReader) forget(dockerID string) {
+	reader.forgotten = append(reader.forgotten, dockerID)
+}
+
+func TestReadCgroupStats(t *testing.T) {
+	numCores = 4
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestReadCgroupStats"))
+	stats := &types.StatsJSON{}
+	stats.Read = time.Now()
+	stats.CPUStats.CPUUsage.TotalUsage = 4000
+	stats.CPUStats.OnlineCPUs = 4
+	stats.MemoryStats.Usage = 100 * BytesInMiB
+	stats.Networks = map[string]types.NetworkStats{"eth0": {RxBytes: 1024, TxBytes: 2048}}
+	reader := &fakeStatsReader{stats: map[string]*types.StatsJSON{"c1": stats, "c2": stats}}
+	engine.cgroupStats = reader
+
+	running := addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 0)
+	running.ctx, running.cancel = context.WithCancel(context.TODO())
+	removed := addTestStatsContainer(engine, "t1", "c2", "db", "bridge", 0)
+	removed.ctx, removed.cancel = context.WithCancel(context.TODO())
+	removed.cancel()
+	unreadable := addTestStatsContainer(engine, "t1", "c3", "cache", "bridge", 0)
+	unreadable.ctx, unreadable.cancel = context.WithCancel(context.TODO())
+
+	engine.readCgroupStats()
+	assert.Equal(t, stats, running.statsQueue.GetLastStat())
+	usage, ok := running.statsQueue.getLastUsageStats()
+	require.True(t, ok)
+	assert.Equal(t, uint32(100), usage.MemoryUsageInMegs)
+	require.NotNil(t, usage.NetworkStats, "Network stats should be read along with cgroups")
+	assert.Equal(t, uint64(1024), usage.NetworkStats.RxBytes)
+	assert.Nil(t, removed.statsQueue.GetLastStat(), "Removed containers should not be read")
+	assert.Nil(t, unreadable.statsQueue.GetLastStat())
+
+	engine.doRemoveContainerUnsafe(running, "t1")
+	assert.Equal(t, []string{"c1"}, reader.forgotten)
+}
@@ -21,6 +21,7 @@ package mock_stats
 import (
 	reflect "reflect"
 
+	stats "github.com/aws/amazon-ecs-agent/agent/stats"
 	ecstcs "github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	types "github.com/docker/docker/api/types"
 	gomock "github.com/golang/mock/gomock"
@@ -49,6 +50,21 @@ func (m *MockEngine) EXPECT() *MockEngineMockRecorder {
 	return m.recorder
 }
 
+// ContainerDiskUsage mocks base method
+func (m *MockEngine) ContainerDiskUsage(arg0, arg1 string) (*stats.ContainerDiskUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ContainerDiskUsage", arg0, arg1)
+	ret0, _ := ret[0].(*stats.ContainerDiskUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerDiskUsage indicates an expected call of ContainerDiskUsage
+func (mr *MockEngineMockRecorder) ContainerDiskUsage(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerDiskUsage", reflect.TypeOf((*MockEngine)(nil).ContainerDiskUsage), arg0, arg1)
+}
+
 // ContainerDockerStats mocks base method
 func (m *MockEngine) ContainerDockerStats(arg0, arg1 string) (*types.StatsJSON, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +80,21 @@ func (mr *MockEngineMockRecorder) ContainerDockerStats(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerDockerStats", reflect.TypeOf((*MockEngine)(nil).ContainerDockerStats), arg0, arg1)
 }
 
+// ContainerUsageDistributions mocks base method
+func (m *MockEngine) ContainerUsageDistributions(arg0, arg1 string) (*stats.UsageDistributions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ContainerUsageDistributions", arg0, arg1)
+	ret0, _ := ret[0].(*stats.UsageDistributions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ContainerUsageDistributions indicates an expected call of ContainerUsageDistributions
+func (mr *MockEngineMockRecorder) ContainerUsageDistributions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ContainerUsageDistributions", reflect.TypeOf((*MockEngine)(nil).ContainerUsageDistributions), arg0, arg1)
+}
+
 // GetInstanceMetrics mocks base method
 func (m *MockEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error) {
 	m.ctrl.T.Helper()
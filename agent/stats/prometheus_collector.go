@@ -0,0 +1,243 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ContainerMetricsNamespace is the Prometheus namespace of the resource
+	// usage metrics of the containers tracked by the stats engine
+	ContainerMetricsNamespace = "ContainerMetrics"
+
+	networkReceive  = "rx"
+	networkTransmit = "tx"
+)
+
+var containerLabels = []string{"TaskArn", "TaskFamily", "TaskRevision", "ContainerName"}
+
+// prometheusCollector exports the latest resource usage of each container
+// tracked by the stats engine. The metrics are read from the stats queues on
+// each scrape, so the series of a container go away as soon as the engine stops
+// tracking it.
+type prometheusCollector struct {
+	engine *DockerStatsEngine
+	// containerLimit is the maximum number of containers exported on a scrape
+	containerLimit int
+
+	cpuUsage          *prometheus.Desc
+	memoryUsage       *prometheus.Desc
+	storageReadBytes  *prometheus.Desc
+	storageWriteBytes *prometheus.Desc
+	networkBytes      *prometheus.Desc
+	networkPackets    *prometheus.Desc
+	networkErrors     *prometheus.Desc
+	networkDropped    *prometheus.Desc
+	omittedContainers *prometheus.Desc
+	// The distributions are only exported when they're enabled
+	cpuUsageDistribution    *prometheus.Desc
+	cpuUsagePercentiles     *prometheus.Desc
+	memoryUsageDistribution *prometheus.Desc
+	memoryUsagePercentiles  *prometheus.Desc
+}
+
+// exportedContainer is a container whose metrics are exported
+type exportedContainer struct {
+	container   *StatsContainer
+	taskARN     string
+	taskDef     taskDefinition
+	withNetwork bool
+}
+
+// labels returns the values of containerLabels for the container
+func (exported *exportedContainer) labels() []string {
+	return []string{exported.taskARN, exported.taskDef.family, exported.taskDef.version,
+		exported.container.containerMetadata.Name}
+}
+
+// NewPrometheusCollector creates a Prometheus collector of the resource usage
+// of at most containerLimit of the containers tracked by the engine.
+func NewPrometheusCollector(engine *DockerStatsEngine, containerLimit int) prometheus.Collector {
+	newDesc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(ContainerMetricsNamespace, "", name), help,
+			append(append([]string{}, containerLabels...), labels...), nil)
+	}
+	return &prometheusCollector{
+		engine:            engine,
+		containerLimit:    containerLimit,
+		cpuUsage:          newDesc("cpu_usage_percent", "CPU usage of the container since the previous sample, in percent of a core"),
+		memoryUsage:       newDesc("memory_usage_bytes", "Memory used by the container"),
+		storageReadBytes:  newDesc("storage_read_bytes_total", "Bytes read by the container from block devices"),
+		storageWriteBytes: newDesc("storage_write_bytes_total", "Bytes written by the container to block devices"),
+		networkBytes:      newDesc("network_bytes_total", "Bytes received or transmitted by the container, by direction", "Direction"),
+		networkPackets:    newDesc("network_packets_total", "Packets received or transmitted by the container, by direction", "Direction"),
+		networkErrors:     newDesc("network_errors_total", "Errors receiving or transmitting packets in the container, by direction", "Direction"),
+		networkDropped:    newDesc("network_dropped_total", "Packets dropped by the container, by direction", "Direction"),
+		omittedContainers: prometheus.NewDesc(prometheus.BuildFQName(ContainerMetricsNamespace, "", "omitted_containers"),
+			"Number of tracked containers whose metrics aren't exported because there are more than the limit", nil, nil),
+		cpuUsageDistribution:    newDesc("cpu_usage_percent_distribution", "Histogram of the CPU usage of the container over its stats window, in percent of a core"),
+		cpuUsagePercentiles:     newDesc("cpu_usage_percent_percentiles", "Estimated percentiles of the CPU usage of the container over its stats window, in percent of a core"),
+		memoryUsageDistribution: newDesc("memory_usage_mib_distribution", "Histogram of the memory used by the container over its stats window, in MiB"),
+		memoryUsagePercentiles:  newDesc("memory_usage_mib_percentiles", "Estimated percentiles of the memory used by the container over its stats window, in MiB"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (collector *prometheusCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.cpuUsage
+	descs <- collector.memoryUsage
+	descs <- collector.storageReadBytes
+	descs <- collector.storageWriteBytes
+	descs <- collector.networkBytes
+	descs <- collector.networkPackets
+	descs <- collector.networkErrors
+	descs <- collector.networkDropped
+	descs <- collector.omittedContainers
+	descs <- collector.cpuUsageDistribution
+	descs <- collector.cpuUsagePercentiles
+	descs <- collector.memoryUsageDistribution
+	descs <- collector.memoryUsagePercentiles
+}
+
+// Collect implements prometheus.Collector
+func (collector *prometheusCollector) Collect(metrics chan<- prometheus.Metric) {
+	containers, omitted := collector.exportedContainers()
+	for _, exported := range containers {
+		usage, ok := exported.container.statsQueue.getLastUsageStats()
+		if !ok {
+			continue
+		}
+		labels := exported.labels()
+		// There's no CPU usage until the second sample of the container. It's
+		// in percent of the instance's cores, and exported in percent of a core.
+		if !math.IsNaN(float64(usage.CPUUsagePerc)) {
+			metrics <- prometheus.MustNewConstMetric(collector.cpuUsage, prometheus.GaugeValue,
+				getCPUUsagePerc(&usage)*float64(numCores), labels...)
+		}
+		metrics <- prometheus.MustNewConstMetric(collector.memoryUsage, prometheus.GaugeValue,
+			float64(usage.memoryUsage), labels...)
+		metrics <- prometheus.MustNewConstMetric(collector.storageReadBytes, prometheus.CounterValue,
+			float64(usage.StorageReadBytes), labels...)
+		metrics <- prometheus.MustNewConstMetric(collector.storageWriteBytes, prometheus.CounterValue,
+			float64(usage.StorageWriteBytes), labels...)
+		if exported.withNetwork && usage.NetworkStats != nil {
+			collector.collectNetworkStats(metrics, usage.NetworkStats, labels)
+		}
+		if distributions := exported.container.statsQueue.GetUsageDistributions(); distributions != nil {
+			collectDistribution(metrics, collector.cpuUsageDistribution, collector.cpuUsagePercentiles,
+				distributions.CPUUsagePerc, labels)
+			collectDistribution(metrics, collector.memoryUsageDistribution, collector.memoryUsagePercentiles,
+				distributions.MemoryUsageInMegs, labels)
+		}
+	}
+	metrics <- prometheus.MustNewConstMetric(collector.omittedContainers, prometheus.GaugeValue, float64(omitted))
+}
+
+func (collector *prometheusCollector) collectNetworkStats(metrics chan<- prometheus.Metric,
+	networkStats *NetworkStats, labels []string) {
+	for _, metric := range []struct {
+		desc   *prometheus.Desc
+		rx, tx uint64
+	}{
+		{collector.networkBytes, networkStats.RxBytes, networkStats.TxBytes},
+		{collector.networkPackets, networkStats.RxPackets, networkStats.TxPackets},
+		{collector.networkErrors, networkStats.RxErrors, networkStats.TxErrors},
+		{collector.networkDropped, networkStats.RxDropped, networkStats.TxDropped},
+	} {
+		metrics <- prometheus.MustNewConstMetric(metric.desc, prometheus.CounterValue, float64(metric.rx),
+			append(labels, networkReceive)...)
+		metrics <- prometheus.MustNewConstMetric(metric.desc, prometheus.CounterValue, float64(metric.tx),
+			append(labels, networkTransmit)...)
+	}
+}
+
+// collectDistribution exports a distribution as a histogram, and its
+// percentiles as a summary
+func collectDistribution(metrics chan<- prometheus.Metric, histogramDesc, summaryDesc *prometheus.Desc,
+	distribution *UsageDistribution, labels []string) {
+	if distribution == nil {
+		return
+	}
+	buckets := make(map[float64]uint64, len(distribution.Buckets))
+	for _, bucket := range distribution.Buckets {
+		buckets[bucket.UpperBound] = bucket.Count
+	}
+	metrics <- prometheus.MustNewConstHistogram(histogramDesc, distribution.SampleCount, distribution.Sum,
+		buckets, labels...)
+	metrics <- prometheus.MustNewConstSummary(summaryDesc, distribution.SampleCount, distribution.Sum,
+		map[float64]float64{0.5: distribution.P50, 0.9: distribution.P90, 0.99: distribution.P99}, labels...)
+}
+
+// exportedContainers returns the containers tracked by the engine whose
+// metrics are exported, ordered by task and container name, and the number of
+// containers left out because of the limit
+func (collector *prometheusCollector) exportedContainers() ([]exportedContainer, int) {
+	engine := collector.engine
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	var containers []exportedContainer
+	for taskARN, containerMap := range engine.tasksToContainers {
+		var taskDef taskDefinition
+		if def, ok := engine.tasksToDefinitions[taskARN]; ok {
+			taskDef = *def
+		}
+		for _, container := range containerMap {
+			containers = append(containers, exportedContainer{
+				container: container,
+				taskARN:   taskARN,
+				taskDef:   taskDef,
+			})
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].taskARN != containers[j].taskARN {
+			return containers[i].taskARN < containers[j].taskARN
+		}
+		return containers[i].container.containerMetadata.Name < containers[j].container.containerMetadata.Name
+	})
+
+	omitted := 0
+	if len(containers) > collector.containerLimit {
+		omitted = len(containers) - collector.containerLimit
+		containers = containers[:collector.containerLimit]
+	}
+	for i := range containers {
+		containers[i].withNetwork = engine.reportsNetworkStatsUnsafe(containers[i].container)
+	}
+	return containers, omitted
+}
+
+// reportsNetworkStatsUnsafe returns true if the network stats of the container
+// are its own. Containers in awsvpc tasks share the network namespace of the
+// task, and containers in host and none network modes have no network stats
+// of their own.
+func (engine *DockerStatsEngine) reportsNetworkStatsUnsafe(container *StatsContainer) bool {
+	if container.containerMetadata.NetworkMode == hostNetworkMode ||
+		container.containerMetadata.NetworkMode == noneNetworkMode {
+		return false
+	}
+	if engine.resolver == nil {
+		return false
+	}
+	task, err := engine.resolver.ResolveTask(container.containerMetadata.DockerID)
+	if err != nil {
+		return false
+	}
+	return !task.IsNetworkModeAWSVPC()
+}
@@ -0,0 +1,173 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	apieni "github.com/aws/amazon-ecs-agent/agent/api/eni"
+	apitask "github.com/aws/amazon-ecs-agent/agent/api/task"
+	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatherContainerMetrics returns the values of the metrics exported by the
+// collector, keyed by metric name and label values
+func gatherContainerMetrics(t *testing.T, collector prometheus.Collector) map[string]float64 {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(collector))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += fmt.Sprintf(" %s=%s", label.GetName(), label.GetValue())
+			}
+			values[key] = metricValue(metric)
+		}
+	}
+	return values
+}
+
+// metricValue returns the value of a counter or gauge, or the sample count of
+// a histogram or summary
+func metricValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.GetCounter() != nil:
+		return metric.GetCounter().GetValue()
+	case metric.GetHistogram() != nil:
+		return float64(metric.GetHistogram().GetSampleCount())
+	case metric.GetSummary() != nil:
+		return float64(metric.GetSummary().GetSampleCount())
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func addTestStatsContainer(engine *DockerStatsEngine, taskARN, dockerID, name, networkMode string,
+	samples int) *StatsContainer {
+	container := &StatsContainer{
+		containerMetadata: &ContainerMetadata{DockerID: dockerID, Name: name, NetworkMode: networkMode},
+		statsQueue:        NewQueue(5),
+	}
+	for _, containerStats := range createFakeContainerStats()[:samples] {
+		container.statsQueue.add(containerStats)
+	}
+	if engine.tasksToContainers[taskARN] == nil {
+		engine.tasksToContainers[taskARN] = make(map[string]*StatsContainer)
+	}
+	engine.tasksToContainers[taskARN][dockerID] = container
+	return container
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	bridgeTask := &apitask.Task{Arn: "t1", Family: "f1", Version: "3"}
+	awsvpcTask := &apitask.Task{Arn: "t2", Family: "f2", Version: "1", ENIs: []*apieni.ENI{{ID: "eni-1"}}}
+	resolver.EXPECT().ResolveTask("c1").AnyTimes().Return(bridgeTask, nil)
+	resolver.EXPECT().ResolveTask("c2").AnyTimes().Return(bridgeTask, nil)
+	resolver.EXPECT().ResolveTask("c3").AnyTimes().Return(awsvpcTask, nil)
+	resolver.EXPECT().ResolveTask("c4").AnyTimes().Return(awsvpcTask, nil)
+
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestPrometheusCollector"))
+	engine.resolver = resolver
+	engine.tasksToDefinitions["t1"] = &taskDefinition{family: "f1", version: "3"}
+	engine.tasksToDefinitions["t2"] = &taskDefinition{family: "f2", version: "1"}
+	addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 2)
+	addTestStatsContainer(engine, "t1", "c2", "sidecar", hostNetworkMode, 1)
+	addTestStatsContainer(engine, "t2", "c3", "app", "awsvpc", 2)
+	addTestStatsContainer(engine, "t2", "c4", "starting", "awsvpc", 0)
+
+	metrics := gatherContainerMetrics(t, NewPrometheusCollector(engine, 10))
+	web := " ContainerName=web TaskArn=t1 TaskFamily=f1 TaskRevision=3"
+	sidecar := " ContainerName=sidecar TaskArn=t1 TaskFamily=f1 TaskRevision=3"
+	app := " ContainerName=app TaskArn=t2 TaskFamily=f2 TaskRevision=1"
+	require.Contains(t, metrics, "ContainerMetrics_cpu_usage_percent"+web)
+	webUsage, _ := engine.tasksToContainers["t1"]["c1"].statsQueue.getLastUsageStats()
+	assert.InDelta(t, float64(webUsage.CPUUsagePerc)*float64(numCores), metrics["ContainerMetrics_cpu_usage_percent"+web], 1e-3,
+		"CPU usage should be in percent of a core")
+	assert.Equal(t, float64(3649536), metrics["ContainerMetrics_memory_usage_bytes"+web])
+	assert.Equal(t, float64(300), metrics["ContainerMetrics_storage_read_bytes_total"+web])
+	assert.Equal(t, float64(400), metrics["ContainerMetrics_storage_write_bytes_total"+web])
+	webNetwork := func(direction string) string {
+		return " ContainerName=web Direction=" + direction + " TaskArn=t1 TaskFamily=f1 TaskRevision=3"
+	}
+	assert.Equal(t, float64(796), metrics["ContainerMetrics_network_bytes_total"+webNetwork("rx")])
+	assert.Equal(t, float64(8192), metrics["ContainerMetrics_network_bytes_total"+webNetwork("tx")])
+	assert.Equal(t, float64(60), metrics["ContainerMetrics_network_packets_total"+webNetwork("tx")])
+	assert.Equal(t, float64(6), metrics["ContainerMetrics_network_dropped_total"+webNetwork("rx")])
+
+	assert.NotContains(t, metrics, "ContainerMetrics_cpu_usage_percent"+sidecar, "No CPU usage from a single sample")
+	assert.Equal(t, float64(1839104), metrics["ContainerMetrics_memory_usage_bytes"+sidecar])
+
+	assert.Contains(t, metrics, "ContainerMetrics_memory_usage_bytes"+app)
+	for key := range metrics {
+		if strings.HasPrefix(key, "ContainerMetrics_network_") {
+			assert.Contains(t, key, "ContainerName=web",
+				"No network stats in host network mode or of containers in awsvpc tasks")
+		}
+		assert.NotContains(t, key, "ContainerName=starting", "No metrics of containers without stats")
+	}
+	assert.Equal(t, float64(0), metrics["ContainerMetrics_omitted_containers"])
+
+	// The series of a container go away when it's not tracked any more
+	delete(engine.tasksToContainers["t1"], "c1")
+	metrics = gatherContainerMetrics(t, NewPrometheusCollector(engine, 10))
+	assert.NotContains(t, metrics, "ContainerMetrics_memory_usage_bytes"+web)
+	assert.Contains(t, metrics, "ContainerMetrics_memory_usage_bytes"+sidecar)
+}
+
+func TestPrometheusCollectorContainerLimit(t *testing.T) {
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestPrometheusCollectorContainerLimit"))
+	addTestStatsContainer(engine, "t2", "c1", "a", "bridge", 2)
+	addTestStatsContainer(engine, "t1", "c2", "b", "bridge", 2)
+	addTestStatsContainer(engine, "t1", "c3", "a", "bridge", 2)
+
+	metrics := gatherContainerMetrics(t, NewPrometheusCollector(engine, 2))
+	assert.Contains(t, metrics, "ContainerMetrics_memory_usage_bytes ContainerName=a TaskArn=t1 TaskFamily= TaskRevision=")
+	assert.Contains(t, metrics, "ContainerMetrics_memory_usage_bytes ContainerName=b TaskArn=t1 TaskFamily= TaskRevision=")
+	assert.NotContains(t, metrics, "ContainerMetrics_memory_usage_bytes ContainerName=a TaskArn=t2 TaskFamily= TaskRevision=")
+	assert.Equal(t, float64(1), metrics["ContainerMetrics_omitted_containers"])
+}
+
+func TestPrometheusCollectorDistributions(t *testing.T) {
+	engine := NewDockerStatsEngine(&cfg, nil, eventStream("TestPrometheusCollectorDistributions"))
+	engine.tasksToDefinitions["t1"] = &taskDefinition{family: "f1", version: "3"}
+	addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 2).statsQueue.enableDistributions()
+	addTestStatsContainer(engine, "t1", "c2", "db", "bridge", 2)
+
+	metrics := gatherContainerMetrics(t, NewPrometheusCollector(engine, 10))
+	web := " ContainerName=web TaskArn=t1 TaskFamily=f1 TaskRevision=3"
+	assert.Equal(t, float64(1), metrics["ContainerMetrics_cpu_usage_percent_distribution"+web])
+	assert.Equal(t, float64(1), metrics["ContainerMetrics_cpu_usage_percent_percentiles"+web])
+	assert.Equal(t, float64(2), metrics["ContainerMetrics_memory_usage_mib_distribution"+web])
+	assert.Equal(t, float64(2), metrics["ContainerMetrics_memory_usage_mib_percentiles"+web])
+	for key := range metrics {
+		if strings.Contains(key, "_distribution") || strings.Contains(key, "_percentiles") {
+			assert.NotContains(t, key, "ContainerName=db", "No distributions unless they're enabled")
+		}
+	}
+}
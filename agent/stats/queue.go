@@ -35,6 +35,13 @@ type Queue struct {
 	buffer   []UsageStats
 	maxSize  int
 	lastStat *types.StatsJSON
+	// cpuHistogram and memoryHistogram are the distributions of the CPU and
+	// memory usage in the buffer. They're nil unless distributions are enabled.
+	cpuHistogram    *windowHistogram
+	memoryHistogram *windowHistogram
+	// cpuCores converts the CPU usage of the stats, in percent of the
+	// instance's cores, to the percent of a core cpuHistogram is in
+	cpuCores float64
 	lock     sync.RWMutex
 }
 
@@ -46,6 +53,36 @@ func NewQueue(maxSize int) *Queue {
 	}
 }
 
+// enableDistributions makes the queue keep the distributions of the CPU and
+// memory usage in its buffer up to date as stats are added
+func (queue *Queue) enableDistributions() {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	queue.cpuCores = float64(numCores)
+	queue.cpuHistogram = newWindowHistogram(scaleBuckets(CPUUsageBuckets, queue.cpuCores))
+	queue.memoryHistogram = newWindowHistogram(MemoryUsageBuckets)
+	for i := range queue.buffer {
+		queue.addToDistributionsUnsafe(&queue.buffer[i])
+	}
+}
+
+func (queue *Queue) addToDistributionsUnsafe(stat *UsageStats) {
+	if queue.cpuHistogram == nil {
+		return
+	}
+	queue.cpuHistogram.add(getCPUUsagePerc(stat) * queue.cpuCores)
+	queue.memoryHistogram.add(getMemoryUsagePerc(stat))
+}
+
+func (queue *Queue) removeFromDistributionsUnsafe(stat *UsageStats) {
+	if queue.cpuHistogram == nil {
+		return
+	}
+	queue.cpuHistogram.remove(getCPUUsagePerc(stat) * queue.cpuCores)
+	queue.memoryHistogram.remove(getMemoryUsagePerc(stat))
+}
+
 // Reset resets the queue's buffer so that only new metrics added after
 // this point will be sent to the backend when calling stat getter functions like
 // GetCPUStatsSet, GetMemoryStatSet, etc.
@@ -88,6 +125,7 @@ func (queue *Queue) add(rawStat *ContainerStats) {
 		NetworkStats:      rawStat.networkStats,
 		Timestamp:         rawStat.timestamp,
 		cpuUsage:          rawStat.cpuUsage,
+		memoryUsage:       rawStat.memoryUsage,
 		sent:              false,
 	}
 	if queueLength != 0 {
@@ -105,6 +143,7 @@ func (queue *Queue) add(rawStat *ContainerStats) {
 
 		if queueLength >= queue.maxSize {
 			// Remove first element if queue is full.
+			queue.removeFromDistributionsUnsafe(&queue.buffer[0])
 			queue.buffer = queue.buffer[1:queueLength]
 		}
 
@@ -116,6 +155,7 @@ func (queue *Queue) add(rawStat *ContainerStats) {
 	}
 
 	queue.buffer = append(queue.buffer, stat)
+	queue.addToDistributionsUnsafe(&stat)
 }
 
 // GetLastStat returns the last recorded raw statistics object from docker
@@ -126,6 +166,75 @@ func (queue *Queue) GetLastStat() *types.StatsJSON {
 	return queue.lastStat
 }
 
+// getLastUsageStats returns the most recent usage stats in the queue, and
+// false if the queue is empty
+func (queue *Queue) getLastUsageStats() (UsageStats, bool) {
+	queue.lock.RLock()
+	defer queue.lock.RUnlock()
+
+	if len(queue.buffer) == 0 {
+		return UsageStats{}, false
+	}
+	return queue.buffer[len(queue.buffer)-1], true
+}
+
+// snapshot returns the stats in the queue, to be saved, and the last raw stat
+func (queue *Queue) snapshot() ([]savedUsageStats, *types.StatsJSON) {
+	queue.lock.RLock()
+	defer queue.lock.RUnlock()
+
+	saved := make([]savedUsageStats, len(queue.buffer))
+	for i, stat := range queue.buffer {
+		saved[i] = newSavedUsageStats(stat)
+	}
+	return saved, queue.lastStat
+}
+
+// restore adds the saved stats taken after since to the queue, which is
+// expected to be empty, along with the last raw stat if it's recent enough.
+// Nothing is restored if the container was started after the stats were
+// saved, as they're from its previous run and its CPU usage started over. It
+// returns whether the stats were restored.
+func (queue *Queue) restore(saved []savedUsageStats, lastStat *types.StatsJSON, savedAt, startedAt, since time.Time) bool {
+	if startedAt.After(savedAt) {
+		return false
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	for _, savedStat := range saved {
+		if !savedStat.Timestamp.After(since) {
+			continue
+		}
+		if len(queue.buffer) >= queue.maxSize {
+			queue.removeFromDistributionsUnsafe(&queue.buffer[0])
+			queue.buffer = queue.buffer[1:]
+		}
+		stat := savedStat.usageStats()
+		queue.buffer = append(queue.buffer, stat)
+		queue.addToDistributionsUnsafe(&stat)
+	}
+	if lastStat != nil && lastStat.Read.After(since) {
+		queue.lastStat = lastStat
+	}
+	return true
+}
+
+// GetUsageDistributions gets the distributions of the CPU and memory usage over
+// the stats in the queue. It returns nil if distributions aren't enabled.
+func (queue *Queue) GetUsageDistributions() *UsageDistributions {
+	queue.lock.RLock()
+	defer queue.lock.RUnlock()
+
+	if queue.cpuHistogram == nil {
+		return nil
+	}
+	return &UsageDistributions{
+		CPUUsagePerc:      queue.cpuHistogram.distribution(),
+		MemoryUsageInMegs: queue.memoryHistogram.distribution(),
+	}
+}
+
 // GetCPUStatsSet gets the stats set for CPU utilization.
 func (queue *Queue) GetCPUStatsSet() (*ecstcs.CWStatsSet, error) {
 	return queue.getCWStatsSet(getCPUUsagePerc)
@@ -510,3 +510,60 @@ func TestHugeCPUUsagePercentDoesntGetCapped(t *testing.T) {
 	require.Equal(t, int64(2), *statSet.SampleCount)
 	require.Equal(t, float64(30000001124), *statSet.Sum)
 }
+
+func TestQueueUsageDistributions(t *testing.T) {
+	queue := NewQueue(3)
+	stats := getContainerStats(false)
+	queue.add(stats[0])
+	assert.Nil(t, queue.GetUsageDistributions(), "Distributions should be disabled by default")
+
+	queue.enableDistributions()
+	distributions := queue.GetUsageDistributions()
+	require.NotNil(t, distributions)
+	assert.Nil(t, distributions.CPUUsagePerc, "No CPU usage from a single stat")
+	require.NotNil(t, distributions.MemoryUsageInMegs)
+	assert.Equal(t, uint64(1), distributions.MemoryUsageInMegs.SampleCount)
+
+	for _, stat := range stats[1:] {
+		queue.add(stat)
+	}
+	require.Len(t, queue.buffer, 3)
+	distributions = queue.GetUsageDistributions()
+	require.NotNil(t, distributions.CPUUsagePerc)
+	require.NotNil(t, distributions.MemoryUsageInMegs)
+	assert.Equal(t, uint64(3), distributions.CPUUsagePerc.SampleCount, "Only the stats in the window should be counted")
+	assert.Equal(t, uint64(3), distributions.MemoryUsageInMegs.SampleCount, "Only the stats in the window should be counted")
+
+	// The distribution kept up to date as stats are added is the one of the stats in the window
+	cpuHistogram := newWindowHistogram(scaleBuckets(CPUUsageBuckets, float64(numCores)))
+	memoryHistogram := newWindowHistogram(MemoryUsageBuckets)
+	for i := range queue.buffer {
+		cpuHistogram.add(getCPUUsagePerc(&queue.buffer[i]) * float64(numCores))
+		memoryHistogram.add(getMemoryUsagePerc(&queue.buffer[i]))
+	}
+	assert.Equal(t, cpuHistogram.counts, queue.cpuHistogram.counts)
+	assert.InDelta(t, cpuHistogram.distribution().Sum, distributions.CPUUsagePerc.Sum, 1e-6)
+	assert.Equal(t, memoryHistogram.distribution(), distributions.MemoryUsageInMegs)
+}
+
+func TestQueueCPUUsageDistributionOfSeveralCores(t *testing.T) {
+	defer func(cores uint64) { numCores = cores }(numCores)
+	numCores = 4
+
+	queue := NewQueue(3)
+	queue.enableDistributions()
+	timestamp := time.Now()
+	// The container uses 3 of the 4 cores, which is 75% of the instance
+	queue.add(&ContainerStats{cpuUsage: 0, timestamp: timestamp})
+	queue.add(&ContainerStats{cpuUsage: uint64(3 * time.Second / 4), timestamp: timestamp.Add(time.Second)})
+	assert.InDelta(t, 75, getCPUUsagePerc(&queue.buffer[1]), 1e-3)
+
+	distribution := queue.GetUsageDistributions().CPUUsagePerc
+	require.NotNil(t, distribution)
+	require.Len(t, distribution.Buckets, len(CPUUsageBuckets))
+	last := distribution.Buckets[len(distribution.Buckets)-1]
+	assert.Equal(t, float64(400), last.UpperBound, "The buckets should go up to all the cores")
+	assert.Equal(t, uint64(1), last.Count, "Usage above a core should be in the buckets")
+	assert.InDelta(t, 300, distribution.Sum, 1e-3, "Usage should be in percent of a core")
+	assert.True(t, distribution.P50 > 280 && distribution.P50 <= 320, "Unexpected median %f", distribution.P50)
+}
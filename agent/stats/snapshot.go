@@ -0,0 +1,232 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// statsSnapshotFile is the file in the data directory the stats of the
+	// watched containers are saved to
+	statsSnapshotFile = "ecs_agent_stats.json"
+	// statsCheckpointInterval is how often the stats are saved while the
+	// agent runs, in case it doesn't get to save them when it stops
+	statsCheckpointInterval = 30 * time.Second
+)
+
+// statsSnapshot is what's saved of the stats of the watched containers, so
+// that their windows carry over an agent restart
+type statsSnapshot struct {
+	SavedAt time.Time `json:"savedAt"`
+	// Containers maps the docker IDs of the watched containers to their stats
+	Containers map[string]*savedContainerStats `json:"containers"`
+}
+
+// savedContainerStats are the saved stats of a container
+type savedContainerStats struct {
+	Stats    []savedUsageStats `json:"stats"`
+	LastStat *types.StatsJSON  `json:"lastStat,omitempty"`
+}
+
+// savedUsageStats is a saved UsageStats, including the fields it keeps to
+// compute the usage of the next stat
+type savedUsageStats struct {
+	// CPUUsagePerc is nil for the first stat of a queue, whose usage is NaN,
+	// which can't be encoded in JSON
+	CPUUsagePerc      *float32      `json:"cpuUsagePerc,omitempty"`
+	MemoryUsageInMegs uint32        `json:"memoryUsageInMegs"`
+	StorageReadBytes  uint64        `json:"storageReadBytes"`
+	StorageWriteBytes uint64        `json:"storageWriteBytes"`
+	NetworkStats      *NetworkStats `json:"networkStats,omitempty"`
+	Timestamp         time.Time     `json:"timestamp"`
+	CPUUsage          uint64        `json:"cpuUsage"`
+	MemoryUsage       uint64        `json:"memoryUsage"`
+	Sent              bool          `json:"sent"`
+}
+
+func newSavedUsageStats(stat UsageStats) savedUsageStats {
+	saved := savedUsageStats{
+		MemoryUsageInMegs: stat.MemoryUsageInMegs,
+		StorageReadBytes:  stat.StorageReadBytes,
+		StorageWriteBytes: stat.StorageWriteBytes,
+		NetworkStats:      stat.NetworkStats,
+		Timestamp:         stat.Timestamp,
+		CPUUsage:          stat.cpuUsage,
+		MemoryUsage:       stat.memoryUsage,
+		Sent:              stat.sent,
+	}
+	if !math.IsNaN(float64(stat.CPUUsagePerc)) {
+		cpuUsagePerc := stat.CPUUsagePerc
+		saved.CPUUsagePerc = &cpuUsagePerc
+	}
+	return saved
+}
+
+func (saved *savedUsageStats) usageStats() UsageStats {
+	stat := UsageStats{
+		CPUUsagePerc:      float32(nan32()),
+		MemoryUsageInMegs: saved.MemoryUsageInMegs,
+		StorageReadBytes:  saved.StorageReadBytes,
+		StorageWriteBytes: saved.StorageWriteBytes,
+		NetworkStats:      saved.NetworkStats,
+		Timestamp:         saved.Timestamp,
+		cpuUsage:          saved.CPUUsage,
+		memoryUsage:       saved.MemoryUsage,
+		sent:              saved.Sent,
+	}
+	if saved.CPUUsagePerc != nil {
+		stat.CPUUsagePerc = *saved.CPUUsagePerc
+	}
+	return stat
+}
+
+// SaveStats saves the stats of the watched containers to the data directory,
+// so that they're restored if the agent restarts while the containers are
+// running. Nothing is saved unless checkpointing is enabled and the engine is
+// initialized, so that the stats saved before a restart are kept until then.
+func (engine *DockerStatsEngine) SaveStats() error {
+	if !engine.config.Checkpoint {
+		return nil
+	}
+	engine.lock.RLock()
+	if engine.resolver == nil {
+		engine.lock.RUnlock()
+		return nil
+	}
+	snapshot := statsSnapshot{
+		SavedAt:    time.Now(),
+		Containers: make(map[string]*savedContainerStats),
+	}
+	for _, containerMap := range engine.tasksToContainers {
+		for dockerID, container := range containerMap {
+			if container.statsQueue == nil {
+				continue
+			}
+			stats, lastStat := container.statsQueue.snapshot()
+			snapshot.Containers[dockerID] = &savedContainerStats{Stats: stats, LastStat: lastStat}
+		}
+	}
+	engine.lock.RUnlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "stats snapshot: unable to encode the stats")
+	}
+	return writeStatsSnapshot(engine.config.DataDir, data)
+}
+
+// writeStatsSnapshot replaces the stats snapshot file with a temporary file in
+// the same directory, so that it's never left half written
+func writeStatsSnapshot(dataDir string, data []byte) error {
+	tmpfile, err := ioutil.TempFile(dataDir, "tmp_"+statsSnapshotFile)
+	if err != nil {
+		return errors.Wrap(err, "stats snapshot: unable to create a temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.Write(data)
+	if err == nil {
+		err = tmpfile.Sync()
+	}
+	if closeErr := tmpfile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "stats snapshot: unable to write the temporary file")
+	}
+	if err := os.Rename(tmpfile.Name(), filepath.Join(dataDir, statsSnapshotFile)); err != nil {
+		return errors.Wrap(err, "stats snapshot: unable to replace the snapshot file")
+	}
+	return nil
+}
+
+// loadSavedStats reads the stats saved before the agent restarted. It returns
+// nil if there are none, or if they're older than the window.
+func (engine *DockerStatsEngine) loadSavedStats() *statsSnapshot {
+	if !engine.config.Checkpoint {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(engine.config.DataDir, statsSnapshotFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			seelog.Warnf("Unable to read the saved container stats: %v", err)
+		}
+		return nil
+	}
+	var snapshot statsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		seelog.Warnf("Unable to decode the saved container stats, ignoring them: %v", err)
+		return nil
+	}
+	if time.Since(snapshot.SavedAt) > statsWindow {
+		seelog.Debugf("Ignoring the container stats saved at %s, which are older than the stats window",
+			snapshot.SavedAt.Format(time.RFC3339))
+		return nil
+	}
+	seelog.Infof("Loaded the saved stats of %d containers", len(snapshot.Containers))
+	return &snapshot
+}
+
+// restoreStatsUnsafe restores the saved stats of a container into its queue,
+// leaving out the ones older than the window. The start time of the container
+// is the one docker reported when the task engine synchronized its state.
+func (engine *DockerStatsEngine) restoreStatsUnsafe(container *StatsContainer) {
+	if engine.savedStats == nil {
+		return
+	}
+	dockerID := container.containerMetadata.DockerID
+	saved, ok := engine.savedStats.Containers[dockerID]
+	if !ok {
+		return
+	}
+	delete(engine.savedStats.Containers, dockerID)
+	var startedAt time.Time
+	if dockerContainer, err := engine.resolver.ResolveContainer(dockerID); err == nil {
+		startedAt = dockerContainer.Container.GetStartedAt()
+	}
+	if !container.statsQueue.restore(saved.Stats, saved.LastStat, engine.savedStats.SavedAt, startedAt,
+		time.Now().Add(-statsWindow)) {
+		seelog.Infof("Dropped the saved stats of container %s, which was restarted at %s after they were saved",
+			dockerID, startedAt.Format(time.RFC3339))
+		return
+	}
+	seelog.Debugf("Restored the saved stats of container %s", dockerID)
+}
+
+// checkpointStats saves the stats of the watched containers periodically,
+// until the context is done
+func (engine *DockerStatsEngine) checkpointStats(ctx context.Context) {
+	ticker := time.NewTicker(statsCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := engine.SaveStats(); err != nil {
+				seelog.Warnf("Error saving container stats: %v", err)
+			}
+		}
+	}
+}
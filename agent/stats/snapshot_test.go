@@ -0,0 +1,213 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stats
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
+	"github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSnapshotTestEngine returns an engine that saves its stats to a temporary
+// data directory, and looks initialized
+func newSnapshotTestEngine(t *testing.T, dataDir string) *DockerStatsEngine {
+	testConfig := config.DefaultConfig()
+	testConfig.Checkpoint = true
+	testConfig.DataDir = dataDir
+	engine := NewDockerStatsEngine(&testConfig, nil, eventStream(t.Name()))
+	engine.resolver = &DockerContainerMetadataResolver{}
+	return engine
+}
+
+// addRecentStats adds stats to a queue, a second apart and ending at end
+func addRecentStats(queue *Queue, end time.Time, count int) {
+	for i := 0; i < count; i++ {
+		queue.add(&ContainerStats{
+			cpuUsage:     uint64(i) * 500000000,
+			memoryUsage:  uint64(i+1) * BytesInMiB,
+			networkStats: &NetworkStats{RxBytes: uint64(i) * 100},
+			timestamp:    end.Add(time.Duration(i-count+1) * time.Second),
+		})
+	}
+}
+
+func TestSaveAndRestoreStats(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "stats-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	// Saved times have no monotonic clock reading
+	now := time.Now().UTC().Round(0)
+	engine := newSnapshotTestEngine(t, dataDir)
+	container := addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 0)
+	addRecentStats(container.statsQueue, now, 3)
+	container.statsQueue.Reset()
+	lastStat := &types.StatsJSON{}
+	lastStat.Read = now
+	container.statsQueue.setLastStat(lastStat)
+	require.NoError(t, engine.SaveStats())
+
+	restarted := newSnapshotTestEngine(t, dataDir)
+	restarted.savedStats = restarted.loadSavedStats()
+	require.NotNil(t, restarted.savedStats)
+	require.Contains(t, restarted.savedStats.Containers, "c1")
+	restoredContainer := &StatsContainer{
+		containerMetadata: &ContainerMetadata{DockerID: "c1"},
+		statsQueue:        NewQueue(5),
+	}
+	restarted.restoreStatsUnsafe(restoredContainer)
+	assert.Empty(t, restarted.savedStats.Containers, "Saved stats should only be restored once")
+
+	saved, _ := container.statsQueue.snapshot()
+	restored, restoredLastStat := restoredContainer.statsQueue.snapshot()
+	assert.Equal(t, saved, restored)
+	assert.Nil(t, restored[0].CPUUsagePerc, "There's no CPU usage for the first stat")
+	assert.True(t, restored[2].Sent, "Stats sent before the restart should not be sent again")
+	assert.Equal(t, lastStat.Read.UnixNano(), restoredLastStat.Read.UnixNano())
+
+	// The CPU usage of the next stat is computed from the restored ones
+	restoredContainer.statsQueue.add(&ContainerStats{cpuUsage: 1500000000, timestamp: now.Add(time.Second)})
+	usage, ok := restoredContainer.statsQueue.getLastUsageStats()
+	require.True(t, ok)
+	assert.InDelta(t, 50, usage.CPUUsagePerc, 0.001)
+}
+
+func TestRestoreStatsIgnoresOldStats(t *testing.T) {
+	queue := NewQueue(5)
+	addRecentStats(queue, time.Now(), 2)
+	addRecentStats(queue, time.Now().Add(-2*statsWindow), 1)
+	saved, _ := queue.snapshot()
+	lastStat := &types.StatsJSON{}
+	lastStat.Read = time.Now().Add(-2 * statsWindow)
+
+	restored := NewQueue(5)
+	assert.True(t, restored.restore(saved, lastStat, time.Now(), time.Time{}, time.Now().Add(-statsWindow)))
+	stats, restoredLastStat := restored.snapshot()
+	assert.Equal(t, saved[:2], stats)
+	assert.Nil(t, restoredLastStat)
+}
+
+func TestRestoreStatsKeepsDistributions(t *testing.T) {
+	queue := NewQueue(5)
+	addRecentStats(queue, time.Now(), 3)
+	saved, _ := queue.snapshot()
+
+	restored := NewQueue(2)
+	restored.enableDistributions()
+	assert.True(t, restored.restore(saved, nil, time.Now(), time.Time{}, time.Now().Add(-statsWindow)))
+	stats, _ := restored.snapshot()
+	assert.Equal(t, saved[1:], stats, "Only the most recent stats should fit in the queue")
+	distributions := restored.GetUsageDistributions()
+	require.NotNil(t, distributions.MemoryUsageInMegs)
+	assert.Equal(t, uint64(2), distributions.MemoryUsageInMegs.SampleCount)
+	assert.Equal(t, float64(5), distributions.MemoryUsageInMegs.Sum)
+}
+
+func TestRestoreStatsOfRestartedContainer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dataDir, err := ioutil.TempDir("", "stats-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	engine := newSnapshotTestEngine(t, dataDir)
+	for _, dockerID := range []string{"c1", "c2"} {
+		container := addTestStatsContainer(engine, "t1", dockerID, dockerID, "bridge", 0)
+		addRecentStats(container.statsQueue, time.Now(), 2)
+	}
+	require.NoError(t, engine.SaveStats())
+
+	restarted := newSnapshotTestEngine(t, dataDir)
+	restarted.savedStats = restarted.loadSavedStats()
+	require.NotNil(t, restarted.savedStats)
+	startedAt := func(startedAt time.Time) *apicontainer.DockerContainer {
+		container := &apicontainer.Container{}
+		container.SetStartedAt(startedAt)
+		return &apicontainer.DockerContainer{Container: container}
+	}
+	resolver := mock_resolver.NewMockContainerMetadataResolver(ctrl)
+	resolver.EXPECT().ResolveContainer("c1").Return(startedAt(restarted.savedStats.SavedAt.Add(-time.Minute)), nil)
+	resolver.EXPECT().ResolveContainer("c2").Return(startedAt(restarted.savedStats.SavedAt.Add(time.Second)), nil)
+	restarted.resolver = resolver
+
+	running := &StatsContainer{containerMetadata: &ContainerMetadata{DockerID: "c1"}, statsQueue: NewQueue(5)}
+	restarted.restoreStatsUnsafe(running)
+	stats, _ := running.statsQueue.snapshot()
+	assert.Len(t, stats, 2)
+
+	started := &StatsContainer{containerMetadata: &ContainerMetadata{DockerID: "c2"}, statsQueue: NewQueue(5)}
+	restarted.restoreStatsUnsafe(started)
+	stats, lastStat := started.statsQueue.snapshot()
+	assert.Empty(t, stats, "The stats of a container started after they were saved should be dropped")
+	assert.Nil(t, lastStat)
+	assert.Empty(t, restarted.savedStats.Containers)
+}
+
+func TestLoadSavedStatsIgnoresOldSnapshot(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "stats-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	engine := newSnapshotTestEngine(t, dataDir)
+	container := addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 0)
+	addRecentStats(container.statsQueue, time.Now(), 2)
+	require.NoError(t, engine.SaveStats())
+	assert.NotNil(t, engine.loadSavedStats())
+
+	data := []byte(`{"savedAt":"2015-02-12T21:22:05Z","containers":{"c1":{"stats":[]}}}`)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, statsSnapshotFile), data, 0644))
+	assert.Nil(t, engine.loadSavedStats())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, statsSnapshotFile), []byte("{"), 0644))
+	assert.Nil(t, engine.loadSavedStats(), "Corrupted snapshots should be ignored")
+}
+
+func TestSaveStatsDisabled(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "stats-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+
+	engine := newSnapshotTestEngine(t, dataDir)
+	engine.config.Checkpoint = false
+	addTestStatsContainer(engine, "t1", "c1", "web", "bridge", 1)
+	require.NoError(t, engine.SaveStats())
+	_, err = os.Stat(filepath.Join(dataDir, statsSnapshotFile))
+	assert.True(t, os.IsNotExist(err), "Stats should not be saved without checkpointing")
+
+	engine.config.Checkpoint = true
+	engine.resolver = nil
+	require.NoError(t, engine.SaveStats())
+	_, err = os.Stat(filepath.Join(dataDir, statsSnapshotFile))
+	assert.True(t, os.IsNotExist(err), "Stats should not be saved before the engine is initialized")
+}
+
+func TestSavedUsageStatsNaN(t *testing.T) {
+	saved := newSavedUsageStats(UsageStats{CPUUsagePerc: float32(math.NaN())})
+	assert.Nil(t, saved.CPUUsagePerc)
+	stat := saved.usageStats()
+	assert.True(t, math.IsNaN(float64(stat.CPUUsagePerc)))
+}
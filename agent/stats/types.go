@@ -54,6 +54,7 @@ type UsageStats struct {
 	NetworkStats      *NetworkStats `json:"networkStats"`
 	Timestamp         time.Time     `json:"timestamp"`
 	cpuUsage          uint64
+	memoryUsage       uint64
 	// sent indicates if the stat has been sent to TACS already.
 	sent bool
 }
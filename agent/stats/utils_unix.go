@@ -24,13 +24,16 @@ import (
 // dockerStatsToContainerStats returns a new object of the ContainerStats object from docker stats.
 func dockerStatsToContainerStats(dockerStats *types.StatsJSON) (*ContainerStats, error) {
 	// The length of PercpuUsage represents the number of cores in an instance.
-	if len(dockerStats.CPUStats.CPUUsage.PercpuUsage) == 0 || numCores == uint64(0) {
+	// There are no per CPU usages on cgroup v2, where the online CPUs are
+	// reported instead.
+	if (len(dockerStats.CPUStats.CPUUsage.PercpuUsage) == 0 && dockerStats.CPUStats.OnlineCPUs == 0) ||
+		numCores == uint64(0) {
 		seelog.Debug("Invalid container statistics reported, no cpu core usage reported")
 		return nil, fmt.Errorf("Invalid container statistics reported, no cpu core usage reported")
 	}
 
 	cpuUsage := dockerStats.CPUStats.CPUUsage.TotalUsage / numCores
-	memoryUsage := dockerStats.MemoryStats.Usage - dockerStats.MemoryStats.Stats["cache"]
+	memoryUsage := dockerStats.MemoryStats.Usage - memoryCache(dockerStats.MemoryStats.Stats)
 	storageReadBytes, storageWriteBytes := getStorageStats(dockerStats)
 	networkStats := getNetworkStats(dockerStats)
 	return &ContainerStats{
@@ -43,6 +46,16 @@ func dockerStatsToContainerStats(dockerStats *types.StatsJSON) (*ContainerStats,
 	}, nil
 }
 
+// memoryCache returns the page cache that's left out of the memory usage. It's
+// "cache" on cgroup v1, and only the inactive part of it is reported on cgroup
+// v2, as "inactive_file".
+func memoryCache(memoryStats map[string]uint64) uint64 {
+	if cache, ok := memoryStats["cache"]; ok {
+		return cache
+	}
+	return memoryStats["inactive_file"]
+}
+
 func getStorageStats(dockerStats *types.StatsJSON) (uint64, uint64) {
 	// initialize block io and loop over stats to aggregate
 	if dockerStats.BlkioStats.IoServiceBytesRecursive == nil {
@@ -30,8 +30,10 @@ func TestDockerStatsToContainerStatsEmptyCpuUsageGeneratesError(t *testing.T) {
 	jsonBytes, _ := ioutil.ReadFile(inputJsonFile)
 	dockerStat := &types.StatsJSON{}
 	json.Unmarshal([]byte(jsonBytes), dockerStat)
-	// empty the PercpuUsage array
+	// empty the PercpuUsage array, and leave out the online CPUs reported
+	// instead on cgroup v2
 	dockerStat.CPUStats.CPUUsage.PercpuUsage = make([]uint64, 0)
+	dockerStat.CPUStats.OnlineCPUs = 0
 	_, err := dockerStatsToContainerStats(dockerStat)
 	assert.Error(t, err, "expected error converting container stats with empty PercpuUsage")
 }
@@ -57,3 +59,17 @@ func TestDockerStatsToContainerStats(t *testing.T) {
 	assert.NotNil(t, netStats, "networkStats should not be nil")
 	validateNetworkMetrics(t, netStats)
 }
+
+func TestDockerStatsToContainerStatsCgroupV2(t *testing.T) {
+	numCores = 4
+	dockerStat := &types.StatsJSON{}
+	dockerStat.CPUStats.CPUUsage.TotalUsage = 4000
+	dockerStat.CPUStats.OnlineCPUs = 4
+	dockerStat.MemoryStats.Usage = 300
+	dockerStat.MemoryStats.Stats = map[string]uint64{"inactive_file": 100, "active_file": 50}
+	containerStats, err := dockerStatsToContainerStats(dockerStat)
+	require.NoError(t, err, "stats without per CPU usages should be converted when the online CPUs are reported")
+	assert.Equal(t, uint64(1000), containerStats.cpuUsage)
+	assert.Equal(t, uint64(200), containerStats.memoryUsage)
+	assert.Nil(t, containerStats.networkStats)
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 
 	"github.com/cihub/seelog"
 	"github.com/docker/docker/api/types"
@@ -70,7 +71,7 @@ type ASMAuthResource struct {
 	terminalReasonOnce sync.Once
 
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // NewASMAuthResource creates a new ASMAuthResource object
@@ -417,6 +418,12 @@ func (auth *ASMAuthResource) GetAppliedStatus() resourcestatus.ResourceStatus {
 	return auth.appliedStatus
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (auth *ASMAuthResource) ChangeVersion() uint64 {
+	return auth.lock.Version()
+}
+
 func (auth *ASMAuthResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
@@ -75,7 +76,7 @@ type ASMSecretResource struct {
 	terminalReasonOnce sync.Once
 
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // NewASMSecretResource creates a new ASMSecretResource object
@@ -523,6 +524,12 @@ func (secret *ASMSecretResource) GetAppliedStatus() resourcestatus.ResourceStatu
 	return secret.appliedStatus
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (secret *ASMSecretResource) ChangeVersion() uint64 {
+	return secret.lock.Version()
+}
+
 func (secret *ASMSecretResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -19,7 +19,6 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
 	"time"
 
 	apicontainer "github.com/aws/amazon-ecs-agent/agent/api/container"
@@ -29,6 +28,7 @@ import (
 	control "github.com/aws/amazon-ecs-agent/agent/taskresource/cgroup/control"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/cihub/seelog"
 	"github.com/containerd/cgroups"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -65,7 +65,7 @@ type CgroupResource struct {
 	appliedStatus       resourcestatus.ResourceStatus
 	statusToTransitions map[resourcestatus.ResourceStatus]func() error
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // NewCgroupResource is used to return an object that implements the Resource interface
@@ -382,6 +382,12 @@ func (cgroup *CgroupResource) Initialize(resourceFields *taskresource.ResourceFi
 	cgroup.control = resourceFields.Control
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (cgroup *CgroupResource) ChangeVersion() uint64 {
+	return cgroup.lock.Version()
+}
+
 func (cgroup *CgroupResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -134,6 +134,11 @@ func (cgroup *CgroupResource) Initialize(resourceFields *taskresource.ResourceFi
 	taskDesiredStatus status.TaskStatus) {
 }
 
+// ChangeVersion returns 0, as the resource never changes on this platform
+func (cgroup *CgroupResource) ChangeVersion() uint64 {
+	return 0
+}
+
 func (cgroup *CgroupResource) DependOnTaskNetwork() bool {
 	return false
 }
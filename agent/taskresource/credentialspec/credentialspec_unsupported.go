@@ -161,6 +161,11 @@ func (cs *CredentialSpecResource) GetAppliedStatus() resourcestatus.ResourceStat
 	return resourcestatus.ResourceStatusNone
 }
 
+// ChangeVersion returns 0, as the resource never changes on this platform
+func (cs *CredentialSpecResource) ChangeVersion() uint64 {
+	return 0
+}
+
 func (cs *CredentialSpecResource) DependOnTaskNetwork() bool {
 	return false
 }
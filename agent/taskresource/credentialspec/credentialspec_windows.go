@@ -36,6 +36,7 @@ import (
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
 	"github.com/aws/amazon-ecs-agent/agent/utils/oswrapper"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/cihub/seelog"
 	"github.com/pkg/errors"
@@ -80,7 +81,7 @@ type CredentialSpecResource struct {
 	// * key := credentialspec:ssmARN, value := credentialspec=file://CredentialSpecResourceLocation/ssm_taskARN_param.json
 	CredSpecMap map[string]string
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // NewCredentialSpecResource creates a new CredentialSpecResource object
@@ -638,6 +639,12 @@ func (cs *CredentialSpecResource) GetAppliedStatus() resourcestatus.ResourceStat
 	return resourcestatus.ResourceStatusNone
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (cs *CredentialSpecResource) ChangeVersion() uint64 {
+	return cs.lock.Version()
+}
+
 func (cs *CredentialSpecResource) DependOnTaskNetwork() bool {
 	return false
 }
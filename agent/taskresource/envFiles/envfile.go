@@ -36,6 +36,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	"github.com/aws/amazon-ecs-agent/agent/utils/bufiowrapper"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 )
 
 const (
@@ -76,7 +77,7 @@ type EnvironmentFileResource struct {
 	statusToTransitions  map[resourcestatus.ResourceStatus]func() error
 	terminalReasonUnsafe string
 	terminalReasonOnce   sync.Once
-	lock                 sync.RWMutex
+	lock                 utilsync.VersionedRWMutex
 }
 
 // NewEnvironmentFileResource creates a new EnvironmentFileResource object
@@ -579,6 +580,12 @@ func (envfile *EnvironmentFileResource) GetAppliedStatus() resourcestatus.Resour
 }
 
 // DependOnTaskNetwork shows whether the resource creation needs task network setup beforehand
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (envfile *EnvironmentFileResource) ChangeVersion() uint64 {
+	return envfile.lock.Version()
+}
+
 func (envfile *EnvironmentFileResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -163,6 +163,11 @@ func (firelens *FirelensResource) GetAppliedStatus() resourcestatus.ResourceStat
 	return resourcestatus.ResourceStatusNone
 }
 
+// ChangeVersion returns 0, as the resource never changes on this platform
+func (firelens *FirelensResource) ChangeVersion() uint64 {
+	return 0
+}
+
 func (firelens *FirelensResource) DependOnTaskNetwork() bool {
 	return false
 }
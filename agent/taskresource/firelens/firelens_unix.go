@@ -36,6 +36,7 @@ import (
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ioutilwrapper"
 	"github.com/aws/amazon-ecs-agent/agent/utils/oswrapper"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 )
 
 const (
@@ -91,7 +92,7 @@ type FirelensResource struct {
 	statusToTransitions map[resourcestatus.ResourceStatus]func() error
 	terminalReason      string
 	terminalReasonOnce  sync.Once
-	lock                sync.RWMutex
+	lock                utilsync.VersionedRWMutex
 }
 
 // NewFirelensResource returns a new FirelensResource.
@@ -553,6 +554,12 @@ func (firelens *FirelensResource) Cleanup() error {
 	return nil
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (firelens *FirelensResource) ChangeVersion() uint64 {
+	return firelens.lock.Version()
+}
+
 func (firelens *FirelensResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -65,6 +65,10 @@ type TaskResource interface {
 	// GetTerminalReason returns string describing why the resource failed to
 	// provision
 	GetTerminalReason() string
+	// ChangeVersion returns a value that's larger every time the resource may
+	// have changed, so that its task doesn't need to be saved again if it's the
+	// same
+	ChangeVersion() uint64
 	// DependOnTaskNetwork shows whether the resource creation needs task network setup beforehand
 	DependOnTaskNetwork() bool
 	// GetContainerDependencies returns dependent containers for a status
@@ -79,6 +79,20 @@ func (mr *MockTaskResourceMockRecorder) BuildContainerDependency(arg0, arg1, arg
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildContainerDependency", reflect.TypeOf((*MockTaskResource)(nil).BuildContainerDependency), arg0, arg1, arg2)
 }
 
+// ChangeVersion mocks base method
+func (m *MockTaskResource) ChangeVersion() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeVersion")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// ChangeVersion indicates an expected call of ChangeVersion
+func (mr *MockTaskResourceMockRecorder) ChangeVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeVersion", reflect.TypeOf((*MockTaskResource)(nil).ChangeVersion))
+}
+
 // Cleanup mocks base method
 func (m *MockTaskResource) Cleanup() error {
 	m.ctrl.T.Helper()
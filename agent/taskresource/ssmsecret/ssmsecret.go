@@ -30,6 +30,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/ssm/factory"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 )
 
 const (
@@ -71,7 +72,7 @@ type SSMSecretResource struct {
 	terminalReasonOnce sync.Once
 
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // NewSSMSecretResource creates a new SSMSecretResource object
@@ -489,6 +490,12 @@ func (secret *SSMSecretResource) GetAppliedStatus() resourcestatus.ResourceStatu
 	return secret.appliedStatus
 }
 
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (secret *SSMSecretResource) ChangeVersion() uint64 {
+	return secret.lock.Version()
+}
+
 func (secret *SSMSecretResource) DependOnTaskNetwork() bool {
 	return false
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/dockerclient/dockerapi"
 	"github.com/aws/amazon-ecs-agent/agent/taskresource"
 	resourcestatus "github.com/aws/amazon-ecs-agent/agent/taskresource/status"
+	utilsync "github.com/aws/amazon-ecs-agent/agent/utils/sync"
 	"github.com/cihub/seelog"
 	"github.com/pkg/errors"
 )
@@ -74,7 +75,7 @@ type VolumeResource struct {
 	terminalReasonOnce sync.Once
 
 	// lock is used for fields that are accessed and updated concurrently
-	lock sync.RWMutex
+	lock utilsync.VersionedRWMutex
 }
 
 // DockerVolumeConfig represents docker volume configuration
@@ -436,6 +437,12 @@ func (vol *VolumeResource) GetAppliedStatus() resourcestatus.ResourceStatus {
 }
 
 // DependOnTaskNetwork shows whether the resource creation needs task network setup beforehand
+// ChangeVersion returns a value that's larger every time the resource may have
+// changed, as long as its fields are only changed with its lock held
+func (vol *VolumeResource) ChangeVersion() uint64 {
+	return vol.lock.Version()
+}
+
 func (vol *VolumeResource) DependOnTaskNetwork() bool {
 	return vol.VolumeType == EFSVolumeType
 }
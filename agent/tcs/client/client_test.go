@@ -60,6 +60,14 @@ func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*types.
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (*mockStatsEngine) ContainerUsageDistributions(taskARN string, id string) (*stats.UsageDistributions, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (*mockStatsEngine) ContainerDiskUsage(taskARN string, id string) (*stats.ContainerDiskUsage, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (*mockStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	return nil, nil, nil
 }
@@ -74,6 +82,14 @@ func (*emptyStatsEngine) ContainerDockerStats(taskARN string, id string) (*types
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (*emptyStatsEngine) ContainerUsageDistributions(taskARN string, id string) (*stats.UsageDistributions, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (*emptyStatsEngine) ContainerDiskUsage(taskARN string, id string) (*stats.ContainerDiskUsage, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (*emptyStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	return nil, nil, nil
 }
@@ -94,6 +110,14 @@ func (*idleStatsEngine) ContainerDockerStats(taskARN string, id string) (*types.
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (*idleStatsEngine) ContainerUsageDistributions(taskARN string, id string) (*stats.UsageDistributions, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (*idleStatsEngine) ContainerDiskUsage(taskARN string, id string) (*stats.ContainerDiskUsage, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (*idleStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	return nil, nil, nil
 }
@@ -122,6 +146,14 @@ func (*nonIdleStatsEngine) ContainerDockerStats(taskARN string, id string) (*typ
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (*nonIdleStatsEngine) ContainerUsageDistributions(taskARN string, id string) (*stats.UsageDistributions, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (*nonIdleStatsEngine) ContainerDiskUsage(taskARN string, id string) (*stats.ContainerDiskUsage, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (*nonIdleStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	return nil, nil, nil
 }
@@ -32,6 +32,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	mock_engine "github.com/aws/amazon-ecs-agent/agent/engine/mocks"
 	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	tcsclient "github.com/aws/amazon-ecs-agent/agent/tcs/client"
 	"github.com/aws/amazon-ecs-agent/agent/tcs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/version"
@@ -71,6 +72,14 @@ func (*mockStatsEngine) ContainerDockerStats(taskARN string, id string) (*types.
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (*mockStatsEngine) ContainerUsageDistributions(taskARN string, id string) (*stats.UsageDistributions, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (*mockStatsEngine) ContainerDiskUsage(taskARN string, id string) (*stats.ContainerDiskUsage, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 func (*mockStatsEngine) GetTaskHealthMetrics() (*ecstcs.HealthMetadata, []*ecstcs.TaskHealth, error) {
 	return nil, nil, nil
 }
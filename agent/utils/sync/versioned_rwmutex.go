@@ -0,0 +1,41 @@
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sync
+
+import (
+	stdsync "sync"
+	"sync/atomic"
+)
+
+// A VersionedRWMutex is a reader/writer mutual exclusion lock that counts how
+// many times it was locked for writing. When the fields of a struct are only
+// changed with its lock held for writing, the count tells whether the struct
+// may have changed since it was last looked at without comparing it.
+// The zero value is an unlocked mutex at version 0.
+type VersionedRWMutex struct {
+	stdsync.RWMutex
+	version uint64
+}
+
+// Lock locks the mutex for writing, and moves it to the next version
+func (m *VersionedRWMutex) Lock() {
+	m.RWMutex.Lock()
+	atomic.AddUint64(&m.version, 1)
+}
+
+// Version returns how many times the mutex was locked for writing. It can be
+// called whether the mutex is locked or not.
+func (m *VersionedRWMutex) Version() uint64 {
+	return atomic.LoadUint64(&m.version)
+}
@@ -0,0 +1,42 @@
+// +build unit
+
+// Copyright Amazon.com Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sync
+
+import "testing"
+
+func TestVersionedRWMutex(t *testing.T) {
+	var m VersionedRWMutex
+	if m.Version() != 0 {
+		t.Fatalf("Expected version 0, got %d", m.Version())
+	}
+
+	m.RLock()
+	m.RUnlock()
+	if m.Version() != 0 {
+		t.Errorf("Locking for reading should not change the version, got %d", m.Version())
+	}
+
+	m.Lock()
+	if m.Version() != 1 {
+		t.Errorf("Expected version 1 while locked, got %d", m.Version())
+	}
+	m.Unlock()
+	m.Lock()
+	m.Unlock()
+	if m.Version() != 2 {
+		t.Errorf("Expected version 2, got %d", m.Version())
+	}
+}